@@ -0,0 +1,10 @@
+// mockgo 统一的命令行入口，包含 http mock、数据库性能测试、主机扫描、ES 导入和数据集生成。
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	os.Exit(execute(os.Args[1:]))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/goccy/go-yaml"
+)
+
+// 默认的项目配置文件
+const defaultProjectFile = "mockgo.yaml"
+
+// projectConfig 项目配置文件，按名称定义多套环境参数，通过 --profile 选择
+type projectConfig struct {
+	DefaultProfile string                            `yaml:"default_profile"`
+	Profiles       map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// profile 一套环境参数，Seed 固定生成数据的随机种子，Commands 以子命令路径为键（如 serve、es load），
+// 值为该命令的参数名到参数值的映射
+type profile struct {
+	Seed          int64
+	Distributions string // 命名分布文件，--distributions 优先
+	Targets       string // 共享的连接配置文件，--targets 优先
+	Commands      map[string]map[string]interface{}
+}
+
+// 拆分 profile 中的公共字段和各命令的参数
+func parseProfile(raw map[string]interface{}) (profile, error) {
+	p := profile{Commands: make(map[string]map[string]interface{}, len(raw))}
+	for key, v := range raw {
+		switch key {
+		case "seed":
+			seed, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+			if err != nil {
+				return p, fmt.Errorf("seed must be an integer, got %v", v)
+			}
+			p.Seed = seed
+		case "distributions", "targets":
+			s, ok := v.(string)
+			if !ok {
+				return p, fmt.Errorf("%s must be a file path, got %v", key, v)
+			}
+			if key == "distributions" {
+				p.Distributions = s
+			} else {
+				p.Targets = s
+			}
+		default:
+			if v == nil {
+				continue
+			}
+			section, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("%s must be a map of flag names to values", key)
+			}
+			p.Commands[key] = section
+		}
+	}
+	return p, nil
+}
+
+// 读取项目配置并选出要使用的 profile，没有配置文件且未显式指定时返回 nil
+func loadProfile(path, name string, explicit bool) (*profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit && name == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read project file: %v", err)
+	}
+	var config projectConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse project file %s: %v", path, err)
+	}
+	if name == "" {
+		name = config.DefaultProfile
+	}
+	if name == "" {
+		return nil, nil
+	}
+	raw, ok := config.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(config.Profiles))
+		for n := range config.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found in %s (available: %s)", name, path, strings.Join(names, ", "))
+	}
+	p, err := parseProfile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s in %s: %v", name, path, err)
+	}
+	if p.Seed != 0 {
+		value.SetSeed(p.Seed)
+	}
+	return &p, nil
+}
+
+// 把 profile 中该命令的参数转换成命令行参数，放在用户参数之前，命令行上显式给出的参数优先。
+// 列表用逗号拼接，字符串中的 ${VAR} 按环境变量展开
+func (p *profile) args(command string, args []string) ([]string, error) {
+	if p == nil {
+		return args, nil
+	}
+	section := p.Commands[command]
+	var flags []string
+	for _, name := range sortedKeys(section) {
+		s, err := flagValue(section[name])
+		if err != nil {
+			return nil, fmt.Errorf("profile %s.%s: %v", command, name, err)
+		}
+		flags = append(flags, "-"+name+"="+s)
+	}
+	return append(flags, args...), nil
+}
+
+func flagValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return os.ExpandEnv(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", errors.New("nested maps are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/TreeWu/mock-go/db_benchmark"
+	"github.com/TreeWu/mock-go/demo"
+	"github.com/TreeWu/mock-go/es"
+	"github.com/TreeWu/mock-go/gen"
+	"github.com/TreeWu/mock-go/grpc_mock"
+	"github.com/TreeWu/mock-go/http_mock"
+	"github.com/TreeWu/mock-go/ldap_mock"
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/pgwire_mock"
+	"github.com/TreeWu/mock-go/redis_mock"
+	"github.com/TreeWu/mock-go/scan_os"
+	"github.com/TreeWu/mock-go/seed"
+	"github.com/TreeWu/mock-go/sftp_mock"
+	"github.com/TreeWu/mock-go/syslog_mock"
+	"github.com/TreeWu/mock-go/targets"
+	"github.com/TreeWu/mock-go/value"
+	"github.com/spf13/cobra"
+)
+
+// 所有子命令共用的参数，需要写在子命令之前，例如 mockgo --log-file run.log scan ...
+type globalOptions struct {
+	logFile       string
+	logLevel      string
+	logFormat     string
+	logModules    string
+	quiet         bool
+	profile       string
+	projectFile   string
+	distributions string
+	targets       string
+}
+
+// 执行命令行，返回进程退出码
+func execute(args []string) int {
+	var opts globalOptions
+	var logOutput io.Closer
+	var selected *profile
+	code := 0
+
+	root := &cobra.Command{
+		Use:           "mockgo",
+		Short:         "Mock servers, test data and loaders for development environments",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// 先解析根命令的参数，子命令的参数原样交给各工具解析
+		TraverseChildren: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			closer, err := setupLogging(opts)
+			logOutput = closer
+			if err != nil {
+				return err
+			}
+			explicit := cmd.Flags().Changed("project-file") || os.Getenv("MOCKGO_CONFIG") != ""
+			if selected, err = loadProfile(opts.projectFile, opts.profile, explicit); err != nil {
+				return err
+			}
+			distributions := opts.distributions
+			if distributions == "" && selected != nil {
+				distributions = selected.Distributions
+			}
+			if distributions != "" {
+				if err := value.LoadDistributions(distributions); err != nil {
+					return err
+				}
+			}
+			// 没有指定连接配置时，当前目录下有 targets.yaml 就加载
+			targetsFile := opts.targets
+			if targetsFile == "" && selected != nil {
+				targetsFile = selected.Targets
+			}
+			if targetsFile == "" {
+				if _, err := os.Stat(targets.DefaultFile); err == nil {
+					targetsFile = targets.DefaultFile
+				}
+			}
+			if targetsFile != "" {
+				return targets.Load(targetsFile)
+			}
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&opts.logFile, "log-file", "", "also append logs to this file")
+	root.PersistentFlags().StringVar(&opts.logLevel, "log-level", envOr("MOCKGO_LOG_LEVEL", "info"), "log level: debug, info, warn or error (env MOCKGO_LOG_LEVEL)")
+	root.PersistentFlags().StringVar(&opts.logFormat, "log-format", envOr("MOCKGO_LOG_FORMAT", logging.FormatText), "log format: text or json (env MOCKGO_LOG_FORMAT)")
+	root.PersistentFlags().StringVar(&opts.logModules, "log-module", os.Getenv("MOCKGO_LOG_MODULE"), "per-module log levels, e.g. es=debug,scan=warn (env MOCKGO_LOG_MODULE)")
+	root.PersistentFlags().BoolVar(&opts.quiet, "quiet", false, "discard logs on stderr")
+	root.PersistentFlags().StringVar(&opts.profile, "profile", os.Getenv("MOCKGO_PROFILE"), "profile from the project file to apply (env MOCKGO_PROFILE)")
+	root.PersistentFlags().StringVar(&opts.projectFile, "project-file", envOr("MOCKGO_CONFIG", defaultProjectFile), "project file defining profiles (env MOCKGO_CONFIG)")
+	root.PersistentFlags().StringVar(&opts.distributions, "distributions", os.Getenv("MOCKGO_DISTRIBUTIONS"), "yaml/json file of named weighted distributions referenced as @dist:<name> (env MOCKGO_DISTRIBUTIONS)")
+	root.PersistentFlags().StringVar(&opts.targets, "targets", os.Getenv("MOCKGO_TARGETS"), "yaml file of named databases, elasticsearch clusters and ssh credentials referenced by -*-target flags, "+targets.DefaultFile+" when present (env MOCKGO_TARGETS)")
+
+	// 子命令交给已有的 Run 函数执行，参数由各自的 FlagSet 解析，profile 中的参数排在前面
+	tool := func(use, short string, run func(args []string) int) *cobra.Command {
+		return &cobra.Command{
+			Use:                use,
+			Short:              short,
+			DisableFlagParsing: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				command := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+				args, err := selected.args(command, args)
+				if err != nil {
+					return err
+				}
+				code = run(args)
+				return nil
+			},
+		}
+	}
+
+	scenarioCmd := &cobra.Command{Use: "scenario", Short: "Multi-step flows usable as a stateful mock server or a load script"}
+	scenarioCmd.AddCommand(
+		tool("serve [flags]", "Serve a scenario as stateful mock endpoints", http_mock.RunScenarioServer),
+		tool("run [flags]", "Run a scenario against a real service and report latency per step", http_mock.RunScenarioClient),
+	)
+
+	esCmd := &cobra.Command{Use: "es", Short: "Elasticsearch and OpenSearch tools"}
+	esCmd.AddCommand(tool("load [flags] [files...]", "Bulk-load JSON/NDJSON files or generated documents into an index", es.Run))
+
+	root.AddCommand(
+		tool("serve [flags]", "Start the http mock server", http_mock.Run),
+		tool("attack [flags]", "Replay mock configs as a load generator against a real service", http_mock.RunAttack),
+		tool("replay [flags] files...", "Replay recorded traffic from a har file or request log against a real service", http_mock.RunReplay),
+		tool("grpc [flags]", "Serve proto services as gRPC mocks together with their google.api.http REST routes", grpc_mock.Run),
+		tool("sftp [flags]", "Serve a virtual filesystem over SFTP and FTP with upload capture and fault injection", sftp_mock.Run),
+		tool("ldap [flags]", "Serve a read-only LDAP directory with bind, search and templated users", ldap_mock.Run),
+		tool("syslog [flags]", "Receive syslog messages over udp and tcp with query and assertion apis", syslog_mock.Run),
+		tool("pgwire [flags]", "Experimental PostgreSQL protocol mock answering configured queries", pgwire_mock.Run),
+		tool("redis [flags]", "Redis protocol mock with scripted replies, delays and errors", redis_mock.Run),
+		tool("import [flags] files...", "Convert WireMock mappings or Mockoon environments into mock configs", http_mock.RunImport),
+		tool("infer [flags] files...", "Infer mock configs with value directives from sample json responses", http_mock.RunInfer),
+		tool("bench [flags]", "Compare insert and search performance of elasticsearch, postgresql and mongodb", db_benchmark.Run),
+		tool("scan [flags] [ranges...]", "Scan hosts over ssh, snmp or open ports", scan_os.Run),
+		scenarioCmd,
+		esCmd,
+		tool("gen [flags]", "Generate a dataset from a value template", gen.Run),
+		tool("seed [flags]", "Write generated records into es, pg, mysql, mongo or redis", seed.Run),
+		tool("demo [flags]", "Generate a dataset, seed it into a store and serve it through a mock api", demo.Run),
+	)
+
+	root.SetArgs(args)
+	err := root.Execute()
+	if logOutput != nil {
+		logOutput.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	return code
+}
+
+// 日志统一输出到 stderr，可以同时写入文件或只写入文件
+func setupLogging(opts globalOptions) (io.Closer, error) {
+	level, err := logging.ParseLevel(opts.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := logging.ParseModules(opts.logModules)
+	if err != nil {
+		return nil, err
+	}
+
+	var writers []io.Writer
+	if !opts.quiet {
+		writers = append(writers, os.Stderr)
+	}
+	var file *os.File
+	if opts.logFile != "" {
+		if file, err = os.OpenFile(opts.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, fmt.Errorf("open log file: %v", err)
+		}
+		writers = append(writers, file)
+	}
+	err = logging.Setup(logging.Options{
+		Level:   level,
+		Modules: modules,
+		Format:  opts.logFormat,
+		Output:  io.MultiWriter(writers...),
+	})
+	if file == nil {
+		return nil, err
+	}
+	return file, err
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
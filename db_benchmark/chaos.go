@@ -0,0 +1,318 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// 故障注入生效的阶段
+const (
+	PhaseInsert = "insert"
+	PhaseSearch = "search"
+)
+
+// ChaosConfig 故障注入配置，测试过程中按时间执行命令（如 docker pause、tc netem），
+// 统计每个引擎在故障期间的吞吐量下降和恢复时间
+type ChaosConfig struct {
+	Interval time.Duration `yaml:"interval"` // 吞吐量采样间隔，默认 1s
+	Recovery float64       `yaml:"recovery"` // 吞吐量回到故障前的多少算恢复，默认 0.9
+	Events   []ChaosEvent  `yaml:"events"`
+}
+
+// ChaosEvent 一次故障：阶段开始 at 之后执行 command，再过 duration 执行 recover。
+// 阶段提前结束时还没开始的故障不再执行，已开始的立即执行 recover，保证环境恢复
+type ChaosEvent struct {
+	Name     string        `yaml:"name"`
+	Engines  []string      `yaml:"engines"` // es、pg、mongo、etcd、graphql，为空时对所有引擎生效
+	Phase    string        `yaml:"phase"`   // insert（默认）或 search
+	At       time.Duration `yaml:"at"`
+	Command  string        `yaml:"command"`
+	Duration time.Duration `yaml:"duration"`
+	Recover  string        `yaml:"recover"`
+}
+
+// ChaosResult 一次故障对吞吐量的影响，吞吐量单位为 记录/秒（查询阶段为 次/秒）
+type ChaosResult struct {
+	Event    string
+	Database string
+	Phase    string
+	Baseline float64       // 故障前的平均吞吐量
+	During   float64       // 故障期间的平均吞吐量
+	Errors   int64         // 故障期间失败的批次或查询
+	Recovery time.Duration // 执行 recover 后吞吐量回到基线的耗时，-1 表示阶段结束前没有恢复
+	Mark     string
+}
+
+// Dip 故障期间吞吐量比基线下降的比例
+func (r ChaosResult) Dip() float64 {
+	if r.Baseline <= 0 {
+		return 0
+	}
+	return 1 - r.During/r.Baseline
+}
+
+func loadChaosConfig(path string) (*ChaosConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &ChaosConfig{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.Recovery <= 0 {
+		config.Recovery = 0.9
+	}
+	for i := range config.Events {
+		event := &config.Events[i]
+		if event.Name == "" {
+			event.Name = fmt.Sprintf("event %d", i+1)
+		}
+		if event.Command == "" {
+			return nil, fmt.Errorf("%s: missing command", event.Name)
+		}
+		switch event.Phase {
+		case "":
+			event.Phase = PhaseInsert
+		case PhaseInsert, PhaseSearch:
+		default:
+			return nil, fmt.Errorf("%s: unknown phase %q, want insert or search", event.Name, event.Phase)
+		}
+		for _, kind := range event.Engines {
+			if kind != "es" && kind != "pg" && kind != "mongo" && kind != "etcd" && kind != "graphql" {
+				return nil, fmt.Errorf("%s: unknown engine %q, want es, pg, mongo, etcd or graphql", event.Name, kind)
+			}
+		}
+	}
+	return config, nil
+}
+
+// 和 -engines 中的名称对应
+func engineKind(engine BenchmarkEngine) string {
+	switch engine.(type) {
+	case *ElasticsearchEngine:
+		return "es"
+	case *PostgresqlEngine:
+		return "pg"
+	case *MongoDB:
+		return "mongo"
+	case *EtcdEngine:
+		return "etcd"
+	case *GraphQLEngine:
+		return "graphql"
+	}
+	return ""
+}
+
+// 当前阶段完成的记录数和失败次数，引擎每完成一批插入或一次查询时累加，故障注入按它采样吞吐量
+var workload struct {
+	ops, failed atomic.Int64
+}
+
+func recordWorkload(ops, failed int) {
+	workload.ops.Add(int64(ops))
+	workload.failed.Add(int64(failed))
+}
+
+// 记录一批插入的结果，latency 为这一批的耗时
+func recordBatch(records int, latency time.Duration, err error) {
+	observeLatency(latency, err)
+	if err != nil {
+		recordWorkload(0, 1)
+		return
+	}
+	recordWorkload(records, 0)
+}
+
+// 一个采样间隔内完成的数量，at 是间隔结束时距阶段开始的时间
+type chaosSample struct {
+	at          time.Duration
+	ops, failed int64
+}
+
+// 一次故障实际执行的时间，距阶段开始
+type chaosFault struct {
+	event              ChaosEvent
+	started, recovered time.Duration
+	err                error
+}
+
+// chaosRun 一个引擎一个阶段的故障注入和吞吐量采样
+type chaosRun struct {
+	config   *ChaosConfig
+	engine   BenchmarkEngine
+	phase    string
+	start    time.Time
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	samples  []chaosSample
+	faults   []chaosFault
+	sampling chan struct{}
+}
+
+// 开始采样并按时间执行该引擎该阶段的故障，config 为 nil 时返回 nil
+func startChaos(config *ChaosConfig, engine BenchmarkEngine, phase string) *chaosRun {
+	if config == nil {
+		return nil
+	}
+	r := &chaosRun{
+		config:   config,
+		engine:   engine,
+		phase:    phase,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		sampling: make(chan struct{}),
+	}
+	workload.ops.Store(0)
+	workload.failed.Store(0)
+	go r.sample()
+	for _, event := range config.Events {
+		if event.Phase == phase && (len(event.Engines) == 0 || slices.Contains(event.Engines, engineKind(engine))) {
+			r.wg.Add(1)
+			go r.inject(event)
+		}
+	}
+	return r
+}
+
+func (r *chaosRun) sample() {
+	defer close(r.sampling)
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	var ops, failed int64
+	take := func() {
+		currentOps, currentFailed := workload.ops.Load(), workload.failed.Load()
+		r.mu.Lock()
+		r.samples = append(r.samples, chaosSample{at: time.Since(r.start), ops: currentOps - ops, failed: currentFailed - failed})
+		r.mu.Unlock()
+		ops, failed = currentOps, currentFailed
+	}
+	for {
+		select {
+		case <-ticker.C:
+			take()
+		case <-r.stop:
+			take()
+			return
+		}
+	}
+}
+
+func (r *chaosRun) inject(event ChaosEvent) {
+	defer r.wg.Done()
+	select {
+	case <-time.After(event.At):
+	case <-r.stop:
+		logger.Warn("阶段已结束，故障没有执行", "event", event.Name, "engine", r.engine.Name(), "phase", r.phase)
+		return
+	}
+	fault := chaosFault{event: event, started: time.Since(r.start)}
+	logger.Info("执行故障命令", "event", event.Name, "engine", r.engine.Name(), "command", event.Command)
+	fault.err = runTool(nil, "sh", "-c", event.Command)
+	if fault.err != nil {
+		logger.Error("故障命令执行失败", "event", event.Name, "err", fault.err)
+	}
+	if event.Recover != "" {
+		select {
+		case <-time.After(event.Duration):
+		case <-r.stop:
+		}
+		logger.Info("执行恢复命令", "event", event.Name, "engine", r.engine.Name(), "command", event.Recover)
+		if err := runTool(nil, "sh", "-c", event.Recover); err != nil {
+			logger.Error("恢复命令执行失败", "event", event.Name, "err", err)
+			fault.err = err
+		}
+	}
+	fault.recovered = time.Since(r.start)
+	r.mu.Lock()
+	r.faults = append(r.faults, fault)
+	r.mu.Unlock()
+}
+
+// 结束阶段：停止采样，执行还没执行的恢复命令，返回每次故障的统计
+func (r *chaosRun) finish() []ChaosResult {
+	if r == nil {
+		return nil
+	}
+	close(r.stop)
+	r.wg.Wait()
+	<-r.sampling
+
+	var results []ChaosResult
+	for _, fault := range r.faults {
+		result := ChaosResult{
+			Event:    fault.event.Name,
+			Database: r.engine.Name(),
+			Phase:    r.phase,
+			Recovery: -1,
+		}
+		var baselineOps, duringOps int64
+		var baselineTime, duringTime time.Duration
+		previous := time.Duration(0)
+		for _, sample := range r.samples {
+			if sample.at <= fault.started {
+				baselineOps += sample.ops
+				baselineTime += sample.at - previous
+			} else if previous < fault.recovered {
+				duringOps += sample.ops
+				duringTime += sample.at - previous
+				result.Errors += sample.failed
+			}
+			previous = sample.at
+		}
+		if baselineTime > 0 {
+			result.Baseline = float64(baselineOps) / baselineTime.Seconds()
+		}
+		if duringTime > 0 {
+			result.During = float64(duringOps) / duringTime.Seconds()
+		}
+		// 故障结束后第一个达到基线的采样间隔
+		previous = 0
+		for _, sample := range r.samples {
+			if previous >= fault.recovered && baselineTime > 0 &&
+				float64(sample.ops)/(sample.at-previous).Seconds() >= result.Baseline*r.config.Recovery {
+				result.Recovery = previous - fault.recovered
+				break
+			}
+			previous = sample.at
+		}
+		switch {
+		case fault.err != nil:
+			result.Mark = fault.err.Error()
+		case baselineTime == 0:
+			result.Mark = "故障前没有采样，无法计算基线"
+		case result.Recovery < 0:
+			result.Mark = "阶段结束前没有恢复"
+		default:
+			result.Mark = "已恢复"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func writeChaosResults(results []ChaosResult, bs *bytes.Buffer) {
+	if len(results) == 0 {
+		return
+	}
+	bs.WriteString("\n故障注入:\n")
+	for _, r := range results {
+		recovery := "-"
+		if r.Recovery >= 0 {
+			recovery = r.Recovery.Round(time.Millisecond).String()
+		}
+		bs.WriteString(fmt.Sprintf("%-15s %-20s %-6s 基线 %.2f/秒, 故障期间 %.2f/秒, 下降 %.1f%%, 失败 %d, 恢复耗时 %s, %s\n",
+			r.Database, r.Event, r.Phase, r.Baseline, r.During, r.Dip()*100, r.Errors, recovery, r.Mark))
+	}
+}
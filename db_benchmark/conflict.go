@@ -0,0 +1,240 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 主键冲突的处理方式
+const (
+	ConflictUpsert = "upsert" // 后写入的记录覆盖已有的记录
+	ConflictIgnore = "ignore" // 保留先写入的记录，冲突的记录被拒绝或跳过
+)
+
+var (
+	duplicateRate float64 // 复用之前记录主键的比例
+	conflictMode  string  // 为空时使用各引擎原来的批量写入方式，不处理主键冲突
+	duplicates    int     // 生成的数据中复用主键的记录数
+	conflicts     atomic.Int64
+)
+
+// ConflictResult 一个引擎插入时检测到的主键冲突
+type ConflictResult struct {
+	Database   string
+	Duplicates int   // 生成的重复主键记录数
+	Conflicts  int64 // 引擎报告的冲突数，upsert 为覆盖的记录，ignore 为被拒绝的记录
+}
+
+// 按 duplicateRate 让一部分记录复用之前某条记录的主键（resource_id 和 parent_id），属性重新生成，返回重复的记录数
+func applyDuplicates(data []Resource) int {
+	if duplicateRate <= 0 {
+		return 0
+	}
+	duplicates := 0
+	for i := 1; i < len(data); i++ {
+		if rand.Float64() >= duplicateRate {
+			continue
+		}
+		source := data[rand.Intn(i)]
+		for _, k := range []string{"id", "resource_id", "parent_id", "location"} {
+			data[i].Attributes[k] = source.Attributes[k]
+		}
+		data[i].ResourceId, data[i].ParentId = source.ResourceId, source.ParentId
+		duplicates++
+	}
+	return duplicates
+}
+
+// 去掉一批中主键重复的记录，upsert 保留最后一条，ignore 保留第一条，返回去掉的数量。
+// pg 的 ON CONFLICT DO UPDATE 和 etcd 的事务都不允许同一批中出现相同的主键
+func dedupeBatch(resources []Resource) ([]Resource, int) {
+	seen := make(map[string]int, len(resources))
+	unique := make([]Resource, 0, len(resources))
+	for _, r := range resources {
+		if i, ok := seen[r.ResourceId]; ok {
+			if conflictMode == ConflictUpsert {
+				unique[i] = r
+			}
+			continue
+		}
+		seen[r.ResourceId] = len(unique)
+		unique = append(unique, r)
+	}
+	return unique, len(resources) - len(unique)
+}
+
+// 解析 bulk 的响应，upsert 统计 updated，ignore 统计 409，有其他错误时返回第一个
+func bulkConflicts(body io.Reader) (int64, error) {
+	var response struct {
+		Items []map[string]struct {
+			Status int             `json:"status"`
+			Result string          `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("解析 bulk 响应失败: %v", err)
+	}
+	var n int64
+	for _, item := range response.Items {
+		for _, r := range item {
+			switch {
+			case r.Status == 409:
+				n++
+			case r.Error != nil:
+				return n, fmt.Errorf("批量插入错误: %s", r.Error)
+			case r.Result == "updated":
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// 先 COPY 到临时表，再 INSERT ... ON CONFLICT 写入，xmax 不为 0 的是被覆盖的记录
+func (p *PostgresqlEngine) insertOnConflict(ctx context.Context, resources []Resource) error {
+	resources, skipped := dedupeBatch(resources)
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	const staging = "benchmark_staging"
+	if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", staging, p.tableName)); err != nil {
+		return fmt.Errorf("创建临时表失败: %w", err)
+	}
+	columns := []string{"resource_id", "parent_id", "version", "deleted", "attributes"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging}, columns, pgx.CopyFromSlice(len(resources), func(i int) ([]interface{}, error) {
+		return p.copyRow(resources[i]), nil
+	})); err != nil {
+		return fmt.Errorf("COPY FROM 失败: %w", err)
+	}
+	action := "DO NOTHING"
+	if conflictMode == ConflictUpsert {
+		action = "DO UPDATE SET parent_id = EXCLUDED.parent_id, version = EXCLUDED.version, deleted = EXCLUDED.deleted, attributes = EXCLUDED.attributes"
+	}
+	list := strings.Join(columns, ", ")
+	// 按主键顺序写入，减少并发批次覆盖相同记录时的死锁
+	query := fmt.Sprintf(`WITH written AS (
+			INSERT INTO %s (%s) SELECT %s FROM %s ORDER BY resource_id
+			ON CONFLICT (resource_id) %s RETURNING (xmax = 0) AS inserted
+		) SELECT count(*), count(*) FILTER (WHERE NOT inserted) FROM written`,
+		p.tableName, list, list, staging, action)
+	var written, updated int64
+	if err := tx.QueryRow(ctx, query).Scan(&written, &updated); err != nil {
+		return fmt.Errorf("写入失败: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	conflicts.Add(int64(skipped) + int64(len(resources)) - written + updated)
+	return nil
+}
+
+// 以 resource_id 作为 _id 写入，upsert 按 _id 替换，ignore 插入时跳过重复键错误
+func (m *MongoDB) insertOnConflict(ctx context.Context, collection *mongo.Collection, resources []Resource) error {
+	resources, skipped := dedupeBatch(resources)
+	conflicts.Add(int64(skipped))
+	var documents []interface{}
+	for _, resource := range resources {
+		documents = append(documents, bson.M{
+			"_id":         resource.ResourceId,
+			"resource_id": resource.ResourceId,
+			"parent_id":   resource.ParentId,
+			"version":     resource.Version,
+			"deleted":     resource.Deleted,
+			"attributes":  resource.Attributes,
+		})
+	}
+	if conflictMode == ConflictUpsert {
+		models := make([]mongo.WriteModel, len(documents))
+		for i, doc := range documents {
+			models[i] = mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": resources[i].ResourceId}).
+				SetReplacement(doc).
+				SetUpsert(true)
+		}
+		result, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		conflicts.Add(result.MatchedCount)
+		return nil
+	}
+	_, err := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return err
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return err
+		}
+	}
+	conflicts.Add(int64(len(bulkErr.WriteErrors)))
+	return nil
+}
+
+// upsert 用 prev_kv 判断是否覆盖，ignore 每条记录一个 create_revision = 0 的子事务
+func (e *EtcdEngine) insertOnConflict(resources []Resource) error {
+	resources, skipped := dedupeBatch(resources)
+	conflicts.Add(int64(skipped))
+	// 子事务中的比较和写入都计入事务的操作数
+	step := etcdMaxTxnOps
+	if conflictMode == ConflictIgnore {
+		step = etcdMaxTxnOps / 2
+	}
+	for i := 0; i < len(resources); i += step {
+		var ops []clientv3.Op
+		for _, resource := range resources[i:min(i+step, len(resources))] {
+			key, value := e.key(resource), string(resource.ResourceStr)
+			if conflictMode == ConflictUpsert {
+				ops = append(ops, clientv3.OpPut(key, value, clientv3.WithPrevKV()))
+			} else {
+				ops = append(ops, clientv3.OpTxn(
+					[]clientv3.Cmp{clientv3.Compare(clientv3.CreateRevision(key), "=", 0)},
+					[]clientv3.Op{clientv3.OpPut(key, value)}, nil))
+			}
+		}
+		response, err := e.client.Txn(context.Background()).Then(ops...).Commit()
+		if err != nil {
+			return err
+		}
+		for _, r := range response.Responses {
+			if put := r.GetResponsePut(); put != nil && put.PrevKv != nil {
+				conflicts.Add(1)
+			} else if txn := r.GetResponseTxn(); txn != nil && !txn.Succeeded {
+				conflicts.Add(1)
+			}
+		}
+	}
+	return nil
+}
+
+func writeConflictResults(results []ConflictResult, bs *bytes.Buffer) {
+	if len(results) == 0 {
+		return
+	}
+	action := "覆盖"
+	if conflictMode == ConflictIgnore {
+		action = "拒绝"
+	}
+	bs.WriteString(fmt.Sprintf("\n主键冲突（-on-conflict %s）:\n", conflictMode))
+	for _, r := range results {
+		bs.WriteString(fmt.Sprintf("%-20s 生成重复主键 %d 条, 冲突%s %d 条\n", r.Database, r.Duplicates, action, r.Conflicts))
+	}
+}
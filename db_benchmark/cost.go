@@ -0,0 +1,162 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 费用估算的价格，键为 -engines 中的名称，"" 为默认值
+var (
+	instanceCosts = map[string]float64{} // 实例每小时的费用
+	storageCosts  = map[string]float64{} // 每 GB 每月的存储费用
+)
+
+// 解析 -instance-cost 和 -storage-cost，如 1.2 或 1.2,pg=0.8,es=1.5
+func parsePrices(text string) (map[string]float64, error) {
+	prices := make(map[string]float64)
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, value, ok := strings.Cut(item, "=")
+		if !ok {
+			kind, value = "", item
+		}
+		switch kind {
+		case "", "es", "pg", "mongo", "etcd", "graphql":
+		default:
+			return nil, fmt.Errorf("unknown engine %q, want es, pg, mongo, etcd or graphql", kind)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("invalid price %q", item)
+		}
+		prices[kind] = price
+	}
+	return prices, nil
+}
+
+func price(prices map[string]float64, kind string) (float64, bool) {
+	if p, ok := prices[kind]; ok {
+		return p, true
+	}
+	p, ok := prices[""]
+	return p, ok
+}
+
+func costEnabled() bool {
+	return len(instanceCosts) > 0 || len(storageCosts) > 0
+}
+
+// 插入或恢复之后的存储占用，引擎不支持统计时返回 false
+func measureStorage(engine BenchmarkEngine) (int64, bool) {
+	maintainer, ok := engine.(Maintainer)
+	if !ok {
+		return 0, false
+	}
+	stats, err := maintainer.IndexStats()
+	if err != nil {
+		logger.Warn("读取存储统计失败，存储费用按写入的 JSON 大小估算", "engine", engine.Name(), "err", err)
+		return 0, false
+	}
+	return stats.TotalBytes, stats.TotalBytes > 0
+}
+
+// CostEstimate 一个引擎的费用估算，不能估算的项为负数
+type CostEstimate struct {
+	Database        string
+	PerMillionWrite float64 // 按插入吞吐量计算的实例费用
+	PerMillionQuery float64 // 按查询平均耗时计算的实例费用
+	StorageMonth    float64 // 全部数据每月的存储费用
+	PerMillionStore float64 // 每百万条记录每月的存储费用
+	StorageBytes    int64
+	Estimated       bool // 存储按写入的 JSON 大小估算，引擎没有提供统计
+}
+
+// 按测试结果估算费用：写入和查询按单个客户端串行执行的速度占用整个实例计算，是实际并发下的上限。
+// 回放查询日志时查询耗时按日志中的次数加权
+func estimateCosts(results []BenchmarkResult, engines []BenchmarkEngine, storage map[string]int64, replay []WorkloadQuery) []CostEstimate {
+	calls := make(map[string]int64, len(replay))
+	for i, q := range replay {
+		calls[replayOperation(i, q)] = q.Calls
+	}
+	var estimates []CostEstimate
+	for _, engine := range engines {
+		kind := engineKind(engine)
+		hourly, hasInstance := price(instanceCosts, kind)
+		monthly, hasStorage := price(storageCosts, kind)
+		estimate := CostEstimate{Database: engine.Name(), PerMillionWrite: -1, PerMillionQuery: -1, StorageMonth: -1, PerMillionStore: -1}
+
+		var records int
+		var payload int64
+		var queryTime time.Duration
+		var queryWeight int64
+		for _, r := range results {
+			if r.Database != estimate.Database {
+				continue
+			}
+			switch {
+			case r.Operation == Operation_InsertTotal:
+				records, payload = r.Records, r.Bytes
+				if hasInstance && r.Throughput > 0 {
+					estimate.PerMillionWrite = 1e6 / r.Throughput / 3600 * hourly
+				}
+			case strings.Contains(r.Operation, Operation_Insert) || r.Duration <= 0:
+			default:
+				weight := int64(1)
+				if n, ok := calls[r.Operation]; ok {
+					weight = n
+				}
+				queryTime += r.Duration * time.Duration(weight)
+				queryWeight += weight
+			}
+		}
+		if hasInstance && queryWeight > 0 {
+			avg := queryTime / time.Duration(queryWeight)
+			estimate.PerMillionQuery = 1e6 * avg.Seconds() / 3600 * hourly
+		}
+
+		estimate.StorageBytes = storage[estimate.Database]
+		if estimate.StorageBytes == 0 && payload > 0 {
+			estimate.StorageBytes, estimate.Estimated = payload, true
+		}
+		if records == 0 {
+			records = totalRecords
+		}
+		if hasStorage && estimate.StorageBytes > 0 {
+			gb := float64(estimate.StorageBytes) / (1 << 30)
+			estimate.StorageMonth = gb * monthly
+			if records > 0 {
+				estimate.PerMillionStore = gb / float64(records) * 1e6 * monthly
+			}
+		}
+		estimates = append(estimates, estimate)
+	}
+	return estimates
+}
+
+func writeCostEstimates(estimates []CostEstimate, bs *bytes.Buffer) {
+	if len(estimates) == 0 {
+		return
+	}
+	cost := func(v float64) string {
+		if v < 0 {
+			return "-"
+		}
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+	bs.WriteString("\n费用估算（货币单位与 -instance-cost、-storage-cost 相同，按单个客户端串行执行的速度计算，是实际并发下的上限）:\n")
+	bs.WriteString(fmt.Sprintf("%-20s %-14s %-14s %-12s %-14s %-12s\n", "数据库", "每百万次写入", "每百万次查询", "存储/月", "每百万条/月", "存储占用"))
+	for _, e := range estimates {
+		size := formatBytes(e.StorageBytes)
+		if e.Estimated {
+			size += "（按 JSON 大小估算）"
+		}
+		bs.WriteString(fmt.Sprintf("%-20s %-14s %-14s %-12s %-14s %-12s\n",
+			e.Database, cost(e.PerMillionWrite), cost(e.PerMillionQuery), cost(e.StorageMonth), cost(e.PerMillionStore), size))
+	}
+}
@@ -0,0 +1,175 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/jackc/pgx/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DocumentWriter 按批写入任意 JSON 文档，seed 等命令通过它复用各引擎的连接
+type DocumentWriter interface {
+	Name() string
+	// Prepare 创建写入需要的索引或表，已经存在时不做修改
+	Prepare(ctx context.Context) error
+	// WriteDocuments 写入一批文档，ids 与 docs 一一对应，相同 id 的文档会被覆盖
+	WriteDocuments(ctx context.Context, ids []string, docs []map[string]interface{}) error
+	Close()
+}
+
+var (
+	_ DocumentWriter = (*ElasticsearchEngine)(nil)
+	_ DocumentWriter = (*PostgresqlEngine)(nil)
+	_ DocumentWriter = (*MongoDB)(nil)
+)
+
+// Prepare 索引不存在时按动态映射创建
+func (e *ElasticsearchEngine) Prepare(ctx context.Context) error {
+	res, err := e.client.Indices.Exists([]string{e.indexName}, e.client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == 200 {
+		return nil
+	}
+	res, err = e.client.Indices.Create(e.indexName, e.client.Indices.Create.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("创建索引失败: %s", res.String())
+	}
+	return nil
+}
+
+// WriteDocuments 通过 bulk 接口写入，任一文档失败时返回第一个错误
+func (e *ElasticsearchEngine) WriteDocuments(ctx context.Context, ids []string, docs []map[string]interface{}) error {
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		meta, _ := json.Marshal(map[string]interface{}{
+			"index": map[string]interface{}{"_index": e.indexName, "_id": ids[i]},
+		})
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		buf.Write(meta)
+		buf.WriteByte('\n')
+		buf.Write(source)
+		buf.WriteByte('\n')
+	}
+
+	opts := []func(*esapi.BulkRequest){e.client.Bulk.WithContext(ctx)}
+	if e.config.WithRefresh != "" {
+		opts = append(opts, e.client.Bulk.WithRefresh(e.config.WithRefresh))
+	}
+	res, err := e.client.Bulk(&buf, opts...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("批量写入错误: %s", res.String())
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string          `json:"_id"`
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析批量写入结果失败: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for _, r := range item {
+			if len(r.Error) > 0 {
+				return fmt.Errorf("文档 %s 写入失败: %s", r.ID, r.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// Prepare 表不存在时创建 (id TEXT PRIMARY KEY, data JSONB) 结构的表
+func (p *PostgresqlEngine) Prepare(ctx context.Context) error {
+	_, err := p.pool.Exec(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id TEXT PRIMARY KEY, data JSONB NOT NULL)",
+		pgx.Identifier{p.tableName}.Sanitize()))
+	return err
+}
+
+// WriteDocuments 在一个事务中批量写入，id 已存在时覆盖 data
+func (p *PostgresqlEngine) WriteDocuments(ctx context.Context, ids []string, docs []map[string]interface{}) error {
+	sql := fmt.Sprintf(
+		"INSERT INTO %s (id, data) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data",
+		pgx.Identifier{p.tableName}.Sanitize())
+	batch := &pgx.Batch{}
+	for i, doc := range docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		batch.Queue(sql, ids[i], data)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	results := tx.SendBatch(ctx, batch)
+	for range docs {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return err
+		}
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// NewMongoDBWriter 连接 MongoDB 并返回可写入文档的引擎
+func NewMongoDBWriter(ctx context.Context, uri, db, collection string) (*MongoDB, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+	return &MongoDB{uri: uri, db: db, Collection: collection, collection: collection, client: client}, nil
+}
+
+// Prepare 集合在第一次写入时自动创建
+func (m *MongoDB) Prepare(ctx context.Context) error {
+	return nil
+}
+
+// WriteDocuments 以 id 作为 _id 批量 upsert
+func (m *MongoDB) WriteDocuments(ctx context.Context, ids []string, docs []map[string]interface{}) error {
+	models := make([]mongo.WriteModel, len(docs))
+	for i, doc := range docs {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": ids[i]}).
+			SetReplacement(doc).
+			SetUpsert(true)
+	}
+	_, err := m.client.Database(m.db).Collection(m.Collection).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
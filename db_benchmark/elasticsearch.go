@@ -1,5 +1,5 @@
 // elasticsearch_engine.go
-package main
+package db_benchmark
 
 import (
 	"bytes"
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"golang.org/x/sync/errgroup"
-	"log"
 	"strings"
 	"time"
 )
@@ -19,12 +18,16 @@ type ElasticsearchEngine struct {
 	client    *elasticsearch.Client
 	config    *ElasticsearchConfig
 	indexName string
+	scope     tenantScope
 }
 
 func (e *ElasticsearchEngine) Insert(data []Resource, batchSize int) []BenchmarkResult {
 
 	// 创建索引
-	e.createIndex()
+	if err := e.createIndex(); err != nil {
+		logger.Error("创建索引失败", "engine", e.Name(), "err", err)
+		return nil
+	}
 
 	var results []BenchmarkResult
 	start := time.Now()
@@ -37,13 +40,16 @@ func (e *ElasticsearchEngine) Insert(data []Resource, batchSize int) []Benchmark
 
 		// 使用 Bulk API 进行批量插入
 		group.Go(func() error {
-			log.Printf("%s 批量插入数据开始: %d 条记录", e.Name(), batchEnd)
-			return e.BulkInsert(batch)
+			logger.Debug("批量插入数据开始", "engine", e.Name(), "records", batchEnd)
+			batchStart := time.Now()
+			err := e.BulkInsert(batch)
+			recordBatch(len(batch), time.Since(batchStart), err)
+			return err
 		})
 	}
 	err := group.Wait()
 	if err != nil {
-		log.Printf("%s 批量插入数据失败: %v", e.Name(), err)
+		logger.Error("批量插入数据失败", "engine", e.Name(), "err", err)
 		return nil
 	}
 	totalDuration := time.Since(start)
@@ -53,10 +59,11 @@ func (e *ElasticsearchEngine) Insert(data []Resource, batchSize int) []Benchmark
 		Duration:   totalDuration,
 		Records:    len(data),
 		Throughput: float64(len(data)) / totalDuration.Seconds(),
+		Bytes:      payloadBytes(data),
 	}
 
-	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒\n",
-		e.Name(), len(data), totalDuration, totalResult.Throughput)
+	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒, %.2f MB/秒\n",
+		e.Name(), len(data), totalDuration, totalResult.Throughput, totalResult.MBPerSecond())
 
 	return append(results, totalResult)
 }
@@ -68,9 +75,27 @@ type ElasticsearchConfig struct {
 	Username    string
 	Password    string
 	WithRefresh string
+	Mapping     string // attributes.bigmap 的映射方式：dynamic（默认）、flattened 或 disabled
+
+	SnapshotRepository string // 保存快照的仓库
+	SnapshotLocation   string // 仓库不存在时按此路径创建 fs 仓库，路径需要在 ES 的 path.repo 中
 }
 
-func (e *ElasticsearchEngine) Init() {
+// 大字段的映射方式
+const (
+	MappingDynamic   = "dynamic"   // 动态映射，每个子字段都建立索引
+	MappingFlattened = "flattened" // 整个对象作为一个 flattened 字段，叶子值按 keyword 索引
+	MappingDisabled  = "disabled"  // enabled: false，只保存在 _source 中，不能搜索
+)
+
+// 各映射方式下 attributes.bigmap 的 mapping，dynamic 不需要显式声明
+var bigMapMappings = map[string]map[string]interface{}{
+	MappingDynamic:   nil,
+	MappingFlattened: {"type": "flattened"},
+	MappingDisabled:  {"type": "object", "enabled": false},
+}
+
+func (e *ElasticsearchEngine) Init() error {
 	cfg := elasticsearch.Config{
 		Addresses: e.config.Addresses,
 		Username:  e.config.Username,
@@ -79,7 +104,7 @@ func (e *ElasticsearchEngine) Init() {
 
 	client, err := elasticsearch.NewClient(cfg)
 	if err != nil {
-		log.Fatalf("创建 Elasticsearch 客户端失败: %v", err)
+		return fmt.Errorf("创建 Elasticsearch 客户端失败: %v", err)
 	}
 
 	e.client = client
@@ -87,15 +112,16 @@ func (e *ElasticsearchEngine) Init() {
 	// 检查连接
 	res, err := e.client.Ping()
 	if err != nil {
-		log.Fatalf("Elasticsearch 连接失败: %v", err)
+		return fmt.Errorf("Elasticsearch 连接失败: %v", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		log.Fatalf("Elasticsearch 连接异常: %s", res.String())
+		return fmt.Errorf("Elasticsearch 连接异常: %s", res.String())
 	}
 
-	fmt.Println("Elasticsearch 初始化成功")
+	logger.Info("Elasticsearch 初始化成功")
+	return nil
 }
 
 // NewElasticsearchEngine 创建新的引擎实例
@@ -121,11 +147,18 @@ func NewElasticsearchEngine(config *ElasticsearchConfig) (*ElasticsearchEngine,
 }
 
 // createIndex 创建索引
-func (e *ElasticsearchEngine) createIndex() {
+func (e *ElasticsearchEngine) createIndex() error {
 
 	// delete old index if exists (for testing convenience)
 	e.client.Indices.Delete([]string{e.indexName})
 
+	attributes := map[string]interface{}{
+		"type":    "object",
+		"dynamic": true, // 允许自动生成子字段
+	}
+	if mapping := bigMapMappings[e.config.Mapping]; mapping != nil {
+		attributes["properties"] = map[string]interface{}{"bigmap": mapping}
+	}
 	settings := map[string]interface{}{
 		"settings": map[string]interface{}{
 			"index.mapping.total_fields.limit": 20000,
@@ -136,10 +169,7 @@ func (e *ElasticsearchEngine) createIndex() {
 				"parent_id":   map[string]interface{}{"type": "keyword"},
 				"version":     map[string]interface{}{"type": "integer"},
 				"deleted":     map[string]interface{}{"type": "integer"},
-				"attributes": map[string]interface{}{
-					"type":    "object",
-					"dynamic": true, // 允许自动生成子字段
-				},
+				"attributes":  attributes,
 			},
 		},
 	}
@@ -147,22 +177,27 @@ func (e *ElasticsearchEngine) createIndex() {
 	body, _ := json.Marshal(settings)
 	res, err := e.client.Indices.Create(e.indexName, e.client.Indices.Create.WithBody(bytes.NewReader(body)))
 	if err != nil {
-		log.Fatalf("创建索引失败: %v", err)
+		return err
 	}
 	defer res.Body.Close()
-	fmt.Println("index created with high field limit (20000)")
-
+	logger.Info("index created with high field limit", "limit", 20000, "bigmap_mapping", e.config.Mapping)
+	return nil
 }
 
 // BulkInsert 批量插入数据
 func (e *ElasticsearchEngine) BulkInsert(resources []Resource) error {
 	var buf bytes.Buffer
+	// ignore 使用 create，_id 已存在时返回 409
+	op := "index"
+	if conflictMode == ConflictIgnore {
+		op = "create"
+	}
 
 	for _, resource := range resources {
 
 		// 构建批量请求
 		meta := map[string]interface{}{
-			"index": map[string]interface{}{
+			op: map[string]interface{}{
 				"_index": e.indexName,
 				"_id":    resource.ResourceId,
 			},
@@ -192,6 +227,11 @@ func (e *ElasticsearchEngine) BulkInsert(resources []Resource) error {
 	if res.IsError() {
 		return fmt.Errorf("批量插入错误: %s", res.String())
 	}
+	if conflictMode != "" {
+		n, err := bulkConflicts(res.Body)
+		conflicts.Add(n)
+		return err
+	}
 
 	return nil
 }
@@ -294,14 +334,50 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 			},
 		},
 	}
+	if bigMapInsert {
+		// 动态映射的字符串有 .keyword 子字段，flattened 的叶子值本身就是 keyword，disabled 时不能搜索，命中数为 0
+		field := "attributes.bigmap.node_0000.meta.title"
+		if e.config.Mapping == "" || e.config.Mapping == MappingDynamic {
+			field += ".keyword"
+		}
+		testCases = append(testCases, struct {
+			name        string
+			description string
+			query       map[string]interface{}
+		}{
+			name:        Operation_BigMapSearch,
+			description: "按大字段中的叶子值精确匹配",
+			query: map[string]interface{}{
+				"query": map[string]interface{}{
+					"term": map[string]interface{}{field: "Title 0"},
+				},
+			},
+		})
+	}
+
+	// 共享布局时每个查询都加上租户过滤，不影响打分
+	if e.scope.filtered() {
+		for i := range testCases {
+			testCases[i].query = map[string]interface{}{
+				"query": map[string]interface{}{
+					"bool": map[string]interface{}{
+						"must":   testCases[i].query["query"],
+						"filter": map[string]interface{}{"term": map[string]interface{}{"attributes.tenant_id.keyword": e.scope.tenant}},
+					},
+				},
+			}
+		}
+	}
 
-	// 执行每个测试用例，多次执行取平均值
+	// 执行每个测试用例，多次执行取平均值。ES 没有可靠的服务端超时，超时后客户端断开连接，ES 取消对应的搜索任务
+	timeout := queryTimeout("es")
 	for _, tc := range testCases {
 		const executionCount = 5 // 每个测试用例执行5次
 		var totalDuration time.Duration
 		var totalRecord int
 		var lastError error
 		var successCount int
+		var timedOut bool
 
 		// 执行多次搜索
 		for i := 0; i < executionCount; i++ {
@@ -310,10 +386,13 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 			queryJSON, err := json.Marshal(tc.query)
 			if err != nil {
 				lastError = err
+				recordWorkload(0, 1)
 				continue
 			}
 
+			ctx, cancel := queryContext(timeout, false)
 			res, err := e.client.Count(
+				e.client.Count.WithContext(ctx),
 				e.client.Count.WithIndex(e.indexName),
 				e.client.Count.WithBody(strings.NewReader(string(queryJSON))),
 			)
@@ -321,18 +400,27 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 			duration := time.Since(start)
 
 			if err != nil {
+				cancel()
 				lastError = err
+				recordWorkload(0, 1)
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
 				continue
 			}
 
 			var searchResult map[string]interface{}
 			if err := json.NewDecoder(res.Body).Decode(&searchResult); err != nil {
 				lastError = err
+				recordWorkload(0, 1)
 				res.Body.Close()
+				cancel()
 				continue
 			}
 
 			res.Body.Close()
+			cancel()
 
 			// 提取命中数量
 			var hitCount int
@@ -343,6 +431,7 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 			totalDuration += duration
 			totalRecord += hitCount
 			successCount++
+			recordWorkload(1, 0)
 		}
 
 		// 计算平均值
@@ -368,6 +457,12 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 				mark += fmt.Sprintf("，最后错误: %v", lastError)
 			}
 		}
+		if timedOut {
+			mark = timeoutMark(timeout, successCount)
+			if successCount == 0 {
+				avgDuration = timeout
+			}
+		}
 
 		results = append(results, BenchmarkResult{
 			Operation:  tc.name,
@@ -375,6 +470,7 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 			Duration:   avgDuration,
 			Records:    avgRecords,
 			Throughput: throughput,
+			TimedOut:   timedOut,
 			Mark:       mark,
 		})
 	}
@@ -384,28 +480,131 @@ func (e *ElasticsearchEngine) Search(test []Resource) []BenchmarkResult {
 
 func (e *ElasticsearchEngine) ClearData() {
 
-	res, err := e.client.Indices.Delete([]string{e.config.IndexName})
+	res, err := e.client.Indices.Delete([]string{e.indexName})
 	if err != nil {
 		return
 	}
 
 	if err != nil {
-		log.Printf("%s 清理数据失败: %v", e.Name(), err)
+		logger.Warn("清理数据失败", "engine", e.Name(), "err", err)
 		return
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		log.Printf("%s 清理数据错误: %s", e.Name(), res.String())
+		logger.Warn("清理数据错误", "engine", e.Name(), "response", res.String())
 		return
 	}
 
-	fmt.Printf("%s 数据清理完成\n", e.Name())
+	logger.Info("数据清理完成", "engine", e.Name())
 }
 
 func (e *ElasticsearchEngine) Close() {
 }
 
+// Name 默认映射方式时为 Elasticsearch，其余映射方式和多租户布局在名称中注明，便于在结果中区分
 func (e *ElasticsearchEngine) Name() string {
-	return "Elasticsearch"
+	switch e.config.Mapping {
+	case "", MappingDynamic:
+		return "Elasticsearch" + e.scope.suffix()
+	case MappingDisabled:
+		return "Elasticsearch(enabled:false)" + e.scope.suffix()
+	default:
+		return "Elasticsearch(" + e.config.Mapping + ")" + e.scope.suffix()
+	}
+}
+
+var _ TenantEngine = (*ElasticsearchEngine)(nil)
+
+// SetTenant 独立布局时每个租户使用 <index>_<tenant> 索引
+func (e *ElasticsearchEngine) SetTenant(layout, tenant string) {
+	e.scope = tenantScope{layout: layout, tenant: tenant}
+	e.indexName = e.config.IndexName
+	if e.scope.separate() {
+		e.indexName += "_" + tenant
+	}
+}
+
+var _ Snapshotter = (*ElasticsearchEngine)(nil)
+
+// Snapshot 用快照 API 把索引保存到快照仓库，同名的旧快照先删除。快照保存在 ES 所在的机器上，dir 不使用
+func (e *ElasticsearchEngine) Snapshot(dir, name string) error {
+	if err := e.ensureRepository(); err != nil {
+		return err
+	}
+	res, err := e.client.Snapshot.Delete(e.config.SnapshotRepository, name)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"indices":              e.indexName,
+		"include_global_state": false,
+	})
+	res, err = e.client.Snapshot.Create(e.config.SnapshotRepository, name,
+		e.client.Snapshot.Create.WithBody(bytes.NewReader(body)),
+		e.client.Snapshot.Create.WithWaitForCompletion(true))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("create snapshot %s: %s", name, res.String())
+	}
+	return nil
+}
+
+// Restore 删除索引后从快照恢复，等待恢复完成后返回
+func (e *ElasticsearchEngine) Restore(dir, name string) error {
+	res, err := e.client.Indices.Delete([]string{e.indexName})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"indices":              e.indexName,
+		"include_global_state": false,
+	})
+	res, err = e.client.Snapshot.Restore(e.config.SnapshotRepository, name,
+		e.client.Snapshot.Restore.WithBody(bytes.NewReader(body)),
+		e.client.Snapshot.Restore.WithWaitForCompletion(true))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("restore snapshot %s: %s", name, res.String())
+	}
+	return nil
+}
+
+// 快照仓库不存在时按 SnapshotLocation 创建 fs 仓库
+func (e *ElasticsearchEngine) ensureRepository() error {
+	res, err := e.client.Snapshot.GetRepository(e.client.Snapshot.GetRepository.WithRepository(e.config.SnapshotRepository))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if !res.IsError() {
+		return nil
+	}
+	if e.config.SnapshotLocation == "" {
+		return fmt.Errorf("snapshot repository %s does not exist, set -es-snapshot-location to create it", e.config.SnapshotRepository)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":     "fs",
+		"settings": map[string]interface{}{"location": e.config.SnapshotLocation},
+	})
+	res, err = e.client.Snapshot.CreateRepository(e.config.SnapshotRepository, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("create snapshot repository %s: %s", e.config.SnapshotRepository, res.String())
+	}
+	logger.Info("快照仓库已创建", "repository", e.config.SnapshotRepository, "location", e.config.SnapshotLocation)
+	return nil
 }
@@ -1,9 +1,13 @@
-package main
+package db_benchmark
 
-import "time"
+import (
+	"time"
+
+	"github.com/TreeWu/mock-go/model"
+)
 
 type BenchmarkEngine interface {
-	Init()
+	Init() error
 	Insert(data []Resource, batchSize int) []BenchmarkResult
 	ClearData()
 	Search(testData []Resource) []BenchmarkResult
@@ -14,20 +18,13 @@ type BenchmarkEngine interface {
 var (
 	ci_type = []int{0, 1, 2, 3, 4, 5, 6, 7}
 
-	Operation_Insert      = "插入"
-	Operation_Search      = "搜索"
-	Operation_InsertTotal = "插入总耗时"
+	Operation_Insert       = "插入"
+	Operation_Search       = "搜索"
+	Operation_InsertTotal  = "插入总耗时"
+	Operation_BigMapSearch = "attributes.bigmap 叶子值精准匹配"
 )
 
-type Resource struct {
-	ResourceId   string                 `json:"resource_id" bson:"resource_id"`
-	ParentId     string                 `json:"parent_id" bson:"parent_id"`
-	Version      int                    `json:"version" bson:"version"`
-	Deleted      int                    `json:"deleted" bson:"deleted"`
-	Attributes   map[string]interface{} `json:"attributes" bson:"attributes"`
-	AttributeStr []byte                 `json:"-" bson:"-"`
-	ResourceStr  []byte                 `json:"-"`
-}
+type Resource = model.Resource
 
 // 性能测试结果
 type BenchmarkResult struct {
@@ -36,5 +33,15 @@ type BenchmarkResult struct {
 	Duration   time.Duration // 耗时
 	Records    int           //插入、搜索条数
 	Throughput float64       // 记录数/秒
+	Bytes      int64         // 写入的 JSON 字节数，只有插入结果设置
+	TimedOut   bool          // 查询超过 -query-timeout 被取消
 	Mark       string
 }
+
+// MBPerSecond 插入结果按字节计算的吞吐量
+func (r BenchmarkResult) MBPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / (1 << 20) / r.Duration.Seconds()
+}
@@ -0,0 +1,371 @@
+package db_benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ BenchmarkEngine = (*EtcdEngine)(nil)
+
+// EtcdConfig etcd 配置
+type EtcdConfig struct {
+	Endpoints   []string
+	Username    string
+	Password    string
+	Prefix      string // 所有 key 的前缀，key 为 <prefix>/<parent_id>/<resource_id>
+	DialTimeout time.Duration
+}
+
+// EtcdEngine 把 Resource 序列化为 JSON 作为 value 写入 etcd，查询只能按 key 或前缀扫描，
+// 属性条件在客户端解码后过滤
+type EtcdEngine struct {
+	client *clientv3.Client
+	config *EtcdConfig
+	prefix string // 独立布局时为 <prefix>/<tenant>
+	scope  tenantScope
+}
+
+// etcd 默认一个事务最多 128 个操作（--max-txn-ops）
+const etcdMaxTxnOps = 128
+
+// 前缀扫描每页读取的 key 数
+const etcdPageSize = 1000
+
+func NewEtcdEngine(config *EtcdConfig) *EtcdEngine {
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	return &EtcdEngine{config: config, prefix: strings.TrimSuffix(config.Prefix, "/")}
+}
+
+func (e *EtcdEngine) Init() error {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   e.config.Endpoints,
+		Username:    e.config.Username,
+		Password:    e.config.Password,
+		DialTimeout: e.config.DialTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("连接 etcd 失败: %v", err)
+	}
+	// 检查连接
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.DialTimeout)
+	defer cancel()
+	if _, err := client.Status(ctx, e.config.Endpoints[0]); err != nil {
+		client.Close()
+		return fmt.Errorf("etcd 连接测试失败: %v", err)
+	}
+	logger.Info("etcd 连接成功")
+	e.client = client
+	return nil
+}
+
+func (e *EtcdEngine) Name() string {
+	return "etcd" + e.scope.suffix()
+}
+
+func (e *EtcdEngine) key(resource Resource) string {
+	return e.prefix + "/" + resource.ParentId + "/" + resource.ResourceId
+}
+
+func (e *EtcdEngine) Insert(data []Resource, batchSize int) []BenchmarkResult {
+	var results []BenchmarkResult
+	start := time.Now()
+	group := errgroup.Group{}
+	group.SetLimit(6)
+
+	for i := 0; i < len(data); i += batchSize {
+		batchEnd := min(i+batchSize, len(data))
+		batch := data[i:batchEnd]
+
+		group.Go(func() error {
+			logger.Debug("批量插入数据开始", "engine", e.Name(), "records", batchEnd)
+			batchStart := time.Now()
+			err := e.BulkInsert(batch)
+			recordBatch(len(batch), time.Since(batchStart), err)
+			return err
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		logger.Error("批量插入失败", "engine", e.Name(), "err", err)
+		return nil
+	}
+	totalDuration := time.Since(start)
+	totalResult := BenchmarkResult{
+		Operation:  Operation_InsertTotal,
+		Database:   e.Name(),
+		Duration:   totalDuration,
+		Records:    len(data),
+		Throughput: float64(len(data)) / totalDuration.Seconds(),
+		Bytes:      payloadBytes(data),
+	}
+
+	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒, %.2f MB/秒\n",
+		e.Name(), len(data), totalDuration, totalResult.Throughput, totalResult.MBPerSecond())
+
+	return append(results, totalResult)
+}
+
+// BulkInsert 每 etcdMaxTxnOps 条记录一个事务写入。单个请求默认不能超过 1.5MB（--max-request-bytes），
+// -big-map 的记录会写入失败
+func (e *EtcdEngine) BulkInsert(resources []Resource) error {
+	if conflictMode != "" {
+		return e.insertOnConflict(resources)
+	}
+	for i := 0; i < len(resources); i += etcdMaxTxnOps {
+		var ops []clientv3.Op
+		for _, resource := range resources[i:min(i+etcdMaxTxnOps, len(resources))] {
+			ops = append(ops, clientv3.OpPut(e.key(resource), string(resource.ResourceStr)))
+		}
+		if _, err := e.client.Txn(context.Background()).Then(ops...).Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *EtcdEngine) ClearData() {
+	_, err := e.client.Delete(context.Background(), e.prefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		logger.Warn("etcd 清理数据失败", "err", err)
+	}
+}
+
+// etcdSearch 一个查询：读取 key 或前缀下的所有记录，match 和 record 都为 nil 时只计数
+type etcdSearch struct {
+	name   string
+	key    string
+	prefix bool
+	match  func(attributes map[string]interface{}) bool
+	record func(doc map[string]interface{}) bool // 需要顶层字段时使用，解码整条记录
+}
+
+func (e *EtcdEngine) Search(test []Resource) []BenchmarkResult {
+	var results []BenchmarkResult
+
+	randStr := make(map[string]bool, len(test))
+	for t := range test {
+		randStr[test[t].Attributes["rand_string"].(string)] = true
+	}
+	ciTypes := func(values ...float64) func(map[string]interface{}) bool {
+		return func(attributes map[string]interface{}) bool {
+			ciType, _ := attributes["ci_type"].(float64)
+			for _, v := range values {
+				if ciType == v {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	searchTests := []etcdSearch{
+		{
+			name: "resource_id精准匹配",
+			key:  e.key(test[0]),
+		},
+		{
+			name:   "parent_id前缀扫描",
+			key:    e.prefix + "/" + test[0].ParentId + "/",
+			prefix: true,
+		},
+		{
+			name:   "全部记录前缀扫描计数",
+			key:    e.prefix + "/",
+			prefix: true,
+		},
+		{
+			name:   "attributes.ci_type精准匹配",
+			key:    e.prefix + "/",
+			prefix: true,
+			match:  ciTypes(2),
+		},
+		{
+			name:   "attributes.ci_type包含多个值",
+			key:    e.prefix + "/",
+			prefix: true,
+			match:  ciTypes(2, 3, 4),
+		},
+		{
+			name:   "attributes.location like 搜索",
+			key:    e.prefix + "/",
+			prefix: true,
+			match: func(attributes map[string]interface{}) bool {
+				location, _ := attributes["location"].(string)
+				return strings.Contains(strings.ToLower(location), "project_root")
+			},
+		},
+		{
+			name:   "attributes.rand_string in 搜索",
+			key:    e.prefix + "/",
+			prefix: true,
+			match: func(attributes map[string]interface{}) bool {
+				value, _ := attributes["rand_string"].(string)
+				return randStr[value]
+			},
+		},
+	}
+
+	// 共享布局时每条记录都要解码后按租户过滤，不能只计数
+	if e.scope.filtered() {
+		for i := range searchTests {
+			match := searchTests[i].match
+			searchTests[i].match = func(attributes map[string]interface{}) bool {
+				return attributes["tenant_id"] == e.scope.tenant && (match == nil || match(attributes))
+			}
+		}
+	}
+
+	timeout := queryTimeout("etcd")
+	for _, searchTest := range searchTests {
+		const executionCount = 5
+		var totalDuration time.Duration
+		var totalRecords int64
+		var successCount int
+		var lastError error
+		var timedOut bool
+
+		for i := 0; i < executionCount; i++ {
+			start := time.Now()
+			ctx, cancel := queryContext(timeout, false)
+			count, err := e.count(ctx, searchTest)
+			cancel()
+			if err != nil {
+				lastError = err
+				recordWorkload(0, 1)
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
+				continue
+			}
+			totalDuration += time.Since(start)
+			totalRecords += count
+			successCount++
+			recordWorkload(1, 0)
+		}
+
+		// 计算平均值
+		var avgDuration time.Duration
+		var avgRecords int64
+		var throughput float64
+		mark := "成功"
+
+		if successCount > 0 {
+			avgDuration = totalDuration / time.Duration(successCount)
+			avgRecords = totalRecords / int64(successCount)
+			if avgDuration > 0 {
+				throughput = float64(avgRecords) / avgDuration.Seconds()
+			}
+		} else {
+			mark = fmt.Sprintf("所有执行都失败: %v", lastError)
+		}
+
+		if successCount < executionCount {
+			mark = fmt.Sprintf("部分成功 (%d/%d)", successCount, executionCount)
+			if lastError != nil {
+				mark += fmt.Sprintf("，最后错误: %v", lastError)
+			}
+		}
+		if timedOut {
+			mark = timeoutMark(timeout, successCount)
+			if successCount == 0 {
+				avgDuration = timeout
+			}
+		}
+
+		results = append(results, BenchmarkResult{
+			Operation:  searchTest.name,
+			Database:   e.Name(),
+			Duration:   avgDuration,
+			Records:    int(avgRecords),
+			Throughput: throughput,
+			TimedOut:   timedOut,
+			Mark:       mark,
+		})
+
+		fmt.Printf("%-12s | %-30s | %-18v | %-10d | %s\n",
+			e.Name(), searchTest.name, avgDuration, int(avgRecords), mark)
+	}
+
+	return results
+}
+
+// 执行一个查询，返回命中的记录数。不需要过滤时用 count only，否则分页读取 value 在客户端过滤
+func (e *EtcdEngine) count(ctx context.Context, search etcdSearch) (int64, error) {
+	if search.match == nil && search.record == nil {
+		opts := []clientv3.OpOption{clientv3.WithCountOnly()}
+		if search.prefix {
+			opts = append(opts, clientv3.WithPrefix())
+		}
+		res, err := e.client.Get(ctx, search.key, opts...)
+		if err != nil {
+			return 0, err
+		}
+		return res.Count, nil
+	}
+
+	end := search.key + "\x00"
+	if search.prefix {
+		end = clientv3.GetPrefixRangeEnd(search.key)
+	}
+	var count, revision int64
+	for from := search.key; ; {
+		// 后续各页读取第一页的 revision，不受并发写入影响
+		res, err := e.client.Get(ctx, from, clientv3.WithRange(end), clientv3.WithLimit(etcdPageSize), clientv3.WithRev(revision))
+		if err != nil {
+			return 0, err
+		}
+		revision = res.Header.Revision
+		for _, kv := range res.Kvs {
+			if search.record != nil {
+				var doc map[string]interface{}
+				if err := json.Unmarshal(kv.Value, &doc); err != nil {
+					return 0, fmt.Errorf("%s: %v", kv.Key, err)
+				}
+				if search.record(doc) {
+					count++
+				}
+				continue
+			}
+			var resource struct {
+				Attributes map[string]interface{} `json:"attributes"`
+			}
+			if err := json.Unmarshal(kv.Value, &resource); err != nil {
+				return 0, fmt.Errorf("%s: %v", kv.Key, err)
+			}
+			if search.match(resource.Attributes) {
+				count++
+			}
+		}
+		if !res.More || len(res.Kvs) == 0 {
+			return count, nil
+		}
+		from = string(res.Kvs[len(res.Kvs)-1].Key) + "\x00"
+	}
+}
+
+func (e *EtcdEngine) Close() {
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
+var _ TenantEngine = (*EtcdEngine)(nil)
+
+// SetTenant 独立布局时每个租户使用 <prefix>/<tenant> 前缀
+func (e *EtcdEngine) SetTenant(layout, tenant string) {
+	e.scope = tenantScope{layout: layout, tenant: tenant}
+	e.prefix = strings.TrimSuffix(e.config.Prefix, "/")
+	if e.scope.separate() {
+		e.prefix += "/" + tenant
+	}
+}
@@ -0,0 +1,397 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GraphQL 接口的风格
+const (
+	GraphQLHasura       = "hasura"       // where: {resource_id: {_eq: ...}}，jsonb 用 _contains
+	GraphQLPostGraphile = "postgraphile" // filter: {resourceId: {equalTo: ...}}，需要 postgraphile-plugin-connection-filter
+)
+
+// GraphQLConfig GraphQL 接口配置，接口需要已经暴露 PostgreSQL 中的测试表
+type GraphQLConfig struct {
+	Endpoint string
+	Dialect  string
+	Headers  map[string]string // 如 x-hasura-admin-secret、Authorization
+	// Field 查询的根字段，为空时按表名推导：Hasura 为表名（其他 schema 为 <schema>_<table>），
+	// PostGraphile 为 all<Table>s，如 allBenchmarkDbs
+	Field string
+}
+
+// GraphQLEngine 数据通过 SQL 写入 PostgreSQL，查询通过 GraphQL 接口执行，
+// 和直接执行 SQL 的 PostgreSQL 引擎对比 API 层的开销
+type GraphQLEngine struct {
+	*PostgresqlEngine
+	api    *GraphQLConfig
+	client *http.Client
+}
+
+func NewGraphQLEngine(pg *PostgresqlEngine, config *GraphQLConfig) *GraphQLEngine {
+	return &GraphQLEngine{
+		PostgresqlEngine: pg,
+		api:              config,
+		client:           &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name 在名称中注明接口风格和多租户布局
+func (g *GraphQLEngine) Name() string {
+	return "GraphQL(" + g.api.Dialect + ")" + g.scope.suffix()
+}
+
+// Insert 通过 SQL 写入，结果按 GraphQL 引擎的名称记录
+func (g *GraphQLEngine) Insert(data []Resource, batchSize int) []BenchmarkResult {
+	results := g.PostgresqlEngine.Insert(data, batchSize)
+	for i := range results {
+		results[i].Database = g.Name()
+	}
+	return results
+}
+
+// 查询的根字段，独立布局时 Hasura 使用 <tenant>_<table>
+func (g *GraphQLEngine) field() string {
+	table := g.PostgresqlEngine.config.TableName
+	if g.api.Field != "" {
+		table = g.api.Field
+	} else if g.api.Dialect == GraphQLPostGraphile {
+		return "all" + pascalCase(table) + "s"
+	}
+	if g.scope.separate() && g.api.Dialect == GraphQLHasura {
+		return g.scope.tenant + "_" + table
+	}
+	return table
+}
+
+// gqlCond 查询条件，按接口风格转换为 GraphQL 输入对象
+type gqlCond struct {
+	op       string // eq、like、contains、and、or、not
+	field    string
+	value    interface{}
+	children []gqlCond
+}
+
+func gqlEq(field string, value interface{}) gqlCond {
+	return gqlCond{op: "eq", field: field, value: value}
+}
+
+func gqlLike(field, pattern string) gqlCond {
+	return gqlCond{op: "like", field: field, value: pattern}
+}
+
+// jsonb 包含，对应 SQL 的 @>，可以使用 GIN 索引
+func gqlContains(field string, value interface{}) gqlCond {
+	return gqlCond{op: "contains", field: field, value: value}
+}
+
+func gqlOr(children ...gqlCond) gqlCond {
+	return gqlCond{op: "or", children: children}
+}
+
+func gqlAnd(children ...gqlCond) gqlCond {
+	return gqlCond{op: "and", children: children}
+}
+
+func gqlNot(child gqlCond) gqlCond {
+	return gqlCond{op: "not", children: []gqlCond{child}}
+}
+
+// 转换为 GraphQL 字面量
+func (c gqlCond) render(dialect string) string {
+	var children []string
+	for _, child := range c.children {
+		children = append(children, child.render(dialect))
+	}
+	if dialect == GraphQLPostGraphile {
+		switch c.op {
+		case "eq":
+			return fmt.Sprintf("{%s: {equalTo: %s}}", camelCase(c.field), graphqlLiteral(c.value))
+		case "like":
+			return fmt.Sprintf("{%s: {like: %s}}", camelCase(c.field), graphqlLiteral(c.value))
+		case "contains":
+			// 默认的 JSON 类型以字符串输入
+			data, _ := json.Marshal(c.value)
+			return fmt.Sprintf("{%s: {contains: %s}}", camelCase(c.field), graphqlLiteral(string(data)))
+		case "not":
+			return fmt.Sprintf("{not: %s}", children[0])
+		}
+		return fmt.Sprintf("{%s: [%s]}", c.op, strings.Join(children, ", "))
+	}
+	switch c.op {
+	case "eq":
+		return fmt.Sprintf("{%s: {_eq: %s}}", c.field, graphqlLiteral(c.value))
+	case "like":
+		return fmt.Sprintf("{%s: {_like: %s}}", c.field, graphqlLiteral(c.value))
+	case "contains":
+		return fmt.Sprintf("{%s: {_contains: %s}}", c.field, graphqlLiteral(c.value))
+	case "not":
+		return fmt.Sprintf("{_not: %s}", children[0])
+	}
+	return fmt.Sprintf("{_%s: [%s]}", c.op, strings.Join(children, ", "))
+}
+
+// JSON 值转换为 GraphQL 字面量：对象的键不加引号，按键排序
+func graphqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, len(keys))
+		for i, k := range keys {
+			fields[i] = k + ": " + graphqlLiteral(v[k])
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = graphqlLiteral(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case nil:
+		return "null"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// resource_id 转换为 resourceId
+func camelCase(name string) string {
+	pascal := pascalCase(name)
+	if pascal == "" {
+		return ""
+	}
+	return strings.ToLower(pascal[:1]) + pascal[1:]
+}
+
+// benchmark_db 转换为 BenchmarkDb
+func pascalCase(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// 满足条件的记录数
+func (g *GraphQLEngine) count(ctx context.Context, cond gqlCond) (int, error) {
+	field := g.field()
+	var query string
+	if g.api.Dialect == GraphQLPostGraphile {
+		query = fmt.Sprintf("query { %s(filter: %s) { totalCount } }", field, cond.render(g.api.Dialect))
+	} else {
+		query = fmt.Sprintf("query { %s_aggregate(where: %s) { aggregate { count } } }", field, cond.render(g.api.Dialect))
+	}
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.api.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range g.api.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := g.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var result struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("%s: %v", res.Status, err)
+	}
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("%s", result.Errors[0].Message)
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s", res.Status)
+	}
+	if g.api.Dialect == GraphQLPostGraphile {
+		var connection struct {
+			TotalCount int `json:"totalCount"`
+		}
+		err = json.Unmarshal(result.Data[field], &connection)
+		return connection.TotalCount, err
+	}
+	var aggregate struct {
+		Aggregate struct {
+			Count int `json:"count"`
+		} `json:"aggregate"`
+	}
+	err = json.Unmarshal(result.Data[field+"_aggregate"], &aggregate)
+	return aggregate.Aggregate.Count, err
+}
+
+// Search 和 PostgreSQL 引擎相同的查询，通过 GraphQL 接口执行。
+// jsonb 字段的条件都转换为包含（@>），GraphQL 过滤条件不支持 jsonb 字段的模糊匹配，跳过 location 查询
+func (g *GraphQLEngine) Search(test []Resource) []BenchmarkResult {
+	var results []BenchmarkResult
+	ciTypes := func(values ...int) gqlCond {
+		var children []gqlCond
+		for _, v := range values {
+			children = append(children, gqlContains("attributes", map[string]interface{}{"ci_type": v}))
+		}
+		return gqlOr(children...)
+	}
+	var randStr []gqlCond
+	for t := range test {
+		randStr = append(randStr, gqlContains("attributes", map[string]interface{}{"rand_string": test[t].Attributes["rand_string"]}))
+	}
+
+	testCases := []struct {
+		name string
+		cond gqlCond
+	}{
+		{name: "resource_id精准匹配", cond: gqlEq("resource_id", test[0].ResourceId)},
+		{name: "resource_id模糊匹配", cond: gqlLike("resource_id", "%"+test[0].ResourceId+"%")},
+		{name: "attributes.ci_type精准匹配", cond: gqlContains("attributes", map[string]interface{}{"ci_type": 2})},
+		{name: "attributes.ci_type包含多个值", cond: ciTypes(2, 3, 4)},
+		{name: "attributes.ci_type不包含多个值", cond: gqlNot(ciTypes(2, 3, 4))},
+		{name: "attributes.rand_string in 搜索", cond: gqlOr(randStr...)},
+	}
+	if bigMapInsert {
+		testCases = append(testCases, struct {
+			name string
+			cond gqlCond
+		}{
+			name: Operation_BigMapSearch,
+			cond: gqlContains("attributes", map[string]interface{}{
+				"bigmap": map[string]interface{}{"node_0000": map[string]interface{}{"meta": map[string]interface{}{"title": "Title 0"}}},
+			}),
+		})
+	}
+	logger.Info("GraphQL 过滤条件不支持 jsonb 字段的模糊匹配，跳过", "engine", g.Name(), "operation", "attributes.location like 搜索")
+
+	timeout := queryTimeout("graphql")
+	for _, tc := range testCases {
+		const executionCount = 5
+		var totalDuration time.Duration
+		var totalRecord int
+		var lastError error
+		var successCount int
+		var timedOut bool
+
+		cond := tc.cond
+		// 共享布局时每个查询都加上租户过滤
+		if g.scope.filtered() {
+			cond = gqlAnd(cond, gqlContains("attributes", map[string]interface{}{"tenant_id": g.scope.tenant}))
+		}
+
+		for i := 0; i < executionCount; i++ {
+			start := time.Now()
+			ctx, cancel := queryContext(timeout, false)
+			count, err := g.count(ctx, cond)
+			cancel()
+			duration := time.Since(start)
+			if err != nil {
+				lastError = err
+				recordWorkload(0, 1)
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
+				continue
+			}
+			totalDuration += duration
+			totalRecord += count
+			successCount++
+			recordWorkload(1, 0)
+		}
+
+		// 计算平均值
+		var avgDuration time.Duration
+		var avgRecords int
+		var throughput float64
+		mark := "成功"
+
+		if successCount > 0 {
+			avgDuration = totalDuration / time.Duration(successCount)
+			avgRecords = totalRecord / successCount
+			if avgDuration > 0 {
+				throughput = float64(avgRecords) / avgDuration.Seconds()
+			}
+		} else {
+			mark = fmt.Sprintf("所有执行都失败: %v", lastError)
+		}
+
+		if successCount < executionCount {
+			mark = fmt.Sprintf("部分成功 (%d/%d)", successCount, executionCount)
+			if lastError != nil {
+				mark += fmt.Sprintf("，最后错误: %v", lastError)
+			}
+		}
+		if timedOut {
+			mark = timeoutMark(timeout, successCount)
+			if successCount == 0 {
+				avgDuration = timeout
+			}
+		}
+
+		results = append(results, BenchmarkResult{
+			Operation:  tc.name,
+			Database:   g.Name(),
+			Duration:   avgDuration,
+			Records:    avgRecords,
+			Throughput: throughput,
+			TimedOut:   timedOut,
+			Mark:       mark,
+		})
+	}
+
+	return results
+}
+
+// 同一查询通过 GraphQL 接口和直接执行 SQL 的耗时对比，多出的耗时是 API 层的开销。
+// 命中数不同说明两边的查询条件不等价
+func writeGraphQLComparison(results []BenchmarkResult, bs *bytes.Buffer) {
+	direct := make(map[string]BenchmarkResult)
+	for _, result := range results {
+		if name, _, _ := strings.Cut(result.Database, "["); name == "PostgreSQL" {
+			direct[result.Database[len(name):]+"\x00"+result.Operation] = result
+		}
+	}
+	var lines []string
+	for _, result := range results {
+		name, _, _ := strings.Cut(result.Database, "[")
+		if !strings.HasPrefix(name, "GraphQL(") || strings.Contains(result.Operation, Operation_Insert) {
+			continue
+		}
+		sql, ok := direct[result.Database[len(name):]+"\x00"+result.Operation]
+		if !ok {
+			continue
+		}
+		overhead := "-"
+		if sql.Duration > 0 {
+			overhead = fmt.Sprintf("%+.1f%%", (float64(result.Duration)/float64(sql.Duration)-1)*100)
+		}
+		line := fmt.Sprintf("%-20s %-30s GraphQL %-15v SQL %-15v API 层开销 %s", result.Database, result.Operation, result.Duration, sql.Duration, overhead)
+		if result.Records != sql.Records {
+			line += fmt.Sprintf(", 命中数不同: GraphQL %d, SQL %d", result.Records, sql.Records)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	bs.WriteString("\nGraphQL 与直接 SQL 对比:\n")
+	for _, line := range lines {
+		bs.WriteString(line + "\n")
+	}
+}
@@ -0,0 +1,317 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Maintainer 支持持续更新和存储统计的引擎，用于测量长时间更新后的写放大和索引维护开销
+type Maintainer interface {
+	// Update 按 resource_id 覆盖已有的记录
+	Update(data []Resource) error
+	// IndexStats 当前的存储和索引统计
+	IndexStats() (IndexStats, error)
+}
+
+// IndexStats 某一时刻的存储和索引统计，引擎不提供的项为 0
+type IndexStats struct {
+	TotalBytes int64 // 数据和索引占用的空间
+	IndexBytes int64 // 索引占用的空间，ES 不单独统计
+	Segments   int64 // ES 的 segment 数
+	Dead       int64 // 已删除或被覆盖但还没清理的记录：PostgreSQL 的 n_dead_tup，ES 的 docs.deleted
+}
+
+// IndexSample 更新阶段的一次统计，At 为距更新开始的时间
+type IndexSample struct {
+	At time.Duration
+	IndexStats
+}
+
+// MaintenanceResult 一个引擎持续更新阶段的结果
+type MaintenanceResult struct {
+	Database string
+	Duration time.Duration
+	Updates  int64
+	Errors   int64
+	Samples  []IndexSample
+}
+
+var (
+	_ Maintainer = (*ElasticsearchEngine)(nil)
+	_ Maintainer = (*PostgresqlEngine)(nil)
+	_ Maintainer = (*MongoDB)(nil)
+	_ Maintainer = (*EtcdEngine)(nil)
+)
+
+// 同时执行更新的协程数，和插入一致
+const updateWorkers = 6
+
+// 在 duration 内持续随机更新已插入的记录，每隔 interval 统计一次存储和索引
+func runUpdates(engine BenchmarkEngine, data []Resource, duration, interval time.Duration) MaintenanceResult {
+	maintainer := engine.(Maintainer)
+	result := MaintenanceResult{Database: engine.Name()}
+	start := time.Now()
+	sample := func() {
+		stats, err := maintainer.IndexStats()
+		if err != nil {
+			logger.Warn("读取索引统计失败", "engine", engine.Name(), "err", err)
+			return
+		}
+		result.Samples = append(result.Samples, IndexSample{At: time.Since(start), IndexStats: stats})
+	}
+	sample()
+
+	var updates, errors atomic.Int64
+	deadline := start.Add(duration)
+	var wg sync.WaitGroup
+	for w := 0; w < updateWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				batch := make([]Resource, min(batchSize, len(data)))
+				for i := range batch {
+					batch[i] = updatedResource(data[rand.Intn(len(data))])
+				}
+				// 按 resource_id 排序，减少并发更新同一批记录时的死锁
+				sort.Slice(batch, func(i, j int) bool { return batch[i].ResourceId < batch[j].ResourceId })
+				if err := maintainer.Update(batch); err != nil {
+					logger.Debug("更新失败", "engine", engine.Name(), "err", err)
+					errors.Add(1)
+					continue
+				}
+				updates.Add(int64(len(batch)))
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-ticker.C:
+			sample()
+			fmt.Printf("%s 持续更新: %v, 已更新 %d 条\n", engine.Name(), time.Since(start).Round(time.Second), updates.Load())
+		case <-done:
+			running = false
+		}
+	}
+	ticker.Stop()
+	result.Duration = time.Since(start)
+	sample()
+	result.Updates, result.Errors = updates.Load(), errors.Load()
+	return result
+}
+
+// 复制一条记录并修改版本号、rand_string 和 updated_at，重新序列化
+func updatedResource(resource Resource) Resource {
+	attributes := make(map[string]interface{}, len(resource.Attributes)+1)
+	for k, v := range resource.Attributes {
+		attributes[k] = v
+	}
+	// 多个协程同时更新，不使用 valHandler（其中的随机数生成器不能并发使用）
+	attributes["rand_string"] = strconv.FormatInt(rand.Int63(), 36)
+	attributes["updated_at"] = time.Now().Format(time.RFC3339Nano)
+	resource.Attributes = attributes
+	resource.Version++
+	resource.AttributeStr, _ = json.Marshal(resource.Attributes)
+	resource.ResourceStr, _ = json.Marshal(resource)
+	return resource
+}
+
+func writeMaintenanceResults(results []MaintenanceResult, bs *bytes.Buffer) {
+	if len(results) == 0 {
+		return
+	}
+	bs.WriteString("\n持续更新与索引维护:\n")
+	for _, r := range results {
+		throughput := 0.0
+		if r.Duration > 0 {
+			throughput = float64(r.Updates) / r.Duration.Seconds()
+		}
+		bs.WriteString(fmt.Sprintf("%s 更新 %d 条, 耗时 %v, %.2f 条/秒, 失败 %d 批\n", r.Database, r.Updates, r.Duration.Round(time.Second), throughput, r.Errors))
+		if len(r.Samples) == 0 {
+			continue
+		}
+		bs.WriteString(fmt.Sprintf("  %-10s %-12s %-12s %-10s %-10s\n", "时间", "总大小", "索引大小", "segments", "dead"))
+		for _, s := range r.Samples {
+			bs.WriteString(fmt.Sprintf("  %-10v %-12s %-12s %-10d %-10d\n", s.At.Round(time.Second), formatBytes(s.TotalBytes), formatBytes(s.IndexBytes), s.Segments, s.Dead))
+		}
+		first, last := r.Samples[0], r.Samples[len(r.Samples)-1]
+		bs.WriteString(fmt.Sprintf("  增长: 总大小 %s, 索引大小 %s, segments %+d, dead %+d\n",
+			growth(first.TotalBytes, last.TotalBytes), growth(first.IndexBytes, last.IndexBytes), last.Segments-first.Segments, last.Dead-first.Dead))
+	}
+}
+
+func growth(from, to int64) string {
+	if from <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (float64(to)/float64(from)-1)*100)
+}
+
+// Update 用 bulk 接口按 _id 覆盖，旧文档标记为删除，直到 segment 合并时才清理
+func (e *ElasticsearchEngine) Update(data []Resource) error {
+	return e.BulkInsert(data)
+}
+
+// IndexStats 主分片的存储大小、segment 数和已删除文档数
+func (e *ElasticsearchEngine) IndexStats() (IndexStats, error) {
+	res, err := e.client.Indices.Stats(
+		e.client.Indices.Stats.WithIndex(e.indexName),
+		e.client.Indices.Stats.WithMetric("store", "docs", "segments"),
+	)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return IndexStats{}, fmt.Errorf("读取索引统计失败: %s", res.String())
+	}
+	var result struct {
+		All struct {
+			Primaries struct {
+				Store struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"store"`
+				Docs struct {
+					Deleted int64 `json:"deleted"`
+				} `json:"docs"`
+				Segments struct {
+					Count int64 `json:"count"`
+				} `json:"segments"`
+			} `json:"primaries"`
+		} `json:"_all"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return IndexStats{}, err
+	}
+	primaries := result.All.Primaries
+	return IndexStats{
+		TotalBytes: primaries.Store.SizeInBytes,
+		Segments:   primaries.Segments.Count,
+		Dead:       primaries.Docs.Deleted,
+	}, nil
+}
+
+// Update 在一个事务中按 resource_id 逐条 upsert
+func (p *PostgresqlEngine) Update(data []Resource) error {
+	ctx := context.Background()
+	sql := fmt.Sprintf(`INSERT INTO %s (resource_id, parent_id, version, deleted, attributes) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (resource_id) DO UPDATE SET version = EXCLUDED.version, attributes = EXCLUDED.attributes`, p.tableName)
+	batch := &pgx.Batch{}
+	for _, resource := range data {
+		var attributes interface{} = []byte(resource.AttributeStr)
+		if p.attributesType() == AttributesText {
+			attributes = string(resource.AttributeStr)
+		}
+		batch.Queue(sql, resource.ResourceId, resource.ParentId, resource.Version, resource.Deleted, attributes)
+	}
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	results := tx.SendBatch(ctx, batch)
+	for range data {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return err
+		}
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// IndexStats 表和索引的总大小、pg_stat_user_indexes 中各索引的大小之和和 n_dead_tup
+func (p *PostgresqlEngine) IndexStats() (IndexStats, error) {
+	var stats IndexStats
+	err := p.pool.QueryRow(context.Background(), `SELECT pg_total_relation_size($1::text::regclass),
+	COALESCE((SELECT sum(pg_relation_size(indexrelid)) FROM pg_stat_user_indexes WHERE relid = $1::text::regclass), 0)::bigint,
+	COALESCE((SELECT n_dead_tup FROM pg_stat_user_tables WHERE relid = $1::text::regclass), 0)`, p.tableName).
+		Scan(&stats.TotalBytes, &stats.IndexBytes, &stats.Dead)
+	return stats, err
+}
+
+// Update 按 resource_id 逐条替换
+func (m *MongoDB) Update(data []Resource) error {
+	models := make([]mongo.WriteModel, len(data))
+	for i, resource := range data {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"resource_id": resource.ResourceId}).
+			SetReplacement(bson.M{
+				"resource_id": resource.ResourceId,
+				"parent_id":   resource.ParentId,
+				"version":     resource.Version,
+				"deleted":     resource.Deleted,
+				"attributes":  resource.Attributes,
+			})
+	}
+	_, err := m.client.Database(m.db).Collection(m.Collection).BulkWrite(context.Background(), models)
+	return err
+}
+
+// IndexStats collStats 中的存储大小和索引大小，WiredTiger 不提供 segment 和 dead 统计
+func (m *MongoDB) IndexStats() (IndexStats, error) {
+	var result bson.M
+	err := m.client.Database(m.db).RunCommand(context.Background(), bson.D{{"collStats", m.Collection}}).Decode(&result)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	storage, index := bsonInt(result["storageSize"]), bsonInt(result["totalIndexSize"])
+	return IndexStats{TotalBytes: storage + index, IndexBytes: index}, nil
+}
+
+// collStats 中的数字按大小可能是 int32、int64 或 double
+func bsonInt(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Update 按 key 覆盖，旧版本保留在 MVCC 历史中，直到 compaction。
+// 一个事务中不能重复写同一个 key，随机抽取的批次中重复的记录只保留一条
+func (e *EtcdEngine) Update(data []Resource) error {
+	seen := make(map[string]bool, len(data))
+	unique := make([]Resource, 0, len(data))
+	for _, resource := range data {
+		if !seen[resource.ResourceId] {
+			seen[resource.ResourceId] = true
+			unique = append(unique, resource)
+		}
+	}
+	return e.BulkInsert(unique)
+}
+
+// IndexStats 后端数据库文件的大小，包括还没 compaction 的历史版本；etcd 没有单独的索引和 segment 统计
+func (e *EtcdEngine) IndexStats() (IndexStats, error) {
+	status, err := e.client.Status(context.Background(), e.config.Endpoints[0])
+	if err != nil {
+		return IndexStats{}, err
+	}
+	return IndexStats{TotalBytes: status.DbSize}, nil
+}
@@ -1,4 +1,4 @@
-package main
+package db_benchmark
 
 import (
 	"context"
@@ -7,7 +7,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/sync/errgroup"
-	"log"
+	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -18,10 +19,12 @@ type MongoDB struct {
 	uri        string
 	client     *mongo.Client
 	Collection string
+	collection string // 配置的集合名，独立布局时 Collection 为 <collection>_<tenant>
+	scope      tenantScope
 }
 
 func (m *MongoDB) Name() string {
-	return "MongoDB"
+	return "MongoDB" + m.scope.suffix()
 }
 
 func NewMongoDB(uri, db, Collection string) BenchmarkEngine {
@@ -29,6 +32,18 @@ func NewMongoDB(uri, db, Collection string) BenchmarkEngine {
 		uri:        uri,
 		db:         db,
 		Collection: Collection,
+		collection: Collection,
+	}
+}
+
+var _ TenantEngine = (*MongoDB)(nil)
+
+// SetTenant 独立布局时每个租户使用 <collection>_<tenant> 集合
+func (m *MongoDB) SetTenant(layout, tenant string) {
+	m.scope = tenantScope{layout: layout, tenant: tenant}
+	m.Collection = m.collection
+	if m.scope.separate() {
+		m.Collection += "_" + tenant
 	}
 }
 
@@ -36,27 +51,28 @@ func (m *MongoDB) Test() {
 
 }
 
-func (m *MongoDB) Init() {
+func (m *MongoDB) Init() error {
 	clientOptions := options.Client().ApplyURI(m.uri)
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
-		log.Fatal("连接 MongoDB 失败:", err)
+		return fmt.Errorf("连接 MongoDB 失败: %v", err)
 	}
 	// 检查连接
 	err = client.Ping(context.Background(), nil)
 	if err != nil {
-		log.Fatal("MongoDB 连接测试失败:", err)
+		client.Disconnect(context.Background())
+		return fmt.Errorf("MongoDB 连接测试失败: %v", err)
 	}
-	fmt.Println("MongoDB 连接成功")
+	logger.Info("MongoDB 连接成功")
 	m.client = client
-
+	return nil
 }
 
 func (m *MongoDB) Insert(data []Resource, batchSize int) []BenchmarkResult {
 
 	collection := m.client.Database(m.db).Collection(m.Collection)
 
-	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+	indexes := []mongo.IndexModel{
 		{Keys: bson.D{{"resource_id", 1}}},
 		{
 			Keys: bson.D{
@@ -65,9 +81,13 @@ func (m *MongoDB) Insert(data []Resource, batchSize int) []BenchmarkResult {
 				{"attributes", "text"},
 			},
 		},
-	})
+	}
+	if m.scope.layout == TenantShared {
+		indexes = append(indexes, mongo.IndexModel{Keys: bson.D{{"attributes.tenant_id", 1}}})
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexes)
 	if err != nil {
-		log.Printf("创建 MongoDB 索引失败: %v", err)
+		logger.Warn("创建 MongoDB 索引失败", "err", err)
 	}
 	var results []BenchmarkResult
 	start := time.Now()
@@ -82,7 +102,16 @@ func (m *MongoDB) Insert(data []Resource, batchSize int) []BenchmarkResult {
 		batch := data[i:batchEnd]
 
 		group.Go(func() error {
-			log.Printf("%s 批量插入数据开始: %d 条记录", m.Name(), batchEnd)
+			logger.Debug("批量插入数据开始", "engine", m.Name(), "records", batchEnd)
+			if conflictMode != "" {
+				batchStart := time.Now()
+				err := m.insertOnConflict(context.Background(), collection, batch)
+				recordBatch(len(batch), time.Since(batchStart), err)
+				if err != nil {
+					logger.Error("MongoDB 批量插入失败", "err", err)
+				}
+				return err
+			}
 
 			var documents []interface{}
 			for _, resource := range batch {
@@ -96,16 +125,18 @@ func (m *MongoDB) Insert(data []Resource, batchSize int) []BenchmarkResult {
 				documents = append(documents, doc)
 			}
 
+			batchStart := time.Now()
 			_, err := collection.InsertMany(context.Background(), documents)
+			recordBatch(len(batch), time.Since(batchStart), err)
 			if err != nil {
-				log.Printf("MongoDB 批量插入失败: %v", err)
+				logger.Error("MongoDB 批量插入失败", "err", err)
 			}
 			return err
 		})
 	}
 	err = group.Wait()
 	if err != nil {
-		log.Printf("MongoDB 批量插入失败: %v", err)
+		logger.Error("MongoDB 批量插入失败", "err", err)
 		return nil
 	}
 	totalDuration := time.Since(start)
@@ -115,10 +146,11 @@ func (m *MongoDB) Insert(data []Resource, batchSize int) []BenchmarkResult {
 		Duration:   totalDuration,
 		Records:    len(data),
 		Throughput: float64(len(data)) / totalDuration.Seconds(),
+		Bytes:      payloadBytes(data),
 	}
 
-	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒\n",
-		m.Name(), len(data), totalDuration, totalResult.Throughput)
+	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒, %.2f MB/秒\n",
+		m.Name(), len(data), totalDuration, totalResult.Throughput, totalResult.MBPerSecond())
 
 	return append(results, totalResult)
 }
@@ -127,7 +159,7 @@ func (m *MongoDB) ClearData() {
 	collection := m.client.Database(m.db).Collection(m.Collection)
 	_, err := collection.DeleteMany(context.Background(), bson.D{})
 	if err != nil {
-		log.Printf("MongoDB 清理数据失败: %v", err)
+		logger.Warn("MongoDB 清理数据失败", "err", err)
 	}
 }
 
@@ -194,6 +226,33 @@ func (m *MongoDB) Search(test []Resource) []BenchmarkResult {
 			},
 		},
 	}
+	if bigMapInsert {
+		searchTests = append(searchTests, struct {
+			name     string
+			pipeline []bson.D
+		}{
+			name: Operation_BigMapSearch,
+			pipeline: []bson.D{
+				{{"$match", bson.D{{"attributes.bigmap.node_0000.meta.title", "Title 0"}}}},
+				{{"$count", "total"}},
+			},
+		})
+	}
+
+	// 共享布局时每个查询之前先按租户过滤
+	if m.scope.filtered() {
+		for i := range searchTests {
+			filter := bson.D{{"$match", bson.D{{"attributes.tenant_id", m.scope.tenant}}}}
+			searchTests[i].pipeline = append([]bson.D{filter}, searchTests[i].pipeline...)
+		}
+	}
+
+	// 超时由服务端的 maxTimeMS 执行
+	timeout := queryTimeout("mongo")
+	aggregateOptions := options.Aggregate()
+	if timeout > 0 {
+		aggregateOptions.SetMaxTime(timeout)
+	}
 
 	for _, searchTest := range searchTests {
 		const executionCount = 5
@@ -201,22 +260,37 @@ func (m *MongoDB) Search(test []Resource) []BenchmarkResult {
 		var totalRecords int64
 		var successCount int
 		var lastError error
+		var timedOut bool
 
 		for i := 0; i < executionCount; i++ {
 			start := time.Now()
 
-			cursor, err := collection.Aggregate(context.Background(), searchTest.pipeline)
+			ctx, cancel := queryContext(timeout, true)
+			cursor, err := collection.Aggregate(ctx, searchTest.pipeline, aggregateOptions)
 			if err != nil {
+				cancel()
 				lastError = err
+				recordWorkload(0, 1)
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
 				continue
 			}
 
 			var result []bson.M
-			if err = cursor.All(context.Background(), &result); err != nil {
+			if err = cursor.All(ctx, &result); err != nil {
 				lastError = err
+				recordWorkload(0, 1)
 				cursor.Close(context.Background())
+				cancel()
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
 				continue
 			}
+			cancel()
 
 			// 提取计数
 			var count int64
@@ -241,6 +315,7 @@ func (m *MongoDB) Search(test []Resource) []BenchmarkResult {
 			totalDuration += duration
 			totalRecords += count
 			successCount++
+			recordWorkload(1, 0)
 		}
 
 		// 计算平均值
@@ -265,6 +340,12 @@ func (m *MongoDB) Search(test []Resource) []BenchmarkResult {
 				mark += fmt.Sprintf("，最后错误: %v", lastError)
 			}
 		}
+		if timedOut {
+			mark = timeoutMark(timeout, successCount)
+			if successCount == 0 {
+				avgDuration = timeout
+			}
+		}
 
 		result := BenchmarkResult{
 			Operation:  searchTest.name,
@@ -272,6 +353,7 @@ func (m *MongoDB) Search(test []Resource) []BenchmarkResult {
 			Duration:   avgDuration,
 			Records:    int(avgRecords),
 			Throughput: throughput,
+			TimedOut:   timedOut,
 			Mark:       mark,
 		}
 		results = append(results, result)
@@ -286,3 +368,20 @@ func (m *MongoDB) Search(test []Resource) []BenchmarkResult {
 func (m *MongoDB) Close() {
 	m.client.Disconnect(context.Background())
 }
+
+var _ Snapshotter = (*MongoDB)(nil)
+
+// Snapshot 用 mongodump 把集合导出为压缩归档，需要本机安装 MongoDB Database Tools
+func (m *MongoDB) Snapshot(dir, name string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return runTool(nil, "mongodump", "--uri="+m.uri, "--db="+m.db, "--collection="+m.Collection,
+		"--gzip", "--archive="+filepath.Join(dir, name+".archive"))
+}
+
+// Restore 用 mongorestore 删除集合后从归档恢复，包括索引
+func (m *MongoDB) Restore(dir, name string) error {
+	return runTool(nil, "mongorestore", "--uri="+m.uri, "--nsInclude="+m.db+"."+m.Collection, "--drop",
+		"--gzip", "--archive="+filepath.Join(dir, name+".archive"))
+}
@@ -1,12 +1,15 @@
 // postgresql_engine.go
-package main
+package db_benchmark
 
 import (
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v4"
 	"golang.org/x/sync/errgroup"
-	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -18,13 +21,15 @@ var _ BenchmarkEngine = (*PostgresqlEngine)(nil)
 type PostgresqlEngine struct {
 	pool      *pgxpool.Pool
 	config    *PostgresqlConfig
-	tableName string
+	tableName string // 独立布局时带租户的 schema，如 tenant_0.benchmark_db
+	scope     tenantScope
 }
 
 func (p *PostgresqlEngine) Insert(data []Resource, batchSize int) []BenchmarkResult {
 	// 创建表
 	if err := p.createTable(); err != nil {
-		log.Fatalf("创建表失败: %v", err)
+		logger.Error("创建表失败", "engine", p.Name(), "err", err)
+		return nil
 	}
 
 	var results []BenchmarkResult
@@ -38,14 +43,17 @@ func (p *PostgresqlEngine) Insert(data []Resource, batchSize int) []BenchmarkRes
 
 		// 使用 COPY 进行批量插入
 		group.Go(func() error {
-			log.Printf("%s 批量插入数据开始: %d 条记录", p.Name(), batchEnd)
-			return p.BulkInsert(batch)
+			logger.Debug("批量插入数据开始", "engine", p.Name(), "records", batchEnd)
+			batchStart := time.Now()
+			err := p.BulkInsert(batch)
+			recordBatch(len(batch), time.Since(batchStart), err)
+			return err
 		})
 	}
 
 	err := group.Wait()
 	if err != nil {
-		log.Printf("%s 批量插入失败: %v", p.Name(), err)
+		logger.Error("批量插入失败", "engine", p.Name(), "err", err)
 
 		return nil
 	}
@@ -56,10 +64,11 @@ func (p *PostgresqlEngine) Insert(data []Resource, batchSize int) []BenchmarkRes
 		Duration:   totalDuration,
 		Records:    len(data),
 		Throughput: float64(len(data)) / totalDuration.Seconds(),
+		Bytes:      payloadBytes(data),
 	}
 
-	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒\n",
-		p.Name(), len(data), totalDuration, totalResult.Throughput)
+	fmt.Printf("%s 插入完成: %d 条记录, 耗时: %v, 吞吐量: %.2f 记录/秒, %.2f MB/秒\n",
+		p.Name(), len(data), totalDuration, totalResult.Throughput, totalResult.MBPerSecond())
 
 	return append(results, totalResult)
 }
@@ -76,16 +85,17 @@ type PostgresqlConfig struct {
 	MaxConns        int32
 	MinConns        int32
 	MaxConnLifetime time.Duration
+	AttributesType  string // attributes 列的类型：jsonb（默认）或 text，text 时查询需要转换为 jsonb
 }
 
-func (p *PostgresqlEngine) Init() {
+func (p *PostgresqlEngine) Init() error {
 	connStr := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
 		p.config.User, p.config.Password, p.config.Host, p.config.Port,
 		p.config.DBName, p.config.SSLMode)
 
 	config, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
-		log.Fatalf("解析 PostgreSQL 配置失败: %v", err)
+		return fmt.Errorf("解析 PostgreSQL 配置失败: %v", err)
 	}
 
 	config.MaxConns = p.config.MaxConns
@@ -94,17 +104,19 @@ func (p *PostgresqlEngine) Init() {
 
 	pool, err := pgxpool.ConnectConfig(context.Background(), config)
 	if err != nil {
-		log.Fatalf("创建 PostgreSQL 连接池失败: %v", err)
+		return fmt.Errorf("创建 PostgreSQL 连接池失败: %v", err)
 	}
 
 	// 测试连接
 	if err := pool.Ping(context.Background()); err != nil {
-		log.Fatalf("PostgreSQL 连接测试失败: %v", err)
+		pool.Close()
+		return fmt.Errorf("PostgreSQL 连接测试失败: %v", err)
 	}
 
 	p.pool = pool
 
-	fmt.Println("PostgreSQL 初始化成功")
+	logger.Info("PostgreSQL 初始化成功")
+	return nil
 }
 
 // NewPostgresqlEngine 创建新的引擎实例
@@ -143,7 +155,13 @@ func (p *PostgresqlEngine) createTable() error {
 		fmt.Sprintf("TRUNCATE TABLE %s", p.tableName))
 	if err != nil {
 		// 表可能不存在，继续创建
-		log.Printf("清理表数据失败（可能表不存在）: %v", err)
+		logger.Warn("清理表数据失败（可能表不存在）", "err", err)
+	}
+
+	if p.scope.separate() {
+		if _, err := p.pool.Exec(context.Background(), fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", p.scope.tenant)); err != nil {
+			return fmt.Errorf("创建 schema 失败: %v", err)
+		}
 	}
 
 	// 创建表结构
@@ -153,26 +171,33 @@ func (p *PostgresqlEngine) createTable() error {
 			parent_id TEXT,
 			version INTEGER,
 			deleted INTEGER,
-			attributes JSONB,
+			attributes %s,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)
-	`, p.tableName)
+	`, p.tableName, p.attributesType())
 
 	_, err = p.pool.Exec(context.Background(), createTableSQL)
 	if err != nil {
 		return fmt.Errorf("创建表失败: %v", err)
 	}
 
-	// 创建索引以提高查询性能
+	// 创建索引以提高查询性能，text 列不能建 GIN 索引
+	// 索引建在表所在的 schema 中，名称不能带 schema
+	indexName := strings.ReplaceAll(p.tableName, ".", "_")
 	indexes := []string{
-		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_resource_id ON %s(resource_id)", p.tableName, p.tableName),
-		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_attributes_gin ON %s USING gin(attributes)", p.tableName, p.tableName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_resource_id ON %s(resource_id)", indexName, p.tableName),
+	}
+	if p.attributesType() == AttributesJSONB {
+		indexes = append(indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_attributes_gin ON %s USING gin(attributes)", indexName, p.tableName))
+	}
+	if p.scope.layout == TenantShared {
+		indexes = append(indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_tenant ON %s((%s->>'tenant_id'))", indexName, p.tableName, p.attributesColumn()))
 	}
 
 	for _, indexSQL := range indexes {
 		_, err = p.pool.Exec(context.Background(), indexSQL)
 		if err != nil {
-			log.Printf("创建索引失败: %v", err)
+			logger.Warn("创建索引失败", "err", err)
 		}
 	}
 
@@ -182,6 +207,9 @@ func (p *PostgresqlEngine) createTable() error {
 // BulkInsert 使用 COPY FROM 进行高性能批量插入
 func (p *PostgresqlEngine) BulkInsert(resources []Resource) error {
 	ctx := context.Background()
+	if conflictMode != "" {
+		return p.insertOnConflict(ctx, resources)
+	}
 
 	// 开始事务
 	tx, err := p.pool.Begin(ctx)
@@ -194,17 +222,10 @@ func (p *PostgresqlEngine) BulkInsert(resources []Resource) error {
 
 	copyCount, err := tx.CopyFrom(
 		ctx,
-		pgx.Identifier{p.tableName},
+		pgx.Identifier(strings.Split(p.tableName, ".")),
 		columnNames,
 		pgx.CopyFromSlice(len(resources), func(i int) ([]interface{}, error) {
-			resource := resources[i]
-			return []interface{}{
-				resource.ResourceId,
-				resource.ParentId,
-				resource.Version,
-				resource.Deleted,
-				[]byte(resource.AttributeStr),
-			}, nil
+			return p.copyRow(resources[i]), nil
 		}),
 	)
 
@@ -223,6 +244,21 @@ func (p *PostgresqlEngine) BulkInsert(resources []Resource) error {
 	return nil
 }
 
+// COPY 的一行，列依次为 resource_id, parent_id, version, deleted, attributes
+func (p *PostgresqlEngine) copyRow(resource Resource) []interface{} {
+	var attributes interface{} = []byte(resource.AttributeStr)
+	if p.attributesType() == AttributesText {
+		attributes = string(resource.AttributeStr)
+	}
+	return []interface{}{
+		resource.ResourceId,
+		resource.ParentId,
+		resource.Version,
+		resource.Deleted,
+		attributes,
+	}
+}
+
 // Search 执行搜索测试，多次执行取平均值
 func (p *PostgresqlEngine) Search(test []Resource) []BenchmarkResult {
 	var results []BenchmarkResult
@@ -231,6 +267,7 @@ func (p *PostgresqlEngine) Search(test []Resource) []BenchmarkResult {
 	for t := range test {
 		randStr = append(randStr, test[t].Attributes["rand_string"])
 	}
+	attributes := p.attributesColumn()
 	// 定义测试用例 - 与 Elasticsearch 保持一致
 	testCases := []struct {
 		name        string
@@ -257,7 +294,7 @@ func (p *PostgresqlEngine) Search(test []Resource) []BenchmarkResult {
 			name:        "attributes.ci_type精准匹配",
 			description: "根据attributes中的ci_type字段精确匹配",
 			queryFunc: func() (string, []interface{}) {
-				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE attributes->>'ci_type' = $1", p.tableName),
+				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s->>'ci_type' = $1", p.tableName, attributes),
 					[]interface{}{"2"}
 			},
 		},
@@ -265,7 +302,7 @@ func (p *PostgresqlEngine) Search(test []Resource) []BenchmarkResult {
 			name:        "attributes.ci_type包含多个值",
 			description: "匹配attributes.ci_type在指定数组中的资源",
 			queryFunc: func() (string, []interface{}) {
-				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE attributes->>'ci_type' IN ($1, $2, $3)", p.tableName),
+				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s->>'ci_type' IN ($1, $2, $3)", p.tableName, attributes),
 					[]interface{}{"2", "3", "4"}
 			},
 		},
@@ -273,7 +310,7 @@ func (p *PostgresqlEngine) Search(test []Resource) []BenchmarkResult {
 			name:        "attributes.ci_type不包含多个值",
 			description: "匹配attributes.ci_type不在指定数组中的资源",
 			queryFunc: func() (string, []interface{}) {
-				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE attributes->>'ci_type' NOT IN ($1, $2, $3)", p.tableName),
+				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s->>'ci_type' NOT IN ($1, $2, $3)", p.tableName, attributes),
 					[]interface{}{"2", "3", "4"}
 			},
 		},
@@ -283,7 +320,7 @@ func (p *PostgresqlEngine) Search(test []Resource) []BenchmarkResult {
 			queryFunc: func() (string, []interface{}) {
 				return fmt.Sprintf(`SELECT COUNT(*)
 FROM %s 
-WHERE attributes->>'location' ILIKE $1`, p.tableName), []interface{}{"%project_root%"}
+WHERE %s->>'location' ILIKE $1`, p.tableName, attributes), []interface{}{"%project_root%"}
 			},
 		},
 
@@ -291,12 +328,42 @@ WHERE attributes->>'location' ILIKE $1`, p.tableName), []interface{}{"%project_r
 			name:        "attributes.rand_string in 搜索",
 			description: "attributes.rand_string in 搜索",
 			queryFunc: func() (string, []interface{}) {
-				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE attributes->>'rand_string' =  ANY($1)", p.tableName),
+				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s->>'rand_string' =  ANY($1)", p.tableName, attributes),
 					[]interface{}{randStr}
 			},
 		},
 	}
 
+	if bigMapInsert {
+		testCases = append(testCases, struct {
+			name        string
+			description string
+			queryFunc   func() (string, []interface{})
+		}{
+			name:        Operation_BigMapSearch,
+			description: "按大字段中的叶子值精确匹配",
+			queryFunc: func() (string, []interface{}) {
+				return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s->'bigmap'->'node_0000'->'meta'->>'title' = $1", p.tableName, attributes),
+					[]interface{}{"Title 0"}
+			},
+		})
+	}
+
+	// 超时由服务端的 statement_timeout 执行，只在查询使用的连接上设置，不影响插入
+	timeout := queryTimeout("pg")
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		logger.Error("获取 PostgreSQL 连接失败", "err", err)
+		return nil
+	}
+	defer conn.Release()
+	if timeout > 0 {
+		if _, err := conn.Exec(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds())); err != nil {
+			logger.Warn("设置 statement_timeout 失败", "err", err)
+		}
+		defer conn.Exec(ctx, "RESET statement_timeout")
+	}
+
 	// 执行每个测试用例，多次执行取平均值
 	for _, tc := range testCases {
 		const executionCount = 5 // 每个测试用例执行5次
@@ -304,26 +371,39 @@ WHERE attributes->>'location' ILIKE $1`, p.tableName), []interface{}{"%project_r
 		var totalRecord int
 		var lastError error
 		var successCount int
+		var timedOut bool
 
 		query, args := tc.queryFunc()
+		// 共享布局时每个查询都加上租户过滤，租户名由程序生成，可以直接写入语句
+		if p.scope.filtered() {
+			query += fmt.Sprintf(" AND %s->>'tenant_id' = '%s'", attributes, p.scope.tenant)
+		}
 
 		// 执行多次搜索
 		for i := 0; i < executionCount; i++ {
 			start := time.Now()
 
 			var count int
-			err := p.pool.QueryRow(ctx, query, args...).Scan(&count)
+			queryCtx, cancel := queryContext(timeout, true)
+			err := conn.QueryRow(queryCtx, query, args...).Scan(&count)
+			cancel()
 
 			duration := time.Since(start)
 
 			if err != nil {
 				lastError = err
+				recordWorkload(0, 1)
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
 				continue
 			}
 
 			totalDuration += duration
 			totalRecord += count
 			successCount++
+			recordWorkload(1, 0)
 		}
 
 		// 计算平均值
@@ -349,6 +429,12 @@ WHERE attributes->>'location' ILIKE $1`, p.tableName), []interface{}{"%project_r
 				mark += fmt.Sprintf("，最后错误: %v", lastError)
 			}
 		}
+		if timedOut {
+			mark = timeoutMark(timeout, successCount)
+			if successCount == 0 {
+				avgDuration = timeout
+			}
+		}
 
 		results = append(results, BenchmarkResult{
 			Operation:  tc.name,
@@ -356,6 +442,7 @@ WHERE attributes->>'location' ILIKE $1`, p.tableName), []interface{}{"%project_r
 			Duration:   avgDuration,
 			Records:    avgRecords,
 			Throughput: throughput,
+			TimedOut:   timedOut,
 			Mark:       mark,
 		})
 	}
@@ -367,11 +454,11 @@ func (p *PostgresqlEngine) ClearData() {
 	ctx := context.Background()
 	_, err := p.pool.Exec(ctx, fmt.Sprintf("TRUNCATE TABLE %s", p.tableName))
 	if err != nil {
-		log.Printf("%s 清理数据失败: %v", p.Name(), err)
+		logger.Warn("清理数据失败", "engine", p.Name(), "err", err)
 		return
 	}
 
-	fmt.Printf("%s 数据清理完成\n", p.Name())
+	logger.Info("数据清理完成", "engine", p.Name())
 }
 
 func (p *PostgresqlEngine) Close() {
@@ -380,6 +467,66 @@ func (p *PostgresqlEngine) Close() {
 	}
 }
 
+// attributes 列的类型
+const (
+	AttributesJSONB = "jsonb"
+	AttributesText  = "text"
+)
+
+// 查询中的 attributes 列，text 列每次查询都要把整个文档解析为 jsonb
+func (p *PostgresqlEngine) attributesColumn() string {
+	if p.attributesType() == AttributesText {
+		return "attributes::jsonb"
+	}
+	return "attributes"
+}
+
+func (p *PostgresqlEngine) attributesType() string {
+	if p.config.AttributesType == "" {
+		return AttributesJSONB
+	}
+	return p.config.AttributesType
+}
+
+// Name 默认的 jsonb 列时为 PostgreSQL，text 列和多租户布局在名称中注明，便于在结果中区分
 func (p *PostgresqlEngine) Name() string {
-	return "PostgreSQL"
+	if p.attributesType() == AttributesText {
+		return "PostgreSQL(text)" + p.scope.suffix()
+	}
+	return "PostgreSQL" + p.scope.suffix()
+}
+
+var _ TenantEngine = (*PostgresqlEngine)(nil)
+
+// SetTenant 独立布局时每个租户使用和租户同名的 schema
+func (p *PostgresqlEngine) SetTenant(layout, tenant string) {
+	p.scope = tenantScope{layout: layout, tenant: tenant}
+	p.tableName = p.config.TableName
+	if p.scope.separate() {
+		p.tableName = tenant + "." + p.config.TableName
+	}
+}
+
+var _ Snapshotter = (*PostgresqlEngine)(nil)
+
+// Snapshot 用 pg_dump 导出表结构、索引和数据，需要本机安装 PostgreSQL 客户端工具
+func (p *PostgresqlEngine) Snapshot(dir, name string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return runTool(p.toolEnv(), "pg_dump", append(p.toolArgs(), "-t", p.tableName, "-Fc", "-f", filepath.Join(dir, name+".pgdump"))...)
+}
+
+// Restore 用 pg_restore 删除并重建表
+func (p *PostgresqlEngine) Restore(dir, name string) error {
+	return runTool(p.toolEnv(), "pg_restore", append(p.toolArgs(), "--clean", "--if-exists", "--no-owner", filepath.Join(dir, name+".pgdump"))...)
+}
+
+func (p *PostgresqlEngine) toolArgs() []string {
+	return []string{"-h", p.config.Host, "-p", strconv.Itoa(p.config.Port), "-U", p.config.User, "-d", p.config.DBName}
+}
+
+// 密码通过环境变量传入，不出现在命令行中
+func (p *PostgresqlEngine) toolEnv() []string {
+	return []string{"PGPASSWORD=" + p.config.Password, "PGSSLMODE=" + p.config.SSLMode}
 }
@@ -0,0 +1,1055 @@
+package db_benchmark
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 条件的比较方式
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpIn   = "in"
+	OpNin  = "nin"
+	OpLike = "like" // SQL 风格的 % 和 _ 通配符，\ 转义，不区分大小写
+)
+
+// WorkloadQuery 与引擎无关的查询，由查询日志转换而来：条件之间为 AND，结果为匹配的记录数。
+// 日志中条件相同、只有值不同的查询合并为一个
+type WorkloadQuery struct {
+	Conditions []Condition
+	Calls      int64         // 日志中出现的次数
+	Latency    time.Duration // 日志中的平均耗时，日志没有记录时为 0
+	Source     string        // 第一次出现时的原始查询
+}
+
+// Condition 一个字段条件，Field 为 resource_id、parent_id、version、deleted 或 attributes.<路径>
+type Condition struct {
+	Field  string
+	Op     string
+	Values []interface{} // eq、ne、like 只有一个值；queryParam 为日志中被规范化掉的参数
+}
+
+// 日志中被规范化掉的参数，如 pg_stat_statements 中的 $1，回放时使用样本记录中该字段的值。
+// many 为 true 时是数组参数（= ANY($1)），展开为多个样本的值
+type queryParam struct {
+	many bool
+}
+
+// 和 Search 中的 IN 查询一样，数组参数最多展开为这么多个值
+const replayParamValues = 100
+
+// 记录的顶层字段，其他字段都在 attributes 中
+var resourceFields = map[string]bool{"resource_id": true, "parent_id": true, "version": true, "deleted": true}
+
+// 条件的形式，值用 ? 代替，相同形式的查询合并
+func (q WorkloadQuery) shape() string {
+	var parts []string
+	for _, c := range q.Conditions {
+		switch c.Op {
+		case OpIn, OpNin:
+			parts = append(parts, fmt.Sprintf("%s %s (...)", c.Field, strings.ToUpper(c.Op)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s %s ?", c.Field, strings.ToUpper(c.Op)))
+		}
+	}
+	if len(parts) == 0 {
+		return "全部记录"
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// queryLog 读取日志时按形式合并查询，记录不支持的查询
+type queryLog struct {
+	queries map[string]*WorkloadQuery
+	latency map[string]time.Duration // 有耗时的查询的耗时之和
+	timed   map[string]int64         // 有耗时的次数
+	skipped map[string]int64         // 不支持的原因和次数
+}
+
+func (l *queryLog) add(conditions []Condition, calls int64, latency time.Duration, source string) {
+	if calls <= 0 {
+		calls = 1
+	}
+	sort.SliceStable(conditions, func(i, j int) bool { return conditions[i].Field < conditions[j].Field })
+	q := WorkloadQuery{Conditions: conditions}
+	key := q.shape()
+	existing, ok := l.queries[key]
+	if !ok {
+		q.Source = source
+		existing = &q
+		l.queries[key] = existing
+	}
+	existing.Calls += calls
+	if latency > 0 {
+		l.latency[key] += latency * time.Duration(calls)
+		l.timed[key] += calls
+	}
+}
+
+func (l *queryLog) skip(reason string, calls int64) {
+	if calls <= 0 {
+		calls = 1
+	}
+	l.skipped[reason] += calls
+}
+
+// 读取查询日志的函数，按 -replay-format 选择
+var queryLogReaders = map[string]func(r io.Reader, log *queryLog) error{
+	"pg":    readPgStatStatements,
+	"es":    readESSlowLog,
+	"mongo": readMongoProfile,
+}
+
+// 读取查询日志，按出现次数从多到少返回前 top 个查询，top 为 0 时返回全部
+func readQueryLog(path, format string, top int) ([]WorkloadQuery, error) {
+	reader, ok := queryLogReaders[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q, want pg, es or mongo", format)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	log := &queryLog{
+		queries: map[string]*WorkloadQuery{},
+		latency: map[string]time.Duration{},
+		timed:   map[string]int64{},
+		skipped: map[string]int64{},
+	}
+	if err := reader(f, log); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for reason, calls := range log.skipped {
+		logger.Warn("跳过不支持的查询", "reason", reason, "calls", calls)
+	}
+	queries := make([]WorkloadQuery, 0, len(log.queries))
+	for key, q := range log.queries {
+		if n := log.timed[key]; n > 0 {
+			q.Latency = log.latency[key] / time.Duration(n)
+		}
+		queries = append(queries, *q)
+	}
+	sort.SliceStable(queries, func(i, j int) bool {
+		if queries[i].Calls != queries[j].Calls {
+			return queries[i].Calls > queries[j].Calls
+		}
+		return queries[i].shape() < queries[j].shape()
+	})
+	if top > 0 && len(queries) > top {
+		queries = queries[:top]
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%s: no supported query found", path)
+	}
+	return queries, nil
+}
+
+// 把日志中的字段名转换为 Condition.Field：去掉表名等前缀和 ES 的 .keyword 子字段
+func normalizeField(parts []string) (string, error) {
+	if n := len(parts); n > 1 && parts[n-1] == "keyword" {
+		parts = parts[:n-1]
+	}
+	for i, part := range parts {
+		if part == "attributes" && i+1 < len(parts) {
+			return strings.Join(parts[i:], "."), nil
+		}
+		if resourceFields[part] && i == len(parts)-1 {
+			return part, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported field %s", strings.Join(parts, "."))
+}
+
+// ---------- pg_stat_statements ----------
+
+// 读取 pg_stat_statements 导出的 CSV，需要 query 和 calls 列，mean_exec_time（13 之前为 mean_time）列为平均耗时（毫秒），如：
+// \copy (SELECT query, calls, mean_exec_time FROM pg_stat_statements) TO 'statements.csv' CSV HEADER
+func readPgStatStatements(r io.Reader, log *queryLog) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	queryColumn, ok := columns["query"]
+	if !ok {
+		return errors.New("missing query column")
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		calls := int64(1)
+		if i, ok := columns["calls"]; ok {
+			calls, _ = strconv.ParseInt(record[i], 10, 64)
+		}
+		var latency time.Duration
+		for _, name := range []string{"mean_exec_time", "mean_time"} {
+			if i, ok := columns[name]; ok {
+				ms, _ := strconv.ParseFloat(record[i], 64)
+				latency = time.Duration(ms * float64(time.Millisecond))
+				break
+			}
+		}
+		query := record[queryColumn]
+		conditions, err := parseSQLWhere(query)
+		if err != nil {
+			log.skip(err.Error(), calls)
+			continue
+		}
+		log.add(conditions, calls, latency, query)
+	}
+}
+
+type sqlToken struct {
+	kind string // ident、string、number、param、op
+	text string
+}
+
+var sqlTokenPattern = regexp.MustCompile(`^(?:` +
+	`(?P<string>'(?:[^']|'')*')|` +
+	`(?P<param>\$\d+)|` +
+	`(?P<number>-?\d+(?:\.\d+)?)|` +
+	`(?P<ident>"(?:[^"]|"")+"|[A-Za-z_][\w$]*(?:\.(?:"(?:[^"]|"")+"|[A-Za-z_][\w$]*))*)|` +
+	`(?P<op>->>|->|::|<>|!=|<=|>=|@>|~~\*|~~|[=<>(),;*?]))`)
+
+func tokenizeSQL(query string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	names := sqlTokenPattern.SubexpNames()
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		m := sqlTokenPattern.FindStringSubmatchIndex(query)
+		if m == nil {
+			return nil, fmt.Errorf("unsupported sql near %q", truncate(query, 20))
+		}
+		for i := 1; i < len(names); i++ {
+			if m[2*i] >= 0 {
+				tokens = append(tokens, sqlToken{kind: names[i], text: query[m[2*i]:m[2*i+1]]})
+				break
+			}
+		}
+		query = query[m[1]:]
+	}
+	return tokens, nil
+}
+
+func truncate(text string, n int) string {
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return text
+}
+
+// sqlParser 解析 SELECT 语句的 WHERE 子句，只支持用 AND 连接的字段条件
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (p *sqlParser) peek() sqlToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return sqlToken{}
+}
+
+func (p *sqlParser) next() sqlToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// 下一个 token 是否为关键字或符号 word，是时跳过
+func (p *sqlParser) accept(word string) bool {
+	t := p.peek()
+	if (t.kind == "ident" || t.kind == "op") && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func parseSQLWhere(query string) ([]Condition, error) {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 || !strings.EqualFold(tokens[0].text, "select") {
+		return nil, errors.New("not a select statement")
+	}
+	// WHERE 子句到 GROUP BY、ORDER BY、LIMIT 等为止，子查询中的 WHERE 不算
+	depth, start, end := 0, -1, len(tokens)
+	for i, t := range tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == "ident":
+			switch strings.ToLower(t.text) {
+			case "where":
+				if start < 0 {
+					start = i + 1
+				}
+			case "group", "order", "limit", "offset", "having", "for", "union":
+				if start >= 0 && end == len(tokens) {
+					end = i
+				}
+			}
+		case depth == 0 && t.text == ";" && end == len(tokens):
+			end = i
+		}
+	}
+	if start < 0 {
+		return nil, nil
+	}
+	p := &sqlParser{tokens: tokens[start:end]}
+	conditions, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unsupported sql %q in where clause", p.peek().text)
+	}
+	return conditions, nil
+}
+
+func (p *sqlParser) conjunction() ([]Condition, error) {
+	var conditions []Condition
+	for {
+		if p.accept("(") {
+			inner, err := p.conjunction()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, errors.New("unbalanced parentheses in where clause")
+			}
+			conditions = append(conditions, inner...)
+		} else {
+			condition, err := p.condition()
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition...)
+		}
+		if p.accept("or") {
+			return nil, errors.New("OR is not supported")
+		}
+		if !p.accept("and") {
+			return conditions, nil
+		}
+	}
+}
+
+// 一个比较，@> 可能产生多个条件
+func (p *sqlParser) condition() ([]Condition, error) {
+	if p.accept("not") {
+		return nil, errors.New("NOT before a condition is not supported")
+	}
+	field, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch strings.ToLower(op.text) {
+	case "=":
+		if p.accept("any") {
+			values, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			return []Condition{{Field: field, Op: OpIn, Values: values}}, nil
+		}
+		value, err := p.value()
+		return []Condition{{Field: field, Op: OpEq, Values: []interface{}{value}}}, err
+	case "<>", "!=":
+		if p.accept("all") {
+			values, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			return []Condition{{Field: field, Op: OpNin, Values: values}}, nil
+		}
+		value, err := p.value()
+		return []Condition{{Field: field, Op: OpNe, Values: []interface{}{value}}}, err
+	case "in":
+		values, err := p.list()
+		return []Condition{{Field: field, Op: OpIn, Values: values}}, err
+	case "not":
+		if !p.accept("in") {
+			return nil, fmt.Errorf("NOT %s is not supported", p.peek().text)
+		}
+		values, err := p.list()
+		return []Condition{{Field: field, Op: OpNin, Values: values}}, err
+	case "like", "ilike", "~~", "~~*":
+		value, err := p.value()
+		return []Condition{{Field: field, Op: OpLike, Values: []interface{}{value}}}, err
+	case "@>":
+		return p.contains(field)
+	}
+	return nil, fmt.Errorf("operator %s is not supported", op.text)
+}
+
+// 字段表达式：列名、attributes->'a'->>'b'，忽略 ::text 等类型转换
+func (p *sqlParser) operand() (string, error) {
+	t := p.next()
+	if t.kind != "ident" {
+		return "", fmt.Errorf("unsupported sql %q in where clause", t.text)
+	}
+	var parts []string
+	for _, part := range strings.Split(t.text, ".") {
+		parts = append(parts, strings.ToLower(strings.Trim(part, `"`)))
+	}
+	for {
+		switch {
+		case p.accept("->"), p.accept("->>"):
+			key := p.next()
+			if key.kind != "string" {
+				return "", errors.New("only constant json keys are supported")
+			}
+			parts = append(parts, sqlString(key.text))
+		case p.accept("::"):
+			p.next()
+		case parts[len(parts)-1] == "attributes" && p.peek().text == "@>":
+			return "attributes", nil
+		default:
+			return normalizeField(parts)
+		}
+	}
+}
+
+func (p *sqlParser) value() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case "param":
+		return queryParam{}, nil
+	case "string":
+		value := sqlString(t.text)
+		for p.accept("::") {
+			p.next()
+		}
+		return value, nil
+	case "number":
+		return strconv.ParseFloat(t.text, 64)
+	case "ident":
+		switch strings.ToLower(t.text) {
+		case "true", "false":
+			return strings.EqualFold(t.text, "true"), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported value %q", t.text)
+}
+
+// IN (...) 的值列表或 ANY($1) 的数组参数
+func (p *sqlParser) list() ([]interface{}, error) {
+	if !p.accept("(") {
+		return nil, errors.New("expected ( after IN")
+	}
+	if t := p.peek(); t.kind == "param" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == ")" {
+		p.pos += 2
+		return []interface{}{queryParam{many: true}}, nil
+	}
+	var values []interface{}
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if !p.accept(")") {
+		return nil, errors.New("expected ) after IN list")
+	}
+	return values, nil
+}
+
+// jsonb 包含：attributes @> '{"ci_type": 2}' 转换为每个叶子值的 eq 条件
+func (p *sqlParser) contains(field string) ([]Condition, error) {
+	t := p.next()
+	if t.kind != "string" {
+		return nil, errors.New("@> with a parameter is not supported")
+	}
+	for p.accept("::") {
+		p.next()
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(sqlString(t.text)), &doc); err != nil {
+		return nil, fmt.Errorf("@> with a non-object value is not supported")
+	}
+	return containsConditions(field, doc)
+}
+
+func containsConditions(field string, doc map[string]interface{}) ([]Condition, error) {
+	var conditions []Condition
+	for k, v := range doc {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			inner, err := containsConditions(field+"."+k, v)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, inner...)
+		case []interface{}:
+			return nil, errors.New("@> with an array value is not supported")
+		default:
+			conditions = append(conditions, Condition{Field: field + "." + k, Op: OpEq, Values: []interface{}{v}})
+		}
+	}
+	return conditions, nil
+}
+
+// 去掉 SQL 字符串的引号
+func sqlString(text string) string {
+	return strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+}
+
+// ---------- Elasticsearch 慢查询日志 ----------
+
+// ES 慢查询日志中的 took_millis
+var esTookPattern = regexp.MustCompile(`took_millis\[(\d+)\]`)
+
+// 读取 ES 的慢查询日志（index.search.slowlog），支持纯文本格式中的 source[...] 和
+// ES 7 之后 JSON 格式中的 source 或 elasticsearch.slowlog.source 字段
+func readESSlowLog(r io.Reader, log *queryLog) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var source string
+		var latency time.Duration
+		var entry map[string]interface{}
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &entry) == nil {
+			for _, key := range []string{"elasticsearch.slowlog.source", "source"} {
+				if s, ok := entry[key].(string); ok {
+					source = s
+					break
+				}
+			}
+			for _, key := range []string{"elasticsearch.slowlog.took_millis", "took_millis"} {
+				if ms, err := toFloat(entry[key]); err == nil {
+					latency = time.Duration(ms * float64(time.Millisecond))
+					break
+				}
+			}
+		} else {
+			source = bracketed(line, "source[")
+			if m := esTookPattern.FindStringSubmatch(line); m != nil {
+				ms, _ := strconv.ParseFloat(m[1], 64)
+				latency = time.Duration(ms * float64(time.Millisecond))
+			}
+		}
+		if source == "" {
+			log.skip("log line without source", 1)
+			continue
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(source), &body); err != nil {
+			log.skip("source is not valid json", 1)
+			continue
+		}
+		var conditions []Condition
+		if query, ok := body["query"].(map[string]interface{}); ok {
+			var err error
+			if conditions, err = parseESQuery(query); err != nil {
+				log.skip(err.Error(), 1)
+				continue
+			}
+		}
+		log.add(conditions, 1, latency, source)
+	}
+	return scanner.Err()
+}
+
+// prefix 之后到对应的 ] 为止的内容，source 中可能有 []
+func bracketed(line, prefix string) string {
+	i := strings.Index(line, prefix)
+	if i < 0 {
+		return ""
+	}
+	start := i + len(prefix)
+	depth := 0
+	inString, escaped := false, false
+	for j := start; j < len(line); j++ {
+		c := line[j]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = inString
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '[' || c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == ']':
+			if depth == 0 {
+				return line[start:j]
+			}
+			depth--
+		}
+	}
+	return ""
+}
+
+// 转换 ES 查询 DSL，支持 term、terms、match、match_phrase、wildcard、prefix、match_all、
+// constant_score 和只有一个 should 子句的 bool
+func parseESQuery(query map[string]interface{}) ([]Condition, error) {
+	if len(query) != 1 {
+		return nil, errors.New("es query with several clauses at one level is not supported")
+	}
+	for kind, body := range query {
+		clause, _ := body.(map[string]interface{})
+		switch kind {
+		case "match_all":
+			return nil, nil
+		case "constant_score":
+			filter, ok := clause["filter"].(map[string]interface{})
+			if !ok {
+				return nil, errors.New("constant_score without filter")
+			}
+			return parseESQuery(filter)
+		case "bool":
+			return parseESBool(clause)
+		case "term", "terms", "match", "match_phrase", "wildcard", "prefix":
+			return parseESLeaf(kind, clause)
+		}
+		return nil, fmt.Errorf("es query %s is not supported", kind)
+	}
+	return nil, nil
+}
+
+func parseESBool(clause map[string]interface{}) ([]Condition, error) {
+	var conditions []Condition
+	should := esClauses(clause["should"])
+	if len(should) > 1 || (len(should) == 1 && (clause["must"] != nil || clause["filter"] != nil)) {
+		return nil, errors.New("bool should is not supported")
+	}
+	for _, key := range []string{"must", "filter"} {
+		for _, c := range append(esClauses(clause[key]), should...) {
+			inner, err := parseESQuery(c)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, inner...)
+		}
+		should = nil
+	}
+	for _, c := range esClauses(clause["must_not"]) {
+		inner, err := parseESQuery(c)
+		if err != nil {
+			return nil, err
+		}
+		if len(inner) != 1 {
+			return nil, errors.New("must_not with several conditions is not supported")
+		}
+		switch inner[0].Op {
+		case OpEq:
+			inner[0].Op = OpNe
+		case OpIn:
+			inner[0].Op = OpNin
+		default:
+			return nil, fmt.Errorf("must_not %s is not supported", inner[0].Op)
+		}
+		conditions = append(conditions, inner[0])
+	}
+	return conditions, nil
+}
+
+// bool 的子句可以是一个对象或对象数组
+func esClauses(v interface{}) []map[string]interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		var clauses []map[string]interface{}
+		for _, item := range v {
+			if c, ok := item.(map[string]interface{}); ok {
+				clauses = append(clauses, c)
+			}
+		}
+		return clauses
+	}
+	return nil
+}
+
+func parseESLeaf(kind string, clause map[string]interface{}) ([]Condition, error) {
+	if len(clause) != 1 {
+		return nil, fmt.Errorf("es %s with several fields is not supported", kind)
+	}
+	for name, value := range clause {
+		field, err := normalizeField(strings.Split(name, "."))
+		if err != nil {
+			return nil, err
+		}
+		// 对象形式：{"field": {"value": ...}} 或 {"field": {"query": ...}}
+		if m, ok := value.(map[string]interface{}); ok {
+			if v, ok := m["value"]; ok {
+				value = v
+			} else {
+				value = m["query"]
+			}
+		}
+		switch kind {
+		case "terms":
+			values, ok := value.([]interface{})
+			if !ok {
+				return nil, errors.New("terms lookup is not supported")
+			}
+			return []Condition{{Field: field, Op: OpIn, Values: values}}, nil
+		case "wildcard", "prefix":
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("es %s needs a string", kind)
+			}
+			if kind == "prefix" {
+				pattern = escapeLike(pattern) + "%"
+			} else {
+				pattern = wildcardToLike(pattern)
+			}
+			return []Condition{{Field: field, Op: OpLike, Values: []interface{}{pattern}}}, nil
+		}
+		return []Condition{{Field: field, Op: OpEq, Values: []interface{}{value}}}, nil
+	}
+	return nil, nil
+}
+
+// 转义文本中的 \、% 和 _，作为 like 模式中的普通字符
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+// ES 的 wildcard 模式（* 和 ?，\ 转义）转换为 like 模式
+func wildcardToLike(pattern string) string {
+	var like strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			like.WriteString(escapeLike(pattern[i : i+1]))
+		case c == '*':
+			like.WriteByte('%')
+		case c == '?':
+			like.WriteByte('_')
+		default:
+			like.WriteString(escapeLike(pattern[i : i+1]))
+		}
+	}
+	return like.String()
+}
+
+// like 模式转换为 ES 的 wildcard 模式
+func likeToWildcard(pattern string) string {
+	var wildcard strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			if strings.IndexByte(`*?\`, pattern[i]) >= 0 {
+				wildcard.WriteByte('\\')
+			}
+			wildcard.WriteByte(pattern[i])
+		case c == '%':
+			wildcard.WriteByte('*')
+		case c == '_':
+			wildcard.WriteByte('?')
+		case c == '*' || c == '?':
+			wildcard.WriteByte('\\')
+			wildcard.WriteByte(c)
+		default:
+			wildcard.WriteByte(c)
+		}
+	}
+	return wildcard.String()
+}
+
+// like 模式转换为不区分大小写、匹配整个值的正则表达式
+func likeToRegex(pattern string) string {
+	var regex strings.Builder
+	regex.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			regex.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '%':
+			regex.WriteString(".*")
+		case c == '_':
+			regex.WriteString(".")
+		default:
+			regex.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	regex.WriteString("$")
+	return regex.String()
+}
+
+// ---------- MongoDB profiler ----------
+
+// 读取 MongoDB profiler 的输出，如 mongoexport -d benchmark_db -c system.profile 导出的扩展 JSON，
+// 每行一个文档，或一个文档数组。支持 find 的 filter、count 的 query 和 aggregate 第一个 $match
+func readMongoProfile(r io.Reader, log *queryLog) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var docs []map[string]interface{}
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &docs); err != nil {
+			return err
+		}
+	} else {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		for decoder.More() {
+			var doc map[string]interface{}
+			if err := decoder.Decode(&doc); err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+	}
+	for _, doc := range docs {
+		doc, _ = unwrapExtJSON(doc).(map[string]interface{})
+		var latency time.Duration
+		if ms, err := toFloat(doc["millis"]); err == nil {
+			latency = time.Duration(ms * float64(time.Millisecond))
+		}
+		command, _ := doc["command"].(map[string]interface{})
+		var filter map[string]interface{}
+		switch {
+		case command["filter"] != nil:
+			filter, _ = command["filter"].(map[string]interface{})
+		case command["count"] != nil:
+			filter, _ = command["query"].(map[string]interface{})
+			if filter == nil {
+				filter = map[string]interface{}{}
+			}
+		case command["aggregate"] != nil:
+			if pipeline, ok := command["pipeline"].([]interface{}); ok && len(pipeline) > 0 {
+				if stage, ok := pipeline[0].(map[string]interface{}); ok {
+					filter, _ = stage["$match"].(map[string]interface{})
+				}
+			}
+		case command["find"] != nil:
+			filter = map[string]interface{}{}
+		}
+		if filter == nil {
+			log.skip(fmt.Sprintf("mongo %v operation is not a query", doc["op"]), 1)
+			continue
+		}
+		conditions, err := parseMongoFilter(filter)
+		if err != nil {
+			log.skip(err.Error(), 1)
+			continue
+		}
+		source, _ := json.Marshal(filter)
+		log.add(conditions, 1, latency, string(source))
+	}
+	return nil
+}
+
+// mongoRegex 扩展 JSON 中的正则表达式
+type mongoRegex struct {
+	pattern, options string
+}
+
+// 把扩展 JSON 的 $numberInt、$regularExpression 等转换为普通的值
+func unwrapExtJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for k, inner := range v {
+				switch k {
+				case "$numberInt", "$numberLong", "$numberDouble", "$numberDecimal":
+					n, _ := toFloat(inner)
+					return n
+				case "$oid", "$date", "$symbol":
+					if m, ok := inner.(map[string]interface{}); ok {
+						return unwrapExtJSON(m)
+					}
+					return inner
+				case "$regularExpression":
+					m, _ := inner.(map[string]interface{})
+					pattern, _ := m["pattern"].(string)
+					options, _ := m["options"].(string)
+					return mongoRegex{pattern: pattern, options: options}
+				}
+			}
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, inner := range v {
+			out[k] = unwrapExtJSON(inner)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, inner := range v {
+			out[i] = unwrapExtJSON(inner)
+		}
+		return out
+	}
+	return v
+}
+
+func parseMongoFilter(filter map[string]interface{}) ([]Condition, error) {
+	var conditions []Condition
+	for name, value := range filter {
+		if name == "$and" {
+			clauses, _ := value.([]interface{})
+			for _, clause := range clauses {
+				m, ok := clause.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("invalid $and clause")
+				}
+				inner, err := parseMongoFilter(m)
+				if err != nil {
+					return nil, err
+				}
+				conditions = append(conditions, inner...)
+			}
+			continue
+		}
+		if strings.HasPrefix(name, "$") {
+			return nil, fmt.Errorf("mongo %s is not supported", name)
+		}
+		field, err := normalizeField(strings.Split(name, "."))
+		if err != nil {
+			return nil, err
+		}
+		inner, err := mongoConditions(field, value)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, inner...)
+	}
+	return conditions, nil
+}
+
+func mongoConditions(field string, value interface{}) ([]Condition, error) {
+	if regex, ok := value.(mongoRegex); ok {
+		pattern, err := regexToLike(regex.pattern)
+		return []Condition{{Field: field, Op: OpLike, Values: []interface{}{pattern}}}, err
+	}
+	operators, ok := value.(map[string]interface{})
+	if !ok || len(operators) == 0 || !strings.HasPrefix(firstKey(operators), "$") {
+		return []Condition{{Field: field, Op: OpEq, Values: []interface{}{value}}}, nil
+	}
+	var conditions []Condition
+	for op, v := range operators {
+		switch op {
+		case "$eq":
+			conditions = append(conditions, Condition{Field: field, Op: OpEq, Values: []interface{}{v}})
+		case "$ne":
+			conditions = append(conditions, Condition{Field: field, Op: OpNe, Values: []interface{}{v}})
+		case "$in", "$nin":
+			values, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("mongo %s needs an array", op)
+			}
+			kind := OpIn
+			if op == "$nin" {
+				kind = OpNin
+			}
+			conditions = append(conditions, Condition{Field: field, Op: kind, Values: values})
+		case "$regex":
+			var pattern string
+			switch v := v.(type) {
+			case string:
+				pattern = v
+			case mongoRegex:
+				pattern = v.pattern
+			}
+			like, err := regexToLike(pattern)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, Condition{Field: field, Op: OpLike, Values: []interface{}{like}})
+		case "$options":
+		default:
+			return nil, fmt.Errorf("mongo %s is not supported", op)
+		}
+	}
+	return conditions, nil
+}
+
+func firstKey(m map[string]interface{}) string {
+	for k := range m {
+		return k
+	}
+	return ""
+}
+
+// 只有可选的 ^ 和 $ 锚点、. 和 .* 通配符，其余都是普通字符或转义字符的正则表达式可以转换为 like
+func regexToLike(pattern string) (string, error) {
+	prefix, suffix := "%", "%"
+	if strings.HasPrefix(pattern, "^") {
+		prefix, pattern = "", pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`) {
+		suffix, pattern = "", pattern[:len(pattern)-1]
+	}
+	var like strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern) && !isAlnum(pattern[i+1]):
+			i++
+			like.WriteString(escapeLike(pattern[i : i+1]))
+		case c == '.' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+			like.WriteByte('%')
+		case c == '.':
+			like.WriteByte('_')
+		case strings.IndexByte(`\^$*+?()[]{}|`, c) >= 0:
+			return "", fmt.Errorf("mongo regex %q is not supported", pattern)
+		default:
+			like.WriteString(escapeLike(pattern[i : i+1]))
+		}
+	}
+	return prefix + like.String() + suffix, nil
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
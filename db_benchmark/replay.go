@@ -0,0 +1,529 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Replayer 执行与引擎无关的查询，返回匹配的记录数，用于 -replay 回放查询日志
+type Replayer interface {
+	Replay(ctx context.Context, query WorkloadQuery) (int64, error)
+}
+
+// 引擎不能执行查询中的条件，回放时跳过该查询
+var errUnsupportedQuery = errors.New("unsupported by the engine")
+
+var (
+	_ Replayer = (*ElasticsearchEngine)(nil)
+	_ Replayer = (*PostgresqlEngine)(nil)
+	_ Replayer = (*GraphQLEngine)(nil)
+	_ Replayer = (*MongoDB)(nil)
+	_ Replayer = (*EtcdEngine)(nil)
+)
+
+// 结果中的操作名，各引擎相同，便于对比
+func replayOperation(i int, query WorkloadQuery) string {
+	return fmt.Sprintf("回放#%d %s", i+1, truncate(query.shape(), 60))
+}
+
+// 回放查询日志：每个查询用样本记录填充参数后执行 5 次取平均值，引擎不支持的查询跳过
+func replayQueries(engine BenchmarkEngine, queries []WorkloadQuery, sample []Resource) []BenchmarkResult {
+	var results []BenchmarkResult
+	replayer := engine.(Replayer)
+	// 各引擎的回放都在客户端取消超时的查询
+	timeout := queryTimeout(engineKind(engine))
+	for i, query := range queries {
+		operation := replayOperation(i, query)
+		bound, err := query.bind(sample)
+		if err != nil {
+			logger.Warn("回放查询缺少参数值，已跳过", "query", operation, "err", err)
+			continue
+		}
+
+		const executionCount = 5
+		var totalDuration time.Duration
+		var totalRecords int64
+		var successCount int
+		var lastError error
+		var timedOut, unsupported bool
+
+		for j := 0; j < executionCount; j++ {
+			start := time.Now()
+			ctx, cancel := queryContext(timeout, false)
+			count, err := replayer.Replay(ctx, bound)
+			cancel()
+			if errors.Is(err, errUnsupportedQuery) {
+				unsupported = true
+				lastError = err
+				break
+			}
+			if err != nil {
+				lastError = err
+				recordWorkload(0, 1)
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
+				continue
+			}
+			totalDuration += time.Since(start)
+			totalRecords += count
+			successCount++
+			recordWorkload(1, 0)
+		}
+		if unsupported {
+			logger.Warn("引擎不支持回放的查询，已跳过", "engine", engine.Name(), "query", operation, "err", lastError)
+			continue
+		}
+
+		var avgDuration time.Duration
+		var avgRecords int64
+		var throughput float64
+		mark := fmt.Sprintf("成功, 日志中 %d 次", query.Calls)
+
+		if successCount > 0 {
+			avgDuration = totalDuration / time.Duration(successCount)
+			avgRecords = totalRecords / int64(successCount)
+			if avgDuration > 0 {
+				throughput = float64(avgRecords) / avgDuration.Seconds()
+			}
+		} else {
+			mark = fmt.Sprintf("所有执行都失败: %v", lastError)
+		}
+
+		if successCount < executionCount {
+			mark = fmt.Sprintf("部分成功 (%d/%d)", successCount, executionCount)
+			if lastError != nil {
+				mark += fmt.Sprintf("，最后错误: %v", lastError)
+			}
+		}
+		if timedOut {
+			mark = timeoutMark(timeout, successCount)
+			if successCount == 0 {
+				avgDuration = timeout
+			}
+		}
+
+		results = append(results, BenchmarkResult{
+			Operation:  operation,
+			Database:   engine.Name(),
+			Duration:   avgDuration,
+			Records:    int(avgRecords),
+			Throughput: throughput,
+			TimedOut:   timedOut,
+			Mark:       mark,
+		})
+
+		fmt.Printf("%-12s | %-30s | %-18v | %-10d | %s\n",
+			engine.Name(), operation, avgDuration, int(avgRecords), mark)
+	}
+	return results
+}
+
+// 用样本记录中对应字段的值填充日志中被规范化掉的参数，并把日志中的值转换为样本中该字段的类型，
+// 如 pg_stat_statements 中的 '2' 在 MongoDB 中按数字 2 查询
+func (q WorkloadQuery) bind(sample []Resource) (WorkloadQuery, error) {
+	if len(sample) == 0 {
+		return q, errors.New("no sample records")
+	}
+	bound := q
+	bound.Conditions = make([]Condition, len(q.Conditions))
+	for i, c := range q.Conditions {
+		var values []interface{}
+		params := 0
+		for _, v := range c.Values {
+			param, ok := v.(queryParam)
+			switch {
+			case !ok && c.Op != OpLike:
+				values = append(values, coerceValue(v, sample[0], c.Field))
+			case !ok:
+				values = append(values, v)
+			case param.many:
+				params += replayParamValues
+			default:
+				params++
+			}
+		}
+		if params > 0 {
+			// IN 的多个参数使用不同样本中不重复的值
+			filled := sampleValues(sample, c.Field, params)
+			if len(filled) == 0 {
+				return q, fmt.Errorf("field %s is not in the sample records", c.Field)
+			}
+			if c.Op == OpLike {
+				filled = []interface{}{"%" + escapeLike(valueText(filled[0])) + "%"}
+			}
+			values = append(values, filled...)
+		}
+		bound.Conditions[i] = Condition{Field: c.Field, Op: c.Op, Values: values}
+	}
+	return bound, nil
+}
+
+// 按顺序取样本中最多 n 个不重复的字段值
+func sampleValues(sample []Resource, field string, n int) []interface{} {
+	var values []interface{}
+	seen := make(map[string]bool)
+	for i := 0; i < len(sample) && len(values) < n; i++ {
+		v, ok := resourceField(sample[i], field)
+		if !ok || seen[valueText(v)] {
+			continue
+		}
+		seen[valueText(v)] = true
+		values = append(values, v)
+	}
+	return values
+}
+
+func resourceField(r Resource, field string) (interface{}, bool) {
+	switch field {
+	case "resource_id":
+		return r.ResourceId, true
+	case "parent_id":
+		return r.ParentId, true
+	case "version":
+		return r.Version, true
+	case "deleted":
+		return r.Deleted, true
+	}
+	return lookupPath(r.Attributes, strings.TrimPrefix(field, "attributes."))
+}
+
+// 按 a.b.c 的路径读取嵌套对象中的值
+func lookupPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// 日志中的值和样本中该字段的类型不同时转换：数字字段的字符串转换为数字，字符串字段的数字转换为字符串
+func coerceValue(v interface{}, sample Resource, field string) interface{} {
+	example, ok := resourceField(sample, field)
+	if !ok {
+		return v
+	}
+	switch example.(type) {
+	case int, int32, int64, float64:
+		if s, ok := v.(string); ok {
+			if n, err := strconv.ParseFloat(s, 64); err == nil {
+				return n
+			}
+		}
+	case string:
+		if _, ok := v.(string); !ok && v != nil {
+			return valueText(v)
+		}
+	}
+	return v
+}
+
+// 值的文本形式，PostgreSQL 的 ->> 和 etcd 的比较都按文本
+func valueText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func valueTexts(values []interface{}) []string {
+	texts := make([]string, len(values))
+	for i, v := range values {
+		texts[i] = valueText(v)
+	}
+	return texts
+}
+
+// 在解码后的记录上执行查询，用于不能在服务端过滤的 etcd。
+// 和其他引擎一样，ne 和 nin 也匹配没有该字段的记录
+func (q WorkloadQuery) matcher() func(doc map[string]interface{}) bool {
+	type check func(v interface{}, ok bool) bool
+	var checks []func(doc map[string]interface{}) bool
+	for _, c := range q.Conditions {
+		texts := make(map[string]bool)
+		for _, text := range valueTexts(c.Values) {
+			texts[text] = true
+		}
+		var test check
+		switch c.Op {
+		case OpEq, OpIn:
+			test = func(v interface{}, ok bool) bool { return ok && texts[valueText(v)] }
+		case OpNe, OpNin:
+			test = func(v interface{}, ok bool) bool { return !ok || !texts[valueText(v)] }
+		case OpLike:
+			pattern := regexp.MustCompile(likeToRegex(valueText(c.Values[0])))
+			test = func(v interface{}, ok bool) bool { return ok && pattern.MatchString(valueText(v)) }
+		}
+		field := c.Field
+		checks = append(checks, func(doc map[string]interface{}) bool {
+			v, ok := lookupPath(doc, field)
+			return test(v, ok)
+		})
+	}
+	return func(doc map[string]interface{}) bool {
+		for _, check := range checks {
+			if !check(doc) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Replay 转换为 bool 查询，attributes 中的字符串在动态映射时使用 .keyword 子字段，like 使用不区分大小写的 wildcard
+func (e *ElasticsearchEngine) Replay(ctx context.Context, query WorkloadQuery) (int64, error) {
+	filter, mustNot := []interface{}{}, []interface{}{}
+	for _, c := range query.Conditions {
+		field := c.Field
+		if _, isString := c.Values[0].(string); strings.HasPrefix(field, "attributes.") && (isString || c.Op == OpLike) {
+			// flattened 的叶子值本身就是 keyword
+			if e.config.Mapping == "" || e.config.Mapping == MappingDynamic || !strings.HasPrefix(field, "attributes.bigmap.") {
+				field += ".keyword"
+			}
+		}
+		var clause map[string]interface{}
+		switch c.Op {
+		case OpEq, OpNe:
+			clause = map[string]interface{}{"term": map[string]interface{}{field: c.Values[0]}}
+		case OpIn, OpNin:
+			clause = map[string]interface{}{"terms": map[string]interface{}{field: c.Values}}
+		case OpLike:
+			clause = map[string]interface{}{"wildcard": map[string]interface{}{field: map[string]interface{}{
+				"value":            likeToWildcard(valueText(c.Values[0])),
+				"case_insensitive": true,
+			}}}
+		}
+		if c.Op == OpNe || c.Op == OpNin {
+			mustNot = append(mustNot, clause)
+		} else {
+			filter = append(filter, clause)
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filter, "must_not": mustNot}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := e.client.Count(
+		e.client.Count.WithContext(ctx),
+		e.client.Count.WithIndex(e.indexName),
+		e.client.Count.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("%s", res.String())
+	}
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+// Replay 转换为 SQL，attributes 中的字段用 ->> 按文本比较，ne 和 nin 也匹配没有该字段的记录
+func (p *PostgresqlEngine) Replay(ctx context.Context, query WorkloadQuery) (int64, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, c := range query.Conditions {
+		column := p.replayColumn(c.Field)
+		switch c.Op {
+		case OpEq:
+			where = append(where, fmt.Sprintf("%s = %s", column, arg(valueText(c.Values[0]))))
+		case OpNe:
+			where = append(where, fmt.Sprintf("%s IS DISTINCT FROM %s", column, arg(valueText(c.Values[0]))))
+		case OpIn:
+			where = append(where, fmt.Sprintf("%s = ANY(%s)", column, arg(valueTexts(c.Values))))
+		case OpNin:
+			where = append(where, fmt.Sprintf("(%s IS NULL OR %s <> ALL(%s))", column, column, arg(valueTexts(c.Values))))
+		case OpLike:
+			where = append(where, fmt.Sprintf("%s ILIKE %s", column, arg(valueText(c.Values[0]))))
+		}
+	}
+	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s", p.tableName)
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	var count int64
+	err := p.pool.QueryRow(ctx, sql, args...).Scan(&count)
+	return count, err
+}
+
+// 条件字段对应的 SQL 表达式，都转换为文本
+func (p *PostgresqlEngine) replayColumn(field string) string {
+	switch field {
+	case "resource_id", "parent_id":
+		return field
+	case "version", "deleted":
+		return field + "::text"
+	}
+	keys := strings.Split(strings.TrimPrefix(field, "attributes."), ".")
+	column := p.attributesColumn()
+	for i, key := range keys {
+		op := "->"
+		if i == len(keys)-1 {
+			op = "->>"
+		}
+		column += fmt.Sprintf("%s'%s'", op, strings.ReplaceAll(key, "'", "''"))
+	}
+	return column
+}
+
+// Replay 转换为 GraphQL 的过滤条件，attributes 中的字段使用 jsonb 包含，不支持 like
+func (g *GraphQLEngine) Replay(ctx context.Context, query WorkloadQuery) (int64, error) {
+	var conds []gqlCond
+	for _, c := range query.Conditions {
+		var attributeKeys []string
+		if strings.HasPrefix(c.Field, "attributes.") {
+			attributeKeys = strings.Split(strings.TrimPrefix(c.Field, "attributes."), ".")
+		}
+		eq := func(v interface{}) gqlCond {
+			if attributeKeys == nil {
+				return gqlEq(c.Field, v)
+			}
+			for i := len(attributeKeys) - 1; i >= 0; i-- {
+				v = map[string]interface{}{attributeKeys[i]: v}
+			}
+			return gqlContains("attributes", v)
+		}
+		anyOf := func() gqlCond {
+			var children []gqlCond
+			for _, v := range c.Values {
+				children = append(children, eq(v))
+			}
+			return gqlOr(children...)
+		}
+		switch c.Op {
+		case OpEq:
+			conds = append(conds, eq(c.Values[0]))
+		case OpNe:
+			conds = append(conds, gqlNot(eq(c.Values[0])))
+		case OpIn:
+			conds = append(conds, anyOf())
+		case OpNin:
+			conds = append(conds, gqlNot(anyOf()))
+		case OpLike:
+			if attributeKeys != nil {
+				return 0, fmt.Errorf("%w: like on %s, graphql cannot filter jsonb values by pattern", errUnsupportedQuery, c.Field)
+			}
+			conds = append(conds, gqlLike(c.Field, valueText(c.Values[0])))
+		}
+	}
+	count, err := g.count(ctx, gqlAnd(conds...))
+	return int64(count), err
+}
+
+// Replay 转换为 $and 连接的过滤条件，like 使用不区分大小写的 $regex
+func (m *MongoDB) Replay(ctx context.Context, query WorkloadQuery) (int64, error) {
+	var clauses bson.A
+	for _, c := range query.Conditions {
+		var value interface{}
+		switch c.Op {
+		case OpEq:
+			value = c.Values[0]
+		case OpNe:
+			value = bson.D{{"$ne", c.Values[0]}}
+		case OpIn:
+			value = bson.D{{"$in", c.Values}}
+		case OpNin:
+			value = bson.D{{"$nin", c.Values}}
+		case OpLike:
+			value = bson.D{{"$regex", likeToRegex(valueText(c.Values[0]))}}
+		}
+		clauses = append(clauses, bson.D{{c.Field, value}})
+	}
+	filter := bson.D{}
+	if len(clauses) > 0 {
+		filter = bson.D{{"$and", clauses}}
+	}
+	return m.client.Database(m.db).Collection(m.Collection).CountDocuments(ctx, filter)
+}
+
+// Replay 扫描前缀下的全部记录在客户端过滤，parent_id 的 eq 条件缩小为 <prefix>/<parent_id>/ 前缀
+func (e *EtcdEngine) Replay(ctx context.Context, query WorkloadQuery) (int64, error) {
+	search := etcdSearch{key: e.prefix + "/", prefix: true, record: query.matcher()}
+	for _, c := range query.Conditions {
+		if c.Field == "parent_id" && c.Op == OpEq {
+			search.key = e.prefix + "/" + valueText(c.Values[0]) + "/"
+		}
+	}
+	return e.count(ctx, search)
+}
+
+// 回放的查询列表和按日志中的次数加权的平均耗时，权重反映真实负载中各查询的占比
+func writeReplayResults(results []BenchmarkResult, queries []WorkloadQuery, bs *bytes.Buffer) {
+	if len(queries) == 0 {
+		return
+	}
+	var totalCalls int64
+	calls := make(map[string]int64, len(queries))
+	for i, q := range queries {
+		calls[replayOperation(i, q)] = q.Calls
+		totalCalls += q.Calls
+	}
+	bs.WriteString(fmt.Sprintf("\n回放查询日志: %d 个查询, 日志中共 %d 次\n", len(queries), totalCalls))
+	for i, q := range queries {
+		line := fmt.Sprintf("%s: 日志中 %d 次", replayOperation(i, q), q.Calls)
+		if q.Latency > 0 {
+			line += fmt.Sprintf(", 日志中平均耗时 %v", q.Latency)
+		}
+		bs.WriteString(line + "\n")
+		bs.WriteString(fmt.Sprintf("    %s\n", truncate(strings.Join(strings.Fields(q.Source), " "), 120)))
+	}
+
+	type weighted struct {
+		duration time.Duration
+		calls    int64
+		queries  int
+	}
+	var order []string
+	byEngine := make(map[string]*weighted)
+	for _, r := range results {
+		n, ok := calls[r.Operation]
+		if !ok || r.Duration <= 0 {
+			continue
+		}
+		w := byEngine[r.Database]
+		if w == nil {
+			w = &weighted{}
+			byEngine[r.Database] = w
+			order = append(order, r.Database)
+		}
+		w.duration += r.Duration * time.Duration(n)
+		w.calls += n
+		w.queries++
+	}
+	bs.WriteString("按日志中的次数加权的平均耗时:\n")
+	for _, name := range order {
+		w := byEngine[name]
+		bs.WriteString(fmt.Sprintf("%-15s %v (完成 %d/%d 个查询)\n", name, w.duration/time.Duration(w.calls), w.queries, len(queries)))
+	}
+}
@@ -0,0 +1,96 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Snapshotter 可以保存和恢复已导入数据的引擎。只调整查询时用 -restore 恢复快照，不需要重新生成和导入数据
+type Snapshotter interface {
+	// Snapshot 保存当前数据，name 在各引擎之间唯一，文件类快照保存在 dir 中
+	Snapshot(dir, name string) error
+	// Restore 用快照替换当前数据
+	Restore(dir, name string) error
+}
+
+// snapshotManifest 和各引擎的快照一起保存，恢复时提供查询用的样本和报告中的文档统计
+type snapshotManifest struct {
+	Name    string        `json:"name"`
+	Created time.Time     `json:"created"`
+	Records int           `json:"records"`
+	BigMap  bool          `json:"big_map"`
+	Stats   DocumentStats `json:"stats"`
+	Sample  []Resource    `json:"sample"`
+}
+
+func manifestFile(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// 查询只用到 resource_id 和 attributes.rand_string，样本中不保存其余属性，避免 -big-map 时清单过大
+func saveManifest(dir, name string, records int, stats DocumentStats, sample []Resource) error {
+	manifest := snapshotManifest{
+		Name:    name,
+		Created: time.Now(),
+		Records: records,
+		BigMap:  bigMapInsert,
+		Stats:   stats,
+		Sample:  make([]Resource, len(sample)),
+	}
+	for i, resource := range sample {
+		manifest.Sample[i] = Resource{
+			ResourceId: resource.ResourceId,
+			ParentId:   resource.ParentId,
+			Attributes: map[string]interface{}{"rand_string": resource.Attributes["rand_string"]},
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestFile(dir, name), data, 0o644)
+}
+
+func loadManifest(dir, name string) (*snapshotManifest, error) {
+	data, err := os.ReadFile(manifestFile(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s not found in %s: %v", name, dir, err)
+	}
+	var manifest snapshotManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %v", manifestFile(dir, name), err)
+	}
+	return &manifest, nil
+}
+
+var snapshotNameInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// 每个引擎的快照名，如 base 和 Elasticsearch(flattened) 为 base_elasticsearch_flattened。
+// 只包含小写字母、数字和下划线，可以直接用作 ES 快照名和文件名
+func snapshotName(name string, engine BenchmarkEngine) string {
+	return strings.Trim(snapshotNameInvalid.ReplaceAllString(strings.ToLower(name+"_"+engine.Name()), "_"), "_")
+}
+
+// 执行 pg_dump、mongorestore 等外部工具，失败时错误中带上命令的输出
+func runTool(env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	logger.Debug("执行快照命令", "command", name)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// DocumentStats 测试数据序列化为 JSON 后的大小分布，单位为字节
+type DocumentStats struct {
+	Count     int
+	Min       int
+	Avg       int
+	P50       int
+	P99       int
+	Max       int
+	Total     int64
+	Histogram []SizeBucket
+}
+
+// SizeBucket 直方图的一个区间，包含大小在 (上一个区间的 Upper, Upper] 中的文档
+type SizeBucket struct {
+	Upper int
+	Count int
+}
+
+// 按 ResourceStr 统计，各引擎写入的内容相同，用它比较不同模板和引擎的吞吐量
+func documentStats(data []Resource) DocumentStats {
+	stats := DocumentStats{Count: len(data)}
+	if len(data) == 0 {
+		return stats
+	}
+	sizes := make([]int, len(data))
+	for i, resource := range data {
+		sizes[i] = len(resource.ResourceStr)
+		stats.Total += int64(sizes[i])
+	}
+	sort.Ints(sizes)
+	stats.Min, stats.Max = sizes[0], sizes[len(sizes)-1]
+	stats.Avg = int(stats.Total / int64(len(sizes)))
+	stats.P50 = percentile(sizes, 0.50)
+	stats.P99 = percentile(sizes, 0.99)
+
+	// 区间上界按 2 的幂增长
+	for _, size := range sizes {
+		upper := 1
+		if size > 1 {
+			upper = 1 << bits.Len(uint(size-1))
+		}
+		if n := len(stats.Histogram); n > 0 && stats.Histogram[n-1].Upper == upper {
+			stats.Histogram[n-1].Count++
+		} else {
+			stats.Histogram = append(stats.Histogram, SizeBucket{Upper: upper, Count: 1})
+		}
+	}
+	return stats
+}
+
+// sizes 已经排序
+func percentile(sizes []int, p float64) int {
+	i := int(float64(len(sizes))*p+0.5) - 1
+	return sizes[min(max(i, 0), len(sizes)-1)]
+}
+
+func (s DocumentStats) write(bs *bytes.Buffer) {
+	bs.WriteString(fmt.Sprintf("\n文档大小: %d 条, 最小 %s, 平均 %s, p50 %s, p99 %s, 最大 %s, 合计 %s\n",
+		s.Count, formatBytes(int64(s.Min)), formatBytes(int64(s.Avg)), formatBytes(int64(s.P50)),
+		formatBytes(int64(s.P99)), formatBytes(int64(s.Max)), formatBytes(s.Total)))
+	peak := 0
+	for _, bucket := range s.Histogram {
+		peak = max(peak, bucket.Count)
+	}
+	for _, bucket := range s.Histogram {
+		width := bucket.Count * 40 / peak
+		bs.WriteString(fmt.Sprintf("  <= %-10s %8d %s\n", formatBytes(int64(bucket.Upper)), bucket.Count, strings.Repeat("#", max(width, 1))))
+	}
+}
+
+func formatBytes(n int64) string {
+	units := []struct {
+		size int64
+		name string
+	}{{1 << 30, "GB"}, {1 << 20, "MB"}, {1 << 10, "KB"}}
+	for _, unit := range units {
+		if n >= unit.size {
+			return strings.TrimSuffix(fmt.Sprintf("%.2f", float64(n)/float64(unit.size)), ".00") + unit.name
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// 写入的 JSON 字节数
+func payloadBytes(data []Resource) int64 {
+	var total int64
+	for _, resource := range data {
+		total += int64(len(resource.ResourceStr))
+	}
+	return total
+}
@@ -0,0 +1,198 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 多租户数据布局
+const (
+	TenantShared   = "shared"   // 所有租户共用一个索引、表或集合，查询按 attributes.tenant_id 过滤
+	TenantSeparate = "separate" // 每个租户单独的索引、schema 或集合
+)
+
+// TenantEngine 支持多租户布局的引擎。SetTenant 之后的 ClearData、Insert 和 Search 只作用于该租户，
+// tenant 为空时作用于共享的索引、表或集合
+type TenantEngine interface {
+	SetTenant(layout, tenant string)
+}
+
+// tenantScope 引擎当前的租户布局和租户
+type tenantScope struct {
+	layout string
+	tenant string
+}
+
+// 共享布局下查询需要按租户过滤
+func (s tenantScope) filtered() bool {
+	return s.layout == TenantShared && s.tenant != ""
+}
+
+// 独立布局下数据写入租户自己的索引、schema 或集合
+func (s tenantScope) separate() bool {
+	return s.layout == TenantSeparate && s.tenant != ""
+}
+
+// 引擎名称的后缀，便于在结果中区分布局
+func (s tenantScope) suffix() string {
+	if s.layout == "" {
+		return ""
+	}
+	return "[" + s.layout + "]"
+}
+
+func tenantID(i int) string {
+	return fmt.Sprintf("tenant_%d", i)
+}
+
+// 按 attributes.tenant_id 拆分数据，每个租户的查询样本最多 sampleSize 条
+func splitTenants(data []Resource, tenants int) ([][]Resource, [][]Resource) {
+	index := make(map[string]int, tenants)
+	for i := 0; i < tenants; i++ {
+		index[tenantID(i)] = i
+	}
+	parts := make([][]Resource, tenants)
+	samples := make([][]Resource, tenants)
+	for _, resource := range data {
+		i := index[resource.Attributes["tenant_id"].(string)]
+		parts[i] = append(parts[i], resource)
+		if len(samples[i]) < sampleSize {
+			samples[i] = append(samples[i], resource)
+		}
+	}
+	return parts, samples
+}
+
+// 按布局写入所有租户的数据：共享布局一次写入，独立布局逐个租户写入，插入结果合并为一条
+func insertTenants(engine BenchmarkEngine, layout string, data []Resource, parts [][]Resource) []BenchmarkResult {
+	scoped := engine.(TenantEngine)
+	if layout == TenantShared {
+		scoped.SetTenant(layout, "")
+		engine.ClearData()
+		return engine.Insert(data, batchSize)
+	}
+	total := BenchmarkResult{Operation: Operation_InsertTotal, Database: engine.Name()}
+	for i, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		scoped.SetTenant(layout, tenantID(i))
+		engine.ClearData()
+		for _, result := range engine.Insert(part, batchSize) {
+			if result.Operation == Operation_InsertTotal {
+				total.Duration += result.Duration
+				total.Records += result.Records
+				total.Bytes += result.Bytes
+			}
+		}
+	}
+	scoped.SetTenant(layout, "")
+	if total.Duration > 0 {
+		total.Throughput = float64(total.Records) / total.Duration.Seconds()
+	}
+	return []BenchmarkResult{total}
+}
+
+// 逐个租户执行查询，每个查询的耗时和命中数取各租户的平均值
+func searchTenants(engine BenchmarkEngine, layout string, samples [][]Resource) []BenchmarkResult {
+	scoped := engine.(TenantEngine)
+	var order []string
+	sums := make(map[string]*BenchmarkResult)
+	counts := make(map[string]int)
+	for i, sample := range samples {
+		if len(sample) == 0 {
+			continue
+		}
+		scoped.SetTenant(layout, tenantID(i))
+		for _, result := range engine.Search(sample) {
+			sum, ok := sums[result.Operation]
+			if !ok {
+				order = append(order, result.Operation)
+				sum = &BenchmarkResult{Operation: result.Operation, Database: result.Database, Mark: "成功"}
+				sums[result.Operation] = sum
+			}
+			sum.Duration += result.Duration
+			sum.Records += result.Records
+			sum.TimedOut = sum.TimedOut || result.TimedOut
+			if result.Mark != "成功" {
+				sum.Mark = fmt.Sprintf("%s: %s", tenantID(i), result.Mark)
+			}
+			counts[result.Operation]++
+		}
+	}
+	scoped.SetTenant(layout, "")
+	var results []BenchmarkResult
+	for _, operation := range order {
+		result := *sums[operation]
+		result.Duration /= time.Duration(counts[operation])
+		result.Records /= counts[operation]
+		if result.Duration > 0 {
+			result.Throughput = float64(result.Records) / result.Duration.Seconds()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 同一个引擎共享布局和独立布局的查询耗时对比，共享布局比独立布局多出的耗时是按租户过滤的开销。
+// 两种布局的命中数不同说明过滤条件漏掉或多查到了其他租户的数据
+func writeTenantComparison(results []BenchmarkResult, bs *bytes.Buffer) {
+	type pair struct {
+		shared, separate *BenchmarkResult
+	}
+	pairs := make(map[string]*pair)
+	var keys []string
+	for i := range results {
+		result := &results[i]
+		if strings.Contains(result.Operation, Operation_Insert) {
+			continue
+		}
+		name, layout, ok := strings.Cut(result.Database, "[")
+		if !ok {
+			continue
+		}
+		key := name + "\x00" + result.Operation
+		p, exists := pairs[key]
+		if !exists {
+			p = &pair{}
+			pairs[key] = p
+			keys = append(keys, key)
+		}
+		switch strings.TrimSuffix(layout, "]") {
+		case TenantShared:
+			p.shared = result
+		case TenantSeparate:
+			p.separate = result
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return strings.SplitN(keys[i], "\x00", 2)[0] < strings.SplitN(keys[j], "\x00", 2)[0]
+	})
+	var lines []string
+	for _, key := range keys {
+		p := pairs[key]
+		if p.shared == nil || p.separate == nil {
+			continue
+		}
+		name, operation, _ := strings.Cut(key, "\x00")
+		overhead := "-"
+		if p.separate.Duration > 0 {
+			overhead = fmt.Sprintf("%+.1f%%", (float64(p.shared.Duration)/float64(p.separate.Duration)-1)*100)
+		}
+		line := fmt.Sprintf("%-15s %-30s shared %-15v separate %-15v 过滤开销 %s", name, operation, p.shared.Duration, p.separate.Duration, overhead)
+		if p.shared.Records != p.separate.Records {
+			line += fmt.Sprintf(", 命中数不同: shared %d, separate %d", p.shared.Records, p.separate.Records)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	bs.WriteString("\n多租户布局对比（每个租户的平均值）:\n")
+	for _, line := range lines {
+		bs.WriteString(line + "\n")
+	}
+}
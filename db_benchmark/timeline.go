@@ -0,0 +1,166 @@
+package db_benchmark
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 插入过程中每批的耗时，只在输出时间线时收集，每个采样间隔取走一次
+var latencies struct {
+	sync.Mutex
+	collect bool
+	values  []time.Duration
+}
+
+func observeLatency(latency time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	latencies.Lock()
+	defer latencies.Unlock()
+	if latencies.collect {
+		latencies.values = append(latencies.values, latency)
+	}
+}
+
+// 取走上一个间隔的耗时，collect 为 false 时停止收集
+func takeLatencies(collect bool) []time.Duration {
+	latencies.Lock()
+	defer latencies.Unlock()
+	values := latencies.values
+	latencies.values = nil
+	latencies.collect = collect
+	return values
+}
+
+var timelineHeader = []string{"engine", "elapsed_s", "records", "records_per_s", "total_records", "batches", "failed_batches",
+	"latency_avg_ms", "latency_p50_ms", "latency_p99_ms", "latency_max_ms"}
+
+// Timeline 插入阶段按固定间隔输出吞吐量和每批耗时的 CSV，用来观察索引变大之后写入是否变慢。
+// 每行写入后立即刷新，长时间的测试可以边跑边画图
+type Timeline struct {
+	file     *os.File
+	writer   *csv.Writer
+	interval time.Duration
+}
+
+func newTimeline(path string, interval time.Duration) (*Timeline, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Timeline{file: file, writer: csv.NewWriter(file), interval: interval}
+	if err := t.write(timelineHeader); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Timeline) write(record []string) error {
+	t.writer.Write(record)
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+func (t *Timeline) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.file.Close()
+}
+
+// timelineRun 一个引擎插入阶段的采样
+type timelineRun struct {
+	timeline *Timeline
+	engine   BenchmarkEngine
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// 开始采样，timeline 为 nil 时返回 nil。需要在 startChaos 之后调用，startChaos 会清零计数
+func (t *Timeline) start(engine BenchmarkEngine) *timelineRun {
+	if t == nil {
+		return nil
+	}
+	r := &timelineRun{timeline: t, engine: engine, stop: make(chan struct{}), done: make(chan struct{})}
+	takeLatencies(true)
+	go r.sample()
+	return r
+}
+
+func (r *timelineRun) sample() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.timeline.interval)
+	defer ticker.Stop()
+	start, previous := time.Now(), time.Now()
+	baseOps, baseFailed := workload.ops.Load(), workload.failed.Load()
+	ops, failed := baseOps, baseFailed
+	take := func(last bool) {
+		now := time.Now()
+		currentOps, currentFailed := workload.ops.Load(), workload.failed.Load()
+		values := takeLatencies(!last)
+		records := currentOps - ops
+		throughput := 0.0
+		if elapsed := now.Sub(previous).Seconds(); elapsed > 0 {
+			throughput = float64(records) / elapsed
+		}
+		record := []string{
+			r.engine.Name(),
+			strconv.FormatFloat(now.Sub(start).Seconds(), 'f', 1, 64),
+			strconv.FormatInt(records, 10),
+			strconv.FormatFloat(throughput, 'f', 2, 64),
+			strconv.FormatInt(currentOps-baseOps, 10),
+			strconv.Itoa(len(values)),
+			strconv.FormatInt(currentFailed-failed, 10),
+		}
+		record = append(record, latencyColumns(values)...)
+		if err := r.timeline.write(record); err != nil {
+			logger.Warn("写入时间线失败", "err", err)
+		}
+		ops, failed, previous = currentOps, currentFailed, now
+	}
+	for {
+		select {
+		case <-ticker.C:
+			take(false)
+		case <-r.stop:
+			take(true)
+			return
+		}
+	}
+}
+
+// 平均、p50、p99 和最大耗时，单位毫秒，间隔内没有完成的批次时为空
+func latencyColumns(values []time.Duration) []string {
+	if len(values) == 0 {
+		return []string{"", "", "", ""}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	var sum time.Duration
+	for _, v := range values {
+		sum += v
+	}
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64)
+	}
+	percentile := func(p float64) time.Duration {
+		return values[min(int(float64(len(values))*p), len(values)-1)]
+	}
+	return []string{ms(sum / time.Duration(len(values))), ms(percentile(0.5)), ms(percentile(0.99)), ms(values[len(values)-1])}
+}
+
+// 结束采样，写入最后一个不完整的间隔
+func (r *timelineRun) finish() {
+	if r == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	fmt.Printf("%s 吞吐量时间线已写入 %s\n", r.engine.Name(), r.timeline.file.Name())
+}
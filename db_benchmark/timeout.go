@@ -0,0 +1,85 @@
+package db_benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 单次查询的超时，键为 -engines 中的名称，"" 为默认值，0 表示不限制
+var queryTimeouts = map[string]time.Duration{}
+
+// 服务端超时（statement_timeout、maxTimeMS）之外客户端多等待的时间，避免客户端先取消，拿不到服务端的超时错误
+const serverTimeoutGrace = 2 * time.Second
+
+// 解析 -query-timeout，如 30s 或 30s,pg=10s,es=5s
+func parseQueryTimeouts(text string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, value, ok := strings.Cut(item, "=")
+		if !ok {
+			kind, value = "", item
+		}
+		switch kind {
+		case "", "es", "pg", "mongo", "etcd", "graphql":
+		default:
+			return nil, fmt.Errorf("unknown engine %q, want es, pg, mongo, etcd or graphql", kind)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout %q", item)
+		}
+		timeouts[kind] = timeout
+	}
+	return timeouts, nil
+}
+
+func queryTimeout(kind string) time.Duration {
+	if timeout, ok := queryTimeouts[kind]; ok {
+		return timeout
+	}
+	return queryTimeouts[""]
+}
+
+// 单次查询的 context，serverSide 为 true 时超时由服务端执行，客户端只作为兜底
+func queryContext(timeout time.Duration, serverSide bool) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	if serverSide {
+		timeout += serverTimeoutGrace
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// 查询是否因为超时被取消：客户端 context 超时、PostgreSQL 的 statement_timeout（57014）、
+// MongoDB 的 maxTimeMS 和 etcd 的 gRPC DeadlineExceeded
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) || status.Code(err) == codes.DeadlineExceeded {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// 超时的查询不再执行剩余的次数，没有成功执行时耗时按超时时间计
+func timeoutMark(timeout time.Duration, successCount int) string {
+	if successCount == 0 {
+		return fmt.Sprintf("超时: 超过 %v 被取消，耗时按超时时间计", timeout)
+	}
+	return fmt.Sprintf("超时: 第 %d 次执行超过 %v 被取消，耗时为之前 %d 次的平均值", successCount+1, timeout, successCount)
+}
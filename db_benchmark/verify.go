@@ -0,0 +1,372 @@
+package db_benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Verifier 可以按 resource_id 读回完整记录的引擎，用于检查写入后数据是否被截断或丢失精度
+type Verifier interface {
+	// Fetch 读回 sample 中的记录，按 resource_id 返回和源数据相同结构的 JSON 文档，数字为 json.Number
+	Fetch(sample []Resource) (map[string]map[string]interface{}, error)
+}
+
+var (
+	_ Verifier = (*ElasticsearchEngine)(nil)
+	_ Verifier = (*PostgresqlEngine)(nil)
+	_ Verifier = (*MongoDB)(nil)
+	_ Verifier = (*EtcdEngine)(nil)
+)
+
+// 差异的种类
+const (
+	DiffMissingRecord = "记录缺失"
+	DiffMissingField  = "字段缺失"
+	DiffExtraField    = "多出字段"
+	DiffType          = "类型变化"
+	DiffPrecision     = "精度丢失"
+	DiffTruncated     = "截断"
+	DiffValue         = "值变化"
+)
+
+// 每个引擎在报告中列出的差异示例数
+const verifyExamples = 10
+
+// VerifyResult 一个引擎的校验结果
+type VerifyResult struct {
+	Database   string
+	Sampled    int
+	Mismatched int            // 有差异的记录数
+	Kinds      map[string]int // 各种差异出现的次数
+	Examples   []string
+	Mark       string
+}
+
+// 从 data 中随机抽取 k 条记录，读回后和源数据逐字段比较
+func verifyRecords(engine BenchmarkEngine, data []Resource, k int) VerifyResult {
+	result := VerifyResult{Database: engine.Name(), Kinds: make(map[string]int)}
+	sample := make([]Resource, 0, min(k, len(data)))
+	for _, i := range rand.Perm(len(data))[:min(k, len(data))] {
+		sample = append(sample, data[i])
+	}
+	result.Sampled = len(sample)
+	fetched, err := engine.(Verifier).Fetch(sample)
+	if err != nil {
+		result.Mark = err.Error()
+		return result
+	}
+	for _, resource := range sample {
+		var diffs []verifyDiff
+		doc, ok := fetched[resource.ResourceId]
+		if !ok {
+			diffs = append(diffs, verifyDiff{kind: DiffMissingRecord})
+		} else {
+			source, err := decodeJSON(resource.ResourceStr)
+			if err != nil {
+				result.Mark = err.Error()
+				return result
+			}
+			compareValues("", source, doc, &diffs)
+		}
+		if len(diffs) == 0 {
+			continue
+		}
+		result.Mismatched++
+		for _, diff := range diffs {
+			result.Kinds[diff.kind]++
+			if len(result.Examples) < verifyExamples {
+				result.Examples = append(result.Examples, resource.ResourceId+" "+diff.String())
+			}
+		}
+	}
+	return result
+}
+
+// 解码 JSON，数字保留为 json.Number，避免解码本身丢失精度
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	err := decoder.Decode(&doc)
+	return doc, err
+}
+
+type verifyDiff struct {
+	kind   string
+	path   string
+	detail string
+}
+
+func (d verifyDiff) String() string {
+	text := d.kind
+	if d.path != "" {
+		text += " " + d.path
+	}
+	if d.detail != "" {
+		text += ": " + d.detail
+	}
+	return text
+}
+
+// 按 JSON 类型逐字段比较，对象的键按顺序比较，结果稳定
+func compareValues(path string, source, actual interface{}, diffs *[]verifyDiff) {
+	if jsonType(source) != jsonType(actual) {
+		*diffs = append(*diffs, verifyDiff{kind: DiffType, path: path, detail: jsonType(source) + " → " + jsonType(actual)})
+		return
+	}
+	switch source := source.(type) {
+	case map[string]interface{}:
+		actual := actual.(map[string]interface{})
+		keys := make([]string, 0, len(source)+len(actual))
+		for k := range source {
+			keys = append(keys, k)
+		}
+		for k := range actual {
+			if _, ok := source[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			s, inSource := source[k]
+			a, inActual := actual[k]
+			switch {
+			case !inActual:
+				*diffs = append(*diffs, verifyDiff{kind: DiffMissingField, path: child})
+			case !inSource:
+				*diffs = append(*diffs, verifyDiff{kind: DiffExtraField, path: child})
+			default:
+				compareValues(child, s, a, diffs)
+			}
+		}
+	case []interface{}:
+		actual := actual.([]interface{})
+		if len(source) != len(actual) {
+			*diffs = append(*diffs, verifyDiff{kind: DiffTruncated, path: path, detail: fmt.Sprintf("%d 项 → %d 项", len(source), len(actual))})
+		}
+		for i := 0; i < min(len(source), len(actual)); i++ {
+			compareValues(fmt.Sprintf("%s[%d]", path, i), source[i], actual[i], diffs)
+		}
+	case json.Number:
+		if diff, ok := compareNumbers(source, actual.(json.Number)); !ok {
+			*diffs = append(*diffs, verifyDiff{kind: diff, path: path, detail: source.String() + " → " + actual.(json.Number).String()})
+		}
+	case string:
+		actual := actual.(string)
+		switch {
+		case source == actual:
+		case len(actual) < len(source) && strings.HasPrefix(source, actual):
+			*diffs = append(*diffs, verifyDiff{kind: DiffTruncated, path: path, detail: fmt.Sprintf("长度 %d → %d", len(source), len(actual))})
+		default:
+			*diffs = append(*diffs, verifyDiff{kind: DiffValue, path: path, detail: fmt.Sprintf("%.40q → %.40q", source, actual)})
+		}
+	default:
+		if source != actual {
+			*diffs = append(*diffs, verifyDiff{kind: DiffValue, path: path, detail: fmt.Sprintf("%v → %v", source, actual)})
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// 按数值比较，1 和 1.0 相同。数值不同但转换为 float64 后相同时为精度丢失，如大于 2^53 的整数
+func compareNumbers(source, actual json.Number) (string, bool) {
+	s, sok := new(big.Float).SetPrec(256).SetString(source.String())
+	a, aok := new(big.Float).SetPrec(256).SetString(actual.String())
+	if !sok || !aok {
+		return DiffValue, source == actual
+	}
+	if s.Cmp(a) == 0 {
+		return "", true
+	}
+	sf, _ := strconv.ParseFloat(source.String(), 64)
+	af, _ := strconv.ParseFloat(actual.String(), 64)
+	if sf == af {
+		return DiffPrecision, false
+	}
+	return DiffValue, false
+}
+
+func writeVerifyResults(results []VerifyResult, bs *bytes.Buffer) {
+	if len(results) == 0 {
+		return
+	}
+	bs.WriteString("\n写入后数据校验:\n")
+	for _, r := range results {
+		if r.Mark != "" {
+			bs.WriteString(fmt.Sprintf("%-20s 抽样 %d 条, 校验失败: %s\n", r.Database, r.Sampled, r.Mark))
+			continue
+		}
+		bs.WriteString(fmt.Sprintf("%-20s 抽样 %d 条, 有差异 %d 条", r.Database, r.Sampled, r.Mismatched))
+		kinds := make([]string, 0, len(r.Kinds))
+		for kind := range r.Kinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			bs.WriteString(fmt.Sprintf(", %s %d", kind, r.Kinds[kind]))
+		}
+		bs.WriteString("\n")
+		for _, example := range r.Examples {
+			bs.WriteString("  " + example + "\n")
+		}
+	}
+}
+
+func sampleIDs(sample []Resource) []string {
+	ids := make([]string, len(sample))
+	for i, resource := range sample {
+		ids[i] = resource.ResourceId
+	}
+	return ids
+}
+
+// Fetch 用 mget 按 _id 读取 _source
+func (e *ElasticsearchEngine) Fetch(sample []Resource) (map[string]map[string]interface{}, error) {
+	body, _ := json.Marshal(map[string]interface{}{"ids": sampleIDs(sample)})
+	res, err := e.client.Mget(bytes.NewReader(body), e.client.Mget.WithIndex(e.indexName))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("mget 失败: %s", res.String())
+	}
+	var result struct {
+		Docs []struct {
+			ID     string          `json:"_id"`
+			Found  bool            `json:"found"`
+			Source json.RawMessage `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	docs := make(map[string]map[string]interface{}, len(result.Docs))
+	for _, doc := range result.Docs {
+		if !doc.Found {
+			continue
+		}
+		if docs[doc.ID], err = decodeJSON(doc.Source); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// Fetch 按 resource_id 读取各列，attributes 以文本读出后解码
+func (p *PostgresqlEngine) Fetch(sample []Resource) (map[string]map[string]interface{}, error) {
+	rows, err := p.pool.Query(context.Background(),
+		fmt.Sprintf("SELECT resource_id, parent_id, version, deleted, attributes::text FROM %s WHERE resource_id = ANY($1)", p.tableName),
+		sampleIDs(sample))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	docs := make(map[string]map[string]interface{}, len(sample))
+	for rows.Next() {
+		var id, parentID, attributes string
+		var version, deleted int
+		if err := rows.Scan(&id, &parentID, &version, &deleted, &attributes); err != nil {
+			return nil, err
+		}
+		doc := map[string]interface{}{
+			"resource_id": id,
+			"parent_id":   parentID,
+			"version":     json.Number(strconv.Itoa(version)),
+			"deleted":     json.Number(strconv.Itoa(deleted)),
+		}
+		decoder := json.NewDecoder(strings.NewReader(attributes))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("%s: %v", id, err)
+		}
+		doc["attributes"] = value
+		docs[id] = doc
+	}
+	return docs, rows.Err()
+}
+
+// Fetch 按 resource_id 查询，文档转换为 relaxed 扩展 JSON 后解码，int32、int64 和 double 都是 JSON 数字
+func (m *MongoDB) Fetch(sample []Resource) (map[string]map[string]interface{}, error) {
+	ctx := context.Background()
+	cursor, err := m.client.Database(m.db).Collection(m.Collection).Find(ctx, bson.M{"resource_id": bson.M{"$in": sampleIDs(sample)}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	docs := make(map[string]map[string]interface{}, len(sample))
+	for cursor.Next(ctx) {
+		data, err := bson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := decodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		delete(doc, "_id")
+		if id, ok := doc["resource_id"].(string); ok {
+			docs[id] = doc
+		}
+	}
+	return docs, cursor.Err()
+}
+
+// Fetch 每 etcdMaxTxnOps 个 key 一个事务读取
+func (e *EtcdEngine) Fetch(sample []Resource) (map[string]map[string]interface{}, error) {
+	docs := make(map[string]map[string]interface{}, len(sample))
+	for i := 0; i < len(sample); i += etcdMaxTxnOps {
+		var ops []clientv3.Op
+		for _, resource := range sample[i:min(i+etcdMaxTxnOps, len(sample))] {
+			ops = append(ops, clientv3.OpGet(e.key(resource)))
+		}
+		res, err := e.client.Txn(context.Background()).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for _, response := range res.Responses {
+			for _, kv := range response.GetResponseRange().Kvs {
+				doc, err := decodeJSON(kv.Value)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", kv.Key, err)
+				}
+				if id, ok := doc["resource_id"].(string); ok {
+					docs[id] = doc
+				}
+			}
+		}
+	}
+	return docs, nil
+}
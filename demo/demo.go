@@ -0,0 +1,185 @@
+// Package demo 把各模块串起来：按模板生成数据集，写入选择的存储，再启动以同一份数据为后端的 http mock 服务。
+package demo
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/gen"
+	"github.com/TreeWu/mock-go/http_mock"
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/seed"
+)
+
+var logger = logging.For("demo")
+
+// Run 执行演示流程，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+	templateFile := fs.String("template", "", "yaml/json document template with value directives, built-in resource template when empty")
+	count := fs.Int("count", 200, "documents to generate")
+	batch := fs.Int("batch", 500, "documents per write")
+	idField := fs.String("id-field", "_id", "document field used as the record id; sequence number when missing")
+	name := fs.String("name", "resources", "collection name, served as /api/<name>")
+	dir := fs.String("dir", "mockgo-demo", "directory for the generated dataset and mock config")
+	port := fs.String("port", ":8080", "listen address of the mock facade")
+	conn := seed.ConnectionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *batch <= 0 {
+		*batch = 1
+	}
+	if *count <= 0 {
+		logger.Error("-count must be positive")
+		return 2
+	}
+	if err := conn.Resolve(); err != nil {
+		logger.Error("applying connection failed", "connection", conn.Connection, "err", err)
+		return 2
+	}
+	if !strings.Contains(*port, ":") {
+		*port = ":" + *port
+	}
+
+	template, err := gen.LoadTemplate(*templateFile)
+	if err != nil {
+		logger.Error("加载模板失败", "template", *templateFile, "err", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 1. 生成数据集，存储和 mock 服务使用同一份数据
+	ids, docs := seed.Generate(template, *count, *idField)
+	logger.Info("数据集已生成", "count", len(docs))
+
+	// 2. 写入存储，没有指定 -target 时只启动 mock 服务
+	seeded := "（未指定 -target 或 -connection，没有写入存储）"
+	if conn.Target != "" {
+		if seeded, err = write(ctx, conn, ids, docs, *batch); err != nil {
+			logger.Error("写入存储失败", "target", conn.Target, "err", err)
+			return 1
+		}
+	}
+
+	// 3. 数据集和 mock 配置写入目录，之后也可以用 mockgo serve 单独启动
+	dataFile, configFile, err := writeFiles(*dir, *name, *idField, ids, docs)
+	if err != nil {
+		logger.Error("写入演示文件失败", "dir", *dir, "err", err)
+		return 1
+	}
+
+	// 4. 启动 mock 服务并打印访问地址
+	handler := http_mock.NewHttpMockHandler(*port, configFile)
+	handler.Admin = true
+	printSummary(*port, *name, dataFile, configFile, seeded, ids[0], docs[0])
+	if err := handler.Start(ctx); err != nil {
+		logger.Error("启动服务器失败", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// 写入存储，返回写入位置的说明
+func write(ctx context.Context, conn *seed.Connection, ids []string, docs []map[string]interface{}, batch int) (string, error) {
+	writer, err := conn.Open(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer writer.Close()
+	if err := writer.Prepare(ctx); err != nil {
+		return "", err
+	}
+	start := time.Now()
+	written, err := seed.Write(ctx, writer, ids, docs, batch)
+	if err != nil {
+		return "", fmt.Errorf("after %d documents: %v", written, err)
+	}
+	return fmt.Sprintf("%d 条记录写入 %s，耗时 %v", written, conn.Describe(), time.Since(start).Round(time.Millisecond)), nil
+}
+
+// 写入数据集（JSON 对象数组，id 放回 idField 字段）和以它为数据的 resource 配置，返回两个文件的绝对路径
+func writeFiles(dir, name, idField string, ids []string, docs []map[string]interface{}) (string, string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	rows := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		row := make(map[string]interface{}, len(doc)+1)
+		for k, v := range doc {
+			row[k] = v
+		}
+		row[idField] = ids[i]
+		rows[i] = row
+	}
+	dataFile := filepath.Join(dir, name+".json")
+	if err := writeJSON(dataFile, rows); err != nil {
+		return "", "", err
+	}
+	configs := []http_mock.MockConfig{{
+		URL:      "/api/" + name,
+		Resource: &http_mock.Resource{ID: idField, Data: dataFile},
+	}}
+	configFile := filepath.Join(dir, "mock.json")
+	if err := writeJSON(configFile, configs); err != nil {
+		return "", "", err
+	}
+	return dataFile, configFile, nil
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
+
+func printSummary(port, name, dataFile, configFile, seeded, firstID string, first map[string]interface{}) {
+	base := "http://localhost" + port[strings.LastIndex(port, ":"):]
+	collection := base + "/api/" + name
+	fmt.Println("数据集:   ", dataFile)
+	fmt.Println("存储:     ", seeded)
+	fmt.Println("Mock 配置:", configFile, "（可以用 mockgo serve -config 单独启动）")
+	fmt.Println("访问地址:")
+	fmt.Println("  列表      GET", collection)
+	fmt.Println("  单个对象  GET", collection+"/"+firstID)
+	if field, v, ok := filterExample(first); ok {
+		fmt.Printf("  按字段过滤 GET %s?%s=%s\n", collection, field, v)
+	}
+	fmt.Println("  新增      POST", collection)
+	fmt.Println("  管理界面  ", base+"/__ui/")
+}
+
+// 取第一个文档中按名称排序的第一个字符串字段作为过滤示例
+func filterExample(doc map[string]interface{}) (string, string, bool) {
+	fields := make([]string, 0, len(doc))
+	for k := range doc {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if s, ok := doc[field].(string); ok && s != "" && !strings.ContainsAny(s, "&#? ") {
+			return field, s, true
+		}
+	}
+	return "", "", false
+}
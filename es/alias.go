@@ -0,0 +1,137 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 带版本的索引名 <alias>-<时间戳>，时间戳的字典序与创建顺序一致
+func versionedIndex(alias string, now time.Time) string {
+	return alias + "-" + now.Format("20060102150405")
+}
+
+// 是否是 alias 的某个版本索引
+func isVersionOf(index, alias string) bool {
+	version, ok := strings.CutPrefix(index, alias+"-")
+	if !ok || len(version) != len("20060102150405") {
+		return false
+	}
+	_, err := time.Parse("20060102150405", version)
+	return err == nil
+}
+
+// AliasIndices 别名当前指向的索引，别名不存在时返回空
+func (esc *ESClient) AliasIndices(alias string) ([]string, error) {
+	res, err := esc.client.GetAlias(context.Background(), alias)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("获取别名失败 %s", res.String())
+	}
+
+	var result map[string]struct {
+		Aliases map[string]json.RawMessage `json:"aliases"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var indices []string
+	for index, info := range result {
+		if _, ok := info.Aliases[alias]; ok {
+			indices = append(indices, index)
+		}
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// SwapAlias 把别名原子地切换到当前索引，返回之前指向的索引
+func (esc *ESClient) SwapAlias(alias string) ([]string, error) {
+	previous, err := esc.AliasIndices(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	// 移除和添加在同一个请求中完成，读请求不会看到别名为空的中间状态
+	var actions []map[string]interface{}
+	for _, index := range previous {
+		if index != esc.index {
+			actions = append(actions, map[string]interface{}{"remove": map[string]string{"index": index, "alias": alias}})
+		}
+	}
+	actions = append(actions, map[string]interface{}{"add": map[string]string{"index": esc.index, "alias": alias}})
+	body, _ := json.Marshal(map[string]interface{}{"actions": actions})
+
+	res, err := esc.client.UpdateAliases(context.Background(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("切换别名失败 %s", res.String())
+	}
+	return previous, nil
+}
+
+// PruneVersions 删除别名的旧版本索引，保留最新的 keep 个（包括当前索引），不会删除别名仍然指向的索引
+func (esc *ESClient) PruneVersions(alias string, keep int) ([]string, error) {
+	res, err := esc.client.CatIndices(context.Background(), alias+"-*")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("获取索引列表失败 %s", res.String())
+	}
+	var rows []struct {
+		Index string `json:"index"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+
+	aliased, err := esc.AliasIndices(alias)
+	if err != nil {
+		return nil, err
+	}
+	inUse := map[string]bool{esc.index: true}
+	for _, index := range aliased {
+		inUse[index] = true
+	}
+
+	var versions []string
+	for _, row := range rows {
+		if isVersionOf(row.Index, alias) {
+			versions = append(versions, row.Index)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+
+	var deleted []string
+	for i, index := range versions {
+		if i < keep || inUse[index] {
+			continue
+		}
+		res, err := esc.client.DeleteIndex(context.Background(), index)
+		if err != nil {
+			return deleted, err
+		}
+		res.Body.Close()
+		if res.IsError() {
+			logger.Warn("删除旧索引失败", "index", index, "response", res.String())
+			continue
+		}
+		deleted = append(deleted, index)
+	}
+	return deleted, nil
+}
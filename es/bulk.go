@@ -0,0 +1,280 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 写入方式
+const (
+	OpTypeIndex  = "index"  // 文档存在时覆盖
+	OpTypeCreate = "create" // 文档存在时跳过，用于可重复执行的导入
+)
+
+// BulkOptions 批量写入参数
+type BulkOptions struct {
+	BatchSize int            // 每批文档数
+	Workers   int            // 并发请求数
+	OpType    string         // index 或 create
+	Pipeline  string         // ingest pipeline，为空时不使用
+	Routing   *routingExpr   // 路由表达式，为空时不指定路由
+	Failures  *FailureReport // 被拒绝的文档记录到这里
+	MaxRate   float64        // 每秒最多写入的文档数，0 表示不限速
+}
+
+// bulkItem 待写入的一条文档
+type bulkItem struct {
+	id      string
+	routing string
+	doc     []byte
+	ack     func() // 文档处理完成（写入成功或被拒绝）后调用，可以为空
+}
+
+// BulkStats 导入统计
+type BulkStats struct {
+	Indexed int64
+	Skipped int64 // create 模式下已存在的文档
+	Failed  int64
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// DocsPerSecond 每秒写入文档数
+func (s BulkStats) DocsPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Indexed) / s.Elapsed.Seconds()
+}
+
+// MBPerSecond 每秒写入的数据量（MB）
+func (s BulkStats) MBPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / 1024 / 1024 / s.Elapsed.Seconds()
+}
+
+func (s BulkStats) String() string {
+	skipped := ""
+	if s.Skipped > 0 {
+		skipped = fmt.Sprintf("，已存在 %d 条", s.Skipped)
+	}
+	return fmt.Sprintf("写入 %d 条%s，失败 %d 条，耗时 %v，%.0f docs/s，%.2f MB/s",
+		s.Indexed, skipped, s.Failed, s.Elapsed.Round(time.Millisecond), s.DocsPerSecond(), s.MBPerSecond())
+}
+
+// BulkLoader 按批次通过 Bulk API 并发写入文档
+type BulkLoader struct {
+	esc      *ESClient
+	options  BulkOptions
+	items    chan bulkItem
+	batches  chan []bulkItem
+	throttle *throttle
+	wg       sync.WaitGroup
+	start    time.Time
+
+	indexed atomic.Int64
+	skipped atomic.Int64
+	failed  atomic.Int64
+	bytes   atomic.Int64
+}
+
+// NewBulkLoader 创建批量导入器
+func (esc *ESClient) NewBulkLoader(options BulkOptions) *BulkLoader {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 1000
+	}
+	if options.Workers <= 0 {
+		options.Workers = 4
+	}
+	if options.OpType == "" {
+		options.OpType = OpTypeIndex
+	}
+	batchSize, workers := options.BatchSize, options.Workers
+	l := &BulkLoader{
+		esc:      esc,
+		options:  options,
+		items:    make(chan bulkItem, batchSize),
+		batches:  make(chan []bulkItem, workers),
+		throttle: newThrottle(options.MaxRate),
+		start:    time.Now(),
+	}
+
+	// 按批次大小攒批
+	go func() {
+		defer close(l.batches)
+		batch := make([]bulkItem, 0, batchSize)
+		for item := range l.items {
+			batch = append(batch, item)
+			if len(batch) >= batchSize {
+				l.batches <- batch
+				batch = make([]bulkItem, 0, batchSize)
+			}
+		}
+		if len(batch) > 0 {
+			l.batches <- batch
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			for batch := range l.batches {
+				l.flush(batch)
+			}
+		}()
+	}
+	return l
+}
+
+// Add 加入一条文档，批次满时由 worker 写入。整批写入失败时不调用 ack，中断后从该文档重新导入
+func (l *BulkLoader) Add(id, routing string, doc []byte, ack func()) {
+	l.items <- bulkItem{id: id, routing: routing, doc: doc, ack: ack}
+}
+
+// Reject 记录一条没有发送的文档，例如缺少路由字段
+func (l *BulkLoader) Reject(id string, doc []byte, errType string, err error) {
+	l.failed.Add(1)
+	reason, _ := json.Marshal(bulkError{Type: errType, Reason: err.Error()})
+	l.options.Failures.record(id, doc, 0, reason)
+}
+
+// 计算文档的路由值
+func (l *BulkLoader) routing(doc map[string]interface{}) (string, error) {
+	return l.options.Routing.value(doc)
+}
+
+// Close 写入剩余文档并等待所有请求完成，返回统计信息
+func (l *BulkLoader) Close() BulkStats {
+	close(l.items)
+	l.wg.Wait()
+	return l.Stats()
+}
+
+// Stats 当前的导入统计
+func (l *BulkLoader) Stats() BulkStats {
+	return BulkStats{
+		Indexed: l.indexed.Load(),
+		Skipped: l.skipped.Load(),
+		Failed:  l.failed.Load(),
+		Bytes:   l.bytes.Load(),
+		Elapsed: time.Since(l.start),
+	}
+}
+
+// 写入一批文档，单条失败只计数不中断导入
+func (l *BulkLoader) flush(batch []bulkItem) {
+	var buf bytes.Buffer
+	for _, item := range batch {
+		meta := map[string]interface{}{"_index": l.esc.index}
+		if item.id != "" {
+			meta["_id"] = item.id
+		}
+		if item.routing != "" {
+			meta["routing"] = item.routing
+		}
+		if l.options.Pipeline != "" {
+			meta["pipeline"] = l.options.Pipeline
+		}
+		line, _ := json.Marshal(map[string]interface{}{l.options.OpType: meta})
+		buf.Write(line)
+		buf.WriteByte('\n')
+		buf.Write(item.doc)
+		buf.WriteByte('\n')
+	}
+	body := buf.Bytes()
+	size := int64(len(body))
+
+	// 集群繁忙或短暂不可用时退避重试
+	var res *Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		res, err = l.esc.client.Bulk(context.Background(), bytes.NewReader(body))
+		if err == nil && !retryable(res.StatusCode) {
+			break
+		}
+		if attempt >= bulkRetries {
+			break
+		}
+		if err == nil {
+			res.Body.Close()
+		}
+		time.Sleep(time.Second << attempt)
+	}
+	if err != nil {
+		logger.Error("批量写入失败", "err", err)
+		l.requestFailed(batch)
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		logger.Error("批量写入失败", "response", res.String())
+		l.requestFailed(batch)
+		return
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		logger.Error("解析批量写入结果失败", "err", err)
+		l.requestFailed(batch)
+		return
+	}
+
+	// items 与请求中的文档一一对应
+	failed, skipped := 0, 0
+	if result.Errors {
+		for i, item := range result.Items {
+			for _, result := range item {
+				if result.Status < 300 {
+					continue
+				}
+				// create 模式下文档已存在，重复导入时跳过
+				if result.Status == 409 && l.options.OpType == OpTypeCreate {
+					skipped++
+					continue
+				}
+				failed++
+				if i < len(batch) {
+					l.options.Failures.record(batch[i].id, batch[i].doc, result.Status, result.Error)
+				}
+			}
+		}
+	}
+	l.failed.Add(int64(failed))
+	l.skipped.Add(int64(skipped))
+	l.indexed.Add(int64(len(batch) - failed - skipped))
+	l.bytes.Add(size)
+	for _, item := range batch {
+		if item.ack != nil {
+			item.ack()
+		}
+	}
+}
+
+// 整批写入失败，文档不会被确认
+func (l *BulkLoader) requestFailed(batch []bulkItem) {
+	l.failed.Add(int64(len(batch)))
+	l.options.Failures.requestFailed(len(batch))
+}
+
+// bulk 请求失败后的最大重试次数
+const bulkRetries = 3
+
+// 429 和 5xx 可以重试
+func retryable(status int) bool {
+	return status == 429 || status >= 500
+}
@@ -0,0 +1,169 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Checkpoint 导入进度，记录每个输入文件已经写入的文档数，用于中断后继续导入
+type Checkpoint struct {
+	Index     string                   `json:"index"`
+	Files     map[string]*FileProgress `json:"files"`
+	UpdatedAt time.Time                `json:"updated_at"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// FileProgress 单个文件的导入进度。bulk 请求并发完成，Offset 只在之前的文档都已写入时前进
+type FileProgress struct {
+	Offset int64 `json:"offset"` // 前 Offset 个文档已写入
+	Done   bool  `json:"done"`   // 整个文件已写入
+
+	mu    sync.Mutex
+	acked map[int64]struct{} // 已写入但在 Offset 之后的文档序号
+	total int64              // 文件中的文档数，读完文件前为 -1
+}
+
+// 创建检查点，resume 为 true 时加载已有检查点
+func openCheckpoint(path, index string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{Index: index, Files: make(map[string]*FileProgress), path: path}
+	if !resume {
+		return cp, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %v", err)
+	}
+	if err := json.Unmarshal(content, cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %v", path, err)
+	}
+	if cp.Index != index {
+		return nil, fmt.Errorf("checkpoint %s belongs to index %s, not %s", path, cp.Index, index)
+	}
+	return cp, nil
+}
+
+// 读取检查点记录的索引名，用于继续导入带版本的索引
+func checkpointIndex(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read checkpoint: %v", err)
+	}
+	var cp struct {
+		Index string `json:"index"`
+	}
+	if err := json.Unmarshal(content, &cp); err != nil {
+		return "", fmt.Errorf("parse checkpoint %s: %v", path, err)
+	}
+	return cp.Index, nil
+}
+
+// 获取文件的进度，不存在时新建
+func (c *Checkpoint) file(path string) *FileProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	progress, ok := c.Files[path]
+	if !ok {
+		progress = &FileProgress{}
+		c.Files[path] = progress
+	}
+	progress.mu.Lock()
+	progress.acked = make(map[int64]struct{})
+	progress.total = -1
+	progress.mu.Unlock()
+	return progress
+}
+
+// 所有文件都已导入完成
+func (c *Checkpoint) complete() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, progress := range c.Files {
+		if !progress.done() {
+			return false
+		}
+	}
+	return true
+}
+
+// 写入检查点文件，先写临时文件再重命名，避免中断时留下不完整的文件
+func (c *Checkpoint) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make(map[string]FileProgress, len(c.Files))
+	for path, progress := range c.Files {
+		progress.mu.Lock()
+		files[path] = FileProgress{Offset: progress.Offset, Done: progress.Done}
+		progress.mu.Unlock()
+	}
+	content, err := json.MarshalIndent(map[string]interface{}{
+		"index":      c.Index,
+		"files":      files,
+		"updated_at": time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// 导入全部完成后删除检查点
+func (c *Checkpoint) remove() {
+	os.Remove(c.path)
+}
+
+// 记录第 seq 个文档已处理（写入成功或被 ES 拒绝），推进连续写入的位置
+func (p *FileProgress) ack(seq int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if seq != p.Offset {
+		p.acked[seq] = struct{}{}
+		return
+	}
+	p.Offset++
+	for {
+		if _, ok := p.acked[p.Offset]; !ok {
+			break
+		}
+		delete(p.acked, p.Offset)
+		p.Offset++
+	}
+	p.Done = p.total >= 0 && p.Offset >= p.total
+}
+
+// 文件读取完毕，共 total 个文档
+func (p *FileProgress) finish(total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.total = total
+	p.Done = p.Offset >= total
+}
+
+// 整个文件是否已写入
+func (p *FileProgress) done() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Done
+}
+
+// 已写入的文档数
+func (p *FileProgress) offset() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Offset
+}
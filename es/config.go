@@ -0,0 +1,215 @@
+package es
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/TreeWu/mock-go/targets"
+)
+
+// 未指定 -mapping 时使用的默认 mapping
+//
+//go:embed mapping.json
+var defaultMapping []byte
+
+// Config 导入配置，命令行参数的默认值取自环境变量
+type Config struct {
+	Inputs    []string // 输入文件、目录或通配符
+	Generate  string   // 文档模板文件，指定时按模板生成文档而不读取输入文件
+	Count     int64    // 生成的文档数
+	Format    string   // 输入格式：auto、json 或 ndjson
+	Addresses []string // ES 地址
+	Backend   string   // 集群类型：auto、es7、es8 或 opensearch
+	Username  string
+	Password  string
+	APIKey    string
+	CACert    string // CA 证书文件，用于自签名证书的集群
+	Index     string // 目标索引，使用别名时为空表示创建新版本索引
+	Mapping   string // mapping 文件，为空时使用内置 mapping
+	Transform string // 写入前的转换配置文件
+	BatchSize int
+	Workers   int
+	OpType    string  // index 或 create
+	Pipeline  string  // ingest pipeline
+	Routing   string  // 路由表达式，{字段路径} 替换为文档中的值
+	MaxRate   float64 // 每秒最多写入的文档数，0 表示不限速
+
+	Checkpoint string // 检查点文件，默认 <index>.checkpoint.json
+	Failures   string // 被拒绝文档的输出文件，默认 <index>.failures.ndjson
+
+	DryRun bool // 只检查映射，不导入
+	Check  bool // 导入前检查映射，有问题时不导入
+	Sample int  // 每个输入用于检查的文档数
+
+	Alias         string // 读别名，导入到新版本索引 <alias>-<时间戳> 后切换别名
+	KeepVersions  int    // 切换别名后保留的版本数，0 表示不删除旧版本
+	AllowFailures bool   // 有文档被拒绝时仍然切换别名
+	Resume        bool   // 从检查点继续导入
+}
+
+// 环境变量不存在时返回默认值
+func envOr(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return def
+}
+
+// 拆分逗号分隔的列表并去掉空项
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// 解析命令行参数，输入文件可以通过 -input 或位置参数指定
+func parseConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("es load", flag.ContinueOnError)
+	input := fs.String("input", os.Getenv("ES_INPUT"), "comma separated input files, directories or globs (env ES_INPUT), positional args are also accepted")
+	generate := fs.String("generate", os.Getenv("ES_GENERATE"), "yaml/json document template with value directives, generate -count documents instead of reading inputs (env ES_GENERATE)")
+	count := fs.Int64("count", int64(envInt("ES_COUNT", 10000)), "documents to generate with -generate (env ES_COUNT)")
+	format := fs.String("format", envOr("ES_FORMAT", FormatAuto), "input format: auto (by extension), json or ndjson (env ES_FORMAT)")
+	addresses := fs.String("address", envOr("ES_ADDRESS", "http://127.0.0.1:9200"), "comma separated elasticsearch addresses (env ES_ADDRESS)")
+	backend := fs.String("backend", envOr("ES_BACKEND", BackendAuto), "cluster type: auto (detect from the root endpoint), es7, es8 or opensearch (env ES_BACKEND)")
+	username := fs.String("username", os.Getenv("ES_USERNAME"), "basic auth username (env ES_USERNAME)")
+	password := fs.String("password", os.Getenv("ES_PASSWORD"), "basic auth password (env ES_PASSWORD)")
+	apiKey := fs.String("api-key", os.Getenv("ES_API_KEY"), "base64 encoded api key, overrides username/password (env ES_API_KEY)")
+	caCert := fs.String("ca-cert", os.Getenv("ES_CA_CERT"), "CA certificate file for https clusters (env ES_CA_CERT)")
+	target := fs.String("target", os.Getenv("ES_TARGET"), "elasticsearch cluster from the --targets file, explicit connection flags override it (env ES_TARGET)")
+	index := fs.String("index", envOr("ES_INDEX", "resources"), "target index name, with -alias only used when set explicitly (env ES_INDEX)")
+	mapping := fs.String("mapping", os.Getenv("ES_MAPPING"), "index mapping/settings json file, built-in mapping when empty (env ES_MAPPING)")
+	transform := fs.String("transform", os.Getenv("ES_TRANSFORM"), "yaml/json file that renames, drops, fills and sets fields before indexing (env ES_TRANSFORM)")
+	batchSize := fs.Int("batch", envInt("ES_BATCH", 1000), "documents per bulk request (env ES_BATCH)")
+	workers := fs.Int("workers", envInt("ES_WORKERS", 4), "concurrent bulk requests (env ES_WORKERS)")
+	opType := fs.String("op-type", envOr("ES_OP_TYPE", OpTypeIndex), "bulk action: index overwrites existing documents, create skips them (env ES_OP_TYPE)")
+	pipeline := fs.String("pipeline", os.Getenv("ES_PIPELINE"), "ingest pipeline applied to every document (env ES_PIPELINE)")
+	routing := fs.String("routing", os.Getenv("ES_ROUTING"), "routing expression, {field.path} is replaced with the document value, e.g. {tenant} (env ES_ROUTING)")
+	maxRate := fs.Float64("max-rate", envFloat("ES_MAX_RATE", 0), "max documents per second, 0 for unlimited, keeps imports into shared clusters gentle (env ES_MAX_RATE)")
+	checkpoint := fs.String("checkpoint", os.Getenv("ES_CHECKPOINT"), "checkpoint file for resumable imports, default <index>.checkpoint.json (env ES_CHECKPOINT)")
+	failures := fs.String("failures", os.Getenv("ES_FAILURES"), "ndjson file for rejected documents with their errors, default <index>.failures.ndjson (env ES_FAILURES)")
+	dryRun := fs.Bool("dry-run", false, "sample documents, check them against the target mapping and exit without loading")
+	check := fs.Bool("check", false, "run the -dry-run mapping check before loading and abort on conflicts")
+	sample := fs.Int("sample", envInt("ES_SAMPLE", 1000), "documents sampled from each input for the mapping check (env ES_SAMPLE)")
+	alias := fs.String("alias", os.Getenv("ES_ALIAS"), "read alias: load into a new index <alias>-<timestamp> and swap the alias to it when done (env ES_ALIAS)")
+	keepVersions := fs.Int("keep-versions", envInt("ES_KEEP_VERSIONS", 0), "with -alias, versions to keep after the swap including the new one, 0 keeps all (env ES_KEEP_VERSIONS)")
+	allowFailures := fs.Bool("allow-failures", false, "with -alias, swap the alias even if some documents were rejected")
+	resume := fs.Bool("resume", false, "resume an interrupted import from the checkpoint file")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if _, err := targets.ApplyCluster(fs, *target, ""); err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Inputs:    append(splitList(*input), fs.Args()...),
+		Generate:  *generate,
+		Count:     *count,
+		Format:    *format,
+		Addresses: splitList(*addresses),
+		Backend:   *backend,
+		Username:  *username,
+		Password:  *password,
+		APIKey:    *apiKey,
+		CACert:    *caCert,
+		Index:     *index,
+		Mapping:   *mapping,
+		Transform: *transform,
+		BatchSize: *batchSize,
+		Workers:   *workers,
+		OpType:    *opType,
+		Pipeline:  *pipeline,
+		Routing:   *routing,
+		MaxRate:   *maxRate,
+
+		Checkpoint: *checkpoint,
+		Failures:   *failures,
+		Resume:     *resume,
+
+		DryRun: *dryRun,
+		Check:  *check,
+		Sample: *sample,
+
+		Alias:         *alias,
+		KeepVersions:  *keepVersions,
+		AllowFailures: *allowFailures,
+	}
+	if len(config.Inputs) == 0 && config.Generate == "" {
+		return nil, fmt.Errorf("no input file, use -input, pass files as arguments or -generate")
+	}
+	if config.Generate != "" && config.Count <= 0 {
+		return nil, fmt.Errorf("-count must be positive")
+	}
+	if config.Format != FormatAuto && config.Format != FormatJSON && config.Format != FormatNDJSON {
+		return nil, fmt.Errorf("unsupported input format: %s", config.Format)
+	}
+	if len(config.Addresses) == 0 {
+		return nil, fmt.Errorf("no elasticsearch address")
+	}
+	if config.OpType != OpTypeIndex && config.OpType != OpTypeCreate {
+		return nil, fmt.Errorf("unsupported op type: %s", config.OpType)
+	}
+	if _, err := parseRouting(config.Routing); err != nil {
+		return nil, err
+	}
+	switch config.Backend {
+	case BackendAuto, BackendES7, BackendES8, BackendOpenSearch:
+	default:
+		return nil, fmt.Errorf("unsupported backend: %s", config.Backend)
+	}
+
+	// 使用别名时，未指定 -index 则由 main 创建新版本或从检查点取得索引名
+	name := config.Index
+	if config.Alias != "" {
+		name = config.Alias
+		indexSet := false
+		fs.Visit(func(f *flag.Flag) { indexSet = indexSet || f.Name == "index" })
+		if !indexSet {
+			config.Index = ""
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no index name")
+	}
+	if config.Checkpoint == "" {
+		config.Checkpoint = name + ".checkpoint.json"
+	}
+	if config.Failures == "" {
+		config.Failures = name + ".failures.ndjson"
+	}
+	return config, nil
+}
+
+// 读取 mapping 文件
+func (c *Config) mapping() ([]byte, error) {
+	if c.Mapping == "" {
+		return defaultMapping, nil
+	}
+	content, err := os.ReadFile(c.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("read mapping: %v", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,518 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 未设置 index.mapping.total_fields.limit 时 ES 的默认值
+const defaultFieldLimit = 1000
+
+// mappedField 映射中的字段
+type mappedField struct {
+	Type    string
+	Format  string
+	Dynamic bool // 由样本文档动态新增
+}
+
+// dynamicTemplate 动态模板，只支持按字段名、路径和值类型匹配
+type dynamicTemplate struct {
+	Name             string
+	Match            *regexp.Regexp
+	Unmatch          *regexp.Regexp
+	PathMatch        *regexp.Regexp
+	PathUnmatch      *regexp.Regexp
+	MatchMappingType string
+	Type             string
+}
+
+// MappingConflict 样本文档与映射冲突的字段
+type MappingConflict struct {
+	Path    string
+	Mapped  string // 映射中的类型
+	Actual  string // 文档中的值类型
+	Count   int
+	Example string // 第一次出现的位置
+}
+
+// MappingCheck 用样本文档模拟动态映射，找出类型冲突和超过字段数上限的情况
+type MappingCheck struct {
+	fields    map[string]*mappedField
+	dynamic   map[string]string // 对象路径 -> dynamic 设置，根对象为空字符串
+	templates []dynamicTemplate
+	limit     int
+	existing  int // 映射中已有的字段数
+	added     int // 动态新增的字段数
+
+	conflicts map[string]*MappingConflict
+	samples   int
+}
+
+// 解析索引的 mappings 和 settings
+func newMappingCheck(mappings, settings map[string]interface{}) (*MappingCheck, error) {
+	c := &MappingCheck{
+		fields:    make(map[string]*mappedField),
+		dynamic:   map[string]string{"": "true"},
+		limit:     fieldLimit(settings),
+		conflicts: make(map[string]*MappingConflict),
+	}
+	if d, ok := mappings["dynamic"]; ok {
+		c.dynamic[""] = fmt.Sprint(d)
+	}
+	if templates, ok := mappings["dynamic_templates"].([]interface{}); ok {
+		for _, item := range templates {
+			named, _ := item.(map[string]interface{})
+			for name, body := range named {
+				template, err := parseDynamicTemplate(name, body)
+				if err != nil {
+					return nil, err
+				}
+				c.templates = append(c.templates, template)
+			}
+		}
+	}
+	properties, _ := mappings["properties"].(map[string]interface{})
+	c.parseProperties("", properties)
+	return c, nil
+}
+
+func (c *MappingCheck) parseProperties(prefix string, properties map[string]interface{}) {
+	for name, body := range properties {
+		field, _ := body.(map[string]interface{})
+		path := prefix + name
+		fieldType, _ := field["type"].(string)
+		if fieldType == "" {
+			fieldType = "object"
+		}
+		format, _ := field["format"].(string)
+		c.fields[path] = &mappedField{Type: fieldType, Format: format}
+		c.existing++
+
+		// 多字段也计入字段数
+		if multi, ok := field["fields"].(map[string]interface{}); ok {
+			c.existing += len(multi)
+		}
+		if d, ok := field["dynamic"]; ok {
+			c.dynamic[path] = fmt.Sprint(d)
+		}
+		if sub, ok := field["properties"].(map[string]interface{}); ok {
+			c.parseProperties(path+".", sub)
+		}
+	}
+}
+
+func parseDynamicTemplate(name string, body interface{}) (dynamicTemplate, error) {
+	spec, _ := body.(map[string]interface{})
+	template := dynamicTemplate{Name: name}
+	var err error
+	pattern := func(key string) *regexp.Regexp {
+		value, ok := spec[key].(string)
+		if !ok || err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		if spec["match_pattern"] == "regex" {
+			re, err = regexp.Compile(value)
+		} else {
+			re, err = regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, ".*") + "$")
+		}
+		return re
+	}
+	template.Match = pattern("match")
+	template.Unmatch = pattern("unmatch")
+	template.PathMatch = pattern("path_match")
+	template.PathUnmatch = pattern("path_unmatch")
+	if err != nil {
+		return template, fmt.Errorf("dynamic template %s: %v", name, err)
+	}
+	template.MatchMappingType, _ = spec["match_mapping_type"].(string)
+	if mapping, ok := spec["mapping"].(map[string]interface{}); ok {
+		template.Type, _ = mapping["type"].(string)
+	}
+	return template, nil
+}
+
+// 读取 index.mapping.total_fields.limit，兼容嵌套和扁平两种写法
+func fieldLimit(settings map[string]interface{}) int {
+	if index, ok := settings["index"].(map[string]interface{}); ok {
+		settings = index
+	}
+	var limit interface{}
+	if v, ok := settings["mapping.total_fields.limit"]; ok {
+		limit = v
+	} else if v, ok := settings["index.mapping.total_fields.limit"]; ok {
+		limit = v
+	} else if mapping, ok := settings["mapping"].(map[string]interface{}); ok {
+		if totalFields, ok := mapping["total_fields"].(map[string]interface{}); ok {
+			limit = totalFields["limit"]
+		}
+	}
+	if n, err := strconv.Atoi(fmt.Sprint(limit)); err == nil && n > 0 {
+		return n
+	}
+	return defaultFieldLimit
+}
+
+// Add 用一个样本文档模拟写入，location 用于报告冲突位置
+func (c *MappingCheck) Add(doc map[string]interface{}, location string) {
+	c.samples++
+	c.addObject("", doc, location)
+}
+
+func (c *MappingCheck) addObject(prefix string, obj map[string]interface{}, location string) {
+	keys := sortedKeys(obj)
+	for _, key := range keys {
+		c.addValue(prefix+key, obj[key], location)
+	}
+}
+
+func (c *MappingCheck) addValue(path string, v interface{}, location string) {
+	switch value := v.(type) {
+	case nil:
+		return
+	case []interface{}:
+		for _, item := range value {
+			c.addValue(path, item, location)
+		}
+		return
+	}
+
+	field, ok := c.fields[path]
+	if !ok {
+		field = c.addDynamic(path, v, location)
+		if field == nil {
+			return
+		}
+	}
+
+	switch field.Type {
+	case "object", "nested":
+		obj, isObject := v.(map[string]interface{})
+		if !isObject {
+			c.conflict(path, field, valueType(v), location)
+			return
+		}
+		c.addObject(path+".", obj, location)
+	case "flattened":
+		// 整个对象作为一个字段
+	default:
+		if !compatible(field, v) {
+			c.conflict(path, field, valueType(v), location)
+		}
+	}
+}
+
+// 按 dynamic 设置和动态模板新增字段，返回 nil 表示字段被忽略或被拒绝
+func (c *MappingCheck) addDynamic(path string, v interface{}, location string) *mappedField {
+	switch c.dynamicFor(path) {
+	case "false":
+		return nil
+	case "strict":
+		c.conflict(path, &mappedField{Type: "strict"}, valueType(v), location)
+		return nil
+	}
+
+	inferred := inferType(v)
+	field := &mappedField{Type: inferred, Dynamic: true}
+	fieldCount := 1
+	if inferred == "text" {
+		fieldCount = 2 // 默认带 keyword 子字段
+	}
+	if template := c.matchTemplate(path, inferred); template != nil && template.Type != "" && template.Type != "{dynamic_type}" {
+		field.Type = template.Type
+		fieldCount = 1
+	}
+	c.fields[path] = field
+	c.added += fieldCount
+	return field
+}
+
+// 最近的上级对象的 dynamic 设置
+func (c *MappingCheck) dynamicFor(path string) string {
+	for {
+		i := strings.LastIndexByte(path, '.')
+		if i < 0 {
+			return c.dynamic[""]
+		}
+		path = path[:i]
+		if d, ok := c.dynamic[path]; ok {
+			return d
+		}
+	}
+}
+
+func (c *MappingCheck) matchTemplate(path, inferred string) *dynamicTemplate {
+	name := path[strings.LastIndexByte(path, '.')+1:]
+	mappingType := map[string]string{"text": "string", "float": "double"}[inferred]
+	if mappingType == "" {
+		mappingType = inferred
+	}
+	for i := range c.templates {
+		t := &c.templates[i]
+		if t.Match != nil && !t.Match.MatchString(name) || t.Unmatch != nil && t.Unmatch.MatchString(name) {
+			continue
+		}
+		if t.PathMatch != nil && !t.PathMatch.MatchString(path) || t.PathUnmatch != nil && t.PathUnmatch.MatchString(path) {
+			continue
+		}
+		if t.MatchMappingType != "" && t.MatchMappingType != "*" && t.MatchMappingType != mappingType {
+			continue
+		}
+		return t
+	}
+	return nil
+}
+
+func (c *MappingCheck) conflict(path string, field *mappedField, actual, location string) {
+	key := path + "\x00" + field.Type + "\x00" + actual
+	if conflict, ok := c.conflicts[key]; ok {
+		conflict.Count++
+		return
+	}
+	mapped := field.Type
+	if field.Dynamic {
+		mapped += " (dynamic)"
+	}
+	c.conflicts[key] = &MappingConflict{Path: path, Mapped: mapped, Actual: actual, Count: 1, Example: location}
+}
+
+// Conflicts 冲突的字段，按路径排序
+func (c *MappingCheck) Conflicts() []*MappingConflict {
+	conflicts := make([]*MappingConflict, 0, len(c.conflicts))
+	for _, conflict := range c.conflicts {
+		conflicts = append(conflicts, conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Path != conflicts[j].Path {
+			return conflicts[i].Path < conflicts[j].Path
+		}
+		return conflicts[i].Actual < conflicts[j].Actual
+	})
+	return conflicts
+}
+
+// TotalFields 写入样本后的字段总数
+func (c *MappingCheck) TotalFields() int {
+	return c.existing + c.added
+}
+
+// Err 有冲突或字段数超过上限时返回错误
+func (c *MappingCheck) Err() error {
+	var problems []string
+	if n := len(c.conflicts); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d mapping conflicts", n))
+	}
+	if c.TotalFields() > c.limit {
+		problems = append(problems, fmt.Sprintf("%d fields exceed the limit of %d", c.TotalFields(), c.limit))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, ", "))
+}
+
+// Report 检查结果，中文输出
+func (c *MappingCheck) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "样本文档 %d 个，已有字段 %d 个，新增字段 %d 个，合计 %d / 上限 %d\n",
+		c.samples, c.existing, c.added, c.TotalFields(), c.limit)
+	if c.TotalFields() > c.limit {
+		fmt.Fprintf(&b, "字段数超过上限，导入过程中会被拒绝，检查是否有以 ID 或时间作为字段名的对象\n")
+	} else if c.TotalFields()*10 >= c.limit*9 {
+		fmt.Fprintf(&b, "字段数接近上限，全量导入时可能超过\n")
+	}
+	conflicts := c.Conflicts()
+	if len(conflicts) == 0 {
+		fmt.Fprintf(&b, "没有发现字段类型冲突\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "字段类型冲突 %d 个:\n", len(conflicts))
+	for _, conflict := range conflicts {
+		fmt.Fprintf(&b, "  %-40s 映射 %-20s 文档 %-8s %d 次，首次出现在 %s\n",
+			conflict.Path, conflict.Mapped, conflict.Actual, conflict.Count, conflict.Example)
+	}
+	return b.String()
+}
+
+// 值的 JSON 类型
+func valueType(v interface{}) string {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "long"
+		}
+		return "float"
+	case float64, float32:
+		return "float"
+	case int, int64, uint64, int32, uint32:
+		return "long"
+	case string:
+		return "string"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// 按 ES 默认的动态映射规则推断字段类型，字符串只识别 ISO 8601 日期，不识别数字
+func inferType(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		if isDate(value) {
+			return "date"
+		}
+		return "text"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return valueType(v)
+	}
+}
+
+// 是否符合 ES 默认的日期检测格式
+func isDate(s string) bool {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02", "2006/01/02 15:04:05", "2006/01/02"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// 值能否写入已映射的字段，考虑 ES 默认的类型转换
+func compatible(field *mappedField, v interface{}) bool {
+	actual := valueType(v)
+	if actual == "object" {
+		return false
+	}
+	switch field.Type {
+	case "keyword", "text", "wildcard", "match_only_text", "constant_keyword", "search_as_you_type":
+		return true
+	case "long", "integer", "short", "byte", "unsigned_long", "double", "float", "half_float", "scaled_float":
+		switch actual {
+		case "long", "float":
+			return true
+		case "string":
+			_, err := strconv.ParseFloat(strings.TrimSpace(v.(string)), 64)
+			return err == nil
+		}
+		return false
+	case "boolean":
+		switch actual {
+		case "boolean":
+			return true
+		case "string":
+			return v == "true" || v == "false" || v == ""
+		}
+		return false
+	case "date", "date_nanos":
+		switch actual {
+		case "long":
+			return true
+		case "string":
+			// 自定义格式无法在本地校验
+			return field.Format != "" || isDate(v.(string))
+		}
+		return false
+	case "ip":
+		s, ok := v.(string)
+		return ok && net.ParseIP(s) != nil
+	default:
+		return true
+	}
+}
+
+// docSource 一个文档来源，输入文件或生成的文档
+type docSource struct {
+	name string
+	read docReader
+}
+
+var errSampleDone = errors.New("sample done")
+
+// CheckMapping 从每个来源读取前 perSource 个文档，经过转换后与目标索引的映射比较
+func (esc *ESClient) CheckMapping(sources []docSource, perSource int, transform *Transform) (*MappingCheck, string, error) {
+	mappings, settings, target, err := esc.targetMapping()
+	if err != nil {
+		return nil, "", err
+	}
+	check, err := newMappingCheck(mappings, settings)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, source := range sources {
+		seq := 0
+		err := source.read(func(doc map[string]interface{}) error {
+			if seq >= perSource {
+				return errSampleDone
+			}
+			seq++
+			prepareDocument(doc, transform)
+			check.Add(doc, fmt.Sprintf("%s#%d", source.name, seq))
+			return nil
+		})
+		if err != nil && err != errSampleDone {
+			return nil, "", fmt.Errorf("%s: %v", source.name, err)
+		}
+	}
+	return check, target, nil
+}
+
+// 读取目标索引的 mappings 和 settings，索引不存在时使用 mapping 文件
+func (esc *ESClient) targetMapping() (mappings, settings map[string]interface{}, source string, err error) {
+	mappings, err = esc.indexSection(esc.client.GetMapping, "mappings")
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if mappings != nil {
+		settings, err = esc.indexSection(esc.client.GetSettings, "settings")
+		return mappings, settings, "索引 " + esc.index, err
+	}
+
+	var body struct {
+		Mappings map[string]interface{} `json:"mappings"`
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.Unmarshal(esc.mapping, &body); err != nil {
+		return nil, nil, "", fmt.Errorf("parse mapping: %v", err)
+	}
+	return body.Mappings, body.Settings, "索引不存在时使用的 mapping", nil
+}
+
+// 读取 GET <index>/_mapping 或 _settings 响应中的一部分，索引不存在时返回 nil
+func (esc *ESClient) indexSection(get func(ctx context.Context, index string) (*Response, error), key string) (map[string]interface{}, error) {
+	res, err := get(context.Background(), esc.index)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("获取索引 %s 失败 %s", key, res.String())
+	}
+	var result map[string]map[string]map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	// 别名可能对应多个索引，取任意一个
+	for _, index := range result {
+		if section, ok := index[key]; ok {
+			return section, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,78 @@
+package es
+
+import (
+	"context"
+	"io"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+var _ Indexer = (*es7Indexer)(nil)
+
+// es7Indexer Elasticsearch 7.x 客户端
+type es7Indexer struct {
+	client *elasticsearch.Client
+}
+
+func newES7Indexer(config *Config) (*es7Indexer, error) {
+	esConfig := elasticsearch.Config{
+		Addresses: config.Addresses,
+		Username:  config.Username,
+		Password:  config.Password,
+		APIKey:    config.APIKey,
+	}
+	if config.CACert != "" {
+		cert, err := readCACert(config.CACert)
+		if err != nil {
+			return nil, err
+		}
+		esConfig.CACert = cert
+	}
+	client, err := elasticsearch.NewClient(esConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &es7Indexer{client: client}, nil
+}
+
+func (i *es7Indexer) Name() string { return BackendES7 }
+
+func (i *es7Indexer) CreateIndex(ctx context.Context, index string, body io.Reader) (*Response, error) {
+	return es7Response(esapi.IndicesCreateRequest{Index: index, Body: body}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) DeleteIndex(ctx context.Context, index string) (*Response, error) {
+	return es7Response(esapi.IndicesDeleteRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) Bulk(ctx context.Context, body io.Reader) (*Response, error) {
+	return es7Response(esapi.BulkRequest{Body: body}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) GetAlias(ctx context.Context, alias string) (*Response, error) {
+	return es7Response(esapi.IndicesGetAliasRequest{Name: []string{alias}}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) UpdateAliases(ctx context.Context, body io.Reader) (*Response, error) {
+	return es7Response(esapi.IndicesUpdateAliasesRequest{Body: body}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) CatIndices(ctx context.Context, pattern string) (*Response, error) {
+	return es7Response(esapi.CatIndicesRequest{Index: []string{pattern}, Format: "json", H: []string{"index"}}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) GetMapping(ctx context.Context, index string) (*Response, error) {
+	return es7Response(esapi.IndicesGetMappingRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func (i *es7Indexer) GetSettings(ctx context.Context, index string) (*Response, error) {
+	return es7Response(esapi.IndicesGetSettingsRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func es7Response(res *esapi.Response, err error) (*Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: res.StatusCode, Body: res.Body, str: res.String}, nil
+}
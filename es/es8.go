@@ -0,0 +1,78 @@
+package es
+
+import (
+	"context"
+	"io"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+var _ Indexer = (*es8Indexer)(nil)
+
+// es8Indexer Elasticsearch 8.x 客户端
+type es8Indexer struct {
+	client *elasticsearch.Client
+}
+
+func newES8Indexer(config *Config) (*es8Indexer, error) {
+	esConfig := elasticsearch.Config{
+		Addresses: config.Addresses,
+		Username:  config.Username,
+		Password:  config.Password,
+		APIKey:    config.APIKey,
+	}
+	if config.CACert != "" {
+		cert, err := readCACert(config.CACert)
+		if err != nil {
+			return nil, err
+		}
+		esConfig.CACert = cert
+	}
+	client, err := elasticsearch.NewClient(esConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &es8Indexer{client: client}, nil
+}
+
+func (i *es8Indexer) Name() string { return BackendES8 }
+
+func (i *es8Indexer) CreateIndex(ctx context.Context, index string, body io.Reader) (*Response, error) {
+	return es8Response(esapi.IndicesCreateRequest{Index: index, Body: body}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) DeleteIndex(ctx context.Context, index string) (*Response, error) {
+	return es8Response(esapi.IndicesDeleteRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) Bulk(ctx context.Context, body io.Reader) (*Response, error) {
+	return es8Response(esapi.BulkRequest{Body: body}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) GetAlias(ctx context.Context, alias string) (*Response, error) {
+	return es8Response(esapi.IndicesGetAliasRequest{Name: []string{alias}}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) UpdateAliases(ctx context.Context, body io.Reader) (*Response, error) {
+	return es8Response(esapi.IndicesUpdateAliasesRequest{Body: body}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) CatIndices(ctx context.Context, pattern string) (*Response, error) {
+	return es8Response(esapi.CatIndicesRequest{Index: []string{pattern}, Format: "json", H: []string{"index"}}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) GetMapping(ctx context.Context, index string) (*Response, error) {
+	return es8Response(esapi.IndicesGetMappingRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func (i *es8Indexer) GetSettings(ctx context.Context, index string) (*Response, error) {
+	return es8Response(esapi.IndicesGetSettingsRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func es8Response(res *esapi.Response, err error) (*Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: res.StatusCode, Body: res.Body, str: res.String}, nil
+}
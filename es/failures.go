@@ -0,0 +1,152 @@
+package es
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 整批请求失败时使用的错误类型
+const requestError = "request_error"
+
+// FailureReport 收集被 ES 拒绝的文档，写入 NDJSON 文件并按错误类型计数。
+// 每行是原始文档加上 _id 和 _error 字段，修正后可以直接作为输入重新导入，导入时会忽略 _error
+type FailureReport struct {
+	path       string
+	appendMode bool
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	counts map[string]int64
+	err    error
+}
+
+// bulkError ES 返回的单条文档错误
+type bulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// 创建失败报告，文件在第一次失败时创建，appendMode 为 true 时追加到已有文件
+func newFailureReport(path string, appendMode bool) *FailureReport {
+	return &FailureReport{path: path, appendMode: appendMode, counts: make(map[string]int64)}
+}
+
+// 记录一条被拒绝的文档
+func (r *FailureReport) record(id string, doc []byte, status int, raw json.RawMessage) {
+	var cause bulkError
+	if err := json.Unmarshal(raw, &cause); err != nil || cause.Type == "" {
+		cause = bulkError{Type: fmt.Sprintf("status_%d", status), Reason: string(raw)}
+	}
+
+	line := map[string]interface{}{}
+	if decoded, err := decodeDocument(doc); err == nil {
+		line = decoded
+	}
+	if id != "" {
+		line["_id"] = id
+	}
+	line["_error"] = map[string]interface{}{"status": status, "type": cause.Type, "reason": cause.Reason}
+	content, err := json.Marshal(line)
+	if err != nil {
+		content, _ = json.Marshal(map[string]interface{}{"_id": id, "_error": line["_error"]})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[cause.Type]++
+	if r.open() != nil {
+		return
+	}
+	r.writer.Write(content)
+	r.writer.WriteByte('\n')
+}
+
+// 记录整批请求失败的文档数，这些文档没有写入失败文件，中断后 -resume 会重新发送
+func (r *FailureReport) requestFailed(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[requestError] += int64(n)
+}
+
+// 打开失败文件，只打开一次，调用方持有锁
+func (r *FailureReport) open() error {
+	if r.file != nil || r.err != nil {
+		return r.err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if r.appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	r.file, r.err = os.OpenFile(r.path, flags, 0o644)
+	if r.err != nil {
+		r.err = fmt.Errorf("open failures file: %v", r.err)
+		return r.err
+	}
+	r.writer = bufio.NewWriter(r.file)
+	return nil
+}
+
+// Close 写入缓冲并关闭失败文件。重新导入时没有失败则删除上次留下的失败文件
+func (r *FailureReport) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		if r.err == nil && !r.appendMode {
+			os.Remove(r.path)
+		}
+		return r.err
+	}
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// Written 是否写入了失败文件
+func (r *FailureReport) Written() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file != nil
+}
+
+// Summary 按错误类型汇总的失败数，数量多的在前
+func (r *FailureReport) Summary() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]string, 0, len(r.counts))
+	for errType := range r.counts {
+		types = append(types, errType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if r.counts[types[i]] != r.counts[types[j]] {
+			return r.counts[types[i]] > r.counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	var b strings.Builder
+	for _, errType := range types {
+		fmt.Fprintf(&b, "  %-32s %d\n", errType, r.counts[errType])
+	}
+	return b.String()
+}
+
+// 两个路径是否指向同一个文件
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
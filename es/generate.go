@@ -0,0 +1,18 @@
+package es
+
+import (
+	"github.com/TreeWu/mock-go/value"
+)
+
+// 按模板生成 count 个文档，每个文档重新计算占位符
+func generateDocuments(template map[string]interface{}, count int64) docReader {
+	return func(fn func(doc map[string]interface{}) error) error {
+		values := value.NewValueHandler()
+		for i := int64(0); i < count; i++ {
+			if err := fn(values.ProcessDynamicMap(template)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
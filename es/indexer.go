@@ -0,0 +1,137 @@
+package es
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 支持的集群类型
+const (
+	BackendAuto       = "auto"       // 请求集群根路径判断版本
+	BackendES7        = "es7"        // Elasticsearch 7.x
+	BackendES8        = "es8"        // Elasticsearch 8.x
+	BackendOpenSearch = "opensearch" // OpenSearch 1.x/2.x
+)
+
+// Indexer 导入使用的集群接口，屏蔽不同版本客户端的差异
+type Indexer interface {
+	Name() string
+	CreateIndex(ctx context.Context, index string, body io.Reader) (*Response, error)
+	DeleteIndex(ctx context.Context, index string) (*Response, error)
+	Bulk(ctx context.Context, body io.Reader) (*Response, error)
+	GetAlias(ctx context.Context, alias string) (*Response, error)
+	UpdateAliases(ctx context.Context, body io.Reader) (*Response, error)
+	CatIndices(ctx context.Context, pattern string) (*Response, error)
+	GetMapping(ctx context.Context, index string) (*Response, error)
+	GetSettings(ctx context.Context, index string) (*Response, error)
+}
+
+// Response 集群的响应，调用方负责关闭 Body
+type Response struct {
+	StatusCode int
+	Body       io.ReadCloser
+	str        func() string
+}
+
+// IsError 状态码是否表示失败
+func (r *Response) IsError() bool {
+	return r.StatusCode > 299
+}
+
+// String 状态码和响应内容，会读取 Body
+func (r *Response) String() string {
+	return r.str()
+}
+
+// 按配置创建客户端，auto 时先探测集群类型
+func newIndexer(config *Config) (Indexer, error) {
+	backend := config.Backend
+	if backend == "" || backend == BackendAuto {
+		detected, err := detectBackend(config)
+		if err != nil {
+			return nil, fmt.Errorf("detect cluster version: %v, use -backend to set it", err)
+		}
+		backend = detected
+	}
+
+	switch backend {
+	case BackendES7:
+		return newES7Indexer(config)
+	case BackendES8:
+		return newES8Indexer(config)
+	case BackendOpenSearch:
+		return newOpenSearchIndexer(config)
+	default:
+		return nil, fmt.Errorf("unsupported backend: %s", backend)
+	}
+}
+
+// 请求集群根路径，根据 version.distribution 和 version.number 判断集群类型
+func detectBackend(config *Config) (string, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CACert != "" {
+		cert, err := readCACert(config.CACert)
+		if err != nil {
+			return "", err
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(cert)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(config.Addresses[0], "/")+"/", nil)
+	if err != nil {
+		return "", err
+	}
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+config.APIKey)
+	} else if config.Username != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", req.URL, res.Status)
+	}
+
+	var info struct {
+		Version struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return "", err
+	}
+	switch {
+	case info.Version.Distribution == "opensearch":
+		return BackendOpenSearch, nil
+	case strings.HasPrefix(info.Version.Number, "7."):
+		return BackendES7, nil
+	case strings.HasPrefix(info.Version.Number, "8."), strings.HasPrefix(info.Version.Number, "9."):
+		return BackendES8, nil
+	default:
+		return "", fmt.Errorf("unknown version %q", info.Version.Number)
+	}
+}
+
+// 读取 CA 证书文件
+func readCACert(path string) ([]byte, error) {
+	cert, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca cert: %v", err)
+	}
+	return cert, nil
+}
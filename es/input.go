@@ -0,0 +1,271 @@
+package es
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// docReader 依次把文档交给 fn 处理，fn 返回错误时停止
+type docReader func(fn func(doc map[string]interface{}) error) error
+
+// 支持的输入格式
+const (
+	FormatAuto   = "auto"   // 按扩展名判断，.ndjson/.jsonl 为 NDJSON，其他为 JSON
+	FormatJSON   = "json"   // {"resources": [...]} 或顶层数组
+	FormatNDJSON = "ndjson" // 每行一个文档
+)
+
+// 目录中会被导入的文件扩展名
+var inputExtensions = map[string]bool{".json": true, ".ndjson": true, ".jsonl": true}
+
+// 展开输入参数中的目录和通配符，返回排序后的文件列表
+func expandInputs(inputs []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, input := range inputs {
+		matches := []string{input}
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(input); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %v", input, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no file matches %s", input)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+			var dirFiles []string
+			err = filepath.WalkDir(match, func(path string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && inputExtensions[strings.ToLower(filepath.Ext(path))] {
+					dirFiles = append(dirFiles, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(dirFiles)
+			for _, path := range dirFiles {
+				add(path)
+			}
+		}
+	}
+	return files, nil
+}
+
+// 确定文件的输入格式
+func inputFormat(path, format string) string {
+	if format != "" && format != FormatAuto {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return FormatJSON
+}
+
+// 流式读取文件中的文档，不把整个文件读入内存
+func readDocuments(path, format string, fn func(doc map[string]interface{}) error) error {
+	return readDocumentsWith(path, format, nil, fn)
+}
+
+// 同 readDocuments，wrap 不为空时包装文件的 Reader，用于统计读取进度
+func readDocumentsWith(path, format string, wrap func(io.Reader) io.Reader, fn func(doc map[string]interface{}) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var source io.Reader = file
+	if wrap != nil {
+		source = wrap(file)
+	}
+	reader := bufio.NewReaderSize(source, 1024*1024)
+	switch inputFormat(path, format) {
+	case FormatNDJSON:
+		return readNDJSON(reader, fn)
+	case FormatJSON:
+		return readJSON(reader, fn)
+	default:
+		return fmt.Errorf("unsupported input format: %s", format)
+	}
+}
+
+// 每行一个 JSON 文档，忽略空行
+func readNDJSON(reader *bufio.Reader, fn func(doc map[string]interface{}) error) error {
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if content = bytes.TrimSpace(content); len(content) > 0 {
+			doc, decodeErr := decodeDocument(content)
+			if decodeErr != nil {
+				return fmt.Errorf("line %d: %v", line, decodeErr)
+			}
+			if err := fn(doc); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// 逐个解码数组元素，支持 {"resources": [...]} 和顶层数组
+func readJSON(reader io.Reader, fn func(doc map[string]interface{}) error) error {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('['):
+		return readArray(decoder, fn)
+	case json.Delim('{'):
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if key != "resources" {
+				var skip json.RawMessage
+				if err := decoder.Decode(&skip); err != nil {
+					return err
+				}
+				continue
+			}
+			if token, err := decoder.Token(); err != nil {
+				return err
+			} else if token != json.Delim('[') {
+				return fmt.Errorf("resources is not an array")
+			}
+			return readArray(decoder, fn)
+		}
+		return fmt.Errorf("missing resources array")
+	default:
+		return fmt.Errorf("unexpected json token %v", token)
+	}
+}
+
+func readArray(decoder *json.Decoder, fn func(doc map[string]interface{}) error) error {
+	for decoder.More() {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解码单个文档，保留数字原样避免大整数丢失精度
+func decodeDocument(content []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// 读取一个输入文件，经过转换后写入导入器，_id 字段作为文档 ID。跳过检查点中已写入的文档，ctx 取消时停止读取
+func loadFile(ctx context.Context, loader *BulkLoader, path, format string, transform *Transform, progress *FileProgress, meter *loadProgress) error {
+	return loadDocuments(ctx, loader, func(fn func(doc map[string]interface{}) error) error {
+		return readDocumentsWith(path, format, meter.reader, fn)
+	}, transform, progress)
+}
+
+// 把 read 产生的文档写入导入器，记录每个文档的序号用于检查点
+func loadDocuments(ctx context.Context, loader *BulkLoader, read docReader, transform *Transform, progress *FileProgress) error {
+	skip := progress.offset()
+	var seq int64
+	err := read(func(doc map[string]interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		current := seq
+		seq++
+		if current < skip {
+			return nil
+		}
+
+		id := prepareDocument(doc, transform)
+		content, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("marshal %s: %v", id, err)
+		}
+		routing, err := loader.routing(doc)
+		if err != nil {
+			loader.Reject(id, content, "routing_error", err)
+			progress.ack(current)
+			return nil
+		}
+		if err := loader.throttle.wait(ctx); err != nil {
+			return err
+		}
+		loader.Add(id, routing, content, func() { progress.ack(current) })
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	progress.finish(seq)
+	return nil
+}
+
+// 取出文档 ID 并执行转换，返回文档 ID
+func prepareDocument(doc map[string]interface{}, transform *Transform) string {
+	id := documentID(doc["_id"])
+	delete(doc, "_id")
+	delete(doc, "_error") // 失败文件中的错误信息
+	transform.apply(doc)
+	return id
+}
+
+// 文档 ID 可以是字符串或数字，数字通常来自 @randInt 等占位符
+func documentID(v interface{}) string {
+	switch id := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	default:
+		return fmt.Sprint(id)
+	}
+}
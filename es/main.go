@@ -1,165 +1,317 @@
-package main
+package es
 
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"github.com/elastic/go-elasticsearch/v8/esapi"
-	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/value"
 )
 
-func getMappings(index string) string {
-
-	return `
-{
-    "mappings": {
-        "dynamic_templates": [
-            {
-                "attributes_specific_fields": {
-                    "path_match": "attributes.*",
-                    "mapping": {
-                        "type": "flattened"
-                    }
-                }
-            }  
-        ],
-        "properties": {
-            "resource_id": {
-                "type": "keyword"
-            },
-            "attributes": {
-                "properties": {
-                    "location": {
-                        "type": "keyword"
-                    }
-                }
-            }
-        }
-    },
-    "settings": {
-        "index": {
-            "number_of_shards": 1,
-            "number_of_replicas": 0,
-            "mapping": {
-                "total_fields": {
-                    "limit": 20000
-                }
-            }
-        }
-    }
-}
-`
-
-}
+var logger = logging.For("es")
 
 // ESClient Elasticsearch客户端封装
 type ESClient struct {
-	index  string
-	client *elasticsearch.Client
+	index   string
+	mapping []byte
+	client  Indexer
 }
 
-// NewESClient 创建ES客户端
-func NewESClient(url, index string) (*ESClient, error) {
-	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{url}})
+// NewESClient 创建ES客户端，按 -backend 选择 Elasticsearch 7、8 或 OpenSearch
+func NewESClient(config *Config) (*ESClient, error) {
+	mapping, err := config.mapping()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newIndexer(config)
 	if err != nil {
 		return nil, err
 	}
 	return &ESClient{
-		index:  index,
-		client: client,
+		index:   config.Index,
+		mapping: mapping,
+		client:  client,
 	}, nil
 }
 
 // CreateIndex 创建索引
 func (esc *ESClient) CreateIndex() error {
-	mappings := getMappings(esc.index)
-	req := esapi.IndicesCreateRequest{
-		Index: esc.index,
-		Body:  bytes.NewReader([]byte(mappings)),
-	}
-
-	res, err := req.Do(context.Background(), esc.client)
-	if err != nil {
-		return err
-	}
+	res, err := esc.client.CreateIndex(context.Background(), esc.index, bytes.NewReader(esc.mapping))
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 	if res.IsError() {
 		if res.StatusCode == 400 {
-			log.Printf("索引 %s 已经存在\n", esc.index)
+			logger.Info("索引已经存在", "index", esc.index)
 			return nil
 		}
 		return fmt.Errorf("创建索引失败 %s", res.String())
 	}
 
-	fmt.Printf("索引 %s 创建成功\n", esc.index)
+	logger.Info("索引创建成功", "index", esc.index)
 	return nil
 }
 
-// InsertProjectRoot 插入数据
-func (esc *ESClient) InsertProjectRoot(data map[string]interface{}) error {
-	ctx := context.Background()
+// Run 执行导入，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	config, err := parseConfig(args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			logger.Error("参数错误", "err", err)
+			return 2
+		}
+		return 0
+	}
+
+	inputs, err := expandInputs(config.Inputs)
+	if err != nil {
+		logger.Error("读取输入失败", "err", err)
+		return 2
+	}
+
+	// 失败文件会在导入过程中被覆盖，不能同时作为输入
+	for _, input := range inputs {
+		if sameFile(input, config.Failures) {
+			logger.Error("input is also the failures file, rename it or use -failures", "input", input)
+			return 2
+		}
+	}
+
+	var template map[string]interface{}
+	if config.Generate != "" {
+		if template, err = value.LoadTemplate(config.Generate); err != nil {
+			logger.Error("加载模板失败", "template", config.Generate, "err", err)
+			return 2
+		}
+	}
 
-	id := data["_id"].(string)
-	delete(data, "_id")
-	marshal, _ := json.Marshal(data)
+	var transform *Transform
+	if config.Transform != "" {
+		if transform, err = loadTransform(config.Transform); err != nil {
+			logger.Error("加载转换规则失败", "transform", config.Transform, "err", err)
+			return 2
+		}
+	}
 
-	req := esapi.IndexRequest{
-		Index:      esc.index,
-		DocumentID: id,
-		Body:       bytes.NewReader(marshal),
+	if config.Index == "" {
+		if config.Resume {
+			config.Index, err = checkpointIndex(config.Checkpoint)
+		} else {
+			config.Index = versionedIndex(config.Alias, time.Now())
+		}
+		if err != nil {
+			logger.Error("确定新版本索引失败", "alias", config.Alias, "err", err)
+			return 1
+		}
+		logger.Info("导入到别名的新版本索引", "alias", config.Alias, "index", config.Index)
 	}
-	res, err := req.Do(ctx, esc.client)
 
+	client, err := NewESClient(config)
 	if err != nil {
-		return err
+		logger.Error("创建客户端失败", "err", err)
+		return 1
 	}
-	defer res.Body.Close()
-	if res.IsError() {
-		log.Printf("插入数据失败 %s: %s", id, res.String())
-		return fmt.Errorf("插入数据失败")
+	logger.Info("集群类型", "backend", client.client.Name())
+	if config.DryRun || config.Check {
+		if !checkMapping(client, config, inputs, template, transform) {
+			return 1
+		}
+		if config.DryRun {
+			return 0
+		}
 	}
 
-	return nil
+	err = client.CreateIndex()
+	if err != nil {
+		logger.Error("创建索引失败", "index", config.Index, "err", err)
+		return 1
+	}
+
+	checkpoint, err := openCheckpoint(config.Checkpoint, config.Index, config.Resume)
+	if err != nil {
+		logger.Error("打开检查点失败", "checkpoint", config.Checkpoint, "err", err)
+		return 1
+	}
+
+	// 中断时停止读取，等待已读取的文档写入后保存进度，之后可使用 -resume 继续
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 继续导入时追加到之前的失败文件
+	failures := newFailureReport(config.Failures, config.Resume)
+	routing, _ := parseRouting(config.Routing)
+	loader := client.NewBulkLoader(BulkOptions{
+		BatchSize: config.BatchSize,
+		Workers:   config.Workers,
+		OpType:    config.OpType,
+		Pipeline:  config.Pipeline,
+		Routing:   routing,
+		Failures:  failures,
+		MaxRate:   config.MaxRate,
+	})
+	meter := newLoadProgress(loader, pendingBytes(inputs, checkpoint), generateTotal(config, template, checkpoint))
+
+	// 定期保存检查点
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := checkpoint.save(); err != nil {
+					logger.Warn("保存检查点失败", "err", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for _, input := range inputs {
+		progress := checkpoint.file(input)
+		if progress.done() {
+			logger.Info("跳过已导入的文件", "input", input)
+			continue
+		}
+		if skip := progress.offset(); skip > 0 {
+			logger.Info("继续导入", "input", input, "from", skip+1)
+		}
+		if err := loadFile(ctx, loader, input, config.Format, transform, progress, meter); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Error("导入文件失败", "input", input, "err", err)
+		}
+	}
+
+	// 生成的文档在检查点中按模板记录进度，继续导入时只生成剩余的数量
+	if template != nil && ctx.Err() == nil {
+		progress := checkpoint.file(generateKey(config))
+		if !progress.done() {
+			logger.Info("按模板生成文档", "template", config.Generate, "count", config.Count-progress.offset())
+			if err := loadDocuments(ctx, loader, meter.countDocs(generateDocuments(template, config.Count)), transform, progress); err != nil && ctx.Err() == nil {
+				logger.Error("生成文档失败", "err", err)
+			}
+		}
+	}
+
+	stats := loader.Close()
+	close(done)
+	meter.finish()
+	fmt.Println("导入完成:", stats)
+	if err := failures.Close(); err != nil {
+		logger.Error("写入失败文件出错", "failures", config.Failures, "err", err)
+	}
+	if stats.Failed > 0 {
+		fmt.Printf("失败文档按错误类型统计:\n%s", failures.Summary())
+		if failures.Written() {
+			fmt.Printf("被拒绝的文档已写入 %s，修正后可作为输入重新导入\n", config.Failures)
+		}
+	}
+
+	if checkpoint.complete() {
+		checkpoint.remove()
+		if config.Alias != "" {
+			return swapAlias(client, config, stats)
+		}
+		return 0
+	}
+	if err := checkpoint.save(); err != nil {
+		logger.Error("保存检查点失败", "err", err)
+		return 1
+	}
+	logger.Warn("导入未完成，进度已保存，使用 -resume 继续", "checkpoint", config.Checkpoint)
+	return 1
 }
 
-// 示例使用
-func main() {
-	var data map[string]interface{}
-	file, err := os.ReadFile("D:\\code\\mock-go\\es\\33_158.json")
+// 导入完成后把别名切换到新索引并清理旧版本，返回退出码
+func swapAlias(client *ESClient, config *Config, stats BulkStats) int {
+	if stats.Failed > 0 && !config.AllowFailures {
+		logger.Error(fmt.Sprintf("有文档导入失败，别名未切换，确认后可使用 -index %s -allow-failures 重新导入并切换", config.Index),
+			"failed", stats.Failed, "alias", config.Alias, "index", config.Index)
+		return 1
+	}
+	previous, err := client.SwapAlias(config.Alias)
 	if err != nil {
-		return
+		logger.Error("切换别名失败", "alias", config.Alias, "err", err)
+		return 1
+	}
+	logger.Info("别名已切换", "alias", config.Alias, "index", config.Index, "previous", previous)
+
+	if config.KeepVersions <= 0 {
+		return 0
+	}
+	deleted, err := client.PruneVersions(config.Alias, config.KeepVersions)
+	if len(deleted) > 0 {
+		logger.Info("已删除旧版本索引", "indices", deleted)
 	}
-	err = json.Unmarshal(file, &data)
 	if err != nil {
-		return
+		logger.Error("清理旧版本索引失败", "alias", config.Alias, "err", err)
+		return 1
 	}
+	return 0
+}
 
-	resourcesI := data["resources"]
+// 用样本文档检查映射，发现冲突或字段数超过上限时返回 false
+func checkMapping(client *ESClient, config *Config, inputs []string, template map[string]interface{}, transform *Transform) bool {
+	var sources []docSource
+	for _, input := range inputs {
+		sources = append(sources, docSource{name: input, read: func(fn func(doc map[string]interface{}) error) error {
+			return readDocuments(input, config.Format, fn)
+		}})
+	}
+	if template != nil {
+		sources = append(sources, docSource{name: "generate", read: generateDocuments(template, min(config.Count, int64(config.Sample)))})
+	}
 
-	client, err := NewESClient("http://127.0.0.1:9200", "resources")
+	check, target, err := client.CheckMapping(sources, config.Sample, transform)
 	if err != nil {
-		return
+		logger.Error("映射检查失败", "err", err)
+		return false
 	}
-	err = client.CreateIndex()
-	if err != nil {
-		fmt.Println("CreateIndex", err)
-		return
+	fmt.Printf("映射检查，映射来自%s:\n%s", target, check.Report())
+	if err := check.Err(); err != nil {
+		logger.Error("mapping check failed", "err", err)
+		return false
 	}
-	for i := range resourcesI.([]interface{}) {
-		resource := resourcesI.([]interface{})[i].(map[string]interface{})
-		id := resource["_id"].(string)
-		err = client.InsertProjectRoot(resource)
-		if err != nil {
-			fmt.Println("InsertProjectRoot", id, err)
-			return
+	return true
+}
+
+// 未导入完成的输入文件的总大小
+func pendingBytes(inputs []string, checkpoint *Checkpoint) int64 {
+	var total int64
+	for _, input := range inputs {
+		if progress, ok := checkpoint.Files[input]; ok && progress.done() {
+			continue
+		}
+		if info, err := os.Stat(input); err == nil {
+			total += info.Size()
 		}
 	}
+	return total
+}
+
+// 需要生成的文档数，已经生成完成时为 0
+func generateTotal(config *Config, template map[string]interface{}, checkpoint *Checkpoint) int64 {
+	if template == nil {
+		return 0
+	}
+	if progress, ok := checkpoint.Files[generateKey(config)]; ok && progress.done() {
+		return 0
+	}
+	return config.Count
+}
 
+// 生成的文档在检查点中的名称
+func generateKey(config *Config) string {
+	return "generate:" + config.Generate
 }
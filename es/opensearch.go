@@ -0,0 +1,81 @@
+package es
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/opensearch-project/opensearch-go/v2"
+	"github.com/opensearch-project/opensearch-go/v2/opensearchapi"
+)
+
+var _ Indexer = (*openSearchIndexer)(nil)
+
+// openSearchIndexer OpenSearch 客户端
+type openSearchIndexer struct {
+	client *opensearch.Client
+}
+
+func newOpenSearchIndexer(config *Config) (*openSearchIndexer, error) {
+	if config.APIKey != "" {
+		return nil, fmt.Errorf("opensearch does not support api keys, use -username/-password")
+	}
+	osConfig := opensearch.Config{
+		Addresses: config.Addresses,
+		Username:  config.Username,
+		Password:  config.Password,
+	}
+	if config.CACert != "" {
+		cert, err := readCACert(config.CACert)
+		if err != nil {
+			return nil, err
+		}
+		osConfig.CACert = cert
+	}
+	client, err := opensearch.NewClient(osConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &openSearchIndexer{client: client}, nil
+}
+
+func (i *openSearchIndexer) Name() string { return BackendOpenSearch }
+
+func (i *openSearchIndexer) CreateIndex(ctx context.Context, index string, body io.Reader) (*Response, error) {
+	return openSearchResponse(opensearchapi.IndicesCreateRequest{Index: index, Body: body}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) DeleteIndex(ctx context.Context, index string) (*Response, error) {
+	return openSearchResponse(opensearchapi.IndicesDeleteRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) Bulk(ctx context.Context, body io.Reader) (*Response, error) {
+	return openSearchResponse(opensearchapi.BulkRequest{Body: body}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) GetAlias(ctx context.Context, alias string) (*Response, error) {
+	return openSearchResponse(opensearchapi.IndicesGetAliasRequest{Name: []string{alias}}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) UpdateAliases(ctx context.Context, body io.Reader) (*Response, error) {
+	return openSearchResponse(opensearchapi.IndicesUpdateAliasesRequest{Body: body}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) CatIndices(ctx context.Context, pattern string) (*Response, error) {
+	return openSearchResponse(opensearchapi.CatIndicesRequest{Index: []string{pattern}, Format: "json", H: []string{"index"}}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) GetMapping(ctx context.Context, index string) (*Response, error) {
+	return openSearchResponse(opensearchapi.IndicesGetMappingRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func (i *openSearchIndexer) GetSettings(ctx context.Context, index string) (*Response, error) {
+	return openSearchResponse(opensearchapi.IndicesGetSettingsRequest{Index: []string{index}}.Do(ctx, i.client))
+}
+
+func openSearchResponse(res *opensearchapi.Response, err error) (*Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: res.StatusCode, Body: res.Body, str: res.String}, nil
+}
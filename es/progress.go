@@ -0,0 +1,190 @@
+package es
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadProgress 导入进度，按已读取的输入字节数和已生成的文档数估算剩余时间，定期在 stderr 输出一行
+type loadProgress struct {
+	loader     *BulkLoader
+	totalBytes int64 // 待读取的输入文件大小
+	totalDocs  int64 // 待生成的文档数
+	readBytes  atomic.Int64
+	generated  atomic.Int64
+	start      time.Time
+	tty        bool
+	stop       chan struct{}
+	stopped    sync.WaitGroup
+}
+
+// 终端上每 500ms 原地刷新，输出到文件或管道时每 10s 输出一条日志
+func newLoadProgress(loader *BulkLoader, totalBytes, totalDocs int64) *loadProgress {
+	p := &loadProgress{
+		loader:     loader,
+		totalBytes: totalBytes,
+		totalDocs:  totalDocs,
+		start:      time.Now(),
+		stop:       make(chan struct{}),
+	}
+	if info, err := os.Stderr.Stat(); err == nil {
+		p.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	interval := 10 * time.Second
+	if p.tty {
+		interval = 500 * time.Millisecond
+	}
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// 统计读取的字节数
+func (p *loadProgress) reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, n: &p.readBytes}
+}
+
+// 统计生成的文档数
+func (p *loadProgress) countDocs(read docReader) docReader {
+	return func(fn func(doc map[string]interface{}) error) error {
+		return read(func(doc map[string]interface{}) error {
+			p.generated.Add(1)
+			return fn(doc)
+		})
+	}
+}
+
+// 终端上原地刷新一行，否则作为日志输出，便于采集
+func (p *loadProgress) print() {
+	stats := p.loader.Stats()
+	elapsed := time.Since(p.start)
+	percent, eta, ok := p.estimate(stats, elapsed)
+	if !p.tty {
+		attrs := []any{"written", stats.Indexed + stats.Skipped, "failed", stats.Failed,
+			"docs_per_sec", math.Round(stats.DocsPerSecond()), "mb_per_sec", math.Round(stats.MBPerSecond()*100) / 100,
+			"elapsed", elapsed.Round(time.Second).String()}
+		if ok {
+			attrs = append(attrs, "percent", math.Round(percent*10)/10, "eta", eta.Round(time.Second).String())
+		}
+		logger.Info("导入进度", attrs...)
+		return
+	}
+
+	line := fmt.Sprintf("写入 %d 条 失败 %d 条 %.0f docs/s %.2f MB/s 已用 %s",
+		stats.Indexed+stats.Skipped, stats.Failed, stats.DocsPerSecond(), stats.MBPerSecond(), elapsed.Round(time.Second))
+	if ok {
+		line = fmt.Sprintf("[%.1f%%] %s 剩余 %s", percent, line, eta.Round(time.Second))
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+}
+
+// 文件部分按读取速度估算，生成部分按写入速度估算
+func (p *loadProgress) estimate(stats BulkStats, elapsed time.Duration) (float64, time.Duration, bool) {
+	if p.totalBytes+p.totalDocs == 0 || elapsed <= 0 {
+		return 0, 0, false
+	}
+	var eta float64
+	var done, total float64
+	if p.totalBytes > 0 {
+		read := min(p.readBytes.Load(), p.totalBytes)
+		if read == 0 {
+			return 0, 0, false
+		}
+		eta += float64(p.totalBytes-read) / (float64(read) / elapsed.Seconds())
+		done += float64(read) / float64(p.totalBytes)
+		total++
+	}
+	if p.totalDocs > 0 {
+		generated := min(p.generated.Load(), p.totalDocs)
+		if rate := stats.DocsPerSecond(); rate > 0 {
+			eta += float64(p.totalDocs-generated) / rate
+		}
+		done += float64(generated) / float64(p.totalDocs)
+		total++
+	}
+	return done * 100 / total, time.Duration(eta * float64(time.Second)), true
+}
+
+// 停止定期输出并打印最终进度
+func (p *loadProgress) finish() {
+	close(p.stop)
+	p.stopped.Wait()
+	p.print()
+	if p.tty {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// throttle 限制每秒写入的文档数，rate 为 0 时不限速
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newThrottle(rate float64) *throttle {
+	if rate <= 0 {
+		return nil
+	}
+	return &throttle{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// 等待下一个放行时刻，ctx 取消时返回错误
+func (t *throttle) wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.next
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next = slot.Add(t.interval)
+	t.mu.Unlock()
+
+	// 间隔很小时攒到 1ms 以上再等待，减少定时器开销
+	delay := time.Until(slot)
+	if delay < time.Millisecond {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,64 @@
+package es
+
+import (
+	"fmt"
+	"strings"
+)
+
+// routingExpr 路由表达式，{字段路径} 替换为文档中的值，其他部分原样保留，例如 {tenant} 或 {org.id}-{region}
+type routingExpr struct {
+	literals []string // 比 fields 多一个，依次与字段值拼接
+	fields   []string
+}
+
+// 解析路由表达式，空表达式返回 nil
+func parseRouting(expr string) (*routingExpr, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	r := &routingExpr{}
+	rest := expr
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			r.literals = append(r.literals, rest)
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("routing %q: unclosed {", expr)
+		}
+		field := strings.TrimSpace(rest[start+1 : start+end])
+		if field == "" {
+			return nil, fmt.Errorf("routing %q: empty field", expr)
+		}
+		r.literals = append(r.literals, rest[:start])
+		r.fields = append(r.fields, field)
+		rest = rest[start+end+1:]
+	}
+	return r, nil
+}
+
+// 计算文档的路由值，字段不存在、为 null 或不是标量时返回错误
+func (r *routingExpr) value(doc map[string]interface{}) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	for i, field := range r.fields {
+		b.WriteString(r.literals[i])
+		v, ok := getPath(doc, field)
+		switch v.(type) {
+		case nil:
+			if ok {
+				return "", fmt.Errorf("routing field %s is null", field)
+			}
+			return "", fmt.Errorf("routing field %s is missing", field)
+		case map[string]interface{}, []interface{}:
+			return "", fmt.Errorf("routing field %s is not a scalar", field)
+		}
+		fmt.Fprint(&b, v)
+	}
+	b.WriteString(r.literals[len(r.literals)-1])
+	return b.String(), nil
+}
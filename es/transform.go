@@ -0,0 +1,119 @@
+package es
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/goccy/go-yaml"
+)
+
+// Transform 写入前对文档的改写，按 rename、drop、fill、set 的顺序执行。
+// 字段使用 . 分隔的路径表示嵌套字段，fill 和 set 的值支持 value 包的占位符，例如 @uuid、@randInt:6
+type Transform struct {
+	Rename map[string]string      `yaml:"rename"` // 旧路径 -> 新路径
+	Drop   []string               `yaml:"drop"`   // 删除的字段
+	Fill   map[string]interface{} `yaml:"fill"`   // 字段不存在或为 null 时填充
+	Set    map[string]interface{} `yaml:"set"`    // 总是覆盖
+
+	values *value.Handler
+}
+
+// 读取转换配置文件，YAML 或 JSON
+func loadTransform(path string) (*Transform, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read transform: %v", err)
+	}
+	var transform Transform
+	if err := yaml.Unmarshal(content, &transform); err != nil {
+		return nil, fmt.Errorf("parse transform %s: %v", path, err)
+	}
+	for from, to := range transform.Rename {
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("transform %s: empty rename path", path)
+		}
+	}
+	transform.values = value.NewValueHandler()
+	return &transform, nil
+}
+
+// 改写文档，nil 表示不做转换
+func (t *Transform) apply(doc map[string]interface{}) {
+	if t == nil {
+		return
+	}
+	// 按路径排序，保证多次导入结果一致
+	for _, from := range sortedKeys(t.Rename) {
+		if v, ok := removePath(doc, from); ok {
+			setPath(doc, t.Rename[from], v)
+		}
+	}
+	for _, path := range t.Drop {
+		removePath(doc, path)
+	}
+	for _, path := range sortedKeys(t.Fill) {
+		if v, ok := getPath(doc, path); !ok || v == nil {
+			setPath(doc, path, t.values.ProcessDynamicValues(t.Fill[path]))
+		}
+	}
+	for _, path := range sortedKeys(t.Set) {
+		setPath(doc, path, t.values.ProcessDynamicValues(t.Set[path]))
+	}
+}
+
+func getPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	current := doc
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	v, ok := current[keys[len(keys)-1]]
+	return v, ok
+}
+
+// 设置字段值，中间路径不存在或不是对象时创建对象
+func setPath(doc map[string]interface{}, path string, v interface{}) {
+	keys := strings.Split(path, ".")
+	current := doc
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = v
+}
+
+func removePath(doc map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	current := doc
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	last := keys[len(keys)-1]
+	v, ok := current[last]
+	delete(current, last)
+	return v, ok
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
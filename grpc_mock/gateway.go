@@ -0,0 +1,241 @@
+package grpc_mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 按 google.api.http 注解注册 REST 路由，请求转换为 RPC 的请求消息后与 gRPC 使用相同的响应，
+// 与 grpc-gateway 一样：路径参数和 query 设置到对应字段，body 为 * 时请求体是整个请求消息
+func (m *mock) gateway(services []protoreflect.ServiceDescriptor) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gatewayLog(), gin.Recovery())
+	for _, sd := range services {
+		methods := sd.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			md := methods.Get(i)
+			for _, b := range httpBindings(md) {
+				if md.IsStreamingClient() || md.IsStreamingServer() {
+					logger.Warn("流式方法不注册 REST 路由", "method", fullMethod(md))
+					break
+				}
+				route, fields, err := ginRoute(b.path)
+				if err != nil {
+					logger.Warn("无法转换 REST 路由，已跳过", "method", fullMethod(md), "err", err)
+					continue
+				}
+				if err := handle(router, b.method, route, m.restHandler(md, b, fields)); err != nil {
+					logger.Warn("注册 REST 路由失败，已跳过", "method", fullMethod(md), "path", route, "err", err)
+					continue
+				}
+				logger.Info("注册 REST 路由", "method", b.method, "path", route, "rpc", fullMethod(md))
+			}
+		}
+	}
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	return router
+}
+
+// 路由冲突时 gin 会 panic，这里转换为错误返回
+func handle(router *gin.Engine, method, path string, handler gin.HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	router.Handle(method, path, handler)
+	return nil
+}
+
+func (m *mock) restHandler(md protoreflect.MethodDescriptor, b binding, fields []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := dynamicpb.NewMessage(md.Input())
+		if b.body != "" {
+			data, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				writeError(c, status.Error(codes.InvalidArgument, err.Error()))
+				return
+			}
+			if len(bytes.TrimSpace(data)) > 0 {
+				if b.body != "*" {
+					// 请求体只对应一个字段时包装为整个请求消息再解析
+					fd := md.Input().Fields().ByName(protoreflect.Name(b.body))
+					if fd == nil {
+						writeError(c, status.Errorf(codes.Internal, "body field %s not found in %s", b.body, md.Input().FullName()))
+						return
+					}
+					data = []byte(fmt.Sprintf(`{%q:%s}`, fd.JSONName(), data))
+				}
+				if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, req); err != nil {
+					writeError(c, status.Error(codes.InvalidArgument, err.Error()))
+					return
+				}
+			}
+		}
+		for _, field := range fields {
+			if err := setField(req, field, strings.TrimPrefix(c.Param(field), "/")); err != nil {
+				writeError(c, status.Error(codes.InvalidArgument, err.Error()))
+				return
+			}
+		}
+		if b.body != "*" {
+			for key, values := range c.Request.URL.Query() {
+				for _, v := range values {
+					if err := setField(req, key, v); err != nil {
+						writeError(c, status.Error(codes.InvalidArgument, err.Error()))
+						return
+					}
+				}
+			}
+		}
+
+		res, err := m.respond(md, req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		data, err := protojson.Marshal(res)
+		if err != nil {
+			writeError(c, status.Error(codes.Internal, err.Error()))
+			return
+		}
+		c.Data(http.StatusOK, "application/json", data)
+	}
+}
+
+// 按点分路径设置字段，字段名可以是 proto 字段名或 JSON 字段名，重复字段追加
+func setField(msg protoreflect.Message, path, raw string) error {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		fields := msg.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(part))
+		if fd == nil {
+			fd = fields.ByJSONName(part)
+		}
+		if fd == nil {
+			return fmt.Errorf("field %s not found in %s", path, msg.Descriptor().FullName())
+		}
+		if i < len(parts)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return fmt.Errorf("field %s: %s is not a message", path, part)
+			}
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+		v, err := parseScalar(fd, raw)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", path, err)
+		}
+		if fd.IsList() {
+			msg.Mutable(fd).List().Append(v)
+		} else {
+			msg.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, raw string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(raw), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(raw)), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(raw)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(raw, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(raw, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(raw, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(raw, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(raw, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(raw)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(raw, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported type %s", fd.Kind())
+	}
+}
+
+// 与 grpc-gateway 相同的错误响应格式和状态码对应关系
+func writeError(c *gin.Context, err error) {
+	s := status.Convert(err)
+	data, _ := json.Marshal(gin.H{"code": s.Code(), "message": s.Message(), "details": []interface{}{}})
+	c.Data(httpStatus(s.Code()), "application/json", data)
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// 记录每个 REST 请求的方法、路径、状态码和耗时
+func gatewayLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.InfoContext(c.Request.Context(), "请求",
+			"protocol", "http",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start).String(),
+			"client", c.ClientIP())
+	}
+}
@@ -0,0 +1,111 @@
+package grpc_mock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 按服务描述动态注册 gRPC 服务。流式方法对每个收到的请求（客户端流为最后一个请求）返回一个响应
+func (m *mock) register(server *grpc.Server, sd protoreflect.ServiceDescriptor) {
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(sd.FullName()),
+		HandlerType: (*interface{})(nil),
+		Metadata:    sd.ParentFile().Path(),
+	}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if !md.IsStreamingClient() && !md.IsStreamingServer() {
+			desc.Methods = append(desc.Methods, grpc.MethodDesc{
+				MethodName: string(md.Name()),
+				Handler:    m.unaryHandler(md),
+			})
+			continue
+		}
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    string(md.Name()),
+			Handler:       m.streamHandler(md),
+			ServerStreams: md.IsStreamingServer(),
+			ClientStreams: md.IsStreamingClient(),
+		})
+	}
+	server.RegisterService(desc, struct{}{})
+	logger.Info("注册 gRPC 服务", "service", desc.ServiceName, "methods", methods.Len())
+}
+
+func (m *mock) unaryHandler(md protoreflect.MethodDescriptor) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := dynamicpb.NewMessage(md.Input())
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return m.respond(md, req.(proto.Message))
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + fullMethod(md)}, handler)
+	}
+}
+
+func (m *mock) streamHandler(md protoreflect.MethodDescriptor) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		var last proto.Message
+		for {
+			req := dynamicpb.NewMessage(md.Input())
+			err := stream.RecvMsg(req)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			last = req
+			if md.IsStreamingClient() && !md.IsStreamingServer() {
+				continue
+			}
+			if err := m.send(stream, md, req); err != nil {
+				return err
+			}
+			if !md.IsStreamingClient() {
+				return nil
+			}
+		}
+		if md.IsStreamingClient() && !md.IsStreamingServer() && last != nil {
+			return m.send(stream, md, last)
+		}
+		return nil
+	}
+}
+
+func (m *mock) send(stream grpc.ServerStream, md protoreflect.MethodDescriptor, req proto.Message) error {
+	res, err := m.respond(md, req)
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(res)
+}
+
+// 记录每个 RPC 的方法、状态码和耗时
+func accessLog(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	logger.InfoContext(ctx, "请求", "protocol", "grpc", "method", info.FullMethod, "code", status.Code(err).String(), "latency", time.Since(start).String())
+	return res, err
+}
+
+func streamAccessLog(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	logger.InfoContext(stream.Context(), "请求", "protocol", "grpc", "method", info.FullMethod, "code", status.Code(err).String(), "latency", time.Since(start).String())
+	return err
+}
@@ -0,0 +1,165 @@
+// Package grpc_mock 按 proto 定义启动 gRPC mock 服务，同时按 google.api.http 注解注册对应的 REST 路由，
+// 两种协议使用同一份响应配置，保证 grpc-gateway 两侧的行为一致。
+package grpc_mock
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/value"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var logger = logging.For("grpc")
+
+// Run 启动 gRPC 和 REST mock 服务，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
+	protos := fs.String("proto", "", "comma separated proto files, relative to -import-path")
+	importPaths := fs.String("import-path", "", "comma separated directories to resolve proto imports from, current directory when empty")
+	config := fs.String("config", "", "json file with the response of each rpc method, empty messages when not set")
+	port := fs.String("port", envOr("GRPC_PORT", ":9090"), "grpc listen address (env GRPC_PORT)")
+	httpPort := fs.String("http-port", envOr("GRPC_HTTP_PORT", ":8080"), "listen address of the REST routes from google.api.http annotations, empty to disable (env GRPC_HTTP_PORT)")
+	enableReflection := fs.Bool("reflection", true, "register the grpc reflection service, used by grpcurl and similar clients")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	files := append(splitList(*protos), fs.Args()...)
+	if len(files) == 0 {
+		logger.Error("-proto is required")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	registry, services, err := loadProtos(ctx, files, splitList(*importPaths))
+	if err != nil {
+		logger.Error("编译 proto 失败", "err", err)
+		return 1
+	}
+	if len(services) == 0 {
+		logger.Error("proto 中没有定义服务", "files", files)
+		return 1
+	}
+	configs, err := loadConfigs(*config)
+	if err != nil {
+		logger.Error("读取响应配置失败", "err", err)
+		return 1
+	}
+	m := &mock{configs: configs, values: value.NewValueHandler()}
+	m.checkConfigs(services)
+
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(accessLog), grpc.ChainStreamInterceptor(streamAccessLog))
+	for _, sd := range services {
+		m.register(server, sd)
+	}
+	if *enableReflection {
+		opts := reflection.ServerOptions{Services: server, DescriptorResolver: registry}
+		grpc_reflection_v1.RegisterServerReflectionServer(server, reflection.NewServerV1(opts))
+		grpc_reflection_v1alpha.RegisterServerReflectionServer(server, reflection.NewServer(opts))
+	}
+
+	listener, err := net.Listen("tcp", listenAddr(*port))
+	if err != nil {
+		logger.Error("启动 gRPC 服务失败", "err", err)
+		return 1
+	}
+	errCh := make(chan error, 2)
+	go func() {
+		logger.Info("gRPC Mock 服务器启动", "addr", listener.Addr().String())
+		errCh <- server.Serve(listener)
+	}()
+
+	var httpServer *http.Server
+	if *httpPort != "" {
+		httpServer = &http.Server{Addr: listenAddr(*httpPort), Handler: m.gateway(services)}
+		go func() {
+			logger.Info("REST Mock 服务器启动", "addr", httpServer.Addr)
+			if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		logger.Error("服务异常退出", "err", err)
+		server.Stop()
+		return 1
+	case <-ctx.Done():
+	}
+	logger.Info("正在停止 Mock 服务器")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if httpServer != nil {
+		httpServer.Shutdown(shutdownCtx)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		server.Stop()
+	}
+	return 0
+}
+
+// 提示配置中写错的方法名
+func (m *mock) checkConfigs(services []protoreflect.ServiceDescriptor) {
+	known := make(map[string]bool)
+	for _, sd := range services {
+		for i := 0; i < sd.Methods().Len(); i++ {
+			known[fullMethod(sd.Methods().Get(i))] = true
+		}
+	}
+	for method := range m.configs {
+		if !known[method] {
+			logger.Warn("响应配置中的方法不存在", "method", method)
+		}
+	}
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,136 @@
+package grpc_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/TreeWu/mock-go/value"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// MockConfig 一个 RPC 方法的响应，gRPC 和 REST 共用。
+// response 中 @ 开头的占位符按 value 包生成，${field} 替换为请求消息中的字段（点分路径，使用 JSON 字段名）
+type MockConfig struct {
+	Method   string      `json:"method"`   // 方法全名，如 demo.v1.UserService/GetUser
+	Response interface{} `json:"response"` // 响应消息的 JSON 模板
+	Code     codes.Code  `json:"code"`     // 非 OK 时返回错误，如 "NOT_FOUND"
+	Message  string      `json:"message"`  // 错误信息，同样支持 ${field}
+}
+
+// 读取响应配置，key 为不带前导 / 的方法全名
+func loadConfigs(path string) (map[string]MockConfig, error) {
+	configs := make(map[string]MockConfig)
+	if path == "" {
+		return configs, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []MockConfig
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	for _, config := range list {
+		configs[strings.TrimPrefix(config.Method, "/")] = config
+	}
+	return configs, nil
+}
+
+// mock 按配置生成响应消息
+type mock struct {
+	configs map[string]MockConfig
+
+	mu     sync.Mutex
+	values *value.Handler
+}
+
+func fullMethod(md protoreflect.MethodDescriptor) string {
+	return string(md.Parent().FullName()) + "/" + string(md.Name())
+}
+
+// 生成方法的响应，没有配置的方法返回空消息
+func (m *mock) respond(md protoreflect.MethodDescriptor, req proto.Message) (proto.Message, error) {
+	config, ok := m.configs[fullMethod(md)]
+	res := dynamicpb.NewMessage(md.Output())
+	if !ok {
+		return res, nil
+	}
+
+	var vars map[string]interface{}
+	if data, err := protojson.Marshal(req); err == nil {
+		json.Unmarshal(data, &vars)
+	}
+	if config.Code != codes.OK {
+		return nil, status.Error(config.Code, fmt.Sprint(expand(config.Message, vars)))
+	}
+	if config.Response == nil {
+		return res, nil
+	}
+
+	m.mu.Lock()
+	body := m.values.ProcessDynamicValues(config.Response)
+	m.mu.Unlock()
+	data, err := json.Marshal(expand(body, vars))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal response: %v", err)
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, res); err != nil {
+		return nil, status.Errorf(codes.Internal, "response of %s does not match %s: %v", fullMethod(md), md.Output().FullName(), err)
+	}
+	return res, nil
+}
+
+var varPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// 替换 v 中所有字符串里的 ${field}，整个字符串只有一个变量时保留字段的原始类型
+func expand(v interface{}, vars map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if m := varPattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			return lookup(vars, strings.TrimSpace(m[1]))
+		}
+		return varPattern.ReplaceAllStringFunc(v, func(s string) string {
+			if value := lookup(vars, strings.TrimSpace(s[2:len(s)-1])); value != nil {
+				return fmt.Sprint(value)
+			}
+			return ""
+		})
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = expand(item, vars)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = expand(item, vars)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// 按点分路径取值，未设置的字段返回 nil
+func lookup(vars map[string]interface{}, path string) interface{} {
+	var v interface{} = vars
+	for _, key := range strings.Split(path, ".") {
+		node, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = node[key]
+	}
+	return v
+}
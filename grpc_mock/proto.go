@@ -0,0 +1,158 @@
+package grpc_mock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// 编译 proto 文件，返回包含所有依赖的描述集合和文件中定义的服务。
+// google/api/annotations.proto 等 googleapis 文件在导入路径中找不到时使用内置的定义
+func loadProtos(ctx context.Context, files, importPaths []string) (*protoregistry.Files, []protoreflect.ServiceDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.CompositeResolver{
+			protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+			protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+				fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+				if err != nil {
+					return protocompile.SearchResult{}, err
+				}
+				return protocompile.SearchResult{Desc: fd}, nil
+			}),
+		},
+	}
+	compiled, err := compiler.Compile(ctx, files...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registry := new(protoregistry.Files)
+	registered := make(map[string]bool)
+	var register func(fd protoreflect.FileDescriptor) error
+	register = func(fd protoreflect.FileDescriptor) error {
+		if registered[fd.Path()] {
+			return nil
+		}
+		registered[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			if err := register(imports.Get(i).FileDescriptor); err != nil {
+				return err
+			}
+		}
+		return registry.RegisterFile(fd)
+	}
+
+	var services []protoreflect.ServiceDescriptor
+	for _, fd := range compiled {
+		if err := register(fd); err != nil {
+			return nil, nil, fmt.Errorf("register %s: %v", fd.Path(), err)
+		}
+		for i := 0; i < fd.Services().Len(); i++ {
+			services = append(services, fd.Services().Get(i))
+		}
+	}
+	return registry, services, nil
+}
+
+// binding 方法上 google.api.http 注解定义的一个 REST 路由
+type binding struct {
+	method string // HTTP 方法
+	path   string // 注解中的路径模板，如 /v1/users/{id}
+	body   string // 请求体对应的字段，* 表示整个请求消息
+}
+
+// 读取方法的 google.api.http 注解，包括 additional_bindings
+func httpBindings(md protoreflect.MethodDescriptor) []binding {
+	opts := md.Options()
+	if opts == nil {
+		return nil
+	}
+	// 编译得到的选项中扩展字段可能是未解析的字节，按已注册的扩展重新解析
+	data, err := proto.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	parsed := opts.ProtoReflect().Type().New().Interface()
+	if err := (proto.UnmarshalOptions{Resolver: protoregistry.GlobalTypes}).Unmarshal(data, parsed); err != nil {
+		return nil
+	}
+	rule, ok := proto.GetExtension(parsed, annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil
+	}
+
+	var bindings []binding
+	var add func(rule *annotations.HttpRule)
+	add = func(rule *annotations.HttpRule) {
+		b := binding{body: rule.GetBody()}
+		switch pattern := rule.GetPattern().(type) {
+		case *annotations.HttpRule_Get:
+			b.method, b.path = "GET", pattern.Get
+		case *annotations.HttpRule_Post:
+			b.method, b.path = "POST", pattern.Post
+		case *annotations.HttpRule_Put:
+			b.method, b.path = "PUT", pattern.Put
+		case *annotations.HttpRule_Delete:
+			b.method, b.path = "DELETE", pattern.Delete
+		case *annotations.HttpRule_Patch:
+			b.method, b.path = "PATCH", pattern.Patch
+		case *annotations.HttpRule_Custom:
+			b.method, b.path = strings.ToUpper(pattern.Custom.GetKind()), pattern.Custom.GetPath()
+		}
+		if b.path != "" {
+			bindings = append(bindings, b)
+		}
+		for _, additional := range rule.GetAdditionalBindings() {
+			add(additional)
+		}
+	}
+	add(rule)
+	return bindings
+}
+
+// 把路径模板转换为 gin 路由，返回路由和按顺序对应的字段路径。
+// {id} 和 {id=*} 转换为 :id，末尾的 {name=**} 或 {name=a/*/b/*} 转换为 *name；不支持自定义动词（/v1/x:cancel）
+func ginRoute(template string) (string, []string, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", nil, fmt.Errorf("path %q must start with /", template)
+	}
+	var segments, fields []string
+	rest := template[1:]
+	for rest != "" {
+		var segment string
+		if strings.HasPrefix(rest, "{") {
+			end := strings.Index(rest, "}")
+			if end < 0 {
+				return "", nil, fmt.Errorf("path %q: unclosed {", template)
+			}
+			field, pattern, _ := strings.Cut(rest[1:end], "=")
+			rest = rest[end+1:]
+			if rest != "" && !strings.HasPrefix(rest, "/") {
+				return "", nil, fmt.Errorf("path %q: custom verbs are not supported", template)
+			}
+			fields = append(fields, field)
+			if pattern == "" || pattern == "*" {
+				segment = ":" + field
+			} else if rest == "" {
+				segment = "*" + field
+			} else {
+				return "", nil, fmt.Errorf("path %q: multi-segment variable %s is only supported at the end", template, field)
+			}
+			rest = strings.TrimPrefix(rest, "/")
+		} else {
+			segment, rest, _ = strings.Cut(rest, "/")
+		}
+		if strings.Contains(segment, ":") && !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			return "", nil, fmt.Errorf("path %q: custom verbs are not supported", template)
+		}
+		segments = append(segments, segment)
+	}
+	return "/" + strings.Join(segments, "/"), fields, nil
+}
@@ -0,0 +1,209 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessConfig 按客户端 IP 控制访问并模拟 CDN/WAF 注入的请求头
+type AccessConfig struct {
+	Allow          []string          `json:"allow"`           // 允许的 IP 或 CIDR，为空时允许所有
+	Deny           []string          `json:"deny"`            // 拒绝的 IP 或 CIDR，优先于 allow
+	DenyCountries  []string          `json:"deny_countries"`  // 拒绝的国家代码，按 countries 判断
+	DenyStatus     int               `json:"deny_status"`     // 拒绝时的状态码，默认 403
+	DenyBody       interface{}       `json:"deny_body"`       // 拒绝时的响应体，支持 ${client_ip}、${country}
+	TrustForwarded bool              `json:"trust_forwarded"` // 使用 X-Forwarded-For 中的第一个地址作为客户端 IP，便于模拟不同来源
+	Countries      map[string]string `json:"countries"`       // CIDR -> 国家代码，按最长前缀匹配
+	Headers        map[string]string `json:"headers"`         // 注入到请求和响应中的头，支持 ${client_ip}、${country}、${forwarded_for}
+}
+
+type countryPrefix struct {
+	prefix  netip.Prefix
+	country string
+}
+
+type accessControl struct {
+	config        AccessConfig
+	allow, deny   []netip.Prefix
+	countries     []countryPrefix
+	denyCountries map[string]bool
+}
+
+func loadAccessConfig(path string) (*accessControl, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config AccessConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return newAccessControl(config)
+}
+
+func newAccessControl(config AccessConfig) (*accessControl, error) {
+	a := &accessControl{config: config, denyCountries: make(map[string]bool)}
+	if a.config.DenyStatus == 0 {
+		a.config.DenyStatus = http.StatusForbidden
+	}
+	if a.config.DenyBody == nil {
+		a.config.DenyBody = map[string]interface{}{"error": "forbidden"}
+	}
+	var err error
+	if a.allow, err = parsePrefixes(config.Allow); err != nil {
+		return nil, err
+	}
+	if a.deny, err = parsePrefixes(config.Deny); err != nil {
+		return nil, err
+	}
+	for cidr, country := range config.Countries {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		a.countries = append(a.countries, countryPrefix{prefix: prefix, country: strings.ToUpper(country)})
+	}
+	// 最长前缀优先
+	sort.Slice(a.countries, func(i, j int) bool {
+		return a.countries[i].prefix.Bits() > a.countries[j].prefix.Bits()
+	})
+	for _, country := range config.DenyCountries {
+		a.denyCountries[strings.ToUpper(country)] = true
+	}
+	return a, nil
+}
+
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		prefix, err := parsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// 单个 IP 按 /32 或 /128 处理
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 客户端 IP，trust_forwarded 时取 X-Forwarded-For 的第一个地址
+func (a *accessControl) clientIP(r *http.Request) string {
+	if a.config.TrustForwarded {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (a *accessControl) country(addr netip.Addr) string {
+	for _, c := range a.countries {
+		if c.prefix.Contains(addr) {
+			return c.country
+		}
+	}
+	return ""
+}
+
+func (a *accessControl) allowed(addr netip.Addr, country string) bool {
+	if containsAddr(a.deny, addr) || a.denyCountries[country] {
+		return false
+	}
+	return len(a.allow) == 0 || containsAddr(a.allow, addr)
+}
+
+func (a *accessControl) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 健康检查不受限制，避免容器探针被拒绝
+		if path := c.Request.URL.Path; path == "/healthz" || path == "/readyz" {
+			return
+		}
+		ip := a.clientIP(c.Request)
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid client ip " + ip})
+			return
+		}
+		addr = addr.Unmap()
+		country := a.country(addr)
+		forwarded := ip
+		if prior := c.GetHeader("X-Forwarded-For"); prior != "" && !a.config.TrustForwarded {
+			forwarded = prior + ", " + ip
+		} else if prior != "" {
+			forwarded = prior
+		}
+		vars := map[string]string{"client_ip": ip, "country": country, "forwarded_for": forwarded}
+		expand := func(s string) string {
+			return os.Expand(s, func(name string) string { return vars[name] })
+		}
+
+		for name, v := range a.config.Headers {
+			v = expand(v)
+			c.Request.Header.Set(name, v)
+			c.Header(name, v)
+		}
+		if !a.allowed(addr, country) {
+			logger.Debug("拒绝访问", "client", ip, "country", country, "path", c.Request.URL.Path)
+			c.AbortWithStatusJSON(a.config.DenyStatus, expandStrings(a.config.DenyBody, expand))
+			return
+		}
+		c.Next()
+	}
+}
+
+// 替换 v 中所有字符串里的变量
+func expandStrings(v interface{}, expand func(string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return expand(v)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = expandStrings(item, expand)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = expandStrings(item, expand)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,209 @@
+package http_mock
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理接口和 Web 界面的路径前缀，mock 配置中不能使用
+const (
+	adminPrefix = "/__admin"
+	uiPrefix    = "/__ui"
+)
+
+// 请求日志保留的条数和每条记录的请求体长度
+const (
+	requestLogSize = 500
+	maxLoggedBody  = 4 << 10
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+func isAdminPath(path string) bool {
+	return strings.HasPrefix(path, adminPrefix) || strings.HasPrefix(path, uiPrefix)
+}
+
+// RequestEntry 请求日志中的一条记录
+type RequestEntry struct {
+	Seq     int64         `json:"seq"`
+	Time    time.Time     `json:"time"`
+	Method  string        `json:"method"`
+	Path    string        `json:"path"`
+	Query   string        `json:"query,omitempty"`
+	Body    string        `json:"body,omitempty"`
+	Status  int           `json:"status"`
+	Latency time.Duration `json:"latency"`
+	Client  string        `json:"client"`
+}
+
+// requestLog 保存最近的请求，Web 界面按序号轮询新增的记录
+type requestLog struct {
+	mu      sync.Mutex
+	seq     int64
+	entries []RequestEntry
+}
+
+func newRequestLog() *requestLog {
+	return &requestLog{}
+}
+
+// 记录管理接口以外的请求，请求体读取后放回，不影响后续处理
+func (l *requestLog) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAdminPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBody+1))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		}
+		c.Next()
+
+		text := string(body)
+		if len(body) > maxLoggedBody {
+			text = string(body[:maxLoggedBody]) + "..."
+		}
+		l.add(RequestEntry{
+			Time:    start,
+			Method:  c.Request.Method,
+			Path:    c.Request.URL.Path,
+			Query:   c.Request.URL.RawQuery,
+			Body:    text,
+			Status:  c.Writer.Status(),
+			Latency: time.Since(start),
+			Client:  c.ClientIP(),
+		})
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (l *requestLog) add(entry RequestEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	entry.Seq = l.seq
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > requestLogSize {
+		l.entries = append(l.entries[:0], l.entries[len(l.entries)-requestLogSize:]...)
+	}
+}
+
+// 返回序号大于 seq 的记录
+func (l *requestLog) since(seq int64) []RequestEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := []RequestEntry{}
+	for _, entry := range l.entries {
+		if entry.Seq > seq {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func (l *requestLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
+
+// adminAPI 管理接口和 Web 界面。mocks 为空时不提供 mock 编辑，scenario 为空时不提供场景状态，messages 为空时不提供消息查询
+type adminAPI struct {
+	requests *requestLog
+	mocks    *HttpMockHandler
+	scenario func() ScenarioState
+	messages *messageStore
+}
+
+func (a *adminAPI) register(router gin.IRouter) {
+	admin := router.Group(adminPrefix)
+	admin.GET("/info", a.info)
+	admin.GET("/requests", a.listRequests)
+	admin.DELETE("/requests", a.clearRequests)
+	if a.mocks != nil {
+		admin.GET("/mocks", a.listMocks)
+		admin.POST("/mocks", a.saveMock)
+		admin.DELETE("/mocks", a.deleteMock)
+		admin.POST("/reload", a.reload)
+		admin.GET("/usage", a.getUsage)
+		admin.DELETE("/usage", a.resetUsage)
+		admin.GET("/assertions", a.getAssertions)
+		admin.DELETE("/assertions", a.resetAssertions)
+		admin.GET("/states", a.listStates)
+		admin.PUT("/states/:name", a.setState)
+		admin.DELETE("/states", a.resetStates)
+	}
+	if a.scenario != nil {
+		admin.GET("/scenario", func(c *gin.Context) {
+			c.JSON(http.StatusOK, a.scenario())
+		})
+	}
+	if a.messages != nil {
+		a.messages.register(admin)
+	}
+
+	ui, _ := fs.Sub(uiFiles, "ui")
+	router.StaticFS(uiPrefix, http.FS(ui))
+}
+
+func (a *adminAPI) info(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"mocks": a.mocks != nil, "scenario": a.scenario != nil, "messages": a.messages != nil})
+}
+
+func (a *adminAPI) listRequests(c *gin.Context) {
+	since, _ := strconv.ParseInt(c.Query("since"), 10, 64)
+	c.JSON(http.StatusOK, a.requests.since(since))
+}
+
+func (a *adminAPI) clearRequests(c *gin.Context) {
+	a.requests.clear()
+	c.Status(http.StatusNoContent)
+}
+
+func (a *adminAPI) listMocks(c *gin.Context) {
+	c.JSON(http.StatusOK, a.mocks.Configs())
+}
+
+// 新增 mock，方法和路径相同时替换原有配置
+func (a *adminAPI) saveMock(c *gin.Context) {
+	var config MockConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	config, err := a.mocks.SaveConfig(config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logger.Info("更新 mock 配置", "method", config.Method, "url", config.URL)
+	c.JSON(http.StatusOK, config)
+}
+
+func (a *adminAPI) deleteMock(c *gin.Context) {
+	method, url := c.Query("method"), c.Query("url")
+	if !a.mocks.DeleteConfig(method, url) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("mock %s %s not found", method, url)})
+		return
+	}
+	logger.Info("删除 mock 配置", "method", method, "url", url)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,414 @@
+package http_mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/value"
+)
+
+var attackLogger = logging.For("attack")
+
+// RunAttack 把 mock 配置中的请求作为客户端发往真实服务做压测，args 为命令行参数（不含命令名），返回进程退出码。
+// 请求的 query 和 body 分别由配置中的 params 和 req 按占位符生成，路径参数 :name 替换为随机字符串
+func RunAttack(args []string) int {
+	fs := flag.NewFlagSet("attack", flag.ContinueOnError)
+	target := fs.String("target", "", "base url of the service under test, e.g. http://localhost:8080")
+	configs := fs.String("config", envOr("SERVE_CONFIG", defaultConfig), "comma separated mock config files or directories of *.json (env SERVE_CONFIG)")
+	env := fs.String("env", os.Getenv("SERVE_ENV"), "environment whose overlays are merged into the configs, same layout as serve (env SERVE_ENV)")
+	overlays := fs.String("overlay", "", "comma separated overlay files merged after the environment overlays")
+	rate := fs.Float64("rate", 0, "requests per second across all workers, 0 for as fast as possible")
+	concurrency := fs.Int("concurrency", 10, "concurrent workers")
+	duration := fs.Duration("duration", 10*time.Second, "how long to attack, ignored when -requests is set")
+	requests := fs.Int("requests", 0, "total requests to send, 0 to run for -duration")
+	timeout := fs.Duration("timeout", 5*time.Second, "per request timeout")
+	headers := fs.String("header", "", "comma separated extra headers, e.g. Authorization:Bearer x")
+	format := fs.String("format", "text", "report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *target == "" {
+		attackLogger.Error("-target is required")
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		attackLogger.Error("unsupported report format", "format", *format)
+		return 2
+	}
+	header, err := parseHeaders(*headers)
+	if err != nil {
+		attackLogger.Error("invalid -header", "err", err)
+		return 2
+	}
+
+	paths := append(splitList(*configs), fs.Args()...)
+	handler := NewHttpMockHandler("", paths...)
+	handler.Env = *env
+	handler.Overlays = splitList(*overlays)
+	mockConfigs, err := handler.loadConfigs()
+	if err != nil {
+		attackLogger.Error("加载配置失败", "err", err)
+		return 1
+	}
+	if len(mockConfigs) == 0 {
+		attackLogger.Error("配置中没有请求")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *requests <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	attacker := &attacker{
+		target:  strings.TrimRight(*target, "/"),
+		configs: mockConfigs,
+		header:  header,
+		client:  &http.Client{Timeout: *timeout},
+		values:  value.NewValueHandler(),
+	}
+	attackLogger.Info("开始压测", "target", attacker.target, "endpoints", len(mockConfigs), "concurrency", *concurrency, "rate", *rate)
+	report := attacker.run(ctx, *concurrency, *rate, *requests)
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Print(report.String())
+	}
+	if report.Total.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// 解析 Name:value 形式的请求头，多个用逗号分隔
+func parseHeaders(s string) (http.Header, error) {
+	header := make(http.Header)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%q should be Name:value", item)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+type attacker struct {
+	target  string
+	configs []MockConfig
+	header  http.Header
+	client  *http.Client
+	next    atomic.Uint64 // 轮流使用各个配置
+
+	mu     sync.Mutex // value.Handler 不是并发安全的
+	values *value.Handler
+}
+
+// 启动 workers 个并发发送请求，rate 大于 0 时按固定间隔放行，total 大于 0 时发送完即停止
+func (a *attacker) run(ctx context.Context, workers int, rate float64, total int) *AttackReport {
+	if workers <= 0 {
+		workers = 1
+	}
+	stats := newAttackStats()
+
+	// 发放请求令牌，ctx 结束或达到总数时关闭
+	tokens := make(chan struct{})
+	go func() {
+		defer close(tokens)
+		var tick <-chan time.Time
+		if rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for sent := 0; total <= 0 || sent < total; sent++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range tokens {
+				config := a.configs[int(a.next.Add(1)-1)%len(a.configs)]
+				status, latency, err := a.send(config)
+				stats.add(endpointName(config), status, latency, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return stats.report(time.Since(start))
+}
+
+// 发送一个请求，返回状态码和耗时，请求失败时状态码为 0
+func (a *attacker) send(config MockConfig) (int, time.Duration, error) {
+	a.mu.Lock()
+	path := fillPathParams(config.URL, a.values)
+	var query map[string]interface{}
+	if len(config.Params) > 0 {
+		query = a.values.ProcessDynamicMap(config.Params)
+	}
+	var body []byte
+	if len(config.Req) > 0 {
+		body, _ = json.Marshal(a.values.ProcessDynamicMap(config.Req))
+	}
+	a.mu.Unlock()
+
+	u := a.target + path
+	if len(query) > 0 {
+		q := url.Values{}
+		for k, v := range query {
+			// 数组参数按重复的参数发送，如 ids=1&ids=2
+			if items, ok := v.([]interface{}); ok {
+				for _, item := range items {
+					q.Add(k, fmt.Sprint(item))
+				}
+				continue
+			}
+			q.Set(k, fmt.Sprint(v))
+		}
+		u += "?" + q.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(strings.ToUpper(config.Method), u, reader)
+	if err != nil {
+		return 0, 0, err
+	}
+	for name, values := range a.header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	res, err := a.client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return res.StatusCode, time.Since(start), nil
+}
+
+// 把路径参数 :name、*name 和 {name} 替换为随机字符串
+func fillPathParams(path string, values *value.Handler) string {
+	segments := strings.Split(ginRoute(path), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = values.GenerateRandomString("8")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func endpointName(config MockConfig) string {
+	return strings.ToUpper(config.Method) + " " + config.URL
+}
+
+// 按接口汇总的结果
+type attackStats struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+type endpointStats struct {
+	latencies []time.Duration
+	status    map[int]int
+	errors    int
+	lastError string
+}
+
+func newAttackStats() *attackStats {
+	return &attackStats{endpoints: make(map[string]*endpointStats)}
+}
+
+func (s *attackStats) add(name string, status int, latency time.Duration, err error) {
+	if err == nil && status >= 400 {
+		err = fmt.Errorf("status %d", status)
+	}
+	s.record(name, status, latency, err)
+}
+
+// 记录一个请求，只有 err 不为空时计为错误
+func (s *attackStats) record(name string, status int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.endpoints[name]
+	if !ok {
+		e = &endpointStats{status: make(map[int]int)}
+		s.endpoints[name] = e
+	}
+	e.latencies = append(e.latencies, latency)
+	if status > 0 {
+		e.status[status]++
+	}
+	if err != nil {
+		e.errors++
+		e.lastError = err.Error()
+	}
+}
+
+// 记录一个不对应请求的错误，例如响应中缺少需要提取的字段
+func (s *attackStats) fail(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.endpoints[name]; ok {
+		e.errors++
+		e.lastError = err.Error()
+	}
+}
+
+// AttackReport 压测结果，Total 为所有接口的汇总
+type AttackReport struct {
+	Duration  time.Duration    `json:"duration"`
+	Total     LatencySummary   `json:"total"`
+	Endpoints []LatencySummary `json:"endpoints"`
+}
+
+// LatencySummary 一组请求的数量、状态码分布和延迟分位数，JSON 中的时间单位为纳秒
+type LatencySummary struct {
+	Name       string        `json:"name"`
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"` // 请求失败或状态码 >= 400
+	Throughput float64       `json:"throughput"`
+	Status     map[int]int   `json:"status"`
+	Min        time.Duration `json:"min"`
+	Mean       time.Duration `json:"mean"`
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	P95        time.Duration `json:"p95"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+	LastError  string        `json:"last_error,omitempty"`
+}
+
+func (s *attackStats) report(elapsed time.Duration) *AttackReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &AttackReport{Duration: elapsed}
+	total := &endpointStats{status: make(map[int]int)}
+	names := make([]string, 0, len(s.endpoints))
+	for name := range s.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := s.endpoints[name]
+		report.Endpoints = append(report.Endpoints, summarize(name, e, elapsed))
+		total.latencies = append(total.latencies, e.latencies...)
+		total.errors += e.errors
+		for code, n := range e.status {
+			total.status[code] += n
+		}
+		if e.lastError != "" {
+			total.lastError = e.lastError
+		}
+	}
+	report.Total = summarize("total", total, elapsed)
+	return report
+}
+
+func summarize(name string, e *endpointStats, elapsed time.Duration) LatencySummary {
+	summary := LatencySummary{Name: name, Requests: len(e.latencies), Errors: e.errors, Status: e.status, LastError: e.lastError}
+	if len(e.latencies) == 0 {
+		return summary
+	}
+	latencies := append([]time.Duration(nil), e.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	percentile := func(p float64) time.Duration {
+		i := int(math.Ceil(p/100*float64(len(latencies)))) - 1
+		return latencies[max(i, 0)]
+	}
+	summary.Min = latencies[0]
+	summary.Max = latencies[len(latencies)-1]
+	summary.Mean = sum / time.Duration(len(latencies))
+	summary.P50, summary.P90, summary.P95, summary.P99 = percentile(50), percentile(90), percentile(95), percentile(99)
+	if elapsed > 0 {
+		summary.Throughput = float64(len(latencies)) / elapsed.Seconds()
+	}
+	return summary
+}
+
+func (r *AttackReport) String() string {
+	return r.format("压测完成")
+}
+
+// 以 title 开头的文本报告
+func (r *AttackReport) format(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s，耗时 %v\n", title, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "%-40s %8s %8s %10s %10s %10s %10s %10s %10s\n",
+		"接口", "请求数", "错误数", "req/s", "mean", "p50", "p90", "p99", "max")
+	for _, s := range append(r.Endpoints, r.Total) {
+		fmt.Fprintf(&b, "%-40s %8d %8d %10.1f %10v %10v %10v %10v %10v\n",
+			s.Name, s.Requests, s.Errors, s.Throughput,
+			s.Mean.Round(time.Microsecond), s.P50.Round(time.Microsecond), s.P90.Round(time.Microsecond),
+			s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+
+	codes := make([]int, 0, len(r.Total.Status))
+	for code := range r.Total.Status {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	b.WriteString("状态码:")
+	for _, code := range codes {
+		fmt.Fprintf(&b, " %d×%d", code, r.Total.Status[code])
+	}
+	b.WriteString("\n")
+	if r.Total.LastError != "" {
+		fmt.Fprintf(&b, "最近一次请求错误: %s\n", r.Total.LastError)
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+)
+
+// body_file 是否按 JSON 读取：设置了 content_type 时按媒体类型判断，否则按扩展名
+func jsonBodyFile(r Response) bool {
+	if r.ContentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(r.ContentType)
+		return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	}
+	return strings.EqualFold(filepath.Ext(r.BodyFile), ".json")
+}
+
+// 处理 content_type 和 body_file：JSON 文件读入 body，其他文件交给 file 原样返回。读取失败时保留原来的 body
+func loadBodyFile(r *Response, url string) {
+	if r.ContentType != "" && !hasHeader(r.Headers, "Content-Type") {
+		// 复制一份，不修改配置中的响应头
+		headers := make(map[string]string, len(r.Headers)+1)
+		for k, v := range r.Headers {
+			headers[k] = v
+		}
+		headers["Content-Type"] = r.ContentType
+		r.Headers = headers
+	}
+	if r.BodyFile == "" {
+		return
+	}
+	if !jsonBodyFile(*r) {
+		r.File = r.BodyFile
+		return
+	}
+	body, err := readJSONBody(r.BodyFile)
+	if err != nil {
+		logger.Warn("读取 body_file 失败，使用 body", "url", url, "file", r.BodyFile, "err", err)
+		return
+	}
+	r.Body = body
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 配置中所有响应引用的 body_file，热加载时一起监听
+func (config MockConfig) bodyFiles() []string {
+	responses := []Response{config.Response}
+	for _, v := range config.Versions {
+		responses = append(responses, v.Response)
+	}
+	for _, m := range config.Matches {
+		responses = append(responses, m.Response)
+	}
+	if config.Sequence != nil {
+		responses = append(responses, config.Sequence.Responses...)
+	}
+	if config.State != nil {
+		for _, s := range config.State.States {
+			responses = append(responses, s.Response)
+		}
+	}
+	if config.Table != nil && config.Table.Missing != nil {
+		responses = append(responses, *config.Table.Missing)
+	}
+	var files []string
+	for _, r := range responses {
+		if r.BodyFile != "" {
+			files = append(files, r.BodyFile)
+		}
+	}
+	return files
+}
+
+func readJSONBody(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return body, nil
+}
+
+// 检查 body_file 是否可以读取，JSON 文件还检查其中的占位符和模板表达式
+func lintBodyFile(prefix string, r Response) []string {
+	if r.BodyFile == "" {
+		return nil
+	}
+	var problems []string
+	if r.Body != nil || r.File != "" {
+		problems = append(problems, prefix+": body_file replaces body and file")
+	}
+	if !jsonBodyFile(r) {
+		if info, err := os.Stat(r.BodyFile); err != nil || info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%s.body_file: %s is not a readable file, requests get 404", prefix, r.BodyFile))
+		}
+		return problems
+	}
+	body, err := readJSONBody(r.BodyFile)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s.body_file: %v, body is used", prefix, err))
+	}
+	problems = append(problems, value.Lint(prefix+".body_file", body)...)
+	return append(problems, lintTemplates(prefix+".body_file", body)...)
+}
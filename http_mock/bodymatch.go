@@ -0,0 +1,499 @@
+package http_mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 请求体的比较方式
+const (
+	MatchStrict    = "strict"    // 完全相等
+	MatchLenient   = "lenient"   // 忽略请求中多余的字段，默认
+	MatchUnordered = "unordered" // 在 lenient 的基础上数组不要求顺序
+)
+
+// BodyMatch 按请求体、query 参数和请求头选择的响应。body 按 mode 和 JSON 请求体比较，jsonpath、xpath、query、form
+// 和 header 中的条件全部满足才命中，设置了多项时都要满足
+type BodyMatch struct {
+	Body     interface{} `json:"body,omitempty"`
+	Mode     string      `json:"mode,omitempty"`
+	JSONPath []string    `json:"jsonpath,omitempty"` // 如 $.order.items[0].sku == "ABC"，只写路径时要求字段存在
+	XPath    []string    `json:"xpath,omitempty"`    // 如 /Envelope/Body/GetOrder/Id == "42"，只对 XML 请求体生效
+	Query    []string    `json:"query,omitempty"`    // query 参数的条件，格式同 jsonpath，如 $.ids[*] == 2
+	Form     []string    `json:"form,omitempty"`     // 表单字段的条件，格式同 jsonpath，如 $.user.name == "tom"
+	Header   []string    `json:"header,omitempty"`   // 请求头的条件，格式同 jsonpath，名称不区分大小写，如 $.X-Tenant == "acme"
+	Response Response    `json:"response"`
+}
+
+// requestBody 解析后的请求，请求体按内容设置 json、xml 和 form 中的一个，query、header 和 params 总是设置，
+// row 是路由关联的数据表中查到或生成的行
+type requestBody struct {
+	json   interface{}
+	xml    *xmlNode
+	form   map[string]interface{}
+	query  map[string]interface{}
+	header map[string]interface{} // 名称为小写
+	params map[string]string
+	row    map[string]interface{}
+}
+
+// 按 Content-Type 解析请求体，没有 Content-Type 时按内容判断，< 开头的按 XML 解析
+func parseRequestBody(contentType string, data []byte) (requestBody, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return requestBody{}, errors.New("empty body")
+	}
+	// curl -d 等工具默认按表单发送，内容是 JSON 对象或数组时仍按 JSON 解析
+	isJSON := (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed)
+	if values, ok, err := parseForm(contentType, data); ok && !isJSON {
+		return requestBody{form: decodeParams(values)}, err
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") || mediaType == "" && trimmed[0] == '<' {
+		doc, err := parseXML(trimmed)
+		return requestBody{xml: doc}, err
+	}
+	var body requestBody
+	err := json.Unmarshal(trimmed, &body.json)
+	return body, err
+}
+
+// bodyMatcher 编译后的 BodyMatch
+type bodyMatcher struct {
+	body       interface{}
+	mode       string
+	conditions []*jsonPathCondition
+	xpaths     []*xpathCondition
+	query      []*jsonPathCondition
+	form       []*jsonPathCondition
+	header     []*jsonPathCondition
+}
+
+func compileBodyMatch(m BodyMatch) (*bodyMatcher, error) {
+	matcher := &bodyMatcher{body: normalizeJSON(m.Body), mode: m.Mode}
+	switch m.Mode {
+	case "":
+		matcher.mode = MatchLenient
+	case MatchStrict, MatchLenient, MatchUnordered:
+	default:
+		return nil, fmt.Errorf("unknown mode %q, want strict, lenient or unordered", m.Mode)
+	}
+	for _, expr := range m.JSONPath {
+		cond, err := parseJSONPathCondition(expr)
+		if err != nil {
+			return nil, err
+		}
+		matcher.conditions = append(matcher.conditions, cond)
+	}
+	for _, expr := range m.XPath {
+		cond, err := parseXPathCondition(expr)
+		if err != nil {
+			return nil, err
+		}
+		matcher.xpaths = append(matcher.xpaths, cond)
+	}
+	for _, expr := range m.Query {
+		cond, err := parseJSONPathCondition(expr)
+		if err != nil {
+			return nil, fmt.Errorf("query: %v", err)
+		}
+		matcher.query = append(matcher.query, cond)
+	}
+	for _, expr := range m.Form {
+		cond, err := parseJSONPathCondition(expr)
+		if err != nil {
+			return nil, fmt.Errorf("form: %v", err)
+		}
+		matcher.form = append(matcher.form, cond)
+	}
+	for _, expr := range m.Header {
+		cond, err := parseJSONPathCondition(expr)
+		if err != nil {
+			return nil, fmt.Errorf("header: %v", err)
+		}
+		if len(cond.segments) > 0 {
+			cond.segments[0].key = strings.ToLower(cond.segments[0].key)
+		}
+		matcher.header = append(matcher.header, cond)
+	}
+	return matcher, nil
+}
+
+func (m *bodyMatcher) match(body requestBody) bool {
+	if m.body != nil && !matchValue(m.body, body.json, m.mode) {
+		return false
+	}
+	for _, cond := range m.conditions {
+		if !cond.eval(body.json) {
+			return false
+		}
+	}
+	for _, cond := range m.xpaths {
+		if body.xml == nil || !cond.eval(body.xml) {
+			return false
+		}
+	}
+	for _, cond := range m.query {
+		if !cond.evalParams(body.query) {
+			return false
+		}
+	}
+	for _, cond := range m.form {
+		if body.form == nil || !cond.evalParams(body.form) {
+			return false
+		}
+	}
+	for _, cond := range m.header {
+		if !cond.evalParams(body.header) {
+			return false
+		}
+	}
+	return true
+}
+
+// 数字统一为 float64，和 json.Unmarshal 得到的请求体一致
+func normalizeJSON(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var result interface{}
+	json.Unmarshal(data, &result)
+	return result
+}
+
+func matchValue(want, got interface{}, mode string) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok || (mode == MatchStrict && len(g) != len(w)) {
+			return false
+		}
+		for key, value := range w {
+			actual, ok := g[key]
+			if !ok || !matchValue(value, actual, mode) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		if mode != MatchUnordered {
+			for i := range w {
+				if !matchValue(w[i], g[i], mode) {
+					return false
+				}
+			}
+			return true
+		}
+		// 每个期望的元素匹配一个不同的实际元素
+		used := make([]bool, len(g))
+		for _, item := range w {
+			found := false
+			for j, actual := range g {
+				if !used[j] && matchValue(item, actual, mode) {
+					used[j], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// jsonPathCondition 一个 JSONPath 条件：路径、比较运算符和 JSON 字面量，没有运算符时只要求路径存在。
+// 路径支持 $、.name、['name']、[n]、[*] 和 .*，有多个值时任意一个满足即可
+type jsonPathCondition struct {
+	segments []pathSegment
+	comparison
+}
+
+// comparison 比较运算符和 JSON 字面量，op 为空时不比较
+type comparison struct {
+	op    string
+	value interface{}
+	re    *regexp.Regexp
+}
+
+type pathSegment struct {
+	key      string
+	index    int // key 为空时使用
+	wildcard bool
+}
+
+var conditionPattern = regexp.MustCompile(`^\s*(\$\S*?)\s*(?:(==|!=|>=|<=|=~|>|<)\s*(.+?))?\s*$`)
+
+func parseJSONPathCondition(expr string) (*jsonPathCondition, error) {
+	m := conditionPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid jsonpath condition %q, want $.path [op value]", expr)
+	}
+	segments, err := parsePath(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", expr, err)
+	}
+	cmp, err := parseComparison(expr, m[2], m[3])
+	if err != nil {
+		return nil, err
+	}
+	return &jsonPathCondition{segments: segments, comparison: cmp}, nil
+}
+
+func parseComparison(expr, op, literal string) (comparison, error) {
+	cmp := comparison{op: op}
+	if op == "" {
+		return cmp, nil
+	}
+	if err := json.Unmarshal([]byte(literal), &cmp.value); err != nil {
+		return cmp, fmt.Errorf("%s: value %s is not a json literal", expr, literal)
+	}
+	if op == "=~" {
+		pattern, ok := cmp.value.(string)
+		if !ok {
+			return cmp, fmt.Errorf("%s: =~ needs a quoted regexp", expr)
+		}
+		var err error
+		if cmp.re, err = regexp.Compile(pattern); err != nil {
+			return cmp, fmt.Errorf("%s: %v", expr, err)
+		}
+	}
+	return cmp, nil
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	rest := strings.TrimPrefix(path, "$")
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".*"):
+			segments = append(segments, pathSegment{wildcard: true})
+			rest = rest[2:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty field name in %s", path)
+			}
+			segments = append(segments, pathSegment{key: rest[1 : end+1]})
+			rest = rest[end+1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %s", path)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in %s", inner, path)
+				}
+				segments = append(segments, pathSegment{index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in %s", rest, path)
+		}
+	}
+	return segments, nil
+}
+
+// 按路径取值，下标为负数时从末尾开始。scalarItems 为 true 时单个值当作只有一个元素的数组，
+// 用于 query 参数和表单字段，ids=1 和 ids=1&ids=2 都可以用 $.ids[0] 取值
+func selectPath(values []interface{}, segments []pathSegment, scalarItems bool) []interface{} {
+	for _, seg := range segments {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if seg.wildcard {
+					for _, item := range v {
+						next = append(next, item)
+					}
+				} else if item, ok := v[seg.key]; ok && seg.key != "" {
+					next = append(next, item)
+				}
+			case []interface{}:
+				if seg.wildcard {
+					next = append(next, v...)
+				} else if seg.key == "" {
+					i := seg.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			default:
+				if scalarItems && (seg.wildcard || seg.key == "" && (seg.index == 0 || seg.index == -1)) {
+					next = append(next, v)
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func (c *jsonPathCondition) eval(body interface{}) bool {
+	for _, v := range selectPath([]interface{}{body}, c.segments, false) {
+		if c.compare(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按 compareText 比较 query 参数或表单字段
+func (c *jsonPathCondition) evalParams(params map[string]interface{}) bool {
+	for _, v := range selectPath([]interface{}{params}, c.segments, true) {
+		if s, ok := v.(string); ok && c.compareText(s) || !ok && c.compare(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 文本和数字或布尔值比较时先按字面量的类型转换，用于 XML、query 参数和表单字段中的值
+func (c comparison) compareText(text string) bool {
+	var v interface{} = text
+	switch c.value.(type) {
+	case float64:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			v = f
+		}
+	case bool:
+		if b, err := strconv.ParseBool(strings.TrimSpace(text)); err == nil {
+			v = b
+		}
+	}
+	return c.compare(v)
+}
+
+func (c comparison) compare(v interface{}) bool {
+	switch c.op {
+	case "":
+		return true
+	case "==":
+		return reflect.DeepEqual(v, c.value)
+	case "!=":
+		return !reflect.DeepEqual(v, c.value)
+	case "=~":
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		return c.re.MatchString(s)
+	}
+	var cmp int
+	switch want := c.value.(type) {
+	case float64:
+		got, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case got < want:
+			cmp = -1
+		case got > want:
+			cmp = 1
+		}
+	case string:
+		got, ok := v.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(got, want)
+	default:
+		return false
+	}
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// xpathCondition 一个 XPath 条件，格式和 jsonPathCondition 相同。XML 中的值都是文本，按 compareText 比较
+type xpathCondition struct {
+	steps []xpathStep
+	comparison
+}
+
+var xpathOperatorPattern = regexp.MustCompile(`^(==|!=|>=|<=|=~|>|<)\s*(.+?)\s*$`)
+
+func parseXPathCondition(expr string) (*xpathCondition, error) {
+	expr = strings.TrimSpace(expr)
+	// 路径在第一个不在 [] 和引号中的空白或运算符处结束，路径中的条件可以包含空格和 =
+	end, depth, quote := len(expr), 0, byte(0)
+scan:
+	for i := 0; i < len(expr); i++ {
+		switch ch := expr[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+		case depth == 0 && strings.IndexByte(" \t=!<>", ch) >= 0:
+			end = i
+			break scan
+		}
+	}
+	steps, err := parseXPath(expr[:end])
+	if err != nil {
+		return nil, err
+	}
+	cond := &xpathCondition{steps: steps}
+	if rest := strings.TrimSpace(expr[end:]); rest != "" {
+		m := xpathOperatorPattern.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("invalid xpath condition %q, want /path [op value]", expr)
+		}
+		if cond.comparison, err = parseComparison(expr, m[1], m[2]); err != nil {
+			return nil, err
+		}
+	}
+	return cond, nil
+}
+
+func (c *xpathCondition) eval(doc *xmlNode) bool {
+	for _, text := range selectXPath(doc, c.steps) {
+		if c.compareText(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package http_mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache 模拟 HTTP 缓存语义。配置后响应体生成一次并在 refresh 时长内保持不变，
+// 根据响应体生成 ETag 和 Last-Modified，GET 和 HEAD 请求的 If-None-Match、If-Modified-Since 命中时返回 304
+type Cache struct {
+	ETag         string `json:"etag"`          // strong 或 weak，为空时不返回 ETag
+	LastModified bool   `json:"last_modified"` // 返回 Last-Modified，值为当前响应体生成的时间
+	CacheControl string `json:"cache_control"` // Cache-Control 响应头，如 public, max-age=60
+	Refresh      string `json:"refresh"`       // 响应体保持不变的时长，如 30s，到期后重新生成；为空时只生成一次
+}
+
+// cacheState 一个 mock 当前版本的响应体
+type cacheState struct {
+	cache   Cache
+	refresh time.Duration
+
+	mu          sync.Mutex
+	data        []byte
+	contentType string
+	etag        string
+	modified    time.Time
+}
+
+func newCacheState(cache Cache) *cacheState {
+	s := &cacheState{cache: cache}
+	if cache.Refresh != "" {
+		refresh, err := time.ParseDuration(cache.Refresh)
+		if err != nil || refresh < 0 {
+			logger.Warn("cache.refresh 格式错误，响应体只生成一次", "refresh", cache.Refresh)
+		} else {
+			s.refresh = refresh
+		}
+	}
+	if cache.ETag != "" && cache.ETag != "strong" && cache.ETag != "weak" {
+		logger.Warn("cache.etag 只支持 strong 和 weak，按 strong 处理", "etag", cache.ETag)
+	}
+	return s
+}
+
+// 返回当前版本的响应体和 Content-Type，没有生成过或已过期时重新生成。encode 生成并编码响应体，和不缓存时的响应相同
+func (s *cacheState) current(encode func() ([]byte, string)) ([]byte, string, string, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.data == nil || s.refresh > 0 && now.Sub(s.modified) >= s.refresh {
+		s.data, s.contentType = encode()
+		sum := sha256.Sum256(s.data)
+		s.etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+		if s.cache.ETag == "weak" {
+			s.etag = "W/" + s.etag
+		}
+		// Last-Modified 精度为秒，截断后 If-Modified-Since 才能按相等比较
+		s.modified = now.Truncate(time.Second)
+	}
+	return s.data, s.contentType, s.etag, s.modified
+}
+
+func (s *cacheState) serve(c *gin.Context, status int, encode func() ([]byte, string)) {
+	data, contentType, etag, modified := s.current(encode)
+	if s.cache.ETag != "" {
+		c.Header("ETag", etag)
+	}
+	if s.cache.LastModified {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if s.cache.CacheControl != "" {
+		c.Header("Cache-Control", s.cache.CacheControl)
+	}
+	if s.notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(status, contentType, data)
+}
+
+// 按 RFC 9110 判断条件请求：有 If-None-Match 时只比较 ETag（弱比较），否则比较 If-Modified-Since
+func (s *cacheState) notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if s.cache.ETag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" && s.cache.LastModified {
+		t, err := http.ParseTime(since)
+		return err == nil && !modified.After(t)
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package http_mock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 没有设置 chunked.size 时每块的大小
+const defaultChunkSize = 1 << 10
+
+// Chunked 分块发送响应体，每块之间等待 delay，用于测试流式解析 JSON/NDJSON 的客户端和下载进度。
+// 响应体和 file 都可以分块发送
+type Chunked struct {
+	Size  string `json:"size,omitempty"`  // 每块的大小，如 16KB，默认 1KB；ndjson 时为空表示每行一块
+	Delay string `json:"delay,omitempty"` // 两块之间的等待时间，如 100ms
+	// NDJSON 为 true 时 body 为数组则每个元素一行，否则按 body 生成 count 行（默认 1），Content-Type 为 application/x-ndjson
+	NDJSON bool `json:"ndjson,omitempty"`
+	Count  int  `json:"count,omitempty"`
+}
+
+// 检查分块发送的配置，返回问题说明
+func lintChunked(prefix string, r Response) []string {
+	var problems []string
+	ch := r.Chunked
+	if ch == nil {
+		return nil
+	}
+	if r.Type == responseSSE {
+		problems = append(problems, prefix+".chunked: sse responses are already streamed, chunked is ignored")
+	}
+	if n, err := parseSize(ch.Size); ch.Size != "" && (err != nil || n == 0) {
+		problems = append(problems, fmt.Sprintf("%s.chunked.size: invalid size %q, the default is used", prefix, ch.Size))
+	}
+	if _, err := time.ParseDuration(ch.Delay); ch.Delay != "" && err != nil {
+		problems = append(problems, fmt.Sprintf("%s.chunked.delay: %v, chunks are sent without delay", prefix, err))
+	}
+	if ch.Count != 0 && !ch.NDJSON {
+		problems = append(problems, prefix+".chunked.count: only applies to ndjson")
+	}
+	return problems
+}
+
+// 分块发送 file 或响应体，客户端断开后停止。generate 按 body 生成一次数据
+func streamChunks(c *gin.Context, response Response, generate func() interface{}) {
+	ch := response.Chunked
+	var size int
+	if n, err := parseSize(ch.Size); ch.Size != "" && err == nil && n > 0 {
+		size = int(n)
+	} else if !ch.NDJSON || response.File != "" {
+		size = defaultChunkSize
+	}
+	delay, _ := time.ParseDuration(ch.Delay)
+
+	contentType := c.Writer.Header().Get("Content-Type")
+	var r io.Reader
+	switch {
+	case response.File != "":
+		f, err := os.Open(response.File)
+		if err != nil {
+			logger.Warn("读取响应文件失败", "file", response.File, "err", err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		defer f.Close()
+		r = f
+		if contentType == "" {
+			if contentType = mime.TypeByExtension(filepath.Ext(response.File)); contentType == "" {
+				contentType = "application/octet-stream"
+			}
+		}
+	case ch.NDJSON:
+		r = &ndjsonReader{body: response.Body, count: max(ch.Count, 1), generate: generate}
+		contentType = "application/x-ndjson"
+	default:
+		data := generate()
+		if text, ok := rawBody(c, data); ok {
+			r = bytes.NewReader([]byte(text))
+		} else {
+			encoded, _ := json.Marshal(data)
+			r = bytes.NewReader(encoded)
+			if contentType == "" {
+				contentType = "application/json; charset=utf-8"
+			}
+		}
+	}
+
+	status := response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(status)
+
+	ctx := c.Request.Context()
+	lines := bufio.NewReader(r)
+	buf := make([]byte, size)
+	for i := 0; ; i++ {
+		var chunk []byte
+		var err error
+		if size > 0 {
+			var n int
+			n, err = io.ReadFull(r, buf)
+			chunk = buf[:n]
+		} else {
+			chunk, err = lines.ReadBytes('\n')
+		}
+		if len(chunk) > 0 {
+			if i > 0 && delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			if _, werr := c.Writer.Write(chunk); werr != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				logger.Warn("分块发送失败", "path", c.Request.URL.Path, "err", err)
+			}
+			return
+		}
+	}
+}
+
+// ndjsonReader 按需生成 NDJSON：body 为数组时每个元素一行，否则每行按 body 重新生成，共 count 行
+type ndjsonReader struct {
+	body     interface{}
+	count    int
+	generate func() interface{}
+
+	items   []interface{}
+	written int
+	pending []byte
+}
+
+func (n *ndjsonReader) Read(p []byte) (int, error) {
+	for len(n.pending) == 0 {
+		if n.items == nil {
+			if _, ok := n.body.([]interface{}); ok {
+				// 数组只生成一次，之后逐个元素输出
+				n.items, _ = n.generate().([]interface{})
+				n.count = len(n.items)
+			} else {
+				n.items = []interface{}{}
+			}
+		}
+		if n.written >= n.count {
+			return 0, io.EOF
+		}
+		var line interface{}
+		if n.written < len(n.items) {
+			line = n.items[n.written]
+		} else {
+			line = n.generate()
+		}
+		n.written++
+		encoded, _ := json.Marshal(line)
+		n.pending = append(encoded, '\n')
+	}
+	copied := copy(p, n.pending)
+	n.pending = n.pending[copied:]
+	return copied, nil
+}
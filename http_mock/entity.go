@@ -1,14 +1,172 @@
 package http_mock
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+)
+
 type MockConfig struct {
 	Method   string                 `json:"method"`
 	URL      string                 `json:"url"`
 	Params   map[string]interface{} `json:"params"`
 	Req      map[string]interface{} `json:"req"`
 	Response Response               `json:"response"`
+	Cache    *Cache                 `json:"cache,omitempty"`
+	Fault    *Fault                 `json:"fault,omitempty"`
+	Expect   *Expect                `json:"expect,omitempty"`
+	Versions []Version              `json:"versions,omitempty"` // 按 Accept 或版本请求头返回不同的响应，都不匹配时使用 response
+	Matches  []BodyMatch            `json:"matches,omitempty"`  // 按请求体、query 参数和请求头返回不同的响应，优先于 versions
+	Table    *DataTable             `json:"table,omitempty"`    // 按路径参数从数据表中查找返回的数据
+	Resource *Resource              `json:"resource,omitempty"` // 有状态的 CRUD 资源，设置后 method 和响应相关的配置都不生效
+	Sequence *Sequence              `json:"sequence,omitempty"` // 按调用次数依次返回的响应，代替 response
+	State    *StateRule             `json:"state,omitempty"`    // 按场景状态响应和切换状态，多个路由组成有状态的流程
+}
+
+// Sequence 第 n 次调用返回第 n 个响应，用完之后返回 response，loop 为 true 时从第一个重新开始。
+// 只统计返回了序列或 response 的调用，命中 matches、versions 等的请求不计入，POST /__admin/reload 后从头开始
+type Sequence struct {
+	Responses []Response `json:"responses"`
+	Loop      bool       `json:"loop,omitempty"`
+}
+
+// 按调用次数（从 1 开始）选择响应，超出序列且不循环时返回 false
+func (s *Sequence) pick(hits int64) (int, bool) {
+	n := len(s.Responses)
+	if n == 0 || hits < 1 {
+		return 0, false
+	}
+	if i := hits - 1; i < int64(n) {
+		return int(i), true
+	} else if s.Loop {
+		return int(i % int64(n)), true
+	}
+	return 0, false
 }
 
 type Response struct {
 	StatusCode int         `json:"status_code"`
 	Body       interface{} `json:"body"`
+	File       string      `json:"file,omitempty"` // 返回文件内容而不是 body，支持 Range 和 If-Range
+	// Headers 响应头，Cookies 通过 Set-Cookie 设置的 cookie，值都可以使用 @ 占位符和模板表达式。
+	// 设置了不是 JSON 的 Content-Type 时字符串 body 原样返回
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies []CookieSpec      `json:"cookies,omitempty"`
+	// Fault 返回该响应时注入的故障：reset_connection、empty_response、malformed_json 或 random_bytes
+	Fault string `json:"fault,omitempty"`
+	// Type 为 sse 时以 text/event-stream 推送 events，没有 events 时每隔 interval 按 body 生成一个事件，
+	// 发送 count 个，count 为 0 时一直发送到客户端断开
+	Type     string     `json:"type,omitempty"`
+	Events   []SSEEvent `json:"events,omitempty"`
+	Interval string     `json:"interval,omitempty"` // 事件之间的间隔，默认 1s
+	Count    int        `json:"count,omitempty"`
+	// Chunked 分块发送响应体或 file，每块之间可以等待
+	Chunked *Chunked `json:"chunked,omitempty"`
+	// BodyFile 代替 body 的文件：JSON 文件作为 body（支持占位符和模板表达式），其他文件和 file 一样原样返回。
+	// ContentType 为 Content-Type 的简写，headers 中设置了时以 headers 为准
+	BodyFile    string `json:"body_file,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// 检查配置中不会生效的占位符和不合法的状态码，返回问题说明
+func lintConfig(config MockConfig) []string {
+	if config.Resource != nil {
+		return lintResource(config)
+	}
+	problems := value.Lint("response.body", config.Response.Body)
+	problems = append(problems, lintTemplates("response.body", config.Response.Body)...)
+	problems = append(problems, value.Lint("params", config.Params)...)
+	problems = append(problems, value.Lint("req", config.Req)...)
+	params := routeParams(config.URL)
+	problems = append(problems, lintParamRefs("response.body", config.Response.Body, params)...)
+	problems = append(problems, lintHeaders("response", config.Response, params)...)
+	problems = append(problems, lintResponse("response", config.Response)...)
+	if code := config.Response.StatusCode; code != 0 && (code < 100 || code > 599) {
+		problems = append(problems, fmt.Sprintf("response.status_code: %d is not a valid http status", code))
+	}
+	for i, v := range config.Versions {
+		prefix := fmt.Sprintf("versions[%d]", i)
+		problems = append(problems, value.Lint(prefix+".response.body", v.Response.Body)...)
+		problems = append(problems, lintTemplates(prefix+".response.body", v.Response.Body)...)
+		problems = append(problems, lintParamRefs(prefix+".response.body", v.Response.Body, params)...)
+		problems = append(problems, lintHeaders(prefix+".response", v.Response, params)...)
+		problems = append(problems, lintResponse(prefix+".response", v.Response)...)
+		if v.Accept == "" && !strings.Contains(v.Header, ":") {
+			problems = append(problems, prefix+": neither accept nor a \"Name: value\" header is set, the version is never served")
+		}
+	}
+	for i, m := range config.Matches {
+		if _, err := compileBodyMatch(m); err != nil {
+			problems = append(problems, fmt.Sprintf("matches[%d]: %v, the match is ignored", i, err))
+		}
+		prefix := fmt.Sprintf("matches[%d].response.body", i)
+		problems = append(problems, value.Lint(prefix, m.Response.Body)...)
+		problems = append(problems, lintTemplates(prefix, m.Response.Body)...)
+		problems = append(problems, lintParamRefs(prefix, m.Response.Body, params)...)
+		problems = append(problems, lintHeaders(fmt.Sprintf("matches[%d].response", i), m.Response, params)...)
+		problems = append(problems, lintResponse(fmt.Sprintf("matches[%d].response", i), m.Response)...)
+	}
+	if s := config.Sequence; s != nil {
+		if len(s.Responses) == 0 {
+			problems = append(problems, "sequence: responses is empty, response is always returned")
+		}
+		for i, r := range s.Responses {
+			prefix := fmt.Sprintf("sequence.responses[%d]", i)
+			problems = append(problems, value.Lint(prefix+".body", r.Body)...)
+			problems = append(problems, lintTemplates(prefix+".body", r.Body)...)
+			problems = append(problems, lintParamRefs(prefix+".body", r.Body, params)...)
+			problems = append(problems, lintHeaders(prefix, r, params)...)
+			problems = append(problems, lintResponse(prefix, r)...)
+			if code := r.StatusCode; code != 0 && (code < 100 || code > 599) {
+				problems = append(problems, fmt.Sprintf("%s.status_code: %d is not a valid http status", prefix, code))
+			}
+		}
+	}
+	if config.State != nil {
+		problems = append(problems, lintState(config.State, params)...)
+	}
+	if t := config.Table; t != nil {
+		param := t.Param
+		if param == "" {
+			param = t.Key
+		}
+		if t.File == "" || t.Key == "" {
+			problems = append(problems, "table: file and key are required, the table is ignored")
+		} else if !slices.Contains(params, param) {
+			problems = append(problems, fmt.Sprintf("table: url has no path parameter :%s, every request falls back to missing or generated rows", param))
+		}
+		if t.Missing != nil {
+			problems = append(problems, value.Lint("table.missing.body", t.Missing.Body)...)
+			problems = append(problems, lintTemplates("table.missing.body", t.Missing.Body)...)
+			problems = append(problems, lintParamRefs("table.missing.body", t.Missing.Body, params)...)
+			problems = append(problems, lintHeaders("table.missing", *t.Missing, params)...)
+		}
+	}
+	return problems
+}
+
+// 检查 SSE、分块发送和 body_file 的配置
+func lintResponse(prefix string, r Response) []string {
+	problems := lintSSE(prefix, r)
+	problems = append(problems, lintChunked(prefix, r)...)
+	return append(problems, lintBodyFile(prefix, r)...)
+}
+
+func lintResource(config MockConfig) []string {
+	var problems []string
+	if config.Response.Body != nil || config.Response.File != "" || len(config.Versions) > 0 || len(config.Matches) > 0 || config.Table != nil {
+		problems = append(problems, "resource: response, versions, matches and table are ignored for a resource")
+	}
+	if params := routeParams(config.URL); len(params) > 0 && params[len(params)-1] == resourceParam {
+		problems = append(problems, fmt.Sprintf("resource: url is the collection path and must not end with :%s", resourceParam))
+	}
+	return problems
+}
+
+func warnConfig(config MockConfig) {
+	for _, problem := range lintConfig(config) {
+		logger.Warn("mock 配置有问题", "method", config.Method, "url", config.URL, "problem", problem)
+	}
 }
@@ -0,0 +1,199 @@
+package http_mock
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Expect 对调用方的期望，违反时只记录下来，不影响响应。测试结束后通过 /__admin/assertions 检查
+type Expect struct {
+	MaxCalls    int64             `json:"max_calls"`    // 最多调用次数，0 表示不限制
+	MinCalls    int64             `json:"min_calls"`    // 至少调用次数，查询断言结果时检查
+	Headers     map[string]string `json:"headers"`      // 必须带的请求头，值支持 * 通配符，为空时只要求存在
+	Query       map[string]string `json:"query"`        // 必须带的 query 参数，规则和 headers 相同
+	MinInterval string            `json:"min_interval"` // 相邻两次调用的最小间隔，如 500ms，用于检查客户端的限速和退避
+}
+
+// Violation 一次违反期望的调用，min_calls 在查询时生成，没有 time 和 client
+type Violation struct {
+	Time   *time.Time `json:"time,omitempty"`
+	Method string     `json:"method"`
+	URL    string     `json:"url"`
+	Rule   string     `json:"rule"`
+	Detail string     `json:"detail"`
+	Client string     `json:"client,omitempty"`
+}
+
+// AssertionReport 断言结果，OK 为 false 时 Violations 不为空
+type AssertionReport struct {
+	OK         bool        `json:"ok"`
+	Checked    int         `json:"checked"` // 配置了 expect 的 mock 数
+	Violations []Violation `json:"violations"`
+}
+
+// 保留的违反记录条数
+const maxViolations = 1000
+
+type violationLog struct {
+	mu         sync.Mutex
+	violations []Violation
+	dropped    int
+}
+
+func (l *violationLog) add(v Violation) {
+	logger.Warn("违反 mock 期望", "method", v.Method, "url", v.URL, "rule", v.Rule, "detail", v.Detail, "client", v.Client)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.violations) >= maxViolations {
+		l.dropped++
+		return
+	}
+	l.violations = append(l.violations, v)
+}
+
+func (l *violationLog) list() ([]Violation, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Violation{}, l.violations...), l.dropped
+}
+
+func (l *violationLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.violations, l.dropped = nil, 0
+}
+
+// expectation 解析后的期望，MinInterval 无效时忽略
+type expectation struct {
+	Expect
+	minInterval time.Duration
+}
+
+func newExpectation(config MockConfig) *expectation {
+	if config.Expect == nil {
+		return nil
+	}
+	e := &expectation{Expect: *config.Expect}
+	if e.MinInterval != "" {
+		d, err := time.ParseDuration(e.MinInterval)
+		if err != nil {
+			logger.Warn("无效的 min_interval，已忽略", "value", e.MinInterval, "url", config.URL)
+		}
+		e.minInterval = d
+	}
+	return e
+}
+
+// 检查一次调用，hits 是包括本次在内的调用次数，previous 是上一次调用的时间
+func (e *expectation) check(c *gin.Context, config MockConfig, hits int64, previous time.Time, log *violationLog) {
+	now := time.Now()
+	report := func(rule, detail string) {
+		log.add(Violation{Time: &now, Method: strings.ToUpper(config.Method), URL: config.URL, Rule: rule, Detail: detail, Client: c.ClientIP()})
+	}
+	if e.MaxCalls > 0 && hits > e.MaxCalls {
+		report("max_calls", fmt.Sprintf("call %d exceeds max_calls %d", hits, e.MaxCalls))
+	}
+	if e.minInterval > 0 && !previous.IsZero() {
+		if gap := now.Sub(previous); gap < e.minInterval {
+			report("min_interval", fmt.Sprintf("called %s after the previous call, min_interval is %s", gap.Round(time.Millisecond), e.minInterval))
+		}
+	}
+	for _, name := range sortedKeys(e.Headers) {
+		if detail := missing("header", name, e.Headers[name], c.Request.Header.Values(name)); detail != "" {
+			report("header", detail)
+		}
+	}
+	query := c.Request.URL.Query()
+	for _, name := range sortedKeys(e.Query) {
+		if detail := missing("query parameter", name, e.Query[name], query[name]); detail != "" {
+			report("query", detail)
+		}
+	}
+}
+
+// 值不满足时返回说明
+func missing(kind, name, pattern string, values []string) string {
+	if len(values) == 0 {
+		return fmt.Sprintf("%s %s is missing", kind, name)
+	}
+	if pattern == "" {
+		return ""
+	}
+	for _, v := range values {
+		if ok, _ := path.Match(pattern, v); ok {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s %s is %q, want %q", kind, name, values[0], pattern)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Assertions 返回目前为止违反期望的调用，以及调用次数少于 min_calls 的 mock
+func (h *HttpMockHandler) Assertions() AssertionReport {
+	violations, dropped := h.violations.list()
+	report := AssertionReport{Violations: violations}
+	for _, config := range h.Configs() {
+		if config.Expect == nil {
+			continue
+		}
+		report.Checked++
+		if hits := h.usage.route(config.Method, config.URL).hits.Load(); hits < config.Expect.MinCalls {
+			report.Violations = append(report.Violations, Violation{
+				Method: strings.ToUpper(config.Method), URL: config.URL, Rule: "min_calls",
+				Detail: fmt.Sprintf("called %d times, min_calls is %d", hits, config.Expect.MinCalls),
+			})
+		}
+	}
+	if dropped > 0 {
+		report.Violations = append(report.Violations, Violation{Rule: "dropped", Detail: fmt.Sprintf("%d more violations were not kept", dropped)})
+	}
+	report.OK = len(report.Violations) == 0
+	return report
+}
+
+// 停止时输出断言结果，调用过程中的违反已经在发生时输出
+func (h *HttpMockHandler) reportAssertions() {
+	report := h.Assertions()
+	if report.Checked == 0 {
+		return
+	}
+	for _, v := range report.Violations {
+		if v.Time == nil {
+			logger.Warn("违反 mock 期望", "method", v.Method, "url", v.URL, "rule", v.Rule, "detail", v.Detail)
+		}
+	}
+	logger.Info("mock 断言结果", "checked", report.Checked, "ok", report.OK, "violations", len(report.Violations))
+}
+
+// 有违反时返回 417，测试脚本可以直接用 curl -f 检查
+func (a *adminAPI) getAssertions(c *gin.Context) {
+	report := a.mocks.Assertions()
+	code := http.StatusOK
+	if !report.OK {
+		code = http.StatusExpectationFailed
+	}
+	c.JSON(code, report)
+}
+
+// 清空违反记录和调用次数，场景回到初始状态，开始新一轮测试
+func (a *adminAPI) resetAssertions(c *gin.Context) {
+	a.mocks.violations.clear()
+	a.mocks.usage.reset()
+	a.mocks.states.reset()
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,276 @@
+package http_mock
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	mathrand "math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 连接级故障类型
+const (
+	FaultReset             = "reset"               // 不返回任何内容，直接发送 RST
+	FaultClose             = "close"               // 不返回任何内容，正常关闭连接
+	FaultResetMidBody      = "reset_mid_body"      // 返回响应头和 after 字节的响应体后发送 RST
+	FaultHalfClose         = "half_close"          // 返回响应头和 after 字节的响应体后关闭写方向（FIN），不再发送剩余内容
+	FaultStallAfterHeaders = "stall_after_headers" // 返回响应头后不再发送任何内容，直到 duration 到期或客户端断开
+)
+
+// Fault 连接级故障，绕过 HTTP 层直接操作 TCP 连接，用于测试客户端对异常连接的处理
+type Fault struct {
+	Type        string  `json:"type"`
+	Probability float64 `json:"probability"` // 触发概率，0 或不设置表示每次都触发
+	After       int     `json:"after"`       // reset_mid_body 和 half_close 在故障前发送的响应体字节数
+	Duration    string  `json:"duration"`    // stall_after_headers 和 half_close 保持连接的时长，默认 1m
+}
+
+var faultTypes = map[string]bool{
+	FaultReset: true, FaultClose: true, FaultResetMidBody: true, FaultHalfClose: true, FaultStallAfterHeaders: true,
+}
+
+// 响应级故障类型，设置在 response 的 fault 中，每次返回该响应时都触发，和 sequence、matches 组合可以得到确定的故障序列
+const (
+	FaultResetConnection = "reset_connection" // 不返回任何内容，直接发送 RST
+	FaultEmptyResponse   = "empty_response"   // 不返回任何内容，正常关闭连接
+	FaultMalformedJSON   = "malformed_json"   // 状态码和响应头正常，响应体是截断后混入非法字符的 JSON
+	FaultRandomBytes     = "random_bytes"     // 返回一段随机字节（不是 HTTP 响应）后关闭连接
+)
+
+var responseFaults = map[string]bool{
+	FaultResetConnection: true, FaultEmptyResponse: true, FaultMalformedJSON: true, FaultRandomBytes: true,
+}
+
+// 返回响应级故障，status 和 body 为正常情况下的响应
+func writeResponseFault(c *gin.Context, fault string, status int, body []byte) {
+	logger.Debug("注入响应故障", "type", fault, "path", c.Request.URL.Path)
+	if fault == FaultMalformedJSON {
+		// 保留前一半让客户端开始解析，再接上不能出现在 JSON 中的控制字符
+		data := append(append([]byte(nil), body[:len(body)/2]...), "\x00\x1b{\"\xff"...)
+		c.Data(status, "application/json; charset=utf-8", data)
+		return
+	}
+	conn, buf, err := c.Writer.Hijack()
+	if err != nil {
+		logger.Warn("无法接管连接，故障未注入", "type", fault, "err", err)
+		c.Data(status, "application/json; charset=utf-8", body)
+		return
+	}
+	defer conn.Close()
+	switch fault {
+	case FaultResetConnection:
+		resetConn(conn)
+	case FaultRandomBytes:
+		garbage := make([]byte, 256+mathrand.IntN(1024))
+		for i := range garbage {
+			garbage[i] = byte(mathrand.IntN(256))
+		}
+		buf.Write(garbage)
+		buf.Flush()
+	}
+}
+
+func (f *Fault) triggered() bool {
+	return f.Probability <= 0 || mathrand.Float64() < f.Probability
+}
+
+// 注入连接故障时的正常响应体：file 为文件内容，其他响应为生成的响应体
+func faultBody(c *gin.Context, response Response, generate func() interface{}) []byte {
+	if response.File != "" {
+		data, err := os.ReadFile(response.File)
+		if err != nil {
+			logger.Warn("读取响应文件失败", "file", response.File, "err", err)
+		}
+		return data
+	}
+	data, _ := encodeBody(c, generate())
+	return data
+}
+
+// 接管连接并按故障类型返回部分响应，status 和 body 为正常情况下的响应
+func injectFault(c *gin.Context, f *Fault, status int, body []byte) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	conn, buf, err := c.Writer.Hijack()
+	if err != nil {
+		logger.Warn("无法接管连接，故障未注入", "type", f.Type, "err", err)
+		c.Data(status, "application/json; charset=utf-8", body)
+		return
+	}
+	defer conn.Close()
+	logger.Debug("注入连接故障", "type", f.Type, "path", c.Request.URL.Path)
+
+	switch f.Type {
+	case FaultReset:
+		resetConn(conn)
+		return
+	case FaultClose:
+		return
+	}
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\n\r\n", status, http.StatusText(status), len(body))
+	if f.Type != FaultStallAfterHeaders {
+		buf.Write(body[:min(max(f.After, 0), len(body))])
+	}
+	buf.Flush()
+
+	switch f.Type {
+	case FaultResetMidBody:
+		resetConn(conn)
+	case FaultHalfClose:
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		waitClient(conn, buf, f.Duration)
+	case FaultStallAfterHeaders:
+		waitClient(conn, buf, f.Duration)
+	}
+}
+
+// 设置 SO_LINGER 为 0 后关闭，内核发送 RST 而不是 FIN。TLS 连接对底层的 TCP 连接操作
+func resetConn(conn net.Conn) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// 保持连接直到客户端断开或超时
+func waitClient(conn net.Conn, buf *bufio.ReadWriter, duration string) {
+	timeout := time.Minute
+	if d, err := time.ParseDuration(duration); err == nil && d > 0 {
+		timeout = d
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	io.Copy(io.Discard, buf)
+}
+
+// TLS 握手阶段的故障，通过包装 TCP listener 在握手前接管连接
+type tlsFault struct {
+	alert       byte // 返回的 TLS alert，reset 为 true 时不使用
+	reset       bool
+	probability float64
+}
+
+// TLS alert 名称和编号，见 RFC 8446 6.2
+var tlsAlerts = map[string]byte{
+	"close_notify":            0,
+	"unexpected_message":      10,
+	"bad_record_mac":          20,
+	"handshake_failure":       40,
+	"bad_certificate":         42,
+	"unsupported_certificate": 43,
+	"certificate_revoked":     44,
+	"certificate_expired":     45,
+	"certificate_unknown":     46,
+	"illegal_parameter":       47,
+	"unknown_ca":              48,
+	"access_denied":           49,
+	"decode_error":            50,
+	"decrypt_error":           51,
+	"protocol_version":        70,
+	"insufficient_security":   71,
+	"internal_error":          80,
+	"inappropriate_fallback":  86,
+	"unrecognized_name":       112,
+	"no_application_protocol": 120,
+}
+
+// 解析 -tls-fault，格式为 <alert 名称|reset>[:概率]，如 handshake_failure:0.3
+func parseTLSFault(s string) (*tlsFault, error) {
+	name, probability, hasProbability := strings.Cut(s, ":")
+	f := &tlsFault{reset: name == FaultReset}
+	if !f.reset {
+		alert, ok := tlsAlerts[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls alert %q", name)
+		}
+		f.alert = alert
+	}
+	if hasProbability {
+		p, err := strconv.ParseFloat(probability, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid probability %q", probability)
+		}
+		f.probability = p
+	}
+	return f, nil
+}
+
+// faultListener 按概率让部分连接在 TLS 握手时失败，其余连接正常交给 tls.Listener
+type faultListener struct {
+	net.Listener
+	fault *tlsFault
+}
+
+func (l *faultListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.fault.probability > 0 && mathrand.Float64() >= l.fault.probability {
+			return conn, nil
+		}
+		go l.fail(conn)
+	}
+}
+
+// 读取 ClientHello 后返回 fatal alert 记录并关闭连接
+func (l *faultListener) fail(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	io.CopyN(io.Discard, conn, int64(header[3])<<8|int64(header[4]))
+	if l.fault.reset {
+		resetConn(conn)
+		return
+	}
+	// 记录类型 21（alert），版本 TLS 1.2，长度 2，级别 2（fatal）
+	conn.Write([]byte{21, 3, 3, 0, 2, 2, l.fault.alert})
+	logger.Debug("TLS 握手故障", "client", conn.RemoteAddr().String(), "alert", l.fault.alert)
+}
+
+// 生成 localhost 的自签名证书
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "mockgo"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,187 @@
+package http_mock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 返回本地文件，Range、If-Range 和 Last-Modified 由 http.ServeContent 处理，Content-Type 按扩展名判断
+func serveFile(c *gin.Context, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Warn("读取响应文件失败", "file", path, "err", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), f)
+}
+
+// 内置的大文件下载路由，如 /__files/500MB?seed=1
+const filesPrefix = "/__files"
+
+// 文件内容按块生成，每块由种子和块序号决定，任意偏移都能直接读取
+const fileBlockSize = 64 << 10
+
+// 单位按 1024 进制计算
+var sizeUnits = map[string]int64{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	"T": 1 << 40, "TB": 1 << 40, "TIB": 1 << 40,
+}
+
+// 解析 500MB、1.5G、1024 这样的大小
+func parseSize(text string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(text))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := sizeUnits[strings.TrimSpace(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || n < 0 || n*float64(unit) > float64(1<<50) {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// randomFile 由种子决定内容的伪随机文件，实现 io.ReadSeeker 供 http.ServeContent 处理 Range
+type randomFile struct {
+	size   int64
+	seed   uint64
+	offset int64
+
+	block    int64 // 当前缓存的块序号
+	blockBuf []byte
+}
+
+func newRandomFile(size int64, seed uint64) *randomFile {
+	return &randomFile{size: size, seed: seed, block: -1, blockBuf: make([]byte, fileBlockSize)}
+}
+
+func (f *randomFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && f.offset < f.size {
+		block := f.offset / fileBlockSize
+		if block != f.block {
+			f.fill(block)
+		}
+		start := f.offset % fileBlockSize
+		end := int64(fileBlockSize)
+		if remain := f.size - block*fileBlockSize; remain < end {
+			end = remain
+		}
+		copied := copy(p[n:], f.blockBuf[start:end])
+		n += copied
+		f.offset += int64(copied)
+	}
+	return n, nil
+}
+
+func (f *randomFile) fill(block int64) {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:8], f.seed)
+	binary.LittleEndian.PutUint64(key[8:16], uint64(block))
+	rand.NewChaCha8(key).Read(f.blockBuf)
+	f.block = block
+}
+
+func (f *randomFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// throttledWriter 按速率限制写出，用于模拟慢速下载
+type throttledWriter struct {
+	http.ResponseWriter
+	rate    int64 // 每秒字节数
+	start   time.Time
+	written int64
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		chunk := p[n:]
+		if max := int(w.rate / 10); max > 0 && len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		written, err := w.ResponseWriter.Write(chunk)
+		n += written
+		w.written += int64(written)
+		if err != nil {
+			return n, err
+		}
+		if wait := time.Duration(float64(w.written)/float64(w.rate)*float64(time.Second)) - time.Since(w.start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	return n, nil
+}
+
+// 返回指定大小的伪随机文件，支持 Range 和 If-Range。
+// seed 决定文件内容（默认 0），rate 限制下载速度（如 1MB，表示每秒 1MB）
+func handleFile(c *gin.Context) {
+	size, err := parseSize(c.Param("size"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seed, err := strconv.ParseUint(c.DefaultQuery("seed", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid seed"})
+		return
+	}
+	var w http.ResponseWriter = c.Writer
+	if rate := c.Query("rate"); rate != "" {
+		bytesPerSecond, err := parseSize(rate)
+		if err != nil || bytesPerSecond <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rate"})
+			return
+		}
+		w = &throttledWriter{ResponseWriter: c.Writer, rate: bytesPerSecond, start: time.Now()}
+	}
+
+	name := fmt.Sprintf("%d-%d.bin", size, seed)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	// 内容只由大小和种子决定，ETag 固定，断点续传时 If-Range 可以校验
+	c.Header("ETag", fmt.Sprintf(`"%d-%d"`, size, seed))
+	http.ServeContent(w, c.Request, name, time.Time{}, newRandomFile(size, seed))
+}
@@ -0,0 +1,373 @@
+package http_mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// templateFunc 模板函数，args 是参数说明，maxArgs 为 -1 时不限制参数个数
+type templateFunc struct {
+	args             string
+	minArgs, maxArgs int
+	call             func(args []interface{}) (interface{}, error)
+}
+
+// 模板函数库。字符串参数可以传入任意值，按 toText 转换；数字和时间参数按 toNumber、toTime 转换
+var templateFuncs = map[string]templateFunc{
+	// 字符串
+	"upper":  stringFunc(strings.ToUpper),
+	"lower":  stringFunc(strings.ToLower),
+	"title":  stringFunc(titleCase),
+	"camel":  stringFunc(camelCase),
+	"snake":  stringFunc(func(s string) string { return joinWords(s, "_") }),
+	"kebab":  stringFunc(func(s string) string { return joinWords(s, "-") }),
+	"trim":   stringFunc(strings.TrimSpace),
+	"substr": {"s, start[, end]", 2, 3, substr},
+	"replace": {"s, old, new", 3, 3, func(args []interface{}) (interface{}, error) {
+		return strings.ReplaceAll(toText(args[0]), toText(args[1]), toText(args[2])), nil
+	}},
+	"concat": {"values...", 1, -1, func(args []interface{}) (interface{}, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(toText(arg))
+		}
+		return b.String(), nil
+	}},
+	"len": {"v", 1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		}
+		return float64(len([]rune(toText(args[0])))), nil
+	}},
+	"default": {"v, fallback", 2, 2, func(args []interface{}) (interface{}, error) {
+		if args[0] == nil || args[0] == "" {
+			return args[1], nil
+		}
+		return args[0], nil
+	}},
+
+	// 数学运算，结果都是数字
+	"add":   mathFunc(func(a, b float64) (float64, error) { return a + b, nil }),
+	"sub":   mathFunc(func(a, b float64) (float64, error) { return a - b, nil }),
+	"mul":   mathFunc(func(a, b float64) (float64, error) { return a * b, nil }),
+	"div":   mathFunc(divide(func(a, b float64) float64 { return a / b })),
+	"mod":   mathFunc(divide(math.Mod)),
+	"min":   mathFunc(func(a, b float64) (float64, error) { return math.Min(a, b), nil }),
+	"max":   mathFunc(func(a, b float64) (float64, error) { return math.Max(a, b), nil }),
+	"abs":   numberFunc(math.Abs),
+	"floor": numberFunc(math.Floor),
+	"ceil":  numberFunc(math.Ceil),
+	"round": {"x[, digits]", 1, 2, func(args []interface{}) (interface{}, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		digits := 0.0
+		if len(args) == 2 {
+			if digits, err = toNumber(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		scale := math.Pow(10, digits)
+		return math.Round(x*scale) / scale, nil
+	}},
+	"number": numberFunc(func(x float64) float64 { return x }),
+
+	// 时间，输出时按 RFC 3339 格式化
+	"now": {"", 0, 0, func([]interface{}) (interface{}, error) {
+		return time.Now(), nil
+	}},
+	"dateAdd": {"t, duration", 2, 2, func(args []interface{}) (interface{}, error) {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		d, err := parseDuration(toText(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		return t.Add(d), nil
+	}},
+	"dateFormat": {"t, layout", 2, 2, func(args []interface{}) (interface{}, error) {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		switch layout := toText(args[1]); layout {
+		case "unix":
+			return float64(t.Unix()), nil
+		case "unixms":
+			return float64(t.UnixMilli()), nil
+		default:
+			return t.Format(layout), nil
+		}
+	}},
+	"dateDiff": {"a, b", 2, 2, func(args []interface{}) (interface{}, error) {
+		a, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		b, err := toTime(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return a.Sub(b).Seconds(), nil
+	}},
+
+	// 编码
+	"base64": stringFunc(func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }),
+	"base64Decode": {"s", 1, 1, func(args []interface{}) (interface{}, error) {
+		data, err := base64.StdEncoding.DecodeString(toText(args[0]))
+		if err != nil {
+			if data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(toText(args[0]), "=")); err != nil {
+				return nil, err
+			}
+		}
+		return string(data), nil
+	}},
+	"urlEncode": stringFunc(url.QueryEscape),
+	"urlDecode": {"s", 1, 1, func(args []interface{}) (interface{}, error) {
+		return url.QueryUnescape(toText(args[0]))
+	}},
+	"json": {"v", 1, 1, func(args []interface{}) (interface{}, error) {
+		data, err := json.Marshal(args[0])
+		return string(data), err
+	}},
+	"parseJson": {"s", 1, 1, func(args []interface{}) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal([]byte(toText(args[0])), &v)
+		return v, err
+	}},
+}
+
+func stringFunc(fn func(string) string) templateFunc {
+	return templateFunc{"s", 1, 1, func(args []interface{}) (interface{}, error) {
+		return fn(toText(args[0])), nil
+	}}
+}
+
+func numberFunc(fn func(float64) float64) templateFunc {
+	return templateFunc{"x", 1, 1, func(args []interface{}) (interface{}, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(x), nil
+	}}
+}
+
+// 两个及以上参数依次计算，如 add(1, 2, 3)
+func mathFunc(fn func(a, b float64) (float64, error)) templateFunc {
+	return templateFunc{"a, b...", 2, -1, func(args []interface{}) (interface{}, error) {
+		result, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range args[1:] {
+			x, err := toNumber(arg)
+			if err != nil {
+				return nil, err
+			}
+			if result, err = fn(result, x); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}}
+}
+
+func divide(fn func(a, b float64) float64) func(a, b float64) (float64, error) {
+	return func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return fn(a, b), nil
+	}
+}
+
+// 按字符截取，下标为负数时从末尾开始，超出范围时截断
+func substr(args []interface{}) (interface{}, error) {
+	runes := []rune(toText(args[0]))
+	bound := func(v interface{}) (int, error) {
+		n, err := toNumber(v)
+		if err != nil {
+			return 0, err
+		}
+		i := int(n)
+		if i < 0 {
+			i += len(runes)
+		}
+		return min(max(i, 0), len(runes)), nil
+	}
+	start, err := bound(args[1])
+	if err != nil {
+		return nil, err
+	}
+	end := len(runes)
+	if len(args) == 3 {
+		if end, err = bound(args[2]); err != nil {
+			return nil, err
+		}
+	}
+	if start >= end {
+		return "", nil
+	}
+	return string(runes[start:end]), nil
+}
+
+// 按分隔符和大小写变化拆分单词，如 orderID、order_id、Order-Id 都拆分为 order 和 id
+func splitWords(s string) []string {
+	var words []string
+	var current []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(current) > 0 {
+				words = append(words, string(current))
+				current = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || unicode.IsUpper(previous) && nextLower {
+				words = append(words, string(current))
+				current = nil
+			}
+		}
+		current = append(current, unicode.ToLower(r))
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+	return words
+}
+
+func joinWords(s, sep string) string {
+	return strings.Join(splitWords(s), sep)
+}
+
+func camelCase(s string) string {
+	words := splitWords(s)
+	for i := 1; i < len(words); i++ {
+		words[i] = capitalize(words[i])
+	}
+	return strings.Join(words, "")
+}
+
+// 每个空白分隔的单词首字母大写，其余不变
+func titleCase(s string) string {
+	fields := strings.Fields(s)
+	for i, field := range fields {
+		fields[i] = capitalize(field)
+	}
+	return strings.Join(fields, " ")
+}
+
+func capitalize(s string) string {
+	for i, r := range s {
+		return string(unicode.ToUpper(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
+
+// 模板值转换为文本：字符串原样返回，null 为空字符串，时间按 RFC 3339 格式化，对象和数组按 JSON 编码
+func toText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func toNumber(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return n, nil
+	case time.Time:
+		return float64(v.Unix()), nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// 支持的时间文本格式
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", time.RFC1123, time.RFC1123Z}
+
+// 时间可以是 now() 的结果、上面格式的文本或 Unix 秒数
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			return time.Unix(n, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%v is not a time", v)
+}
+
+// 在 time.ParseDuration 的基础上支持 d 表示天，如 7d、-1d12h
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	sign := time.Duration(1)
+	rest := s
+	if strings.HasPrefix(rest, "-") {
+		sign, rest = -1, rest[1:]
+	}
+	var days time.Duration
+	if i := strings.IndexByte(rest, 'd'); i > 0 {
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		days, rest = time.Duration(n)*24*time.Hour, rest[i+1:]
+	}
+	var d time.Duration
+	if rest != "" {
+		var err error
+		if d, err = time.ParseDuration(rest); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	return sign * (days + d), nil
+}
@@ -0,0 +1,112 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/gin-gonic/gin"
+)
+
+// CookieSpec 响应中通过 Set-Cookie 设置的 cookie，value 可以使用 @ 占位符和模板表达式
+type CookieSpec struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	MaxAge   int    `json:"max_age,omitempty"` // 秒，0 为会话 cookie，负数删除 cookie
+	Secure   bool   `json:"secure,omitempty"`
+	HttpOnly bool   `json:"http_only,omitempty"`
+	SameSite string `json:"same_site,omitempty"` // lax、strict 或 none
+}
+
+var sameSiteModes = map[string]http.SameSite{
+	"":       http.SameSiteDefaultMode,
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+func (s CookieSpec) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.Name,
+		Value:    value,
+		Path:     s.Path,
+		Domain:   s.Domain,
+		MaxAge:   s.MaxAge,
+		Secure:   s.Secure,
+		HttpOnly: s.HttpOnly,
+		SameSite: sameSiteModes[strings.ToLower(s.SameSite)],
+	}
+}
+
+// 设置响应头和 cookie，值中的占位符和模板表达式每次请求重新生成
+func (h *HttpMockHandler) writeHeaders(c *gin.Context, response Response, body requestBody) {
+	for name, v := range response.Headers {
+		c.Header(name, h.dynamicText(v, body))
+	}
+	for _, spec := range response.Cookies {
+		http.SetCookie(c.Writer, spec.cookie(h.dynamicText(spec.Value, body)))
+	}
+}
+
+func (h *HttpMockHandler) dynamicText(text string, body requestBody) string {
+	v := h.valueHandler.ProcessDynamicValues(text)
+	if hasTemplates(v) {
+		v = renderTemplates(v, body)
+	}
+	return toText(v)
+}
+
+// 设置了不是 JSON 的 Content-Type 时，字符串响应体原样返回，如 text/plain、text/html 和 application/xml
+func rawBody(c *gin.Context, body interface{}) (string, bool) {
+	text, ok := body.(string)
+	if !ok {
+		return "", false
+	}
+	contentType := c.Writer.Header().Get("Content-Type")
+	if contentType == "" {
+		return "", false
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return text, mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")
+}
+
+// 编码响应体，返回内容和 Content-Type：rawBody 的字符串原样返回，其他值序列化为 JSON
+func encodeBody(c *gin.Context, body interface{}) ([]byte, string) {
+	if text, ok := rawBody(c, body); ok {
+		return []byte(text), c.Writer.Header().Get("Content-Type")
+	}
+	data, _ := json.Marshal(body)
+	return data, "application/json; charset=utf-8"
+}
+
+// 检查响应头和 cookie 的名称，以及值中不会生效的占位符
+func lintHeaders(path string, response Response, params []string) []string {
+	var problems []string
+	for name, v := range response.Headers {
+		prefix := fmt.Sprintf("%s.headers[%q]", path, name)
+		if name == "" || strings.ContainsAny(name, " \t:\r\n") {
+			problems = append(problems, prefix+": invalid header name, the header is dropped")
+		}
+		problems = append(problems, value.Lint(prefix, v)...)
+		problems = append(problems, lintTemplates(prefix, v)...)
+		problems = append(problems, lintParamRefs(prefix, v, params)...)
+	}
+	for i, cookie := range response.Cookies {
+		prefix := fmt.Sprintf("%s.cookies[%d]", path, i)
+		if cookie.Name == "" || strings.ContainsAny(cookie.Name, " \t;=,\r\n") {
+			problems = append(problems, prefix+": invalid cookie name, the cookie is dropped")
+		}
+		if _, ok := sameSiteModes[strings.ToLower(cookie.SameSite)]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: same_site %q is not lax, strict or none", prefix, cookie.SameSite))
+		}
+		problems = append(problems, value.Lint(prefix+".value", cookie.Value)...)
+		problems = append(problems, lintTemplates(prefix+".value", cookie.Value)...)
+		problems = append(problems, lintParamRefs(prefix+".value", cookie.Value, params)...)
+	}
+	return problems
+}
@@ -0,0 +1,562 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RunImport 把 WireMock 的 stub mapping 或 Mockoon 的 environment 文件转换为 mock 配置，
+// args 为命令行参数（不含命令名），返回进程退出码。无法转换的部分（响应头、延迟、匹配规则等）输出警告后忽略
+func RunImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "", "source format: wiremock or mockoon, detected from the file when empty")
+	output := fs.String("o", "", "output file, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		logger.Error("no input files, pass wiremock mapping files or directories, or mockoon environment files")
+		return 2
+	}
+	if *from != "" && *from != "wiremock" && *from != "mockoon" {
+		logger.Error("unsupported -from", "from", *from)
+		return 2
+	}
+
+	var configs []MockConfig
+	for _, path := range fs.Args() {
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				logger.Error("读取目录失败", "path", path, "err", err)
+				return 1
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			converted, err := importFile(file, *from)
+			if err != nil {
+				logger.Error("转换失败", "file", file, "err", err)
+				return 1
+			}
+			logger.Info("转换完成", "file", file, "mocks", len(converted))
+			configs = append(configs, converted...)
+		}
+	}
+	configs = dedupeConfigs(configs)
+
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		logger.Error("序列化失败", "err", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		logger.Error("写入失败", "file", *output, "err", err)
+		return 1
+	}
+	logger.Info("已写入 mock 配置", "file", *output, "mocks", len(configs))
+	return 0
+}
+
+func importFile(file, from string) ([]MockConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if from == "" {
+		// Mockoon 的 environment 有 routes 字段，WireMock 的文件是单个 mapping 或 mappings 列表
+		from = "wiremock"
+		if _, ok := doc["routes"]; ok {
+			from = "mockoon"
+		}
+	}
+	if from == "mockoon" {
+		return ConvertMockoon(data)
+	}
+	// WireMock 的 bodyFileName 相对于 mappings 同级的 __files 目录
+	files := filepath.Join(filepath.Dir(filepath.Dir(file)), "__files")
+	return ConvertWireMock(data, files)
+}
+
+// 方法和路径相同的配置只保留第一个，gin 不允许重复注册路由
+func dedupeConfigs(configs []MockConfig) []MockConfig {
+	seen := make(map[string]bool)
+	result := make([]MockConfig, 0, len(configs))
+	for _, config := range configs {
+		key := strings.ToUpper(config.Method) + " " + config.URL
+		if seen[key] {
+			logger.Warn("方法和路径相同的配置只保留第一个", "method", config.Method, "url", config.URL)
+			continue
+		}
+		seen[key] = true
+		result = append(result, config)
+	}
+	return result
+}
+
+type wireMockFile struct {
+	Mappings []wireMockMapping `json:"mappings"`
+}
+
+type wireMockMapping struct {
+	Name     string           `json:"name"`
+	Priority int              `json:"priority"`
+	Request  wireMockRequest  `json:"request"`
+	Response wireMockResponse `json:"response"`
+}
+
+type wireMockRequest struct {
+	Method          string                            `json:"method"`
+	URL             string                            `json:"url"`
+	URLPath         string                            `json:"urlPath"`
+	URLPattern      string                            `json:"urlPattern"`
+	URLPathPattern  string                            `json:"urlPathPattern"`
+	URLPathTemplate string                            `json:"urlPathTemplate"`
+	QueryParameters map[string]map[string]interface{} `json:"queryParameters"`
+	BodyPatterns    []map[string]interface{}          `json:"bodyPatterns"`
+}
+
+type wireMockResponse struct {
+	Status       int               `json:"status"`
+	JSONBody     interface{}       `json:"jsonBody"`
+	Body         *string           `json:"body"`
+	BodyFileName string            `json:"bodyFileName"`
+	Headers      map[string]string `json:"headers"`
+	FixedDelay   int               `json:"fixedDelayMilliseconds"`
+	Fault        string            `json:"fault"`
+}
+
+// ConvertWireMock 转换 WireMock 的 stub mapping，data 可以是单个 mapping 或 {"mappings": [...]}，
+// files 为 bodyFileName 所在的目录。priority 数值小的优先，方法和路径相同时只保留优先的一个
+func ConvertWireMock(data []byte, files string) ([]MockConfig, error) {
+	var file wireMockFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Mappings == nil {
+		var mapping wireMockMapping
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return nil, err
+		}
+		file.Mappings = []wireMockMapping{mapping}
+	}
+	sort.SliceStable(file.Mappings, func(i, j int) bool {
+		return wireMockPriority(file.Mappings[i]) < wireMockPriority(file.Mappings[j])
+	})
+
+	var configs []MockConfig
+	for _, m := range file.Mappings {
+		url, params, ok := wireMockURL(m.Request)
+		if !ok {
+			logger.Warn("无法转换 WireMock 的 url 匹配，已跳过", "name", m.Name, "request", m.Request)
+			continue
+		}
+		for name, matcher := range m.Request.QueryParameters {
+			if v, ok := matcher["equalTo"]; ok {
+				params[name] = v
+			}
+		}
+		if len(params) == 0 {
+			params = nil
+		}
+		var req map[string]interface{}
+		for _, pattern := range m.Request.BodyPatterns {
+			if v, ok := pattern["equalToJson"]; ok {
+				if s, isString := v.(string); isString {
+					json.Unmarshal([]byte(s), &v)
+				}
+				req, _ = v.(map[string]interface{})
+			}
+		}
+
+		res := m.Response
+		if res.Fault != "" || res.FixedDelay > 0 {
+			logger.Warn("不支持 WireMock 的 fault 和延迟，已忽略", "url", url, "fault", res.Fault, "delay_ms", res.FixedDelay)
+		}
+		headers := importHeaders(res.Headers)
+		var body interface{}
+		switch {
+		case res.JSONBody != nil:
+			body = convertTemplates(res.JSONBody)
+		case res.Body != nil:
+			body = parseBody(*res.Body)
+		case res.BodyFileName != "":
+			content, err := os.ReadFile(filepath.Join(files, res.BodyFileName))
+			if err != nil {
+				return nil, fmt.Errorf("mapping %s: %v", m.Name, err)
+			}
+			body = parseBody(string(content))
+		}
+
+		status := res.Status
+		if status == 0 {
+			status = 200
+		}
+		for _, method := range expandMethod(m.Request.Method, "ANY") {
+			configs = append(configs, MockConfig{
+				Method:   method,
+				URL:      url,
+				Params:   params,
+				Req:      req,
+				Response: Response{StatusCode: status, Body: body, Headers: headers},
+			})
+		}
+	}
+	return configs, nil
+}
+
+// 没有设置 priority 时 WireMock 按 5 处理
+func wireMockPriority(m wireMockMapping) int {
+	if m.Priority == 0 {
+		return 5
+	}
+	return m.Priority
+}
+
+// 转换 WireMock 的 url 匹配为 gin 路由，url 中的 query 作为 params 返回
+func wireMockURL(r wireMockRequest) (string, map[string]interface{}, bool) {
+	params := make(map[string]interface{})
+	switch {
+	case r.URL != "":
+		path, query, _ := strings.Cut(r.URL, "?")
+		for _, pair := range strings.Split(query, "&") {
+			if k, v, ok := strings.Cut(pair, "="); ok {
+				params[k] = v
+			}
+		}
+		return path, params, true
+	case r.URLPath != "":
+		return r.URLPath, params, true
+	case r.URLPathTemplate != "":
+		path, _ := routePath(strings.NewReplacer("{", "${").Replace(r.URLPathTemplate))
+		return path, params, true
+	case r.URLPathPattern != "":
+		path, ok := regexRoute(r.URLPathPattern)
+		return path, params, ok
+	case r.URLPattern != "":
+		pattern, _, _ := strings.Cut(r.URLPattern, `\?`)
+		path, ok := regexRoute(pattern)
+		return path, params, ok
+	default:
+		return "/*path", params, true
+	}
+}
+
+// 把路径正则转换为 gin 路由：含正则元字符的段转换为路径参数，结尾的 .* 转换为通配
+func regexRoute(pattern string) (string, bool) {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+	if !strings.HasPrefix(pattern, "/") {
+		return "", false
+	}
+	segments := strings.Split(pattern[1:], "/")
+	for i, segment := range segments {
+		switch {
+		case (segment == ".*" || segment == ".+") && i == len(segments)-1:
+			segments[i] = "*path"
+		case strings.Contains(segment, ".*") && i < len(segments)-1:
+			// 中间的 .* 可能跨越多段，无法对应到路径参数
+			return "", false
+		case strings.ContainsAny(segment, `.*+?[](){}|\^$`):
+			segments[i] = ":p" + strconv.Itoa(i+1)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), true
+}
+
+type mockoonEnvironment struct {
+	Name           string         `json:"name"`
+	EndpointPrefix string         `json:"endpointPrefix"`
+	Routes         []mockoonRoute `json:"routes"`
+}
+
+type mockoonRoute struct {
+	Type      string            `json:"type"`
+	Method    string            `json:"method"`
+	Endpoint  string            `json:"endpoint"`
+	Responses []mockoonResponse `json:"responses"`
+}
+
+type mockoonResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+	Default    bool   `json:"default"`
+	Latency    int    `json:"latency"`
+	Headers    []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"headers"`
+	Rules []interface{} `json:"rules"`
+}
+
+// ConvertMockoon 转换 Mockoon 的 environment 文件，每个路由使用默认响应（没有标记默认时使用第一个），
+// 按规则匹配的其他响应被忽略
+func ConvertMockoon(data []byte) ([]MockConfig, error) {
+	var env mockoonEnvironment
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(env.EndpointPrefix, "/")
+	var configs []MockConfig
+	for _, route := range env.Routes {
+		if route.Type != "" && route.Type != "http" {
+			logger.Warn("只支持 http 类型的 Mockoon 路由，已跳过", "type", route.Type, "endpoint", route.Endpoint)
+			continue
+		}
+		if len(route.Responses) == 0 {
+			continue
+		}
+		url, ok := mockoonURL(prefix, route.Endpoint)
+		if !ok {
+			logger.Warn("无法转换 Mockoon 的路径，已跳过", "endpoint", route.Endpoint)
+			continue
+		}
+		res := route.Responses[0]
+		for _, r := range route.Responses {
+			if r.Default {
+				res = r
+				break
+			}
+		}
+		if len(route.Responses) > 1 {
+			logger.Warn("Mockoon 路由有多个响应，只使用默认响应", "url", url, "responses", len(route.Responses))
+		}
+		if res.Latency > 0 {
+			logger.Warn("不支持 Mockoon 的延迟，已忽略", "url", url, "latency_ms", res.Latency)
+		}
+		headers := make(map[string]string, len(res.Headers))
+		for _, h := range res.Headers {
+			headers[h.Key] = h.Value
+		}
+		headers = importHeaders(headers)
+
+		status := res.StatusCode
+		if status == 0 {
+			status = 200
+		}
+		var body interface{}
+		if res.Body != "" {
+			body = parseBody(res.Body)
+		}
+		for _, method := range expandMethod(route.Method, "ALL") {
+			configs = append(configs, MockConfig{
+				Method:   method,
+				URL:      url,
+				Response: Response{StatusCode: status, Body: body, Headers: headers},
+			})
+		}
+	}
+	return configs, nil
+}
+
+// Mockoon 的路径不以 / 开头，参数同样使用 :name，* 只能出现在末尾
+func mockoonURL(prefix, endpoint string) (string, bool) {
+	path := "/" + strings.Trim(prefix+"/"+strings.TrimLeft(endpoint, "/"), "/")
+	segments := strings.Split(path[1:], "/")
+	for i, segment := range segments {
+		if strings.Contains(segment, "*") {
+			if segment != "*" || i != len(segments)-1 {
+				return "", false
+			}
+			segments[i] = "*path"
+		}
+	}
+	return "/" + strings.Join(segments, "/"), true
+}
+
+// any 表示匹配所有方法，展开为服务支持的所有方法
+func expandMethod(method, any string) []string {
+	method = strings.ToUpper(method)
+	if method == "" || method == any {
+		return []string{"GET", "POST", "PUT", "DELETE", "PATCH"}
+	}
+	return []string{method}
+}
+
+// 响应头的值和响应体一样转换模板表达式，没有响应头时返回 nil
+func importHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	converted := make(map[string]string, len(headers))
+	for name, v := range headers {
+		converted[name] = toText(convertTemplates(v))
+	}
+	return converted
+}
+
+// 模板表达式，如 {{randomValue type='UUID'}}、{{faker 'person.firstName'}}
+var templatePattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// 响应体是 JSON 时解析后转换模板表达式，否则作为字符串返回
+func parseBody(text string) interface{} {
+	var body interface{}
+	if err := json.Unmarshal([]byte(quoteTemplates(text)), &body); err != nil {
+		return text
+	}
+	return convertTemplates(body)
+}
+
+// 给 JSON 字符串以外的模板表达式加上引号，如 "age": {{int 1 100}}，使响应体可以按 JSON 解析
+func quoteTemplates(text string) string {
+	var b strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+		case strings.HasPrefix(text[i:], "{{"):
+			if end := strings.Index(text[i:], "}}"); end > 0 {
+				b.WriteString(strconv.Quote(text[i : i+end+2]))
+				i += end + 1
+				continue
+			}
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// 整个字符串是一个模板表达式且有对应的占位符时替换为占位符，其余的表达式保留原样
+func convertTemplates(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		m := templatePattern.FindStringSubmatch(v)
+		if m == nil {
+			return v
+		}
+		if m[0] == v {
+			if placeholder, ok := templatePlaceholder(m[1]); ok {
+				return placeholder
+			}
+		}
+		logger.Warn("不支持的模板表达式，保留原样", "template", v)
+		return v
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = convertTemplates(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = convertTemplates(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// faker 方法和 value 包占位符的对应关系
+var fakerPlaceholders = map[string]string{
+	"string.uuid":          "@uuid",
+	"datatype.uuid":        "@uuid",
+	"person.fullName":      "@name",
+	"person.firstName":     "@name",
+	"person.lastName":      "@name",
+	"name.fullName":        "@name",
+	"name.firstName":       "@name",
+	"internet.email":       "@email",
+	"lorem.word":           "@word",
+	"lorem.sentence":       "@sentence",
+	"number.int":           "@randInt",
+	"datatype.number":      "@randInt",
+	"number.float":         "@float",
+	"datatype.boolean":     "@bool",
+	"date.past":            "@datetime",
+	"date.recent":          "@datetime",
+	"date.anytime":         "@datetime",
+	"string.alphanumeric":  "@randString",
+	"commerce.price":       "@price",
+	"finance.currencyCode": "@currency",
+	"finance.currencyName": "@currencyName",
+	"location.country":     "@country",
+	"location.countryCode": "@countryCode",
+	"address.country":      "@country",
+	"address.countryCode":  "@countryCode",
+}
+
+// 模板 helper 和 value 包占位符的对应关系，前一部分为 Mockoon 的 helper，后一部分为 WireMock 的 helper
+var helperPlaceholders = map[string]string{
+	"uuid":      "@uuid",
+	"guid":      "@uuid",
+	"email":     "@email",
+	"firstName": "@name",
+	"lastName":  "@name",
+	"int":       "@randInt",
+	"float":     "@float",
+	"boolean":   "@bool",
+	"date":      "@date",
+	"now":       "@datetime",
+	"lorem":     "@sentence",
+	"randomInt": "@randInt",
+}
+
+// 把模板表达式转换为 value 包的占位符
+func templatePlaceholder(expr string) (string, bool) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return "", false
+	}
+	switch fields[0] {
+	case "faker":
+		if len(fields) < 2 {
+			return "", false
+		}
+		placeholder, ok := fakerPlaceholders[strings.Trim(fields[1], `'"`)]
+		return placeholder, ok
+	case "randomValue":
+		// WireMock: randomValue length=10 type='ALPHANUMERIC'
+		options := make(map[string]string)
+		for _, field := range fields[1:] {
+			if k, v, ok := strings.Cut(field, "="); ok {
+				options[k] = strings.Trim(v, `'"`)
+			}
+		}
+		switch options["type"] {
+		case "UUID":
+			return "@uuid", true
+		case "ALPHANUMERIC", "ALPHABETIC", "HEXADECIMAL":
+			if options["length"] != "" {
+				return "@randString:" + options["length"], true
+			}
+			return "@randString", true
+		case "NUMERIC":
+			if options["length"] != "" {
+				return "@randInt:" + options["length"], true
+			}
+			return "@randInt", true
+		}
+		return "", false
+	default:
+		placeholder, ok := helperPlaceholders[fields[0]]
+		return placeholder, ok
+	}
+}
@@ -0,0 +1,203 @@
+package http_mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 按字段名推断的指令，字段名转小写后匹配
+var (
+	nameKeys     = []string{"name", "username", "nickname", "full_name", "fullname", "first_name", "last_name", "author", "owner"}
+	sentenceKeys = []string{"title", "description", "desc", "message", "content", "remark", "comment", "summary", "text"}
+	// 取值通常是枚举的字段保留原值，随机值会破坏调用方的分支逻辑
+	literalKeys = []string{"status", "state", "type", "kind", "category", "level", "currency", "lang", "locale", "country", "code", "unit", "method", "version", "mode", "role"}
+)
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern    = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	datePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	datetimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}`)
+	wordPattern     = regexp.MustCompile(`^[A-Za-z]{1,12}$`)
+	tokenPattern    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	digitsPattern   = regexp.MustCompile(`^-?\d+$`)
+)
+
+// RunInfer 从真实接口的响应样例推断 mock 配置，args 为命令行参数（不含命令名），返回进程退出码。
+// 每个 JSON 文件生成一个路由，路径为 -prefix 加文件名，响应体中的值按类型、格式和字段名替换为占位符
+func RunInfer(args []string) int {
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "url prefix of the generated routes, e.g. /api/v1")
+	method := fs.String("method", "get", "http method of the generated routes")
+	keep := fs.String("keep", "", "comma separated field names whose sample values are kept as is, in addition to status, type, code and similar enum fields")
+	maxItems := fs.Int("max-items", 3, "keep at most this many elements of each array, 0 keeps all")
+	output := fs.String("o", "", "output file, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		logger.Error("no input files, pass json response samples or directories of them")
+		return 2
+	}
+	if !supportedMethods[strings.ToUpper(*method)] {
+		logger.Error("unsupported -method", "method", *method)
+		return 2
+	}
+
+	inferrer := &inferrer{literal: map[string]bool{}, maxItems: *maxItems}
+	for _, key := range append(literalKeys, splitList(*keep)...) {
+		inferrer.literal[strings.ToLower(key)] = true
+	}
+	var configs []MockConfig
+	for _, path := range fs.Args() {
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				logger.Error("读取目录失败", "path", path, "err", err)
+				return 1
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			body, err := readSample(file)
+			if err != nil {
+				logger.Error("读取样例失败", "file", file, "err", err)
+				return 1
+			}
+			name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			configs = append(configs, MockConfig{
+				Method:   strings.ToLower(*method),
+				URL:      strings.TrimRight(*prefix, "/") + "/" + name,
+				Response: Response{StatusCode: http.StatusOK, Body: inferrer.infer("", body)},
+			})
+			logger.Info("推断完成", "file", file, "url", configs[len(configs)-1].URL)
+		}
+	}
+	configs = dedupeConfigs(configs)
+
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		logger.Error("序列化失败", "err", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		logger.Error("写入失败", "file", *output, "err", err)
+		return 1
+	}
+	logger.Info("已写入 mock 配置", "file", *output, "mocks", len(configs))
+	return 0
+}
+
+// 数字保留为 json.Number，区分整数和小数
+func readSample(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+type inferrer struct {
+	literal  map[string]bool
+	maxItems int
+}
+
+// 把样例中的值替换为占位符，key 是值所在的字段名，数组元素沿用数组的字段名
+func (in *inferrer) infer(key string, v interface{}) interface{} {
+	lower := strings.ToLower(key)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = in.infer(k, item)
+		}
+		return result
+	case []interface{}:
+		if in.maxItems > 0 && len(v) > in.maxItems {
+			v = v[:in.maxItems]
+		}
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = in.infer(key, item)
+		}
+		return result
+	case bool:
+		if in.literal[lower] {
+			return v
+		}
+		return "@bool"
+	case json.Number:
+		if in.literal[lower] {
+			return v
+		}
+		return inferNumber(lower, v)
+	case string:
+		if in.literal[lower] {
+			return v
+		}
+		return inferString(lower, v)
+	}
+	return v
+}
+
+func inferNumber(key string, n json.Number) interface{} {
+	text := n.String()
+	if !digitsPattern.MatchString(text) {
+		return "@float"
+	}
+	digits := len(strings.TrimPrefix(text, "-"))
+	if digits == 10 && (key == "ts" || strings.HasSuffix(key, "_at") || strings.HasSuffix(key, "time") || strings.HasSuffix(key, "timestamp")) {
+		return "@timestamp"
+	}
+	return "@randInt:" + strconv.Itoa(min(digits, 18))
+}
+
+func inferString(key, s string) interface{} {
+	switch {
+	case s == "" || strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://"):
+		return s
+	case uuidPattern.MatchString(s):
+		return "@uuid"
+	case emailPattern.MatchString(s) || strings.Contains(key, "email"):
+		return "@email"
+	case datePattern.MatchString(s):
+		return "@date"
+	case datetimePattern.MatchString(s):
+		return "@datetime"
+	case slices.Contains(nameKeys, key):
+		return "@name"
+	case slices.Contains(sentenceKeys, key) || strings.Contains(s, " "):
+		return "@sentence"
+	case digitsPattern.MatchString(s):
+		// 数字字符串无法用占位符生成，保留原值
+		return s
+	case wordPattern.MatchString(s):
+		return "@word"
+	case tokenPattern.MatchString(s):
+		return "@randString:" + strconv.Itoa(len(s))
+	}
+	return s
+}
@@ -0,0 +1,380 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内置短信和邮件接口保存的消息条数，超过后丢弃最早的消息
+const messageStoreSize = 1000
+
+// 消息类型
+const (
+	MessageSMS   = "sms"
+	MessageEmail = "email"
+)
+
+// Message 短信或邮件接口收到的一条消息，通过 /__admin/messages 查询
+type Message struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Time    time.Time       `json:"time"`
+	Status  string          `json:"status,omitempty"`
+	From    string          `json:"from"`
+	To      []string        `json:"to"`
+	Subject string          `json:"subject,omitempty"`
+	Text    string          `json:"text,omitempty"`
+	HTML    string          `json:"html,omitempty"`
+	Request json.RawMessage `json:"request"` // 原始请求，表单请求转换为 JSON 对象
+}
+
+type messageStore struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func newMessageStore() *messageStore {
+	return &messageStore{}
+}
+
+func (s *messageStore) add(m *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+	if len(s.messages) > messageStoreSize {
+		s.messages = append(s.messages[:0], s.messages[len(s.messages)-messageStoreSize:]...)
+	}
+	logger.Info("收到消息", "type", m.Type, "id", m.ID, "to", strings.Join(m.To, ","))
+}
+
+func (s *messageStore) get(id string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			return *m, true
+		}
+	}
+	return Message{}, false
+}
+
+func (s *messageStore) setStatus(id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			m.Status = status
+		}
+	}
+}
+
+// 按类型和收件人过滤，收件人为包含匹配
+func (s *messageStore) list(messageType, to string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []Message{}
+	for _, m := range s.messages {
+		if messageType != "" && m.Type != messageType {
+			continue
+		}
+		if to != "" && !strings.Contains(strings.Join(m.To, "\n"), to) {
+			continue
+		}
+		result = append(result, *m)
+	}
+	return result
+}
+
+func (s *messageStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// 管理接口中的消息查询，GET /__admin/messages?type=sms&to=+8613800000000
+func (s *messageStore) register(admin gin.IRouter) {
+	admin.GET("/messages", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.list(c.Query("type"), c.Query("to")))
+	})
+	admin.GET("/messages/:id", func(c *gin.Context) {
+		m, ok := s.get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	})
+	admin.DELETE("/messages", func(c *gin.Context) {
+		s.clear()
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// Twilio 风格的短信接口，路径与 https://api.twilio.com 相同，SDK 只需要替换域名
+type smsAPI struct {
+	store  *messageStore
+	client *http.Client
+}
+
+func (a *smsAPI) register(router gin.IRouter) {
+	group := router.Group("/2010-04-01/Accounts/:account")
+	group.Use(a.authenticate)
+	group.POST("/Messages.json", a.send)
+	group.GET("/Messages.json", a.list)
+	group.GET("/Messages/:sid", a.get)
+}
+
+// 接受任意凭证，但和真实服务一样要求 Basic 认证，且用户名与路径中的 AccountSid 一致
+func (a *smsAPI) authenticate(c *gin.Context) {
+	user, _, ok := c.Request.BasicAuth()
+	if !ok || user != c.Param("account") {
+		twilioError(c, http.StatusUnauthorized, 20003, "Authenticate")
+		c.Abort()
+	}
+}
+
+func (a *smsAPI) send(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		twilioError(c, http.StatusBadRequest, 20001, err.Error())
+		return
+	}
+	form := c.Request.PostForm
+	to, from := form.Get("To"), form.Get("From")
+	if from == "" {
+		from = form.Get("MessagingServiceSid")
+	}
+	switch {
+	case to == "":
+		twilioError(c, http.StatusBadRequest, 21604, "A 'To' phone number is required.")
+		return
+	case from == "":
+		twilioError(c, http.StatusBadRequest, 21603, "A 'From' phone number is required.")
+		return
+	case form.Get("Body") == "" && form.Get("MediaUrl") == "":
+		twilioError(c, http.StatusBadRequest, 21602, "Message body is required.")
+		return
+	}
+
+	raw := make(map[string]interface{}, len(form))
+	for k, v := range form {
+		if len(v) == 1 {
+			raw[k] = v[0]
+		} else {
+			raw[k] = v
+		}
+	}
+	request, _ := json.Marshal(raw)
+	m := &Message{
+		ID:      "SM" + newUploadID(),
+		Type:    MessageSMS,
+		Time:    time.Now().UTC(),
+		Status:  "queued",
+		From:    from,
+		To:      []string{to},
+		Text:    form.Get("Body"),
+		Request: request,
+	}
+	a.store.add(m)
+	c.JSON(http.StatusCreated, twilioMessage(c.Param("account"), m))
+
+	// 有 StatusCallback 时随后依次通知 sent 和 delivered
+	if callback := form.Get("StatusCallback"); callback != "" {
+		go a.statusCallback(callback, c.Param("account"), m)
+	}
+}
+
+func (a *smsAPI) statusCallback(callback, account string, m *Message) {
+	for _, status := range []string{"sent", "delivered"} {
+		time.Sleep(500 * time.Millisecond)
+		a.store.setStatus(m.ID, status)
+		form := url.Values{
+			"AccountSid":    {account},
+			"MessageSid":    {m.ID},
+			"SmsSid":        {m.ID},
+			"MessageStatus": {status},
+			"SmsStatus":     {status},
+			"To":            {m.To[0]},
+			"From":          {m.From},
+		}
+		resp, err := a.client.PostForm(callback, form)
+		if err != nil {
+			logger.Warn("短信状态回调失败", "url", callback, "err", err)
+			return
+		}
+		resp.Body.Close()
+	}
+}
+
+func (a *smsAPI) get(c *gin.Context) {
+	m, ok := a.store.get(strings.TrimSuffix(c.Param("sid"), ".json"))
+	if !ok || m.Type != MessageSMS {
+		twilioError(c, http.StatusNotFound, 20404, "The requested resource was not found")
+		return
+	}
+	c.JSON(http.StatusOK, twilioMessage(c.Param("account"), &m))
+}
+
+func (a *smsAPI) list(c *gin.Context) {
+	messages := a.store.list(MessageSMS, c.Query("To"))
+	result := make([]gin.H, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		result = append(result, twilioMessage(c.Param("account"), &messages[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"messages":          result,
+		"uri":               c.Request.URL.RequestURI(),
+		"page":              0,
+		"page_size":         len(result),
+		"first_page_uri":    c.Request.URL.RequestURI(),
+		"next_page_uri":     nil,
+		"previous_page_uri": nil,
+	})
+}
+
+// 转换为 Twilio 的 Message 资源
+func twilioMessage(account string, m *Message) gin.H {
+	date := m.Time.Format(time.RFC1123Z)
+	segments := (len([]rune(m.Text)) + 152) / 153
+	return gin.H{
+		"sid":           m.ID,
+		"account_sid":   account,
+		"api_version":   "2010-04-01",
+		"to":            m.To[0],
+		"from":          m.From,
+		"body":          m.Text,
+		"status":        m.Status,
+		"direction":     "outbound-api",
+		"num_segments":  strconv.Itoa(max(segments, 1)),
+		"num_media":     "0",
+		"price":         nil,
+		"price_unit":    "USD",
+		"error_code":    nil,
+		"error_message": nil,
+		"date_created":  date,
+		"date_updated":  date,
+		"date_sent":     nil,
+		"uri":           fmt.Sprintf("/2010-04-01/Accounts/%s/Messages/%s.json", account, m.ID),
+	}
+}
+
+func twilioError(c *gin.Context, status, code int, message string) {
+	c.JSON(status, gin.H{
+		"code":      code,
+		"message":   message,
+		"more_info": fmt.Sprintf("https://www.twilio.com/docs/errors/%d", code),
+		"status":    status,
+	})
+}
+
+// SendGrid 风格的邮件接口，路径与 https://api.sendgrid.com 相同
+type emailAPI struct {
+	store *messageStore
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridMail struct {
+	Personalizations []struct {
+		To      []sendGridAddress `json:"to"`
+		Cc      []sendGridAddress `json:"cc"`
+		Bcc     []sendGridAddress `json:"bcc"`
+		Subject string            `json:"subject"`
+	} `json:"personalizations"`
+	From    *sendGridAddress `json:"from"`
+	Subject string           `json:"subject"`
+	Content []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"content"`
+	TemplateID string `json:"template_id"`
+}
+
+func (a *emailAPI) register(router gin.IRouter) {
+	router.POST("/v3/mail/send", a.send)
+}
+
+// 接受任意 API key，但要求 Bearer 认证。每个 personalization 保存为一条消息
+func (a *emailAPI) send(c *gin.Context) {
+	if !strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+		sendGridError(c, http.StatusUnauthorized, "", "The provided authorization grant is invalid, expired, or revoked")
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		sendGridError(c, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	var mail sendGridMail
+	if err := json.Unmarshal(body, &mail); err != nil {
+		sendGridError(c, http.StatusBadRequest, "", "Bad Request")
+		return
+	}
+	switch {
+	case mail.From == nil || mail.From.Email == "":
+		sendGridError(c, http.StatusBadRequest, "from.email", "The from object must be provided for every email send. It is an object that requires the email parameter, but may also contain a name parameter.")
+		return
+	case len(mail.Personalizations) == 0:
+		sendGridError(c, http.StatusBadRequest, "personalizations", "The personalizations field is required and must have at least one personalization.")
+		return
+	case len(mail.Content) == 0 && mail.TemplateID == "":
+		sendGridError(c, http.StatusBadRequest, "content", "Unless a valid template_id is provided, the content parameter is required. There must be at least one defined content block.")
+		return
+	}
+	for i, p := range mail.Personalizations {
+		if len(p.To) == 0 {
+			sendGridError(c, http.StatusBadRequest, fmt.Sprintf("personalizations.%d.to", i), "The to array is required for all personalization objects, and must have at least one email object with a valid email address.")
+			return
+		}
+	}
+
+	messageID := newUploadID()[:22]
+	for i, p := range mail.Personalizations {
+		m := &Message{
+			ID:      fmt.Sprintf("%s.%d", messageID, i),
+			Type:    MessageEmail,
+			Time:    time.Now().UTC(),
+			Status:  "processed",
+			From:    mail.From.Email,
+			Subject: mail.Subject,
+			Request: body,
+		}
+		if p.Subject != "" {
+			m.Subject = p.Subject
+		}
+		for _, list := range [][]sendGridAddress{p.To, p.Cc, p.Bcc} {
+			for _, address := range list {
+				m.To = append(m.To, address.Email)
+			}
+		}
+		for _, content := range mail.Content {
+			switch content.Type {
+			case "text/plain":
+				m.Text = content.Value
+			case "text/html":
+				m.HTML = content.Value
+			}
+		}
+		a.store.add(m)
+	}
+	c.Header("X-Message-Id", messageID)
+	c.Status(http.StatusAccepted)
+}
+
+func sendGridError(c *gin.Context, status int, field, message string) {
+	var fieldValue interface{}
+	if field != "" {
+		fieldValue = field
+	}
+	c.JSON(status, gin.H{"errors": []gin.H{{"message": message, "field": fieldValue, "help": nil}}})
+}
@@ -0,0 +1,24 @@
+package http_mock
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/gin-gonic/gin"
+)
+
+// Prometheus 指标的路径，配置中定义了相同路径时以配置为准
+const metricsPath = "/metrics"
+
+// 以 Prometheus 文本格式输出每个 mock 的命中次数和占位符指令的统计
+func (h *HttpMockHandler) serveMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := c.Writer
+	fmt.Fprint(w, "# HELP mockgo_mock_requests_total Requests answered by each mock config.\n# TYPE mockgo_mock_requests_total counter\n")
+	for _, r := range h.Usage().Routes {
+		fmt.Fprintf(w, "mockgo_mock_requests_total{method=%q,url=%q} %d\n", r.Method, r.URL, r.Hits)
+	}
+	value.WritePrometheus(w)
+}
@@ -0,0 +1,134 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 按环境查找的覆盖文件：配置文件 name.json 对应 name.<env>.json，配置目录 dir 对应 dir/<env>/*.json
+func (h *HttpMockHandler) overlayFiles() ([]string, error) {
+	var files []string
+	if h.Env != "" {
+		for _, path := range h.path {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				matches, err := filepath.Glob(filepath.Join(path, h.Env, "*.json"))
+				if err != nil {
+					return nil, err
+				}
+				sort.Strings(matches)
+				files = append(files, matches...)
+				continue
+			}
+			overlay := strings.TrimSuffix(path, filepath.Ext(path)) + "." + h.Env + filepath.Ext(path)
+			if _, err := os.Stat(overlay); err == nil {
+				files = append(files, overlay)
+			}
+		}
+	}
+	return append(files, h.Overlays...), nil
+}
+
+// 依次应用覆盖文件。覆盖文件和配置文件格式相同，按 method 和 url 找到基础配置后以 JSON Merge Patch（RFC 7386）合并：
+// 对象逐字段合并，null 删除字段，其他值直接替换；"remove": true 删除整个路由，找不到的路由追加到最后
+func (h *HttpMockHandler) applyOverlays(configs []MockConfig) ([]MockConfig, error) {
+	files, err := h.overlayFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取覆盖文件失败: %v", err)
+		}
+		var patches []map[string]interface{}
+		if err := json.Unmarshal(data, &patches); err != nil {
+			return nil, fmt.Errorf("解析覆盖文件 %s 失败: %v", file, err)
+		}
+		for i, patch := range patches {
+			if configs, err = overlayConfig(configs, patch); err != nil {
+				return nil, fmt.Errorf("覆盖文件 %s 第 %d 项: %v", file, i+1, err)
+			}
+		}
+		logger.Info("应用覆盖文件", "file", file, "env", h.Env, "routes", len(patches))
+	}
+	return configs, nil
+}
+
+func overlayConfig(configs []MockConfig, patch map[string]interface{}) ([]MockConfig, error) {
+	method, _ := patch["method"].(string)
+	url, _ := patch["url"].(string)
+	if method == "" || url == "" {
+		return nil, errors.New("method and url are required")
+	}
+	remove, _ := patch["remove"].(bool)
+	delete(patch, "remove")
+	for i, c := range configs {
+		if !strings.EqualFold(c.Method, method) || c.URL != url {
+			continue
+		}
+		if remove {
+			return append(configs[:i:i], configs[i+1:]...), nil
+		}
+		merged, err := mergeConfig(c, patch)
+		if err != nil {
+			return nil, err
+		}
+		configs[i] = merged
+		return configs, nil
+	}
+	if remove {
+		logger.Warn("要删除的路由不存在", "method", method, "url", url)
+		return configs, nil
+	}
+	merged, err := mergeConfig(MockConfig{}, patch)
+	if err != nil {
+		return nil, err
+	}
+	return append(configs, merged), nil
+}
+
+// 通过 JSON 表示合并，合并结果必须仍然是合法的 mock 配置
+func mergeConfig(base MockConfig, patch map[string]interface{}) (MockConfig, error) {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return base, err
+	}
+	var target interface{}
+	if err := json.Unmarshal(data, &target); err != nil {
+		return base, err
+	}
+	data, err = json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return base, err
+	}
+	var merged MockConfig
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return base, err
+	}
+	return merged, nil
+}
+
+// RFC 7386 JSON Merge Patch
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatch(t[key], value)
+	}
+	return t
+}
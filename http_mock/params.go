@@ -0,0 +1,147 @@
+package http_mock
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 把 query 参数或表单字段转换为嵌套结构，供 query、form 条件和引用使用：
+// 重复的参数（ids=1&ids=2）和以 [] 结尾的参数（ids[]=1）为数组，user[name]=a 为嵌套对象，
+// 下标都是数字的对象（item[0]=a&item[1]=b）转换为数组。值都是字符串
+func decodeParams(values url.Values) map[string]interface{} {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	root := make(map[string]interface{})
+	for _, key := range keys {
+		setParam(root, paramPath(key), values[key])
+	}
+	return toArrays(root).(map[string]interface{})
+}
+
+// user[tags][]=x 拆分为 user、tags 和空字符串，空字符串表示追加到数组
+func paramPath(key string) []string {
+	open := strings.IndexByte(key, '[')
+	if open <= 0 || !strings.HasSuffix(key, "]") {
+		return []string{key}
+	}
+	parts := []string{key[:open]}
+	for _, part := range strings.Split(key[open+1:len(key)-1], "][") {
+		if strings.ContainsAny(part, "[]") {
+			return []string{key}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func setParam(node map[string]interface{}, path []string, values []string) {
+	key := path[0]
+	if len(path) == 1 || len(path) == 2 && path[1] == "" {
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		if existing, ok := node[key].([]interface{}); ok {
+			node[key] = append(existing, items...)
+		} else if len(items) == 1 && len(path) == 1 {
+			node[key] = items[0]
+		} else {
+			node[key] = items
+		}
+		return
+	}
+	child, ok := node[key].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		node[key] = child
+	}
+	setParam(child, path[1:], values)
+}
+
+func toArrays(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	indexed := len(m) > 0
+	for key, item := range m {
+		m[key] = toArrays(item)
+		if n, err := strconv.Atoi(key); err != nil || n < 0 || n >= len(m) {
+			indexed = false
+		}
+	}
+	if !indexed {
+		return m
+	}
+	items := make([]interface{}, len(m))
+	for key, item := range m {
+		n, _ := strconv.Atoi(key)
+		items[n] = item
+	}
+	return items
+}
+
+// 解析 application/x-www-form-urlencoded 和 multipart/form-data 请求体，上传的文件取文件名
+func parseForm(contentType string, data []byte) (url.Values, bool, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false, nil
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(data))
+		return values, true, err
+	case "multipart/form-data":
+		values := url.Values{}
+		reader := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return values, true, nil
+			}
+			if err != nil {
+				return nil, true, err
+			}
+			if part.FormName() == "" {
+				continue
+			}
+			if part.FileName() != "" {
+				values.Add(part.FormName(), part.FileName())
+				continue
+			}
+			content, err := io.ReadAll(part)
+			if err != nil {
+				return nil, true, err
+			}
+			values.Add(part.FormName(), string(content))
+		}
+	}
+	return nil, false, nil
+}
+
+// 请求头按小写名称保存，重复的请求头为数组，和 query 参数一样单个值也可以用 [0] 取值
+func decodeHeader(header http.Header) map[string]interface{} {
+	result := make(map[string]interface{}, len(header))
+	for name, values := range header {
+		if len(values) == 1 {
+			result[strings.ToLower(name)] = values[0]
+			continue
+		}
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		result[strings.ToLower(name)] = items
+	}
+	return result
+}
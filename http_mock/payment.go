@@ -0,0 +1,480 @@
+package http_mock
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内置支付网关的路径前缀
+const paymentPrefix = "/__payments"
+
+// 支付和退款的状态
+const (
+	PaymentPending           = "pending"
+	PaymentSucceeded         = "succeeded"
+	PaymentFailed            = "failed"
+	PaymentCanceled          = "canceled"
+	PaymentRefunded          = "refunded"
+	PaymentPartiallyRefunded = "partially_refunded"
+)
+
+// PaymentConfig 内置支付网关的配置，全部字段都可以省略
+type PaymentConfig struct {
+	WebhookURL     string  `json:"webhook_url"`     // 状态变化时通知的地址，创建支付时可以用 webhook_url 覆盖
+	WebhookSecret  string  `json:"webhook_secret"`  // 签名 webhook 的密钥，默认 whsec_mockgo
+	Delay          string  `json:"delay"`           // 支付和退款从 pending 到最终状态的时间，默认 2s
+	FailureRate    float64 `json:"failure_rate"`    // 随机失败的概率
+	DeclineAmounts []int64 `json:"decline_amounts"` // 这些金额的支付总是失败，便于在测试中稳定复现
+	Retries        int     `json:"retries"`         // webhook 返回非 2xx 时的重试次数，默认 3
+	Currency       string  `json:"currency"`        // 默认币种，默认 USD
+}
+
+// Payment 一笔支付，金额以最小货币单位（如分）表示
+type Payment struct {
+	ID             string            `json:"id"`
+	Status         string            `json:"status"`
+	Amount         int64             `json:"amount"`
+	AmountRefunded int64             `json:"amount_refunded"`
+	Currency       string            `json:"currency"`
+	Description    string            `json:"description,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	FailureCode    string            `json:"failure_code,omitempty"`
+	Refunds        []*Refund         `json:"refunds"`
+	Created        int64             `json:"created"`
+	Updated        int64             `json:"updated"`
+
+	webhookURL string
+	simulate   string
+}
+
+// Refund 一笔退款
+type Refund struct {
+	ID        string `json:"id"`
+	PaymentID string `json:"payment_id"`
+	Status    string `json:"status"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason,omitempty"`
+	Created   int64  `json:"created"`
+}
+
+// WebhookEvent 发送给 webhook 地址的事件，同时记录投递结果
+type WebhookEvent struct {
+	ID      string      `json:"id"`
+	Type    string      `json:"type"`
+	Created int64       `json:"created"`
+	Data    interface{} `json:"data"`
+
+	url      string
+	attempts int
+	response int // 最后一次投递的状态码，0 表示未投递成功
+}
+
+// 幂等键对应的首次请求和响应，status 为 0 表示首次请求还在处理中
+type idempotentResponse struct {
+	fingerprint string
+	status      int
+	body        []byte
+}
+
+type paymentGateway struct {
+	config PaymentConfig
+	delay  time.Duration
+	client *http.Client
+
+	mu          sync.Mutex
+	payments    map[string]*Payment
+	order       []string // 按创建顺序排列的支付 ID
+	events      []*WebhookEvent
+	idempotency map[string]*idempotentResponse
+}
+
+func loadPaymentGateway(path string) (*paymentGateway, error) {
+	var config PaymentConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+	}
+	return newPaymentGateway(config)
+}
+
+func newPaymentGateway(config PaymentConfig) (*paymentGateway, error) {
+	g := &paymentGateway{
+		config:      config,
+		delay:       2 * time.Second,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		payments:    make(map[string]*Payment),
+		idempotency: make(map[string]*idempotentResponse),
+	}
+	if config.Delay != "" {
+		delay, err := time.ParseDuration(config.Delay)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid delay %q", config.Delay)
+		}
+		g.delay = delay
+	}
+	if g.config.WebhookSecret == "" {
+		g.config.WebhookSecret = "whsec_mockgo"
+	}
+	if g.config.Retries == 0 {
+		g.config.Retries = 3
+	}
+	if g.config.Currency == "" {
+		g.config.Currency = "USD"
+	}
+	return g, nil
+}
+
+func (g *paymentGateway) register(router gin.IRouter) {
+	group := router.Group(paymentPrefix)
+	group.POST("/payments", g.idempotent(g.createPayment))
+	group.GET("/payments", g.listPayments)
+	group.GET("/payments/:id", g.getPayment)
+	group.POST("/payments/:id/cancel", g.idempotent(g.cancelPayment))
+	group.POST("/payments/:id/refunds", g.idempotent(g.createRefund))
+	group.GET("/events", g.listEvents)
+}
+
+// 带 Idempotency-Key 的请求只执行一次，相同的键和请求体直接返回首次的响应，请求体不同时返回 422
+func (g *paymentGateway) idempotent(handler func(c *gin.Context, body []byte) (int, interface{})) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			status, response := handler(c, body)
+			c.JSON(status, response)
+			return
+		}
+
+		sum := sha256.Sum256(append([]byte(c.Request.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+		g.mu.Lock()
+		saved, ok := g.idempotency[key]
+		if !ok {
+			saved = &idempotentResponse{fingerprint: fingerprint}
+			g.idempotency[key] = saved
+		}
+		replay := *saved
+		g.mu.Unlock()
+		switch {
+		case ok && replay.fingerprint != fingerprint:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was used with a different request", "code": "idempotency_key_reused"})
+			return
+		case ok && replay.status == 0:
+			c.JSON(http.StatusConflict, gin.H{"error": "a request with the same Idempotency-Key is in progress", "code": "idempotency_key_in_progress"})
+			return
+		case ok:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(replay.status, "application/json; charset=utf-8", replay.body)
+			return
+		}
+
+		status, response := handler(c, body)
+		data, _ := json.Marshal(response)
+		g.mu.Lock()
+		// 服务端错误允许客户端用同一个键重试
+		if status >= http.StatusInternalServerError {
+			delete(g.idempotency, key)
+		} else {
+			saved.status, saved.body = status, data
+		}
+		g.mu.Unlock()
+		c.Data(status, "application/json; charset=utf-8", data)
+	}
+}
+
+func (g *paymentGateway) createPayment(c *gin.Context, body []byte) (int, interface{}) {
+	var request struct {
+		Amount      int64             `json:"amount"`
+		Currency    string            `json:"currency"`
+		Description string            `json:"description"`
+		Metadata    map[string]string `json:"metadata"`
+		WebhookURL  string            `json:"webhook_url"`
+		Simulate    string            `json:"simulate"` // succeeded 或 failed，覆盖随机结果
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return http.StatusBadRequest, gin.H{"error": "invalid json: " + err.Error(), "code": "invalid_request"}
+	}
+	if request.Amount <= 0 {
+		return http.StatusBadRequest, gin.H{"error": "amount must be a positive integer in the smallest currency unit", "code": "invalid_amount"}
+	}
+	if request.Simulate != "" && request.Simulate != PaymentSucceeded && request.Simulate != PaymentFailed {
+		return http.StatusBadRequest, gin.H{"error": "simulate must be succeeded or failed", "code": "invalid_request"}
+	}
+	now := time.Now().Unix()
+	payment := &Payment{
+		ID:          "pay_" + newUploadID()[:24],
+		Status:      PaymentPending,
+		Amount:      request.Amount,
+		Currency:    strings.ToUpper(request.Currency),
+		Description: request.Description,
+		Metadata:    request.Metadata,
+		Refunds:     []*Refund{},
+		Created:     now,
+		Updated:     now,
+		webhookURL:  request.WebhookURL,
+		simulate:    request.Simulate,
+	}
+	if payment.Currency == "" {
+		payment.Currency = g.config.Currency
+	}
+	if payment.webhookURL == "" {
+		payment.webhookURL = g.config.WebhookURL
+	}
+
+	g.mu.Lock()
+	g.payments[payment.ID] = payment
+	g.order = append(g.order, payment.ID)
+	snapshot := payment.copy()
+	g.mu.Unlock()
+	logger.Info("创建支付", "id", payment.ID, "amount", payment.Amount, "currency", payment.Currency)
+	time.AfterFunc(g.delay, func() { g.settle(payment.ID) })
+	return http.StatusCreated, snapshot
+}
+
+// 复制支付和其中的退款，在锁外序列化时不受后续状态变化影响。调用方持有 g.mu
+func (p *Payment) copy() *Payment {
+	payment := *p
+	payment.Refunds = make([]*Refund, len(p.Refunds))
+	for i, refund := range p.Refunds {
+		r := *refund
+		payment.Refunds[i] = &r
+	}
+	return &payment
+}
+
+// pending 的支付按 simulate、decline_amounts 和 failure_rate 决定结果并发送 webhook
+func (g *paymentGateway) settle(id string) {
+	g.mu.Lock()
+	payment := g.payments[id]
+	if payment.Status != PaymentPending {
+		g.mu.Unlock()
+		return
+	}
+	failed := payment.simulate == PaymentFailed ||
+		payment.simulate == "" && (slices.Contains(g.config.DeclineAmounts, payment.Amount) || rand.Float64() < g.config.FailureRate)
+	if failed {
+		payment.Status = PaymentFailed
+		payment.FailureCode = "card_declined"
+	} else {
+		payment.Status = PaymentSucceeded
+	}
+	payment.Updated = time.Now().Unix()
+	status := payment.Status
+	event := g.newEvent("payment."+status, payment)
+	g.mu.Unlock()
+	logger.Info("支付完成", "id", id, "status", status)
+	g.deliver(event)
+}
+
+func (g *paymentGateway) cancelPayment(c *gin.Context, _ []byte) (int, interface{}) {
+	g.mu.Lock()
+	payment, ok := g.payments[c.Param("id")]
+	if !ok {
+		g.mu.Unlock()
+		return http.StatusNotFound, gin.H{"error": "payment not found", "code": "resource_missing"}
+	}
+	if payment.Status != PaymentPending {
+		g.mu.Unlock()
+		return http.StatusConflict, gin.H{"error": "only pending payments can be canceled, current status is " + payment.Status, "code": "payment_unexpected_state"}
+	}
+	payment.Status = PaymentCanceled
+	payment.Updated = time.Now().Unix()
+	event := g.newEvent("payment.canceled", payment)
+	snapshot := payment.copy()
+	g.mu.Unlock()
+	go g.deliver(event)
+	return http.StatusOK, snapshot
+}
+
+// 退款金额默认为剩余可退金额，退款在 delay 之后完成并发送 refund.succeeded
+func (g *paymentGateway) createRefund(c *gin.Context, body []byte) (int, interface{}) {
+	var request struct {
+		Amount int64  `json:"amount"`
+		Reason string `json:"reason"`
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			return http.StatusBadRequest, gin.H{"error": "invalid json: " + err.Error(), "code": "invalid_request"}
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	payment, ok := g.payments[c.Param("id")]
+	if !ok {
+		return http.StatusNotFound, gin.H{"error": "payment not found", "code": "resource_missing"}
+	}
+	if payment.Status != PaymentSucceeded && payment.Status != PaymentPartiallyRefunded {
+		return http.StatusConflict, gin.H{"error": "only succeeded payments can be refunded, current status is " + payment.Status, "code": "payment_unexpected_state"}
+	}
+	// 处理中的退款也占用可退金额
+	remaining := payment.Amount
+	for _, refund := range payment.Refunds {
+		if refund.Status != PaymentFailed {
+			remaining -= refund.Amount
+		}
+	}
+	if request.Amount == 0 {
+		request.Amount = remaining
+	}
+	if request.Amount < 0 || request.Amount > remaining {
+		return http.StatusBadRequest, gin.H{"error": fmt.Sprintf("refund amount must be between 1 and %d", remaining), "code": "invalid_amount"}
+	}
+	refund := &Refund{
+		ID:        "re_" + newUploadID()[:24],
+		PaymentID: payment.ID,
+		Status:    PaymentPending,
+		Amount:    request.Amount,
+		Reason:    request.Reason,
+		Created:   time.Now().Unix(),
+	}
+	payment.Refunds = append(payment.Refunds, refund)
+	logger.Info("创建退款", "id", refund.ID, "payment", payment.ID, "amount", refund.Amount)
+	time.AfterFunc(g.delay, func() { g.completeRefund(payment.ID, refund.ID) })
+	snapshot := *refund
+	return http.StatusCreated, &snapshot
+}
+
+func (g *paymentGateway) completeRefund(paymentID, refundID string) {
+	g.mu.Lock()
+	payment := g.payments[paymentID]
+	var refund *Refund
+	for _, r := range payment.Refunds {
+		if r.ID == refundID {
+			refund = r
+		}
+	}
+	refund.Status = PaymentSucceeded
+	payment.AmountRefunded += refund.Amount
+	if payment.AmountRefunded == payment.Amount {
+		payment.Status = PaymentRefunded
+	} else {
+		payment.Status = PaymentPartiallyRefunded
+	}
+	payment.Updated = time.Now().Unix()
+	event := g.newEvent("refund.succeeded", refund)
+	event.url = payment.webhookURL
+	g.mu.Unlock()
+	g.deliver(event)
+}
+
+func (g *paymentGateway) getPayment(c *gin.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	payment, ok := g.payments[c.Param("id")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found", "code": "resource_missing"})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+func (g *paymentGateway) listPayments(c *gin.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	payments := make([]*Payment, 0, len(g.order))
+	for i := len(g.order) - 1; i >= 0; i-- {
+		payments = append(payments, g.payments[g.order[i]])
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// 列出已经产生的 webhook 事件和投递结果，便于在测试中断言
+func (g *paymentGateway) listEvents(c *gin.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	events := make([]gin.H, 0, len(g.events))
+	for _, event := range g.events {
+		events = append(events, gin.H{
+			"id":       event.ID,
+			"type":     event.Type,
+			"created":  event.Created,
+			"data":     event.Data,
+			"url":      event.url,
+			"attempts": event.attempts,
+			"response": event.response,
+		})
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// 创建事件时复制对象，投递过程中状态的变化不影响事件内容。调用方持有 g.mu
+func (g *paymentGateway) newEvent(eventType string, data interface{}) *WebhookEvent {
+	event := &WebhookEvent{ID: "evt_" + newUploadID()[:24], Type: eventType, Created: time.Now().Unix()}
+	switch v := data.(type) {
+	case *Payment:
+		event.Data = v.copy()
+		event.url = v.webhookURL
+	case *Refund:
+		refund := *v
+		event.Data = &refund
+	}
+	g.events = append(g.events, event)
+	return event
+}
+
+// 发送 webhook，非 2xx 时按 1s、2s、4s... 重试。签名头格式为 t=<时间戳>,v1=<HMAC-SHA256(t.body)>
+func (g *paymentGateway) deliver(event *WebhookEvent) {
+	g.mu.Lock()
+	url := event.url
+	g.mu.Unlock()
+	if url == "" {
+		return
+	}
+	body, _ := json.Marshal(event)
+	backoff := time.Second
+	for attempt := 0; attempt <= g.config.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		timestamp := fmt.Sprint(time.Now().Unix())
+		mac := hmac.New(sha256.New, []byte(g.config.WebhookSecret))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			logger.Warn("webhook 地址无效", "url", url, "err", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Mock-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+		status := 0
+		resp, err := g.client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+		g.mu.Lock()
+		event.attempts++
+		event.response = status
+		g.mu.Unlock()
+		if status >= 200 && status < 300 {
+			logger.Debug("webhook 已送达", "event", event.Type, "id", event.ID, "url", url)
+			return
+		}
+		logger.Warn("webhook 投递失败", "event", event.Type, "id", event.ID, "url", url, "attempt", attempt+1, "status", status, "err", err)
+	}
+}
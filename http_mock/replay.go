@@ -0,0 +1,332 @@
+package http_mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/logging"
+)
+
+var replayLogger = logging.For("replay")
+
+// 实际发送时间晚于计划时间超过该值时计为延迟发送
+const replayLagThreshold = 100 * time.Millisecond
+
+// recordedRequest 录制的一个请求，offset 是相对第一个请求的时间
+type recordedRequest struct {
+	offset time.Duration
+	method string
+	path   string // 包含 query
+	header http.Header
+	body   []byte
+}
+
+// RunReplay 按录制时的相对时间把请求重新发往目标服务，args 为命令行参数（不含命令名），返回进程退出码。
+// 录制文件可以是 HAR，也可以是 serve 管理接口 /__admin/requests 返回的请求日志
+func RunReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	target := fs.String("target", "", "base url of the service to replay against, e.g. http://staging:8080")
+	speed := fs.Float64("speed", 1, "time compression factor, 2 replays twice as fast, 0 sends without waiting")
+	maxGap := fs.Duration("max-gap", 0, "shorten idle gaps between requests to at most this long after applying -speed, 0 keeps them")
+	concurrency := fs.Int("concurrency", 100, "maximum requests in flight, later requests wait and are sent late")
+	timeout := fs.Duration("timeout", 10*time.Second, "per request timeout")
+	headers := fs.String("header", "", "comma separated headers added to every request, e.g. Authorization:Bearer x")
+	include := fs.String("include", "", "only replay requests whose path matches this regexp")
+	format := fs.String("format", "text", "report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *target == "" || fs.NArg() == 0 {
+		replayLogger.Error("usage: replay -target <url> [flags] <recording.har|requests.json>...")
+		return 2
+	}
+	if *speed < 0 {
+		replayLogger.Error("-speed must not be negative")
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		replayLogger.Error("unsupported report format", "format", *format)
+		return 2
+	}
+	header, err := parseHeaders(*headers)
+	if err != nil {
+		replayLogger.Error("invalid -header", "err", err)
+		return 2
+	}
+	var pattern *regexp.Regexp
+	if *include != "" {
+		if pattern, err = regexp.Compile(*include); err != nil {
+			replayLogger.Error("invalid -include", "err", err)
+			return 2
+		}
+	}
+
+	var requests []recordedRequest
+	for _, file := range fs.Args() {
+		loaded, err := loadRecording(file)
+		if err != nil {
+			replayLogger.Error("读取录制文件失败", "file", file, "err", err)
+			return 1
+		}
+		replayLogger.Info("读取录制文件", "file", file, "requests", len(loaded))
+		requests = append(requests, loaded...)
+	}
+	if pattern != nil {
+		filtered := requests[:0]
+		for _, r := range requests {
+			if pattern.MatchString(r.path) {
+				filtered = append(filtered, r)
+			}
+		}
+		requests = filtered
+	}
+	if len(requests) == 0 {
+		replayLogger.Error("没有需要回放的请求")
+		return 1
+	}
+	schedule(requests, *speed, *maxGap)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r := &replayer{
+		target: strings.TrimRight(*target, "/"),
+		header: header,
+		client: &http.Client{Timeout: *timeout},
+	}
+	replayLogger.Info("开始回放", "target", r.target, "requests", len(requests), "speed", *speed,
+		"span", requests[len(requests)-1].offset.Round(time.Millisecond))
+	report, late, maxLag := r.run(ctx, requests, *concurrency)
+	if late > 0 {
+		replayLogger.Warn("部分请求晚于计划时间发送，目标服务响应慢或 -concurrency 不足", "late", late, "max_lag", maxLag.Round(time.Millisecond))
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Print(report.format("回放完成"))
+	}
+	if report.Total.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// 按录制时间排序，把时间换算为回放时的相对时间
+func schedule(requests []recordedRequest, speed float64, maxGap time.Duration) {
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].offset < requests[j].offset })
+	first := requests[0].offset
+	var previous, shifted time.Duration
+	for i := range requests {
+		recorded := requests[i].offset - first
+		gap := recorded - previous
+		previous = recorded
+		if speed == 0 {
+			gap = 0
+		} else {
+			gap = time.Duration(float64(gap) / speed)
+		}
+		if maxGap > 0 && gap > maxGap {
+			gap = maxGap
+		}
+		shifted += gap
+		requests[i].offset = shifted
+	}
+}
+
+type replayer struct {
+	target string
+	header http.Header
+	client *http.Client
+}
+
+// 每个请求在计划时间单独发送，保持录制时的并发；同时进行的请求达到 concurrency 时等待。
+// 返回结果和延迟发送的请求数、最大延迟
+func (r *replayer) run(ctx context.Context, requests []recordedRequest, concurrency int) (*AttackReport, int, time.Duration) {
+	stats := newAttackStats()
+	slots := make(chan struct{}, max(concurrency, 1))
+	var (
+		wg     sync.WaitGroup
+		late   int
+		maxLag time.Duration
+	)
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+loop:
+	for _, req := range requests {
+		if wait := time.Until(start.Add(req.offset)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				break loop
+			}
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		if lag := time.Since(start.Add(req.offset)); lag > replayLagThreshold {
+			late++
+			maxLag = max(maxLag, lag)
+		}
+		wg.Add(1)
+		go func(req recordedRequest) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			status, latency, err := r.send(ctx, req)
+			stats.add(req.method+" "+strings.SplitN(req.path, "?", 2)[0], status, latency, err)
+		}(req)
+	}
+	wg.Wait()
+	return stats.report(time.Since(start)), late, maxLag
+}
+
+func (r *replayer) send(ctx context.Context, recorded recordedRequest) (int, time.Duration, error) {
+	var body io.Reader
+	if len(recorded.body) > 0 {
+		body = bytes.NewReader(recorded.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, recorded.method, r.target+recorded.path, body)
+	if err != nil {
+		return 0, 0, err
+	}
+	for name, values := range recorded.header {
+		req.Header[name] = values
+	}
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	start := time.Now()
+	res, err := r.client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return res.StatusCode, time.Since(start), nil
+}
+
+// 回放时不转发的请求头，由 http.Client 按目标服务重新设置
+var skippedReplayHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Connection": true, "Accept-Encoding": true,
+	"Keep-Alive": true, "Transfer-Encoding": true, "Upgrade": true, "Te": true,
+}
+
+// har 只包含回放需要的字段
+type har struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// 读取 HAR 或请求日志，按文件内容判断格式
+func loadRecording(file string) ([]recordedRequest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty file")
+	}
+	if data[0] == '[' {
+		var entries []RequestEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", file, err)
+		}
+		return fromRequestLog(entries), nil
+	}
+	var h har
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", file, err)
+	}
+	if len(h.Log.Entries) == 0 {
+		return nil, fmt.Errorf("%s is neither a har file nor a request log", file)
+	}
+	var requests []recordedRequest
+	for _, entry := range h.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url %q: %v", entry.Request.URL, err)
+		}
+		req := recordedRequest{
+			offset: time.Duration(entry.StartedDateTime.UnixNano()),
+			method: strings.ToUpper(entry.Request.Method),
+			path:   u.RequestURI(),
+			header: http.Header{},
+		}
+		for _, h := range entry.Request.Headers {
+			name := http.CanonicalHeaderKey(h.Name)
+			if strings.HasPrefix(h.Name, ":") || skippedReplayHeaders[name] {
+				continue
+			}
+			req.header.Add(name, h.Value)
+		}
+		if entry.Request.PostData != nil {
+			req.body = []byte(entry.Request.PostData.Text)
+			if req.header.Get("Content-Type") == "" && entry.Request.PostData.MimeType != "" {
+				req.header.Set("Content-Type", entry.Request.PostData.MimeType)
+			}
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// 请求日志没有请求头，请求体是 JSON 时补上 Content-Type；超过 maxLoggedBody 被截断的请求体原样发送
+func fromRequestLog(entries []RequestEntry) []recordedRequest {
+	requests := make([]recordedRequest, 0, len(entries))
+	for _, entry := range entries {
+		req := recordedRequest{
+			offset: time.Duration(entry.Time.UnixNano()),
+			method: entry.Method,
+			path:   entry.Path,
+			header: http.Header{},
+			body:   []byte(entry.Body),
+		}
+		if entry.Query != "" {
+			req.path += "?" + entry.Query
+		}
+		if json.Valid(req.body) {
+			req.header.Set("Content-Type", "application/json")
+		}
+		requests = append(requests, req)
+	}
+	return requests
+}
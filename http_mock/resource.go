@@ -0,0 +1,282 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Resource 有状态的 CRUD 资源，url 为集合路径（如 /users），不需要设置 method 和 response：
+// POST 集合保存请求体，GET 集合返回全部对象（query 参数按字段过滤），
+// GET、PUT、PATCH、DELETE <url>/:id 读取、替换、合并和删除单个对象。
+// 数据只保存在内存中，管理接口修改配置和热加载后保留，重启后清空
+type Resource struct {
+	ID   string `json:"id,omitempty"`   // 对象中作为主键的字段，默认 id，POST 时没有该字段按自增整数生成
+	Data string `json:"data,omitempty"` // 启动时预先加载的对象，CSV（第一行为列名）或 JSON 对象数组，格式同 table.file
+}
+
+// 单个对象路由的路径参数名
+const resourceParam = "id"
+
+// resourceStore 一个资源集合中的对象，按创建顺序返回
+type resourceStore struct {
+	mu    sync.Mutex
+	order []string
+	items map[string]map[string]interface{}
+	next  int64
+	data  string // 已经加载的 data 文件，热加载时不重复加载
+}
+
+// resourceStores 按集合路径保存资源，重建路由后数据不会丢失
+type resourceStores struct {
+	mu     sync.Mutex
+	stores map[string]*resourceStore
+}
+
+func newResourceStores() *resourceStores {
+	return &resourceStores{stores: map[string]*resourceStore{}}
+}
+
+func (s *resourceStores) store(url string) *resourceStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	store, ok := s.stores[url]
+	if !ok {
+		store = &resourceStore{items: map[string]map[string]interface{}{}}
+		s.stores[url] = store
+	}
+	return store
+}
+
+// 保存新对象，没有主键时生成，主键已存在时返回 false
+func (s *resourceStore) create(idField string, item map[string]interface{}) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := item[idField]; ok && v != nil {
+		id := toText(v)
+		if _, exists := s.items[id]; exists {
+			return nil, false
+		}
+		s.put(id, item)
+		return item, true
+	}
+	// 跳过客户端已经使用的整数主键
+	for {
+		s.next++
+		if _, exists := s.items[strconv.FormatInt(s.next, 10)]; !exists {
+			break
+		}
+	}
+	item[idField] = s.next
+	s.put(strconv.FormatInt(s.next, 10), item)
+	return item, true
+}
+
+// 加载 data 文件中的对象，同一个文件只加载一次，已有的主键不覆盖
+func (s *resourceStore) preload(idField, file string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if file == "" || file == s.data {
+		return 0, nil
+	}
+	var rows []map[string]interface{}
+	var err error
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		rows, err = readCSVTable(file)
+	} else {
+		rows, err = readJSONTable(file)
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.data = file
+	loaded := 0
+	for i, row := range rows {
+		v, ok := row[idField]
+		if !ok || v == nil {
+			return loaded, fmt.Errorf("%s: row %d has no field %s", file, i+1, idField)
+		}
+		id := toText(v)
+		if _, exists := s.items[id]; exists {
+			continue
+		}
+		s.put(id, row)
+		loaded++
+		// 之后 POST 生成的整数主键排在已加载的之后
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil && n > s.next {
+			s.next = n
+		}
+	}
+	return loaded, nil
+}
+
+func (s *resourceStore) put(id string, item map[string]interface{}) {
+	if _, exists := s.items[id]; !exists {
+		s.order = append(s.order, id)
+	}
+	s.items[id] = item
+}
+
+func (s *resourceStore) get(id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	return item, ok
+}
+
+// 按创建顺序返回字段值都和 filter 相同的对象
+func (s *resourceStore) list(filter map[string]string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]map[string]interface{}, 0, len(s.order))
+	for _, id := range s.order {
+		item := s.items[id]
+		matched := true
+		for k, v := range filter {
+			if field, ok := item[k]; !ok || toText(field) != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 替换（merge 为 false）或合并对象的字段，对象不存在时返回 false。主键始终为路径中的值
+func (s *resourceStore) update(idField, id string, fields map[string]interface{}, merge bool) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.items[id]
+	if !ok {
+		return nil, false
+	}
+	item := fields
+	if merge {
+		// 复制一份，之前返回给其他请求的对象不受影响
+		item = make(map[string]interface{}, len(current)+len(fields))
+		for k, v := range current {
+			item[k] = v
+		}
+		for k, v := range fields {
+			item[k] = v
+		}
+	}
+	item[idField] = current[idField]
+	s.items[id] = item
+	return item, true
+}
+
+func (s *resourceStore) delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return false
+	}
+	delete(s.items, id)
+	for i, v := range s.order {
+		if v == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// 注册资源的集合和单个对象路由
+func (h *HttpMockHandler) registerResource(router *gin.Engine, config MockConfig) {
+	idField := config.Resource.ID
+	if idField == "" {
+		idField = "id"
+	}
+	store := h.resources.store(config.URL)
+	if n, err := store.preload(idField, config.Resource.Data); err != nil {
+		logger.Error("加载资源数据失败", "url", config.URL, "data", config.Resource.Data, "err", err)
+	} else if n > 0 {
+		logger.Info("已加载资源数据", "url", config.URL, "count", n)
+	}
+	usage := h.usage.route(config.Method, config.URL)
+	collection := ginRoute(strings.TrimSuffix(config.URL, "/"))
+	item := collection + "/:" + resourceParam
+	notFound := func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s %s not found", idField, c.Param(resourceParam))})
+	}
+
+	router.POST(collection, func(c *gin.Context) {
+		usage.hit()
+		fields, ok := bindResource(c)
+		if !ok {
+			return
+		}
+		created, ok := store.create(idField, fields)
+		if !ok {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s %v already exists", idField, fields[idField])})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	})
+	router.GET(collection, func(c *gin.Context) {
+		usage.hit()
+		filter := make(map[string]string)
+		for k, v := range c.Request.URL.Query() {
+			filter[k] = v[0]
+		}
+		c.JSON(http.StatusOK, store.list(filter))
+	})
+	router.GET(item, func(c *gin.Context) {
+		usage.hit()
+		found, ok := store.get(c.Param(resourceParam))
+		if !ok {
+			notFound(c)
+			return
+		}
+		c.JSON(http.StatusOK, found)
+	})
+	update := func(merge bool) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			usage.hit()
+			fields, ok := bindResource(c)
+			if !ok {
+				return
+			}
+			updated, ok := store.update(idField, c.Param(resourceParam), fields, merge)
+			if !ok {
+				notFound(c)
+				return
+			}
+			c.JSON(http.StatusOK, updated)
+		}
+	}
+	router.PUT(item, update(false))
+	router.PATCH(item, update(true))
+	router.DELETE(item, func(c *gin.Context) {
+		usage.hit()
+		if !store.delete(c.Param(resourceParam)) {
+			notFound(c)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// 读取 JSON 对象请求体，失败时返回 400
+func bindResource(c *gin.Context) (map[string]interface{}, bool) {
+	var fields map[string]interface{}
+	data, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(data, &fields)
+	}
+	if err != nil || fields == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request body must be a json object"})
+		return nil, false
+	}
+	return fields, true
+}
@@ -0,0 +1,55 @@
+package http_mock
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// OpenAPI 风格的路径参数，如 /users/{id}
+var braceParamPattern = regexp.MustCompile(`\{([A-Za-z_][\w-]*)\}`)
+
+// 把配置中的 /users/{id} 转换为 gin 的 /users/:id，gin 风格的 :id 和 *path 原样保留
+func ginRoute(url string) string {
+	return braceParamPattern.ReplaceAllString(url, ":$1")
+}
+
+// 路由中的路径参数名
+func routeParams(url string) []string {
+	var params []string
+	for _, segment := range strings.Split(ginRoute(url), "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+		}
+	}
+	return params
+}
+
+// 响应体中 {{param('name')}} 或 {{.param.name}} 引用的参数名
+var paramRefPattern = regexp.MustCompile(`\bparam\(\s*['"]([^'"]*)['"]\s*\)|(?:^|[^\w.])\.params?\.([\w-]+)`)
+
+// 检查响应体引用的路径参数是否都在路由中，返回 "路径: 问题" 形式的说明
+func lintParamRefs(path string, body interface{}, params []string) []string {
+	var problems []string
+	switch v := body.(type) {
+	case string:
+		for _, m := range templateExprPattern.FindAllStringSubmatch(v, -1) {
+			for _, ref := range paramRefPattern.FindAllStringSubmatch(m[1], -1) {
+				name := ref[1] + ref[2]
+				if !slices.Contains(params, name) {
+					problems = append(problems, fmt.Sprintf("%s: param(%q) is not a path parameter of the url, it renders as null", path, name))
+				}
+			}
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			problems = append(problems, lintParamRefs(path+"."+k, item, params)...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			problems = append(problems, lintParamRefs(fmt.Sprintf("%s[%d]", path, i), item, params)...)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,599 @@
+package http_mock
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// S3 兼容接口的路径前缀，SDK 的 endpoint 设置为 http://localhost:8080/__s3 并使用 path-style 访问
+const s3Prefix = "/__s3"
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// S3 响应中的时间格式
+const s3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// s3Server 每个 bucket 对应 dir 下的一个目录，对象以 base64url 编码的 key 为文件名，元数据保存在同名的 .json 文件中
+type s3Server struct {
+	dir       string
+	accessKey string
+	secretKey string
+
+	mu sync.RWMutex // 保证对象内容和元数据文件一起替换
+}
+
+// 保存在 .json 文件中的对象元数据
+type s3Meta struct {
+	ETag    string            `json:"etag"`
+	Headers map[string]string `json:"headers"` // Content-Type、x-amz-meta-* 等，GET 时原样返回
+}
+
+// 保存到元数据中的请求头，另外还有所有 x-amz-meta-* 头
+var s3StoredHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Content-Language", "Cache-Control", "Expires"}
+
+// s3Error S3 的错误响应
+type s3Error struct {
+	status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string { return e.Code + ": " + e.Message }
+
+var (
+	errNoSuchBucket = &s3Error{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist"}
+	errNoSuchKey    = &s3Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+)
+
+func newS3Server(dir, accessKey, secretKey string) (*s3Server, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &s3Server{dir: dir, accessKey: accessKey, secretKey: secretKey}, nil
+}
+
+func (s *s3Server) register(router gin.IRouter) {
+	group := router.Group(s3Prefix)
+	group.Use(s.authenticate)
+	group.GET("", s.listBuckets)
+	group.Any("/*path", s.handle)
+}
+
+// 校验请求头或预签名 URL 中的 SigV4 签名，没有签名的请求按匿名访问处理
+func (s *s3Server) authenticate(c *gin.Context) {
+	if err := s.verifySignature(c.Request, time.Now()); err != nil {
+		logger.Debug("S3 签名校验失败", "path", c.Request.URL.Path, "err", err)
+		writeS3Error(c, err)
+		c.Abort()
+	}
+}
+
+// 按路径和查询参数分发到 bucket 或对象的操作
+func (s *s3Server) handle(c *gin.Context) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Param("path"), "/"), "/")
+	query := c.Request.URL.Query()
+	if bucket == "" {
+		if c.Request.Method != http.MethodGet {
+			writeS3Error(c, &s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource."})
+			return
+		}
+		s.listBuckets(c)
+		return
+	}
+	for _, sub := range []string{"uploads", "uploadId", "acl", "tagging", "versioning", "versions", "policy", "cors", "lifecycle"} {
+		if query.Has(sub) {
+			writeS3Error(c, &s3Error{http.StatusNotImplemented, "NotImplemented", "?" + sub + " is not supported by this mock"})
+			return
+		}
+	}
+
+	var err error
+	switch {
+	case key == "" && c.Request.Method == http.MethodPut:
+		err = s.createBucket(c, bucket)
+	case key == "" && c.Request.Method == http.MethodHead:
+		err = s.headBucket(c, bucket)
+	case key == "" && c.Request.Method == http.MethodDelete:
+		err = s.deleteBucket(c, bucket)
+	case key == "" && c.Request.Method == http.MethodGet && query.Has("location"):
+		err = s.bucketLocation(c, bucket)
+	case key == "" && c.Request.Method == http.MethodGet:
+		err = s.listObjects(c, bucket, query)
+	case key == "" && c.Request.Method == http.MethodPost && query.Has("delete"):
+		err = s.deleteObjects(c, bucket)
+	case key == "":
+		err = &s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource."}
+	case c.Request.Method == http.MethodPut:
+		err = s.putObject(c, bucket, key)
+	case c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead:
+		err = s.getObject(c, bucket, key)
+	case c.Request.Method == http.MethodDelete:
+		err = s.deleteObject(c, bucket, key)
+	default:
+		err = &s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource."}
+	}
+	if err != nil {
+		writeS3Error(c, err)
+	}
+}
+
+func (s *s3Server) bucketDir(bucket string) (string, error) {
+	if !bucketName.MatchString(bucket) {
+		return "", &s3Error{http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid."}
+	}
+	dir := filepath.Join(s.dir, bucket)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return dir, errNoSuchBucket
+	}
+	return dir, nil
+}
+
+func objectFile(dir, key string) string {
+	return filepath.Join(dir, base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+func (s *s3Server) listBuckets(c *gin.Context) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		writeS3Error(c, err)
+		return
+	}
+	result := s3ListAllMyBucketsResult{Owner: s3Owner{ID: s.accessKey, DisplayName: s.accessKey}}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		result.Buckets = append(result.Buckets, s3Bucket{Name: entry.Name(), CreationDate: info.ModTime().UTC().Format(s3TimeFormat)})
+	}
+	writeXML(c, http.StatusOK, result)
+}
+
+func (s *s3Server) createBucket(c *gin.Context, bucket string) error {
+	dir, err := s.bucketDir(bucket)
+	if err == nil {
+		return &s3Error{http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it."}
+	}
+	if err != errNoSuchBucket {
+		return err
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	logger.Info("创建 bucket", "bucket", bucket)
+	c.Header("Location", "/"+bucket)
+	c.Status(http.StatusOK)
+	return nil
+}
+
+func (s *s3Server) headBucket(c *gin.Context, bucket string) error {
+	if _, err := s.bucketDir(bucket); err != nil {
+		return err
+	}
+	c.Status(http.StatusOK)
+	return nil
+}
+
+func (s *s3Server) deleteBucket(c *gin.Context, bucket string) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return &s3Error{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty"}
+	}
+	if err := os.Remove(dir); err != nil {
+		return err
+	}
+	c.Status(http.StatusNoContent)
+	return nil
+}
+
+// 返回空的 LocationConstraint，即 us-east-1
+func (s *s3Server) bucketLocation(c *gin.Context, bucket string) error {
+	if _, err := s.bucketDir(bucket); err != nil {
+		return err
+	}
+	writeXML(c, http.StatusOK, s3LocationConstraint{})
+	return nil
+}
+
+// 支持 ListObjects 和 ListObjectsV2（list-type=2）
+func (s *s3Server) listObjects(c *gin.Context, bucket string, query url.Values) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	v2 := query.Get("list-type") == "2"
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := 1000
+	if v := query.Get("max-keys"); v != "" {
+		if maxKeys, err = strconv.Atoi(v); err != nil || maxKeys < 0 {
+			return &s3Error{http.StatusBadRequest, "InvalidArgument", "Invalid max-keys"}
+		}
+		maxKeys = min(maxKeys, 1000)
+	}
+	// 从 marker 之后开始列出，v2 的 continuation-token 为上一页最后一个 key 的 base64
+	marker := query.Get("marker")
+	if v2 {
+		marker = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return &s3Error{http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect"}
+			}
+			marker = string(decoded)
+		}
+	}
+
+	s.mu.RLock()
+	entries, err := os.ReadDir(dir)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	var keys []string
+	files := make(map[string]fs.DirEntry)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name, err := base64.RawURLEncoding.DecodeString(entry.Name())
+		if err != nil || !strings.HasPrefix(string(name), prefix) || string(name) <= marker {
+			continue
+		}
+		keys = append(keys, string(name))
+		files[string(name)] = entry
+	}
+	sort.Strings(keys)
+
+	encode := func(s string) string { return s }
+	if query.Get("encoding-type") == "url" {
+		encode = url.QueryEscape
+	}
+	result := s3ListBucketResult{
+		Name:         bucket,
+		Prefix:       encode(prefix),
+		Delimiter:    encode(delimiter),
+		MaxKeys:      maxKeys,
+		EncodingType: query.Get("encoding-type"),
+	}
+	// 按 delimiter 合并成 CommonPrefixes，每个公共前缀计为一个结果
+	seen := make(map[string]bool)
+	var last string
+	count := 0
+	for _, key := range keys {
+		common := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if common != "" && seen[common] {
+			last = key
+			continue
+		}
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+		last = key
+		if common != "" {
+			seen[common] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: encode(common)})
+			continue
+		}
+		info, err := files[key].Info()
+		if err != nil {
+			continue
+		}
+		meta, _ := s.readMeta(objectFile(dir, key))
+		result.Contents = append(result.Contents, s3Object{
+			Key:          encode(key),
+			LastModified: info.ModTime().UTC().Format(s3TimeFormat),
+			ETag:         meta.ETag,
+			Size:         info.Size(),
+			StorageClass: "STANDARD",
+		})
+	}
+	if v2 {
+		result.KeyCount = &count
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = encode(query.Get("start-after"))
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		result.Marker = encode(marker)
+		if result.IsTruncated {
+			result.NextMarker = encode(last)
+		}
+	}
+	writeXML(c, http.StatusOK, result)
+	return nil
+}
+
+func (s *s3Server) putObject(c *gin.Context, bucket, key string) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	if c.GetHeader("X-Amz-Copy-Source") != "" {
+		return &s3Error{http.StatusNotImplemented, "NotImplemented", "CopyObject is not supported by this mock"}
+	}
+	body := io.Reader(c.Request.Body)
+	if isAWSChunked(c.Request) {
+		body = newAWSChunkedReader(c.Request.Body)
+	}
+
+	// 先写临时文件，完整写入后再替换，失败时不影响已有的对象
+	tmp, err := os.CreateTemp(dir, ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	tmp.Close()
+	if err != nil {
+		return &s3Error{http.StatusBadRequest, "IncompleteBody", err.Error()}
+	}
+
+	meta := s3Meta{ETag: `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, Headers: make(map[string]string)}
+	for _, name := range s3StoredHeaders {
+		if v := c.GetHeader(name); v != "" {
+			meta.Headers[name] = v
+		}
+	}
+	// aws-chunked 只是传输编码，不属于对象本身
+	if encoding := removeToken(meta.Headers["Content-Encoding"], "aws-chunked"); encoding != "" {
+		meta.Headers["Content-Encoding"] = encoding
+	} else {
+		delete(meta.Headers, "Content-Encoding")
+	}
+	for name, values := range c.Request.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			meta.Headers[name] = values[0]
+		}
+	}
+	data, _ := json.Marshal(meta)
+
+	path := objectFile(dir, key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.WriteFile(path+".json", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	logger.Debug("上传对象", "bucket", bucket, "key", key)
+	c.Header("ETag", meta.ETag)
+	c.Status(http.StatusOK)
+	return nil
+}
+
+// GET 和 HEAD，Range、If-None-Match 等条件请求由 http.ServeContent 处理
+func (s *s3Server) getObject(c *gin.Context, bucket, key string) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	path := objectFile(dir, key)
+	s.mu.RLock()
+	f, err := os.Open(path)
+	var meta s3Meta
+	if err == nil {
+		meta, err = s.readMeta(path)
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			if c.Request.Method == http.MethodHead {
+				c.Status(http.StatusNotFound)
+				return nil
+			}
+			return errNoSuchKey
+		}
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	for name, v := range meta.Headers {
+		c.Header(name, v)
+	}
+	if meta.Headers["Content-Type"] == "" {
+		c.Header("Content-Type", "binary/octet-stream")
+	}
+	c.Header("ETag", meta.ETag)
+	c.Header("Accept-Ranges", "bytes")
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), f)
+	return nil
+}
+
+// 删除不存在的对象同样返回 204
+func (s *s3Server) deleteObject(c *gin.Context, bucket, key string) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	s.removeObject(dir, key)
+	c.Status(http.StatusNoContent)
+	return nil
+}
+
+func (s *s3Server) deleteObjects(c *gin.Context, bucket string) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	var request s3DeleteRequest
+	if err := xml.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		return &s3Error{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed"}
+	}
+	var result s3DeleteResult
+	for _, object := range request.Objects {
+		s.removeObject(dir, object.Key)
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, s3Deleted{Key: object.Key})
+		}
+	}
+	writeXML(c, http.StatusOK, result)
+	return nil
+}
+
+func (s *s3Server) removeObject(dir, key string) {
+	path := objectFile(dir, key)
+	s.mu.Lock()
+	os.Remove(path)
+	os.Remove(path + ".json")
+	s.mu.Unlock()
+}
+
+func (s *s3Server) readMeta(path string) (s3Meta, error) {
+	var meta s3Meta
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return meta, err
+	}
+	return meta, json.Unmarshal(data, &meta)
+}
+
+// 从逗号分隔的列表中去掉 token
+func removeToken(list, token string) string {
+	var kept []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" && item != token {
+			kept = append(kept, item)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+func writeXML(c *gin.Context, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		writeS3Error(c, err)
+		return
+	}
+	c.Data(status, "application/xml", append([]byte(xml.Header), data...))
+}
+
+func writeS3Error(c *gin.Context, err error) {
+	var e *s3Error
+	if !errors.As(err, &e) {
+		logger.Error("S3 请求失败", "path", c.Request.URL.Path, "err", err)
+		e = &s3Error{http.StatusInternalServerError, "InternalError", err.Error()}
+	}
+	// HEAD 请求的错误响应没有响应体
+	if c.Request.Method == http.MethodHead {
+		c.Status(e.status)
+		return
+	}
+	writeXML(c, e.status, s3ErrorResponse{Code: e.Code, Message: e.Message, Resource: c.Request.URL.Path, RequestID: newUploadID()[:16]})
+}
+
+type s3ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string
+	RequestID string `xml:"RequestId"`
+}
+
+type s3Owner struct {
+	ID          string
+	DisplayName string
+}
+
+type s3Bucket struct {
+	Name         string
+	CreationDate string
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3LocationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+}
+
+type s3Object struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type s3CommonPrefix struct {
+	Prefix string
+}
+
+type s3ListBucketResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	Marker                string `xml:",omitempty"`
+	NextMarker            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	KeyCount              *int   `xml:",omitempty"`
+	MaxKeys               int
+	EncodingType          string `xml:",omitempty"`
+	IsTruncated           bool
+	Contents              []s3Object
+	CommonPrefixes        []s3CommonPrefix
+}
+
+type s3DeleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type s3Deleted struct {
+	Key string
+}
+
+type s3DeleteResult struct {
+	XMLName xml.Name    `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []s3Deleted `xml:"Deleted"`
+}
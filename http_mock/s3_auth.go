@@ -0,0 +1,252 @@
+package http_mock
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4，见 https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// 签名参数，来自 Authorization 头或预签名 URL 的查询参数
+type sigV4 struct {
+	accessKey     string
+	scope         string // 日期/区域/服务/aws4_request
+	date          string
+	signedHeaders []string
+	signature     string
+	payloadHash   string
+	presigned     bool
+}
+
+func (s *s3Server) verifySignature(r *http.Request, now time.Time) error {
+	sig, err := parseSigV4(r)
+	if err != nil || sig == nil {
+		return err
+	}
+	if sig.accessKey != s.accessKey {
+		return &s3Error{http.StatusForbidden, "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records."}
+	}
+	signedAt, err := time.Parse(sigV4TimeFormat, sig.date)
+	if err != nil {
+		return &s3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "invalid X-Amz-Date " + sig.date}
+	}
+	if sig.presigned {
+		expires, err := strconv.Atoi(r.URL.Query().Get("X-Amz-Expires"))
+		if err != nil || expires <= 0 || expires > 7*24*3600 {
+			return &s3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires must be between 1 and 604800 seconds"}
+		}
+		if now.After(signedAt.Add(time.Duration(expires) * time.Second)) {
+			return &s3Error{http.StatusForbidden, "AccessDenied", "Request has expired"}
+		}
+	}
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		sig.date,
+		sig.scope,
+		hexSHA256(canonicalRequest(r, sig)),
+	}, "\n")
+	scope := strings.Split(sig.scope, "/")
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range scope {
+		key = hmacSHA256(key, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return &s3Error{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method."}
+	}
+	return nil
+}
+
+// 没有签名时返回 nil
+func parseSigV4(r *http.Request) (*sigV4, error) {
+	query := r.URL.Query()
+	if query.Has("X-Amz-Signature") {
+		if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+			return nil, &s3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Algorithm only supports " + sigV4Algorithm}
+		}
+		sig := &sigV4{
+			date:          query.Get("X-Amz-Date"),
+			signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+			signature:     query.Get("X-Amz-Signature"),
+			payloadHash:   unsignedPayload,
+			presigned:     true,
+		}
+		sig.accessKey, sig.scope, _ = strings.Cut(query.Get("X-Amz-Credential"), "/")
+		return sig, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, nil
+	}
+	params, ok := strings.CutPrefix(auth, sigV4Algorithm+" ")
+	if !ok {
+		return nil, &s3Error{http.StatusBadRequest, "InvalidRequest", "only " + sigV4Algorithm + " signatures are supported"}
+	}
+	sig := &sigV4{date: r.Header.Get("X-Amz-Date"), payloadHash: r.Header.Get("X-Amz-Content-Sha256")}
+	for _, param := range strings.Split(params, ",") {
+		name, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "Credential":
+			sig.accessKey, sig.scope, _ = strings.Cut(v, "/")
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(v, ";")
+		case "Signature":
+			sig.signature = v
+		}
+	}
+	if sig.payloadHash == "" {
+		sig.payloadHash = hexSHA256("")
+	}
+	if sig.date == "" {
+		return nil, &s3Error{http.StatusBadRequest, "InvalidRequest", "X-Amz-Date is required"}
+	}
+	return sig, nil
+}
+
+func canonicalRequest(r *http.Request, sig *sigV4) string {
+	var headers strings.Builder
+	for _, name := range sig.signedHeaders {
+		var v string
+		// Go 把 Host 和 Content-Length 从 Header 中移到了单独的字段
+		switch name {
+		case "host":
+			v = r.Host
+		case "content-length":
+			v = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			v = strings.Join(values, ",")
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, v)
+	}
+	return strings.Join([]string{
+		r.Method,
+		sigV4Escape(r.URL.Path, false),
+		canonicalQuery(r.URL.Query(), sig.presigned),
+		headers.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		sig.payloadHash,
+	}, "\n")
+}
+
+// 按参数名和值排序，预签名 URL 的 X-Amz-Signature 不参与签名
+func canonicalQuery(query url.Values, presigned bool) string {
+	var pairs []string
+	for name, values := range query {
+		if presigned && name == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, sigV4Escape(name, true)+"="+sigV4Escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// 除 A-Z a-z 0-9 - _ . ~ 外全部编码，路径中的 / 不编码
+func sigV4Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' && !escapeSlash {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// SDK 上传时可能使用 aws-chunked 编码，每块前面是十六进制长度和块签名，最后是 trailer 中的校验和
+func isAWSChunked(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") ||
+		strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked")
+}
+
+// awsChunkedReader 解码 aws-chunked 请求体，不校验块签名和 trailer
+type awsChunkedReader struct {
+	r         *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+func newAWSChunkedReader(r io.Reader) *awsChunkedReader {
+	return &awsChunkedReader{r: bufio.NewReader(r)}
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.r.Read(p[:min(int64(len(p)), c.remaining)])
+	c.remaining -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && c.remaining == 0 {
+		// 块数据后面的 \r\n
+		if _, err = c.r.Discard(2); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, err
+}
+
+// 读取块头 <hex 长度>[;chunk-signature=...]\r\n，长度为 0 时读完 trailer
+func (c *awsChunkedReader) nextChunk() error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	size, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+	n, err := strconv.ParseInt(size, 16, 64)
+	if err != nil || n < 0 {
+		return errors.New("invalid aws-chunked chunk size " + strconv.Quote(size))
+	}
+	if n == 0 {
+		c.done = true
+		io.Copy(io.Discard, c.r)
+		return nil
+	}
+	c.remaining = n
+	return nil
+}
@@ -0,0 +1,210 @@
+package http_mock
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Scenario 多步骤流程（如 登录 → 创建 → 轮询 → 删除），步骤之间通过 capture 传递变量。
+// 同一份定义既可以作为有状态的 mock 服务运行，也可以作为客户端压测脚本运行
+type Scenario struct {
+	Name  string                 `yaml:"name"`
+	Vars  map[string]interface{} `yaml:"vars"` // 初始变量
+	Steps []ScenarioStep         `yaml:"steps"`
+}
+
+// ScenarioStep 流程中的一步。字符串中的 ${name} 替换为变量，@ 开头的占位符按 value 包生成
+type ScenarioStep struct {
+	Name     string            `yaml:"name"`
+	Request  StepRequest       `yaml:"request"`
+	Response StepResponse      `yaml:"response"`
+	Capture  map[string]string `yaml:"capture"` // 变量名 -> 取值路径，如 body.token、header.Location、status
+	Poll     *StepPoll         `yaml:"poll"`    // 客户端重复请求直到满足条件
+	Delete   bool              `yaml:"delete"`  // 服务端在响应后删除路径参数对应的对象
+}
+
+// StepRequest 请求定义，服务端用 method 和 path 注册路由并校验 headers，客户端据此发送请求
+type StepRequest struct {
+	Method  string                 `yaml:"method"`
+	Path    string                 `yaml:"path"`
+	Headers map[string]string      `yaml:"headers"`
+	Query   map[string]interface{} `yaml:"query"`
+	Body    interface{}            `yaml:"body"`
+}
+
+// StepResponse 响应定义，服务端据此生成响应，客户端校验状态码
+type StepResponse struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    interface{}       `yaml:"body"`
+}
+
+// StepPoll 轮询条件，until 形如 body.state == ready 或 status != 404
+type StepPoll struct {
+	Until    string        `yaml:"until"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// LoadScenario 读取并校验场景文件
+func LoadScenario(path string) (*Scenario, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scenario Scenario
+	if err := yaml.Unmarshal(content, &scenario); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %v", path, err)
+	}
+	if len(scenario.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", path)
+	}
+	for i := range scenario.Steps {
+		step := &scenario.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if step.Request.Method == "" {
+			step.Request.Method = "GET"
+		}
+		step.Request.Method = strings.ToUpper(step.Request.Method)
+		if !strings.HasPrefix(step.Request.Path, "/") {
+			return nil, fmt.Errorf("step %s: path must start with /", step.Name)
+		}
+		if step.Poll != nil {
+			if _, err := parseCondition(step.Poll.Until); err != nil {
+				return nil, fmt.Errorf("step %s: %v", step.Name, err)
+			}
+			if step.Poll.Interval <= 0 {
+				step.Poll.Interval = time.Second
+			}
+			if step.Poll.Timeout <= 0 {
+				step.Poll.Timeout = 30 * time.Second
+			}
+		}
+	}
+	return &scenario, nil
+}
+
+var varPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// 替换 v 中所有字符串里的 ${name}，name 可以是点分路径。整个字符串只有一个变量时保留变量的原始类型
+func expandVars(v interface{}, vars map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if m := varPattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			if value, ok := lookupPath(vars, strings.TrimSpace(m[1])); ok {
+				return value
+			}
+			return ""
+		}
+		return varPattern.ReplaceAllStringFunc(v, func(s string) string {
+			value, _ := lookupPath(vars, strings.TrimSpace(s[2:len(s)-1]))
+			if value == nil {
+				return ""
+			}
+			return fmt.Sprint(value)
+		})
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = expandVars(item, vars)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = expandVars(item, vars)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func expandString(s string, vars map[string]interface{}) string {
+	return fmt.Sprint(expandVars(s, vars))
+}
+
+// 按点分路径取值，数组用下标，如 body.items.0.id
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return nil, false
+			}
+		case map[string]string:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// 把路径中的 ${name} 转换为 gin 的路径参数 :name，返回转换后的路径和参数名
+func routePath(path string) (string, []string) {
+	var params []string
+	route := varPattern.ReplaceAllStringFunc(path, func(s string) string {
+		name := strings.TrimSpace(s[2 : len(s)-1])
+		params = append(params, name)
+		return ":" + name
+	})
+	return route, params
+}
+
+// condition 轮询条件
+type condition struct {
+	path   string
+	negate bool
+	want   string
+}
+
+func parseCondition(s string) (condition, error) {
+	for _, op := range []string{"!=", "=="} {
+		if left, right, ok := strings.Cut(s, op); ok {
+			return condition{
+				path:   strings.TrimSpace(left),
+				negate: op == "!=",
+				want:   strings.Trim(strings.TrimSpace(right), `"'`),
+			}, nil
+		}
+	}
+	return condition{}, fmt.Errorf("poll.until %q: expected <path> == <value> or <path> != <value>", s)
+}
+
+func (c condition) match(result map[string]interface{}) bool {
+	v, ok := lookupPath(result, c.path)
+	equal := ok && fmt.Sprint(v) == c.want
+	return equal != c.negate
+}
+
+// 按 capture 定义从 result（包含 status、header、body、request）中取出变量
+func capture(spec map[string]string, result map[string]interface{}) (map[string]interface{}, error) {
+	captured := make(map[string]interface{}, len(spec))
+	for name, path := range spec {
+		v, ok := lookupPath(result, path)
+		if !ok {
+			return nil, fmt.Errorf("capture %s: %s not found", name, path)
+		}
+		captured[name] = v
+	}
+	return captured, nil
+}
@@ -0,0 +1,224 @@
+package http_mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/value"
+)
+
+// RunScenarioClient 把场景作为压测脚本运行：每个虚拟用户按顺序执行所有步骤，args 为命令行参数（不含命令名），返回进程退出码
+func RunScenarioClient(args []string) int {
+	fs := flag.NewFlagSet("scenario run", flag.ContinueOnError)
+	file := fs.String("file", "", "scenario yaml file")
+	target := fs.String("target", "", "base url of the service under test, e.g. http://localhost:8080")
+	users := fs.Int("users", 1, "concurrent virtual users, each runs the steps in order")
+	iterations := fs.Int("iterations", 1, "iterations per user, 0 to run for -duration")
+	duration := fs.Duration("duration", time.Minute, "how long to run when -iterations is 0")
+	timeout := fs.Duration("timeout", 5*time.Second, "per request timeout")
+	format := fs.String("format", "text", "report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *file == "" || *target == "" {
+		attackLogger.Error("-file and -target are required")
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		attackLogger.Error("unsupported report format", "format", *format)
+		return 2
+	}
+	scenario, err := LoadScenario(*file)
+	if err != nil {
+		attackLogger.Error("加载场景失败", "err", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *iterations <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	runner := &scenarioRunner{
+		scenario: scenario,
+		target:   strings.TrimRight(*target, "/"),
+		client:   &http.Client{Timeout: *timeout},
+		stats:    newAttackStats(),
+	}
+	attackLogger.Info("开始执行场景", "scenario", scenario.Name, "target", runner.target, "users", *users, "iterations", *iterations)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < max(*users, 1); i++ {
+		wg.Add(1)
+		go func(user int) {
+			defer wg.Done()
+			values := value.NewValueHandler()
+			for n := 0; *iterations <= 0 || n < *iterations; n++ {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := runner.iterate(ctx, values); err != nil && ctx.Err() == nil {
+					attackLogger.Debug("场景执行失败", "user", user, "iteration", n+1, "err", err)
+				}
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+	report := runner.stats.report(time.Since(start))
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Print(report.String())
+	}
+	if report.Total.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+type scenarioRunner struct {
+	scenario *Scenario
+	target   string
+	client   *http.Client
+	stats    *attackStats
+}
+
+// 按顺序执行一遍所有步骤，某一步失败时放弃本轮剩余的步骤
+func (r *scenarioRunner) iterate(ctx context.Context, values *value.Handler) error {
+	vars := make(map[string]interface{}, len(r.scenario.Vars))
+	for k, v := range r.scenario.Vars {
+		vars[k] = v
+	}
+	for i := range r.scenario.Steps {
+		step := &r.scenario.Steps[i]
+		name := fmt.Sprintf("%d.%s", i+1, step.Name)
+		result, err := r.step(ctx, step, name, values, vars)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", step.Name, err)
+		}
+		captured, err := capture(step.Capture, result)
+		if err != nil {
+			r.stats.fail(name, err)
+			return fmt.Errorf("step %s: %v", step.Name, err)
+		}
+		for k, v := range captured {
+			vars[k] = v
+		}
+	}
+	return nil
+}
+
+// 执行一个步骤，有轮询条件时重复请求直到满足或超时，返回最后一次的结果
+func (r *scenarioRunner) step(ctx context.Context, step *ScenarioStep, name string, values *value.Handler, vars map[string]interface{}) (map[string]interface{}, error) {
+	var deadline time.Time
+	var until condition
+	if step.Poll != nil {
+		deadline = time.Now().Add(step.Poll.Timeout)
+		until, _ = parseCondition(step.Poll.Until)
+	}
+	for {
+		result, err := r.send(ctx, step, values, vars)
+		status, _ := result["status"].(int)
+		latency := result["latency"].(time.Duration)
+		if err == nil {
+			if step.Poll != nil && !until.match(result) {
+				if time.Now().Before(deadline) {
+					r.stats.record(name, status, latency, nil)
+					select {
+					case <-time.After(step.Poll.Interval):
+						continue
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				}
+				err = fmt.Errorf("poll %q timed out after %v", step.Poll.Until, step.Poll.Timeout)
+			} else if want := step.Response.Status; want != 0 && status != want {
+				err = fmt.Errorf("status %d, want %d", status, want)
+			} else if want == 0 && status >= 400 {
+				err = fmt.Errorf("status %d", status)
+			}
+		}
+		r.stats.record(name, status, latency, err)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+// 发送步骤的请求，返回 status、header、body、latency 组成的结果
+func (r *scenarioRunner) send(ctx context.Context, step *ScenarioStep, values *value.Handler, vars map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{"latency": time.Duration(0)}
+	u := r.target + expandString(step.Request.Path, vars)
+	if len(step.Request.Query) > 0 {
+		query := expandVars(values.ProcessDynamicMap(step.Request.Query), vars).(map[string]interface{})
+		q := url.Values{}
+		for k, v := range query {
+			q.Set(k, fmt.Sprint(v))
+		}
+		u += "?" + q.Encode()
+	}
+	var reader io.Reader
+	if step.Request.Body != nil {
+		body, err := json.Marshal(expandVars(values.ProcessDynamicValues(step.Request.Body), vars))
+		if err != nil {
+			return result, err
+		}
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, step.Request.Method, u, reader)
+	if err != nil {
+		return result, err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, v := range step.Request.Headers {
+		req.Header.Set(name, expandString(v, vars))
+	}
+
+	start := time.Now()
+	res, err := r.client.Do(req)
+	result["latency"] = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	result["latency"] = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+
+	result["status"] = res.StatusCode
+	result["header"] = headerValues(res.Header)
+	if len(data) > 0 {
+		var body interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			body = string(data)
+		}
+		result["body"] = body
+	}
+	return result, nil
+}
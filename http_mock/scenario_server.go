@@ -0,0 +1,344 @@
+package http_mock
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/gin-gonic/gin"
+)
+
+// RunScenarioServer 把场景作为有状态的 mock 服务运行，args 为命令行参数（不含命令名），返回进程退出码
+func RunScenarioServer(args []string) int {
+	fs := flag.NewFlagSet("scenario serve", flag.ContinueOnError)
+	port := fs.String("port", envOr("SERVE_PORT", ":8080"), "listen address, a bare port listens on all interfaces (env SERVE_PORT)")
+	file := fs.String("file", "", "scenario yaml file")
+	admin := fs.Bool("admin", true, "serve the admin api under "+adminPrefix+" and the web ui under "+uiPrefix+"/")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *file == "" {
+		logger.Error("-file is required")
+		return 2
+	}
+	scenario, err := LoadScenario(*file)
+	if err != nil {
+		logger.Error("加载场景失败", "err", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := newScenarioServer(scenario)
+	server.admin = *admin
+	if err := server.start(ctx, listenAddr(*port)); err != nil {
+		logger.Error("启动服务器失败", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// scenarioServer 按步骤注册路由。capture 得到的每个变量值都记录为一个对象（保存同一步提取的所有变量），
+// 后续请求的路径参数和请求头按模板匹配出变量值，只有对应的对象存在时才返回定义的响应，
+// 这样多个客户端并发执行同一流程时各自的 token、id 互不影响
+type scenarioServer struct {
+	scenario *Scenario
+	values   *value.Handler
+	admin    bool
+	requests *requestLog
+
+	mu       sync.Mutex
+	objects  map[string]map[string]map[string]interface{} // 变量名 -> 变量值 -> 对象的变量
+	captured map[string]bool                              // 由 capture 产生的变量名
+}
+
+func newScenarioServer(scenario *Scenario) *scenarioServer {
+	s := &scenarioServer{
+		scenario: scenario,
+		values:   value.NewValueHandler(),
+		requests: newRequestLog(),
+		objects:  make(map[string]map[string]map[string]interface{}),
+		captured: make(map[string]bool),
+	}
+	for _, step := range scenario.Steps {
+		for name := range step.Capture {
+			s.captured[name] = true
+		}
+	}
+	return s
+}
+
+func (s *scenarioServer) start(ctx context.Context, addr string) error {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(accessLog())
+	if s.admin {
+		router.Use(s.requests.middleware())
+	}
+	router.Use(gin.Recovery())
+	// 方法和路径相同的步骤由第一个步骤响应，例如删除后再次查询时对象已不存在，直接返回 404
+	registered := make(map[string]string)
+	for i := range s.scenario.Steps {
+		step := &s.scenario.Steps[i]
+		route, params := routePath(step.Request.Path)
+		key := step.Request.Method + " " + route
+		if first, ok := registered[key]; ok {
+			logger.Info("场景步骤与之前的步骤路由相同，由之前的步骤响应", "step", step.Name, "same_as", first)
+			continue
+		}
+		registered[key] = step.Name
+		router.Handle(step.Request.Method, route, s.handle(step, params))
+		logger.Info("注册场景步骤", "scenario", s.scenario.Name, "step", step.Name, "method", step.Request.Method, "path", route)
+	}
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	if s.admin {
+		(&adminAPI{requests: s.requests, scenario: s.state}).register(router)
+	}
+
+	server := &http.Server{Addr: addr, Handler: router}
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("场景 Mock 服务器启动", "addr", addr)
+		if s.admin {
+			logger.Info("管理界面", "url", "http://"+displayAddr(addr)+uiPrefix+"/")
+		}
+		errCh <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func (s *scenarioServer) handle(step *ScenarioStep, params []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := map[string]interface{}{
+			"path":   pathParams(c, params),
+			"query":  queryParams(c),
+			"header": headerValues(c.Request.Header),
+		}
+		if c.Request.ContentLength != 0 {
+			var body interface{}
+			if err := c.ShouldBindJSON(&body); err == nil {
+				request["body"] = body
+			}
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// 变量优先级：场景变量 < 匹配到的对象 < 路径参数和请求头中的变量 < 请求
+		vars := make(map[string]interface{}, len(s.scenario.Vars)+4)
+		for k, v := range s.scenario.Vars {
+			vars[k] = v
+		}
+		bound := make(map[string]string)
+		for _, name := range params {
+			bound[name] = c.Param(name)
+		}
+		for name, template := range step.Request.Headers {
+			values, ok := matchTemplate(template, c.GetHeader(name), vars)
+			if !ok {
+				c.JSON(headerStatus(name), gin.H{"error": "header " + name + " mismatch"})
+				return
+			}
+			for k, v := range values {
+				bound[k] = v
+			}
+		}
+		for name, v := range bound {
+			if !s.captured[name] {
+				continue
+			}
+			object, ok := s.objects[name][v]
+			if !ok {
+				status := http.StatusNotFound
+				for header, template := range step.Request.Headers {
+					if strings.Contains(template, "${"+name+"}") {
+						status = headerStatus(header)
+					}
+				}
+				c.JSON(status, gin.H{"error": name + " " + v + " not found"})
+				return
+			}
+			for k, ov := range object {
+				if _, isBound := bound[k]; !isBound {
+					vars[k] = ov
+				}
+			}
+		}
+		for name, v := range bound {
+			vars[name] = v
+		}
+		vars["request"] = request
+
+		status := step.Response.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		body := expandVars(s.values.ProcessDynamicValues(step.Response.Body), vars)
+		headers := make(map[string]string, len(step.Response.Headers))
+		for name, v := range step.Response.Headers {
+			headers[name] = expandString(v, vars)
+			c.Header(name, headers[name])
+		}
+
+		captured, err := capture(step.Capture, map[string]interface{}{
+			"status": status, "header": headers, "body": body, "request": request,
+		})
+		if err != nil {
+			logger.Warn("场景变量提取失败", "step", step.Name, "err", err)
+		}
+		s.store(captured)
+
+		// 删除对象时同时删除按同一步提取的其他变量记录的对象
+		if step.Delete {
+			for _, name := range params {
+				for k, v := range s.objects[name][c.Param(name)] {
+					delete(s.objects[k], fmt.Sprint(v))
+				}
+				delete(s.objects[name], c.Param(name))
+			}
+		}
+
+		if body == nil {
+			c.Status(status)
+			return
+		}
+		c.JSON(status, body)
+	}
+}
+
+// ScenarioState 场景服务的路由和当前保存的对象，供管理界面查看
+type ScenarioState struct {
+	Name    string                                       `json:"name"`
+	Steps   []ScenarioRoute                              `json:"steps"`
+	Objects map[string]map[string]map[string]interface{} `json:"objects"` // 变量名 -> 变量值 -> 对象的变量
+}
+
+// ScenarioRoute 场景步骤对应的路由
+type ScenarioRoute struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+}
+
+func (s *scenarioServer) state() ScenarioState {
+	state := ScenarioState{Name: s.scenario.Name, Objects: make(map[string]map[string]map[string]interface{})}
+	for _, step := range s.scenario.Steps {
+		state.Steps = append(state.Steps, ScenarioRoute{Name: step.Name, Method: step.Request.Method, Path: step.Request.Path, Status: step.Response.Status})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 对象创建后不再修改，只需要复制外层的 map
+	for name, objects := range s.objects {
+		copied := make(map[string]map[string]interface{}, len(objects))
+		for v, object := range objects {
+			copied[v] = object
+		}
+		state.Objects[name] = copied
+	}
+	return state
+}
+
+// 每个提取的变量值记录为一个对象，对象中保存同一步提取的所有变量
+func (s *scenarioServer) store(captured map[string]interface{}) {
+	for name, v := range captured {
+		if v == nil {
+			continue
+		}
+		object := make(map[string]interface{}, len(captured))
+		for k, v := range captured {
+			object[k] = v
+		}
+		if s.objects[name] == nil {
+			s.objects[name] = make(map[string]map[string]interface{})
+		}
+		s.objects[name][fmt.Sprint(v)] = object
+	}
+}
+
+// 按模板匹配实际值，模板中的 ${name} 匹配任意内容并返回匹配到的变量值；
+// 已知的变量（如场景变量）必须与已知值一致
+func matchTemplate(template, actual string, vars map[string]interface{}) (map[string]string, bool) {
+	var pattern strings.Builder
+	var names []string
+	last := 0
+	for _, loc := range varPattern.FindAllStringSubmatchIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		name := strings.TrimSpace(template[loc[2]:loc[3]])
+		if v, ok := lookupPath(vars, name); ok {
+			pattern.WriteString(regexp.QuoteMeta(fmt.Sprint(v)))
+		} else {
+			pattern.WriteString("(.+?)")
+			names = append(names, name)
+		}
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+
+	re, err := regexp.Compile("^" + pattern.String() + "$")
+	if err != nil {
+		return nil, false
+	}
+	m := re.FindStringSubmatch(actual)
+	if m == nil {
+		return nil, false
+	}
+	values := make(map[string]string, len(names))
+	for i, name := range names {
+		values[name] = m[i+1]
+	}
+	return values, true
+}
+
+// Authorization 不匹配时返回 401，其他请求头返回 400
+func headerStatus(name string) int {
+	if strings.EqualFold(name, "Authorization") {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
+
+func pathParams(c *gin.Context, names []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		result[name] = c.Param(name)
+	}
+	return result
+}
+
+func queryParams(c *gin.Context) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range c.Request.URL.Query() {
+		result[k] = v[0]
+	}
+	return result
+}
+
+func headerValues(header http.Header) map[string]interface{} {
+	result := make(map[string]interface{}, len(header))
+	for k, v := range header {
+		result[k] = strings.Join(v, ", ")
+	}
+	return result
+}
@@ -1,18 +1,75 @@
 package http_mock
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/TreeWu/mock-go/logging"
 	"github.com/TreeWu/mock-go/value"
 	"github.com/gin-gonic/gin"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+var logger = logging.For("serve")
+
 type HttpMockHandler struct {
 	port         string
 	path         []string
 	valueHandler *value.Handler
+
+	// Admin 为 true 时在 /__admin 提供管理接口，在 /__ui 提供 Web 界面
+	Admin bool
+	// Metrics 为 true 时在 /metrics 提供 Prometheus 指标，包括占位符指令的调用次数和耗时
+	Metrics bool
+	// Env 不为空时读取该环境的覆盖文件，Overlays 是额外的覆盖文件，都在配置文件之后按顺序合并
+	Env      string
+	Overlays []string
+	// Watch 为 true 时监听配置文件和覆盖文件，变化后自动重新加载
+	Watch bool
+	// HTTP2 为 true 时同时提供 HTTP/2：TLS 下通过 ALPN 协商，明文下接受 prior knowledge 方式的 h2c
+	HTTP2 bool
+	// UsageFile 不为空时在停止时写入每个 mock 配置的命中统计
+	UsageFile  string
+	usage      *usageTracker
+	violations *violationLog // 违反 expect 的调用
+
+	requests  *requestLog
+	tus       *tusServer      // 为空时不提供 tus 上传接口
+	access    *accessControl  // 为空时不限制来源 IP，也不注入请求头
+	oidc      *oidcProvider   // 为空时不提供内置的 OIDC 提供方
+	s3        *s3Server       // 为空时不提供 S3 兼容接口
+	payments  *paymentGateway // 为空时不提供内置的支付网关
+	sms       *smsAPI         // 为空时不提供 Twilio 风格的短信接口
+	email     *emailAPI       // 为空时不提供 SendGrid 风格的邮件接口
+	messages  *messageStore   // 短信和邮件接口收到的消息
+	resources *resourceStores // resource 配置保存的对象
+	states    *scenarioStates // state 配置的场景状态
+
+	// TLS 为空时使用 HTTP，tlsFault 为空时不注入握手故障
+	TLS      *tls.Config
+	tlsFault *tlsFault
+
+	mu      sync.Mutex
+	configs []MockConfig
+	router  atomic.Pointer[gin.Engine] // 配置变化时整体替换
+	rewatch func()                     // 重新加载后更新监听的文件，没有监听时为空
 }
 
 func NewHttpMockHandler(port string, path ...string) *HttpMockHandler {
@@ -21,81 +78,738 @@ func NewHttpMockHandler(port string, path ...string) *HttpMockHandler {
 		valueHandler: value.NewValueHandler(),
 		port:         port,
 		path:         path,
+		requests:     newRequestLog(),
+		usage:        newUsageTracker(),
+		violations:   &violationLog{},
+		messages:     newMessageStore(),
+		resources:    newResourceStores(),
+		states:       newScenarioStates(),
 	}
 }
 
-func (h *HttpMockHandler) Start() {
-	var mockConfigs []MockConfig
+// 默认配置文件，当前目录下没有时依次查找容器中挂载的配置目录和内置的示例配置
+const (
+	defaultConfig    = "http.json"
+	defaultConfigDir = "/etc/mockgo"
+)
 
-	for _, path := range h.path {
-		// 读取配置文件
-		configFile, err := os.ReadFile(path)
-		if err != nil {
-			log.Fatalf("读取配置文件失败: %v", err)
+//go:embed default.json
+var embeddedConfig []byte
+
+// Run 启动 mock 服务，args 为命令行参数（不含命令名），返回进程退出码。
+// 收到 SIGINT 或 SIGTERM 时停止接收新请求，等待进行中的请求完成后退出
+func Run(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.String("port", envOr("SERVE_PORT", ":8080"), "listen address, a bare port listens on all interfaces (env SERVE_PORT)")
+	configs := fs.String("config", envOr("SERVE_CONFIG", defaultConfig), "comma separated mock config files or directories of *.json (env SERVE_CONFIG)")
+	env := fs.String("env", os.Getenv("SERVE_ENV"), "environment whose overlays are merged into the configs, name.<env>.json for files and <dir>/<env>/*.json for directories (env SERVE_ENV)")
+	overlays := fs.String("overlay", "", "comma separated overlay files merged after the environment overlays")
+	watch := fs.Bool("watch", true, "reload the configs and overlays when they change, mocks edited through the admin api are replaced on reload")
+	metrics := fs.Bool("metrics", false, "serve prometheus metrics under "+metricsPath+", including mock hits and value directive timings")
+	usageReport := fs.String("usage-report", "", "write the hit count of every mock as json to this file on shutdown")
+	http2 := fs.Bool("http2", false, "also serve HTTP/2: negotiated through ALPN with -tls, cleartext h2c with prior knowledge otherwise; connection-level faults only apply to HTTP/1.1 requests")
+	admin := fs.Bool("admin", true, "serve the admin api under "+adminPrefix+" and the web ui under "+uiPrefix+"/")
+	tus := fs.Bool("tus", false, "serve a tus 1.0.0 resumable upload endpoint under "+tusPrefix)
+	tusDir := fs.String("tus-dir", "", "directory for tus uploads, a new temporary directory when empty")
+	tusMaxSize := fs.String("tus-max-size", "", "largest accepted upload, e.g. 1GB, unlimited when empty")
+	useTLS := fs.Bool("tls", false, "serve https, with a self-signed certificate for localhost unless -tls-cert and -tls-key are set")
+	tlsCert := fs.String("tls-cert", "", "tls certificate file")
+	tlsKey := fs.String("tls-key", "", "tls private key file")
+	tlsCertOut := fs.String("tls-cert-out", "", "write the self-signed certificate as pem to this file so clients can trust it")
+	tlsClientCA := fs.String("tls-client-ca", "", "pem file of the cas that sign client certificates, enables mutual tls")
+	tlsClientAuth := fs.String("tls-client-auth", "", "client certificate policy: require (default with -tls-client-ca), optional (verify when sent) or request (ask without verifying)")
+	sms := fs.Bool("sms", false, "serve a twilio style sms api, sent messages are listed under "+adminPrefix+"/messages")
+	email := fs.Bool("email", false, "serve a sendgrid style email api, sent messages are listed under "+adminPrefix+"/messages")
+	payments := fs.Bool("payments", false, "serve a mock payment gateway with webhooks under "+paymentPrefix)
+	paymentsConfig := fs.String("payments-config", "", "json file with the webhook url, delay and failure rules of the payment gateway, implies -payments")
+	s3 := fs.Bool("s3", false, "serve an s3 compatible object storage api under "+s3Prefix+", use it as a path-style endpoint")
+	s3Dir := fs.String("s3-dir", "", "directory for s3 buckets, a new temporary directory when empty")
+	s3AccessKey := fs.String("s3-access-key", "mockgo", "access key accepted in s3 signatures")
+	s3SecretKey := fs.String("s3-secret-key", "mockgo-secret", "secret key used to verify s3 signatures and presigned urls")
+	oidc := fs.Bool("oidc", false, "serve a mock oauth2/oidc provider under "+oidcPrefix)
+	oidcConfig := fs.String("oidc-config", "", "json file with the clients, users and claims of the oidc provider, implies -oidc")
+	access := fs.String("access", "", "json file with client ip allow/deny rules and injected geo headers such as CF-IPCountry")
+	tlsFaultSpec := fs.String("tls-fault", "", "fail tls handshakes with an alert or a tcp reset, <alert|reset>[:probability], e.g. handshake_failure:0.3")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
 		}
+		return 2
+	}
 
-		var mcs []MockConfig
-		err = json.Unmarshal(configFile, &mcs)
+	paths := append(splitList(*configs), fs.Args()...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	handler := NewHttpMockHandler(listenAddr(*port), paths...)
+	handler.Admin = *admin
+	handler.Metrics = *metrics
+	value.EnableMetrics(*metrics)
+	handler.Env = *env
+	handler.Overlays = splitList(*overlays)
+	handler.Watch = *watch
+	handler.HTTP2 = *http2
+	handler.UsageFile = *usageReport
+	if *tus {
+		var err error
+		var maxSize int64
+		if *tusMaxSize != "" {
+			if maxSize, err = parseSize(*tusMaxSize); err != nil {
+				logger.Error("invalid -tus-max-size", "err", err)
+				return 2
+			}
+		}
+		dir := *tusDir
+		if dir == "" {
+			if dir, err = os.MkdirTemp("", "mockgo-tus-"); err != nil {
+				logger.Error("创建上传目录失败", "err", err)
+				return 1
+			}
+		}
+		if handler.tus, err = newTusServer(dir, maxSize); err != nil {
+			logger.Error("创建上传目录失败", "err", err)
+			return 1
+		}
+		logger.Info("tus 上传接口", "path", tusPrefix, "dir", dir)
+	}
+	if *sms {
+		handler.sms = &smsAPI{store: handler.messages, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	if *email {
+		handler.email = &emailAPI{store: handler.messages}
+	}
+	if *payments || *paymentsConfig != "" {
+		var err error
+		if handler.payments, err = loadPaymentGateway(*paymentsConfig); err != nil {
+			logger.Error("读取支付网关配置失败", "err", err)
+			return 1
+		}
+		logger.Info("支付网关", "path", paymentPrefix)
+	}
+	if *s3 {
+		var err error
+		dir := *s3Dir
+		if dir == "" {
+			if dir, err = os.MkdirTemp("", "mockgo-s3-"); err != nil {
+				logger.Error("创建 S3 目录失败", "err", err)
+				return 1
+			}
+		}
+		if handler.s3, err = newS3Server(dir, *s3AccessKey, *s3SecretKey); err != nil {
+			logger.Error("创建 S3 目录失败", "err", err)
+			return 1
+		}
+		logger.Info("S3 兼容接口", "path", s3Prefix, "dir", dir)
+	}
+	if *oidc || *oidcConfig != "" {
+		var err error
+		if handler.oidc, err = loadOIDCProvider(*oidcConfig); err != nil {
+			logger.Error("读取 OIDC 配置失败", "err", err)
+			return 1
+		}
+		logger.Info("OIDC 提供方", "discovery", oidcPrefix+"/.well-known/openid-configuration")
+	}
+	if *access != "" {
+		var err error
+		if handler.access, err = loadAccessConfig(*access); err != nil {
+			logger.Error("读取访问控制配置失败", "err", err)
+			return 1
+		}
+	}
+	if *useTLS || *tlsCert != "" || *tlsFaultSpec != "" || *tlsClientCA != "" || *tlsClientAuth != "" {
+		cert, err := loadCert(*tlsCert, *tlsKey)
 		if err != nil {
-			log.Fatalf("解析配置文件失败: %v", err)
+			logger.Error("加载证书失败", "err", err)
+			return 1
+		}
+		handler.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		if *tlsCertOut != "" {
+			data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+			if err := os.WriteFile(*tlsCertOut, data, 0o644); err != nil {
+				logger.Error("写入证书失败", "err", err)
+				return 1
+			}
+			logger.Info("服务端证书已写入", "file", *tlsCertOut)
+		}
+		if handler.TLS.ClientAuth, handler.TLS.ClientCAs, err = clientAuth(*tlsClientAuth, *tlsClientCA); err != nil {
+			logger.Error("invalid client certificate settings", "err", err)
+			return 2
 		}
+		if *tlsFaultSpec != "" {
+			if handler.tlsFault, err = parseTLSFault(*tlsFaultSpec); err != nil {
+				logger.Error("invalid -tls-fault", "err", err)
+				return 2
+			}
+		}
+	}
+	if err := handler.Start(ctx); err != nil {
+		logger.Error("启动服务器失败", "err", err)
+		return 1
+	}
+	return 0
+}
 
-		mockConfigs = append(mockConfigs, mcs...)
+// Start 加载配置并启动服务，ctx 取消后优雅退出
+func (h *HttpMockHandler) Start(ctx context.Context) error {
+	mockConfigs, err := h.loadConfigs()
+	if err != nil {
+		return err
+	}
+
+	if err := h.setConfigs(mockConfigs); err != nil {
+		return err
+	}
+	if h.Watch {
+		if err := h.watch(ctx); err != nil {
+			logger.Warn("监听配置文件失败，修改配置后需要重启", "err", err)
+		}
 	}
 
-	// 创建 Gin 路由
-	router := gin.Default()
+	listener, err := net.Listen("tcp", h.port)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	server := &http.Server{Handler: h.handler()}
+	if h.HTTP2 {
+		// HTTP/2 的请求不能接管连接，连接级故障只对 HTTP/1.1 的请求生效
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(true)
+		server.Protocols.SetUnencryptedHTTP2(h.TLS == nil)
+	}
+	if h.TLS != nil {
+		scheme = "https"
+		if h.tlsFault != nil {
+			listener = &faultListener{Listener: listener, fault: h.tlsFault}
+		}
+		if h.HTTP2 {
+			// 自行创建的 TLS listener 需要在证书配置中声明 ALPN 协议
+			h.TLS.NextProtos = []string{"h2", "http/1.1"}
+		} else {
+			// 只使用 HTTP/1.1，连接级故障需要接管连接
+			server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		listener = tls.NewListener(listener, h.TLS)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("Mock 服务器启动", "addr", h.port, "scheme", scheme, "http2", h.HTTP2)
+		if h.Admin {
+			logger.Info("管理界面", "url", scheme+"://"+displayAddr(h.port)+uiPrefix+"/")
+		}
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	logger.Info("正在停止 Mock 服务器")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	h.reportUsage()
+	h.reportAssertions()
+	return err
+}
+
+// 管理接口使用独立的路由，其余请求交给当前的 mock 路由
+func (h *HttpMockHandler) handler() http.Handler {
+	if !h.Admin {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.router.Load().ServeHTTP(w, r)
+		})
+	}
+	admin := gin.New()
+	admin.Use(accessLog(), gin.Recovery())
+	(&adminAPI{requests: h.requests, mocks: h, messages: h.messages}).register(admin)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminPath(r.URL.Path) {
+			admin.ServeHTTP(w, r)
+			return
+		}
+		h.router.Load().ServeHTTP(w, r)
+	})
+}
+
+// 按配置重新创建路由，创建成功后替换当前路由
+func (h *HttpMockHandler) setConfigs(configs []MockConfig) error {
+	router, err := h.buildRouter(configs)
+	if err != nil {
+		return err
+	}
+	h.configs = configs
+	h.router.Store(router)
+	return nil
+}
+
+// 创建 Gin 路由，请求日志使用统一的日志输出。路由冲突时 gin 会 panic，这里转换为错误返回
+func (h *HttpMockHandler) buildRouter(mockConfigs []MockConfig) (router *gin.Engine, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("注册路由失败: %v", r)
+		}
+	}()
+
+	gin.SetMode(gin.ReleaseMode)
+	router = gin.New()
+	router.Use(accessLog())
+	if h.Admin {
+		router.Use(h.requests.middleware())
+	}
+	if h.access != nil {
+		router.Use(h.access.middleware())
+	}
 	router.Use(gin.Recovery())
-	// 注册 mock 处理器
 
 	// 为每个配置项注册路由
+	routes := make(map[string]bool)
 	for _, config := range mockConfigs {
-		switch strings.ToUpper(config.Method) {
-		case "GET":
-			router.GET(config.URL, h.HandleMock(config))
-		case "POST":
-			router.POST(config.URL, h.HandleMock(config))
-		case "PUT":
-			router.PUT(config.URL, h.HandleMock(config))
-		case "DELETE":
-			router.DELETE(config.URL, h.HandleMock(config))
-		case "PATCH":
-			router.PATCH(config.URL, h.HandleMock(config))
-		default:
-			log.Printf("不支持的 HTTP 方法: %s", config.Method)
+		if config.Resource != nil {
+			h.registerResource(router, config)
+			routes[config.URL] = true
+			logger.Debug("注册资源", "url", config.URL)
+			continue
 		}
+		method := strings.ToUpper(config.Method)
+		if !supportedMethods[method] {
+			logger.Warn("不支持的 HTTP 方法", "method", config.Method, "url", config.URL)
+			continue
+		}
+		router.Handle(method, ginRoute(config.URL), h.HandleMock(config))
+		routes[config.URL] = true
+
+		logger.Debug("注册路由", "method", config.Method, "url", config.URL)
+	}
+
+	router.GET(filesPrefix+"/:size", handleFile)
+	router.HEAD(filesPrefix+"/:size", handleFile)
+	if h.tus != nil {
+		h.tus.register(router)
+	}
+	if h.oidc != nil {
+		h.oidc.register(router)
+	}
+	if h.s3 != nil {
+		h.s3.register(router)
+	}
+	if h.payments != nil {
+		h.payments.register(router)
+	}
+	if h.sms != nil {
+		h.sms.register(router)
+	}
+	if h.email != nil {
+		h.email.register(router)
+	}
 
-		log.Println("注册路由: ", config.Method, config.URL)
+	if h.Metrics && !routes[metricsPath] {
+		router.GET(metricsPath, h.serveMetrics)
 	}
 
-	// 启动服务器
-	log.Println("Mock 服务器启动在端口", h.port)
-	if err := router.Run(h.port); err != nil {
-		log.Fatalf("启动服务器失败: %v", err)
+	// 健康检查，配置中定义了相同路径时以配置为准
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if !routes[path] {
+			router.GET(path, func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			})
+		}
 	}
+	return router, nil
+}
+
+var supportedMethods = map[string]bool{"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true}
+
+// Configs 返回当前的 mock 配置
+func (h *HttpMockHandler) Configs() []MockConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]MockConfig(nil), h.configs...)
+}
+
+// SaveConfig 新增 mock 配置，方法和路径相同时替换原有配置，立即生效。返回补全默认值后的配置
+func (h *HttpMockHandler) SaveConfig(config MockConfig) (MockConfig, error) {
+	config.Method = strings.ToUpper(config.Method)
+	if config.Resource == nil && !supportedMethods[config.Method] {
+		return config, fmt.Errorf("unsupported method %q", config.Method)
+	}
+	if !strings.HasPrefix(config.URL, "/") || isAdminPath(config.URL) {
+		return config, fmt.Errorf("url must start with / and not with %s or %s", adminPrefix, uiPrefix)
+	}
+	if config.Response.StatusCode == 0 {
+		config.Response.StatusCode = http.StatusOK
+	}
+	warnConfig(config)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	configs := make([]MockConfig, 0, len(h.configs)+1)
+	replaced := false
+	for _, c := range h.configs {
+		if strings.EqualFold(c.Method, config.Method) && c.URL == config.URL {
+			c, replaced = config, true
+		}
+		configs = append(configs, c)
+	}
+	if !replaced {
+		configs = append(configs, config)
+	}
+	return config, h.setConfigs(configs)
+}
+
+// DeleteConfig 删除方法和路径对应的 mock 配置，不存在时返回 false
+func (h *HttpMockHandler) DeleteConfig(method, url string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	configs := make([]MockConfig, 0, len(h.configs))
+	for _, c := range h.configs {
+		if !strings.EqualFold(c.Method, method) || c.URL != url {
+			configs = append(configs, c)
+		}
+	}
+	if len(configs) == len(h.configs) {
+		return false
+	}
+	// 删除路由不会产生冲突
+	h.setConfigs(configs)
+	return true
+}
+
+// 读取所有配置文件，目录按文件名顺序读取其中的 *.json
+func (h *HttpMockHandler) loadConfigs() ([]MockConfig, error) {
+	var mockConfigs []MockConfig
+	for _, path := range h.path {
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				return nil, err
+			}
+			sort.Strings(files)
+		}
+
+		for _, file := range files {
+			data, err := h.readConfig(file)
+			if err != nil {
+				return nil, fmt.Errorf("读取配置文件失败: %v", err)
+			}
+			var mcs []MockConfig
+			if err := json.Unmarshal(data, &mcs); err != nil {
+				return nil, fmt.Errorf("解析配置文件 %s 失败: %v", file, err)
+			}
+			mockConfigs = append(mockConfigs, mcs...)
+		}
+	}
+	mockConfigs, err := h.applyOverlays(mockConfigs)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range mockConfigs {
+		warnConfig(config)
+	}
+	return mockConfigs, nil
+}
+
+// 未显式指定的默认配置不存在时，使用挂载目录中的配置或内置配置
+func (h *HttpMockHandler) readConfig(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil || path != defaultConfig || !errors.Is(err, os.ErrNotExist) {
+		return data, err
+	}
+	mounted := filepath.Join(defaultConfigDir, defaultConfig)
+	if data, err := os.ReadFile(mounted); err == nil {
+		logger.Info("使用挂载的配置文件", "path", mounted)
+		return data, nil
+	}
+	logger.Info("没有找到配置文件，使用内置的示例配置", "path", path)
+	return embeddedConfig, nil
+}
+
+// 读取证书，没有指定证书文件时生成自签名证书
+func loadCert(certFile, keyFile string) (tls.Certificate, error) {
+	if certFile == "" && keyFile == "" {
+		return selfSignedCert()
+	}
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// 客户端证书的校验方式，设置了 CA 时默认要求客户端证书
+func clientAuth(mode, caFile string) (tls.ClientAuthType, *x509.CertPool, error) {
+	if mode == "" && caFile != "" {
+		mode = "require"
+	}
+	var auth tls.ClientAuthType
+	switch mode {
+	case "":
+		return tls.NoClientCert, nil, nil
+	case "request":
+		return tls.RequestClientCert, nil, nil
+	case "require":
+		auth = tls.RequireAndVerifyClientCert
+	case "optional":
+		auth = tls.VerifyClientCertIfGiven
+	default:
+		return 0, nil, fmt.Errorf("unknown -tls-client-auth %q, want require, optional or request", mode)
+	}
+	if caFile == "" {
+		return 0, nil, fmt.Errorf("-tls-client-auth %s needs -tls-client-ca", mode)
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return 0, nil, fmt.Errorf("no pem certificate in %s", caFile)
+	}
+	return auth, pool, nil
+}
+
+// 日志中展示的访问地址，监听所有网卡时使用 localhost
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+// 按逗号分隔，去掉空白和空项
+func splitList(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
 }
 
 func (h *HttpMockHandler) HandleMock(mockConfig MockConfig) gin.HandlerFunc {
+	// 候选响应依次是各个版本、各个请求体匹配和默认响应，没有设置状态码时沿用默认响应的状态码
+	responses := make([]Response, 0, len(mockConfig.Versions)+len(mockConfig.Matches)+1)
+	for _, v := range mockConfig.Versions {
+		responses = append(responses, v.Response)
+	}
+	var matchers []*bodyMatcher
+	for _, m := range mockConfig.Matches {
+		// 无效的匹配在加载配置时已经输出警告，这里忽略
+		matcher, _ := compileBodyMatch(m)
+		matchers = append(matchers, matcher)
+		responses = append(responses, m.Response)
+	}
+	responses = append(responses, mockConfig.Response)
+	fallback := len(responses) - 1
+	// 序列中的响应在默认响应之后
+	sequenceStart := len(responses)
+	if mockConfig.Sequence != nil {
+		responses = append(responses, mockConfig.Sequence.Responses...)
+	}
+	stateStart := len(responses)
+	if mockConfig.State != nil {
+		for _, s := range mockConfig.State.States {
+			responses = append(responses, s.Response)
+		}
+	}
+	var table *dataTable
+	missing := -1
+	if mockConfig.Table != nil {
+		var err error
+		if table, err = loadDataTable(*mockConfig.Table); err != nil {
+			logger.Warn("加载数据表失败，已忽略", "url", mockConfig.URL, "err", err)
+		} else if mockConfig.Table.Missing != nil {
+			responses = append(responses, *mockConfig.Table.Missing)
+			missing = len(responses) - 1
+		}
+	}
+	// 有模板表达式的响应在生成值之后计算表达式，请求中 @ 开头的文本不会被当作占位符
+	templates := make([]bool, len(responses))
+	for i := range responses {
+		if responses[i].StatusCode == 0 {
+			responses[i].StatusCode = mockConfig.Response.StatusCode
+		}
+		loadBodyFile(&responses[i], mockConfig.URL)
+		templates[i] = hasTemplates(responses[i].Body)
+		if f := responses[i].Fault; f != "" && !responseFaults[f] {
+			logger.Warn("不支持的响应故障类型，已忽略", "type", f, "url", mockConfig.URL)
+			responses[i].Fault = ""
+		}
+	}
+	// 每个候选响应的响应体分别缓存
+	var caches []*cacheState
+	if mockConfig.Cache != nil {
+		for range responses {
+			caches = append(caches, newCacheState(*mockConfig.Cache))
+		}
+	}
+	vary := varyHeader(mockConfig.Versions)
+	if mockConfig.Fault != nil && !faultTypes[mockConfig.Fault.Type] {
+		logger.Warn("不支持的故障类型，已忽略", "type", mockConfig.Fault.Type, "url", mockConfig.URL)
+		mockConfig.Fault = nil
+	}
+	usage := h.usage.route(mockConfig.Method, mockConfig.URL)
+	expect := newExpectation(mockConfig)
+	// 序列单独计数，只统计实际使用了序列的请求
+	var sequenceHits atomic.Int64
 	return func(c *gin.Context) {
-		var paramStr, reqStr []byte
-		params := make(map[string]string)
-		if err := c.ShouldBindQuery(&params); err != nil {
-			log.Println("query 参数解析失败: ", err)
+		hits, previous := usage.hit()
+		if expect != nil {
+			expect.check(c, mockConfig, hits, previous, h.violations)
+		}
+		var reqStr []byte
+		var body requestBody
+		if data, err := c.GetRawData(); err != nil {
+			logger.Debug("body 读取失败", "err", err)
+		} else if body, err = parseRequestBody(c.GetHeader("Content-Type"), data); err != nil {
+			logger.Debug("body 参数解析失败", "err", err)
 		} else {
-			paramStr, _ = json.Marshal(params)
+			reqStr = data
 		}
+		// 重复的参数保留为数组
+		body.query = decodeParams(c.Request.URL.Query())
+		body.header = decodeHeader(c.Request.Header)
+		paramStr, _ := json.Marshal(body.query)
 
-		req := make(map[string]interface{})
-		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Println("body  参数解析失败: ", err)
-		} else {
-			reqStr, _ = json.Marshal(req)
+		logger.Debug("请求参数", "param", string(paramStr), "req", string(reqStr))
+
+		index := fallback
+		for i, matcher := range matchers {
+			if matcher != nil && matcher.match(body) {
+				index = len(mockConfig.Versions) + i
+				break
+			}
 		}
+		var versionType string
+		if len(mockConfig.Versions) > 0 {
+			c.Header("Vary", vary)
+			if i, contentType := selectVersion(c, mockConfig.Versions); i >= 0 && index == fallback {
+				index, versionType = i, contentType
+			}
+		}
+		// 场景状态不满足 require 时和没有配置路由一样返回 404，states 中的响应只代替默认响应
+		if rule := mockConfig.State; rule != nil && rule.Scenario != "" {
+			state, i, ok := h.states.enter(rule)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("scenario %s is in state %s, not %s", rule.Scenario, state, rule.Require)})
+				return
+			}
+			if i >= 0 && index == fallback {
+				index = stateStart + i
+			}
+		}
+		// 序列只代替默认响应，请求体匹配、版本和场景状态优先，命中它们的请求不计入序列
+		if mockConfig.Sequence != nil && index == fallback {
+			if i, ok := mockConfig.Sequence.pick(sequenceHits.Add(1)); ok {
+				index = sequenceStart + i
+			}
+		}
+		body.params = make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			body.params[p.Key] = p.Value
+		}
+		if table != nil {
+			key := c.Param(table.param)
+			if row, ok := table.lookup(key); ok {
+				body.row = row
+			} else if missing >= 0 && index == fallback {
+				index = missing
+			} else {
+				body.row = table.generate(h.valueHandler, key)
+			}
+		}
+		response := responses[index]
+		// 最终返回的是版本的响应时才使用版本的媒体类型，命中 matches 时保持原来的 Content-Type
+		if versionType != "" && index < len(mockConfig.Versions) {
+			c.Header("Content-Type", versionType)
+		}
+		generate := func() interface{} {
+			if response.Body == nil && body.row != nil {
+				return body.row
+			}
+			processed := h.valueHandler.ProcessDynamicValues(response.Body)
+			if templates[index] {
+				processed = renderTemplates(processed, body)
+			}
+			return processed
+		}
+
+		h.writeHeaders(c, response, body)
 
-		log.Printf("param: %s, req: %s \n", string(paramStr), string(reqStr))
+		if response.Fault != "" {
+			data, _ := json.Marshal(generate())
+			writeResponseFault(c, response.Fault, response.StatusCode, data)
+			return
+		}
+		// 连接级故障对分块、文件和 SSE 响应同样生效，在发送响应体之前判断
+		if mockConfig.Fault != nil && mockConfig.Fault.triggered() {
+			injectFault(c, mockConfig.Fault, response.StatusCode, faultBody(c, response, generate))
+			return
+		}
+		if response.Chunked != nil && response.Type != responseSSE {
+			streamChunks(c, response, generate)
+			return
+		}
+		if response.File != "" {
+			serveFile(c, response.File)
+			return
+		}
+		if response.Type == responseSSE {
+			render := func(v interface{}) interface{} {
+				processed := h.valueHandler.ProcessDynamicValues(v)
+				if hasTemplates(v) {
+					processed = renderTemplates(processed, body)
+				}
+				return processed
+			}
+			streamEvents(c, response, render, generate)
+			return
+		}
+		if caches != nil {
+			caches[index].serve(c, response.StatusCode, func() ([]byte, string) { return encodeBody(c, generate()) })
+			return
+		}
 
-		processedBody := h.valueHandler.ProcessDynamicValues(mockConfig.Response.Body)
+		data, contentType := encodeBody(c, generate())
+		c.Data(response.StatusCode, contentType, data)
+	}
+}
 
-		c.JSON(mockConfig.Response.StatusCode, processedBody)
+// 记录每个请求的方法、路径、状态码和耗时
+func accessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		// 健康检查和管理界面的轮询请求很频繁，只在 debug 级别记录
+		level := slog.LevelInfo
+		if path := c.Request.URL.Path; path == "/healthz" || path == "/readyz" || isAdminPath(path) {
+			level = slog.LevelDebug
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start).String(),
+			"client", c.ClientIP(),
+		}
+		// 双向 TLS 时记录客户端证书的主题
+		if state := c.Request.TLS; state != nil && len(state.PeerCertificates) > 0 {
+			attrs = append(attrs, "client_cert", state.PeerCertificates[0].Subject.String())
+		}
+		logger.Log(c.Request.Context(), level, "请求", attrs...)
 	}
 }
@@ -0,0 +1,358 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 按配置启动 mock 服务，配置中的 $DIR 替换为临时目录，files 写入临时目录
+func startMock(t *testing.T, config string, files map[string]string) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "mock.json")
+	config = strings.ReplaceAll(config, "$DIR", filepath.ToSlash(dir))
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHttpMockHandler("", path)
+	configs, err := h.loadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.setConfigs(configs); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(h.handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+type mockRequest struct {
+	method  string
+	path    string
+	body    string
+	headers map[string]string
+}
+
+func do(t *testing.T, server *httptest.Server, r mockRequest) (*http.Response, string) {
+	t.Helper()
+	if r.method == "" {
+		r.method = http.MethodGet
+	}
+	req, err := http.NewRequest(r.method, server.URL+r.path, strings.NewReader(r.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// mockCase 按顺序发送 requests，逐个检查状态码、Content-Type 和响应体
+type mockCase struct {
+	name        string
+	config      string
+	files       map[string]string
+	requests    []mockRequest
+	status      []int
+	contentType string
+	check       func(t *testing.T, i int, body string)
+}
+
+func runCases(t *testing.T, tests []mockCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startMock(t, tt.config, tt.files)
+			for i, r := range tt.requests {
+				resp, body := do(t, server, r)
+				if resp.StatusCode != tt.status[i] {
+					t.Fatalf("request %d: status = %d, want %d, body %s", i, resp.StatusCode, tt.status[i], body)
+				}
+				if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+					t.Fatalf("request %d: Content-Type = %q, want %q", i, ct, tt.contentType)
+				}
+				if tt.check != nil {
+					tt.check(t, i, body)
+				}
+			}
+		})
+	}
+}
+
+func wantBody(want string) func(t *testing.T, i int, body string) {
+	return func(t *testing.T, i int, body string) {
+		t.Helper()
+		if body != want {
+			t.Fatalf("request %d: body = %q, want %q", i, body, want)
+		}
+	}
+}
+
+// 连接级故障在发送文件、分块和 SSE 响应之前生效
+func TestHandleMockConnectionFault(t *testing.T) {
+	server := startMock(t, `[
+		{"method": "get", "url": "/json", "fault": {"type": "close"}, "response": {"status_code": 200, "body": {"a": 1}}},
+		{"method": "get", "url": "/file", "fault": {"type": "close"}, "response": {"status_code": 200, "file": "$DIR/a.txt"}},
+		{"method": "get", "url": "/chunked", "fault": {"type": "close"}, "response": {"body": {"a": 1}, "chunked": {"size": "1"}}},
+		{"method": "get", "url": "/sse", "fault": {"type": "close"}, "response": {"type": "sse", "body": "x", "count": 1}}
+	]`, map[string]string{"a.txt": "hello"})
+	for _, path := range []string{"/json", "/file", "/chunked", "/sse"} {
+		resp, err := server.Client().Get(server.URL + path)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: got status %d, want the connection to be closed", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandleMockCache(t *testing.T) {
+	server := startMock(t, `[
+		{"method": "get", "url": "/json", "cache": {"etag": "strong"}, "response": {"status_code": 200, "body": {"id": "@uuid"}}},
+		{"method": "get", "url": "/text", "cache": {"etag": "weak"}, "response": {"status_code": 200, "body": "@uuid", "content_type": "text/plain"}}
+	]`, nil)
+	for _, tt := range []struct {
+		path        string
+		contentType string
+	}{
+		{"/json", "application/json"},
+		{"/text", "text/plain"},
+	} {
+		first, body := do(t, server, mockRequest{path: tt.path})
+		if ct := first.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Fatalf("%s: Content-Type = %q, want %q", tt.path, ct, tt.contentType)
+		}
+		if tt.contentType == "text/plain" && strings.HasPrefix(body, `"`) {
+			t.Fatalf("%s: cached text body is JSON encoded: %s", tt.path, body)
+		}
+		if _, again := do(t, server, mockRequest{path: tt.path}); again != body {
+			t.Fatalf("%s: cached body changed from %s to %s", tt.path, body, again)
+		}
+		etag := first.Header.Get("ETag")
+		resp, _ := do(t, server, mockRequest{path: tt.path, headers: map[string]string{"If-None-Match": etag}})
+		if etag == "" || resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: If-None-Match %q returned %d", tt.path, etag, resp.StatusCode)
+		}
+	}
+}
+
+func TestResource(t *testing.T) {
+	server := startMock(t, `[{"url": "/api/books", "resource": {"id": "isbn", "data": "$DIR/books.json"}}]`,
+		map[string]string{"books.json": `[{"isbn": "1", "title": "Go"}]`})
+	steps := []struct {
+		req    mockRequest
+		status int
+		want   string
+	}{
+		{mockRequest{path: "/api/books/1"}, 200, `"title":"Go"`},
+		{mockRequest{method: "POST", path: "/api/books", body: `{"isbn": "2", "title": "Rust"}`, headers: map[string]string{"Content-Type": "application/json"}}, 201, `"isbn":"2"`},
+		{mockRequest{path: "/api/books"}, 200, `"title":"Rust"`},
+		{mockRequest{method: "PATCH", path: "/api/books/2", body: `{"title": "Zig"}`, headers: map[string]string{"Content-Type": "application/json"}}, 200, `"title":"Zig"`},
+		{mockRequest{method: "DELETE", path: "/api/books/2"}, 204, ``},
+		{mockRequest{path: "/api/books/2"}, 404, ``},
+	}
+	for i, step := range steps {
+		resp, body := do(t, server, step.req)
+		if resp.StatusCode != step.status || !strings.Contains(body, step.want) {
+			t.Fatalf("step %d %s %s: %d %s, want %d containing %s", i, step.req.method, step.req.path, resp.StatusCode, body, step.status, step.want)
+		}
+	}
+}
+
+func TestResponseFault(t *testing.T) {
+	runCases(t, []mockCase{
+		{
+			name:        "malformed_json",
+			config:      `[{"method": "get", "url": "/broken", "response": {"status_code": 200, "body": {"items": [1, 2, 3]}, "fault": "malformed_json"}}]`,
+			requests:    []mockRequest{{path: "/broken"}},
+			status:      []int{200},
+			contentType: "application/json",
+			check: func(t *testing.T, _ int, body string) {
+				if json.Valid([]byte(body)) {
+					t.Fatalf("body %q is valid JSON", body)
+				}
+			},
+		},
+	})
+}
+
+func TestSSE(t *testing.T) {
+	runCases(t, []mockCase{
+		{
+			name:        "sse events resume after Last-Event-ID",
+			config:      `[{"method": "get", "url": "/events", "response": {"type": "sse", "interval": "1ms", "events": [{"id": "1", "data": "a"}, {"id": "2", "event": "tick", "data": {"n": 2}}]}}]`,
+			requests:    []mockRequest{{path: "/events"}, {path: "/events", headers: map[string]string{"Last-Event-ID": "1"}}},
+			status:      []int{200, 200},
+			contentType: "text/event-stream",
+			check: func(t *testing.T, i int, body string) {
+				want := []string{"id: 1\ndata: a\n\nid: 2\nevent: tick\ndata: {\"n\":2}\n\n", "id: 2\nevent: tick\ndata: {\"n\":2}\n\n"}[i]
+				wantBody(want)(t, i, body)
+			},
+		},
+	})
+}
+
+func TestChunked(t *testing.T) {
+	runCases(t, []mockCase{
+		{
+			name:        "chunked ndjson",
+			config:      `[{"method": "get", "url": "/stream", "response": {"status_code": 200, "body": [{"a": 1}, {"a": 2}], "chunked": {"ndjson": true}}}]`,
+			requests:    []mockRequest{{path: "/stream"}},
+			status:      []int{200},
+			contentType: "application/x-ndjson",
+			check:       wantBody("{\"a\":1}\n{\"a\":2}\n"),
+		},
+	})
+}
+
+func TestBodyFile(t *testing.T) {
+	runCases(t, []mockCase{
+		{
+			name:        "json body_file",
+			config:      `[{"method": "get", "url": "/file", "response": {"status_code": 200, "body_file": "$DIR/body.json"}}]`,
+			files:       map[string]string{"body.json": `{"id": "@uuid", "fixed": 1}`},
+			requests:    []mockRequest{{path: "/file"}},
+			status:      []int{200},
+			contentType: "application/json",
+			check: func(t *testing.T, _ int, body string) {
+				if !strings.Contains(body, `"fixed":1`) || strings.Contains(body, "@uuid") {
+					t.Fatalf("body = %s", body)
+				}
+			},
+		},
+		{
+			name:        "non-json body_file is served as is",
+			config:      `[{"method": "get", "url": "/csv", "response": {"status_code": 200, "body_file": "$DIR/data.csv", "content_type": "text/csv"}}]`,
+			files:       map[string]string{"data.csv": "id,name\n1,@name\n"},
+			requests:    []mockRequest{{path: "/csv"}},
+			status:      []int{200},
+			contentType: "text/csv",
+			check:       wantBody("id,name\n1,@name\n"),
+		},
+		{
+			name:        "text content type returns the raw string",
+			config:      `[{"method": "get", "url": "/text", "response": {"status_code": 201, "body": "hello", "content_type": "text/plain"}}]`,
+			requests:    []mockRequest{{path: "/text"}},
+			status:      []int{201},
+			contentType: "text/plain",
+			check:       wantBody("hello"),
+		},
+	})
+}
+
+func TestPathParams(t *testing.T) {
+	runCases(t, []mockCase{
+		{
+			name:        "json body with placeholders",
+			config:      `[{"method": "get", "url": "/users/:id", "response": {"status_code": 200, "body": {"id": "{{number(param('id'))}}", "email": "@email", "n": "@randInt:3"}}}]`,
+			requests:    []mockRequest{{path: "/users/42"}},
+			status:      []int{200},
+			contentType: "application/json",
+			check: func(t *testing.T, _ int, body string) {
+				var v map[string]interface{}
+				if err := json.Unmarshal([]byte(body), &v); err != nil {
+					t.Fatal(err)
+				}
+				if v["id"] != float64(42) || !strings.Contains(v["email"].(string), "@") {
+					t.Fatalf("body = %s", body)
+				}
+				if n := v["n"].(float64); n < 100 || n > 999 {
+					t.Fatalf("@randInt:3 = %v", n)
+				}
+			},
+		},
+	})
+}
+
+func TestMatches(t *testing.T) {
+	v2 := map[string]string{"Accept": "application/vnd.api.v2+json", "Content-Type": "application/json"}
+	runCases(t, []mockCase{
+		{
+			name: "matches take precedence over the default response",
+			config: `[{"method": "post", "url": "/orders", "response": {"status_code": 200, "body": {"kind": "default"}},
+				"matches": [{"jsonpath": ["$.vip == true"], "response": {"status_code": 202, "body": {"kind": "vip"}}}]}]`,
+			requests: []mockRequest{
+				{method: "POST", path: "/orders", body: `{"vip": true}`, headers: map[string]string{"Content-Type": "application/json"}},
+				{method: "POST", path: "/orders", body: `{"vip": false}`, headers: map[string]string{"Content-Type": "application/json"}},
+			},
+			status: []int{202, 200},
+			check: func(t *testing.T, i int, body string) {
+				wantBody([]string{`{"kind":"vip"}`, `{"kind":"default"}`}[i])(t, i, body)
+			},
+		},
+		{
+			name: "a winning match keeps its own content type over the accepted version",
+			config: `[{"method": "post", "url": "/orders", "response": {"status_code": 200, "body": {"kind": "default"}},
+				"versions": [{"accept": "application/vnd.api.v2+json", "response": {"body": {"kind": "v2"}}}],
+				"matches": [{"jsonpath": ["$.vip == true"], "response": {"status_code": 202, "body": {"kind": "vip"}}}]}]`,
+			requests: []mockRequest{
+				{method: "POST", path: "/orders", body: `{"vip": true}`, headers: v2},
+			},
+			status:      []int{202},
+			contentType: "application/json",
+			check:       wantBody(`{"kind":"vip"}`),
+		},
+		{
+			name: "the accepted version sets its content type when no match wins",
+			config: `[{"method": "post", "url": "/orders", "response": {"status_code": 200, "body": {"kind": "default"}},
+				"versions": [{"accept": "application/vnd.api.v2+json", "response": {"body": {"kind": "v2"}}}],
+				"matches": [{"jsonpath": ["$.vip == true"], "response": {"status_code": 202, "body": {"kind": "vip"}}}]}]`,
+			requests: []mockRequest{
+				{method: "POST", path: "/orders", body: `{"vip": false}`, headers: v2},
+			},
+			status:      []int{200},
+			contentType: "application/vnd.api.v2+json",
+			check:       wantBody(`{"kind":"v2"}`),
+		},
+	})
+}
+
+func TestSequence(t *testing.T) {
+	runCases(t, []mockCase{
+		{
+			name:     "sequence falls back to the response after the last entry",
+			config:   `[{"method": "get", "url": "/seq", "response": {"status_code": 200, "body": "done"}, "sequence": {"responses": [{"status_code": 503, "body": "busy"}, {"body": "retry"}]}}]`,
+			requests: []mockRequest{{path: "/seq"}, {path: "/seq"}, {path: "/seq"}},
+			status:   []int{503, 200, 200},
+			check: func(t *testing.T, i int, body string) {
+				wantBody([]string{`"busy"`, `"retry"`, `"done"`}[i])(t, i, body)
+			},
+		},
+		{
+			name: "requests answered by matches do not advance the sequence",
+			config: `[{"method": "post", "url": "/pay", "response": {"status_code": 200, "body": "done"},
+				"matches": [{"jsonpath": ["$.card == \"test\""], "response": {"status_code": 402, "body": "declined"}}],
+				"sequence": {"responses": [{"status_code": 503, "body": "busy"}]}}]`,
+			requests: []mockRequest{
+				{method: "POST", path: "/pay", body: `{"card": "test"}`, headers: map[string]string{"Content-Type": "application/json"}},
+				{method: "POST", path: "/pay", body: `{"card": "visa"}`, headers: map[string]string{"Content-Type": "application/json"}},
+				{method: "POST", path: "/pay", body: `{"card": "visa"}`, headers: map[string]string{"Content-Type": "application/json"}},
+			},
+			status: []int{402, 503, 200},
+			check: func(t *testing.T, i int, body string) {
+				wantBody([]string{`"declined"`, `"busy"`, `"done"`}[i])(t, i, body)
+			},
+		},
+	})
+}
@@ -0,0 +1,151 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/gin-gonic/gin"
+)
+
+// 响应类型
+const responseSSE = "sse"
+
+// 没有设置 interval 时事件之间的间隔
+const defaultSSEInterval = time.Second
+
+// SSEEvent 一个 Server-Sent Events 事件，data 为字符串时原样发送（多行拆成多个 data 字段），
+// 其他值序列化为 JSON，都支持 @ 占位符和模板表达式
+type SSEEvent struct {
+	Event string      `json:"event,omitempty"`
+	ID    string      `json:"id,omitempty"`
+	Data  interface{} `json:"data"`
+	Retry int         `json:"retry,omitempty"` // 建议客户端断开后重连的等待毫秒数
+	Delay string      `json:"delay,omitempty"` // 发送该事件前的等待时间，默认为 interval
+}
+
+// 检查 SSE 响应的配置，返回问题说明
+func lintSSE(prefix string, r Response) []string {
+	var problems []string
+	if r.Type != "" && r.Type != responseSSE {
+		problems = append(problems, fmt.Sprintf("%s.type: unknown type %q, want sse", prefix, r.Type))
+		return problems
+	}
+	if r.Type == "" {
+		if len(r.Events) > 0 || r.Interval != "" || r.Count != 0 {
+			problems = append(problems, prefix+": events, interval and count only apply to type sse")
+		}
+		return problems
+	}
+	if _, err := time.ParseDuration(r.Interval); r.Interval != "" && err != nil {
+		problems = append(problems, fmt.Sprintf("%s.interval: %v, 1s is used", prefix, err))
+	}
+	for i, e := range r.Events {
+		problems = append(problems, value.Lint(fmt.Sprintf("%s.events[%d].data", prefix, i), e.Data)...)
+		problems = append(problems, lintTemplates(fmt.Sprintf("%s.events[%d].data", prefix, i), e.Data)...)
+		if _, err := time.ParseDuration(e.Delay); e.Delay != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("%s.events[%d].delay: %v, interval is used", prefix, i, err))
+		}
+	}
+	if len(r.Events) > 0 && r.Count != 0 {
+		problems = append(problems, prefix+".count: only applies when events is empty and body generates the events")
+	}
+	return problems
+}
+
+// 以 text/event-stream 推送事件，直到发送完或客户端断开。
+// 有 events 时依次发送（客户端带 Last-Event-ID 重连时从该事件之后开始），否则每隔 interval 按 body 生成一个事件，
+// 发送 count 个，count 为 0 时一直发送。render 生成事件的 data，generate 按 body 生成 data
+func streamEvents(c *gin.Context, response Response, render func(interface{}) interface{}, generate func() interface{}) {
+	interval := defaultSSEInterval
+	if d, err := time.ParseDuration(response.Interval); err == nil && d >= 0 {
+		interval = d
+	}
+	status := response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // 经过 nginx 时不缓冲
+	c.Status(status)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	// 等待之后再生成 data，其中的时间等值是发送时的
+	send := func(i int, e SSEEvent, data func() interface{}) bool {
+		wait := interval
+		if d, err := time.ParseDuration(e.Delay); e.Delay != "" && err == nil {
+			wait = d
+		} else if i == 0 {
+			wait = 0
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+		}
+		writeEvent(c.Writer, e, data())
+		c.Writer.Flush()
+		return ctx.Err() == nil
+	}
+
+	// 重连时跳过客户端已经收到的事件
+	last := c.GetHeader("Last-Event-ID")
+	if len(response.Events) > 0 {
+		events := response.Events
+		for i, e := range events {
+			if last != "" && e.ID == last {
+				events = events[i+1:]
+				break
+			}
+		}
+		for i, e := range events {
+			if !send(i, e, func() interface{} { return render(e.Data) }) {
+				return
+			}
+		}
+		return
+	}
+	// 生成的事件 id 为从 1 开始的序号
+	start, _ := strconv.Atoi(last)
+	start = max(start, 0)
+	for i := start; response.Count <= 0 || i < response.Count; i++ {
+		if !send(i-start, SSEEvent{ID: strconv.Itoa(i + 1)}, generate) {
+			return
+		}
+	}
+}
+
+// 按 SSE 格式写入一个事件
+func writeEvent(w io.Writer, e SSEEvent, data interface{}) {
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry)
+	}
+	text, ok := data.(string)
+	if !ok {
+		encoded, _ := json.Marshal(data)
+		text = string(encoded)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	io.WriteString(w, b.String())
+}
@@ -0,0 +1,162 @@
+package http_mock
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/gin-gonic/gin"
+)
+
+// 场景的初始状态，和 WireMock 相同
+const stateStarted = "Started"
+
+// StateRule 类似 WireMock 的 scenario：同名场景在所有路由间共享一个状态，初始为 Started。
+// require 限制只有场景处于该状态时才响应，否则返回 404；返回响应后切换到 next，
+// states 按当前状态返回不同的响应，可以各自指定切换到的状态
+type StateRule struct {
+	Scenario string          `json:"scenario"`
+	Require  string          `json:"require,omitempty"`
+	Next     string          `json:"next,omitempty"`
+	States   []StateResponse `json:"states,omitempty"`
+}
+
+// StateResponse 场景处于 state 时返回的响应，next 为空时使用 StateRule 的 next
+type StateResponse struct {
+	State    string   `json:"state"`
+	Response Response `json:"response"`
+	Next     string   `json:"next,omitempty"`
+}
+
+// ScenarioStatus 一个场景当前的状态
+type ScenarioStatus struct {
+	Scenario string `json:"scenario"`
+	State    string `json:"state"`
+}
+
+// scenarioStates 保存各场景的当前状态，重建路由后不会丢失
+type scenarioStates struct {
+	mu     sync.Mutex
+	states map[string]string
+}
+
+func newScenarioStates() *scenarioStates {
+	return &scenarioStates{states: map[string]string{}}
+}
+
+func (s *scenarioStates) current(name string) string {
+	if state, ok := s.states[name]; ok {
+		return state
+	}
+	return stateStarted
+}
+
+// 检查状态并切换，在同一把锁内完成，并发请求不会同时通过 require。
+// 返回当前状态和命中的 states 下标（没有命中为 -1），状态不满足 require 时 ok 为 false
+func (s *scenarioStates) enter(rule *StateRule) (state string, index int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state = s.current(rule.Scenario)
+	if rule.Require != "" && state != rule.Require {
+		return state, -1, false
+	}
+	index = -1
+	next := rule.Next
+	for i, r := range rule.States {
+		if r.State == state {
+			index = i
+			if r.Next != "" {
+				next = r.Next
+			}
+			break
+		}
+	}
+	if next != "" {
+		s.states[rule.Scenario] = next
+	}
+	return state, index, true
+}
+
+func (s *scenarioStates) set(name, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[name] = state
+}
+
+func (s *scenarioStates) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = map[string]string{}
+}
+
+// 按名称列出配置中出现的场景和切换过状态的场景
+func (s *scenarioStates) list(configs []MockConfig) []ScenarioStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make(map[string]bool, len(s.states))
+	for name := range s.states {
+		names[name] = true
+	}
+	for _, config := range configs {
+		if config.State != nil && config.State.Scenario != "" {
+			names[config.State.Scenario] = true
+		}
+	}
+	list := make([]ScenarioStatus, 0, len(names))
+	for name := range names {
+		list = append(list, ScenarioStatus{Scenario: name, State: s.current(name)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Scenario < list[j].Scenario })
+	return list
+}
+
+func (a *adminAPI) listStates(c *gin.Context) {
+	c.JSON(http.StatusOK, a.mocks.states.list(a.mocks.Configs()))
+}
+
+// 把场景设置为指定状态，用于直接从流程的中间开始测试
+func (a *adminAPI) setState(c *gin.Context) {
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.State == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request body must be a json object with a non-empty state"})
+		return
+	}
+	a.mocks.states.set(c.Param("name"), body.State)
+	c.JSON(http.StatusOK, ScenarioStatus{Scenario: c.Param("name"), State: body.State})
+}
+
+// 所有场景回到 Started
+func (a *adminAPI) resetStates(c *gin.Context) {
+	a.mocks.states.reset()
+	c.Status(http.StatusNoContent)
+}
+
+func lintState(rule *StateRule, params []string) []string {
+	var problems []string
+	if rule.Scenario == "" {
+		problems = append(problems, "state.scenario: empty, the state rule is ignored")
+	}
+	seen := make(map[string]bool, len(rule.States))
+	for i, r := range rule.States {
+		prefix := fmt.Sprintf("state.states[%d]", i)
+		switch {
+		case r.State == "":
+			problems = append(problems, prefix+".state: empty, the response is never returned")
+		case seen[r.State]:
+			problems = append(problems, fmt.Sprintf("%s.state: %q is listed before, the response is never returned", prefix, r.State))
+		case rule.Require != "" && r.State != rule.Require:
+			problems = append(problems, fmt.Sprintf("%s.state: %q differs from require %q, the response is never returned", prefix, r.State, rule.Require))
+		}
+		seen[r.State] = true
+		problems = append(problems, value.Lint(prefix+".response.body", r.Response.Body)...)
+		problems = append(problems, lintTemplates(prefix+".response.body", r.Response.Body)...)
+		problems = append(problems, lintParamRefs(prefix+".response.body", r.Response.Body, params)...)
+		problems = append(problems, lintHeaders(prefix+".response", r.Response, params)...)
+		problems = append(problems, lintResponse(prefix+".response", r.Response)...)
+	}
+	return problems
+}
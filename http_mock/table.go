@@ -0,0 +1,143 @@
+package http_mock
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+)
+
+// DataTable 路由关联的数据表，按路径参数查找行。找到时响应体中可以用 {{row('$.column')}} 引用行中的值，
+// response.body 为空时直接返回该行；找不到时使用 missing，没有设置 missing 时按第一行的值生成一行
+type DataTable struct {
+	File    string    `json:"file"`              // CSV（第一行为列名）或 JSON 对象数组
+	Key     string    `json:"key"`               // 用于查找的列
+	Param   string    `json:"param,omitempty"`   // 路径参数名，默认和 key 相同
+	Missing *Response `json:"missing,omitempty"` // 找不到时的响应，如 404
+}
+
+// 按 key 列的值索引的数据表
+type dataTable struct {
+	config DataTable
+	param  string
+	rows   map[string]map[string]interface{}
+	sample map[string]interface{} // 第一行，找不到时按它生成数据
+}
+
+func loadDataTable(config DataTable) (*dataTable, error) {
+	if config.File == "" || config.Key == "" {
+		return nil, fmt.Errorf("table needs file and key")
+	}
+	var rows []map[string]interface{}
+	var err error
+	if strings.EqualFold(filepath.Ext(config.File), ".csv") {
+		rows, err = readCSVTable(config.File)
+	} else {
+		rows, err = readJSONTable(config.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+	table := &dataTable{config: config, param: config.Param, rows: make(map[string]map[string]interface{}, len(rows))}
+	if table.param == "" {
+		table.param = config.Key
+	}
+	for i, row := range rows {
+		key, ok := row[config.Key]
+		if !ok {
+			return nil, fmt.Errorf("%s: row %d has no column %s", config.File, i+1, config.Key)
+		}
+		// 重复的键使用第一行
+		if _, exists := table.rows[toText(key)]; !exists {
+			table.rows[toText(key)] = row
+		}
+		if table.sample == nil {
+			table.sample = row
+		}
+	}
+	return table, nil
+}
+
+// 形如数字和布尔值的单元格转换为对应类型，以 0 开头的整数（如邮编）保留为字符串
+var csvNumberPattern = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?$`)
+
+func readCSVTable(file string) ([]map[string]interface{}, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: missing header row", file)
+	}
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			cell := record[i]
+			switch {
+			case csvNumberPattern.MatchString(cell):
+				row[column], _ = strconv.ParseFloat(cell, 64)
+			case cell == "true" || cell == "false":
+				row[column] = cell == "true"
+			default:
+				row[column] = cell
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSONTable(file string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("%s: %v, want an array of objects", file, err)
+	}
+	return rows, nil
+}
+
+// 查找路径参数对应的行，找不到时返回 false
+func (t *dataTable) lookup(key string) (map[string]interface{}, bool) {
+	row, ok := t.rows[key]
+	return row, ok
+}
+
+// 按第一行的值推断占位符生成一行，key 列使用请求的值，和 infer 命令使用相同的规则
+func (t *dataTable) generate(values *value.Handler, key string) map[string]interface{} {
+	if t.sample == nil {
+		return map[string]interface{}{t.config.Key: key}
+	}
+	in := &inferrer{literal: map[string]bool{}}
+	for _, k := range literalKeys {
+		in.literal[k] = true
+	}
+	sample := make(map[string]interface{}, len(t.sample))
+	for k, v := range t.sample {
+		if n, ok := v.(float64); ok {
+			v = json.Number(toText(n))
+		}
+		sample[k] = v
+	}
+	row, _ := values.ProcessDynamicValues(in.infer("", sample)).(map[string]interface{})
+	if row == nil {
+		row = make(map[string]interface{})
+	}
+	row[t.config.Key] = key
+	return row
+}
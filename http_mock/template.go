@@ -0,0 +1,409 @@
+package http_mock
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// 响应体中的模板表达式，是一个函数调用或 . 开头的请求引用，参数可以是字面量、请求引用或嵌套的函数调用，如
+// {{xpath('/Envelope/Body/GetOrder/Id')}}、{{upper(form('$.user.name'))}}、{{dateAdd(now(), '24h')}}、
+// {{.query.id}}、{{.body.user.name}}、{{.header.X-Trace}}。
+// 其他 {{...}}（如导入时保留的 WireMock 模板）原样返回
+var templateExprPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_]\w*\s*\(.*?\)|\.[A-Za-z_][^{}\s]*)\s*\}\}`)
+
+// 响应体中是否有模板表达式，没有时不需要逐个字符串替换
+func hasTemplates(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return templateExprPattern.MatchString(v)
+	case map[string]interface{}:
+		for _, item := range v {
+			if hasTemplates(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasTemplates(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 检查响应体中的模板表达式，返回 "路径: 问题" 形式的说明
+func lintTemplates(path string, v interface{}) []string {
+	var problems []string
+	switch v := v.(type) {
+	case string:
+		for _, m := range templateExprPattern.FindAllStringSubmatch(v, -1) {
+			if _, err := compileTemplate(m[1]); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v, the expression renders as empty", path, err))
+			}
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			problems = append(problems, lintTemplates(path+"."+k, item)...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			problems = append(problems, lintTemplates(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+	}
+	return problems
+}
+
+// templateExpr 编译后的表达式，按请求计算值
+type templateExpr func(body requestBody) (interface{}, error)
+
+// 按表达式文本缓存编译结果，编译失败时保存 error
+var compiledTemplates sync.Map
+
+func compileTemplate(text string) (templateExpr, error) {
+	if cached, ok := compiledTemplates.Load(text); ok {
+		if err, ok := cached.(error); ok {
+			return nil, err
+		}
+		return cached.(templateExpr), nil
+	}
+	p := &templateParser{text: text}
+	expr, err := p.parse()
+	if err != nil {
+		compiledTemplates.Store(text, err)
+		return nil, err
+	}
+	compiledTemplates.Store(text, expr)
+	return expr, nil
+}
+
+// 计算表达式，编译或计算失败时返回 nil
+func evalTemplate(text string, body requestBody) interface{} {
+	expr, err := compileTemplate(text)
+	if err != nil {
+		return nil
+	}
+	value, err := expr(body)
+	if err != nil {
+		logger.Debug("模板表达式计算失败", "template", text, "err", err)
+		return nil
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return value
+}
+
+// 计算响应体中的模板表达式，返回新的响应体，不修改配置。整个字符串只有一个表达式时保留值的原始类型，
+// 计算失败或取不到值时为 null；嵌在文本中的表达式按文本替换，取不到值时替换为空字符串
+func renderTemplates(v interface{}, body requestBody) interface{} {
+	switch v := v.(type) {
+	case string:
+		if m := templateExprPattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			return evalTemplate(m[1], body)
+		}
+		return templateExprPattern.ReplaceAllStringFunc(v, func(s string) string {
+			return toText(evalTemplate(templateExprPattern.FindStringSubmatch(s)[1], body))
+		})
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = renderTemplates(item, body)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = renderTemplates(item, body)
+		}
+		return result
+	}
+	return v
+}
+
+// templateParser 解析 name(arg, ...) 形式的表达式，参数是字符串、数字、true、false、null 或函数调用
+type templateParser struct {
+	text string
+	pos  int
+}
+
+// templateNode 解析出的参数，字面量同时保存值，请求引用函数在编译时解析路径
+type templateNode struct {
+	eval    templateExpr
+	value   interface{}
+	literal bool
+}
+
+func literal(v interface{}) templateNode {
+	return templateNode{
+		eval:    func(requestBody) (interface{}, error) { return v, nil },
+		value:   v,
+		literal: true,
+	}
+}
+
+func (p *templateParser) parse() (templateExpr, error) {
+	node, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, fmt.Errorf("unexpected %q in %s", p.text[p.pos:], p.text)
+	}
+	return node.eval, nil
+}
+
+func (p *templateParser) skipSpace() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *templateParser) expr() (templateNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return templateNode{}, fmt.Errorf("unexpected end of %s", p.text)
+	}
+	switch ch := p.text[p.pos]; {
+	case ch == '.':
+		return p.dotRef()
+	case ch == '\'' || ch == '"':
+		s, err := p.str()
+		if err != nil {
+			return templateNode{}, err
+		}
+		return literal(s), nil
+	case ch == '-' || ch >= '0' && ch <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.text) && (p.text[p.pos] >= '0' && p.text[p.pos] <= '9' || p.text[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			return templateNode{}, fmt.Errorf("invalid number %s in %s", p.text[start:p.pos], p.text)
+		}
+		return literal(n), nil
+	}
+	start := p.pos
+	for p.pos < len(p.text) && (p.text[p.pos] == '_' || unicode.IsLetter(rune(p.text[p.pos])) || unicode.IsDigit(rune(p.text[p.pos]))) {
+		p.pos++
+	}
+	name := p.text[start:p.pos]
+	switch name {
+	case "":
+		return templateNode{}, fmt.Errorf("unexpected %q in %s", p.text[p.pos:], p.text)
+	case "true", "false":
+		return literal(name == "true"), nil
+	case "null":
+		return literal(nil), nil
+	}
+	if p.skipSpace(); p.pos >= len(p.text) || p.text[p.pos] != '(' {
+		return templateNode{}, fmt.Errorf("expected ( after %s in %s", name, p.text)
+	}
+	p.pos++
+	var args []templateNode
+	for {
+		if p.skipSpace(); p.pos < len(p.text) && p.text[p.pos] == ')' && len(args) == 0 {
+			p.pos++
+			break
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return templateNode{}, err
+		}
+		args = append(args, arg)
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			return templateNode{}, fmt.Errorf("unclosed ( after %s in %s", name, p.text)
+		}
+		if p.text[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.text[p.pos] != ')' {
+			return templateNode{}, fmt.Errorf("expected , or ) in %s", p.text)
+		}
+		p.pos++
+		break
+	}
+	return call(name, args)
+}
+
+func call(name string, args []templateNode) (templateNode, error) {
+	if ref, ok := requestRefs[name]; ok {
+		if len(args) != 1 || !args[0].literal {
+			return templateNode{}, fmt.Errorf("%s expects one quoted argument", name)
+		}
+		path, ok := args[0].value.(string)
+		if !ok {
+			return templateNode{}, fmt.Errorf("%s expects one quoted argument", name)
+		}
+		eval, err := ref(path)
+		return templateNode{eval: eval}, err
+	}
+	fn, ok := templateFuncs[name]
+	if !ok {
+		return templateNode{}, fmt.Errorf("unknown function %s", name)
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return templateNode{}, fmt.Errorf("%s(%s) called with %d arguments", name, fn.args, len(args))
+	}
+	return templateNode{eval: func(body requestBody) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			v, err := arg.eval(body)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return fn.call(values)
+	}}, nil
+}
+
+// . 开头的请求引用，如 .query.ids[0]、.body.user.name、.header.X-Trace、.param.id，
+// 第一段是引用的来源，其余部分和 jsonpath 的路径相同
+func (p *templateParser) dotRef() (templateNode, error) {
+	start := p.pos
+	for p.pos < len(p.text) && isDotRefChar(p.text[p.pos]) {
+		p.pos++
+	}
+	text := p.text[start:p.pos]
+	root, rest := text[1:], ""
+	if i := strings.IndexAny(root, ".["); i >= 0 {
+		root, rest = root[:i], root[i:]
+	}
+	segments, err := parsePath("$" + rest)
+	if err != nil {
+		return templateNode{}, fmt.Errorf("%s: %v", text, err)
+	}
+	var eval templateExpr
+	switch root {
+	case "query":
+		eval = pathRef(segments, true, func(body requestBody) interface{} { return body.query })
+	case "form":
+		eval = pathRef(segments, true, func(body requestBody) interface{} { return body.form })
+	case "row":
+		eval = pathRef(segments, false, func(body requestBody) interface{} { return body.row })
+	case "body":
+		// JSON 请求体按原样取值，表单请求体按表单字段取值
+		json := pathRef(segments, false, func(body requestBody) interface{} { return body.json })
+		form := pathRef(segments, true, func(body requestBody) interface{} { return body.form })
+		eval = func(body requestBody) (interface{}, error) {
+			if body.form != nil {
+				return form(body)
+			}
+			return json(body)
+		}
+	case "header":
+		if len(segments) > 0 {
+			segments[0].key = strings.ToLower(segments[0].key)
+		}
+		eval = pathRef(segments, true, func(body requestBody) interface{} { return body.header })
+	case "param", "params":
+		if len(segments) != 1 || segments[0].key == "" {
+			return templateNode{}, fmt.Errorf("%s: want .param.name", text)
+		}
+		eval, _ = requestRefs["param"](segments[0].key)
+	default:
+		return templateNode{}, fmt.Errorf("unknown reference %s, want .query, .body, .header, .param, .form or .row", text)
+	}
+	return templateNode{eval: eval}, nil
+}
+
+func isDotRefChar(ch byte) bool {
+	return ch == '_' || ch == '-' || ch == '.' || ch == '[' || ch == ']' || ch == '*' || ch == '\'' || ch == '"' ||
+		ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+func (p *templateParser) str() (string, error) {
+	quote := p.text[p.pos]
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.text); p.pos++ {
+		ch := p.text[p.pos]
+		switch {
+		case ch == '\\' && p.pos+1 < len(p.text):
+			p.pos++
+			b.WriteByte(p.text[p.pos])
+		case ch == quote:
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return "", fmt.Errorf("unclosed string in %s", p.text)
+}
+
+// 引用请求的函数，参数是编译时解析的路径或名称
+var requestRefs = map[string]func(path string) (templateExpr, error){
+	"xpath": func(path string) (templateExpr, error) {
+		steps, err := parseXPath(path)
+		if err != nil {
+			return nil, err
+		}
+		return func(body requestBody) (interface{}, error) {
+			if body.xml == nil {
+				return nil, nil
+			}
+			if values := selectXPath(body.xml, steps); len(values) > 0 {
+				return values[0], nil
+			}
+			return nil, nil
+		}, nil
+	},
+	"jsonpath": jsonRef("jsonpath", func(body requestBody) interface{} { return body.json }),
+	"query":    jsonRef("query", func(body requestBody) interface{} { return body.query }),
+	"form":     jsonRef("form", func(body requestBody) interface{} { return body.form }),
+	"row":      jsonRef("row", func(body requestBody) interface{} { return body.row }),
+	// 路径参数，参数是参数名，如 /products/:sku 中的 sku
+	"param": func(name string) (templateExpr, error) {
+		return func(body requestBody) (interface{}, error) {
+			if v, ok := body.params[name]; ok {
+				return v, nil
+			}
+			return nil, nil
+		}, nil
+	},
+	// 请求头，参数是名称，不区分大小写，重复的请求头取第一个
+	"header": func(name string) (templateExpr, error) {
+		name = strings.ToLower(name)
+		return func(body requestBody) (interface{}, error) {
+			if values, ok := body.header[name].([]interface{}); ok {
+				return values[0], nil
+			}
+			return body.header[name], nil
+		}, nil
+	},
+}
+
+// query 参数和表单字段中的单个值也可以用 [0] 取值，见 selectPath
+func jsonRef(name string, root func(requestBody) interface{}) func(string) (templateExpr, error) {
+	return func(path string) (templateExpr, error) {
+		if !strings.HasPrefix(path, "$") {
+			return nil, fmt.Errorf("%s path %s must start with $", name, path)
+		}
+		segments, err := parsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		return pathRef(segments, name == "query" || name == "form", root), nil
+	}
+}
+
+// 按路径取值，匹配到多个值时取第一个，取不到时为 nil
+func pathRef(segments []pathSegment, scalarItems bool, root func(requestBody) interface{}) templateExpr {
+	return func(body requestBody) (interface{}, error) {
+		values := selectPath([]interface{}{root(body)}, segments, scalarItems)
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return values[0], nil
+	}
+}
@@ -0,0 +1,258 @@
+package http_mock
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tus 断点续传上传接口的路径前缀，实现 tus 1.0.0 的 core、creation、creation-with-upload 和 termination 扩展
+const tusPrefix = "/__tus"
+
+const tusVersion = "1.0.0"
+
+// tusServer 上传的文件保存在 dir 中，上传状态只保存在内存中
+type tusServer struct {
+	dir     string
+	maxSize int64 // 0 表示不限制
+
+	mu      sync.Mutex
+	uploads map[string]*tusUpload
+}
+
+type tusUpload struct {
+	mu       sync.Mutex
+	length   int64
+	offset   int64
+	metadata string
+	path     string
+}
+
+func newTusServer(dir string, maxSize int64) (*tusServer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &tusServer{dir: dir, maxSize: maxSize, uploads: make(map[string]*tusUpload)}, nil
+}
+
+func (t *tusServer) register(router gin.IRouter) {
+	group := router.Group(tusPrefix)
+	group.Use(t.checkVersion)
+	group.OPTIONS("", t.options)
+	group.OPTIONS("/", t.options)
+	group.POST("", t.create)
+	group.POST("/", t.create)
+	group.HEAD("/:id", t.head)
+	group.PATCH("/:id", t.patch)
+	group.DELETE("/:id", t.delete)
+	// 下载已上传的内容，不属于 tus 协议，便于在测试中校验
+	group.GET("/:id", t.download)
+}
+
+// 除 OPTIONS 和下载外的请求必须带上支持的 Tus-Resumable
+func (t *tusServer) checkVersion(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.Request.Method == http.MethodOptions || c.Request.Method == http.MethodGet {
+		return
+	}
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+	}
+}
+
+func (t *tusServer) options(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,creation-with-upload,termination")
+	if t.maxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(t.maxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (t *tusServer) create(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if t.maxSize > 0 && length > t.maxSize {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := validateMetadata(c.GetHeader("Upload-Metadata")); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := newUploadID()
+	upload := &tusUpload{length: length, metadata: c.GetHeader("Upload-Metadata"), path: filepath.Join(t.dir, id)}
+	f, err := os.Create(upload.path)
+	if err != nil {
+		logger.Error("创建上传文件失败", "err", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	f.Close()
+	t.mu.Lock()
+	t.uploads[id] = upload
+	t.mu.Unlock()
+	logger.Info("创建上传", "id", id, "length", length)
+
+	c.Header("Location", tusPrefix+"/"+id)
+	// creation-with-upload：创建请求可以同时带上第一段数据
+	if c.GetHeader("Content-Type") == "application/offset+octet-stream" {
+		upload.mu.Lock()
+		defer upload.mu.Unlock()
+		if err := upload.write(c.Request.Body); err != nil {
+			logger.Warn("写入上传数据失败", "id", id, "err", err)
+		}
+		c.Header("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	}
+	c.Status(http.StatusCreated)
+}
+
+func (t *tusServer) lookup(c *gin.Context) *tusUpload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	upload, ok := t.uploads[c.Param("id")]
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return nil
+	}
+	return upload
+}
+
+func (t *tusServer) head(c *gin.Context) {
+	upload := t.lookup(c)
+	if upload == nil {
+		return
+	}
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.length, 10))
+	if upload.metadata != "" {
+		c.Header("Upload-Metadata", upload.metadata)
+	}
+	c.Status(http.StatusOK)
+}
+
+func (t *tusServer) patch(c *gin.Context) {
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	}
+	upload := t.lookup(c)
+	if upload == nil {
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+	// 同一个上传同时只处理一个 PATCH，偏移不一致时客户端需要先 HEAD 查询
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	if offset != upload.offset {
+		c.Status(http.StatusConflict)
+		return
+	}
+	// 连接中断时保留已经写入的部分，客户端可以从新的偏移继续
+	if err := upload.write(c.Request.Body); err != nil {
+		logger.Info("上传中断", "id", c.Param("id"), "offset", upload.offset, "err", err)
+	}
+	if upload.offset == upload.length {
+		logger.Info("上传完成", "id", c.Param("id"), "length", upload.length)
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+func (t *tusServer) delete(c *gin.Context) {
+	upload := t.lookup(c)
+	if upload == nil {
+		return
+	}
+	t.mu.Lock()
+	delete(t.uploads, c.Param("id"))
+	t.mu.Unlock()
+	upload.mu.Lock()
+	os.Remove(upload.path)
+	upload.mu.Unlock()
+	c.Status(http.StatusNoContent)
+}
+
+func (t *tusServer) download(c *gin.Context) {
+	upload := t.lookup(c)
+	if upload == nil {
+		return
+	}
+	upload.mu.Lock()
+	done := upload.offset == upload.length
+	upload.mu.Unlock()
+	if !done {
+		c.String(http.StatusConflict, "upload not finished")
+		return
+	}
+	serveFile(c, upload.path)
+}
+
+// 把请求体追加到文件末尾，超过 Upload-Length 时返回错误。调用方持有 u.mu
+func (u *tusUpload) write(body io.Reader) error {
+	f, err := os.OpenFile(u.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(u.offset, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(body, u.length-u.offset))
+	u.offset += n
+	if err != nil {
+		return err
+	}
+	if extra, _ := body.Read(make([]byte, 1)); extra > 0 {
+		return errors.New("body exceeds Upload-Length")
+	}
+	return nil
+}
+
+// Upload-Metadata 为逗号分隔的 key base64(value)
+func validateMetadata(metadata string) error {
+	if metadata == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(metadata, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return fmt.Errorf("invalid Upload-Metadata %q", pair)
+		}
+		if len(fields) == 2 {
+			if _, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+				return fmt.Errorf("invalid Upload-Metadata %q: %v", pair, err)
+			}
+		}
+	}
+	return nil
+}
+
+func newUploadID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,136 @@
+package http_mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteUsage 一个 mock 配置的命中次数
+type RouteUsage struct {
+	Method  string     `json:"method"`
+	URL     string     `json:"url"`
+	Hits    int64      `json:"hits"`
+	LastHit *time.Time `json:"last_hit,omitempty"`
+}
+
+// UsageReport 当前所有 mock 配置的命中统计，Unused 列出从未命中的配置
+type UsageReport struct {
+	Since  time.Time    `json:"since"`
+	Total  int          `json:"total"`
+	Used   int          `json:"used"`
+	Routes []RouteUsage `json:"routes"`
+	Unused []RouteUsage `json:"unused"`
+}
+
+type routeCounter struct {
+	hits    atomic.Int64
+	lastHit atomic.Int64 // UnixNano
+}
+
+// 记录一次命中，返回包括本次在内的命中次数和上一次命中的时间
+func (r *routeCounter) hit() (int64, time.Time) {
+	hits := r.hits.Add(1)
+	previous := r.lastHit.Swap(time.Now().UnixNano())
+	if previous == 0 {
+		return hits, time.Time{}
+	}
+	return hits, time.Unix(0, previous)
+}
+
+// usageTracker 按方法和路径统计命中次数，管理接口修改配置重建路由后统计不会丢失
+type usageTracker struct {
+	mu     sync.Mutex
+	since  time.Time
+	routes map[string]*routeCounter
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{since: time.Now(), routes: map[string]*routeCounter{}}
+}
+
+func (t *usageTracker) route(method, url string) *routeCounter {
+	key := strings.ToUpper(method) + " " + url
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.routes[key]
+	if !ok {
+		r = &routeCounter{}
+		t.routes[key] = r
+	}
+	return r
+}
+
+func (t *usageTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.since = time.Now()
+	// 路由中保存了计数器，只能清零
+	for _, r := range t.routes {
+		r.hits.Store(0)
+		r.lastHit.Store(0)
+	}
+}
+
+// 按配置顺序生成统计
+func (t *usageTracker) report(configs []MockConfig) UsageReport {
+	t.mu.Lock()
+	report := UsageReport{Since: t.since, Total: len(configs), Routes: []RouteUsage{}, Unused: []RouteUsage{}}
+	t.mu.Unlock()
+	for _, config := range configs {
+		r := t.route(config.Method, config.URL)
+		usage := RouteUsage{Method: strings.ToUpper(config.Method), URL: config.URL, Hits: r.hits.Load()}
+		if usage.Hits == 0 {
+			report.Unused = append(report.Unused, usage)
+		} else {
+			last := time.Unix(0, r.lastHit.Load())
+			usage.LastHit = &last
+			report.Used++
+		}
+		report.Routes = append(report.Routes, usage)
+	}
+	return report
+}
+
+// Usage 返回当前 mock 配置的命中统计
+func (h *HttpMockHandler) Usage() UsageReport {
+	return h.usage.report(h.Configs())
+}
+
+// 停止时输出从未命中的配置，UsageFile 不为空时写入完整统计
+func (h *HttpMockHandler) reportUsage() {
+	report := h.Usage()
+	logger.Info("mock 命中统计", "total", report.Total, "used", report.Used, "unused", len(report.Unused))
+	for _, r := range report.Unused {
+		logger.Info("未命中的 mock", "method", r.Method, "url", r.URL)
+	}
+	if h.UsageFile == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(h.UsageFile, append(data, '\n'), 0o644); err != nil {
+		logger.Error("写入命中统计失败", "file", h.UsageFile, "err", err)
+		return
+	}
+	logger.Info("命中统计已写入", "file", h.UsageFile)
+}
+
+// unused=true 时只返回从未命中的配置
+func (a *adminAPI) getUsage(c *gin.Context) {
+	report := a.mocks.Usage()
+	if c.Query("unused") == "true" {
+		report.Routes = report.Unused
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (a *adminAPI) resetUsage(c *gin.Context) {
+	a.mocks.usage.reset()
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package http_mock
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version 同一路由按 API 版本返回的响应。accept 匹配 Accept 请求头中的媒体类型，如 application/vnd.api.v2+json，
+// 命中时响应的 Content-Type 使用该媒体类型；header 按请求头匹配，格式为 "Name: value"，如 "X-API-Version: 2"。
+// 两者都设置时满足其一即可，都不满足时返回 MockConfig 的 response
+type Version struct {
+	Accept   string   `json:"accept,omitempty"`
+	Header   string   `json:"header,omitempty"`
+	Response Response `json:"response"`
+}
+
+// 返回请求对应的版本序号和响应的 Content-Type，没有命中时序号为 -1
+func selectVersion(c *gin.Context, versions []Version) (int, string) {
+	accepted := acceptedTypes(c.GetHeader("Accept"))
+	for i, v := range versions {
+		if v.Accept != "" {
+			want := normalizeMediaType(v.Accept)
+			for _, mediaType := range accepted {
+				if mediaType == want || (!strings.Contains(want, ";") && strings.SplitN(mediaType, ";", 2)[0] == want) {
+					return i, v.Accept
+				}
+			}
+		}
+		if name, value, ok := strings.Cut(v.Header, ":"); ok && strings.TrimSpace(name) != "" {
+			if strings.EqualFold(strings.TrimSpace(c.GetHeader(strings.TrimSpace(name))), strings.TrimSpace(value)) {
+				return i, ""
+			}
+		}
+	}
+	return -1, ""
+}
+
+// 响应随哪些请求头变化，供缓存使用
+func varyHeader(versions []Version) string {
+	names := []string{"Accept"}
+	for _, v := range versions {
+		if name, _, ok := strings.Cut(v.Header, ":"); ok {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Accept 中的媒体类型，去掉 q 参数和空白后转为小写
+func acceptedTypes(accept string) []string {
+	var types []string
+	for _, item := range strings.Split(accept, ",") {
+		if item = normalizeMediaType(item); item != "" {
+			types = append(types, item)
+		}
+	}
+	return types
+}
+
+func normalizeMediaType(s string) string {
+	parts := strings.Split(strings.ToLower(s), ";")
+	result := []string{strings.TrimSpace(parts[0])}
+	for _, param := range parts[1:] {
+		if param = strings.ReplaceAll(param, " ", ""); param != "" && !strings.HasPrefix(param, "q=") {
+			result = append(result, param)
+		}
+	}
+	return strings.Join(result, ";")
+}
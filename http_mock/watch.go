@@ -0,0 +1,202 @@
+package http_mock
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+)
+
+// 编辑器保存文件时可能连续产生多个事件，最后一个事件之后等待这么久再重新加载
+const reloadDelay = 200 * time.Millisecond
+
+// configWatch 需要监听的目录和其中会影响配置的文件
+type configWatch struct {
+	dirs     []string        // 监听的目录
+	files    map[string]bool // 配置文件、环境覆盖文件和 -overlay 文件
+	jsonDirs map[string]bool // 配置目录和其中的环境覆盖目录，目录下的 *.json 都会读取
+}
+
+// 编辑器通常先写临时文件再改名替换原文件，直接监听文件会在替换后失效，这里监听文件所在的目录
+func (h *HttpMockHandler) configWatch() configWatch {
+	w := configWatch{files: make(map[string]bool), jsonDirs: make(map[string]bool)}
+	dirs := make(map[string]bool)
+	addFile := func(file string) {
+		file = filepath.Clean(file)
+		w.files[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+	for _, path := range h.path {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Clean(path)
+			w.jsonDirs[path] = true
+			dirs[path] = true
+			if h.Env != "" {
+				// 环境目录可能还不存在，创建后需要重新启动才能监听其中的文件
+				env := filepath.Join(path, h.Env)
+				if info, err := os.Stat(env); err == nil && info.IsDir() {
+					w.jsonDirs[env] = true
+					dirs[env] = true
+				}
+			}
+			continue
+		}
+		addFile(path)
+		if h.Env != "" {
+			addFile(strings.TrimSuffix(path, filepath.Ext(path)) + "." + h.Env + filepath.Ext(path))
+		}
+	}
+	for _, overlay := range h.Overlays {
+		addFile(overlay)
+	}
+	// 占位符使用的命名分布，当前配置中引用的数据表和 body_file
+	if file := value.DistributionsFile(); file != "" {
+		addFile(file)
+	}
+	for _, config := range h.Configs() {
+		if config.Table != nil && config.Table.File != "" {
+			addFile(config.Table.File)
+		}
+		for _, file := range config.bodyFiles() {
+			addFile(file)
+		}
+	}
+	for dir := range dirs {
+		w.dirs = append(w.dirs, dir)
+	}
+	return w
+}
+
+// 文件变化是否影响配置
+func (w configWatch) relevant(name string) bool {
+	name = filepath.Clean(name)
+	return w.files[name] || (filepath.Ext(name) == ".json" && w.jsonDirs[filepath.Dir(name)])
+}
+
+// 监听配置文件，变化后重新加载全部配置并替换路由，ctx 取消后停止。
+// 新配置有错误时保留当前配置；通过管理接口做的修改会被文件中的配置覆盖
+func (h *HttpMockHandler) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	w := h.configWatch()
+	watched := make(map[string]bool)
+	for _, dir := range w.dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		watched[dir] = true
+	}
+	logger.Info("监听配置文件变化", "dirs", w.dirs)
+	// 重新加载的配置可能引用新的数据表和 body_file，之后它们的变化也要触发重新加载
+	h.rewatch = func() {
+		next := h.configWatch()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, dir := range next.dirs {
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				logger.Warn("监听目录失败", "dir", dir, "err", err)
+				continue
+			}
+			watched[dir] = true
+			logger.Debug("监听新的目录", "dir", dir)
+		}
+		w = next
+	}
+
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				mu.Lock()
+				relevant := w.relevant(event.Name)
+				mu.Unlock()
+				if event.Op == fsnotify.Chmod || !relevant {
+					continue
+				}
+				logger.Debug("配置文件变化", "file", event.Name, "op", event.Op.String())
+				if timer == nil {
+					timer = time.AfterFunc(reloadDelay, func() {
+						if _, err := h.reload(); err != nil {
+							logger.Error("重新加载配置失败，继续使用当前配置", "err", err)
+						}
+					})
+				} else {
+					timer.Reset(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("监听配置文件失败", "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// ReloadResult 重新加载后的路由数和命名分布数
+type ReloadResult struct {
+	Routes        int `json:"routes"`
+	Distributions int `json:"distributions"`
+}
+
+// 重新读取命名分布、配置文件和覆盖文件，成功后替换当前路由，数据表文件在替换路由时重新读取。
+// 命名分布和配置分别替换，配置有错误时已经读取的分布仍然生效
+func (h *HttpMockHandler) reload() (ReloadResult, error) {
+	var result ReloadResult
+	var err error
+	if result.Distributions, err = value.ReloadDistributions(); err != nil {
+		return result, err
+	}
+	configs, err := h.loadConfigs()
+	if err != nil {
+		return result, err
+	}
+	h.mu.Lock()
+	err = h.setConfigs(configs)
+	h.mu.Unlock()
+	if err != nil {
+		return result, err
+	}
+	if h.rewatch != nil {
+		h.rewatch()
+	}
+	result.Routes = len(configs)
+	logger.Info("已重新加载配置", "routes", result.Routes, "distributions", result.Distributions)
+	return result, nil
+}
+
+// 不重启服务重新加载配置文件、数据表和命名分布，失败时返回 500 并继续使用当前配置
+func (a *adminAPI) reload(c *gin.Context) {
+	result, err := a.mocks.reload()
+	if err != nil {
+		logger.Error("重新加载配置失败，继续使用当前配置", "err", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,296 @@
+package http_mock
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode 解析后的 XML 元素，名称和属性名都去掉了命名空间前缀，/Envelope/Body 可以匹配 soap:Envelope/soap:Body
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string // 元素自身的文本，不含子元素
+}
+
+// 返回文档节点，根元素是它唯一的子节点
+func parseXML(data []byte) (*xmlNode, error) {
+	doc := &xmlNode{}
+	stack := []*xmlNode{doc}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			current.text += string(t)
+		}
+	}
+	if len(doc.children) == 0 {
+		return nil, fmt.Errorf("no root element")
+	}
+	return doc, nil
+}
+
+// 元素的字符串值：自身和所有子元素的文本，去掉首尾空白
+func (n *xmlNode) value() string {
+	var b strings.Builder
+	var walk func(*xmlNode)
+	walk = func(n *xmlNode) {
+		b.WriteString(n.text)
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
+// 自身和所有后代元素，按文档顺序
+func (n *xmlNode) descendantsOrSelf(nodes []*xmlNode) []*xmlNode {
+	nodes = append(nodes, n)
+	for _, child := range n.children {
+		nodes = child.descendantsOrSelf(nodes)
+	}
+	return nodes
+}
+
+// xpathStep 路径中的一步。attr 和 text 只能出现在最后一步
+type xpathStep struct {
+	descendant bool   // 以 // 开头
+	name       string // 元素名或属性名，* 匹配任意名称
+	attr       bool
+	text       bool
+	predicates []xpathPredicate
+}
+
+// xpathPredicate 步骤上的条件：[n]、[last()]、[@name='v'] 或 [name='v']，只写名称时要求属性或子元素存在
+type xpathPredicate struct {
+	index    int // 从 1 开始，-1 表示 last()
+	attr     bool
+	name     string
+	value    string
+	hasValue bool
+}
+
+// 解析 XPath 的常用子集：/a/b、//b、*、@attr、text() 和上面几种条件
+func parseXPath(path string) ([]xpathStep, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("xpath %s must start with /", path)
+	}
+	var steps []xpathStep
+	rest := path
+	for rest != "" {
+		var step xpathStep
+		if strings.HasPrefix(rest, "//") {
+			step.descendant = true
+			rest = rest[2:]
+		} else if rest[0] == '/' {
+			rest = rest[1:]
+		} else {
+			return nil, fmt.Errorf("unexpected %q in xpath %s", rest, path)
+		}
+		end := stepEnd(rest)
+		text := rest[:end]
+		rest = rest[end:]
+		if len(steps) > 0 && (steps[len(steps)-1].attr || steps[len(steps)-1].text) {
+			return nil, fmt.Errorf("@attribute and text() must be the last step of xpath %s", path)
+		}
+		name, predicates, _ := strings.Cut(text, "[")
+		switch {
+		case name == "text()":
+			step.text = true
+		case strings.HasPrefix(name, "@"):
+			step.attr, step.name = true, name[1:]
+		default:
+			step.name = name
+		}
+		if step.name == "" && !step.text || strings.ContainsAny(step.name, "()'\"") {
+			return nil, fmt.Errorf("invalid step %q in xpath %s", text, path)
+		}
+		if predicates != "" {
+			if step.attr || step.text {
+				return nil, fmt.Errorf("conditions on @attribute or text() are not supported in xpath %s", path)
+			}
+			for _, p := range strings.Split(strings.TrimSuffix(predicates, "]"), "][") {
+				predicate, err := parsePredicate(p)
+				if err != nil {
+					return nil, fmt.Errorf("invalid condition [%s] in xpath %s", p, path)
+				}
+				step.predicates = append(step.predicates, predicate)
+			}
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("empty xpath %s", path)
+	}
+	return steps, nil
+}
+
+// 下一个不在 [] 和引号中的 / 的位置
+func stepEnd(s string) int {
+	depth, quote := 0, byte(0)
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+		case ch == '/' && depth == 0:
+			return i
+		}
+	}
+	return len(s)
+}
+
+func parsePredicate(p string) (xpathPredicate, error) {
+	p = strings.TrimSpace(p)
+	if p == "last()" {
+		return xpathPredicate{index: -1}, nil
+	}
+	if n, err := strconv.Atoi(p); err == nil {
+		if n < 1 {
+			return xpathPredicate{}, fmt.Errorf("position starts at 1")
+		}
+		return xpathPredicate{index: n}, nil
+	}
+	var predicate xpathPredicate
+	name, literal, hasValue := strings.Cut(p, "=")
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "@") {
+		predicate.attr, name = true, name[1:]
+	}
+	if name == "" || strings.ContainsAny(name, "()'\" ") {
+		return xpathPredicate{}, fmt.Errorf("invalid name")
+	}
+	predicate.name = name
+	if hasValue {
+		literal = strings.TrimSpace(literal)
+		if len(literal) < 2 || (literal[0] != '\'' && literal[0] != '"') || literal[len(literal)-1] != literal[0] {
+			return xpathPredicate{}, fmt.Errorf("value must be quoted")
+		}
+		predicate.value, predicate.hasValue = literal[1:len(literal)-1], true
+	}
+	return predicate, nil
+}
+
+func nameMatches(pattern, name string) bool {
+	return pattern == "*" || pattern == name
+}
+
+// 按路径取值，返回元素或属性的字符串值
+func selectXPath(doc *xmlNode, steps []xpathStep) []string {
+	nodes := []*xmlNode{doc}
+	for _, step := range steps {
+		var contexts []*xmlNode
+		if step.descendant {
+			seen := make(map[*xmlNode]bool)
+			for _, n := range nodes {
+				for _, d := range n.descendantsOrSelf(nil) {
+					if !seen[d] {
+						seen[d] = true
+						contexts = append(contexts, d)
+					}
+				}
+			}
+		} else {
+			contexts = nodes
+		}
+		if step.attr || step.text {
+			var values []string
+			for _, n := range contexts {
+				if step.text {
+					if text := strings.TrimSpace(n.text); text != "" {
+						values = append(values, text)
+					}
+					continue
+				}
+				for name, v := range n.attrs {
+					if nameMatches(step.name, name) {
+						values = append(values, v)
+					}
+				}
+			}
+			return values
+		}
+		var next []*xmlNode
+		for _, n := range contexts {
+			// 位置条件按同一个父元素下的匹配元素计算
+			var matched []*xmlNode
+			for _, child := range n.children {
+				if nameMatches(step.name, child.name) {
+					matched = append(matched, child)
+				}
+			}
+			for _, predicate := range step.predicates {
+				matched = predicate.filter(matched)
+			}
+			next = append(next, matched...)
+		}
+		if nodes = next; len(nodes) == 0 {
+			break
+		}
+	}
+	values := make([]string, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.value()
+	}
+	return values
+}
+
+func (p xpathPredicate) filter(nodes []*xmlNode) []*xmlNode {
+	switch {
+	case p.index == -1 && len(nodes) > 0:
+		return nodes[len(nodes)-1:]
+	case p.index > 0:
+		if p.index > len(nodes) {
+			return nil
+		}
+		return nodes[p.index-1 : p.index]
+	}
+	var result []*xmlNode
+	for _, n := range nodes {
+		if p.attr {
+			if v, ok := n.attrs[p.name]; ok && (!p.hasValue || v == p.value) {
+				result = append(result, n)
+			}
+			continue
+		}
+		for _, child := range n.children {
+			if child.name == p.name && (!p.hasValue || child.value() == p.value) {
+				result = append(result, n)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,277 @@
+package ldap_mock
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/goccy/go-yaml"
+)
+
+// 未指定 -config 时使用的示例目录
+//
+//go:embed directory.yaml
+var defaultConfig []byte
+
+// Config 目录的初始条目和按模板生成的用户
+type Config struct {
+	BaseDN         string         `yaml:"base_dn"`         // 根 DSE 中返回的 namingContexts
+	AllowAnonymous bool           `yaml:"allow_anonymous"` // 允许不绑定直接查询
+	Entries        []Entry        `yaml:"entries"`
+	Users          []UserTemplate `yaml:"users"`
+}
+
+// Entry 目录条目，属性值是字符串或字符串数组，有 userPassword 属性的条目可以绑定
+type Entry struct {
+	DN         string                 `yaml:"dn"`
+	Attributes map[string]interface{} `yaml:"attributes"`
+}
+
+// UserTemplate 按模板批量生成用户条目。dn、password 和属性值中的 ${i} 替换为从 1 开始的序号，属性值支持 @name、@email 等占位符
+type UserTemplate struct {
+	Count      int                    `yaml:"count"`
+	DN         string                 `yaml:"dn"`
+	Password   string                 `yaml:"password"` // 写入 userPassword 属性
+	Attributes map[string]interface{} `yaml:"attributes"`
+}
+
+func loadConfig(path string) (*Config, error) {
+	content := defaultConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content = data
+	}
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// entry 目录中的条目，dn 保留配置中的写法，key 是规范化后用于比较的 DN
+type entry struct {
+	dn         string
+	key        string
+	attributes []attribute
+}
+
+type attribute struct {
+	name   string
+	values []string
+}
+
+// 按名称查找属性，属性名不区分大小写
+func (e *entry) get(name string) *attribute {
+	for i := range e.attributes {
+		if strings.EqualFold(e.attributes[i].name, name) {
+			return &e.attributes[i]
+		}
+	}
+	return nil
+}
+
+func (e *entry) add(name string, values ...string) {
+	if a := e.get(name); a != nil {
+		a.values = append(a.values, values...)
+		return
+	}
+	e.attributes = append(e.attributes, attribute{name: name, values: values})
+}
+
+// directory 只读的目录树，启动时生成，之后不再修改
+type directory struct {
+	baseDN  string
+	entries []*entry
+	byKey   map[string]*entry
+}
+
+func (c *Config) newDirectory() (*directory, error) {
+	d := &directory{baseDN: c.BaseDN, byKey: map[string]*entry{}}
+	for _, e := range c.Entries {
+		if err := d.insert(e.DN, e.Attributes, nil); err != nil {
+			return nil, err
+		}
+	}
+	values := value.NewValueHandler()
+	for _, u := range c.Users {
+		for i := 1; i <= u.Count; i++ {
+			index := strconv.Itoa(i)
+			attributes := values.ProcessDynamicMap(replaceIndex(u.Attributes, index).(map[string]interface{}))
+			var password []string
+			if u.Password != "" {
+				password = []string{strings.ReplaceAll(u.Password, "${i}", index)}
+			}
+			if err := d.insert(strings.ReplaceAll(u.DN, "${i}", index), attributes, password); err != nil {
+				return nil, err
+			}
+		}
+	}
+	d.addMemberOf()
+	return d, nil
+}
+
+func (d *directory) insert(dn string, attributes map[string]interface{}, password []string) error {
+	key := normalizeDN(dn)
+	if key == "" {
+		return fmt.Errorf("entry without dn")
+	}
+	if _, ok := d.byKey[key]; ok {
+		return fmt.Errorf("duplicate dn %q", dn)
+	}
+	e := &entry{dn: dn, key: key}
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e.add(name, attributeValues(attributes[name])...)
+	}
+	if len(password) > 0 {
+		e.add("userPassword", password...)
+	}
+	// 和真实目录一样，RDN 中的属性总是出现在条目中
+	if name, val, ok := strings.Cut(splitDN(dn)[0], "="); ok && e.get(strings.TrimSpace(name)) == nil {
+		e.add(strings.TrimSpace(name), strings.TrimSpace(val))
+	}
+	d.entries = append(d.entries, e)
+	d.byKey[key] = e
+	return nil
+}
+
+// 根据组的 member、uniqueMember 属性为成员补充 memberOf，已经配置了 memberOf 的条目不修改
+func (d *directory) addMemberOf() {
+	configured := map[*entry]bool{}
+	for _, e := range d.entries {
+		if e.get("memberOf") != nil {
+			configured[e] = true
+		}
+	}
+	for _, group := range d.entries {
+		for _, name := range []string{"member", "uniqueMember"} {
+			a := group.get(name)
+			if a == nil {
+				continue
+			}
+			for _, member := range a.values {
+				if e := d.byKey[normalizeDN(member)]; e != nil && !configured[e] {
+					e.add("memberOf", group.dn)
+				}
+			}
+		}
+	}
+}
+
+// 查询范围
+const (
+	scopeBase = 0
+	scopeOne  = 1
+	scopeSub  = 2
+)
+
+// 返回 base 下按 scope 匹配的条目，保持配置中的顺序
+func (d *directory) scope(base string, scope int64) []*entry {
+	var result []*entry
+	for _, e := range d.entries {
+		var ok bool
+		switch scope {
+		case scopeBase:
+			ok = e.key == base
+		case scopeOne:
+			ok = parentDN(e.key) == base
+		default:
+			ok = base == "" || e.key == base || strings.HasSuffix(e.key, ","+base)
+		}
+		if ok {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// 规范化 DN：属性名和值转为小写，去掉分隔符两侧的空格
+func normalizeDN(dn string) string {
+	var rdns []string
+	for _, rdn := range splitDN(dn) {
+		var parts []string
+		for _, ava := range strings.Split(rdn, "+") {
+			name, val, _ := strings.Cut(ava, "=")
+			parts = append(parts, strings.ToLower(strings.TrimSpace(name))+"="+strings.ToLower(strings.TrimSpace(val)))
+		}
+		sort.Strings(parts)
+		rdns = append(rdns, strings.Join(parts, "+"))
+	}
+	return strings.Join(rdns, ",")
+}
+
+// 按没有转义的逗号拆分 DN
+func splitDN(dn string) []string {
+	var rdns []string
+	start := 0
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case ',':
+			rdns = append(rdns, dn[start:i])
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(dn[start:]); rest != "" || len(rdns) > 0 {
+		rdns = append(rdns, dn[start:])
+	}
+	return rdns
+}
+
+func parentDN(key string) string {
+	rdns := splitDN(key)
+	if len(rdns) <= 1 {
+		return ""
+	}
+	return strings.Join(rdns[1:], ",")
+}
+
+// 把 ${i} 替换为序号，递归处理数组和对象
+func replaceIndex(v interface{}, index string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return strings.ReplaceAll(v, "${i}", index)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = replaceIndex(item, index)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = replaceIndex(item, index)
+		}
+		return result
+	}
+	return v
+}
+
+// 把配置中的属性值转换为字符串列表
+func attributeValues(v interface{}) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, attributeValues(item)...)
+		}
+		return values
+	case string:
+		return []string{v}
+	}
+	return []string{fmt.Sprint(v)}
+}
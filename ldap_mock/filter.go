@@ -0,0 +1,181 @@
+package ldap_mock
+
+import (
+	"strconv"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// 查询过滤器的标签，见 RFC 4511 4.5.1
+const (
+	filterAnd        = 0
+	filterOr         = 1
+	filterNot        = 2
+	filterEquality   = 3
+	filterSubstrings = 4
+	filterGreater    = 5
+	filterLess       = 6
+	filterPresent    = 7
+	filterApprox     = 8
+	filterExtensible = 9
+)
+
+// 判断条目是否匹配过滤器，不支持的过滤器不匹配任何条目
+func (e *entry) match(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !e.match(child) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.Children {
+			if e.match(child) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return len(filter.Children) == 1 && !e.match(filter.Children[0])
+	case filterPresent:
+		name := text(filter)
+		return strings.EqualFold(name, "objectClass") || e.get(name) != nil
+	case filterEquality, filterApprox:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		return e.any(text(filter.Children[0]), func(v string) bool { return equalValue(v, text(filter.Children[1])) })
+	case filterGreater, filterLess:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		want := text(filter.Children[1])
+		return e.any(text(filter.Children[0]), func(v string) bool {
+			c := compareValue(v, want)
+			return c == 0 || (c > 0) == (filter.Tag == filterGreater)
+		})
+	case filterSubstrings:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		return e.any(text(filter.Children[0]), func(v string) bool { return matchSubstrings(v, filter.Children[1].Children) })
+	case filterExtensible:
+		// 忽略 matchingRule，按属性相等处理，可以匹配 AD 的 memberOf:1.2.840.113556.1.4.1941:= 写法
+		var name, want string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case 2:
+				name = text(child)
+			case 3:
+				want = text(child)
+			}
+		}
+		return name != "" && e.any(name, func(v string) bool { return equalValue(v, want) })
+	}
+	return false
+}
+
+func (e *entry) any(name string, match func(string) bool) bool {
+	a := e.get(name)
+	if a == nil {
+		return false
+	}
+	for _, v := range a.values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 值比较不区分大小写，DN 按规范化后的形式比较
+func equalValue(v, want string) bool {
+	if strings.EqualFold(v, want) {
+		return true
+	}
+	return strings.Contains(want, "=") && normalizeDN(v) == normalizeDN(want)
+}
+
+// 两边都是数字时按数值比较，否则按小写字符串比较
+func compareValue(v, want string) int {
+	a, errA := strconv.ParseFloat(v, 64)
+	b, errB := strconv.ParseFloat(want, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(v), strings.ToLower(want))
+}
+
+// substrings 过滤器由 initial、any、final 三种片段组成
+func matchSubstrings(v string, parts []*ber.Packet) bool {
+	v = strings.ToLower(v)
+	for _, part := range parts {
+		s := strings.ToLower(text(part))
+		switch part.Tag {
+		case 0:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case 1:
+			i := strings.Index(v, s)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(s):]
+		case 2:
+			if !strings.HasSuffix(v, s) {
+				return false
+			}
+			v = ""
+		}
+	}
+	return true
+}
+
+// 把过滤器还原为 RFC 4515 的字符串形式，用于日志
+func filterString(filter *ber.Packet) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	switch filter.Tag {
+	case filterAnd, filterOr, filterNot:
+		b.WriteString([]string{"&", "|", "!"}[filter.Tag])
+		for _, child := range filter.Children {
+			b.WriteString(filterString(child))
+		}
+	case filterPresent:
+		b.WriteString(text(filter) + "=*")
+	case filterEquality, filterApprox, filterGreater, filterLess:
+		if len(filter.Children) == 2 {
+			op := map[ber.Tag]string{filterEquality: "=", filterApprox: "~=", filterGreater: ">=", filterLess: "<="}[filter.Tag]
+			b.WriteString(text(filter.Children[0]) + op + text(filter.Children[1]))
+		}
+	case filterSubstrings:
+		if len(filter.Children) == 2 {
+			b.WriteString(text(filter.Children[0]) + "=")
+			parts := filter.Children[1].Children
+			for i, part := range parts {
+				if part.Tag != 0 && i == 0 {
+					b.WriteByte('*')
+				}
+				b.WriteString(text(part))
+				if part.Tag != 2 {
+					b.WriteByte('*')
+				}
+			}
+		}
+	default:
+		b.WriteString("?")
+	}
+	b.WriteByte(')')
+	return b.String()
+}
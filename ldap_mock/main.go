@@ -0,0 +1,94 @@
+// Package ldap_mock 提供只读的 LDAP 目录服务，用于在没有真实目录的环境中测试依赖 LDAP 的登录和授权逻辑：
+// 目录条目由配置和用户模板生成，支持简单绑定、查询（含分页）、比较和 WhoAmI。
+package ldap_mock
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/TreeWu/mock-go/logging"
+)
+
+var logger = logging.For("ldap")
+
+// server 所有连接共用的配置和目录
+type server struct {
+	config    *Config
+	directory *directory
+}
+
+// Run 启动 LDAP 服务，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("ldap", flag.ContinueOnError)
+	configFile := fs.String("config", "", "yaml/json directory with entries and user templates, built-in example directory when empty")
+	port := fs.String("port", envOr("LDAP_PORT", ":1389"), "listen address (env LDAP_PORT)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		logger.Error("读取配置失败", "err", err)
+		return 1
+	}
+	directory, err := config.newDirectory()
+	if err != nil {
+		logger.Error("创建目录失败", "err", err)
+		return 1
+	}
+	s := &server{config: config, directory: directory}
+
+	listener, err := net.Listen("tcp", listenAddr(*port))
+	if err != nil {
+		logger.Error("启动 LDAP 服务失败", "err", err)
+		return 1
+	}
+	defer listener.Close()
+	logger.Info("LDAP Mock 服务器启动", "addr", listener.Addr().String(), "base_dn", config.BaseDN, "entries", len(directory.entries))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.serve(listener) }()
+	select {
+	case err := <-errCh:
+		logger.Error("服务异常退出", "err", err)
+		return 1
+	case <-ctx.Done():
+		logger.Info("正在停止 Mock 服务器")
+		return 0
+	}
+}
+
+func (s *server) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go (&session{server: s, conn: conn, client: conn.RemoteAddr().String()}).serve()
+	}
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
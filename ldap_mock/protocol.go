@@ -0,0 +1,392 @@
+package ldap_mock
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAP 操作的应用标签，见 RFC 4511 4.2
+const (
+	appBindRequest     = 0
+	appBindResponse    = 1
+	appUnbindRequest   = 2
+	appSearchRequest   = 3
+	appSearchEntry     = 4
+	appSearchDone      = 5
+	appModifyRequest   = 6
+	appModifyResponse  = 7
+	appAddRequest      = 8
+	appAddResponse     = 9
+	appDelRequest      = 10
+	appDelResponse     = 11
+	appModDNRequest    = 12
+	appModDNResponse   = 13
+	appCompareRequest  = 14
+	appCompareResponse = 15
+	appAbandonRequest  = 16
+	appExtendedRequest = 23
+	appExtendedResp    = 24
+)
+
+// 结果码，见 RFC 4511 附录 A
+const (
+	resultSuccess                = 0
+	resultProtocolError          = 2
+	resultSizeLimitExceeded      = 4
+	resultCompareFalse           = 5
+	resultCompareTrue            = 6
+	resultAuthMethodNotSupported = 7
+	resultNoSuchObject           = 32
+	resultInvalidCredentials     = 49
+	resultInsufficientAccess     = 50
+	resultUnwillingToPerform     = 53
+)
+
+// 支持的扩展操作和控件
+const (
+	oidWhoAmI       = "1.3.6.1.4.1.4203.1.11.3"
+	oidPagedResults = "1.2.840.113556.1.4.319"
+)
+
+// 写操作请求对应的响应标签，目录是只读的，写操作都返回 unwillingToPerform
+var writeResponses = map[ber.Tag]ber.Tag{
+	appModifyRequest: appModifyResponse,
+	appAddRequest:    appAddResponse,
+	appDelRequest:    appDelResponse,
+	appModDNRequest:  appModDNResponse,
+}
+
+// session 一个客户端连接，请求按顺序处理
+type session struct {
+	*server
+	conn    net.Conn
+	client  string
+	boundDN string // 为空表示匿名
+}
+
+func (s *session) serve() {
+	defer s.conn.Close()
+	reader := bufio.NewReader(s.conn)
+	for {
+		packet, err := ber.ReadPacket(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Debug("读取请求失败", "client", s.client, "err", err)
+			}
+			return
+		}
+		if len(packet.Children) < 2 {
+			logger.Debug("无效的请求", "client", s.client)
+			return
+		}
+		id := integer(packet.Children[0])
+		op := packet.Children[1]
+		var controls []*ber.Packet
+		if len(packet.Children) > 2 {
+			controls = packet.Children[2].Children
+		}
+
+		switch op.Tag {
+		case appBindRequest:
+			s.bind(id, op)
+		case appUnbindRequest:
+			return
+		case appSearchRequest:
+			s.search(id, op, controls)
+		case appCompareRequest:
+			s.compare(id, op)
+		case appExtendedRequest:
+			s.extended(id, op)
+		case appAbandonRequest:
+			// 请求都是同步处理的，没有可以取消的操作
+		default:
+			tag, ok := writeResponses[op.Tag]
+			if !ok {
+				logger.Debug("不支持的操作", "client", s.client, "tag", op.Tag)
+				return
+			}
+			s.send(id, result(tag, resultUnwillingToPerform, "", "the directory is read-only"))
+		}
+	}
+}
+
+func (s *session) send(id int64, op *ber.Packet, controls ...*ber.Packet) {
+	message := ber.NewSequence("")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	message.AppendChild(op)
+	if len(controls) > 0 {
+		list := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "")
+		for _, control := range controls {
+			list.AppendChild(control)
+		}
+		message.AppendChild(list)
+	}
+	s.conn.Write(message.Bytes())
+}
+
+func (s *session) bind(id int64, op *ber.Packet) {
+	if len(op.Children) < 3 {
+		s.send(id, result(appBindResponse, resultProtocolError, "", "invalid bind request"))
+		return
+	}
+	name, auth := text(op.Children[1]), op.Children[2]
+	if auth.Tag != 0 {
+		s.send(id, result(appBindResponse, resultAuthMethodNotSupported, "", "only simple bind is supported"))
+		return
+	}
+	password := text(auth)
+	switch {
+	case name == "" && password == "":
+		s.boundDN = ""
+		s.send(id, result(appBindResponse, resultSuccess, "", ""))
+		return
+	case password == "":
+		s.send(id, result(appBindResponse, resultUnwillingToPerform, "", "unauthenticated bind is not allowed"))
+		return
+	}
+	e := s.directory.bindEntry(name)
+	if e == nil || !e.any("userPassword", func(v string) bool { return v == password }) {
+		logger.Info("绑定失败", "client", s.client, "dn", name)
+		s.send(id, result(appBindResponse, resultInvalidCredentials, "", "invalid credentials"))
+		return
+	}
+	s.boundDN = e.dn
+	logger.Info("绑定成功", "client", s.client, "dn", e.dn)
+	s.send(id, result(appBindResponse, resultSuccess, "", ""))
+}
+
+// 查找绑定的条目，不是 DN 时按 AD 的习惯匹配 userPrincipalName、mail 或 uid
+func (d *directory) bindEntry(name string) *entry {
+	if strings.Contains(name, "=") {
+		return d.byKey[normalizeDN(name)]
+	}
+	for _, e := range d.entries {
+		for _, attr := range []string{"userPrincipalName", "mail", "uid"} {
+			if e.any(attr, func(v string) bool { return strings.EqualFold(v, name) }) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (s *session) search(id int64, op *ber.Packet, controls []*ber.Packet) {
+	if len(op.Children) < 8 {
+		s.send(id, result(appSearchDone, resultProtocolError, "", "invalid search request"))
+		return
+	}
+	base := normalizeDN(text(op.Children[0]))
+	scope := integer(op.Children[1])
+	sizeLimit := integer(op.Children[3])
+	typesOnly := integer(op.Children[5]) != 0
+	filter := op.Children[6]
+	var attributes []string
+	for _, a := range op.Children[7].Children {
+		attributes = append(attributes, text(a))
+	}
+
+	// 根 DSE 总是可以匿名查询，客户端用来发现 namingContexts
+	if base == "" && scope == scopeBase {
+		s.send(id, s.rootDSE().response(attributes, typesOnly))
+		s.send(id, result(appSearchDone, resultSuccess, "", ""))
+		return
+	}
+	if s.boundDN == "" && !s.config.AllowAnonymous {
+		s.send(id, result(appSearchDone, resultInsufficientAccess, "", "bind required"))
+		return
+	}
+	if base != "" && s.directory.byKey[base] == nil {
+		s.send(id, result(appSearchDone, resultNoSuchObject, s.directory.matchedDN(base), "no such object"))
+		return
+	}
+
+	var matches []*entry
+	for _, e := range s.directory.scope(base, scope) {
+		if e.match(filter) {
+			matches = append(matches, e)
+		}
+	}
+	logger.Info("查询", "client", s.client, "base", text(op.Children[0]), "scope", scope, "filter", filterString(filter), "results", len(matches))
+
+	// 分页控件的 cookie 是下一页的起始位置
+	var pageControl *ber.Packet
+	if size, cookie, ok := pagedResults(controls); ok {
+		start, _ := strconv.Atoi(cookie)
+		start = min(start, len(matches))
+		end := len(matches)
+		if size > 0 {
+			end = min(start+int(size), len(matches))
+		}
+		next := ""
+		if end < len(matches) {
+			next = strconv.Itoa(end)
+		}
+		matches = matches[start:end]
+		pageControl = pagedResultsControl(len(matches), next)
+	}
+
+	code, message := resultSuccess, ""
+	if sizeLimit > 0 && int64(len(matches)) > sizeLimit {
+		matches = matches[:sizeLimit]
+		code, message = resultSizeLimitExceeded, "size limit exceeded"
+	}
+	for _, e := range matches {
+		s.send(id, e.response(attributes, typesOnly))
+	}
+	if pageControl != nil {
+		s.send(id, result(appSearchDone, code, "", message), pageControl)
+		return
+	}
+	s.send(id, result(appSearchDone, code, "", message))
+}
+
+// 返回存在的最近上级 DN，用于 noSuchObject 的 matchedDN
+func (d *directory) matchedDN(key string) string {
+	for p := parentDN(key); p != ""; p = parentDN(p) {
+		if e := d.byKey[p]; e != nil {
+			return e.dn
+		}
+	}
+	return ""
+}
+
+func (s *session) rootDSE() *entry {
+	e := &entry{}
+	if s.directory.baseDN != "" {
+		e.add("namingContexts", s.directory.baseDN)
+	}
+	e.add("supportedLDAPVersion", "3")
+	e.add("supportedExtension", oidWhoAmI)
+	e.add("supportedControl", oidPagedResults)
+	e.add("vendorName", "mockgo")
+	return e
+}
+
+// 按请求的属性列表生成 SearchResultEntry。列表为空或包含 * 时返回所有属性，
+// userPassword 只在明确请求时返回，1.1 表示不返回属性
+func (e *entry) response(attributes []string, typesOnly bool) *ber.Packet {
+	all := len(attributes) == 0
+	requested := map[string]bool{}
+	for _, a := range attributes {
+		all = all || a == "*"
+		requested[strings.ToLower(a)] = true
+	}
+	list := ber.NewSequence("")
+	for _, a := range e.attributes {
+		name := strings.ToLower(a.name)
+		if !requested[name] && (!all || name == "userpassword") {
+			continue
+		}
+		attr := ber.NewSequence("")
+		attr.AppendChild(octetString(a.name))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		if !typesOnly {
+			for _, v := range a.values {
+				values.AppendChild(octetString(v))
+			}
+		}
+		attr.AppendChild(values)
+		list.AppendChild(attr)
+	}
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, appSearchEntry, nil, "")
+	p.AppendChild(octetString(e.dn))
+	p.AppendChild(list)
+	return p
+}
+
+func (s *session) compare(id int64, op *ber.Packet) {
+	if len(op.Children) < 2 || len(op.Children[1].Children) < 2 {
+		s.send(id, result(appCompareResponse, resultProtocolError, "", "invalid compare request"))
+		return
+	}
+	if s.boundDN == "" && !s.config.AllowAnonymous {
+		s.send(id, result(appCompareResponse, resultInsufficientAccess, "", "bind required"))
+		return
+	}
+	key := normalizeDN(text(op.Children[0]))
+	e := s.directory.byKey[key]
+	if e == nil {
+		s.send(id, result(appCompareResponse, resultNoSuchObject, s.directory.matchedDN(key), "no such object"))
+		return
+	}
+	want := text(op.Children[1].Children[1])
+	code := resultCompareFalse
+	if e.any(text(op.Children[1].Children[0]), func(v string) bool { return equalValue(v, want) }) {
+		code = resultCompareTrue
+	}
+	s.send(id, result(appCompareResponse, code, "", ""))
+}
+
+// 只支持 WhoAmI（RFC 4532），StartTLS 等其他扩展操作返回 protocolError
+func (s *session) extended(id int64, op *ber.Packet) {
+	var name string
+	if len(op.Children) > 0 {
+		name = text(op.Children[0])
+	}
+	if name != oidWhoAmI {
+		s.send(id, result(appExtendedResp, resultProtocolError, "", "unsupported extended operation "+name))
+		return
+	}
+	p := result(appExtendedResp, resultSuccess, "", "")
+	authzID := ""
+	if s.boundDN != "" {
+		authzID = "dn:" + s.boundDN
+	}
+	p.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 11, authzID, ""))
+	s.send(id, p)
+}
+
+// 解析分页控件（RFC 2696）中的页大小和 cookie
+func pagedResults(controls []*ber.Packet) (int64, string, bool) {
+	for _, control := range controls {
+		if len(control.Children) < 2 || text(control.Children[0]) != oidPagedResults {
+			continue
+		}
+		raw := control.Children[len(control.Children)-1]
+		value, err := ber.DecodePacketErr(raw.Data.Bytes())
+		if err != nil || len(value.Children) < 2 {
+			return 0, "", false
+		}
+		return integer(value.Children[0]), text(value.Children[1]), true
+	}
+	return 0, "", false
+}
+
+func pagedResultsControl(size int, cookie string) *ber.Packet {
+	value := ber.NewSequence("")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, size, ""))
+	value.AppendChild(octetString(cookie))
+	control := ber.NewSequence("")
+	control.AppendChild(octetString(oidPagedResults))
+	control.AppendChild(octetString(string(value.Bytes())))
+	return control
+}
+
+// LDAPResult，见 RFC 4511 4.1.9
+func result(tag ber.Tag, code int, matchedDN, message string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	p.AppendChild(octetString(matchedDN))
+	p.AppendChild(octetString(message))
+	return p
+}
+
+func octetString(s string) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, s, "")
+}
+
+// 原始内容作为字符串，适用于 OCTET STRING 和上下文标签的字符串
+func text(p *ber.Packet) string {
+	return string(p.Data.Bytes())
+}
+
+func integer(p *ber.Packet) int64 {
+	n, _ := ber.ParseInt64(p.Data.Bytes())
+	return n
+}
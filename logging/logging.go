@@ -0,0 +1,136 @@
+// Package logging 各工具共用的结构化日志，基于 log/slog，支持日志级别、JSON 输出和按模块设置级别
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options 日志配置
+type Options struct {
+	Level   slog.Level            // 默认级别
+	Modules map[string]slog.Level // 按模块覆盖默认级别
+	Format  string                // text 或 json
+	Output  io.Writer             // 为 nil 时输出到 stderr
+}
+
+// 当前生效的配置，Setup 之前创建的 logger 也会使用之后的配置
+type state struct {
+	handler slog.Handler
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	current.Store(&state{handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})})
+}
+
+// Setup 按配置替换全局日志输出，标准库 log 包的输出也转到这里，级别为 info
+func Setup(opts Options) error {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	// 级别由 moduleHandler 判断，这里放行所有级别
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	switch opts.Format {
+	case "", FormatText:
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		return fmt.Errorf("unsupported log format %q, use text or json", opts.Format)
+	}
+	current.Store(&state{handler: handler, level: opts.Level, modules: opts.Modules})
+	slog.SetDefault(slog.New(&moduleHandler{}))
+	return nil
+}
+
+// For 返回模块的 logger，日志带有 module 字段，级别可以通过 Options.Modules 单独设置
+func For(module string) *slog.Logger {
+	return slog.New(&moduleHandler{module: module})
+}
+
+// ParseLevel 解析日志级别，支持 debug、info、warn、error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// ParseModules 解析按模块设置的级别，格式为 module=level，多个用逗号分隔，例如 es=debug,scan=warn
+func ParseModules(s string) (map[string]slog.Level, error) {
+	modules := make(map[string]slog.Level)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid module level %q, use module=level", item)
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		modules[strings.TrimSpace(name)] = level
+	}
+	return modules, nil
+}
+
+// moduleHandler 每次输出时读取当前配置，WithAttrs、WithGroup 先记录下来，输出时再应用到当前的 handler 上
+type moduleHandler struct {
+	module string
+	wrap   []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	s := current.Load()
+	min := s.level
+	if l, ok := s.modules[h.module]; ok && h.module != "" {
+		min = l
+	}
+	return level >= min
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := current.Load().handler
+	if h.module != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String("module", h.module)})
+	}
+	for _, wrap := range h.wrap {
+		handler = wrap(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *moduleHandler) with(wrap func(slog.Handler) slog.Handler) slog.Handler {
+	wraps := make([]func(slog.Handler) slog.Handler, len(h.wrap), len(h.wrap)+1)
+	copy(wraps, h.wrap)
+	return &moduleHandler{module: h.module, wrap: append(wraps, wrap)}
+}
@@ -0,0 +1,12 @@
+package model
+
+// Resource 资源文档，db_benchmark 的测试数据和 scan_os 的扫描结果共用此结构写入数据库
+type Resource struct {
+	ResourceId   string                 `json:"resource_id" bson:"resource_id"`
+	ParentId     string                 `json:"parent_id" bson:"parent_id"`
+	Version      int                    `json:"version" bson:"version"`
+	Deleted      int                    `json:"deleted" bson:"deleted"`
+	Attributes   map[string]interface{} `json:"attributes" bson:"attributes"`
+	AttributeStr []byte                 `json:"-" bson:"-"`
+	ResourceStr  []byte                 `json:"-"`
+}
@@ -0,0 +1,202 @@
+package pgwire_mock
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/goccy/go-yaml"
+)
+
+// 没有匹配的查询时的处理方式
+const (
+	UnmatchedError = "error" // 返回错误，默认
+	UnmatchedEmpty = "empty" // 返回空结果
+)
+
+// Config 连接认证和按查询语句匹配的结果集
+type Config struct {
+	User      string  `yaml:"user"`     // 为空时接受任意用户
+	Password  string  `yaml:"password"` // 为空时不需要密码
+	Unmatched string  `yaml:"unmatched"`
+	Queries   []Query `yaml:"queries"`
+}
+
+// Query 一条查询的结果。match 是正则表达式，匹配时不区分大小写，语句中的连续空白按一个空格处理。
+// rows 中的值支持 @ 占位符，${1} 引用绑定参数 $1，${name} 引用 match 中的命名分组，
+// 设置 count 时用第一行作为模板生成 count 行，${i} 是从 1 开始的行号
+type Query struct {
+	Match   string        `yaml:"match"`
+	Columns []string      `yaml:"columns"` // 列名和类型，如 id:int4、name，类型默认 text
+	Params  []string      `yaml:"params"`  // 参数类型，客户端没有指定时在 ParameterDescription 中返回，默认 text
+	Rows    []interface{} `yaml:"rows"`    // 每行是按列顺序的数组或按列名的对象
+	Count   int           `yaml:"count"`
+	Tag     string        `yaml:"tag"` // CommandComplete 的命令标签，默认按语句类型和行数生成
+	Delay   time.Duration `yaml:"delay"`
+	Error   *QueryError   `yaml:"error"`
+
+	pattern *regexp.Regexp
+	columns []column
+	params  []uint32
+}
+
+// QueryError 返回给客户端的错误，code 是 SQLSTATE
+type QueryError struct {
+	Code    string `yaml:"code"`
+	Message string `yaml:"message"`
+}
+
+type column struct {
+	name string
+	oid  uint32
+}
+
+func loadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(content, config); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+	}
+	switch config.Unmatched {
+	case "":
+		config.Unmatched = UnmatchedError
+	case UnmatchedError, UnmatchedEmpty:
+	default:
+		return nil, fmt.Errorf("unknown unmatched %q", config.Unmatched)
+	}
+	for i := range config.Queries {
+		q := &config.Queries[i]
+		var err error
+		if q.pattern, err = regexp.Compile("(?is)" + q.Match); err != nil {
+			return nil, fmt.Errorf("query %d: %v", i, err)
+		}
+		for _, c := range q.Columns {
+			name, typeName, _ := strings.Cut(c, ":")
+			oid, err := lookupType(typeName)
+			if err != nil {
+				return nil, fmt.Errorf("query %d column %s: %v", i, name, err)
+			}
+			q.columns = append(q.columns, column{name: strings.TrimSpace(name), oid: oid})
+		}
+		for _, p := range q.Params {
+			oid, err := lookupType(p)
+			if err != nil {
+				return nil, fmt.Errorf("query %d params: %v", i, err)
+			}
+			q.params = append(q.params, oid)
+		}
+	}
+	return config, nil
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// 去掉首尾空白和结尾的分号，连续空白合并为一个空格
+func normalizeSQL(sql string) string {
+	return spaces.ReplaceAllString(strings.TrimRight(strings.TrimSpace(sql), "; \t\r\n"), " ")
+}
+
+// 返回第一个匹配的查询和命名分组
+func (c *Config) lookup(sql string) (*Query, map[string]string) {
+	for i := range c.Queries {
+		q := &c.Queries[i]
+		match := q.pattern.FindStringSubmatch(sql)
+		if match == nil {
+			continue
+		}
+		groups := map[string]string{}
+		for j, name := range q.pattern.SubexpNames() {
+			if name != "" {
+				groups[name] = match[j]
+			}
+		}
+		return q, groups
+	}
+	return nil, nil
+}
+
+// 生成结果集，每行按列顺序返回文本格式的值，nil 表示 NULL
+func (q *Query) rows(values *value.Handler, vars map[string]string) [][][]byte {
+	templates := q.Rows
+	if q.Count > 0 && len(templates) > 0 {
+		templates = make([]interface{}, q.Count)
+		for i := range templates {
+			templates[i] = q.Rows[0]
+		}
+	}
+	var result [][][]byte
+	for i, row := range templates {
+		vars["i"] = strconv.Itoa(i + 1)
+		row = values.ProcessDynamicValues(expandVars(row, vars))
+		fields := make([][]byte, len(q.columns))
+		for j, col := range q.columns {
+			var v interface{}
+			switch row := row.(type) {
+			case []interface{}:
+				if j < len(row) {
+					v = row[j]
+				}
+			case map[string]interface{}:
+				v = row[col.name]
+			}
+			fields[j] = textValue(v, col.oid)
+		}
+		result = append(result, fields)
+	}
+	return result
+}
+
+var varPattern = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// 替换 ${name}，递归处理数组和对象
+func expandVars(v interface{}, vars map[string]string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return varPattern.ReplaceAllStringFunc(v, func(s string) string {
+			if val, ok := vars[s[2:len(s)-1]]; ok {
+				return val
+			}
+			return s
+		})
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = expandVars(item, vars)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = expandVars(item, vars)
+		}
+		return result
+	}
+	return v
+}
+
+// CommandComplete 的命令标签，如 SELECT 3、INSERT 0 1、UPDATE 1
+func commandTag(sql string, rows int, hasColumns bool) string {
+	keyword, _, _ := strings.Cut(sql, " ")
+	keyword = strings.ToUpper(keyword)
+	if !hasColumns && (keyword == "INSERT" || keyword == "UPDATE" || keyword == "DELETE") {
+		rows = 1
+	}
+	switch keyword {
+	case "INSERT":
+		return "INSERT 0 " + strconv.Itoa(rows)
+	case "UPDATE", "DELETE", "FETCH", "MOVE", "COPY":
+		return keyword + " " + strconv.Itoa(rows)
+	case "SELECT", "WITH", "VALUES", "TABLE":
+		return "SELECT " + strconv.Itoa(rows)
+	}
+	return keyword
+}
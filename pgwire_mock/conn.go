@@ -0,0 +1,522 @@
+package pgwire_mock
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TreeWu/mock-go/value"
+	"github.com/jackc/pgproto3/v2"
+)
+
+// 启动时返回的服务端参数，客户端驱动会检查其中的编码和时间格式
+var parameterStatus = [][2]string{
+	{"server_version", "14.0 (mockgo)"},
+	{"server_encoding", "UTF8"},
+	{"client_encoding", "UTF8"},
+	{"DateStyle", "ISO, MDY"},
+	{"TimeZone", "UTC"},
+	{"integer_datetimes", "on"},
+	{"standard_conforming_strings", "on"},
+}
+
+// session 一个客户端连接，支持简单查询和扩展查询协议
+type session struct {
+	*server
+	conn     net.Conn
+	client   string
+	backend  *pgproto3.Backend
+	writer   *bufio.Writer
+	values   *value.Handler
+	database string
+	txStatus byte // I 空闲，T 事务中，E 事务出错
+
+	statements map[string]*statement
+	portals    map[string]*portal
+	failed     bool // 扩展查询出错后忽略消息直到 Sync
+}
+
+// statement 解析后的语句，只解析一次
+type statement struct {
+	sql    string
+	plan   *plan
+	params []uint32
+}
+
+type portal struct {
+	statement *statement
+	params    []string
+	formats   []int16
+}
+
+// plan 语句对应的结果：配置中的查询、内置语句或错误
+type plan struct {
+	columns []column
+	query   *Query
+	groups  map[string]string
+	rows    [][][]byte // 内置语句的结果
+	tag     string
+	empty   bool
+	err     *pgproto3.ErrorResponse
+}
+
+func (s *session) serve() {
+	defer s.conn.Close()
+	s.writer = bufio.NewWriter(s.conn)
+	s.backend = pgproto3.NewBackend(pgproto3.NewChunkReader(s.conn), s.writer)
+	if !s.startup() {
+		return
+	}
+	for {
+		msg, err := s.backend.Receive()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				logger.Debug("读取消息失败", "client", s.client, "err", err)
+			}
+			return
+		}
+		if _, ok := msg.(*pgproto3.Terminate); ok {
+			return
+		}
+		s.handle(msg)
+		if err := s.writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// 处理 SSL 协商、认证，返回 false 时关闭连接
+func (s *session) startup() bool {
+	for {
+		msg, err := s.backend.ReceiveStartupMessage()
+		if err != nil {
+			return false
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			// 不支持加密，客户端会改用明文连接
+			if _, err := s.conn.Write([]byte("N")); err != nil {
+				return false
+			}
+		case *pgproto3.StartupMessage:
+			return s.authenticate(msg.Parameters["user"], msg.Parameters["database"])
+		default:
+			// CancelRequest 等，查询都是同步执行的，直接关闭
+			return false
+		}
+	}
+}
+
+func (s *session) authenticate(user, database string) bool {
+	if s.config.User != "" && user != s.config.User {
+		s.sendError("28000", "role \""+user+"\" does not exist")
+		s.writer.Flush()
+		return false
+	}
+	if s.config.Password != "" {
+		s.backend.Send(&pgproto3.AuthenticationCleartextPassword{})
+		s.writer.Flush()
+		s.backend.SetAuthType(pgproto3.AuthTypeCleartextPassword)
+		msg, err := s.backend.Receive()
+		if err != nil {
+			return false
+		}
+		password, ok := msg.(*pgproto3.PasswordMessage)
+		if !ok || password.Password != s.config.Password {
+			logger.Info("认证失败", "client", s.client, "user", user)
+			s.sendError("28P01", "password authentication failed for user \""+user+"\"")
+			s.writer.Flush()
+			return false
+		}
+	}
+	s.database = database
+	s.backend.Send(&pgproto3.AuthenticationOk{})
+	for _, p := range parameterStatus {
+		s.backend.Send(&pgproto3.ParameterStatus{Name: p[0], Value: p[1]})
+	}
+	s.backend.Send(&pgproto3.BackendKeyData{ProcessID: rand.Uint32(), SecretKey: rand.Uint32()})
+	s.backend.Send(&pgproto3.ReadyForQuery{TxStatus: s.txStatus})
+	logger.Info("客户端连接", "client", s.client, "user", user, "database", database)
+	return s.writer.Flush() == nil
+}
+
+func (s *session) handle(msg pgproto3.FrontendMessage) {
+	if msg, ok := msg.(*pgproto3.Query); ok {
+		s.simpleQuery(msg.String)
+		return
+	}
+	if _, ok := msg.(*pgproto3.Sync); ok {
+		s.failed = false
+		s.backend.Send(&pgproto3.ReadyForQuery{TxStatus: s.txStatus})
+		return
+	}
+	if s.failed {
+		return
+	}
+	switch msg := msg.(type) {
+	case *pgproto3.Parse:
+		s.parse(msg)
+	case *pgproto3.Bind:
+		s.bind(msg)
+	case *pgproto3.Describe:
+		s.describe(msg)
+	case *pgproto3.Execute:
+		s.execute(msg)
+	case *pgproto3.Close:
+		if msg.ObjectType == 'S' {
+			delete(s.statements, msg.Name)
+		} else {
+			delete(s.portals, msg.Name)
+		}
+		s.backend.Send(&pgproto3.CloseComplete{})
+	case *pgproto3.Flush:
+	default:
+		s.extendedError("0A000", "unsupported message")
+	}
+}
+
+// 简单查询协议，一次可以包含多条用分号分隔的语句，出错后不再执行后面的语句
+func (s *session) simpleQuery(text string) {
+	defer s.backend.Send(&pgproto3.ReadyForQuery{TxStatus: s.txStatus})
+	statements := splitStatements(text)
+	if len(statements) == 0 {
+		s.backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+	for _, sql := range statements {
+		p := s.plan(sql)
+		if p.empty {
+			s.backend.Send(&pgproto3.EmptyQueryResponse{})
+			continue
+		}
+		rows, tag, err := s.run(p, sql, nil)
+		if err != nil {
+			s.sendError(err.Code, err.Message)
+			return
+		}
+		if len(p.columns) > 0 {
+			s.backend.Send(rowDescription(p.columns, nil))
+		}
+		for _, row := range rows {
+			s.backend.Send(&pgproto3.DataRow{Values: row})
+		}
+		s.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+	}
+}
+
+func (s *session) parse(msg *pgproto3.Parse) {
+	sql := normalizeSQL(msg.Query)
+	p := s.plan(sql)
+	// 没有匹配的语句在解析时报错，和真实数据库中表不存在一样
+	if p.err != nil && p.query == nil {
+		s.extendedError(p.err.Code, p.err.Message)
+		return
+	}
+	params := append([]uint32(nil), msg.ParameterOIDs...)
+	for i := len(params); i < paramCount(sql); i++ {
+		params = append(params, 0)
+	}
+	for i, oid := range params {
+		if oid != 0 {
+			continue
+		}
+		params[i] = oidText
+		if p.query != nil && i < len(p.query.params) {
+			params[i] = p.query.params[i]
+		}
+	}
+	s.statements[msg.Name] = &statement{sql: sql, plan: p, params: params}
+	s.backend.Send(&pgproto3.ParseComplete{})
+}
+
+func (s *session) bind(msg *pgproto3.Bind) {
+	st, ok := s.statements[msg.PreparedStatement]
+	if !ok {
+		s.extendedError("26000", "prepared statement \""+msg.PreparedStatement+"\" does not exist")
+		return
+	}
+	params := make([]string, len(msg.Parameters))
+	for i, data := range msg.Parameters {
+		var format int16
+		switch {
+		case len(msg.ParameterFormatCodes) == 1:
+			format = msg.ParameterFormatCodes[0]
+		case i < len(msg.ParameterFormatCodes):
+			format = msg.ParameterFormatCodes[i]
+		}
+		var oid uint32 = oidText
+		if i < len(st.params) {
+			oid = st.params[i]
+		}
+		params[i] = paramText(data, format, oid)
+	}
+	s.portals[msg.DestinationPortal] = &portal{statement: st, params: params, formats: msg.ResultFormatCodes}
+	s.backend.Send(&pgproto3.BindComplete{})
+}
+
+func (s *session) describe(msg *pgproto3.Describe) {
+	var columns []column
+	var formats []int16
+	if msg.ObjectType == 'S' {
+		st, ok := s.statements[msg.Name]
+		if !ok {
+			s.extendedError("26000", "prepared statement \""+msg.Name+"\" does not exist")
+			return
+		}
+		s.backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: st.params})
+		columns = st.plan.columns
+	} else {
+		p, ok := s.portals[msg.Name]
+		if !ok {
+			s.extendedError("34000", "portal \""+msg.Name+"\" does not exist")
+			return
+		}
+		columns, formats = p.statement.plan.columns, p.formats
+	}
+	if len(columns) == 0 {
+		s.backend.Send(&pgproto3.NoData{})
+		return
+	}
+	s.backend.Send(rowDescription(columns, formats))
+}
+
+func (s *session) execute(msg *pgproto3.Execute) {
+	p, ok := s.portals[msg.Portal]
+	if !ok {
+		s.extendedError("34000", "portal \""+msg.Portal+"\" does not exist")
+		return
+	}
+	st := p.statement
+	if st.plan.empty {
+		s.backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+	rows, tag, err := s.run(st.plan, st.sql, p.params)
+	if err != nil {
+		s.extendedError(err.Code, err.Message)
+		return
+	}
+	for _, row := range rows {
+		values := make([][]byte, len(row))
+		for i, field := range row {
+			data, err := encodeField(field, st.plan.columns[i].oid, resultFormat(p.formats, i))
+			if err != nil {
+				s.extendedError("22P02", "column "+st.plan.columns[i].name+": "+err.Error())
+				return
+			}
+			values[i] = data
+		}
+		s.backend.Send(&pgproto3.DataRow{Values: values})
+	}
+	s.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+// 按配置、内置语句的顺序查找语句的结果
+func (s *session) plan(sql string) *plan {
+	if sql == "" {
+		return &plan{empty: true}
+	}
+	if q, groups := s.config.lookup(sql); q != nil {
+		return &plan{columns: q.columns, query: q, groups: groups}
+	}
+	if p := s.builtin(sql); p != nil {
+		return p
+	}
+	if s.config.Unmatched == UnmatchedEmpty {
+		return &plan{tag: commandTag(sql, 0, false)}
+	}
+	return &plan{err: &pgproto3.ErrorResponse{Code: "42P01", Message: "mockgo: no query matches " + strconv.Quote(sql)}}
+}
+
+// 驱动和连接池常用的语句，没有配置时也能执行
+func (s *session) builtin(sql string) *plan {
+	lower := strings.ToLower(sql)
+	keyword, rest, _ := strings.Cut(lower, " ")
+	switch keyword {
+	case "begin", "start", "commit", "end", "rollback", "abort":
+		return &plan{tag: transactionTag(keyword)}
+	case "set", "reset", "listen", "unlisten", "deallocate", "savepoint", "release":
+		return &plan{tag: strings.ToUpper(keyword)}
+	case "discard":
+		return &plan{tag: "DISCARD " + strings.ToUpper(rest)}
+	case "show":
+		name := strings.TrimSpace(rest)
+		val := ""
+		for _, p := range parameterStatus {
+			if strings.EqualFold(p[0], name) {
+				val = p[1]
+			}
+		}
+		return &plan{columns: []column{{name: name, oid: oidText}}, rows: [][][]byte{{[]byte(val)}}, tag: "SHOW"}
+	}
+	switch lower {
+	case "select 1":
+		return &plan{columns: []column{{name: "?column?", oid: 23}}, rows: [][][]byte{{[]byte("1")}}, tag: "SELECT 1"}
+	case "select version()":
+		return &plan{columns: []column{{name: "version", oid: oidText}}, rows: [][][]byte{{[]byte("PostgreSQL 14.0 (mockgo)")}}, tag: "SELECT 1"}
+	case "select current_database()":
+		return &plan{columns: []column{{name: "current_database", oid: oidText}}, rows: [][][]byte{{[]byte(s.database)}}, tag: "SELECT 1"}
+	}
+	return nil
+}
+
+func transactionTag(keyword string) string {
+	switch keyword {
+	case "begin", "start":
+		return "BEGIN"
+	case "commit", "end":
+		return "COMMIT"
+	}
+	return "ROLLBACK"
+}
+
+// 执行语句，返回文本格式的行和命令标签，同时维护事务状态
+func (s *session) run(p *plan, sql string, params []string) ([][][]byte, string, *pgproto3.ErrorResponse) {
+	if p.query != nil && p.query.Delay > 0 {
+		time.Sleep(p.query.Delay)
+	}
+	err := p.err
+	if err == nil && p.query != nil && p.query.Error != nil {
+		err = &pgproto3.ErrorResponse{Code: p.query.Error.Code, Message: p.query.Error.Message}
+	}
+	if err != nil {
+		logger.Warn("查询失败", "client", s.client, "sql", sql, "code", err.Code, "err", err.Message)
+		if s.txStatus != 'I' {
+			s.txStatus = 'E'
+		}
+		code := err.Code
+		if code == "" {
+			code = "XX000"
+		}
+		return nil, "", &pgproto3.ErrorResponse{Severity: "ERROR", Code: code, Message: err.Message}
+	}
+
+	switch p.tag {
+	case "BEGIN":
+		s.txStatus = 'T'
+	case "COMMIT", "ROLLBACK":
+		s.txStatus = 'I'
+	}
+	if p.query == nil {
+		logger.Debug("执行内置语句", "client", s.client, "sql", sql)
+		return p.rows, p.tag, nil
+	}
+
+	vars := map[string]string{}
+	for name, val := range p.groups {
+		vars[name] = val
+	}
+	for i, val := range params {
+		vars[strconv.Itoa(i+1)] = val
+	}
+	rows := p.query.rows(s.values, vars)
+	tag := p.query.Tag
+	if tag == "" {
+		tag = commandTag(sql, len(rows), len(p.columns) > 0)
+	}
+	logger.Info("查询", "client", s.client, "sql", sql, "params", params, "rows", len(rows))
+	return rows, tag, nil
+}
+
+func (s *session) sendError(code, message string) {
+	s.backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: code, Message: message})
+}
+
+// 扩展查询出错后客户端会发送 Sync，在此之前的消息都忽略
+func (s *session) extendedError(code, message string) {
+	s.sendError(code, message)
+	s.failed = true
+}
+
+func rowDescription(columns []column, formats []int16) *pgproto3.RowDescription {
+	fields := make([]pgproto3.FieldDescription, len(columns))
+	for i, c := range columns {
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(c.name),
+			DataTypeOID:  c.oid,
+			DataTypeSize: typeSize(c.oid),
+			TypeModifier: -1,
+			Format:       resultFormat(formats, i),
+		}
+	}
+	return &pgproto3.RowDescription{Fields: fields}
+}
+
+// Bind 中的结果格式：没有表示都是文本，一个表示所有列相同，否则按列指定
+func resultFormat(formats []int16, i int) int16 {
+	switch {
+	case len(formats) == 1:
+		return formats[0]
+	case i < len(formats):
+		return formats[i]
+	}
+	return 0
+}
+
+func encodeField(text []byte, oid uint32, format int16) ([]byte, error) {
+	if text == nil {
+		return nil, nil
+	}
+	if format == 1 {
+		return binaryValue(text, oid)
+	}
+	if oid == 16 {
+		b, err := strconv.ParseBool(string(text))
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return []byte("t"), nil
+		}
+		return []byte("f"), nil
+	}
+	return text, nil
+}
+
+var paramPattern = regexp.MustCompile(`\$(\d+)`)
+
+// 语句中最大的 $n
+func paramCount(sql string) int {
+	n := 0
+	for _, m := range paramPattern.FindAllStringSubmatch(sql, -1) {
+		if i, _ := strconv.Atoi(m[1]); i > n {
+			n = i
+		}
+	}
+	return n
+}
+
+// 按引号外的分号拆分语句，去掉空语句
+func splitStatements(text string) []string {
+	var statements []string
+	var quote byte
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) {
+			c := text[i]
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '\'' || c == '"':
+				quote = c
+				continue
+			case c != ';':
+				continue
+			}
+		}
+		if sql := normalizeSQL(text[start:i]); sql != "" {
+			statements = append(statements, sql)
+		}
+		start = i + 1
+	}
+	return statements
+}
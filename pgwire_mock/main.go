@@ -0,0 +1,96 @@
+// Package pgwire_mock 实验性的 PostgreSQL 协议 mock：接受客户端连接，按配置的正则表达式匹配查询语句并返回模板生成的结果集，
+// 让应用在部分测试场景下不依赖真实数据库运行。只实现了简单查询和扩展查询协议中常用的部分，不支持 COPY、SSL 和取消查询。
+package pgwire_mock
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/value"
+)
+
+var logger = logging.For("pgwire")
+
+// server 所有连接共用的配置
+type server struct {
+	config *Config
+}
+
+// Run 启动 PostgreSQL 协议 mock，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("pgwire", flag.ContinueOnError)
+	configFile := fs.String("config", "", "yaml/json file with credentials and query results, only built-in statements when empty")
+	port := fs.String("port", envOr("PGWIRE_PORT", ":5432"), "listen address (env PGWIRE_PORT)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		logger.Error("读取配置失败", "err", err)
+		return 1
+	}
+	listener, err := net.Listen("tcp", listenAddr(*port))
+	if err != nil {
+		logger.Error("启动 PostgreSQL Mock 失败", "err", err)
+		return 1
+	}
+	defer listener.Close()
+	logger.Info("PostgreSQL Mock 服务器启动", "addr", listener.Addr().String(), "queries", len(config.Queries))
+
+	s := &server{config: config}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.serve(listener) }()
+	select {
+	case err := <-errCh:
+		logger.Error("服务异常退出", "err", err)
+		return 1
+	case <-ctx.Done():
+		logger.Info("正在停止 Mock 服务器")
+		return 0
+	}
+}
+
+func (s *server) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go (&session{
+			server:     s,
+			conn:       conn,
+			client:     conn.RemoteAddr().String(),
+			values:     value.NewValueHandler(),
+			txStatus:   'I',
+			statements: map[string]*statement{},
+			portals:    map[string]*portal{},
+		}).serve()
+	}
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,210 @@
+package pgwire_mock
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支持的列类型和 OID，见 pg_type.dat
+var typeOIDs = map[string]uint32{
+	"bool":        16,
+	"bytea":       17,
+	"int8":        20,
+	"int2":        21,
+	"int4":        23,
+	"text":        25,
+	"json":        114,
+	"float4":      700,
+	"float8":      701,
+	"varchar":     1043,
+	"date":        1082,
+	"timestamp":   1114,
+	"timestamptz": 1184,
+	"uuid":        2950,
+	"jsonb":       3802,
+}
+
+// 常用的类型别名
+var typeAliases = map[string]string{
+	"boolean":          "bool",
+	"bigint":           "int8",
+	"smallint":         "int2",
+	"int":              "int4",
+	"integer":          "int4",
+	"real":             "float4",
+	"double precision": "float8",
+	"double":           "float8",
+	"string":           "text",
+}
+
+const oidText = 25
+
+// PostgreSQL 的时间从 2000-01-01 开始计算
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func lookupType(name string) (uint32, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := typeAliases[name]; ok {
+		name = alias
+	}
+	if name == "" {
+		return oidText, nil
+	}
+	oid, ok := typeOIDs[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported type %q", name)
+	}
+	return oid, nil
+}
+
+// 类型的固定长度，变长类型为 -1
+func typeSize(oid uint32) int16 {
+	switch oid {
+	case 16:
+		return 1
+	case 21:
+		return 2
+	case 23, 700, 1082:
+		return 4
+	case 20, 701, 1114, 1184:
+		return 8
+	case 2950:
+		return 16
+	}
+	return -1
+}
+
+// 把配置或模板中的值转换为文本格式，nil 表示 NULL
+func textValue(v interface{}, oid uint32) []byte {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	case bool:
+		if oid == 16 {
+			if v {
+				return []byte("t")
+			}
+			return []byte("f")
+		}
+		return []byte(strconv.FormatBool(v))
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return data
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// 按类型把文本格式的值编码为二进制格式
+func binaryValue(text []byte, oid uint32) ([]byte, error) {
+	s := string(text)
+	switch oid {
+	case 16:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case 21, 23, 20:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, typeSize(oid))
+		switch oid {
+		case 21:
+			binary.BigEndian.PutUint16(buf, uint16(n))
+		case 23:
+			binary.BigEndian.PutUint32(buf, uint32(n))
+		default:
+			binary.BigEndian.PutUint64(buf, uint64(n))
+		}
+		return buf, nil
+	case 700, 701:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		if oid == 700 {
+			return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+		}
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), nil
+	case 2950:
+		b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+		if err != nil || len(b) != 16 {
+			return nil, fmt.Errorf("invalid uuid %q", s)
+		}
+		return b, nil
+	case 1082:
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(t.Sub(pgEpoch).Hours()/24))), nil
+	case 1114, 1184:
+		t, err := parseTime(s)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(nil, uint64(t.Sub(pgEpoch).Microseconds())), nil
+	case 3802:
+		// jsonb 的二进制格式是版本号 1 加上 JSON 文本
+		return append([]byte{1}, text...), nil
+	}
+	return text, nil
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// 把 Bind 中的参数转换为文本，用于 ${1} 引用和日志。二进制参数按类型解码，未知类型按原始字节处理
+func paramText(data []byte, format int16, oid uint32) string {
+	if data == nil {
+		return ""
+	}
+	if format == 0 {
+		return string(data)
+	}
+	switch {
+	case oid == 16 && len(data) == 1:
+		return strconv.FormatBool(data[0] != 0)
+	case oid == 21 && len(data) == 2:
+		return strconv.Itoa(int(int16(binary.BigEndian.Uint16(data))))
+	case oid == 23 && len(data) == 4:
+		return strconv.Itoa(int(int32(binary.BigEndian.Uint32(data))))
+	case oid == 20 && len(data) == 8:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(data)), 10)
+	case oid == 700 && len(data) == 4:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(data))), 'g', -1, 32)
+	case oid == 701 && len(data) == 8:
+		return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(data)), 'g', -1, 64)
+	case oid == 2950 && len(data) == 16:
+		h := hex.EncodeToString(data)
+		return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+	case (oid == 1114 || oid == 1184) && len(data) == 8:
+		return pgEpoch.Add(time.Duration(int64(binary.BigEndian.Uint64(data))) * time.Microsecond).Format(time.RFC3339Nano)
+	case oid == 1082 && len(data) == 4:
+		return pgEpoch.AddDate(0, 0, int(int32(binary.BigEndian.Uint32(data)))).Format("2006-01-02")
+	case oid == 3802 && len(data) > 0:
+		return string(data[1:])
+	}
+	return string(data)
+}
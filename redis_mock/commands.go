@@ -0,0 +1,835 @@
+package redis_mock
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内置命令的回复
+const (
+	errWrongType  = errReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger = errReply("ERR value is not an integer or out of range")
+	errSyntax     = errReply("ERR syntax error")
+	errNoAuth     = errReply("NOAUTH Authentication required.")
+	statusOK      = status("OK")
+)
+
+// 和 Redis 默认配置一样有 16 个数据库
+const databases = 16
+
+// store 内存中的数据，过期的键在访问时删除
+type store struct {
+	mu  sync.Mutex
+	dbs [databases]map[string]*item
+}
+
+// item 的 value 是 string、map[string]string 或 []string
+type item struct {
+	value  interface{}
+	expire time.Time
+}
+
+func newStore() *store {
+	s := &store{}
+	for i := range s.dbs {
+		s.dbs[i] = map[string]*item{}
+	}
+	return s
+}
+
+func (s *store) lookup(db int, key string) *item {
+	it := s.dbs[db][key]
+	if it != nil && !it.expire.IsZero() && time.Now().After(it.expire) {
+		delete(s.dbs[db], key)
+		return nil
+	}
+	return it
+}
+
+// 命令的最少参数个数（含命令名），为负数时表示参数个数固定为 -arity
+type command struct {
+	arity int
+	fn    func(c *client, args []string) interface{}
+}
+
+// 连接相关的命令总是启用，不受配置中 commands 的限制
+var connectionCommands = map[string]bool{
+	"auth": true, "hello": true, "ping": true, "quit": true, "select": true, "client": true, "command": true, "echo": true,
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":      {1, cmdPing},
+		"echo":      {-2, func(c *client, args []string) interface{} { return args[1] }},
+		"select":    {-2, cmdSelect},
+		"auth":      {2, cmdAuth},
+		"hello":     {1, cmdHello},
+		"client":    {2, cmdClient},
+		"command":   {1, cmdCommand},
+		"info":      {1, cmdInfo},
+		"readonly":  {-1, func(c *client, args []string) interface{} { return statusOK }},
+		"readwrite": {-1, func(c *client, args []string) interface{} { return statusOK }},
+		"asking":    {-1, func(c *client, args []string) interface{} { return statusOK }},
+		"cluster":   {2, cmdCluster},
+		"dbsize":    {-1, func(c *client, args []string) interface{} { return len(c.store.dbs[c.db]) }},
+		"flushdb":   {1, func(c *client, args []string) interface{} { c.store.dbs[c.db] = map[string]*item{}; return statusOK }},
+		"flushall": {1, func(c *client, args []string) interface{} {
+			for i := range c.store.dbs {
+				c.store.dbs[i] = map[string]*item{}
+			}
+			return statusOK
+		}},
+
+		"del":     {2, cmdDel},
+		"unlink":  {2, cmdDel},
+		"exists":  {2, cmdExists},
+		"expire":  {3, cmdExpire},
+		"pexpire": {3, cmdExpire},
+		"persist": {-2, cmdPersist},
+		"ttl":     {-2, cmdTTL},
+		"pttl":    {-2, cmdTTL},
+		"keys":    {-2, cmdKeys},
+		"type":    {-2, cmdType},
+
+		"get":    {-2, cmdGet},
+		"set":    {3, cmdSet},
+		"setex":  {-4, cmdSetEx},
+		"setnx":  {-3, cmdSetNX},
+		"mget":   {2, cmdMGet},
+		"mset":   {3, cmdMSet},
+		"incr":   {-2, cmdIncr},
+		"decr":   {-2, cmdIncr},
+		"incrby": {-3, cmdIncr},
+		"decrby": {-3, cmdIncr},
+		"append": {-3, cmdAppend},
+		"strlen": {-2, cmdStrlen},
+
+		"hset":    {4, cmdHSet},
+		"hmset":   {4, cmdHSet},
+		"hget":    {-3, cmdHGet},
+		"hmget":   {3, cmdHMGet},
+		"hgetall": {-2, cmdHGetAll},
+		"hdel":    {3, cmdHDel},
+		"hexists": {-3, cmdHExists},
+		"hlen":    {-2, cmdHLen},
+		"hkeys":   {-2, cmdHKeys},
+		"hvals":   {-2, cmdHKeys},
+		"hincrby": {-4, cmdHIncrBy},
+
+		"lpush":  {3, cmdPush},
+		"rpush":  {3, cmdPush},
+		"lpop":   {2, cmdPop},
+		"rpop":   {2, cmdPop},
+		"lrange": {-4, cmdLRange},
+		"llen":   {-2, cmdLLen},
+	}
+}
+
+func cmdPing(c *client, args []string) interface{} {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return status("PONG")
+}
+
+// 多个键的命令的键位置：第一个、最后一个（-1 表示到结尾）和步长，其余命令的第一个参数是键
+var keyPositions = map[string][3]int{
+	"del": {1, -1, 1}, "unlink": {1, -1, 1}, "exists": {1, -1, 1}, "mget": {1, -1, 1}, "mset": {1, -1, 2},
+}
+
+// 只读命令，集群客户端会把它们发到副本
+var readonlyCommands = map[string]bool{
+	"get": true, "mget": true, "strlen": true, "exists": true, "ttl": true, "pttl": true, "type": true, "keys": true,
+	"hget": true, "hmget": true, "hgetall": true, "hexists": true, "hlen": true, "hkeys": true, "hvals": true, "lrange": true, "llen": true,
+}
+
+// COMMAND 按 Redis 5 的格式返回启用的内置命令，集群客户端用其中的键位置计算槽位
+func cmdCommand(c *client, args []string) interface{} {
+	var names []string
+	for name := range commands {
+		if c.enabled(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(args) > 1 {
+		if strings.EqualFold(args[1], "count") {
+			return len(names)
+		}
+		return []interface{}{}
+	}
+	infos := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		flags := []interface{}{status("write")}
+		if readonlyCommands[name] {
+			flags = []interface{}{status("readonly")}
+		}
+		keys, ok := keyPositions[name]
+		switch {
+		case connectionCommands[name] || name == "info" || name == "cluster" || strings.HasPrefix(name, "flush") || name == "dbsize" ||
+			name == "readonly" || name == "readwrite" || name == "asking":
+			keys = [3]int{0, 0, 0}
+		case !ok:
+			keys = [3]int{1, 1, 1}
+		}
+		// Redis 的 arity 正数表示参数个数固定，负数表示最少个数，和 command.arity 相反
+		infos = append(infos, []interface{}{name, -commands[name].arity, flags, keys[0], keys[1], keys[2]})
+	}
+	return infos
+}
+
+func cmdSelect(c *client, args []string) interface{} {
+	db, err := strconv.Atoi(args[1])
+	if err != nil || db < 0 || db >= databases {
+		return errReply("ERR DB index is out of range")
+	}
+	c.db = db
+	return statusOK
+}
+
+// AUTH password 或 AUTH username password，用户名不检查
+func cmdAuth(c *client, args []string) interface{} {
+	if c.config.Password == "" {
+		return errReply("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+	if args[len(args)-1] != c.config.Password {
+		return errReply("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	c.authed = true
+	return statusOK
+}
+
+// 只支持 RESP2，客户端请求 RESP3 时返回 NOPROTO，go-redis 等客户端会回退到 RESP2
+func cmdHello(c *client, args []string) interface{} {
+	if len(args) > 1 && args[1] != "2" {
+		return errReply("NOPROTO unsupported protocol version")
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "auth":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			if reply := cmdAuth(c, args[i:i+3]); reply != statusOK {
+				return reply
+			}
+			i += 2
+		case "setname":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			c.name = args[i+1]
+			i++
+		}
+	}
+	if c.config.Password != "" && !c.authed {
+		return errNoAuth
+	}
+	return map[string]interface{}{
+		"server": "redis", "version": "7.0.0", "proto": 2, "id": c.id, "mode": c.mode(), "role": "master", "modules": []interface{}{},
+	}
+}
+
+func cmdClient(c *client, args []string) interface{} {
+	switch strings.ToLower(args[1]) {
+	case "setname":
+		if len(args) != 3 {
+			return errSyntax
+		}
+		c.name = args[2]
+	case "getname":
+		if c.name == "" {
+			return nilReply{}
+		}
+		return c.name
+	case "id":
+		return c.id
+	}
+	return statusOK
+}
+
+func cmdInfo(c *client, args []string) interface{} {
+	enabled := 0
+	if c.config.Cluster {
+		enabled = 1
+	}
+	keys := ""
+	for i, db := range c.store.dbs {
+		if len(db) > 0 {
+			keys += fmt.Sprintf("db%d:keys=%d,expires=0,avg_ttl=0\r\n", i, len(db))
+		}
+	}
+	return fmt.Sprintf("# Server\r\nredis_version:7.0.0\r\nredis_mode:%s\r\n\r\n# Persistence\r\nloading:0\r\n\r\n# Replication\r\nrole:master\r\n\r\n# Cluster\r\ncluster_enabled:%d\r\n\r\n# Keyspace\r\n%s",
+		c.mode(), enabled, keys)
+}
+
+// 单节点集群，所有槽位都在本节点
+func cmdCluster(c *client, args []string) interface{} {
+	if !c.config.Cluster {
+		return errReply("ERR This instance has cluster support disabled")
+	}
+	host, portText, _ := strings.Cut(c.announce(), ":")
+	port, _ := strconv.Atoi(portText)
+	id := fmt.Sprintf("%040x", 1)
+	switch strings.ToLower(args[1]) {
+	case "slots":
+		return []interface{}{[]interface{}{0, 16383, []interface{}{host, port, id}}}
+	case "shards":
+		node := map[string]interface{}{"id": id, "endpoint": host, "ip": host, "port": port, "role": "master", "replication-offset": 0, "health": "online"}
+		return []interface{}{map[string]interface{}{"slots": []interface{}{0, 16383}, "nodes": []interface{}{node}}}
+	case "nodes":
+		return fmt.Sprintf("%s %s:%d@%d myself,master - 0 0 1 connected 0-16383\n", id, host, port, port+10000)
+	case "info":
+		return "cluster_enabled:1\r\ncluster_state:ok\r\ncluster_slots_assigned:16384\r\ncluster_slots_ok:16384\r\ncluster_known_nodes:1\r\ncluster_size:1\r\n"
+	case "myid":
+		return id
+	case "keyslot":
+		if len(args) < 3 {
+			return errSyntax
+		}
+		return int(keySlot(args[2]))
+	}
+	return errReply("ERR unknown subcommand '" + args[1] + "'")
+}
+
+func cmdDel(c *client, args []string) interface{} {
+	n := 0
+	for _, key := range args[1:] {
+		if c.store.lookup(c.db, key) != nil {
+			delete(c.store.dbs[c.db], key)
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(c *client, args []string) interface{} {
+	n := 0
+	for _, key := range args[1:] {
+		if c.store.lookup(c.db, key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExpire(c *client, args []string) interface{} {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	it := c.store.lookup(c.db, args[1])
+	if it == nil {
+		return 0
+	}
+	unit := time.Second
+	if strings.EqualFold(args[0], "pexpire") {
+		unit = time.Millisecond
+	}
+	it.expire = time.Now().Add(time.Duration(n) * unit)
+	return 1
+}
+
+func cmdPersist(c *client, args []string) interface{} {
+	it := c.store.lookup(c.db, args[1])
+	if it == nil || it.expire.IsZero() {
+		return 0
+	}
+	it.expire = time.Time{}
+	return 1
+}
+
+func cmdTTL(c *client, args []string) interface{} {
+	it := c.store.lookup(c.db, args[1])
+	switch {
+	case it == nil:
+		return -2
+	case it.expire.IsZero():
+		return -1
+	}
+	left := time.Until(it.expire)
+	if strings.EqualFold(args[0], "pttl") {
+		return left.Milliseconds()
+	}
+	return int64((left + time.Second - 1) / time.Second)
+}
+
+func cmdKeys(c *client, args []string) interface{} {
+	keys := []string{}
+	for key := range c.store.dbs[c.db] {
+		if ok, _ := path.Match(args[1], key); ok && c.store.lookup(c.db, key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func cmdType(c *client, args []string) interface{} {
+	switch c.store.lookup(c.db, args[1]).valueOrNil().(type) {
+	case string:
+		return status("string")
+	case map[string]string:
+		return status("hash")
+	case []string:
+		return status("list")
+	}
+	return status("none")
+}
+
+func (it *item) valueOrNil() interface{} {
+	if it == nil {
+		return nil
+	}
+	return it.value
+}
+
+// 返回字符串类型的值，键不存在时 ok 为 false，类型不对时返回 WRONGTYPE
+func (c *client) getString(key string) (string, bool, interface{}) {
+	it := c.store.lookup(c.db, key)
+	if it == nil {
+		return "", false, nil
+	}
+	v, ok := it.value.(string)
+	if !ok {
+		return "", false, errWrongType
+	}
+	return v, true, nil
+}
+
+func cmdGet(c *client, args []string) interface{} {
+	v, ok, err := c.getString(args[1])
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return nilReply{}
+	}
+	return v
+}
+
+// SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|KEEPTTL]
+func cmdSet(c *client, args []string) interface{} {
+	var nx, xx, get, keepTTL bool
+	var expire time.Time
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return errReply("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			expire = time.Now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	old := c.store.lookup(c.db, args[1])
+	var reply interface{} = statusOK
+	if get {
+		reply = nilReply{}
+		if old != nil {
+			v, ok := old.value.(string)
+			if !ok {
+				return errWrongType
+			}
+			reply = v
+		}
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		if get {
+			return reply
+		}
+		return nilReply{}
+	}
+	if keepTTL && old != nil {
+		expire = old.expire
+	}
+	c.store.dbs[c.db][args[1]] = &item{value: args[2], expire: expire}
+	return reply
+}
+
+func cmdSetEx(c *client, args []string) interface{} {
+	return cmdSet(c, []string{"set", args[1], args[3], "EX", args[2]})
+}
+
+func cmdSetNX(c *client, args []string) interface{} {
+	if c.store.lookup(c.db, args[1]) != nil {
+		return 0
+	}
+	c.store.dbs[c.db][args[1]] = &item{value: args[2]}
+	return 1
+}
+
+func cmdMGet(c *client, args []string) interface{} {
+	values := make([]interface{}, 0, len(args)-1)
+	for _, key := range args[1:] {
+		v, ok, err := c.getString(key)
+		if !ok || err != nil {
+			values = append(values, nilReply{})
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func cmdMSet(c *client, args []string) interface{} {
+	if len(args)%2 != 1 {
+		return errReply("ERR wrong number of arguments for 'mset' command")
+	}
+	for i := 1; i < len(args); i += 2 {
+		c.store.dbs[c.db][args[i]] = &item{value: args[i+1]}
+	}
+	return statusOK
+}
+
+// INCR、DECR、INCRBY、DECRBY
+func cmdIncr(c *client, args []string) interface{} {
+	delta := int64(1)
+	if len(args) == 3 {
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		delta = n
+	}
+	if strings.HasPrefix(strings.ToLower(args[0]), "decr") {
+		delta = -delta
+	}
+	v, ok, err := c.getString(args[1])
+	if err != nil {
+		return err
+	}
+	var n int64
+	if ok {
+		var parseErr error
+		if n, parseErr = strconv.ParseInt(v, 10, 64); parseErr != nil {
+			return errNotInteger
+		}
+	}
+	n += delta
+	c.setKeepTTL(args[1], strconv.FormatInt(n, 10))
+	return n
+}
+
+// 修改值时保留原来的过期时间
+func (c *client) setKeepTTL(key string, value interface{}) {
+	if it := c.store.lookup(c.db, key); it != nil {
+		it.value = value
+		return
+	}
+	c.store.dbs[c.db][key] = &item{value: value}
+}
+
+func cmdAppend(c *client, args []string) interface{} {
+	v, _, err := c.getString(args[1])
+	if err != nil {
+		return err
+	}
+	v += args[2]
+	c.setKeepTTL(args[1], v)
+	return len(v)
+}
+
+func cmdStrlen(c *client, args []string) interface{} {
+	v, _, err := c.getString(args[1])
+	if err != nil {
+		return err
+	}
+	return len(v)
+}
+
+// 返回哈希类型的值，create 为 true 时不存在则创建
+func (c *client) getHash(key string, create bool) (map[string]string, interface{}) {
+	it := c.store.lookup(c.db, key)
+	if it == nil {
+		if !create {
+			return nil, nil
+		}
+		h := map[string]string{}
+		c.store.dbs[c.db][key] = &item{value: h}
+		return h, nil
+	}
+	h, ok := it.value.(map[string]string)
+	if !ok {
+		return nil, errWrongType
+	}
+	return h, nil
+}
+
+func cmdHSet(c *client, args []string) interface{} {
+	if len(args)%2 != 0 {
+		return errReply("ERR wrong number of arguments for '" + strings.ToLower(args[0]) + "' command")
+	}
+	h, err := c.getHash(args[1], true)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for i := 2; i < len(args); i += 2 {
+		if _, ok := h[args[i]]; !ok {
+			n++
+		}
+		h[args[i]] = args[i+1]
+	}
+	if strings.EqualFold(args[0], "hmset") {
+		return statusOK
+	}
+	return n
+}
+
+func cmdHGet(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	v, ok := h[args[2]]
+	if !ok {
+		return nilReply{}
+	}
+	return v
+}
+
+func cmdHMGet(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, 0, len(args)-2)
+	for _, field := range args[2:] {
+		if v, ok := h[field]; ok {
+			values = append(values, v)
+		} else {
+			values = append(values, nilReply{})
+		}
+	}
+	return values
+}
+
+func cmdHGetAll(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	result := make(map[string]interface{}, len(h))
+	for field, v := range h {
+		result[field] = v
+	}
+	return result
+}
+
+func cmdHDel(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, field := range args[2:] {
+		if _, ok := h[field]; ok {
+			delete(h, field)
+			n++
+		}
+	}
+	if h != nil && len(h) == 0 {
+		delete(c.store.dbs[c.db], args[1])
+	}
+	return n
+}
+
+func cmdHExists(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	_, ok := h[args[2]]
+	return ok
+}
+
+func cmdHLen(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	return len(h)
+}
+
+// HKEYS、HVALS，按字段名排序
+func cmdHKeys(c *client, args []string) interface{} {
+	h, err := c.getHash(args[1], false)
+	if err != nil {
+		return err
+	}
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	if strings.EqualFold(args[0], "hvals") {
+		for i, field := range fields {
+			fields[i] = h[field]
+		}
+	}
+	return fields
+}
+
+func cmdHIncrBy(c *client, args []string) interface{} {
+	delta, parseErr := strconv.ParseInt(args[3], 10, 64)
+	if parseErr != nil {
+		return errNotInteger
+	}
+	h, err := c.getHash(args[1], true)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if v, ok := h[args[2]]; ok {
+		if n, parseErr = strconv.ParseInt(v, 10, 64); parseErr != nil {
+			return errReply("ERR hash value is not an integer")
+		}
+	}
+	n += delta
+	h[args[2]] = strconv.FormatInt(n, 10)
+	return n
+}
+
+func (c *client) getList(key string) ([]string, interface{}) {
+	it := c.store.lookup(c.db, key)
+	if it == nil {
+		return nil, nil
+	}
+	l, ok := it.value.([]string)
+	if !ok {
+		return nil, errWrongType
+	}
+	return l, nil
+}
+
+func cmdPush(c *client, args []string) interface{} {
+	l, err := c.getList(args[1])
+	if err != nil {
+		return err
+	}
+	for _, v := range args[2:] {
+		if strings.EqualFold(args[0], "lpush") {
+			l = append([]string{v}, l...)
+		} else {
+			l = append(l, v)
+		}
+	}
+	c.setKeepTTL(args[1], l)
+	return len(l)
+}
+
+// LPOP、RPOP，带 count 时返回数组
+func cmdPop(c *client, args []string) interface{} {
+	l, err := c.getList(args[1])
+	if err != nil {
+		return err
+	}
+	count := 1
+	if len(args) > 2 {
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return errReply("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+	if l == nil {
+		return nilReply{}
+	}
+	count = min(count, len(l))
+	var popped []string
+	if strings.EqualFold(args[0], "lpop") {
+		popped, l = l[:count], l[count:]
+	} else {
+		popped, l = slices.Clone(l[len(l)-count:]), l[:len(l)-count]
+		slices.Reverse(popped)
+	}
+	if len(l) == 0 {
+		delete(c.store.dbs[c.db], args[1])
+	} else {
+		c.setKeepTTL(args[1], l)
+	}
+	if len(args) > 2 {
+		return append([]string(nil), popped...)
+	}
+	return popped[0]
+}
+
+func cmdLRange(c *client, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInteger
+	}
+	l, err := c.getList(args[1])
+	if err != nil {
+		return err
+	}
+	n := len(l)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return []string{}
+	}
+	return append([]string{}, l[start:stop+1]...)
+}
+
+func cmdLLen(c *client, args []string) interface{} {
+	l, err := c.getList(args[1])
+	if err != nil {
+		return err
+	}
+	return len(l)
+}
+
+// 键所在的集群槽位，和 Redis 一样对 {hash tag} 中的内容做 CRC16
+func keySlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc % 16384
+}
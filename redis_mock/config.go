@@ -0,0 +1,110 @@
+package redis_mock
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Config 认证、启用的命令和按命令匹配的脚本规则
+type Config struct {
+	Password string   `yaml:"password"` // 为空时不需要 AUTH
+	Commands []string `yaml:"commands"` // 启用的内置命令，为空时全部启用，其他命令返回 unknown command
+	Cluster  bool     `yaml:"cluster"`  // 以单节点集群模式回复 CLUSTER SLOTS、CLUSTER SHARDS，所有槽位都在本节点
+	Announce string   `yaml:"announce"` // 集群模式下回复的节点地址，默认是客户端连接的地址
+	Rules    []Rule   `yaml:"rules"`
+}
+
+// Rule 按命令和参数匹配的脚本规则，按顺序使用第一条命中的规则。
+// 设置 error、status、reply、nil、close 时直接返回，不执行命令；只设置 delay 时等待后照常执行
+type Rule struct {
+	Command     string        `yaml:"command"`
+	Args        []string      `yaml:"args"` // 按位置匹配参数，支持 path.Match 通配符，没有列出的参数不检查
+	Delay       time.Duration `yaml:"delay"`
+	Error       string        `yaml:"error"`  // 错误回复，如 MOVED 3999 127.0.0.1:7001、LOADING Redis is loading the dataset in memory
+	Status      string        `yaml:"status"` // 简单字符串回复，如 OK
+	Reply       interface{}   `yaml:"reply"`  // 字符串、整数、数组或对象
+	Nil         bool          `yaml:"nil"`    // 回复 nil
+	Close       bool          `yaml:"close"`  // 断开连接
+	Times       int64         `yaml:"times"`  // 只命中前 times 次，0 表示不限制
+	Probability float64       `yaml:"probability"`
+
+	hits atomic.Int64
+}
+
+func loadConfig(file string) (*Config, error) {
+	config := &Config{}
+	if file == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", file, err)
+	}
+	for i := range config.Rules {
+		r := &config.Rules[i]
+		if r.Command == "" {
+			return nil, fmt.Errorf("rule %d: command is required", i)
+		}
+		for _, pattern := range r.Args {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid pattern %q", i, pattern)
+			}
+		}
+	}
+	return config, nil
+}
+
+// 返回第一条命中的规则
+func (c *Config) rule(args []string) *Rule {
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if !strings.EqualFold(r.Command, args[0]) || !r.matchArgs(args[1:]) {
+			continue
+		}
+		if r.Probability > 0 && rand.Float64() >= r.Probability {
+			continue
+		}
+		if r.Times > 0 && r.hits.Add(1) > r.Times {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+func (r *Rule) matchArgs(args []string) bool {
+	if len(args) < len(r.Args) {
+		return false
+	}
+	for i, pattern := range r.Args {
+		if ok, _ := path.Match(pattern, args[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 规则的回复，返回 false 表示继续执行命令
+func (r *Rule) reply() (interface{}, bool) {
+	switch {
+	case r.Error != "":
+		return errReply(r.Error), true
+	case r.Status != "":
+		return status(r.Status), true
+	case r.Nil:
+		return nilReply{}, true
+	case r.Reply != nil:
+		return r.Reply, true
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package redis_mock
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// client 一个客户端连接的状态
+type client struct {
+	*server
+	conn   net.Conn
+	id     int64
+	db     int
+	authed bool
+	name   string
+}
+
+func (c *client) mode() string {
+	if c.config.Cluster {
+		return "cluster"
+	}
+	return "standalone"
+}
+
+// 集群模式下回复给客户端的本节点地址
+func (c *client) announce() string {
+	if c.config.Announce != "" {
+		return c.config.Announce
+	}
+	return c.conn.LocalAddr().String()
+}
+
+func (c *client) serve() {
+	defer c.conn.Close()
+	remote := c.conn.RemoteAddr().String()
+	logger.Debug("客户端连接", "client", remote, "id", c.id)
+	reader := bufio.NewReader(c.conn)
+	writer := bufio.NewWriter(c.conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				writeReply(writer, errReply("ERR "+err.Error()))
+				writer.Flush()
+				logger.Warn("读取命令失败", "client", remote, "err", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		reply, close := c.handle(args)
+		if close {
+			writer.Flush()
+			logger.Info("按规则断开连接", "client", remote, "command", args[0])
+			return
+		}
+		writeReply(writer, reply)
+		if strings.EqualFold(args[0], "quit") {
+			writer.Flush()
+			return
+		}
+		// 流水线中的命令都处理完再写回
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 执行一条命令，第二个返回值为 true 时断开连接
+func (c *client) handle(args []string) (interface{}, bool) {
+	name := strings.ToLower(args[0])
+	logger.Debug("收到命令", "id", c.id, "command", name, "args", len(args)-1)
+	if r := c.config.rule(args); r != nil {
+		if r.Delay > 0 {
+			time.Sleep(r.Delay)
+		}
+		if r.Close {
+			return nil, true
+		}
+		if reply, ok := r.reply(); ok {
+			logger.Info("命中规则", "id", c.id, "command", name, "rule", r.Command)
+			return reply, false
+		}
+	}
+	if name == "quit" {
+		return statusOK, false
+	}
+	cmd, ok := commands[name]
+	if !ok || !c.enabled(name) {
+		return errReply("ERR unknown command '" + args[0] + "', with args beginning with: " + strings.Join(args[1:min(len(args), 4)], " ")), false
+	}
+	if (cmd.arity > 0 && len(args) < cmd.arity) || (cmd.arity < 0 && len(args) != -cmd.arity) {
+		return errReply("ERR wrong number of arguments for '" + name + "' command"), false
+	}
+	if c.config.Password != "" && !c.authed && name != "auth" && name != "hello" {
+		return errNoAuth, false
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	return cmd.fn(c, args), false
+}
+
+func (c *client) enabled(name string) bool {
+	return c.enabledCommands == nil || c.enabledCommands[name] || connectionCommands[name]
+}
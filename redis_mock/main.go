@@ -0,0 +1,106 @@
+// Package redis_mock Redis 协议（RESP2）mock：在内存中实现常用的字符串、哈希、列表和连接命令，
+// 并可以按配置的规则对指定命令返回脚本化的回复、延迟或错误（如 MOVED、LOADING），用于测试客户端的重试和容错逻辑。
+package redis_mock
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/TreeWu/mock-go/logging"
+)
+
+var logger = logging.For("redis")
+
+// server 所有连接共用的配置和数据
+type server struct {
+	config          *Config
+	store           *store
+	enabledCommands map[string]bool // 为 nil 时启用全部内置命令
+	nextID          atomic.Int64
+}
+
+// Run 启动 Redis 协议 mock，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("redis", flag.ContinueOnError)
+	configFile := fs.String("config", "", "yaml/json file with enabled commands and scripted rules, all built-in commands when empty")
+	port := fs.String("port", envOr("REDIS_PORT", ":6379"), "listen address (env REDIS_PORT)")
+	password := fs.String("password", "", "require AUTH with this password, overrides the config file")
+	cluster := fs.Bool("cluster", false, "answer CLUSTER commands as a single-node cluster")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		logger.Error("读取配置失败", "err", err)
+		return 1
+	}
+	if *password != "" {
+		config.Password = *password
+	}
+	if *cluster {
+		config.Cluster = true
+	}
+	s := &server{config: config, store: newStore()}
+	if len(config.Commands) > 0 {
+		s.enabledCommands = map[string]bool{}
+		for _, name := range config.Commands {
+			s.enabledCommands[strings.ToLower(name)] = true
+		}
+	}
+
+	listener, err := net.Listen("tcp", listenAddr(*port))
+	if err != nil {
+		logger.Error("启动 Redis Mock 失败", "err", err)
+		return 1
+	}
+	defer listener.Close()
+	logger.Info("Redis Mock 服务器启动", "addr", listener.Addr().String(), "rules", len(config.Rules), "cluster", config.Cluster)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.serve(listener) }()
+	select {
+	case err := <-errCh:
+		logger.Error("服务异常退出", "err", err)
+		return 1
+	case <-ctx.Done():
+		logger.Info("正在停止 Mock 服务器")
+		return 0
+	}
+}
+
+func (s *server) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go (&client{server: s, conn: conn, id: s.nextID.Add(1)}).serve()
+	}
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
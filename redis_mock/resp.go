@@ -0,0 +1,115 @@
+package redis_mock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 单个参数的最大长度，和 Redis 的 proto-max-bulk-len 默认值一致
+const maxBulkLen = 512 << 20
+
+// 读取一条命令，支持 RESP 数组和 telnet 使用的内联命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > 1024*1024 {
+		return nil, errors.New("Protocol error: invalid multibulk length")
+	}
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("Protocol error: expected '$', got '%.1s'", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errors.New("Protocol error: invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// 回复类型，写入时按 RESP2 编码
+type (
+	status   string // +OK
+	errReply string // -ERR ...
+	nilReply struct{}
+)
+
+// 按 RESP2 编码回复。string 是 bulk string，整数是 integer，切片是 array，map 按键排序后展开为 array
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil, nilReply:
+		w.WriteString("$-1\r\n")
+	case status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case errReply:
+		w.WriteString("-" + string(v) + "\r\n")
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case uint64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case bool:
+		if v {
+			w.WriteString(":1\r\n")
+		} else {
+			w.WriteString(":0\r\n")
+		}
+	case float64:
+		writeReply(w, strconv.FormatFloat(v, 'f', -1, 64))
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(w, "*%d\r\n", len(keys)*2)
+		for _, key := range keys {
+			writeReply(w, key)
+			writeReply(w, v[key])
+		}
+	default:
+		writeReply(w, fmt.Sprint(v))
+	}
+}
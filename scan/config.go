@@ -0,0 +1,222 @@
+package scan
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/TreeWu/mock-go/targets"
+	"github.com/goccy/go-yaml"
+)
+
+// Config 扫描配置文件，defaults 为全局配置，groups 中的配置覆盖全局配置
+type Config struct {
+	Defaults   GroupConfig       `yaml:"defaults"`
+	Groups     []TargetGroup     `yaml:"groups"`
+	Collectors []CollectorConfig `yaml:"collectors"` // 自定义采集项，在 -facts 之外额外执行
+}
+
+// TargetGroup 一组使用相同连接配置的目标
+type TargetGroup struct {
+	Name        string   `yaml:"name"`
+	Targets     []string `yaml:"targets"` // IP范围，格式同命令行参数
+	GroupConfig `yaml:",inline"`
+}
+
+// GroupConfig 可在全局或目标组上设置的连接配置，零值表示沿用上层配置
+type GroupConfig struct {
+	Username string         `yaml:"username"`
+	Password string         `yaml:"password"`
+	Port     int            `yaml:"port"`
+	Bastion  *BastionConfig `yaml:"bastion"`
+	Become   *BecomeConfig  `yaml:"become"`
+	Ports    []int          `yaml:"ports"` // 端口扫描模式下扫描的端口
+	SNMP     *SNMPConfig    `yaml:"snmp"`  // SSH 失败时的 SNMP 采集配置
+
+	Credentials     []Credential `yaml:"credentials"`       // 按顺序尝试的登录凭据，设置后替代 username/password
+	CredentialSet   string       `yaml:"credential_set"`    // --targets 文件中的 SSH 凭据名称，组内直接写的字段优先
+	MaxAuthAttempts int          `yaml:"max_auth_attempts"` // 每台主机最多认证失败次数
+
+	Vars map[string]string `yaml:"vars"` // 自定义采集命令模板中可引用的变量，组内变量覆盖全局变量
+}
+
+// BastionConfig 跳板机配置，目标主机经由跳板机建立连接
+type BastionConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// 提权方式
+const (
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+)
+
+// BecomeConfig 以其他用户身份执行采集命令，适用于禁止 root 直接登录的主机
+type BecomeConfig struct {
+	Method   string `yaml:"method"`   // sudo 或 su，默认 sudo
+	User     string `yaml:"user"`     // 目标用户，默认 root
+	Password string `yaml:"password"` // 提权密码，默认使用登录密码
+}
+
+// Target 待扫描的单台主机
+type Target struct {
+	IP    string
+	Group string
+	SSH   SSHConfig
+	Ports []int
+	Vars  map[string]string
+	SNMP  *SNMPConfig
+}
+
+// TargetKey 目标的唯一标识，同一 IP 可以出现在不同的目标组中
+func TargetKey(group, ip string) string {
+	return group + "/" + ip
+}
+
+// LoadConfig 读取扫描配置文件
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// 将配置中的非零值覆盖到SSH配置上
+func (g GroupConfig) apply(config SSHConfig) SSHConfig {
+	if g.Username != "" {
+		config.Username = g.Username
+	}
+	if g.Password != "" {
+		config.Password = g.Password
+	}
+	if g.Port != 0 {
+		config.Port = g.Port
+	}
+	if g.Bastion != nil {
+		config.Bastion = g.Bastion
+	}
+	if g.Become != nil {
+		config.Become = g.Become
+	}
+	if len(g.Credentials) > 0 {
+		config.Credentials = g.Credentials
+	}
+	if g.MaxAuthAttempts != 0 {
+		config.MaxAuthAttempts = g.MaxAuthAttempts
+	}
+	return config
+}
+
+// 先套用 credential_set 引用的共享凭据，再套用组内配置
+func (g GroupConfig) resolve(config SSHConfig) (SSHConfig, error) {
+	if g.CredentialSet != "" {
+		creds, err := targets.FindCredentials(g.CredentialSet)
+		if err != nil {
+			return config, err
+		}
+		set := GroupConfig{Username: creds.User, Password: creds.Password, Port: creds.Port}
+		for _, key := range creds.Keys {
+			set.Credentials = append(set.Credentials, Credential{KeyFile: key})
+		}
+		if len(set.Credentials) > 0 && creds.Password != "" {
+			set.Credentials = append(set.Credentials, Credential{Password: creds.Password})
+		}
+		if creds.Bastion != "" {
+			if set.Bastion, err = ParseBastion(creds.Bastion); err != nil {
+				return config, err
+			}
+		}
+		if creds.Become != "" {
+			set.Become = &BecomeConfig{Method: creds.Become, User: creds.BecomeUser}
+		}
+		config = set.apply(config)
+	}
+	return g.apply(config), nil
+}
+
+// Targets 展开配置中所有目标组，得到带连接配置的主机列表
+func (c *Config) Targets(base SSHConfig) ([]Target, error) {
+	base, err := c.Defaults.resolve(base)
+	if err != nil {
+		return nil, fmt.Errorf("defaults: %v", err)
+	}
+	defaultPorts := c.Defaults.Ports
+
+	var targets []Target
+	for i, group := range c.Groups {
+		name := group.Name
+		if name == "" {
+			name = fmt.Sprintf("group%d", i+1)
+		}
+		config, err := group.resolve(base)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %v", name, err)
+		}
+		ports := group.Ports
+		if len(ports) == 0 {
+			ports = defaultPorts
+		}
+		vars := mergeVars(c.Defaults.Vars, group.Vars)
+		snmp := group.SNMP
+		if snmp == nil {
+			snmp = c.Defaults.SNMP
+		}
+		for _, ipRange := range group.Targets {
+			ips, err := ParseIPRange(ipRange)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %v", name, err)
+			}
+			for _, ip := range ips {
+				targets = append(targets, Target{IP: ip, Group: name, SSH: config, Ports: ports, Vars: vars, SNMP: snmp})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// 合并全局和组内变量，组内变量优先
+func mergeVars(defaults, group map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return group
+	}
+	vars := make(map[string]string, len(defaults)+len(group))
+	for k, v := range defaults {
+		vars[k] = v
+	}
+	for k, v := range group {
+		vars[k] = v
+	}
+	return vars
+}
+
+// ParseBastion 解析命令行中的跳板机参数，格式为 user:password@host:port
+func ParseBastion(value string) (*BastionConfig, error) {
+	at := strings.LastIndex(value, "@")
+	if at < 0 {
+		return nil, fmt.Errorf("invalid bastion format, want user:password@host:port")
+	}
+
+	var bastion BastionConfig
+	bastion.Username, bastion.Password, _ = strings.Cut(value[:at], ":")
+
+	host, port, err := net.SplitHostPort(value[at+1:])
+	if err != nil {
+		bastion.Host = value[at+1:]
+		return &bastion, nil
+	}
+	bastion.Host = host
+	if bastion.Port, err = strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("invalid bastion port: %s", port)
+	}
+	return &bastion, nil
+}
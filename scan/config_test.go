@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TreeWu/mock-go/targets"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// defaults 覆盖命令行给出的基础配置，目标组再覆盖 defaults，零值沿用上层配置
+func TestConfigTargets(t *testing.T) {
+	if err := targets.Load(writeFile(t, "targets.yaml", `
+ssh:
+  lab:
+    user: ops
+    password: secret
+    port: 2200
+    keys: [/keys/id_ed25519]
+    become: sudo
+`)); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(writeFile(t, "scan.yaml", `
+defaults:
+  username: admin
+  ports: [22, 80]
+  vars: {env: prod, region: eu}
+groups:
+  - name: web
+    targets: [10.0.0.1-2]
+    password: web-pass
+    vars: {region: us}
+  - targets: [10.0.1.5]
+    port: 2222
+    ports: [443]
+  - name: lab
+    targets: [10.0.2.1]
+    credential_set: lab
+    port: 2201
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ConfigTargets{Config: config, Base: SSHConfig{Username: "root", Password: "base", Port: 22}}.Targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		ip, group, user, password string
+		port                      int
+		ports                     []int
+		vars                      map[string]string
+	}{
+		{"10.0.0.1", "web", "admin", "web-pass", 22, []int{22, 80}, map[string]string{"env": "prod", "region": "us"}},
+		{"10.0.0.2", "web", "admin", "web-pass", 22, []int{22, 80}, map[string]string{"env": "prod", "region": "us"}},
+		{"10.0.1.5", "group2", "admin", "base", 2222, []int{443}, map[string]string{"env": "prod", "region": "eu"}},
+		// credential_set 先套用，组内直接写的 port 优先
+		{"10.0.2.1", "lab", "ops", "secret", 2201, []int{22, 80}, map[string]string{"env": "prod", "region": "eu"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d targets, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.IP != w.ip || g.Group != w.group || g.SSH.Username != w.user || g.SSH.Password != w.password || g.SSH.Port != w.port {
+			t.Errorf("target %d = %s %s %s:%s port %d, want %+v", i, g.IP, g.Group, g.SSH.Username, g.SSH.Password, g.SSH.Port, w)
+		}
+		if !reflect.DeepEqual(g.Ports, w.ports) || !reflect.DeepEqual(g.Vars, w.vars) {
+			t.Errorf("target %d ports %v vars %v, want %v %v", i, g.Ports, g.Vars, w.ports, w.vars)
+		}
+	}
+	lab := got[3].SSH
+	wantCreds := []Credential{{KeyFile: "/keys/id_ed25519"}, {Password: "secret"}}
+	if !reflect.DeepEqual(lab.Credentials, wantCreds) || lab.Become == nil || lab.Become.Method != BecomeSudo {
+		t.Errorf("credential_set lab resolved to credentials %+v become %+v", lab.Credentials, lab.Become)
+	}
+
+	config.Groups[2].CredentialSet = "missing"
+	if _, err := config.Targets(SSHConfig{}); err == nil {
+		t.Error("unknown credential_set did not return an error")
+	}
+}
+
+func TestParseBastion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    BastionConfig
+		wantErr bool
+	}{
+		{"jump:pw@10.0.0.1:2222", BastionConfig{Host: "10.0.0.1", Port: 2222, Username: "jump", Password: "pw"}, false},
+		{"jump@bastion", BastionConfig{Host: "bastion", Username: "jump"}, false},
+		{"u:p@ss@host:22", BastionConfig{Host: "host", Port: 22, Username: "u", Password: "p@ss"}, false},
+		{"host:22", BastionConfig{}, true},
+		{"u:p@host:abc", BastionConfig{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBastion(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBastion(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && *got != tt.want {
+			t.Errorf("ParseBastion(%q) = %+v, want %+v", tt.value, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 检测的容器运行时和相关进程
+const containerDetectCommand = `for b in docker podman containerd crictl kubelet; do command -v $b >/dev/null 2>&1 && echo "bin $b"; done; ` +
+	`for p in dockerd containerd crio kubelet; do pgrep -x $p >/dev/null 2>&1 && echo "proc $p"; done; true`
+
+// 各运行时获取版本的命令
+var containerVersionCommands = map[string]string{
+	"docker":     "docker version --format '{{.Server.Version}}' 2>/dev/null || docker --version",
+	"podman":     "podman --version",
+	"containerd": "containerd --version",
+	"crictl":     "crictl version",
+	"kubelet":    "kubelet --version",
+}
+
+// containerCollector 检测主机上的容器运行时（docker、podman、containerd、CRI、kubelet）
+type containerCollector struct{}
+
+func (containerCollector) Name() string {
+	return "containers"
+}
+
+func (containerCollector) Collect(runner CommandRunner, target Target) (interface{}, error) {
+	binaries, processes, err := detectContainerRuntimes(runner)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimes := make(map[string]interface{})
+	names := make(map[string]struct{})
+	for name := range binaries {
+		names[name] = struct{}{}
+	}
+	for name := range processes {
+		names[name] = struct{}{}
+	}
+	for name := range names {
+		info := map[string]interface{}{
+			"installed": binaries[name],
+			"running":   processes[name],
+		}
+		if command, ok := containerVersionCommands[name]; ok && binaries[name] {
+			if output, err := runner.Run(command); err == nil {
+				for key, value := range parseRuntimeVersion(name, output) {
+					info[key] = value
+				}
+			}
+		}
+		runtimes[name] = info
+	}
+	return runtimes, nil
+}
+
+// 返回已安装的命令和正在运行的进程，dockerd 归入 docker
+func detectContainerRuntimes(runner CommandRunner) (map[string]bool, map[string]bool, error) {
+	output, err := runner.Run(containerDetectCommand)
+	if err != nil {
+		return nil, nil, err
+	}
+	binaries := make(map[string]bool)
+	processes := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		kind, name, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "bin":
+			binaries[name] = true
+		case "proc":
+			if name == "dockerd" {
+				name = "docker"
+			}
+			processes[name] = true
+		}
+	}
+	return binaries, processes, nil
+}
+
+// 从各运行时的版本输出中取出版本号
+func parseRuntimeVersion(name, output string) map[string]interface{} {
+	output = strings.TrimSpace(output)
+	fields := strings.Fields(output)
+	switch name {
+	case "crictl":
+		// RuntimeName:  containerd
+		// RuntimeVersion:  v1.7.2
+		result := make(map[string]interface{})
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "RuntimeName":
+				result["runtime"] = strings.TrimSpace(value)
+			case "RuntimeVersion":
+				result["version"] = strings.TrimSpace(value)
+			}
+		}
+		return result
+	case "containerd":
+		// containerd github.com/containerd/containerd v1.7.2 0cae528dd6cb557f7201036e9f43420650207b58
+		if len(fields) >= 3 {
+			return map[string]interface{}{"version": fields[2]}
+		}
+	case "docker":
+		// 24.0.5 或 Docker version 24.0.5, build ced0996
+		if len(fields) == 1 {
+			return map[string]interface{}{"version": fields[0]}
+		}
+		if len(fields) >= 3 {
+			return map[string]interface{}{"version": strings.TrimSuffix(fields[2], ",")}
+		}
+	default:
+		// podman version 4.6.1 / Kubernetes v1.28.2
+		if len(fields) > 0 {
+			return map[string]interface{}{"version": fields[len(fields)-1]}
+		}
+	}
+	return nil
+}
+
+// Workload 运行中的容器或 Pod
+type Workload struct {
+	Runtime   string `json:"runtime"`
+	Kind      string `json:"kind"` // container 或 pod
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Image     string `json:"image,omitempty"`
+	State     string `json:"state,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// workloadCollector 列出运行中的容器和 Pod，作为主机的子项记录
+type workloadCollector struct{}
+
+func (workloadCollector) Name() string {
+	return "workloads"
+}
+
+func (workloadCollector) Collect(runner CommandRunner, target Target) (interface{}, error) {
+	binaries, _, err := detectContainerRuntimes(runner)
+	if err != nil {
+		return nil, err
+	}
+
+	workloads := []Workload{}
+	var errs []string
+	list := func(runtime, command string, parse func(string) ([]Workload, error)) {
+		if !binaries[runtime] {
+			return
+		}
+		output, err := runner.Run(command)
+		if err == nil {
+			var items []Workload
+			if items, err = parse(output); err == nil {
+				workloads = append(workloads, items...)
+				return
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", runtime, err))
+	}
+	list("docker", "docker ps --no-trunc --format '{{json .}}'", parseDockerPS)
+	list("podman", "podman ps --format json", parsePodmanPS)
+	list("crictl", "crictl pods --state ready -o json", parseCrictlPods)
+	list("crictl", "crictl ps --state running -o json", parseCrictlPS)
+
+	// 所有运行时都失败时才视为采集失败
+	if len(workloads) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return workloads, nil
+}
+
+// docker ps 每行一个 JSON 对象
+func parseDockerPS(output string) ([]Workload, error) {
+	var workloads []Workload
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var item struct {
+			ID     string `json:"ID"`
+			Image  string `json:"Image"`
+			Names  string `json:"Names"`
+			State  string `json:"State"`
+			Labels string `json:"Labels"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			return nil, fmt.Errorf("parse docker ps: %v", err)
+		}
+		w := Workload{Runtime: "docker", Kind: "container", ID: item.ID, Name: item.Names, Image: item.Image, State: item.State}
+		// dockershim 创建的容器带有 Kubernetes 标签
+		for _, label := range strings.Split(item.Labels, ",") {
+			key, value, _ := strings.Cut(label, "=")
+			switch key {
+			case "io.kubernetes.pod.name":
+				w.Pod = value
+			case "io.kubernetes.pod.namespace":
+				w.Namespace = value
+			}
+		}
+		workloads = append(workloads, w)
+	}
+	return workloads, scanner.Err()
+}
+
+func parsePodmanPS(output string) ([]Workload, error) {
+	var items []struct {
+		ID      string   `json:"Id"`
+		Image   string   `json:"Image"`
+		Names   []string `json:"Names"`
+		State   string   `json:"State"`
+		PodName string   `json:"PodName"`
+	}
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		return nil, fmt.Errorf("parse podman ps: %v", err)
+	}
+	var workloads []Workload
+	for _, item := range items {
+		workloads = append(workloads, Workload{
+			Runtime: "podman",
+			Kind:    "container",
+			ID:      item.ID,
+			Name:    strings.Join(item.Names, ","),
+			Image:   item.Image,
+			State:   item.State,
+			Pod:     item.PodName,
+		})
+	}
+	return workloads, nil
+}
+
+func parseCrictlPods(output string) ([]Workload, error) {
+	var result struct {
+		Items []struct {
+			ID       string `json:"id"`
+			State    string `json:"state"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("parse crictl pods: %v", err)
+	}
+	var workloads []Workload
+	for _, item := range result.Items {
+		workloads = append(workloads, Workload{
+			Runtime:   "cri",
+			Kind:      "pod",
+			ID:        item.ID,
+			Name:      item.Metadata.Name,
+			State:     item.State,
+			Namespace: item.Metadata.Namespace,
+		})
+	}
+	return workloads, nil
+}
+
+func parseCrictlPS(output string) ([]Workload, error) {
+	var result struct {
+		Containers []struct {
+			ID       string `json:"id"`
+			State    string `json:"state"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Image struct {
+				Image string `json:"image"`
+			} `json:"image"`
+			Labels map[string]string `json:"labels"`
+		} `json:"containers"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("parse crictl ps: %v", err)
+	}
+	var workloads []Workload
+	for _, item := range result.Containers {
+		workloads = append(workloads, Workload{
+			Runtime:   "cri",
+			Kind:      "container",
+			ID:        item.ID,
+			Name:      item.Metadata.Name,
+			Image:     item.Image.Image,
+			State:     item.State,
+			Pod:       item.Labels["io.kubernetes.pod.name"],
+			Namespace: item.Labels["io.kubernetes.pod.namespace"],
+		})
+	}
+	return workloads, nil
+}
@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Credential 登录凭据候选项，异构的老旧主机上按顺序逐个尝试
+type Credential struct {
+	Name       string `yaml:"name"`       // 记录在结果中的名称，默认由用户名和密钥文件生成，不包含密码
+	Username   string `yaml:"username"`   // 默认使用组配置中的用户名
+	Password   string `yaml:"password"`   // 密码登录，同时作为 sudo/su 的默认提权密码
+	KeyFile    string `yaml:"key_file"`   // 私钥文件，设置后使用密钥登录
+	Passphrase string `yaml:"passphrase"` // 私钥密码
+}
+
+// 结果中记录的凭据名称
+func (c Credential) label(index int) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.KeyFile != "" {
+		return c.Username + "@key:" + filepath.Base(c.KeyFile)
+	}
+	return c.Username + "@password#" + strconv.Itoa(index+1)
+}
+
+func (c Credential) authMethod() (ssh.AuthMethod, error) {
+	if c.KeyFile == "" {
+		return ssh.Password(c.Password), nil
+	}
+	signer, err := loadSigner(c.KeyFile, c.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// 私钥只解析一次，所有主机共用
+var (
+	signersMu sync.Mutex
+	signers   = make(map[string]ssh.Signer)
+)
+
+func loadSigner(path, passphrase string) (ssh.Signer, error) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+
+	if signer, ok := signers[path]; ok {
+		return signer, nil
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %v", err)
+	}
+
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(content, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse key %s: %v", path, err)
+	}
+	signers[path] = signer
+	return signer, nil
+}
+
+// 同一目标组中最近一次登录成功的凭据，后续主机优先尝试，减少失败次数
+var (
+	credentialHintsMu sync.Mutex
+	credentialHints   = make(map[string]string)
+)
+
+type candidate struct {
+	label      string
+	credential Credential
+}
+
+// 本台主机要尝试的凭据，未配置候选项时使用 Username/Password
+func credentialCandidates(group string, config SSHConfig) []candidate {
+	credentials := config.Credentials
+	if len(credentials) == 0 {
+		credentials = []Credential{{Name: config.Username + "@password", Password: config.Password}}
+	}
+
+	candidates := make([]candidate, 0, len(credentials))
+	for i, credential := range credentials {
+		if credential.Username == "" {
+			credential.Username = config.Username
+		}
+		candidates = append(candidates, candidate{label: credential.label(i), credential: credential})
+	}
+
+	credentialHintsMu.Lock()
+	hint, ok := credentialHints[group]
+	credentialHintsMu.Unlock()
+	if ok {
+		for i, c := range candidates {
+			if c.label == hint && i > 0 {
+				reordered := append([]candidate{c}, candidates[:i]...)
+				candidates = append(reordered, candidates[i+1:]...)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+func rememberCredential(group, label string) {
+	credentialHintsMu.Lock()
+	credentialHints[group] = label
+	credentialHintsMu.Unlock()
+}
+
+// 认证失败时尝试下一个凭据，其他错误（网络、主机密钥）说明主机本身有问题，不再尝试
+func isAuthError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "unable to authenticate")
+}
@@ -0,0 +1,219 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// 自定义采集项的输出解析方式
+const (
+	ParserRaw   = "raw"   // 原样返回去除首尾空白的输出
+	ParserLines = "lines" // 按行拆分，忽略空行
+	ParserRegex = "regex" // 按正则提取，命名分组作为字段
+	ParserJSON  = "json"  // 解析 JSON，可用 path 取其中一部分
+)
+
+// CollectorConfig 配置文件中定义的采集项，无需修改代码即可采集新的事实
+type CollectorConfig struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"` // 命令模板，可引用 {{.IP}} {{.Group}} {{.Vars.xxx}}，{{quote .Vars.xxx}} 转义为 shell 参数
+	Parser  string `yaml:"parser"`  // raw、lines、regex 或 json，默认 raw
+	Pattern string `yaml:"pattern"` // regex 解析使用的正则
+	All     bool   `yaml:"all"`     // regex 解析返回所有匹配，默认只返回第一个
+	Path    string `yaml:"path"`    // json 解析的取值路径，例如 data.items.0.name
+	Field   string `yaml:"field"`   // 结果在 facts 中的键，默认使用 name
+}
+
+// 命令模板中可引用的主机信息
+type templateData struct {
+	IP    string
+	Group string
+	Vars  map[string]string
+}
+
+// templateCollector 按主机渲染命令模板并解析输出
+type templateCollector struct {
+	field   string
+	command *template.Template
+	parse   func(output string) (interface{}, error)
+}
+
+func (c *templateCollector) Name() string {
+	return c.field
+}
+
+func (c *templateCollector) Collect(runner CommandRunner, target Target) (interface{}, error) {
+	var command bytes.Buffer
+	err := c.command.Execute(&command, templateData{IP: target.IP, Group: target.Group, Vars: target.Vars})
+	if err != nil {
+		return nil, fmt.Errorf("render command: %v", err)
+	}
+	output, err := runner.Run(command.String())
+	if err != nil {
+		return nil, err
+	}
+	return c.parse(output)
+}
+
+// CustomCollectors 根据配置文件中的定义创建采集项
+func (c *Config) CustomCollectors() ([]Collector, error) {
+	var collectors []Collector
+	fields := make(map[string]struct{})
+	for i, def := range c.Collectors {
+		collector, err := newTemplateCollector(def)
+		if err != nil {
+			name := def.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("collector %s: %v", name, err)
+		}
+		if _, ok := fields[collector.field]; ok {
+			return nil, fmt.Errorf("collector %s: duplicate field %s", def.Name, collector.field)
+		}
+		fields[collector.field] = struct{}{}
+		collectors = append(collectors, collector)
+	}
+	return collectors, nil
+}
+
+func newTemplateCollector(def CollectorConfig) (*templateCollector, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if def.Command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+
+	command, err := template.New(def.Name).
+		Funcs(template.FuncMap{"quote": shellQuote}).
+		Option("missingkey=error").
+		Parse(def.Command)
+	if err != nil {
+		return nil, fmt.Errorf("parse command: %v", err)
+	}
+
+	collector := &templateCollector{field: def.Field, command: command}
+	if collector.field == "" {
+		collector.field = def.Name
+	}
+
+	switch def.Parser {
+	case "", ParserRaw:
+		collector.parse = func(output string) (interface{}, error) {
+			return strings.TrimSpace(output), nil
+		}
+	case ParserLines:
+		collector.parse = parseLines
+	case ParserRegex:
+		if def.Pattern == "" {
+			return nil, fmt.Errorf("pattern is required for regex parser")
+		}
+		re, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v", err)
+		}
+		collector.parse = func(output string) (interface{}, error) {
+			return parseRegex(re, def.All, output)
+		}
+	case ParserJSON:
+		collector.parse = func(output string) (interface{}, error) {
+			return parseJSONPath(def.Path, output)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported parser: %s", def.Parser)
+	}
+	return collector, nil
+}
+
+// 按行拆分输出
+func parseLines(output string) (interface{}, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// 按正则提取：有命名分组时返回分组名到值的映射，只有一个分组时返回该分组，否则返回整个匹配
+func parseRegex(re *regexp.Regexp, all bool, output string) (interface{}, error) {
+	names := re.SubexpNames()
+	named := false
+	for _, name := range names {
+		if name != "" {
+			named = true
+			break
+		}
+	}
+
+	extract := func(match []string) interface{} {
+		switch {
+		case named:
+			fields := make(map[string]string)
+			for i, name := range names {
+				if name != "" {
+					fields[name] = match[i]
+				}
+			}
+			return fields
+		case len(match) == 2:
+			return match[1]
+		default:
+			return match[0]
+		}
+	}
+
+	if !all {
+		match := re.FindStringSubmatch(output)
+		if match == nil {
+			return nil, fmt.Errorf("pattern %q not matched", re.String())
+		}
+		return extract(match), nil
+	}
+
+	values := []interface{}{}
+	for _, match := range re.FindAllStringSubmatch(output, -1) {
+		values = append(values, extract(match))
+	}
+	return values, nil
+}
+
+// 解析 JSON 输出，path 以 . 分隔，数组使用下标
+func parseJSONPath(path, output string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return nil, fmt.Errorf("invalid json output: %v", err)
+	}
+	if path == "" {
+		return value, nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("path %s: key %s not found", path, key)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("path %s: invalid index %s", path, key)
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("path %s: cannot descend into %s", path, key)
+		}
+	}
+	return value, nil
+}
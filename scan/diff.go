@@ -0,0 +1,148 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// HostDiff 两次扫描之间单台主机的变化
+type HostDiff struct {
+	IP        string            `json:"ip"`
+	Group     string            `json:"group,omitempty"`
+	Status    string            `json:"status"` // added, removed, changed
+	OSBefore  string            `json:"os_before,omitempty"`
+	OSAfter   string            `json:"os_after,omitempty"`
+	KeyBefore string            `json:"host_key_before,omitempty"`
+	KeyAfter  string            `json:"host_key_after,omitempty"`
+	Added     map[string]string `json:"packages_added,omitempty"`
+	Removed   map[string]string `json:"packages_removed,omitempty"`
+	Changed   map[string]string `json:"packages_changed,omitempty"` // 名称 -> "旧版本 -> 新版本"
+}
+
+// LoadResultsJSONL 读取 JSON lines 格式的扫描结果
+func LoadResultsJSONL(filename string) ([]RemoteServer, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var results []RemoteServer
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var server RemoteServer
+		if err := json.Unmarshal(scanner.Bytes(), &server); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", filename, err)
+		}
+		results = append(results, server)
+	}
+	return results, scanner.Err()
+}
+
+// 从事实中取出软件包列表，兼容采集结果和从文件读回的结果
+func packagesOf(server RemoteServer) map[string]string {
+	result := make(map[string]string)
+	switch packages := server.Facts["packages"].(type) {
+	case []Package:
+		for _, p := range packages {
+			result[p.Name] = p.Version
+		}
+	case []interface{}:
+		for _, item := range packages {
+			if p, ok := item.(map[string]interface{}); ok {
+				name, _ := p["name"].(string)
+				version, _ := p["version"].(string)
+				result[name] = version
+			}
+		}
+	}
+	return result
+}
+
+// Diff 对比两次扫描的结果，只返回有变化的主机
+func Diff(before, after []RemoteServer) []HostDiff {
+	// 主机密钥不匹配时扫描失败，但仍然记录了密钥，需要保留下来报告密钥变化
+	index := func(results []RemoteServer) map[string]RemoteServer {
+		m := make(map[string]RemoteServer, len(results))
+		for _, server := range results {
+			if server.Success || server.HostKey != "" {
+				m[TargetKey(server.Group, server.IP)] = server
+			}
+		}
+		return m
+	}
+	old, cur := index(before), index(after)
+
+	var diffs []HostDiff
+	for _, key := range sortedKeys(cur) {
+		server := cur[key]
+		prev, ok := old[key]
+		if !ok {
+			diffs = append(diffs, HostDiff{IP: server.IP, Group: server.Group, Status: "added", OSAfter: server.OS.PrettyName})
+			continue
+		}
+		if diff, changed := diffHost(prev, server); changed {
+			diffs = append(diffs, diff)
+		}
+	}
+	for _, key := range sortedKeys(old) {
+		if _, ok := cur[key]; !ok {
+			server := old[key]
+			diffs = append(diffs, HostDiff{IP: server.IP, Group: server.Group, Status: "removed", OSBefore: server.OS.PrettyName})
+		}
+	}
+	return diffs
+}
+
+// 对比同一主机的两次结果
+func diffHost(before, after RemoteServer) (HostDiff, bool) {
+	diff := HostDiff{IP: after.IP, Group: after.Group, Status: "changed"}
+	changed := false
+
+	// 主机密钥变化可能意味着重装系统或中间人攻击
+	if before.HostKey != "" && after.HostKey != "" && before.HostKey != after.HostKey {
+		diff.KeyBefore, diff.KeyAfter = before.HostKey, after.HostKey
+		changed = true
+	}
+	// 失败的结果只有主机密钥，没有系统和软件包信息
+	if !before.Success || !after.Success {
+		return diff, changed
+	}
+
+	if before.OS.PrettyName != after.OS.PrettyName {
+		diff.OSBefore, diff.OSAfter = before.OS.PrettyName, after.OS.PrettyName
+		changed = true
+	}
+
+	oldPkgs, newPkgs := packagesOf(before), packagesOf(after)
+	for name, version := range newPkgs {
+		oldVersion, ok := oldPkgs[name]
+		switch {
+		case !ok:
+			setDiff(&diff.Added, name, version)
+		case oldVersion != version:
+			setDiff(&diff.Changed, name, oldVersion+" -> "+version)
+		}
+	}
+	for name, version := range oldPkgs {
+		if _, ok := newPkgs[name]; !ok {
+			setDiff(&diff.Removed, name, version)
+		}
+	}
+
+	changed = changed || len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.Changed) > 0
+	return diff, changed
+}
+
+func setDiff(m *map[string]string, key, value string) {
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[key] = value
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	host := func(key, os string, success bool, packages ...Package) RemoteServer {
+		s := RemoteServer{IP: "10.0.0.1", HostKey: key, Success: success, OS: OSRelease{PrettyName: os}}
+		if packages != nil {
+			s.Facts = map[string]interface{}{"packages": packages}
+		}
+		return s
+	}
+	tests := []struct {
+		name          string
+		before, after RemoteServer
+		want          []HostDiff
+	}{
+		{
+			name:   "unchanged",
+			before: host("SHA256:a", "Ubuntu 22.04", true, Package{"curl", "7.81"}),
+			after:  host("SHA256:a", "Ubuntu 22.04", true, Package{"curl", "7.81"}),
+		},
+		{
+			name:   "os and packages",
+			before: host("SHA256:a", "Ubuntu 22.04", true, Package{"curl", "7.81"}, Package{"vim", "8.2"}),
+			after:  host("SHA256:a", "Ubuntu 24.04", true, Package{"curl", "8.5"}, Package{"git", "2.43"}),
+			want: []HostDiff{{
+				IP: "10.0.0.1", Status: "changed", OSBefore: "Ubuntu 22.04", OSAfter: "Ubuntu 24.04",
+				Added: map[string]string{"git": "2.43"}, Removed: map[string]string{"vim": "8.2"}, Changed: map[string]string{"curl": "7.81 -> 8.5"},
+			}},
+		},
+		{
+			// 密钥不匹配时扫描失败，只比较密钥，不报告系统和软件包的变化
+			name:   "host key mismatch on a failed scan",
+			before: host("SHA256:a", "Ubuntu 22.04", true, Package{"curl", "7.81"}),
+			after:  host("SHA256:b", "", false),
+			want:   []HostDiff{{IP: "10.0.0.1", Status: "changed", KeyBefore: "SHA256:a", KeyAfter: "SHA256:b"}},
+		},
+		{
+			name:   "failed scan without a host key",
+			before: host("SHA256:a", "Ubuntu 22.04", true),
+			after:  host("", "", false),
+			want:   []HostDiff{{IP: "10.0.0.1", Status: "removed", OSBefore: "Ubuntu 22.04"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff([]RemoteServer{tt.before}, []RemoteServer{tt.after})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
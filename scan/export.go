@@ -0,0 +1,129 @@
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var invalidGroupChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// 将 OS 标识转换为合法的 Ansible 组名，例如 ubuntu 22.04 -> ubuntu_22_04
+func ansibleGroupName(parts ...string) string {
+	name := strings.ToLower(strings.Join(parts, "_"))
+	name = strings.Trim(invalidGroupChars.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// 主机名，优先使用采集到的 hostname
+func hostName(server RemoteServer) string {
+	if name, ok := server.Facts["hostname"].(string); ok && name != "" {
+		return name
+	}
+	return ""
+}
+
+// 保存为 Ansible INI inventory，按 OS 和版本分组，OS 组作为版本组的父组
+func saveResultsToAnsible(results []RemoteServer, filename string) error {
+	versionGroups := make(map[string][]RemoteServer)
+	osChildren := make(map[string]map[string]struct{})
+
+	for _, server := range results {
+		if !server.Success {
+			continue
+		}
+		osGroup := ansibleGroupName(server.OS.ID)
+		versionGroup := osGroup
+		if server.OS.VersionID != "" {
+			versionGroup = ansibleGroupName(server.OS.ID, server.OS.VersionID)
+		}
+		versionGroups[versionGroup] = append(versionGroups[versionGroup], server)
+		if versionGroup != osGroup {
+			if osChildren[osGroup] == nil {
+				osChildren[osGroup] = make(map[string]struct{})
+			}
+			osChildren[osGroup][versionGroup] = struct{}{}
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, group := range sortedKeys(versionGroups) {
+		fmt.Fprintf(writer, "[%s]\n", group)
+		for _, server := range versionGroups[group] {
+			host := hostName(server)
+			if host == "" {
+				host = server.IP
+			}
+			fmt.Fprintf(writer, "%s ansible_host=%s", host, server.IP)
+			if server.OS.PrettyName != "" {
+				fmt.Fprintf(writer, " os_pretty_name=%q", server.OS.PrettyName)
+			}
+			if server.Group != "" {
+				fmt.Fprintf(writer, " scan_group=%s", server.Group)
+			}
+			writer.WriteString("\n")
+		}
+		writer.WriteString("\n")
+	}
+
+	for _, group := range sortedKeys(osChildren) {
+		fmt.Fprintf(writer, "[%s:children]\n", group)
+		for _, child := range sortedKeys(osChildren[group]) {
+			fmt.Fprintf(writer, "%s\n", child)
+		}
+		writer.WriteString("\n")
+	}
+	return writer.Flush()
+}
+
+// 保存为 /etc/hosts 片段，没有采集到主机名时按 OS 和 IP 生成
+func saveResultsToHosts(results []RemoteServer, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	writer.WriteString("# generated by scan_os\n")
+	for _, server := range results {
+		if !server.Success {
+			continue
+		}
+		host := hostName(server)
+		if host == "" {
+			prefix := server.OS.ID
+			if prefix == "" {
+				prefix = "host"
+			}
+			host = prefix + "-" + strings.NewReplacer(".", "-", ":", "-").Replace(server.IP)
+		}
+		line := fmt.Sprintf("%-16s %s", server.IP, host)
+		if server.OS.PrettyName != "" {
+			line += " # " + server.OS.PrettyName
+		}
+		writer.WriteString(line + "\n")
+	}
+	return writer.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,271 @@
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CommandRunner 在目标主机上执行命令
+type CommandRunner interface {
+	Run(command string) (string, error)
+}
+
+// Collector 事实采集项，采集结果以 Name 为键合并到主机的 Facts 中
+type Collector interface {
+	Name() string
+	Collect(runner CommandRunner, target Target) (interface{}, error)
+}
+
+// CommandCollector 执行一条命令并解析输出的采集项
+type CommandCollector struct {
+	FactName string
+	Command  string
+	Parse    func(output string) (interface{}, error) // 为空时返回原始输出
+}
+
+func (c CommandCollector) Name() string {
+	return c.FactName
+}
+
+func (c CommandCollector) Collect(runner CommandRunner, target Target) (interface{}, error) {
+	output, err := runner.Run(c.Command)
+	if err != nil {
+		return nil, err
+	}
+	if c.Parse == nil {
+		return output, nil
+	}
+	return c.Parse(output)
+}
+
+// 内置的事实采集项
+var builtinCollectors = map[string]Collector{
+	"hostname": CommandCollector{FactName: "hostname", Command: "hostname", Parse: parseRaw},
+	"kernel":   CommandCollector{FactName: "kernel", Command: "uname -r", Parse: parseRaw},
+	"cpu":      CommandCollector{FactName: "cpu", Command: "cat /proc/cpuinfo", Parse: parseCPUInfo},
+	"memory":   CommandCollector{FactName: "memory", Command: "free -b", Parse: parseFree},
+	"disk":     CommandCollector{FactName: "disk", Command: "df -P -k", Parse: parseDF},
+	"uptime":   CommandCollector{FactName: "uptime", Command: "cat /proc/uptime", Parse: parseUptime},
+	"network":  CommandCollector{FactName: "network", Command: "ip -o addr show", Parse: parseIPAddr},
+
+	"containers": containerCollector{},
+
+	// 已安装软件包、运行中的容器和 Pod，默认不采集
+	"packages":  CommandCollector{FactName: "packages", Command: packagesCommand, Parse: parsePackages},
+	"workloads": workloadCollector{},
+}
+
+// 根据系统中存在的包管理器列出已安装软件包，每行为 名称\t版本
+const packagesCommand = `if command -v rpm >/dev/null 2>&1; then rpm -qa --qf '%{NAME}\t%{VERSION}-%{RELEASE}\n'; ` +
+	`elif command -v dpkg-query >/dev/null 2>&1; then dpkg-query -W -f='${Package}\t${Version}\n'; ` +
+	`else echo 'no supported package manager' >&2; exit 1; fi`
+
+// Package 已安装的软件包
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// DefaultFacts 默认采集的事实
+var DefaultFacts = []string{"hostname", "kernel", "cpu", "memory", "disk", "uptime", "network", "containers"}
+
+// BuiltinFacts 所有内置事实名称
+func BuiltinFacts() []string {
+	var names []string
+	for name := range builtinCollectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFacts 按名称解析内置采集项列表，例如 "kernel,cpu"
+func ParseFacts(facts string) ([]Collector, error) {
+	var result []Collector
+	for _, f := range strings.Split(facts, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		collector, ok := builtinCollectors[f]
+		if !ok {
+			return nil, fmt.Errorf("unknown fact: %s", f)
+		}
+		result = append(result, collector)
+	}
+	return result, nil
+}
+
+// 依次执行采集项，单项失败不影响其他项
+func collectFacts(runner CommandRunner, target Target, collectors []Collector) (map[string]interface{}, map[string]string) {
+	if len(collectors) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{}, len(collectors))
+	errs := make(map[string]string)
+	for _, collector := range collectors {
+		value, err := collector.Collect(runner, target)
+		if err != nil {
+			errs[collector.Name()] = err.Error()
+			continue
+		}
+		result[collector.Name()] = value
+	}
+
+	if len(errs) == 0 {
+		errs = nil
+	}
+	return result, errs
+}
+
+// 原样返回命令输出
+func parseRaw(output string) (interface{}, error) {
+	return output, nil
+}
+
+// 解析 /proc/cpuinfo，统计型号、逻辑核数和物理CPU数
+func parseCPUInfo(output string) (interface{}, error) {
+	var model string
+	processors := 0
+	sockets := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "processor":
+			processors++
+		case "model name":
+			if model == "" {
+				model = value
+			}
+		case "physical id":
+			sockets[value] = struct{}{}
+		}
+	}
+	if processors == 0 {
+		return nil, fmt.Errorf("no processor found in cpuinfo")
+	}
+
+	return map[string]interface{}{
+		"model":   model,
+		"cores":   processors,
+		"sockets": max(len(sockets), 1),
+	}, nil
+}
+
+// 解析 free -b 输出中的 Mem 和 Swap 行，单位为字节
+func parseFree(output string) (interface{}, error) {
+	result := make(map[string]interface{})
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		switch fields[0] {
+		case "Mem:":
+			result["total"], _ = strconv.ParseInt(fields[1], 10, 64)
+			result["used"], _ = strconv.ParseInt(fields[2], 10, 64)
+			result["free"], _ = strconv.ParseInt(fields[3], 10, 64)
+			if len(fields) >= 7 {
+				result["available"], _ = strconv.ParseInt(fields[6], 10, 64)
+			}
+		case "Swap:":
+			result["swap_total"], _ = strconv.ParseInt(fields[1], 10, 64)
+			result["swap_used"], _ = strconv.ParseInt(fields[2], 10, 64)
+		}
+	}
+	if _, ok := result["total"]; !ok {
+		return nil, fmt.Errorf("no Mem line found in free output")
+	}
+	return result, nil
+}
+
+// 解析 df -P -k 输出，容量单位转换为字节
+func parseDF(output string) (interface{}, error) {
+	var disks []map[string]interface{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		used, _ := strconv.ParseInt(fields[2], 10, 64)
+		avail, _ := strconv.ParseInt(fields[3], 10, 64)
+		disks = append(disks, map[string]interface{}{
+			"filesystem":  fields[0],
+			"size":        size * 1024,
+			"used":        used * 1024,
+			"available":   avail * 1024,
+			"use_percent": strings.TrimSuffix(fields[4], "%"),
+			"mount":       strings.Join(fields[5:], " "),
+		})
+	}
+	return disks, nil
+}
+
+// 解析 /proc/uptime，返回开机秒数
+func parseUptime(output string) (interface{}, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty uptime output")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uptime: %s", fields[0])
+	}
+	return map[string]interface{}{"seconds": int64(seconds)}, nil
+}
+
+// 解析 ip -o addr show 输出，返回网卡地址列表
+func parseIPAddr(output string) (interface{}, error) {
+	var addrs []map[string]interface{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		// 2: eth0    inet 192.168.33.10/24 brd 192.168.33.255 scope global eth0
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		addrs = append(addrs, map[string]interface{}{
+			"interface": fields[1],
+			"family":    fields[2],
+			"address":   fields[3],
+		})
+	}
+	return addrs, nil
+}
+
+// 解析软件包列表，每行为 名称\t版本
+func parsePackages(output string) (interface{}, error) {
+	var packages []Package
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, version, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "\t")
+		if !ok || name == "" {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: version})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages, nil
+}
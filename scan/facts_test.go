@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFactOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) (interface{}, error)
+		output  string
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:  "cpuinfo with two sockets",
+			parse: parseCPUInfo,
+			output: "processor\t: 0\nmodel name\t: Intel(R) Xeon(R) Gold 6230\nphysical id\t: 0\n\n" +
+				"processor\t: 1\nmodel name\t: Intel(R) Xeon(R) Gold 6230\nphysical id\t: 1\n\n" +
+				"processor\t: 2\nmodel name\t: Intel(R) Xeon(R) Gold 6230\nphysical id\t: 1\n",
+			want: map[string]interface{}{"model": "Intel(R) Xeon(R) Gold 6230", "cores": 3, "sockets": 2},
+		},
+		{
+			// 虚拟机和 ARM 上可能没有 physical id
+			name:   "cpuinfo without physical id",
+			parse:  parseCPUInfo,
+			output: "processor\t: 0\nBogoMIPS\t: 50.00\n",
+			want:   map[string]interface{}{"model": "", "cores": 1, "sockets": 1},
+		},
+		{name: "empty cpuinfo", parse: parseCPUInfo, output: "", wantErr: true},
+		{
+			name:  "free",
+			parse: parseFree,
+			output: "               total        used        free      shared  buff/cache   available\n" +
+				"Mem:      8000000000  3000000000  1000000000    10000000  4000000000  4500000000\n" +
+				"Swap:     2000000000   100000000  1900000000\n",
+			want: map[string]interface{}{
+				"total": int64(8000000000), "used": int64(3000000000), "free": int64(1000000000), "available": int64(4500000000),
+				"swap_total": int64(2000000000), "swap_used": int64(100000000),
+			},
+		},
+		{
+			// 旧版本的 free 没有 available 列
+			name:   "free without available",
+			parse:  parseFree,
+			output: "             total       used       free     shared    buffers     cached\nMem:          1024        512        512          0\n",
+			want:   map[string]interface{}{"total": int64(1024), "used": int64(512), "free": int64(512)},
+		},
+		{name: "free without Mem line", parse: parseFree, output: "Swap: 0 0 0\n", wantErr: true},
+		{
+			name:  "df with spaces in the mount point",
+			parse: parseDF,
+			output: "Filesystem     1024-blocks     Used Available Capacity Mounted on\n" +
+				"/dev/sda1         10000000  4000000   6000000      40% /\n" +
+				"/dev/sdb1             2048     1024      1024      50% /mnt/my disk\n",
+			want: []map[string]interface{}{
+				{"filesystem": "/dev/sda1", "size": int64(10240000000), "used": int64(4096000000), "available": int64(6144000000), "use_percent": "40", "mount": "/"},
+				{"filesystem": "/dev/sdb1", "size": int64(2097152), "used": int64(1048576), "available": int64(1048576), "use_percent": "50", "mount": "/mnt/my disk"},
+			},
+		},
+		{name: "uptime", parse: parseUptime, output: "12345.67 45678.90\n", want: map[string]interface{}{"seconds": int64(12345)}},
+		{name: "invalid uptime", parse: parseUptime, output: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 按命令返回固定输出的 CommandRunner
+type fakeRunner map[string]string
+
+func (r fakeRunner) Run(command string) (string, error) {
+	if output, ok := r[command]; ok {
+		return output, nil
+	}
+	return "", errors.New("command not found: " + command)
+}
+
+func TestCollectFacts(t *testing.T) {
+	collectors, err := ParseFacts(" Kernel, uptime,memory ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := fakeRunner{
+		"uname -r":         "5.15.0-91-generic",
+		"cat /proc/uptime": "3600.5 100.0",
+	}
+	facts, errs := collectFacts(runner, Target{}, collectors)
+	want := map[string]interface{}{"kernel": "5.15.0-91-generic", "uptime": map[string]interface{}{"seconds": int64(3600)}}
+	if !reflect.DeepEqual(facts, want) {
+		t.Fatalf("facts = %#v, want %#v", facts, want)
+	}
+	// 单项失败只记录错误，不影响其他项
+	if len(errs) != 1 || errs["memory"] == "" {
+		t.Fatalf("errs = %v, want only memory to fail", errs)
+	}
+	if _, err := ParseFacts("kernel,gpu"); err == nil {
+		t.Fatal("ParseFacts accepted an unknown fact")
+	}
+}
@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// 主机密钥校验方式
+const (
+	HostKeyInsecure = "insecure" // 不校验，仅记录指纹
+	HostKeyStrict   = "strict"   // 必须存在于 known_hosts 中且一致
+	HostKeyTOFU     = "tofu"     // 首次连接时记录，之后必须一致
+)
+
+// NewHostKeyCallback 创建主机密钥校验回调
+func NewHostKeyCallback(mode, knownHostsFile string) (ssh.HostKeyCallback, error) {
+	switch mode {
+	case "", HostKeyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case HostKeyStrict:
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("load known_hosts: %v", err)
+		}
+		return callback, nil
+	case HostKeyTOFU:
+		return newTOFUCallback(knownHostsFile)
+	default:
+		return nil, fmt.Errorf("unsupported host key mode: %s", mode)
+	}
+}
+
+// tofuStore 首次信任模式下记录新主机的密钥
+type tofuStore struct {
+	mu       sync.Mutex
+	path     string
+	known    ssh.HostKeyCallback
+	recorded map[string]ssh.PublicKey
+}
+
+func newTOFUCallback(knownHostsFile string) (ssh.HostKeyCallback, error) {
+	if err := os.MkdirAll(filepath.Dir(knownHostsFile), 0o700); err != nil {
+		return nil, err
+	}
+	// 文件不存在时创建空文件
+	file, err := os.OpenFile(knownHostsFile, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	known, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts: %v", err)
+	}
+	store := &tofuStore{path: knownHostsFile, known: known, recorded: make(map[string]ssh.PublicKey)}
+	return store.check, nil
+}
+
+func (s *tofuStore) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := s.known(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if err == nil || !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+		// 已知且一致、其他错误或密钥已变化
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	address := knownhosts.Normalize(hostname)
+	if recorded, ok := s.recorded[address]; ok {
+		if string(recorded.Marshal()) != string(key.Marshal()) {
+			return fmt.Errorf("host key changed for %s", address)
+		}
+		return nil
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(knownhosts.Line([]string{address}, key) + "\n"); err != nil {
+		return err
+	}
+	s.recorded[address] = key
+	return nil
+}
+
+// 包装校验回调，记录对端密钥指纹
+func recordHostKey(callback ssh.HostKeyCallback, fingerprint *string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		*fingerprint = ssh.FingerprintSHA256(key)
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key mismatch for %s, got %s", hostname, *fingerprint)
+		}
+		return err
+	}
+}
+
+// DefaultKnownHostsFile 默认的 known_hosts 路径
+func DefaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "known_hosts"
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
@@ -0,0 +1,53 @@
+package scan
+
+import (
+	"bufio"
+	"strings"
+)
+
+// OSRelease /etc/os-release 中的常用字段
+type OSRelease struct {
+	ID         string `json:"id"`
+	IDLike     string `json:"id_like,omitempty"`
+	Name       string `json:"name,omitempty"`
+	VersionID  string `json:"version_id"`
+	PrettyName string `json:"pretty_name"`
+}
+
+// 解析 /etc/os-release 内容，格式为 KEY=VALUE，值可能带引号
+func parseOSRelease(content string) OSRelease {
+	fields := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = unquoteOSReleaseValue(strings.TrimSpace(value))
+	}
+
+	return OSRelease{
+		ID:         fields["ID"],
+		IDLike:     fields["ID_LIKE"],
+		Name:       fields["NAME"],
+		VersionID:  fields["VERSION_ID"],
+		PrettyName: fields["PRETTY_NAME"],
+	}
+}
+
+// 去掉值两端的引号并处理转义字符
+func unquoteOSReleaseValue(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			value = value[1 : len(value)-1]
+		}
+	}
+	replacer := strings.NewReplacer(`\"`, `"`, `\'`, `'`, `\\`, `\`, "\\`", "`", `\$`, `$`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,214 @@
+package scan
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ResultSink 扫描结果输出，每台主机扫描完成后调用 Write，全部完成后调用 Close
+type ResultSink interface {
+	Write(server RemoteServer) error
+	Close() error
+}
+
+// 支持的输出格式
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatAnsible = "ansible" // Ansible INI inventory
+	FormatHosts   = "hosts"   // /etc/hosts 片段
+)
+
+// 各输出格式对应的文件扩展名
+var formatExtensions = map[string]string{
+	FormatJSONL:   "jsonl",
+	FormatCSV:     "csv",
+	FormatAnsible: "ini",
+	FormatHosts:   "hosts",
+}
+
+// ParseFormats 解析输出格式列表，例如 "jsonl,csv"
+func ParseFormats(formats string) ([]string, error) {
+	var result []string
+	for _, f := range strings.Split(formats, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if _, ok := formatExtensions[f]; !ok {
+			return nil, fmt.Errorf("unsupported output format: %s", f)
+		}
+		result = append(result, f)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no output format specified")
+	}
+	return result, nil
+}
+
+// NewFileSink 按格式创建文件输出，文件名为 output 加上格式对应的扩展名。
+// appendMode 为 true 时在已有文件后追加，用于继续中断的扫描；需要汇总的格式会先读回同名 jsonl 文件中的结果
+func NewFileSink(format, output string, appendMode bool) (ResultSink, string, error) {
+	ext, ok := formatExtensions[format]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported output format: %s", format)
+	}
+	filename := fmt.Sprintf("%s.%s", output, ext)
+
+	switch format {
+	case FormatAnsible, FormatHosts:
+		save := saveResultsToAnsible
+		if format == FormatHosts {
+			save = saveResultsToHosts
+		}
+		sink := &collectSink{flush: func(results []RemoteServer) error {
+			return save(results, filename)
+		}}
+		if appendMode {
+			previous, err := LoadResultsJSONL(fmt.Sprintf("%s.%s", output, formatExtensions[FormatJSONL]))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, "", err
+			}
+			for _, server := range previous {
+				sink.Write(server)
+			}
+		}
+		return sink, filename, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, "", err
+	}
+	if format == FormatCSV {
+		sink := &csvSink{file: file, writer: csv.NewWriter(file)}
+		// 追加到已有文件时不重复写表头
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			if err := sink.writer.Write(csvHeader); err != nil {
+				file.Close()
+				return nil, "", err
+			}
+		}
+		return sink, filename, nil
+	}
+	return &jsonlSink{file: file, encoder: json.NewEncoder(file)}, filename, nil
+}
+
+// NewFileSinks 为每种格式创建文件输出，返回合并后的输出和文件列表
+func NewFileSinks(output string, formats []string, appendMode bool) (ResultSink, []string, error) {
+	var sinks MultiSink
+	var files []string
+	for _, format := range formats {
+		sink, filename, err := NewFileSink(format, output, appendMode)
+		if err != nil {
+			sinks.Close()
+			return nil, nil, fmt.Errorf("create %s: %v", filename, err)
+		}
+		sinks = append(sinks, sink)
+		files = append(files, filename)
+	}
+	return sinks, files, nil
+}
+
+// WriteAll 写入全部结果并关闭输出
+func WriteAll(sink ResultSink, results []RemoteServer) error {
+	for _, server := range results {
+		if err := sink.Write(server); err != nil {
+			sink.Close()
+			return err
+		}
+	}
+	return sink.Close()
+}
+
+// MultiSink 将结果同时写入多个输出
+type MultiSink []ResultSink
+
+func (m MultiSink) Write(server RemoteServer) error {
+	for _, sink := range m {
+		if err := sink.Write(server); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MultiSink) Close() error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// jsonlSink 保存结果为 JSON lines，每行一个主机，写入后立即落盘以配合检查点
+type jsonlSink struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func (s *jsonlSink) Write(server RemoteServer) error {
+	return s.encoder.Encode(server)
+}
+
+func (s *jsonlSink) Close() error {
+	return s.file.Close()
+}
+
+var csvHeader = []string{"ip", "success", "id", "version_id", "pretty_name", "error"}
+
+// csvSink 保存结果为 CSV，便于导入 BI 工具
+type csvSink struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func (s *csvSink) Write(server RemoteServer) error {
+	err := s.writer.Write([]string{
+		server.IP,
+		strconv.FormatBool(server.Success),
+		server.OS.ID,
+		server.OS.VersionID,
+		server.OS.PrettyName,
+		server.Error,
+	})
+	if err != nil {
+		return err
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	return errors.Join(s.writer.Error(), s.file.Close())
+}
+
+// collectSink 需要全部结果才能输出的格式（按组汇总），在 Close 时一次写入。
+// 只保留汇总需要的字段，避免大规模扫描时占用过多内存
+type collectSink struct {
+	results []RemoteServer
+	flush   func(results []RemoteServer) error
+}
+
+func (s *collectSink) Write(server RemoteServer) error {
+	summary := RemoteServer{IP: server.IP, Group: server.Group, OS: server.OS, Success: server.Success}
+	if hostname, ok := server.Facts["hostname"]; ok {
+		summary.Facts = map[string]interface{}{"hostname": hostname}
+	}
+	s.results = append(s.results, summary)
+	return nil
+}
+
+func (s *collectSink) Close() error {
+	return s.flush(s.results)
+}
@@ -0,0 +1,221 @@
+package scan
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 扫描模式
+const (
+	ModeSSH   = "ssh"   // 登录主机采集信息
+	ModePorts = "ports" // 无需凭据，端口扫描并识别服务
+)
+
+// 端口扫描模式默认扫描的端口
+var defaultPorts = []int{21, 22, 23, 25, 80, 135, 443, 445, 3306, 3389, 5432, 6379, 8080, 9200, 27017}
+
+// PortInfo 单个端口的扫描结果
+type PortInfo struct {
+	Port    int    `json:"port"`
+	Service string `json:"service,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+	Server  string `json:"server,omitempty"` // HTTP Server 头
+}
+
+// 常见端口对应的服务名
+var wellKnownServices = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 80: "http", 135: "msrpc",
+	443: "https", 445: "smb", 3306: "mysql", 3389: "rdp", 5432: "postgresql",
+	6379: "redis", 8080: "http", 8443: "https", 9200: "elasticsearch", 27017: "mongodb",
+}
+
+// 使用 HTTP 探测的端口
+var httpPorts = map[int]bool{80: true, 8000: true, 8080: true, 9200: true}
+var httpsPorts = map[int]bool{443: true, 8443: true}
+
+// ParsePorts 解析端口列表，例如 "22,80,8000-8010"
+func ParsePorts(ports string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(ports, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(startStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(endStr); err != nil {
+				return nil, fmt.Errorf("invalid port: %s", part)
+			}
+		}
+		if start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("invalid port range: %s", part)
+		}
+		for p := start; p <= end; p++ {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// 扫描主机的端口列表，抓取 banner 并推测操作系统
+func scanPorts(target Target) RemoteServer {
+	server := RemoteServer{IP: target.IP, Group: target.Group, Protocol: ModePorts}
+
+	ports := target.Ports
+	if len(ports) == 0 {
+		ports = defaultPorts
+	}
+
+	for _, port := range ports {
+		info, ok := probePort(target.IP, port, target.SSH)
+		if ok {
+			server.Ports = append(server.Ports, info)
+		}
+	}
+
+	if len(server.Ports) == 0 {
+		server.Error = "no open ports"
+		return server
+	}
+	server.Success = true
+	server.OS = guessOS(server.Ports)
+	return server
+}
+
+// 探测单个端口，返回端口是否开放及识别到的信息
+func probePort(ip string, port int, config SSHConfig) (PortInfo, bool) {
+	info := PortInfo{Port: port, Service: wellKnownServices[port]}
+
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	conn, err := dialTCP(address, config)
+	if err != nil {
+		return info, false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	switch {
+	case httpsPorts[port]:
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: ip})
+		info.Server = httpServerHeader(tlsConn, ip)
+	case httpPorts[port]:
+		info.Server = httpServerHeader(conn, ip)
+	default:
+		// ssh/ftp/smtp/mysql 等协议会在连接后主动发送 banner
+		buf := make([]byte, 256)
+		n, _ := conn.Read(buf)
+		info.Banner = sanitizeBanner(buf[:n])
+		if n == 0 {
+			// 没有主动发送 banner 的端口再尝试一次 HTTP 探测
+			info.Server = probeHTTP(address, ip, config)
+			if info.Server != "" && info.Service == "" {
+				info.Service = "http"
+			}
+		}
+	}
+
+	if service := serviceFromBanner(info); service != "" {
+		info.Service = service
+	}
+	return info, true
+}
+
+// 新建连接进行 HTTP 探测
+func probeHTTP(address, host string, config SSHConfig) string {
+	conn, err := dialTCP(address, config)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	return httpServerHeader(conn, host)
+}
+
+// 发送 HEAD 请求并读取 Server 响应头
+func httpServerHeader(conn net.Conn, host string) string {
+	fmt.Fprintf(conn, "HEAD / HTTP/1.0\r\nHost: %s\r\nUser-Agent: scan_os\r\n\r\n", host)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Server")
+}
+
+// 只保留 banner 中可打印的第一行
+func sanitizeBanner(b []byte) string {
+	line, _, _ := strings.Cut(string(b), "\n")
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r > 126 {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+// 根据 banner 识别服务
+func serviceFromBanner(info PortInfo) string {
+	switch {
+	case strings.HasPrefix(info.Banner, "SSH-"):
+		return "ssh"
+	case strings.HasPrefix(info.Banner, "220") && strings.Contains(strings.ToUpper(info.Banner), "FTP"):
+		return "ftp"
+	case strings.HasPrefix(info.Banner, "220") && strings.Contains(strings.ToUpper(info.Banner), "SMTP"):
+		return "smtp"
+	case strings.Contains(strings.ToLower(info.Banner), "mysql") || strings.Contains(info.Banner, "MariaDB"):
+		return "mysql"
+	}
+	return ""
+}
+
+// OS 识别规则，按顺序匹配 banner 或 Server 头中的关键字
+var osSignatures = []struct {
+	keyword string
+	id      string
+	name    string
+}{
+	{"Ubuntu", "ubuntu", "Ubuntu"},
+	{"Debian", "debian", "Debian"},
+	{"Raspbian", "raspbian", "Raspbian"},
+	{"CentOS", "centos", "CentOS"},
+	{"Red Hat", "rhel", "Red Hat Enterprise Linux"},
+	{".el7", "rhel", "RHEL 7 family"},
+	{".el8", "rhel", "RHEL 8 family"},
+	{".el9", "rhel", "RHEL 9 family"},
+	{"FreeBSD", "freebsd", "FreeBSD"},
+	{"Microsoft", "windows", "Windows"},
+	{"Win64", "windows", "Windows"},
+	{"Win32", "windows", "Windows"},
+}
+
+// 根据开放端口和 banner 推测操作系统
+func guessOS(ports []PortInfo) OSRelease {
+	for _, port := range ports {
+		for _, text := range []string{port.Banner, port.Server} {
+			for _, sig := range osSignatures {
+				if strings.Contains(text, sig.keyword) {
+					return OSRelease{ID: sig.id, Name: sig.name, PrettyName: sig.name + " (guessed)"}
+				}
+			}
+		}
+	}
+
+	// 只有 Windows 常开的端口
+	for _, port := range ports {
+		if port.Port == 3389 || port.Port == 135 || port.Port == 445 {
+			return OSRelease{ID: "windows", Name: "Windows", PrettyName: "Windows (guessed)"}
+		}
+	}
+	return OSRelease{}
+}
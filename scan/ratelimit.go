@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// 按固定间隔放行，rate 为每秒放行次数
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// 等待下一个放行时刻，ctx 取消时返回 false
+func (l *limiter) wait(ctx context.Context) bool {
+	if l == nil {
+		return ctx.Err() == nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// 每个子网一个限速器，避免集中访问同一网段的管理网络
+type subnetLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	mask     net.IPMask
+	limiters map[string]*limiter
+}
+
+func newSubnetLimiter(rate float64, prefix int) *subnetLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if prefix <= 0 || prefix > 32 {
+		prefix = 24
+	}
+	return &subnetLimiter{rate: rate, mask: net.CIDRMask(prefix, 32), limiters: make(map[string]*limiter)}
+}
+
+func (s *subnetLimiter) wait(ctx context.Context, ip string) bool {
+	if s == nil {
+		return ctx.Err() == nil
+	}
+
+	subnet := ip
+	if parsed := net.ParseIP(ip).To4(); parsed != nil {
+		subnet = parsed.Mask(s.mask).String()
+	}
+
+	s.mu.Lock()
+	l, ok := s.limiters[subnet]
+	if !ok {
+		l = newLimiter(s.rate)
+		s.limiters[subnet] = l
+	}
+	s.mu.Unlock()
+	return l.wait(ctx)
+}
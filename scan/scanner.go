@@ -0,0 +1,374 @@
+// Package scan 通过 SSH 或端口探测采集主机信息，命令行入口为 mockgo scan。
+// 其他程序可以使用 Scanner 扫描目标，并通过 TargetSource、HostScanner、Collector 和 ResultSink
+// 替换目标来源、单台主机的扫描方式、采集项和结果输出。
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHConfig 的超时为 0 时使用的默认值
+const (
+	DefaultTimeout     = time.Second
+	DefaultHostTimeout = 10 * time.Second
+)
+
+// HostScanner 扫描单台主机。*Scanner 按 Mode 通过 SSH、端口或 SNMP 实现，
+// 调用方可以设置 Scanner.Host 替换或包装它，例如接入自己的采集方式或记录指标
+type HostScanner interface {
+	ScanTarget(target Target) RemoteServer
+}
+
+// HostScannerFunc 把函数当作 HostScanner 使用
+type HostScannerFunc func(target Target) RemoteServer
+
+// ScanTarget 调用 f(target)
+func (f HostScannerFunc) ScanTarget(target Target) RemoteServer {
+	return f(target)
+}
+
+// TargetSource 提供扫描目标，调用方可以从 CMDB 等来源生成目标
+type TargetSource interface {
+	Targets() ([]Target, error)
+}
+
+// TargetList 固定的目标列表
+type TargetList []Target
+
+// Targets 返回列表本身
+func (l TargetList) Targets() ([]Target, error) {
+	return l, nil
+}
+
+// ConfigTargets 按扫描配置展开目标组，Base 为命令行等给出的基础 SSH 配置
+type ConfigTargets struct {
+	Config *Config
+	Base   SSHConfig
+}
+
+// Targets 展开配置中的所有目标组
+func (c ConfigTargets) Targets() ([]Target, error) {
+	return c.Config.Targets(c.Base)
+}
+
+// Scanner 并发扫描一组目标
+type Scanner struct {
+	Mode        string      // ModeSSH 或 ModePorts，默认 ModeSSH
+	Collectors  []Collector // SSH 模式下在主机上执行的事实采集项
+	Concurrency int         // 最大并发数，默认 20
+	Host        HostScanner // 扫描单台主机，为空时按 Mode 扫描
+
+	// 限速和打散顺序，避免触发 IDS 端口扫描告警或压垮管理网络
+	Rate         float64 // 全局每秒最多开始扫描的主机数，0 表示不限制
+	SubnetRate   float64 // 同一子网每秒最多开始扫描的主机数，0 表示不限制
+	SubnetPrefix int     // 按该前缀长度划分子网，默认 24
+	Shuffle      bool    // 随机打乱扫描顺序
+}
+
+// NewScanner 创建扫描器
+func NewScanner(mode string, collectors []Collector) *Scanner {
+	return &Scanner{Mode: mode, Collectors: collectors, Concurrency: 20}
+}
+
+// ScanSource 从 source 取得目标后并发扫描，取目标失败时返回错误
+func (s *Scanner) ScanSource(ctx context.Context, source TargetSource, onResult func(RemoteServer)) error {
+	targets, err := source.Targets()
+	if err != nil {
+		return err
+	}
+	s.ScanContext(ctx, targets, onResult)
+	return nil
+}
+
+// Scan 并发扫描所有目标，每得到一台主机的结果调用一次 onResult（在调用方的 goroutine 中）
+func (s *Scanner) Scan(targets []Target, onResult func(RemoteServer)) {
+	s.ScanContext(context.Background(), targets, onResult)
+}
+
+// ScanContext 同 Scan，ctx 取消后不再开始新的目标，等待已开始的目标完成后返回。
+// 只启动 Concurrency 个 worker，内存占用与目标数量无关
+func (s *Scanner) ScanContext(ctx context.Context, targets []Target, onResult func(RemoteServer)) {
+	// 限制并发数，避免过多连接
+	workers := s.Concurrency
+	if workers <= 0 {
+		workers = 20
+	}
+
+	if s.Shuffle {
+		targets = slices.Clone(targets)
+		rand.Shuffle(len(targets), func(i, j int) {
+			targets[i], targets[j] = targets[j], targets[i]
+		})
+	}
+	var host HostScanner = s
+	if s.Host != nil {
+		host = s.Host
+	}
+	global := newLimiter(s.Rate)
+	subnets := newSubnetLimiter(s.SubnetRate, s.SubnetPrefix)
+
+	jobs := make(chan Target)
+	results := make(chan RemoteServer, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				// 中断时丢弃还在等待限速的目标，它们仍会留在检查点的待扫描列表中
+				if !subnets.wait(ctx, target.IP) || !global.wait(ctx) {
+					continue
+				}
+				results <- host.ScanTarget(target)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, target := range targets {
+			select {
+			case jobs <- target:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// 等待所有worker完成
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for server := range results {
+		onResult(server)
+	}
+}
+
+// ScanTarget 按 Mode 扫描单台主机，不使用 Host。SSH 超时为 0 时使用默认值
+func (s *Scanner) ScanTarget(target Target) RemoteServer {
+	if target.SSH.Timeout <= 0 {
+		target.SSH.Timeout = DefaultTimeout
+	}
+	if target.SSH.HostTimeout <= 0 {
+		target.SSH.HostTimeout = DefaultHostTimeout
+	}
+	switch s.Mode {
+	case ModePorts:
+		return scanPorts(target)
+	case ModeSNMP:
+		if target.SNMP == nil {
+			target.SNMP = &SNMPConfig{}
+		}
+		return collectSNMP(target)
+	}
+
+	// 先检查主机是否可达
+	var server RemoteServer
+	if isHostReachable(target.IP, target.SSH) {
+		server = getOSInfo(target, s.Collectors)
+	} else {
+		server = RemoteServer{
+			IP:      target.IP,
+			Group:   target.Group,
+			Success: false,
+			Error:   "Host unreachable",
+		}
+	}
+
+	// 没有 SSH 的网络设备等通过 SNMP 补充采集
+	if !server.Success && target.SNMP != nil {
+		fallback := collectSNMP(target)
+		if fallback.Success {
+			return fallback
+		}
+		server.Error += "; " + fallback.Error
+	}
+	return server
+}
+
+// SSHConfig 包含SSH连接配置
+type SSHConfig struct {
+	Username    string
+	Password    string
+	Port        int
+	Timeout     time.Duration // 建立连接的超时，默认 DefaultTimeout
+	HostTimeout time.Duration // 单台主机采集的总超时，默认 DefaultHostTimeout
+	Bastion     *BastionConfig
+	Become      *BecomeConfig
+
+	Credentials     []Credential  // 按顺序尝试的凭据，为空时使用 Username/Password
+	MaxAuthAttempts int           // 每台主机最多认证失败次数，默认 3
+	AuthDelay       time.Duration // 同一主机两次认证之间的间隔
+
+	HostKeyCallback ssh.HostKeyCallback // 为空时不校验主机密钥
+}
+
+// RemoteServer 表示远程服务器信息
+type RemoteServer struct {
+	IP         string    `json:"ip"`
+	Group      string    `json:"group,omitempty"`
+	Protocol   string    `json:"protocol,omitempty"`   // 采集方式：ssh、ports 或 snmp
+	Credential string    `json:"credential,omitempty"` // 登录成功的凭据名称
+	HostKey    string    `json:"host_key,omitempty"`   // SSH 主机密钥 SHA256 指纹
+	OSInfo     string    `json:"-"`
+	OS         OSRelease `json:"os"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+
+	Facts      map[string]interface{} `json:"facts,omitempty"`
+	FactErrors map[string]string      `json:"fact_errors,omitempty"`
+	Ports      []PortInfo             `json:"ports,omitempty"`
+}
+
+// ParseIPRange 解析IP范围，支持第三、第四位都包含范围
+func ParseIPRange(ipRange string) ([]string, error) {
+	parts := strings.Split(ipRange, ".")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid IP range format")
+	}
+
+	// 解析每个部分的范围
+	var ranges [4][]int
+	for i, part := range parts {
+		if strings.Contains(part, "-") {
+			rangeParts := strings.Split(part, "-")
+			if len(rangeParts) != 2 {
+				return nil, fmt.Errorf("invalid range in part %d: %s", i, part)
+			}
+
+			start, err := strconv.Atoi(rangeParts[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid start value in part %d: %s", i, rangeParts[0])
+			}
+
+			end, err := strconv.Atoi(rangeParts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid end value in part %d: %s", i, rangeParts[1])
+			}
+
+			if start > end {
+				return nil, fmt.Errorf("start cannot be greater than end in part %d", i)
+			}
+
+			for j := start; j <= end; j++ {
+				ranges[i] = append(ranges[i], j)
+			}
+		} else {
+			// 单个值
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in part %d: %s", i, part)
+			}
+			ranges[i] = []int{value}
+		}
+	}
+
+	// 生成所有IP地址组合
+	var ips []string
+	for _, a := range ranges[0] {
+		for _, b := range ranges[1] {
+			for _, c := range ranges[2] {
+				for _, d := range ranges[3] {
+					// 验证IP地址各部分的有效性
+					if a >= 0 && a <= 255 && b >= 0 && b <= 255 &&
+						c >= 0 && c <= 255 && d >= 0 && d <= 255 {
+						ip := fmt.Sprintf("%d.%d.%d.%d", a, b, c, d)
+						ips = append(ips, ip)
+					} else {
+						return nil, fmt.Errorf("invalid IP address: %d.%d.%d.%d", a, b, c, d)
+					}
+				}
+			}
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no valid IP addresses generated")
+	}
+
+	return ips, nil
+}
+
+// 获取远程服务器的OS信息及扩展信息，整体带超时控制
+func getOSInfo(target Target, collectors []Collector) RemoteServer {
+
+	ctx, cancel := context.WithTimeout(context.Background(), target.SSH.HostTimeout)
+	defer cancel()
+
+	resultChan := make(chan RemoteServer, 1)
+	go func() {
+		server := collectHost(target, collectors)
+		select {
+		case resultChan <- server:
+		case <-ctx.Done():
+		}
+	}()
+
+	select {
+	case c := <-resultChan:
+		return c
+	case <-ctx.Done():
+		return RemoteServer{
+			IP:      target.IP,
+			Group:   target.Group,
+			OSInfo:  "",
+			Success: false,
+			Error:   "timeout",
+		}
+	}
+}
+
+// 通过一个SSH连接依次执行 os-release 及事实采集命令
+func collectHost(target Target, collectors []Collector) RemoteServer {
+	server := RemoteServer{IP: target.IP, Group: target.Group, Protocol: ModeSSH}
+	config := target.SSH
+
+	client, fingerprint, credential, err := dialSSH(target)
+	server.HostKey = fingerprint
+	server.Credential = credential.label
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	defer client.Close()
+
+	password := credential.credential.Password
+	if password == "" {
+		password = config.Password
+	}
+	runner := &sshRunner{client: client, become: config.Become, password: password}
+	output, err := runner.Run("cat /etc/os-release")
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	server.Success = true
+	server.OSInfo = output
+	server.OS = parseOSRelease(output)
+	server.Facts, server.FactErrors = collectFacts(runner, target, collectors)
+	return server
+}
+
+// 检查主机是否可达
+func isHostReachable(ip string, config SSHConfig) bool {
+	address := net.JoinHostPort(ip, strconv.Itoa(config.Port))
+	conn, err := dialTCP(address, config)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,74 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 库调用方没有设置超时时使用默认值，而不是得到已经过期的 context
+func TestScanTargetZeroTimeouts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	server := NewScanner(ModeSSH, nil).ScanTarget(Target{IP: "127.0.0.1", SSH: SSHConfig{Username: "root", Password: "x", Port: p}})
+	if server.Success {
+		t.Fatal("scan succeeded against a server that closes every connection")
+	}
+	if server.Error == "timeout" || server.Error == "Host unreachable" {
+		t.Fatalf("error = %q, want the ssh handshake error", server.Error)
+	}
+}
+
+func TestScanSourceHost(t *testing.T) {
+	config := &Config{Groups: []TargetGroup{
+		{Name: "web", Targets: []string{"10.0.0.1-3"}, GroupConfig: GroupConfig{Username: "admin"}},
+		{Name: "db", Targets: []string{"10.0.1.1"}},
+	}}
+	var mu sync.Mutex
+	scanned := map[string]string{}
+	scanner := NewScanner(ModeSSH, nil)
+	scanner.Host = HostScannerFunc(func(target Target) RemoteServer {
+		mu.Lock()
+		scanned[target.IP] = target.SSH.Username
+		mu.Unlock()
+		return RemoteServer{IP: target.IP, Group: target.Group, Success: true}
+	})
+
+	var results int
+	err := scanner.ScanSource(context.Background(), ConfigTargets{Config: config, Base: SSHConfig{Username: "root"}}, func(server RemoteServer) {
+		results++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"10.0.0.1": "admin", "10.0.0.2": "admin", "10.0.0.3": "admin", "10.0.1.1": "root"}
+	if results != len(want) || len(scanned) != len(want) {
+		t.Fatalf("scanned %v with %d results, want %v", scanned, results, want)
+	}
+	for ip, user := range want {
+		if scanned[ip] != user {
+			t.Errorf("%s scanned as %q, want %q", ip, scanned[ip], user)
+		}
+	}
+
+	if err := scanner.ScanSource(context.Background(), ConfigTargets{Config: &Config{Groups: []TargetGroup{{Targets: []string{"bad"}}}}}, func(RemoteServer) {}); err == nil {
+		t.Fatal("invalid range did not return an error")
+	}
+}
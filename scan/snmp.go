@@ -0,0 +1,299 @@
+package scan
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// ModeSNMP 只通过 SNMP 采集，适用于没有 SSH 的网络设备
+const ModeSNMP = "snmp"
+
+// SNMPConfig SNMP 采集配置，设置后 SSH 失败的主机会再尝试 SNMP
+type SNMPConfig struct {
+	Version   string `yaml:"version"`   // 2c 或 3，默认 2c
+	Community string `yaml:"community"` // v2c 团体名，默认 public
+	Port      int    `yaml:"port"`      // 默认 161
+
+	// v3 USM 认证，auth_protocol 为空时不认证，priv_protocol 为空时不加密
+	Username     string `yaml:"username"`
+	AuthProtocol string `yaml:"auth_protocol"` // MD5、SHA、SHA224、SHA256、SHA384、SHA512
+	AuthPassword string `yaml:"auth_password"`
+	PrivProtocol string `yaml:"priv_protocol"` // DES、AES、AES192、AES256、AES192C、AES256C
+	PrivPassword string `yaml:"priv_password"`
+}
+
+// SNMPInterface 设备的一个网络接口
+type SNMPInterface struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	MAC    string `json:"mac,omitempty"`
+	Speed  uint64 `json:"speed,omitempty"` // bit/s
+	Status string `json:"status,omitempty"`
+}
+
+// SNMPv2-MIB system 组和 IF-MIB ifTable 中采集的 OID
+const (
+	oidSysDescr     = ".1.3.6.1.2.1.1.1.0"
+	oidSysObjectID  = ".1.3.6.1.2.1.1.2.0"
+	oidSysUpTime    = ".1.3.6.1.2.1.1.3.0"
+	oidSysContact   = ".1.3.6.1.2.1.1.4.0"
+	oidSysName      = ".1.3.6.1.2.1.1.5.0"
+	oidSysLocation  = ".1.3.6.1.2.1.1.6.0"
+	oidIfDescr      = ".1.3.6.1.2.1.2.2.1.2"
+	oidIfSpeed      = ".1.3.6.1.2.1.2.2.1.5"
+	oidIfPhysAddr   = ".1.3.6.1.2.1.2.2.1.6"
+	oidIfOperStatus = ".1.3.6.1.2.1.2.2.1.8"
+)
+
+var ifOperStatus = map[int]string{
+	1: "up", 2: "down", 3: "testing", 4: "unknown", 5: "dormant", 6: "notPresent", 7: "lowerLayerDown",
+}
+
+// 网络设备常见的 sysDescr 关键字
+var snmpOSSignatures = []struct {
+	keyword string
+	id      string
+	name    string
+}{
+	{"Cisco IOS XE", "ios-xe", "Cisco IOS XE"},
+	{"Cisco NX-OS", "nx-os", "Cisco NX-OS"},
+	{"Cisco IOS", "ios", "Cisco IOS"},
+	{"Cisco Adaptive Security Appliance", "asa", "Cisco ASA"},
+	{"JUNOS", "junos", "Juniper JunOS"},
+	{"Huawei Versatile Routing Platform", "vrp", "Huawei VRP"},
+	{"Comware", "comware", "H3C Comware"},
+	{"RouterOS", "routeros", "MikroTik RouterOS"},
+	{"FortiGate", "fortios", "Fortinet FortiOS"},
+	{"Arista Networks EOS", "eos", "Arista EOS"},
+	{"Hardware: x86", "windows", "Windows"},
+	{"Linux", "linux", "Linux"},
+}
+
+// 通过 SNMP 采集系统信息和接口列表
+func collectSNMP(target Target) RemoteServer {
+	server := RemoteServer{IP: target.IP, Group: target.Group, Protocol: ModeSNMP}
+
+	client, err := newSNMPClient(target.IP, target.SNMP, target.SSH.Timeout)
+	if err != nil {
+		server.Error = fmt.Sprintf("snmp: %v", err)
+		return server
+	}
+	if err := client.Connect(); err != nil {
+		server.Error = fmt.Sprintf("snmp: %v", err)
+		return server
+	}
+	defer client.Conn.Close()
+
+	packet, err := client.Get([]string{oidSysDescr, oidSysObjectID, oidSysUpTime, oidSysContact, oidSysName, oidSysLocation})
+	if err != nil {
+		server.Error = fmt.Sprintf("snmp: %v", err)
+		return server
+	}
+
+	system := make(map[string]interface{})
+	for _, pdu := range packet.Variables {
+		if pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance {
+			continue
+		}
+		switch pdu.Name {
+		case oidSysDescr:
+			system["descr"] = snmpString(pdu)
+		case oidSysObjectID:
+			system["object_id"] = snmpString(pdu)
+		case oidSysUpTime:
+			// 单位为 1/100 秒
+			system["uptime_seconds"] = gosnmp.ToBigInt(pdu.Value).Uint64() / 100
+		case oidSysContact:
+			system["contact"] = snmpString(pdu)
+		case oidSysName:
+			system["name"] = snmpString(pdu)
+		case oidSysLocation:
+			system["location"] = snmpString(pdu)
+		}
+	}
+	descr, _ := system["descr"].(string)
+	if descr == "" {
+		server.Error = "snmp: empty sysDescr"
+		return server
+	}
+
+	server.Success = true
+	server.OS = snmpOS(descr)
+	server.Facts = map[string]interface{}{"snmp": system}
+	if name, _ := system["name"].(string); name != "" {
+		server.Facts["hostname"] = name
+	}
+
+	interfaces, err := snmpInterfaces(client)
+	if err != nil {
+		server.FactErrors = map[string]string{"interfaces": err.Error()}
+	} else {
+		server.Facts["interfaces"] = interfaces
+	}
+	return server
+}
+
+func newSNMPClient(ip string, config *SNMPConfig, timeout time.Duration) (*gosnmp.GoSNMP, error) {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	client := &gosnmp.GoSNMP{
+		Target:         ip,
+		Port:           161,
+		Transport:      "udp",
+		Timeout:        timeout,
+		Retries:        1,
+		MaxOids:        gosnmp.MaxOids,
+		MaxRepetitions: 20,
+	}
+	if config.Port != 0 {
+		client.Port = uint16(config.Port)
+	}
+
+	switch config.Version {
+	case "", "2c", "v2c":
+		client.Version = gosnmp.Version2c
+		client.Community = config.Community
+		if client.Community == "" {
+			client.Community = "public"
+		}
+	case "3", "v3":
+		usm, flags, err := snmpUSM(config)
+		if err != nil {
+			return nil, err
+		}
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = flags
+		client.SecurityParameters = usm
+	default:
+		return nil, fmt.Errorf("unsupported snmp version: %s", config.Version)
+	}
+	return client, nil
+}
+
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+}
+
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192,
+	"AES256": gosnmp.AES256, "AES192C": gosnmp.AES192C, "AES256C": gosnmp.AES256C,
+}
+
+// 根据配置的协议确定 v3 安全级别
+func snmpUSM(config *SNMPConfig) (*gosnmp.UsmSecurityParameters, gosnmp.SnmpV3MsgFlags, error) {
+	if config.Username == "" {
+		return nil, 0, fmt.Errorf("snmp v3 requires a username")
+	}
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:               config.Username,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+	}
+	flags := gosnmp.NoAuthNoPriv
+
+	if config.AuthProtocol != "" {
+		auth, ok := snmpAuthProtocols[strings.ToUpper(config.AuthProtocol)]
+		if !ok {
+			return nil, 0, fmt.Errorf("unsupported snmp auth protocol: %s", config.AuthProtocol)
+		}
+		usm.AuthenticationProtocol = auth
+		usm.AuthenticationPassphrase = config.AuthPassword
+		flags = gosnmp.AuthNoPriv
+	}
+	if config.PrivProtocol != "" {
+		if flags == gosnmp.NoAuthNoPriv {
+			return nil, 0, fmt.Errorf("snmp privacy requires an auth protocol")
+		}
+		priv, ok := snmpPrivProtocols[strings.ToUpper(config.PrivProtocol)]
+		if !ok {
+			return nil, 0, fmt.Errorf("unsupported snmp priv protocol: %s", config.PrivProtocol)
+		}
+		usm.PrivacyProtocol = priv
+		usm.PrivacyPassphrase = config.PrivPassword
+		flags = gosnmp.AuthPriv
+	}
+	return usm, flags, nil
+}
+
+// 遍历 ifTable 的几列，按 ifIndex 合并为接口列表
+func snmpInterfaces(client *gosnmp.GoSNMP) ([]SNMPInterface, error) {
+	walk := client.BulkWalkAll
+	if client.Version == gosnmp.Version1 {
+		walk = client.WalkAll
+	}
+
+	byIndex := make(map[int]*SNMPInterface)
+	for _, column := range []string{oidIfDescr, oidIfSpeed, oidIfPhysAddr, oidIfOperStatus} {
+		pdus, err := walk(column)
+		if err != nil {
+			return nil, err
+		}
+		for _, pdu := range pdus {
+			index, err := strconv.Atoi(pdu.Name[strings.LastIndex(pdu.Name, ".")+1:])
+			if err != nil {
+				continue
+			}
+			iface, ok := byIndex[index]
+			if !ok {
+				iface = &SNMPInterface{Index: index}
+				byIndex[index] = iface
+			}
+			switch column {
+			case oidIfDescr:
+				iface.Name = snmpString(pdu)
+			case oidIfSpeed:
+				iface.Speed = gosnmp.ToBigInt(pdu.Value).Uint64()
+			case oidIfPhysAddr:
+				if mac, ok := pdu.Value.([]byte); ok && len(mac) > 0 {
+					iface.MAC = net.HardwareAddr(mac).String()
+				}
+			case oidIfOperStatus:
+				iface.Status = ifOperStatus[int(gosnmp.ToBigInt(pdu.Value).Int64())]
+			}
+		}
+	}
+
+	interfaces := make([]SNMPInterface, 0, len(byIndex))
+	for _, iface := range byIndex {
+		interfaces = append(interfaces, *iface)
+	}
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Index < interfaces[j].Index })
+	return interfaces, nil
+}
+
+func snmpString(pdu gosnmp.SnmpPDU) string {
+	switch v := pdu.Value.(type) {
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// 根据 sysDescr 识别设备系统，PrettyName 使用 sysDescr 的第一行
+func snmpOS(descr string) OSRelease {
+	pretty, _, _ := strings.Cut(descr, "\n")
+	pretty = strings.TrimSpace(pretty)
+	for _, sig := range snmpOSSignatures {
+		if strings.Contains(descr, sig.keyword) {
+			return OSRelease{ID: sig.id, Name: sig.name, PrettyName: pretty}
+		}
+	}
+	for _, sig := range osSignatures {
+		if strings.Contains(descr, sig.keyword) {
+			return OSRelease{ID: sig.id, Name: sig.name, PrettyName: pretty}
+		}
+	}
+	return OSRelease{PrettyName: pretty}
+}
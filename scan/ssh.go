@@ -0,0 +1,284 @@
+package scan
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 跳板机连接复用，同一跳板机上的多个目标共享一个SSH连接
+var (
+	bastionMu      sync.Mutex
+	bastionClients = make(map[string]*ssh.Client)
+)
+
+// 获取（或建立）到跳板机的连接
+func bastionClient(bastion *BastionConfig, config SSHConfig) (*ssh.Client, error) {
+	port := bastion.Port
+	if port == 0 {
+		port = 22
+	}
+	address := net.JoinHostPort(bastion.Host, strconv.Itoa(port))
+	key := bastion.Username + "@" + address
+
+	bastionMu.Lock()
+	defer bastionMu.Unlock()
+
+	if client, ok := bastionClients[key]; ok {
+		return client, nil
+	}
+
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            bastion.Username,
+		Auth:            []ssh.AuthMethod{ssh.Password(bastion.Password)},
+		HostKeyCallback: hostKeyCallback(config),
+		Timeout:         config.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial bastion %s: %v", address, err)
+	}
+	bastionClients[key] = client
+	return client, nil
+}
+
+// 丢弃失效的跳板机连接，下次使用时重新建立
+func dropBastionClient(client *ssh.Client) {
+	bastionMu.Lock()
+	defer bastionMu.Unlock()
+
+	for key, c := range bastionClients {
+		if c == client {
+			delete(bastionClients, key)
+		}
+	}
+	client.Close()
+}
+
+// CloseBastionClients 关闭所有跳板机连接
+func CloseBastionClients() {
+	bastionMu.Lock()
+	defer bastionMu.Unlock()
+
+	for key, client := range bastionClients {
+		client.Close()
+		delete(bastionClients, key)
+	}
+}
+
+// 建立到目标地址的TCP连接，配置了跳板机时经由跳板机转发
+func dialTCP(address string, config SSHConfig) (net.Conn, error) {
+	if config.Bastion == nil {
+		return net.DialTimeout("tcp", address, config.Timeout)
+	}
+
+	client, err := bastionClient(config.Bastion, config)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial("tcp", address)
+	if err != nil {
+		// 跳板机连接可能已断开，重建后重试一次
+		dropBastionClient(client)
+		if client, err = bastionClient(config.Bastion, config); err != nil {
+			return nil, err
+		}
+		conn, err = client.Dial("tcp", address)
+	}
+	return conn, err
+}
+
+// 未配置时不校验主机密钥
+func hostKeyCallback(config SSHConfig) ssh.HostKeyCallback {
+	if config.HostKeyCallback == nil {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return config.HostKeyCallback
+}
+
+// 依次尝试凭据候选项建立SSH连接，返回连接、主机密钥指纹和登录成功的凭据。
+// 每个凭据使用单独的连接，认证失败次数达到 MaxAuthAttempts 后停止，避免触发账号锁定
+func dialSSH(target Target) (*ssh.Client, string, candidate, error) {
+	config := target.SSH
+	maxAttempts := config.MaxAuthAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	var fingerprint string
+	var errs []string
+	for i, c := range credentialCandidates(target.Group, config) {
+		if i >= maxAttempts {
+			errs = append(errs, fmt.Sprintf("stopped after %d failed attempts", maxAttempts))
+			break
+		}
+		if i > 0 && config.AuthDelay > 0 {
+			time.Sleep(config.AuthDelay)
+		}
+
+		auth, err := c.credential.authMethod()
+		if err != nil {
+			// 本地密钥不可用不计入失败次数
+			errs = append(errs, fmt.Sprintf("%s: %v", c.label, err))
+			maxAttempts++
+			continue
+		}
+
+		client, err := dialSSHWith(target.IP, config, c.credential.Username, auth, &fingerprint)
+		if err == nil {
+			rememberCredential(target.Group, c.label)
+			return client, fingerprint, c, nil
+		}
+		if !isAuthError(err) {
+			return nil, fingerprint, candidate{}, err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", c.label, err))
+	}
+	return nil, fingerprint, candidate{}, fmt.Errorf("all credentials failed: %s", strings.Join(errs, "; "))
+}
+
+// 使用一种认证方式建立SSH连接
+func dialSSHWith(ip string, config SSHConfig, user string, auth ssh.AuthMethod, fingerprint *string) (*ssh.Client, error) {
+	sshConfig := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: recordHostKey(hostKeyCallback(config), fingerprint),
+		Timeout:         config.Timeout,
+	}
+
+	address := net.JoinHostPort(ip, strconv.Itoa(config.Port))
+	conn, err := dialTCP(address, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %v", err)
+	}
+
+	// 握手阶段同样受连接超时控制
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, sshConfig)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to dial: %v", err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// 在已建立的连接上新建会话执行命令
+func runSSHCommand(client *ssh.Client, command string) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	// 创建带缓冲的管道来收集输出
+	var stdoutBuf bytes.Buffer
+	var stderrBuf bytes.Buffer
+	session.Stdout = &stdoutBuf
+	session.Stderr = &stderrBuf
+
+	err = session.Run(command)
+	if err != nil {
+		stderr := strings.TrimSpace(stderrBuf.String())
+		if stderr != "" {
+			return "", fmt.Errorf("command failed: %s", stderr)
+		}
+		return "", fmt.Errorf("command failed: %v", err)
+	}
+	return strings.TrimSpace(stdoutBuf.String()), nil
+}
+
+// sshRunner 在目标主机上执行命令，按配置通过 sudo/su 切换用户
+type sshRunner struct {
+	client   *ssh.Client
+	become   *BecomeConfig
+	password string // 登录密码，提权未单独配置密码时使用
+}
+
+// Run 执行命令，配置了提权时包装为 sudo/su 调用
+func (r *sshRunner) Run(command string) (string, error) {
+	if r.become == nil {
+		return runSSHCommand(r.client, command)
+	}
+
+	user := r.become.User
+	if user == "" {
+		user = "root"
+	}
+	password := r.become.Password
+	if password == "" {
+		password = r.password
+	}
+
+	switch r.become.Method {
+	case "", BecomeSudo:
+		// -S 从标准输入读取密码，-p '' 去掉提示符避免混入输出
+		wrapped := fmt.Sprintf("sudo -S -p '' -u %s -- sh -c %s", shellQuote(user), shellQuote(command))
+		return runSSHCommandWithInput(r.client, wrapped, password+"\n", false)
+	case BecomeSu:
+		// su 只从终端读取密码，需要申请伪终端
+		wrapped := fmt.Sprintf("su - %s -c %s", shellQuote(user), shellQuote(command))
+		output, err := runSSHCommandWithInput(r.client, wrapped, password+"\n", true)
+		return stripPasswordPrompt(output), err
+	default:
+		return "", fmt.Errorf("unsupported become method: %s", r.become.Method)
+	}
+}
+
+// 执行命令并写入标准输入，pty 为 true 时申请伪终端
+func runSSHCommandWithInput(client *ssh.Client, command, input string, pty bool) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	if pty {
+		modes := ssh.TerminalModes{ssh.ECHO: 0}
+		if err := session.RequestPty("xterm", 80, 200, modes); err != nil {
+			return "", fmt.Errorf("failed to request pty: %v", err)
+		}
+	}
+
+	var stdoutBuf bytes.Buffer
+	var stderrBuf bytes.Buffer
+	session.Stdout = &stdoutBuf
+	session.Stderr = &stderrBuf
+	session.Stdin = strings.NewReader(input)
+
+	err = session.Run(command)
+	if err != nil {
+		stderr := strings.TrimSpace(stderrBuf.String())
+		if stderr == "" && pty {
+			// 伪终端下错误信息输出在 stdout 中
+			stderr = stripPasswordPrompt(strings.TrimSpace(stdoutBuf.String()))
+		}
+		if stderr != "" {
+			return "", fmt.Errorf("command failed: %s", stderr)
+		}
+		return "", fmt.Errorf("command failed: %v", err)
+	}
+	return strings.TrimSpace(stdoutBuf.String()), nil
+}
+
+// 去掉 su 输出开头的密码提示行
+func stripPasswordPrompt(output string) string {
+	first, rest, ok := strings.Cut(output, "\n")
+	if strings.Contains(strings.ToLower(first), "password") || strings.Contains(first, "密码") {
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(rest)
+	}
+	return output
+}
+
+// 使用单引号转义 shell 参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,127 @@
+package scan_os
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TreeWu/mock-go/scan"
+)
+
+// 检查点保存间隔
+const checkpointInterval = 5 * time.Second
+
+// Checkpoint 扫描进度，记录已完成和待扫描的主机，用于中断后继续扫描。
+// 结果本身已流式写入输出文件，检查点只保存主机标识和计数
+type Checkpoint struct {
+	Completed []string  `json:"completed"`
+	Pending   []string  `json:"pending"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	path      string
+	mu        sync.Mutex
+	completed map[string]struct{}
+	pending   map[string]struct{}
+	lastSave  time.Time
+}
+
+// 为本次扫描创建检查点，resume 为 true 时加载已有检查点
+func openCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{path: path, completed: make(map[string]struct{}), pending: make(map[string]struct{})}
+	if !resume {
+		return cp, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %v", err)
+	}
+	if err := json.Unmarshal(content, cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %v", path, err)
+	}
+	for _, key := range cp.Completed {
+		cp.completed[key] = struct{}{}
+	}
+	return cp, nil
+}
+
+// 过滤掉已完成的目标，并把剩余目标记为待扫描
+func (c *Checkpoint) filter(targets []scan.Target) []scan.Target {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var remaining []scan.Target
+	for _, target := range targets {
+		key := scan.TargetKey(target.Group, target.IP)
+		if _, ok := c.completed[key]; ok {
+			continue
+		}
+		c.pending[key] = struct{}{}
+		remaining = append(remaining, target)
+	}
+	return remaining
+}
+
+// 记录一台主机已完成，距上次保存超过间隔时写入文件
+func (c *Checkpoint) add(server scan.RemoteServer) {
+	c.mu.Lock()
+	key := scan.TargetKey(server.Group, server.IP)
+	c.completed[key] = struct{}{}
+	delete(c.pending, key)
+	if server.Success {
+		c.Succeeded++
+	} else {
+		c.Failed++
+	}
+	due := time.Since(c.lastSave) >= checkpointInterval
+	c.mu.Unlock()
+
+	if due {
+		if err := c.save(); err != nil {
+			logger.Error("saving checkpoint failed", "err", err)
+		}
+	}
+}
+
+// 写入检查点文件，先写临时文件再重命名，避免中断时留下不完整的文件
+func (c *Checkpoint) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Completed = sortedKeys(c.completed)
+	c.Pending = sortedKeys(c.pending)
+	c.UpdatedAt = time.Now()
+	content, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.lastSave = time.Now()
+	return nil
+}
+
+// 扫描全部完成后删除检查点
+func (c *Checkpoint) remove() {
+	os.Remove(c.path)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
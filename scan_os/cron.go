@@ -0,0 +1,119 @@
+package scan_os
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule 计算下一次执行时间
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+// everySchedule 固定间隔执行，例如 @every 30m
+type everySchedule time.Duration
+
+func (e everySchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronSchedule 标准五段 cron 表达式: 分 时 日 月 周
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+}
+
+// 解析调度表达式，支持五段 cron、@every <duration>、@hourly、@daily、@weekly
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	}
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval: %s", rest)
+		}
+		return everySchedule(d), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression needs 5 fields: %s", spec)
+	}
+
+	var c cronSchedule
+	var err error
+	bounds := []struct {
+		target   *map[int]bool
+		min, max int
+	}{
+		{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 6},
+	}
+	for i, b := range bounds {
+		if *b.target, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("field %d: %v", i+1, err)
+		}
+	}
+	return &c, nil
+}
+
+// 解析单个字段，支持 *、数字、列表、范围和步长，例如 */5、1-10/2、1,15
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step: %s", part)
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			startStr, endStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(startStr); err != nil {
+				return nil, fmt.Errorf("invalid value: %s", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(endStr); err != nil {
+					return nil, fmt.Errorf("invalid value: %s", part)
+				}
+			} else if hasStep {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("value out of range: %s", part)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// 逐分钟查找下一个匹配的时间，最多查找一年
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(1, 0, 0)
+	for t.Before(limit) {
+		if c.month[int(t.Month())] && c.dom[t.Day()] && c.dow[int(t.Weekday())] &&
+			c.hour[t.Hour()] && c.minute[t.Minute()] {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return limit
+}
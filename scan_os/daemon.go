@@ -0,0 +1,209 @@
+package scan_os
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TreeWu/mock-go/scan"
+)
+
+// 快照文件名格式
+const snapshotLayout = "20060102_150405"
+
+// daemon 按计划定期扫描，保存带时间戳的快照并通过 HTTP 提供最新资产和变化
+type daemon struct {
+	mu          sync.RWMutex
+	snapshotDir string
+	latest      []scan.RemoteServer
+	latestAt    time.Time
+	previous    []scan.RemoteServer
+	previousAt  time.Time
+	scanning    bool
+}
+
+// 创建守护进程，加载目录中最近的两份快照，重启后仍可查询变化
+func newDaemon(snapshotDir string) (*daemon, error) {
+	if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &daemon{snapshotDir: snapshotDir}
+
+	snapshots, err := d.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if n := len(snapshots); n > 0 {
+		if d.latest, d.latestAt, err = d.loadSnapshot(snapshots[n-1]); err != nil {
+			return nil, err
+		}
+		if n > 1 {
+			if d.previous, d.previousAt, err = d.loadSnapshot(snapshots[n-2]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// 按时间排序的快照文件名
+func (d *daemon) snapshots() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(d.snapshotDir, "scan_*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *daemon) loadSnapshot(name string) ([]scan.RemoteServer, time.Time, error) {
+	results, err := scan.LoadResultsJSONL(filepath.Join(d.snapshotDir, name))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, "scan_"), ".jsonl")
+	at, _ := time.ParseInLocation(snapshotLayout, stamp, time.Local)
+	return results, at, nil
+}
+
+// 启动 HTTP 服务并按计划扫描，启动时立即执行一次，ctx 取消后停止服务并返回
+func (d *daemon) run(ctx context.Context, sched schedule, listen string, runScan func() []scan.RemoteServer) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/inventory", d.handleInventory)
+	mux.HandleFunc("/deltas", d.handleDeltas)
+	mux.HandleFunc("/snapshots", d.handleSnapshots)
+	mux.HandleFunc("/healthz", d.handleHealth)
+
+	server := &http.Server{Addr: listen, Handler: mux}
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("inventory api listening", "addr", listen)
+		errCh <- server.ListenAndServe()
+	}()
+
+	next := time.Now()
+	for {
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			logger.Info("daemon stopping")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		case <-time.After(time.Until(next)):
+		}
+
+		d.scanOnce(ctx, runScan)
+		next = sched.next(time.Now())
+		logger.Info("next scan", "at", next.Format(time.DateTime))
+	}
+}
+
+// 执行一次扫描并保存快照，扫描被中断时不保存不完整的快照
+func (d *daemon) scanOnce(ctx context.Context, runScan func() []scan.RemoteServer) {
+	d.mu.Lock()
+	d.scanning = true
+	d.mu.Unlock()
+
+	start := time.Now()
+	results := runScan()
+	if ctx.Err() != nil {
+		d.mu.Lock()
+		d.scanning = false
+		d.mu.Unlock()
+		logger.Warn("scan interrupted, snapshot discarded", "hosts", len(results))
+		return
+	}
+
+	name := fmt.Sprintf("scan_%s.jsonl", start.Format(snapshotLayout))
+	sink, _, err := scan.NewFileSink(scan.FormatJSONL, filepath.Join(d.snapshotDir, strings.TrimSuffix(name, ".jsonl")), false)
+	if err == nil {
+		err = scan.WriteAll(sink, results)
+	}
+	if err != nil {
+		logger.Error("save snapshot failed", "snapshot", name, "err", err)
+	}
+
+	d.mu.Lock()
+	d.previous, d.previousAt = d.latest, d.latestAt
+	d.latest, d.latestAt = results, start
+	d.scanning = false
+	d.mu.Unlock()
+
+	logger.Info("scan finished", "hosts", len(results), "elapsed", time.Since(start).Round(time.Millisecond).String(), "snapshot", name)
+}
+
+// GET /healthz 进程存活即返回 ok，scanning 表示是否正在扫描
+func (d *daemon) handleHealth(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	scanning := d.scanning
+	d.mu.RUnlock()
+	writeJSON(w, map[string]interface{}{"status": "ok", "scanning": scanning})
+}
+
+// GET /inventory[?ip=...&group=...] 最新一次扫描的结果
+func (d *daemon) handleInventory(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ip, group := r.URL.Query().Get("ip"), r.URL.Query().Get("group")
+	hosts := make([]scan.RemoteServer, 0, len(d.latest))
+	for _, server := range d.latest {
+		if (ip == "" || server.IP == ip) && (group == "" || server.Group == group) {
+			hosts = append(hosts, server)
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"scanned_at": d.latestAt,
+		"scanning":   d.scanning,
+		"total":      len(hosts),
+		"hosts":      hosts,
+	})
+}
+
+// GET /deltas 最近两次扫描之间的变化
+func (d *daemon) handleDeltas(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	diffs := scan.Diff(d.previous, d.latest)
+	if diffs == nil {
+		diffs = []scan.HostDiff{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"from":    d.previousAt,
+		"to":      d.latestAt,
+		"changes": diffs,
+	})
+}
+
+// GET /snapshots 已保存的快照列表
+func (d *daemon) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := d.snapshots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, snapshots)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		logger.Warn("write response failed", "err", err)
+	}
+}
@@ -0,0 +1,54 @@
+package scan_os
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TreeWu/mock-go/scan"
+)
+
+// 打印对比结果
+func printDiffs(diffs []scan.HostDiff) {
+	if len(diffs) == 0 {
+		fmt.Println("No changes.")
+		return
+	}
+
+	printSorted := func(prefix string, m map[string]string) {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("    %s %s %s\n", prefix, name, m[name])
+		}
+	}
+
+	for _, diff := range diffs {
+		fmt.Printf("%s [%s] %s\n", diff.IP, diff.Group, diff.Status)
+		if diff.OSBefore != diff.OSAfter {
+			fmt.Printf("    os: %q -> %q\n", diff.OSBefore, diff.OSAfter)
+		}
+		if diff.KeyBefore != diff.KeyAfter {
+			fmt.Printf("    host key changed: %s -> %s\n", diff.KeyBefore, diff.KeyAfter)
+		}
+		printSorted("+", diff.Added)
+		printSorted("-", diff.Removed)
+		printSorted("~", diff.Changed)
+	}
+}
+
+// 对比两个扫描结果文件
+func runDiff(beforeFile, afterFile string) error {
+	before, err := scan.LoadResultsJSONL(beforeFile)
+	if err != nil {
+		return err
+	}
+	after, err := scan.LoadResultsJSONL(afterFile)
+	if err != nil {
+		return err
+	}
+	printDiffs(scan.Diff(before, after))
+	return nil
+}
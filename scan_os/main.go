@@ -1,319 +1,335 @@
-package main
+package scan_os
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
-	"sync"
-	"time"
+	"syscall"
 
-	"golang.org/x/crypto/ssh"
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/scan"
+	"github.com/TreeWu/mock-go/targets"
 )
 
-// SSHConfig 包含SSH连接配置
-type SSHConfig struct {
-	Username string
-	Password string
-	Port     int
-	Timeout  time.Duration
-}
+var logger = logging.For("scan")
 
-// RemoteServer 表示远程服务器信息
-type RemoteServer struct {
-	IP      string
-	OSInfo  string
-	Success bool
-	Error   string
+// 打印单台主机的扫描结果
+func printResult(server scan.RemoteServer) {
+	if server.Success {
+		fmt.Printf("✓ Successfully retrieved OS info from %s: %s\n", server.IP, server.OS.PrettyName)
+		for _, port := range server.Ports {
+			fmt.Printf("    %5d/tcp %-12s %s%s\n", port.Port, port.Service, port.Banner, port.Server)
+		}
+	} else {
+		fmt.Printf("✗ Failed to get OS info from %s: %s\n", server.IP, server.Error)
+	}
 }
 
-// 解析IP范围，支持第三、第四位都包含范围
-func parseIPRange(ipRange string) ([]string, error) {
-	parts := strings.Split(ipRange, ".")
-	if len(parts) != 4 {
-		return nil, fmt.Errorf("invalid IP range format")
+// 根据配置文件和命令行参数确定扫描目标及配置文件中的自定义采集项，命令行中的IP范围使用全局配置
+func loadTargets(config scan.SSHConfig, configFile string, ranges []string, ports []int, snmp *scan.SNMPConfig) ([]scan.Target, []scan.Collector, error) {
+	scanConfig := &scan.Config{}
+	if configFile != "" {
+		var err error
+		if scanConfig, err = scan.LoadConfig(configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	collectors, err := scanConfig.CustomCollectors()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(scanConfig.Defaults.Ports) == 0 {
+		scanConfig.Defaults.Ports = ports
+	}
+	if scanConfig.Defaults.SNMP == nil {
+		scanConfig.Defaults.SNMP = snmp
 	}
 
-	// 解析每个部分的范围
-	var ranges [4][]int
-	for i, part := range parts {
-		if strings.Contains(part, "-") {
-			rangeParts := strings.Split(part, "-")
-			if len(rangeParts) != 2 {
-				return nil, fmt.Errorf("invalid range in part %d: %s", i, part)
-			}
-
-			start, err := strconv.Atoi(rangeParts[0])
-			if err != nil {
-				return nil, fmt.Errorf("invalid start value in part %d: %s", i, rangeParts[0])
-			}
-
-			end, err := strconv.Atoi(rangeParts[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid end value in part %d: %s", i, rangeParts[1])
-			}
+	if len(ranges) == 0 && len(scanConfig.Groups) == 0 {
+		ranges = []string{"192.168.33.1-245"} // 默认IP范围
+	}
+	if len(ranges) > 0 {
+		scanConfig.Groups = append(scanConfig.Groups, scan.TargetGroup{Name: "default", Targets: ranges})
+	}
+	targets, err := scan.ConfigTargets{Config: scanConfig, Base: config}.Targets()
+	return targets, collectors, err
+}
 
-			if start > end {
-				return nil, fmt.Errorf("start cannot be greater than end in part %d", i)
-			}
+// Run 执行扫描，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	// SSH配置
+	config := scan.SSHConfig{
+		Username: "root",     // 修改为你的用户名
+		Password: "password", // 修改为你的密码
+		Port:     22,         // SSH端口
+		Timeout:  scan.DefaultTimeout,
+	}
 
-			for j := start; j <= end; j++ {
-				ranges[i] = append(ranges[i], j)
-			}
-		} else {
-			// 单个值
-			value, err := strconv.Atoi(part)
-			if err != nil {
-				return nil, fmt.Errorf("invalid value in part %d: %s", i, part)
-			}
-			ranges[i] = []int{value}
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.StringVar(&config.Username, "user", config.Username, "ssh username")
+	fs.StringVar(&config.Password, "password", config.Password, "ssh password")
+	fs.IntVar(&config.Port, "port", config.Port, "ssh port")
+	keyFiles := fs.String("key", "", "comma separated private key files tried in order before -password")
+	fs.IntVar(&config.MaxAuthAttempts, "max-auth-attempts", 3, "max failed logins per host before giving up, to avoid account lockout")
+	fs.DurationVar(&config.AuthDelay, "auth-delay", 0, "delay between login attempts on the same host")
+	bastion := fs.String("bastion", "", "jump host for all targets, format user:password@host:port")
+	become := fs.String("become", "", "run commands via sudo or su")
+	becomeUser := fs.String("become-user", "root", "user to run commands as when -become is set")
+	mode := fs.String("mode", scan.ModeSSH, "scan mode: ssh (login and collect), ports (banner grabbing without credentials) or snmp")
+	snmpCommunity := fs.String("snmp", "", "SNMP v2c community used when ssh fails and in snmp mode, v3 is configured in -config")
+	portList := fs.String("ports", "", "comma separated ports to scan in ports mode, e.g. 22,80,8000-8010")
+	sinkKind := fs.String("sink", "", "also write results to a database: es, pg or mongo")
+	sinkURL := fs.String("sink-url", "", "database address: es url, postgres connection string or mongodb uri")
+	sinkTarget := fs.String("sink-target", "", "database or elasticsearch cluster from the --targets file used as -sink and -sink-url")
+	credentials := fs.String("credentials", "", "ssh credentials from the --targets file, explicit -user, -password, -key, -port, -bastion and -become flags override it")
+	sinkName := fs.String("sink-name", "scan_resource", "index, table or collection name for -sink")
+	checkpointFile := fs.String("checkpoint", "", "checkpoint file for resumable scans (default <output>.checkpoint.json)")
+	resume := fs.Bool("resume", false, "resume an interrupted scan from the checkpoint file")
+	diff := fs.Bool("diff", false, "compare two jsonl scan results: -diff old.jsonl new.jsonl")
+	hostKeyMode := fs.String("host-key", scan.HostKeyInsecure, "host key checking: insecure, strict (known_hosts) or tofu (record on first contact)")
+	knownHosts := fs.String("known-hosts", scan.DefaultKnownHostsFile(), "known_hosts file for -host-key strict/tofu")
+	daemonSchedule := fs.String("daemon", "", "run as a daemon rescanning on a cron-like schedule, e.g. \"@every 1h\" or \"0 */6 * * *\"")
+	listen := fs.String("listen", ":8090", "http address for the daemon inventory api")
+	snapshotDir := fs.String("snapshot-dir", "snapshots", "directory for timestamped daemon snapshots")
+	configFile := fs.String("config", "", "yaml config file with target groups")
+	output := fs.String("o", "os-results", "output file name without extension")
+	format := fs.String("format", "jsonl,csv", "comma separated output formats: jsonl,csv,ansible,hosts")
+	factList := fs.String("facts", strings.Join(scan.DefaultFacts, ","), "comma separated facts to collect: "+strings.Join(scan.BuiltinFacts(), ","))
+	concurrency := fs.Int("concurrency", 20, "max hosts scanned at the same time")
+	rate := fs.Float64("rate", 0, "max new hosts per second across all targets, 0 for unlimited")
+	subnetRate := fs.Float64("subnet-rate", 0, "max new hosts per second within one subnet, 0 for unlimited")
+	subnetPrefix := fs.Int("subnet-prefix", 24, "prefix length that defines a subnet for -subnet-rate")
+	shuffle := fs.Bool("shuffle", false, "scan targets in random order instead of sequentially")
+	verbose := fs.Bool("v", false, "print every host result instead of only the progress line")
+	fs.DurationVar(&config.HostTimeout, "host-timeout", scan.DefaultHostTimeout, "total timeout for collecting one host")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
 		}
+		return 2
 	}
-
-	// 生成所有IP地址组合
-	var ips []string
-	for _, a := range ranges[0] {
-		for _, b := range ranges[1] {
-			for _, c := range ranges[2] {
-				for _, d := range ranges[3] {
-					// 验证IP地址各部分的有效性
-					if a >= 0 && a <= 255 && b >= 0 && b <= 255 &&
-						c >= 0 && c <= 255 && d >= 0 && d <= 255 {
-						ip := fmt.Sprintf("%d.%d.%d.%d", a, b, c, d)
-						ips = append(ips, ip)
-					} else {
-						return nil, fmt.Errorf("invalid IP address: %d.%d.%d.%d", a, b, c, d)
-					}
-				}
-			}
+	if *credentials != "" {
+		creds, err := targets.FindCredentials(*credentials)
+		if err == nil {
+			_, err = targets.Apply(fs, creds.Flags())
+		}
+		if err != nil {
+			logger.Error("applying credentials failed", "credentials", *credentials, "err", err)
+			return 2
 		}
 	}
-
-	if len(ips) == 0 {
-		return nil, fmt.Errorf("no valid IP addresses generated")
+	if *sinkTarget != "" {
+		kind, address, err := targets.FindSink(*sinkTarget)
+		if err == nil {
+			_, err = targets.Apply(fs, map[string]string{"sink": kind, "sink-url": address})
+		}
+		if err != nil {
+			logger.Error("applying sink target failed", "sink-target", *sinkTarget, "err", err)
+			return 2
+		}
 	}
 
-	return ips, nil
-}
-
-// 通过SSH执行命令，带超时控制
-func executeSSHCommand(ip string, config SSHConfig, command string) (string, error) {
-	sshConfig := &ssh.ClientConfig{
-		User: config.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(config.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         config.Timeout,
+	if *diff {
+		if fs.NArg() != 2 {
+			fmt.Println("Usage: scan -diff old.jsonl new.jsonl")
+			return 2
+		}
+		if err := runDiff(fs.Arg(0), fs.Arg(1)); err != nil {
+			logger.Error("comparing scans failed", "err", err)
+			return 1
+		}
+		return 0
 	}
 
-	address := fmt.Sprintf("%s:%d", ip, config.Port)
-	client, err := ssh.Dial("tcp", address, sshConfig)
+	collectors, err := scan.ParseFacts(*factList)
 	if err != nil {
-		return "", fmt.Errorf("failed to dial: %v", err)
+		logger.Error("parsing facts failed", "err", err)
+		return 1
 	}
-	defer client.Close()
 
-	session, err := client.NewSession()
+	formats, err := scan.ParseFormats(*format)
 	if err != nil {
-		return "", fmt.Errorf("failed to create session: %v", err)
+		logger.Error("parsing output format failed", "err", err)
+		return 1
 	}
-	defer session.Close()
 
-	// 创建带缓冲的管道来收集输出
-	var stdoutBuf bytes.Buffer
-	var stderrBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	session.Stderr = &stderrBuf
+	// 凭据候选项：依次尝试各个私钥，最后尝试密码
+	if *keyFiles != "" {
+		for _, keyFile := range strings.Split(*keyFiles, ",") {
+			if keyFile = strings.TrimSpace(keyFile); keyFile != "" {
+				config.Credentials = append(config.Credentials, scan.Credential{KeyFile: keyFile})
+			}
+		}
+		if config.Password != "" {
+			config.Credentials = append(config.Credentials, scan.Credential{Password: config.Password})
+		}
+	}
 
-	// 使用channel来处理超时
+	if *bastion != "" {
+		if config.Bastion, err = scan.ParseBastion(*bastion); err != nil {
+			logger.Error("parsing bastion failed", "err", err)
+			return 1
+		}
+	}
 
-	err = session.Run(command)
-	// 设置命令执行超时为1秒
+	if *mode != scan.ModeSSH && *mode != scan.ModePorts && *mode != scan.ModeSNMP {
+		logger.Error("unsupported mode", "mode", *mode)
+		return 1
+	}
 
+	ports, err := scan.ParsePorts(*portList)
 	if err != nil {
-		stderr := strings.TrimSpace(stderrBuf.String())
-		if stderr != "" {
-			return "", fmt.Errorf("command failed: %s", stderr)
-		}
-		return "", fmt.Errorf("command failed: %v", err)
+		logger.Error("parsing ports failed", "err", err)
+		return 1
 	}
-	return strings.TrimSpace(stdoutBuf.String()), nil
-}
-
-// 获取远程服务器的OS信息
-func getOSInfo(ip string, config SSHConfig, results chan<- RemoteServer) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
-	defer cancel()
+	if config.HostKeyCallback, err = scan.NewHostKeyCallback(*hostKeyMode, *knownHosts); err != nil {
+		logger.Error("setting up host key checking failed", "err", err)
+		return 1
+	}
 
-	resultChan := make(chan RemoteServer, 1)
-	defer close(resultChan)
-	go func() {
+	if *become != "" {
+		config.Become = &scan.BecomeConfig{Method: *become, User: *becomeUser}
+	}
 
-		server := RemoteServer{IP: ip}
+	var snmp *scan.SNMPConfig
+	if *snmpCommunity != "" {
+		snmp = &scan.SNMPConfig{Version: "2c", Community: *snmpCommunity}
+	}
 
-		output, err := executeSSHCommand(ip, config, "cat /etc/os-release")
+	targets, custom, err := loadTargets(config, *configFile, fs.Args(), ports, snmp)
+	if err != nil {
+		logger.Error("loading targets failed", "err", err)
+		return 1
+	}
+	collectors = append(collectors, custom...)
+	defer scan.CloseBastionClients()
+
+	scanner := scan.NewScanner(*mode, collectors)
+	scanner.Concurrency = *concurrency
+	scanner.Rate = *rate
+	scanner.SubnetRate = *subnetRate
+	scanner.SubnetPrefix = *subnetPrefix
+	scanner.Shuffle = *shuffle
+
+	if *daemonSchedule != "" {
+		sched, err := parseSchedule(*daemonSchedule)
 		if err != nil {
-			server.Success = false
-			server.Error = err.Error()
-		} else {
-			server.Success = true
-			server.OSInfo = output
+			logger.Error("parsing schedule failed", "err", err)
+			return 1
 		}
-		select {
-		case resultChan <- server:
-		case <-ctx.Done():
+		d, err := newDaemon(*snapshotDir)
+		if err != nil {
+			logger.Error("loading snapshots failed", "err", err)
+			return 1
 		}
-
-	}()
-
-	select {
-	case c := <-resultChan:
-		results <- c
-	case <-ctx.Done():
-		results <- RemoteServer{
-			IP:      ip,
-			OSInfo:  "",
-			Success: false,
-			Error:   "timeout",
+		// 收到 SIGTERM 时中止进行中的扫描并停止服务
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = d.run(ctx, sched, *listen, func() []scan.RemoteServer {
+			var results []scan.RemoteServer
+			scanner.ScanContext(ctx, targets, func(server scan.RemoteServer) {
+				if *verbose {
+					printResult(server)
+				}
+				results = append(results, server)
+			})
+			return results
+		})
+		if err != nil {
+			logger.Error("daemon stopped", "err", err)
+			return 1
 		}
-
+		return 0
 	}
 
-}
-
-// 保存结果到文件，格式为 {ip:osinfo}
-func saveResultsToFile(results []RemoteServer, filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	if *checkpointFile == "" {
+		*checkpointFile = *output + ".checkpoint.json"
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	ip := make(map[string]string, len(results))
-
-	for _, server := range results {
-		if server.Success {
-			ip[server.IP] = server.OSInfo
-		}
-	}
-	indent, _ := json.MarshalIndent(ip, "", "  ")
-	writer.WriteString(string(indent))
-
-	return nil
-}
-
-// 检查主机是否可达
-func isHostReachable(ip string, port int, timeout time.Duration) bool {
-	address := fmt.Sprintf("%s:%d", ip, port)
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	checkpoint, err := openCheckpoint(*checkpointFile, *resume)
 	if err != nil {
-		return false
+		logger.Error("loading checkpoint failed", "err", err)
+		return 1
 	}
-	conn.Close()
-	return true
-}
-
-func main() {
-	// SSH配置
-	config := SSHConfig{
-		Username: "root",     // 修改为你的用户名
-		Password: "password", // 修改为你的密码
-		Port:     22,         // SSH端口
-		Timeout:  time.Second,
+	total := len(targets)
+	targets = checkpoint.filter(targets)
+	if *resume {
+		logger.Info("resuming scan", "completed", total-len(targets), "total", total)
 	}
-
-	// 从命令行参数获取IP范围，如果没有则使用默认值
-	var ipRange string
-	if len(os.Args) > 1 {
-		ipRange = os.Args[1]
-	} else {
-		ipRange = "192.168.33.1-245" // 默认IP范围
+	if err := checkpoint.save(); err != nil {
+		logger.Error("saving checkpoint failed", "err", err)
+		return 1
 	}
 
-	// 解析IP范围
-	ips, err := parseIPRange(ipRange)
+	// 结果边扫描边写入，继续扫描时追加到上次的输出文件
+	var sinks scan.MultiSink
+	fileSink, files, err := scan.NewFileSinks(*output, formats, *resume)
 	if err != nil {
-		fmt.Printf("Error parsing IP range: %v\n", err)
-		return
+		logger.Error("creating output failed", "err", err)
+		return 1
+	}
+	sinks = append(sinks, fileSink)
+	if *sinkKind != "" {
+		dbSink, err := scan.NewDBSink(*sinkKind, *sinkURL, *sinkName)
+		if err != nil {
+			fileSink.Close()
+			logger.Error("connecting to sink failed", "sink", *sinkKind, "err", err)
+			return 1
+		}
+		sinks = append(sinks, dbSink)
 	}
 
-	fmt.Printf("Scanning %d IP addresses...\n", len(ips))
-
-	var wg sync.WaitGroup
-	results := make(chan RemoteServer, len(ips))
-
-	// 限制并发数，避免过多连接
-	maxConcurrent := 20
-	semaphore := make(chan struct{}, maxConcurrent)
-
-	successCount := 0
-	failedCount := 0
-
-	// 为每个IP启动goroutine
-	for _, ip := range ips {
-		wg.Add(1)
-
-		go func(ip string) {
-			semaphore <- struct{}{} // 获取信号量
+	// 中断时不再开始新的主机，等待进行中的主机完成后保存进度，之后可使用 -resume 继续
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-			defer func() {
-				wg.Done()
-				<-semaphore // 释放信号量
-			}()
+	logger.Info("scanning", "targets", len(targets))
+	progress := newProgress(total, checkpoint.Succeeded, checkpoint.Failed)
 
-			fmt.Printf("Checking %s...\n", ip)
+	var writeErr error
+	scanner.ScanContext(ctx, targets, func(server scan.RemoteServer) {
+		if *verbose {
+			printResult(server)
+		}
+		if writeErr != nil {
+			return
+		}
+		if writeErr = sinks.Write(server); writeErr != nil {
+			stop()
+			return
+		}
+		checkpoint.add(server)
+		progress.add(server.Success)
+	})
+	progress.finish()
 
-			// 先检查主机是否可达
-			if !isHostReachable(ip, config.Port, time.Second) {
-				results <- RemoteServer{
-					IP:      ip,
-					Success: false,
-					Error:   "Host unreachable",
-				}
-			} else {
-				getOSInfo(ip, config, results)
-			}
-		}(ip)
+	if err := errors.Join(writeErr, sinks.Close()); err != nil {
+		logger.Error("saving results failed", "err", err)
 	}
-
-	// 等待所有goroutine完成
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// 收集结果
-	var allResults []RemoteServer
-	for server := range results {
-		allResults = append(allResults, server)
-		if server.Success {
-			successCount++
-			fmt.Printf("✓ Successfully retrieved OS info from %s\n", server.IP)
-		} else {
-			failedCount++
-			fmt.Printf("✗ Failed to get OS info from %s: %s\n", server.IP, server.Error)
-		}
+	if err := checkpoint.save(); err != nil {
+		logger.Error("saving checkpoint failed", "err", err)
+		return 1
 	}
-
-	// 保存结果到文件
-	outputFile := "os-results.json"
-	if err := saveResultsToFile(allResults, outputFile); err != nil {
-		fmt.Printf("Error saving results: %v\n", err)
-		return
+	if writeErr != nil {
+		return 1
 	}
+	if ctx.Err() != nil {
+		logger.Warn("interrupted, rerun with -resume to continue", "checkpoint", *checkpointFile)
+		return 130
+	}
+	if *sinkKind != "" {
+		logger.Info("results written", "sink", *sinkKind, "name", *sinkName)
+	}
+
+	checkpoint.remove()
 
 	fmt.Printf("\nScan completed!\n")
-	fmt.Printf("Successful: %d\n", successCount)
-	fmt.Printf("Failed: %d\n", failedCount)
-	fmt.Printf("Results saved to: %s\n", outputFile)
+	fmt.Printf("Successful: %d\n", checkpoint.Succeeded)
+	fmt.Printf("Failed: %d\n", checkpoint.Failed)
+	fmt.Printf("Results saved to: %s\n", strings.Join(files, ", "))
+	return 0
 }
@@ -0,0 +1,108 @@
+package scan_os
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// progress 统计扫描进度，定期输出一行摘要：完成数/总数、成功/失败、速率
+type progress struct {
+	mu      sync.Mutex
+	total   int
+	done    int
+	success int
+	failed  int
+	scanned int // 本次运行扫描的主机数，用于计算速率
+	start   time.Time
+	tty     bool
+	stop    chan struct{}
+}
+
+// 终端上每 500ms 原地刷新，输出到文件或管道时每 10s 输出一条日志
+func newProgress(total, success, failed int) *progress {
+	p := &progress{
+		total:   total,
+		done:    success + failed,
+		success: success,
+		failed:  failed,
+		start:   time.Now(),
+		stop:    make(chan struct{}),
+	}
+	if info, err := os.Stderr.Stat(); err == nil {
+		p.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	interval := 10 * time.Second
+	if p.tty {
+		interval = 500 * time.Millisecond
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progress) add(success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.scanned++
+	if success {
+		p.success++
+	} else {
+		p.failed++
+	}
+}
+
+func (p *progress) print() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.start)
+	rate := float64(p.scanned) / elapsed.Seconds()
+	if !p.tty {
+		attrs := []any{"done", p.done, "total", p.total, "success", p.success, "failed", p.failed,
+			"hosts_per_sec", math.Round(rate*10) / 10, "elapsed", elapsed.Round(time.Second).String()}
+		if remaining := p.total - p.done; remaining > 0 && rate > 0 {
+			attrs = append(attrs, "eta", (time.Duration(float64(remaining)/rate) * time.Second).Round(time.Second).String())
+		}
+		logger.Info("scan progress", attrs...)
+		return
+	}
+
+	line := fmt.Sprintf("[%d/%d] %.1f%% success %d failed %d %.1f hosts/s elapsed %s",
+		p.done, p.total, percent(p.done, p.total), p.success, p.failed, rate, elapsed.Round(time.Second))
+	if remaining := p.total - p.done; remaining > 0 && rate > 0 {
+		line += fmt.Sprintf(" eta %s", (time.Duration(float64(remaining)/rate) * time.Second).Round(time.Second))
+	}
+
+	fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+}
+
+// 停止定期输出并打印最终进度
+func (p *progress) finish() {
+	close(p.stop)
+	p.print()
+	if p.tty {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,67 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/TreeWu/mock-go/db_benchmark"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+var _ db_benchmark.DocumentWriter = (*mysqlWriter)(nil)
+
+// mysqlWriter 写入 (id, data) 结构的表，data 为 JSON 列
+type mysqlWriter struct {
+	db    *sql.DB
+	table string
+}
+
+func newMySQLWriter(ctx context.Context, dsn, table string) (*mysqlWriter, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &mysqlWriter{db: db, table: "`" + strings.ReplaceAll(table, "`", "``") + "`"}, nil
+}
+
+func (m *mysqlWriter) Name() string {
+	return "MySQL"
+}
+
+func (m *mysqlWriter) Prepare(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id VARCHAR(191) PRIMARY KEY, data JSON NOT NULL)", m.table))
+	return err
+}
+
+// WriteDocuments 一条多行 INSERT 写入，id 已存在时覆盖 data
+func (m *mysqlWriter) WriteDocuments(ctx context.Context, ids []string, docs []map[string]interface{}) error {
+	var query strings.Builder
+	fmt.Fprintf(&query, "INSERT INTO %s (id, data) VALUES ", m.table)
+	args := make([]interface{}, 0, 2*len(docs))
+	for i, doc := range docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(?, ?)")
+		args = append(args, ids[i], data)
+	}
+	query.WriteString(" ON DUPLICATE KEY UPDATE data = VALUES(data)")
+	_, err := m.db.ExecContext(ctx, query.String(), args...)
+	return err
+}
+
+func (m *mysqlWriter) Close() {
+	m.db.Close()
+}
@@ -0,0 +1,63 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/TreeWu/mock-go/db_benchmark"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ db_benchmark.DocumentWriter = (*redisWriter)(nil)
+
+// redisWriter 每个文档写为一个 JSON 字符串，key 为 prefix + id
+type redisWriter struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func newRedisWriter(ctx context.Context, addr, password string, db int, prefix string, ttl time.Duration) (*redisWriter, error) {
+	// 客户端内部的重试日志转到 debug 级别，连接失败由调用方统一输出
+	redis.SetLogger(redisLogger{})
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: password, DB: db})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &redisWriter{client: client, prefix: prefix, ttl: ttl}, nil
+}
+
+func (r *redisWriter) Name() string {
+	return "Redis"
+}
+
+func (r *redisWriter) Prepare(ctx context.Context) error {
+	return nil
+}
+
+// WriteDocuments 通过 pipeline 一次发送一批 SET
+func (r *redisWriter) WriteDocuments(ctx context.Context, ids []string, docs []map[string]interface{}) error {
+	pipe := r.client.Pipeline()
+	for i, doc := range docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, r.prefix+ids[i], data, r.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisWriter) Close() {
+	r.client.Close()
+}
+
+type redisLogger struct{}
+
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	logger.DebugContext(ctx, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,160 @@
+// Package seed 按模板生成文档直接写入数据库，用于初始化开发环境的数据。
+// Elasticsearch、PostgreSQL、MongoDB 复用 db_benchmark 中的连接，MySQL 和 Redis 在本包中实现。
+package seed
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/db_benchmark"
+	"github.com/TreeWu/mock-go/gen"
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/TreeWu/mock-go/value"
+)
+
+var logger = logging.For("seed")
+
+// 支持的目标
+const (
+	TargetES    = "es"
+	TargetPG    = "pg"
+	TargetMySQL = "mysql"
+	TargetMongo = "mongo"
+	TargetRedis = "redis"
+)
+
+// Run 生成并写入数据，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	templateFile := fs.String("template", "", "yaml/json document template with value directives, built-in resource template when empty")
+	count := fs.Int("count", 1000, "documents to generate")
+	batch := fs.Int("batch", 500, "documents per write")
+	idField := fs.String("id-field", "_id", "document field used as the record id, removed from the document; sequence number when missing")
+	conn := ConnectionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *batch <= 0 {
+		*batch = 1
+	}
+	if err := conn.Resolve(); err != nil {
+		logger.Error("applying connection failed", "connection", conn.Connection, "err", err)
+		return 2
+	}
+
+	template, err := gen.LoadTemplate(*templateFile)
+	if err != nil {
+		logger.Error("加载模板失败", "template", *templateFile, "err", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	writer, err := conn.Open(ctx)
+	if err != nil {
+		logger.Error("连接失败", "target", conn.Target, "err", err)
+		return exitCode(err)
+	}
+	defer writer.Close()
+
+	if err := writer.Prepare(ctx); err != nil {
+		logger.Error("初始化目标失败", "target", writer.Name(), "err", err)
+		return 1
+	}
+
+	start := time.Now()
+	written, err := Seed(ctx, writer, template, *count, *batch, *idField)
+	elapsed := time.Since(start)
+	if err != nil {
+		logger.Error("写入失败", "target", writer.Name(), "written", written, "err", err)
+		return 1
+	}
+	fmt.Printf("已写入 %d 条记录到 %s，耗时 %v，%.0f 条/秒\n",
+		written, writer.Name(), elapsed.Round(time.Millisecond), float64(written)/elapsed.Seconds())
+	return 0
+}
+
+// Seed 按模板生成 count 个文档分批写入 writer，返回已写入的数量。
+// idField 字段的值作为记录 id 并从文档中移除，没有该字段时使用从 1 开始的序号
+func Seed(ctx context.Context, writer db_benchmark.DocumentWriter, template map[string]interface{}, count, batch int, idField string) (int, error) {
+	values := value.NewValueHandler()
+	ids := make([]string, 0, batch)
+	docs := make([]map[string]interface{}, 0, batch)
+	written := 0
+
+	flush := func() error {
+		if len(docs) == 0 {
+			return nil
+		}
+		if err := writer.WriteDocuments(ctx, ids, docs); err != nil {
+			return err
+		}
+		written += len(docs)
+		logger.Debug("批量写入完成", "target", writer.Name(), "written", written)
+		ids, docs = ids[:0], docs[:0]
+		return nil
+	}
+
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		id, doc := document(values, template, i, idField)
+		ids = append(ids, id)
+		docs = append(docs, doc)
+		if len(docs) >= batch {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, flush()
+}
+
+// Generate 在内存中生成 count 个文档，id 规则同 Seed，用于同一份数据还要写到别处的场景
+func Generate(template map[string]interface{}, count int, idField string) ([]string, []map[string]interface{}) {
+	values := value.NewValueHandler()
+	ids := make([]string, count)
+	docs := make([]map[string]interface{}, count)
+	for i := range docs {
+		ids[i], docs[i] = document(values, template, i, idField)
+	}
+	return ids, docs
+}
+
+// Write 把已经生成的文档分批写入 writer，返回已写入的数量
+func Write(ctx context.Context, writer db_benchmark.DocumentWriter, ids []string, docs []map[string]interface{}, batch int) (int, error) {
+	written := 0
+	for start := 0; start < len(docs); start += batch {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		end := min(start+batch, len(docs))
+		if err := writer.WriteDocuments(ctx, ids[start:end], docs[start:end]); err != nil {
+			return written, err
+		}
+		written = end
+		logger.Debug("批量写入完成", "target", writer.Name(), "written", written)
+	}
+	return written, nil
+}
+
+// 生成第 i 个文档，idField 字段的值作为 id 并从文档中移除，没有时使用从 1 开始的序号
+func document(values *value.Handler, template map[string]interface{}, i int, idField string) (string, map[string]interface{}) {
+	doc := values.ProcessDynamicMap(template)
+	id := fmt.Sprint(i + 1)
+	if v, ok := doc[idField]; ok && v != nil {
+		id = fmt.Sprint(v)
+		delete(doc, idField)
+	}
+	return id, doc
+}
@@ -0,0 +1,228 @@
+package sftp_mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Config 虚拟文件系统的初始内容、用户和故障规则
+type Config struct {
+	Users  []User   `json:"users"` // 为空时接受任意用户名和密码
+	Dirs   []string `json:"dirs"`  // 预先创建的空目录
+	Files  []File   `json:"files"`
+	Faults []Fault  `json:"faults"`
+}
+
+// User 可以登录的用户，SFTP 可以使用密码或 authorized_keys 格式的公钥
+type User struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	PublicKey string `json:"public_key"`
+}
+
+// File 初始文件，content、source、size 三选一
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"` // 文件内容
+	Source  string `json:"source"`  // 从本地文件读取内容
+	Size    string `json:"size"`    // 按大小生成伪随机内容，如 10MB
+	Seed    uint64 `json:"seed"`    // 生成内容的种子，相同种子内容相同
+}
+
+// 故障类型
+const (
+	FaultError      = "error"      // 操作失败，设置 after 时在传输 after 字节后失败
+	FaultDisconnect = "disconnect" // 断开连接，设置 after 时在传输 after 字节后断开
+	FaultSlow       = "slow"       // 按 rate 限制传输速度
+)
+
+// 故障作用的操作
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+	OpList  = "list"
+)
+
+// Fault 按路径匹配的故障规则，path 使用 path.Match 的通配符语法
+type Fault struct {
+	Path        string  `json:"path"`
+	Op          string  `json:"op"` // read、write、list，为空时匹配所有操作
+	Type        string  `json:"type"`
+	After       int64   `json:"after"`
+	Rate        string  `json:"rate"`        // slow 的速度，如 64KB，表示每秒字节数
+	Probability float64 `json:"probability"` // 触发概率，0 或不设置表示每次都触发
+
+	rate int64
+}
+
+func loadConfig(file string) (*Config, error) {
+	config := &Config{}
+	if file == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", file, err)
+	}
+	for i := range config.Faults {
+		f := &config.Faults[i]
+		if _, err := path.Match(f.Path, "/"); err != nil {
+			return nil, fmt.Errorf("fault %d: invalid path %q", i, f.Path)
+		}
+		switch f.Type {
+		case FaultError, FaultDisconnect:
+		case FaultSlow:
+			if f.rate, err = parseSize(f.Rate); err != nil || f.rate <= 0 {
+				return nil, fmt.Errorf("fault %d: invalid rate %q", i, f.Rate)
+			}
+		default:
+			return nil, fmt.Errorf("fault %d: unknown type %q", i, f.Type)
+		}
+		if f.Op != "" && f.Op != OpRead && f.Op != OpWrite && f.Op != OpList {
+			return nil, fmt.Errorf("fault %d: unknown op %q", i, f.Op)
+		}
+	}
+	return config, nil
+}
+
+// 按配置创建虚拟文件系统
+func (c *Config) newVFS() (*vfs, error) {
+	v := newVFS()
+	for _, dir := range c.Dirs {
+		v.seed(dir, &node{dir: true})
+	}
+	for _, f := range c.Files {
+		if f.Path == "" {
+			return nil, errors.New("file without path")
+		}
+		n := &node{data: []byte(f.Content)}
+		switch {
+		case f.Source != "":
+			data, err := os.ReadFile(f.Source)
+			if err != nil {
+				return nil, err
+			}
+			n.data = data
+		case f.Size != "":
+			size, err := parseSize(f.Size)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Path, err)
+			}
+			n = &node{size: size, seed: f.Seed}
+		}
+		v.seed(f.Path, n)
+	}
+	return v, nil
+}
+
+// 校验密码，没有配置用户时接受任意用户
+func (c *Config) checkPassword(username, password string) bool {
+	if len(c.Users) == 0 {
+		return true
+	}
+	for _, u := range c.Users {
+		if u.Username == username && u.Password != "" && u.Password == password {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) checkPublicKey(username string, key ssh.PublicKey) bool {
+	for _, u := range c.Users {
+		if u.Username != username || u.PublicKey == "" {
+			continue
+		}
+		allowed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(u.PublicKey))
+		if err == nil && string(allowed.Marshal()) == string(key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回匹配路径和操作并且按概率触发的第一条故障
+func (c *Config) fault(p, op string) *Fault {
+	for i := range c.Faults {
+		f := &c.Faults[i]
+		if f.Op != "" && f.Op != op {
+			continue
+		}
+		if ok, _ := path.Match(f.Path, p); !ok {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		logger.Debug("注入故障", "path", p, "op", op, "type", f.Type)
+		return f
+	}
+	return nil
+}
+
+// 故障中断传输时返回的错误
+var errInjected = errors.New("injected failure")
+
+// faultReader 按故障规则包装读取，disconnect 用于断开当前连接
+type faultReader struct {
+	r          io.ReaderAt
+	fault      *Fault
+	disconnect func()
+}
+
+func (f *faultReader) ReadAt(p []byte, off int64) (int, error) {
+	if f.fault == nil {
+		return f.r.ReadAt(p, off)
+	}
+	if f.fault.Type == FaultSlow {
+		n, err := f.r.ReadAt(p, off)
+		throttle(n, f.fault.rate)
+		return n, err
+	}
+	// 到达 after 之前正常读取，之后失败或断开
+	if limit := f.fault.After - off; limit > 0 {
+		return f.r.ReadAt(p[:min(int64(len(p)), limit)], off)
+	}
+	if f.fault.Type == FaultDisconnect {
+		f.disconnect()
+	}
+	return 0, errInjected
+}
+
+// 按速度限制计算传输 n 字节需要的时间并等待
+func throttle(n int, rate int64) {
+	time.Sleep(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+}
+
+var sizeUnits = map[string]int64{"": 1, "B": 1, "KB": 1 << 10, "K": 1 << 10, "MB": 1 << 20, "M": 1 << 20, "GB": 1 << 30, "G": 1 << 30}
+
+// 解析 10MB、1.5GB 这样的大小，单位按 1024 计算
+func parseSize(text string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(text))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := sizeUnits[strings.TrimSpace(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || n < 0 || n*float64(unit) > float64(1<<50) {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return int64(n * float64(unit)), nil
+}
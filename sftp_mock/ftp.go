@@ -0,0 +1,461 @@
+package sftp_mock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 等待客户端建立数据连接的时间
+const ftpDataTimeout = 10 * time.Second
+
+// ftpServer 只支持被动模式（PASV、EPSV）的 FTP 服务
+type ftpServer struct {
+	server       *server
+	publicHost   string // PASV 回复中的地址，为空时使用控制连接的本地地址
+	passivePorts []int  // 为空时使用随机端口
+}
+
+type ftpSession struct {
+	*ftpServer
+	conn   net.Conn
+	reader *bufio.Reader
+
+	user       string
+	loggedIn   bool
+	cwd        string
+	rest       int64  // REST 指定的续传偏移
+	renameFrom string // RNFR 指定的源路径
+
+	mu   sync.Mutex
+	data net.Listener // PASV、EPSV 打开的数据端口
+}
+
+// 解析 30000-30009 这样的端口范围
+func parsePortRange(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	from, to, _ := strings.Cut(s, "-")
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return nil, err
+	}
+	end := start
+	if to != "" {
+		if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return nil, err
+		}
+	}
+	if start <= 0 || end > 65535 || end < start {
+		return nil, fmt.Errorf("invalid port range %q", s)
+	}
+	var ports []int
+	for p := start; p <= end; p++ {
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+func (f *ftpServer) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s := &ftpSession{ftpServer: f, conn: conn, reader: bufio.NewReader(conn), cwd: "/"}
+		go s.run()
+	}
+}
+
+func (s *ftpSession) reply(code int, format string, args ...interface{}) {
+	fmt.Fprintf(s.conn, "%d %s\r\n", code, fmt.Sprintf(format, args...))
+}
+
+// 断开控制连接和数据连接，用于 disconnect 故障
+func (s *ftpSession) disconnect() {
+	s.conn.Close()
+	s.closeData()
+}
+
+func (s *ftpSession) closeData() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data != nil {
+		s.data.Close()
+		s.data = nil
+	}
+}
+
+func (s *ftpSession) run() {
+	defer s.disconnect()
+	client := s.conn.RemoteAddr().String()
+	s.reply(220, "mockgo FTP server ready")
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		command = strings.ToUpper(command)
+		if command == "PASS" {
+			logger.Debug("FTP 命令", "client", client, "command", command)
+		} else {
+			logger.Debug("FTP 命令", "client", client, "command", command, "arg", arg)
+		}
+		if command == "QUIT" {
+			s.reply(221, "Goodbye")
+			return
+		}
+		s.handle(command, arg)
+	}
+}
+
+func (s *ftpSession) handle(command, arg string) {
+	switch command {
+	case "USER":
+		s.user, s.loggedIn = arg, false
+		s.reply(331, "Password required")
+		return
+	case "PASS":
+		if !s.server.config.checkPassword(s.user, arg) {
+			s.reply(530, "Login incorrect")
+			return
+		}
+		s.loggedIn = true
+		logger.Info("FTP 客户端登录", "client", s.conn.RemoteAddr().String(), "user", s.user)
+		s.reply(230, "Login successful")
+		return
+	case "SYST":
+		s.reply(215, "UNIX Type: L8")
+		return
+	case "FEAT":
+		fmt.Fprint(s.conn, "211-Features:\r\n EPSV\r\n PASV\r\n SIZE\r\n MDTM\r\n REST STREAM\r\n UTF8\r\n211 End\r\n")
+		return
+	case "OPTS":
+		s.reply(200, "OK")
+		return
+	case "NOOP":
+		s.reply(200, "OK")
+		return
+	}
+	if !s.loggedIn {
+		s.reply(530, "Please login with USER and PASS")
+		return
+	}
+
+	switch command {
+	case "PWD", "XPWD":
+		s.reply(257, "%q is the current directory", s.cwd)
+	case "CWD", "XCWD":
+		s.changeDir(s.resolve(arg))
+	case "CDUP", "XCUP":
+		s.changeDir(path.Dir(s.cwd))
+	case "TYPE", "MODE", "STRU":
+		s.reply(200, "OK")
+	case "PASV":
+		s.passive(false)
+	case "EPSV":
+		s.passive(true)
+	case "PORT", "EPRT":
+		s.reply(502, "Active mode is not supported, use PASV")
+	case "LIST", "NLST":
+		s.list(arg, command == "NLST")
+	case "RETR":
+		s.retrieve(s.resolve(arg))
+	case "STOR", "APPE":
+		s.store(s.resolve(arg), command == "APPE")
+	case "REST":
+		offset, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || offset < 0 {
+			s.reply(501, "Invalid offset")
+			return
+		}
+		s.rest = offset
+		s.reply(350, "Restarting at %d", offset)
+	case "SIZE":
+		info, err := s.server.fs.stat(s.resolve(arg))
+		if err != nil || info.IsDir() {
+			s.reply(550, "Could not get file size")
+			return
+		}
+		s.reply(213, "%d", info.Size())
+	case "MDTM":
+		info, err := s.server.fs.stat(s.resolve(arg))
+		if err != nil {
+			s.reply(550, "Could not get modification time")
+			return
+		}
+		s.reply(213, "%s", info.ModTime().UTC().Format("20060102150405"))
+	case "DELE":
+		s.command(s.resolve(arg), 250, func(p string) error { return s.server.fs.remove(p, false) })
+	case "MKD", "XMKD":
+		p := s.resolve(arg)
+		s.command(p, 257, s.server.fs.mkdir)
+	case "RMD", "XRMD":
+		s.command(s.resolve(arg), 250, func(p string) error { return s.server.fs.remove(p, true) })
+	case "RNFR":
+		if _, err := s.server.fs.stat(s.resolve(arg)); err != nil {
+			s.reply(550, "File not found")
+			return
+		}
+		s.renameFrom = s.resolve(arg)
+		s.reply(350, "Ready for RNTO")
+	case "RNTO":
+		if s.renameFrom == "" {
+			s.reply(503, "RNFR required first")
+			return
+		}
+		from := s.renameFrom
+		s.renameFrom = ""
+		s.command(s.resolve(arg), 250, func(p string) error { return s.server.fs.rename(from, p) })
+	default:
+		s.reply(502, "Command not implemented")
+	}
+}
+
+func (s *ftpSession) resolve(arg string) string {
+	if strings.HasPrefix(arg, "/") {
+		return cleanPath(arg)
+	}
+	return cleanPath(s.cwd + "/" + arg)
+}
+
+func (s *ftpSession) changeDir(p string) {
+	info, err := s.server.fs.stat(p)
+	if err != nil || !info.IsDir() {
+		s.reply(550, "No such directory")
+		return
+	}
+	s.cwd = p
+	s.reply(250, "Directory changed to %s", p)
+}
+
+// 执行修改文件系统的命令，适用写操作的故障规则
+func (s *ftpSession) command(p string, code int, op func(string) error) {
+	if !s.checkFault(p, OpWrite) {
+		return
+	}
+	if err := op(p); err != nil {
+		s.reply(550, "%s", ftpError(err))
+		return
+	}
+	s.reply(code, "%q OK", p)
+}
+
+// 检查操作开始时生效的故障，返回 false 表示已经回复错误或断开连接
+func (s *ftpSession) checkFault(p, op string) bool {
+	_, ok := s.fault(p, op)
+	return ok
+}
+
+func (s *ftpSession) fault(p, op string) (*Fault, bool) {
+	f := s.server.config.fault(p, op)
+	if f == nil || f.Type == FaultSlow || f.After > 0 {
+		return f, true
+	}
+	if f.Type == FaultDisconnect {
+		s.disconnect()
+		return nil, false
+	}
+	s.closeData()
+	s.reply(550, "Permission denied")
+	return nil, false
+}
+
+func ftpError(err error) string {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "No such file or directory"
+	case errors.Is(err, os.ErrExist):
+		return "File exists"
+	case errors.Is(err, os.ErrPermission):
+		return "Permission denied"
+	}
+	return err.Error()
+}
+
+// 打开被动模式的数据端口
+func (s *ftpSession) passive(extended bool) {
+	s.closeData()
+	host, _, _ := net.SplitHostPort(s.conn.LocalAddr().String())
+	if s.publicHost != "" {
+		host = s.publicHost
+	}
+	ip := net.ParseIP(host).To4()
+	if !extended && ip == nil {
+		s.reply(425, "Use EPSV with IPv6")
+		return
+	}
+
+	var listener net.Listener
+	var err error
+	if len(s.passivePorts) == 0 {
+		listener, err = net.Listen("tcp", ":0")
+	} else {
+		for _, port := range s.passivePorts {
+			if listener, err = net.Listen("tcp", ":"+strconv.Itoa(port)); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		s.reply(425, "Can't open data connection")
+		return
+	}
+	s.mu.Lock()
+	s.data = listener
+	s.mu.Unlock()
+	port := listener.Addr().(*net.TCPAddr).Port
+	if extended {
+		s.reply(229, "Entering Extended Passive Mode (|||%d|)", port)
+		return
+	}
+	s.reply(227, "Entering Passive Mode (%d,%d,%d,%d,%d,%d)", ip[0], ip[1], ip[2], ip[3], port>>8, port&0xff)
+}
+
+// 回复 150 后等待客户端连接数据端口，每个数据端口只使用一次
+func (s *ftpSession) openData() (net.Conn, error) {
+	s.mu.Lock()
+	listener := s.data
+	s.data = nil
+	s.mu.Unlock()
+	if listener == nil {
+		s.reply(425, "Use PASV or EPSV first")
+		return nil, errors.New("no data connection")
+	}
+	defer listener.Close()
+	s.reply(150, "Opening data connection")
+	if tcp, ok := listener.(*net.TCPListener); ok {
+		tcp.SetDeadline(time.Now().Add(ftpDataTimeout))
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		s.reply(425, "Can't open data connection")
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *ftpSession) list(arg string, namesOnly bool) {
+	// 忽略 ls 风格的参数，如 LIST -la
+	target := s.cwd
+	for _, field := range strings.Fields(arg) {
+		if !strings.HasPrefix(field, "-") {
+			target = s.resolve(field)
+		}
+	}
+	if !s.checkFault(target, OpList) {
+		return
+	}
+	infos, err := s.server.fs.list(target)
+	if err != nil {
+		// 列出单个文件
+		info, statErr := s.server.fs.stat(target)
+		if statErr != nil {
+			s.closeData()
+			s.reply(550, "%s", ftpError(err))
+			return
+		}
+		infos = []os.FileInfo{info}
+	}
+	conn, err := s.openData()
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(conn)
+	for _, info := range infos {
+		if namesOnly {
+			fmt.Fprintf(w, "%s\r\n", info.Name())
+			continue
+		}
+		fmt.Fprintf(w, "%s 1 ftp ftp %12d %s %s\r\n", info.Mode(), info.Size(), listTime(info.ModTime()), info.Name())
+	}
+	w.Flush()
+	conn.Close()
+	s.reply(226, "Transfer complete")
+}
+
+// ls -l 的时间格式，半年以内显示时间，否则显示年份
+func listTime(t time.Time) string {
+	if time.Since(t) < 180*24*time.Hour {
+		return t.Format("Jan _2 15:04")
+	}
+	return t.Format("Jan _2  2006")
+}
+
+func (s *ftpSession) retrieve(p string) {
+	rest := s.rest
+	s.rest = 0
+	fault, ok := s.fault(p, OpRead)
+	if !ok {
+		return
+	}
+	reader, size, err := s.server.fs.open(p)
+	if err != nil {
+		s.closeData()
+		s.reply(550, "%s", ftpError(err))
+		return
+	}
+	conn, err := s.openData()
+	if err != nil {
+		return
+	}
+	logger.Info("下载文件", "path", p, "offset", rest)
+	r := io.NewSectionReader(&faultReader{r: reader, fault: fault, disconnect: s.disconnect}, rest, max(size-rest, 0))
+	_, err = io.Copy(conn, r)
+	conn.Close()
+	if err != nil {
+		s.reply(426, "Connection closed; transfer aborted")
+		return
+	}
+	s.reply(226, "Transfer complete")
+}
+
+func (s *ftpSession) store(p string, appendData bool) {
+	rest := s.rest
+	s.rest = 0
+	fault, ok := s.fault(p, OpWrite)
+	if !ok {
+		return
+	}
+	if info, err := s.server.fs.stat(p); err == nil && info.IsDir() {
+		s.closeData()
+		s.reply(550, "Is a directory")
+		return
+	}
+	w := &uploadWriter{server: s.server, path: p, fault: fault, disconnect: s.disconnect}
+	// APPE 和 REST 续传时保留已有内容
+	if appendData || rest > 0 {
+		if reader, size, err := s.server.fs.open(p); err == nil {
+			w.data = make([]byte, size)
+			reader.ReadAt(w.data, 0)
+			if appendData {
+				rest = size
+			}
+			w.data = w.data[:min(rest, size)]
+		}
+	}
+	conn, err := s.openData()
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(io.NewOffsetWriter(w, rest), conn)
+	conn.Close()
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.reply(426, "Connection closed; transfer aborted")
+		return
+	}
+	s.reply(226, "Transfer complete")
+}
@@ -0,0 +1,205 @@
+// Package sftp_mock 通过 SFTP 和 FTP 提供内存中的虚拟文件系统，用于测试批量文件对接：
+// 文件由配置或按大小生成，客户端上传的文件记录到日志并可以保存到本地目录，按路径注入传输故障。
+package sftp_mock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/TreeWu/mock-go/logging"
+	"golang.org/x/crypto/ssh"
+)
+
+var logger = logging.For("sftp")
+
+// server SFTP 和 FTP 共用的文件系统、用户和故障配置
+type server struct {
+	config     *Config
+	fs         *vfs
+	captureDir string // 为空时上传的文件只保存在内存中
+}
+
+// Run 启动 SFTP 和 FTP 服务，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("sftp", flag.ContinueOnError)
+	configFile := fs.String("config", "", "json file with users, initial files and faults, any user and an empty filesystem when not set")
+	sftpPort := fs.String("sftp-port", envOr("SFTP_PORT", ":2222"), "sftp listen address, empty to disable (env SFTP_PORT)")
+	ftpPort := fs.String("ftp-port", envOr("FTP_PORT", ":2121"), "ftp listen address, empty to disable (env FTP_PORT)")
+	hostKeyFile := fs.String("host-key", "", "ssh host private key, a new ed25519 key on every start when empty")
+	captureDir := fs.String("capture-dir", "", "also save uploaded files to this directory")
+	passivePorts := fs.String("ftp-passive-ports", "", "port range for ftp passive data connections, e.g. 30000-30009, random ports when empty")
+	publicHost := fs.String("ftp-public-host", "", "ip address announced in ftp passive replies, the local address of the control connection when empty")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *sftpPort == "" && *ftpPort == "" {
+		logger.Error("-sftp-port and -ftp-port are both empty")
+		return 2
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		logger.Error("读取配置失败", "err", err)
+		return 1
+	}
+	files, err := config.newVFS()
+	if err != nil {
+		logger.Error("创建文件失败", "err", err)
+		return 1
+	}
+	s := &server{config: config, fs: files, captureDir: *captureDir}
+	ports, err := parsePortRange(*passivePorts)
+	if err != nil {
+		logger.Error("invalid -ftp-passive-ports", "err", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 2)
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	if *sftpPort != "" {
+		key, err := hostKey(*hostKeyFile)
+		if err != nil {
+			logger.Error("加载主机密钥失败", "err", err)
+			return 1
+		}
+		listener, err := net.Listen("tcp", listenAddr(*sftpPort))
+		if err != nil {
+			logger.Error("启动 SFTP 服务失败", "err", err)
+			return 1
+		}
+		listeners = append(listeners, listener)
+		logger.Info("SFTP Mock 服务器启动", "addr", listener.Addr().String(), "fingerprint", ssh.FingerprintSHA256(key.PublicKey()))
+		go func() { errCh <- s.serveSFTP(listener, s.sshConfig(key)) }()
+	}
+	if *ftpPort != "" {
+		listener, err := net.Listen("tcp", listenAddr(*ftpPort))
+		if err != nil {
+			logger.Error("启动 FTP 服务失败", "err", err)
+			return 1
+		}
+		listeners = append(listeners, listener)
+		logger.Info("FTP Mock 服务器启动", "addr", listener.Addr().String())
+		ftp := &ftpServer{server: s, publicHost: *publicHost, passivePorts: ports}
+		go func() { errCh <- ftp.serve(listener) }()
+	}
+
+	select {
+	case err := <-errCh:
+		logger.Error("服务异常退出", "err", err)
+		return 1
+	case <-ctx.Done():
+		logger.Info("正在停止 Mock 服务器")
+		return 0
+	}
+}
+
+// 保存上传的文件，complete 为 false 表示传输中断，只保存已经收到的部分
+func (s *server) upload(p string, data []byte, complete bool) error {
+	if err := s.fs.write(p, data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if complete {
+		logger.Info("收到上传", "path", cleanPath(p), "size", len(data), "sha256", hex.EncodeToString(sum[:]))
+	} else {
+		logger.Warn("上传中断", "path", cleanPath(p), "received", len(data))
+	}
+	if s.captureDir == "" {
+		return nil
+	}
+	target := filepath.Join(s.captureDir, filepath.FromSlash(cleanPath(p)))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		logger.Warn("保存上传文件失败", "path", target, "err", err)
+		return nil
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		logger.Warn("保存上传文件失败", "path", target, "err", err)
+	}
+	return nil
+}
+
+// uploadWriter 在内存中接收上传内容，关闭时写入虚拟文件系统。fault 在写满 after 字节后生效
+type uploadWriter struct {
+	server     *server
+	path       string
+	fault      *Fault
+	disconnect func()
+
+	mu     sync.Mutex
+	data   []byte
+	failed bool
+}
+
+func (w *uploadWriter) WriteAt(p []byte, off int64) (int, error) {
+	if w.fault != nil && w.fault.Type == FaultSlow {
+		throttle(len(p), w.fault.rate)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if f := w.fault; f != nil && f.Type != FaultSlow && off+int64(len(p)) > f.After {
+		p = p[:max(f.After-off, 0)]
+		w.failed = true
+		if f.Type == FaultDisconnect {
+			w.disconnect()
+		}
+		err = errInjected
+	}
+	if len(p) == 0 {
+		return 0, err
+	}
+	if end := off + int64(len(p)); end > int64(len(w.data)) {
+		w.data = append(w.data, make([]byte, end-int64(len(w.data)))...)
+	}
+	copy(w.data[off:], p)
+	return len(p), err
+}
+
+// 传输中断时 pkg/sftp 会调用 TransferError
+func (w *uploadWriter) TransferError(err error) {
+	w.mu.Lock()
+	w.failed = true
+	w.mu.Unlock()
+}
+
+func (w *uploadWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.server.upload(w.path, w.data, !w.failed)
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+// envOr 环境变量未设置时返回默认值，设置为空表示关闭对应的服务
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
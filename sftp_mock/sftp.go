@@ -0,0 +1,204 @@
+package sftp_mock
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// 加载 SSH 主机密钥，没有指定文件时生成临时的 ed25519 密钥
+func hostKey(file string) (ssh.Signer, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.ParsePrivateKey(data)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+func (s *server) sshConfig(key ssh.Signer) *ssh.ServerConfig {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if s.config.checkPassword(conn.User(), string(password)) {
+				return nil, nil
+			}
+			return nil, errors.New("invalid username or password")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if s.config.checkPublicKey(conn.User(), key) {
+				return nil, nil
+			}
+			return nil, errors.New("public key not allowed")
+		},
+	}
+	config.AddHostKey(key)
+	return config
+}
+
+func (s *server) serveSFTP(listener net.Listener, config *ssh.ServerConfig) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleSSH(conn, config)
+	}
+}
+
+// 只接受 session 通道上的 sftp 子系统请求
+func (s *server) handleSSH(conn net.Conn, config *ssh.ServerConfig) {
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		logger.Debug("SSH 握手失败", "client", conn.RemoteAddr().String(), "err", err)
+		conn.Close()
+		return
+	}
+	defer sshConn.Close()
+	logger.Info("SFTP 客户端登录", "client", conn.RemoteAddr().String(), "user", sshConn.User())
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go s.runSFTP(channel, sshConn)
+				}
+			}
+		}()
+	}
+}
+
+func (s *server) runSFTP(channel ssh.Channel, conn *ssh.ServerConn) {
+	defer channel.Close()
+	h := &sftpHandlers{server: s, disconnect: func() { conn.Close() }}
+	handlers := sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+	if err := sftp.NewRequestServer(channel, handlers).Serve(); err != nil && err != io.EOF {
+		logger.Debug("SFTP 会话结束", "user", conn.User(), "err", err)
+	}
+}
+
+// sftpHandlers 把 SFTP 请求转换为虚拟文件系统的操作
+type sftpHandlers struct {
+	server     *server
+	disconnect func()
+}
+
+// 没有 after 的 error、disconnect 故障在操作开始时直接生效
+func (h *sftpHandlers) check(p, op string) (*Fault, error) {
+	f := h.server.config.fault(p, op)
+	if f == nil || f.Type == FaultSlow || f.After > 0 {
+		return f, nil
+	}
+	if f.Type == FaultDisconnect {
+		h.disconnect()
+	}
+	return nil, os.ErrPermission
+}
+
+func (h *sftpHandlers) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	fault, err := h.check(r.Filepath, OpRead)
+	if err != nil {
+		return nil, err
+	}
+	reader, _, err := h.server.fs.open(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("下载文件", "path", r.Filepath)
+	return &faultReader{r: reader, fault: fault, disconnect: h.disconnect}, nil
+}
+
+func (h *sftpHandlers) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	fault, err := h.check(r.Filepath, OpWrite)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := h.server.fs.stat(r.Filepath); err == nil && info.IsDir() {
+		return nil, errors.New("is a directory")
+	}
+	w := &uploadWriter{server: h.server, path: r.Filepath, fault: fault, disconnect: h.disconnect}
+	// 追加写入时从已有内容开始
+	if r.Pflags().Append {
+		if reader, size, err := h.server.fs.open(r.Filepath); err == nil {
+			w.data = make([]byte, size)
+			reader.ReadAt(w.data, 0)
+		}
+	}
+	return w, nil
+}
+
+func (h *sftpHandlers) Filecmd(r *sftp.Request) error {
+	if _, err := h.check(r.Filepath, OpWrite); err != nil {
+		return err
+	}
+	fs := h.server.fs
+	switch r.Method {
+	case "Setstat":
+		_, err := fs.stat(r.Filepath)
+		return err
+	case "Rename":
+		logger.Info("重命名", "from", r.Filepath, "to", r.Target)
+		return fs.rename(r.Filepath, r.Target)
+	case "Remove":
+		logger.Info("删除文件", "path", r.Filepath)
+		return fs.remove(r.Filepath, false)
+	case "Rmdir":
+		return fs.remove(r.Filepath, true)
+	case "Mkdir":
+		return fs.mkdir(r.Filepath)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (h *sftpHandlers) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		if _, err := h.check(r.Filepath, OpList); err != nil {
+			return nil, err
+		}
+		infos, err := h.server.fs.list(r.Filepath)
+		return listerAt(infos), err
+	case "Stat":
+		info, err := h.server.fs.stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(dst []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, l[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,249 @@
+package sftp_mock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 生成文件的内容按块生成，每块由种子和块序号决定，任意偏移都能直接读取
+const generatedBlockSize = 64 << 10
+
+// vfs 内存中的虚拟文件系统，SFTP 和 FTP 共用。路径都是以 / 开头的绝对路径
+type vfs struct {
+	mu    sync.RWMutex
+	nodes map[string]*node
+}
+
+type node struct {
+	dir     bool
+	data    []byte
+	size    int64 // 生成文件的大小，data 为空时使用
+	seed    uint64
+	modTime time.Time
+}
+
+func newVFS() *vfs {
+	return &vfs{nodes: map[string]*node{"/": {dir: true, modTime: time.Now()}}}
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// 按需创建上级目录，只在加载配置时使用
+func (v *vfs) mkdirAll(p string) {
+	p = cleanPath(p)
+	for dir := p; ; dir = path.Dir(dir) {
+		if _, ok := v.nodes[dir]; !ok {
+			v.nodes[dir] = &node{dir: true, modTime: time.Now()}
+		}
+		if dir == "/" {
+			return
+		}
+	}
+}
+
+func (v *vfs) seed(p string, n *node) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = cleanPath(p)
+	v.mkdirAll(path.Dir(p))
+	n.modTime = time.Now()
+	v.nodes[p] = n
+}
+
+func (v *vfs) stat(p string) (os.FileInfo, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	p = cleanPath(p)
+	n, ok := v.nodes[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return n.info(path.Base(p)), nil
+}
+
+func (v *vfs) list(dir string) ([]os.FileInfo, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	dir = cleanPath(dir)
+	n, ok := v.nodes[dir]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if !n.dir {
+		return nil, errors.New("not a directory")
+	}
+	var infos []os.FileInfo
+	for p, child := range v.nodes {
+		if p != "/" && path.Dir(p) == dir {
+			infos = append(infos, child.info(path.Base(p)))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// 返回文件内容的 ReaderAt 和大小，内容在打开时确定，之后的修改不影响已打开的文件
+func (v *vfs) open(p string) (io.ReaderAt, int64, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	n, ok := v.nodes[cleanPath(p)]
+	switch {
+	case !ok:
+		return nil, 0, os.ErrNotExist
+	case n.dir:
+		return nil, 0, errors.New("is a directory")
+	case n.data == nil && n.size > 0:
+		return &generatedFile{seed: n.seed, size: n.size}, n.size, nil
+	}
+	// 写入时总是替换整个切片，已有的 data 不会被修改
+	return bytes.NewReader(n.data), int64(len(n.data)), nil
+}
+
+// 写入文件，上级目录必须存在
+func (v *vfs) write(p string, data []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = cleanPath(p)
+	if parent, ok := v.nodes[path.Dir(p)]; !ok || !parent.dir {
+		return os.ErrNotExist
+	}
+	if n, ok := v.nodes[p]; ok && n.dir {
+		return errors.New("is a directory")
+	}
+	v.nodes[p] = &node{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (v *vfs) mkdir(p string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = cleanPath(p)
+	if _, ok := v.nodes[p]; ok {
+		return os.ErrExist
+	}
+	if parent, ok := v.nodes[path.Dir(p)]; !ok || !parent.dir {
+		return os.ErrNotExist
+	}
+	v.nodes[p] = &node{dir: true, modTime: time.Now()}
+	return nil
+}
+
+// 删除文件或空目录
+func (v *vfs) remove(p string, dir bool) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = cleanPath(p)
+	n, ok := v.nodes[p]
+	switch {
+	case !ok:
+		return os.ErrNotExist
+	case p == "/":
+		return os.ErrPermission
+	case n.dir != dir && dir:
+		return errors.New("not a directory")
+	case n.dir != dir:
+		return errors.New("is a directory")
+	}
+	if dir {
+		for child := range v.nodes {
+			if strings.HasPrefix(child, p+"/") {
+				return errors.New("directory not empty")
+			}
+		}
+	}
+	delete(v.nodes, p)
+	return nil
+}
+
+// 移动文件或目录，目标已存在时返回错误
+func (v *vfs) rename(from, to string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	from, to = cleanPath(from), cleanPath(to)
+	if _, ok := v.nodes[from]; !ok || from == "/" {
+		return os.ErrNotExist
+	}
+	if _, ok := v.nodes[to]; ok {
+		return os.ErrExist
+	}
+	if parent, ok := v.nodes[path.Dir(to)]; !ok || !parent.dir {
+		return os.ErrNotExist
+	}
+	for p, n := range v.nodes {
+		if p == from || strings.HasPrefix(p, from+"/") {
+			delete(v.nodes, p)
+			v.nodes[to+strings.TrimPrefix(p, from)] = n
+		}
+	}
+	return nil
+}
+
+func (n *node) info(name string) os.FileInfo {
+	size := int64(len(n.data))
+	if n.data == nil {
+		size = n.size
+	}
+	return &fileInfo{name: name, size: size, dir: n.dir, modTime: n.modTime}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (f *fileInfo) Name() string       { return f.name }
+func (f *fileInfo) Size() int64        { return f.size }
+func (f *fileInfo) ModTime() time.Time { return f.modTime }
+func (f *fileInfo) IsDir() bool        { return f.dir }
+func (f *fileInfo) Sys() interface{}   { return nil }
+
+func (f *fileInfo) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// generatedFile 按种子生成的伪随机内容，相同种子和偏移的内容总是相同
+type generatedFile struct {
+	seed uint64
+	size int64
+}
+
+func (g *generatedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= g.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && off < g.size {
+		block := off / generatedBlockSize
+		var key [32]byte
+		binary.LittleEndian.PutUint64(key[:], g.seed)
+		binary.LittleEndian.PutUint64(key[8:], uint64(block))
+		data := make([]byte, generatedBlockSize)
+		rand.NewChaCha8(key).Read(data)
+		start := off - block*generatedBlockSize
+		end := min(int64(generatedBlockSize), g.size-block*generatedBlockSize, start+int64(len(p)-n))
+		copied := copy(p[n:], data[start:end])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
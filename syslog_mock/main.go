@@ -0,0 +1,186 @@
+// Package syslog_mock 接收 UDP 和 TCP 的 syslog 消息（RFC 5424、RFC 3164），保存在内存中并提供 HTTP 查询和断言接口，
+// 用于端到端验证日志采集代理和告警流水线。
+package syslog_mock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TreeWu/mock-go/logging"
+)
+
+var logger = logging.For("syslog")
+
+// TCP 单条消息的最大长度
+const maxMessageSize = 64 << 10
+
+// Run 启动 syslog 接收服务和查询接口，args 为命令行参数（不含命令名），返回进程退出码
+func Run(args []string) int {
+	fs := flag.NewFlagSet("syslog", flag.ContinueOnError)
+	udpPort := fs.String("udp-port", envOr("SYSLOG_UDP_PORT", ":5514"), "udp listen address, empty to disable (env SYSLOG_UDP_PORT)")
+	tcpPort := fs.String("tcp-port", envOr("SYSLOG_TCP_PORT", ":5514"), "tcp listen address, empty to disable (env SYSLOG_TCP_PORT)")
+	httpPort := fs.String("http-port", envOr("SYSLOG_HTTP_PORT", ":8514"), "query api listen address (env SYSLOG_HTTP_PORT)")
+	maxMessages := fs.Int("max", 10000, "messages kept in memory, older messages are dropped")
+	output := fs.String("o", "", "also append every message as a json line to this file")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *udpPort == "" && *tcpPort == "" {
+		logger.Error("-udp-port and -tcp-port are both empty")
+		return 2
+	}
+	if *maxMessages <= 0 {
+		logger.Error("-max must be positive")
+		return 2
+	}
+
+	var w io.Writer
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			logger.Error("打开输出文件失败", "err", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	s := newStore(*maxMessages, w)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 3)
+	if *udpPort != "" {
+		conn, err := net.ListenPacket("udp", listenAddr(*udpPort))
+		if err != nil {
+			logger.Error("启动 UDP 服务失败", "err", err)
+			return 1
+		}
+		defer conn.Close()
+		logger.Info("syslog UDP 服务启动", "addr", conn.LocalAddr().String())
+		go func() { errCh <- s.serveUDP(conn) }()
+	}
+	if *tcpPort != "" {
+		listener, err := net.Listen("tcp", listenAddr(*tcpPort))
+		if err != nil {
+			logger.Error("启动 TCP 服务失败", "err", err)
+			return 1
+		}
+		defer listener.Close()
+		logger.Info("syslog TCP 服务启动", "addr", listener.Addr().String())
+		go func() { errCh <- s.serveTCP(listener) }()
+	}
+	server := &http.Server{Addr: listenAddr(*httpPort), Handler: s.router()}
+	go func() { errCh <- server.ListenAndServe() }()
+	logger.Info("查询接口启动", "addr", server.Addr)
+
+	select {
+	case err := <-errCh:
+		logger.Error("服务异常退出", "err", err)
+		return 1
+	case <-ctx.Done():
+		logger.Info("正在停止 Mock 服务器")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		return 0
+	}
+}
+
+// 每个 UDP 报文是一条消息
+func (s *store) serveUDP(conn net.PacketConn) error {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		m := parse(buf[:n])
+		m.Received, m.Source, m.Transport = time.Now(), addr.String(), "udp"
+		s.add(m)
+	}
+}
+
+func (s *store) serveTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleTCP(conn)
+	}
+}
+
+// 按 RFC 6587 分帧：以数字开头时是 "长度 消息" 的 octet counting，否则按换行分隔
+func (s *store) handleTCP(conn net.Conn) {
+	defer conn.Close()
+	source := conn.RemoteAddr().String()
+	reader := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(reader)
+		if len(frame) > 0 {
+			m := parse(frame)
+			m.Received, m.Source, m.Transport = time.Now(), source, "tcp"
+			s.add(m)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Debug("读取消息失败", "source", source, "err", err)
+			}
+			return
+		}
+	}
+}
+
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := reader.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil || n > maxMessageSize {
+			return nil, errors.New("invalid octet count " + strconv.Quote(prefix))
+		}
+		frame := make([]byte, n)
+		_, err = io.ReadFull(reader, frame)
+		return frame, err
+	}
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, errors.New("message too long")
+	}
+	return line, err
+}
+
+// 只有端口号时监听所有网卡
+func listenAddr(port string) string {
+	if !strings.Contains(port, ":") {
+		return ":" + port
+	}
+	return port
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
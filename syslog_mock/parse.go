@@ -0,0 +1,186 @@
+package syslog_mock
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 消息格式
+const (
+	FormatRFC5424 = "rfc5424"
+	FormatRFC3164 = "rfc3164"
+	FormatRaw     = "raw" // 没有 PRI 或无法解析的消息，整行作为 message
+)
+
+// 没有 PRI 时按 RFC 3164 使用 user.notice
+const defaultPriority = 13
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Message 收到的一条 syslog 消息
+type Message struct {
+	ID             int64                        `json:"id"`
+	Received       time.Time                    `json:"received"`
+	Source         string                       `json:"source"`    // 发送方地址
+	Transport      string                       `json:"transport"` // udp 或 tcp
+	Format         string                       `json:"format"`
+	Facility       int                          `json:"facility"`
+	FacilityName   string                       `json:"facility_name"`
+	Severity       int                          `json:"severity"`
+	SeverityName   string                       `json:"severity_name"`
+	Timestamp      *time.Time                   `json:"timestamp,omitempty"` // 消息中的时间，没有或无法解析时为空
+	Hostname       string                       `json:"hostname,omitempty"`
+	AppName        string                       `json:"app_name,omitempty"` // RFC 3164 中的 TAG
+	ProcID         string                       `json:"proc_id,omitempty"`
+	MsgID          string                       `json:"msg_id,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+	Message        string                       `json:"message"`
+	Raw            string                       `json:"raw"`
+}
+
+// 解析一条 RFC 5424 或 RFC 3164 格式的消息，无法识别的部分保留在 message 中
+func parse(raw []byte) *Message {
+	raw = bytes.TrimRight(raw, "\r\n\x00")
+	m := &Message{Format: FormatRaw, Raw: string(raw), Message: string(raw)}
+	m.setPriority(defaultPriority)
+
+	rest := string(raw)
+	end := strings.IndexByte(rest, '>')
+	if !strings.HasPrefix(rest, "<") || end < 2 || end > 4 {
+		return m
+	}
+	priority, err := strconv.Atoi(rest[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return m
+	}
+	m.setPriority(priority)
+	rest = rest[end+1:]
+	m.Message = rest
+
+	if strings.HasPrefix(rest, "1 ") {
+		m.parse5424(rest[2:])
+	} else {
+		m.parse3164(rest)
+	}
+	return m
+}
+
+func (m *Message) setPriority(priority int) {
+	m.Facility, m.Severity = priority/8, priority%8
+	m.FacilityName, m.SeverityName = facilityNames[m.Facility], severityNames[m.Severity]
+}
+
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]，- 表示空值
+func (m *Message) parse5424(rest string) {
+	fields := make([]string, 5)
+	for i := range fields {
+		var ok bool
+		fields[i], rest, ok = strings.Cut(rest, " ")
+		if !ok && i < len(fields)-1 {
+			return
+		}
+		if fields[i] == "-" {
+			fields[i] = ""
+		}
+	}
+	m.Format = FormatRFC5424
+	if t, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		m.Timestamp = &t
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		m.StructuredData, rest = parseStructuredData(rest)
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	m.Message = strings.TrimPrefix(rest, "\ufeff") // MSG 可以以 UTF-8 BOM 开头
+}
+
+// [id key="value" ...][id2 ...]，值中的 \"、\\、\] 需要转义
+func parseStructuredData(s string) (map[string]map[string]string, string) {
+	data := map[string]map[string]string{}
+	for strings.HasPrefix(s, "[") {
+		i := 1
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		params := map[string]string{}
+		data[s[1:i]] = params
+		for i < len(s) && s[i] == ' ' {
+			i++
+			eq := strings.IndexByte(s[i:], '=')
+			if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+				return data, s[min(i, len(s)):]
+			}
+			name := s[i : i+eq]
+			i += eq + 2
+			var value strings.Builder
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			params[name] = value.String()
+			i++
+		}
+		if i >= len(s) {
+			return data, ""
+		}
+		s = s[i+1:]
+	}
+	return data, s
+}
+
+// TIMESTAMP HOSTNAME TAG[PID]: MSG。时间戳也接受 rsyslog 高精度模式的 RFC 3339 格式
+func (m *Message) parse3164(rest string) {
+	if len(rest) >= 15 {
+		if t, err := time.ParseInLocation(time.Stamp, rest[:15], time.Local); err == nil {
+			// RFC 3164 的时间没有年份，按当前年份补全，跨年时使用上一年
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = &t
+			rest = strings.TrimPrefix(rest[15:], " ")
+		}
+	}
+	if m.Timestamp == nil {
+		token, after, _ := strings.Cut(rest, " ")
+		t, err := time.Parse(time.RFC3339Nano, token)
+		if err != nil {
+			return
+		}
+		m.Timestamp = &t
+		rest = after
+	}
+	m.Format = FormatRFC3164
+	m.Hostname, rest, _ = strings.Cut(rest, " ")
+
+	// TAG 由字母数字组成，以 [ 或 : 结束，找不到时整段都是消息
+	end := strings.IndexAny(rest, "[: ")
+	if end > 0 && (rest[end] == '[' || rest[end] == ':') {
+		m.AppName = rest[:end]
+		rest = rest[end:]
+		if strings.HasPrefix(rest, "[") {
+			if j := strings.IndexByte(rest, ']'); j > 0 {
+				m.ProcID = rest[1:j]
+				rest = rest[j+1:]
+			}
+		}
+		rest = strings.TrimPrefix(rest, ":")
+		rest = strings.TrimPrefix(rest, " ")
+	}
+	m.Message = rest
+}
@@ -0,0 +1,252 @@
+package syslog_mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 断言接口默认的等待时间
+const defaultAssertTimeout = 5 * time.Second
+
+// store 保存最近收到的消息，超过 max 条后丢弃最早的消息
+type store struct {
+	mu       sync.Mutex
+	max      int
+	messages []*Message
+	nextID   int64
+	changed  chan struct{} // 收到消息时关闭并替换，用于断言接口等待新消息
+	output   io.Writer     // 不为空时每条消息追加一行 JSON
+}
+
+func newStore(max int, output io.Writer) *store {
+	return &store{max: max, nextID: 1, changed: make(chan struct{}), output: output}
+}
+
+func (s *store) add(m *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.ID = s.nextID
+	s.nextID++
+	s.messages = append(s.messages, m)
+	if len(s.messages) > s.max {
+		s.messages = append(s.messages[:0], s.messages[len(s.messages)-s.max:]...)
+	}
+	if s.output != nil {
+		line, _ := json.Marshal(m)
+		s.output.Write(append(line, '\n'))
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+	logger.Debug("收到消息", "source", m.Source, "severity", m.SeverityName, "app", m.AppName, "message", m.Message)
+}
+
+// 返回匹配的消息和下次收到消息时关闭的通道
+func (s *store) list(f *filter) ([]Message, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []Message{}
+	for _, m := range s.messages {
+		if f.match(m) {
+			result = append(result, *m)
+		}
+	}
+	return result, s.changed
+}
+
+func (s *store) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// filter 查询和断言共用的条件，零值表示不限制
+type filter struct {
+	severity  int // 只匹配不低于这个级别的消息，-1 表示不限制
+	facility  int // -1 表示不限制
+	host      string
+	app       string
+	msgID     string
+	transport string
+	contains  string
+	pattern   *regexp.Regexp
+	since     int64 // 只匹配 ID 大于 since 的消息
+}
+
+func parseFilter(c *gin.Context) (*filter, error) {
+	f := &filter{
+		severity:  -1,
+		facility:  -1,
+		host:      c.Query("host"),
+		app:       c.Query("app"),
+		msgID:     c.Query("msgid"),
+		transport: c.Query("transport"),
+		contains:  c.Query("contains"),
+	}
+	var err error
+	if v := c.Query("severity"); v != "" {
+		if f.severity, err = lookupName(v, severityNames); err != nil {
+			return nil, fmt.Errorf("invalid severity %q", v)
+		}
+	}
+	if v := c.Query("facility"); v != "" {
+		if f.facility, err = lookupName(v, facilityNames); err != nil {
+			return nil, fmt.Errorf("invalid facility %q", v)
+		}
+	}
+	if v := c.Query("match"); v != "" {
+		if f.pattern, err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("invalid match: %v", err)
+		}
+	}
+	if v := c.Query("since"); v != "" {
+		if f.since, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid since %q", v)
+		}
+	}
+	return f, nil
+}
+
+// 名称或数字，如 warning 或 4
+func lookupName(v string, names []string) (int, error) {
+	if i := slices.Index(names, strings.ToLower(v)); i >= 0 {
+		return i, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n >= len(names) {
+		return 0, fmt.Errorf("unknown name %q", v)
+	}
+	return n, nil
+}
+
+func (f *filter) match(m *Message) bool {
+	switch {
+	case m.ID <= f.since:
+		return false
+	case f.severity >= 0 && m.Severity > f.severity:
+		return false
+	case f.facility >= 0 && m.Facility != f.facility:
+		return false
+	case f.host != "" && !strings.EqualFold(m.Hostname, f.host):
+		return false
+	case f.app != "" && m.AppName != f.app:
+		return false
+	case f.msgID != "" && m.MsgID != f.msgID:
+		return false
+	case f.transport != "" && m.Transport != f.transport:
+		return false
+	case f.contains != "" && !strings.Contains(m.Message, f.contains):
+		return false
+	case f.pattern != nil && !f.pattern.MatchString(m.Message):
+		return false
+	}
+	return true
+}
+
+// 查询接口：GET /messages 按条件查询，GET /assert 等待满足数量条件，DELETE /messages 清空
+func (s *store) router() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/messages", func(c *gin.Context) {
+		f, err := parseFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		messages, _ := s.list(f)
+		if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit >= 0 && limit < len(messages) {
+			messages = messages[len(messages)-limit:]
+		}
+		c.JSON(http.StatusOK, messages)
+	})
+	router.GET("/messages/:id", func(c *gin.Context) {
+		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+		messages, _ := s.list(&filter{severity: -1, facility: -1, since: id - 1})
+		if len(messages) == 0 || messages[0].ID != id {
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+			return
+		}
+		c.JSON(http.StatusOK, messages[0])
+	})
+	router.DELETE("/messages", func(c *gin.Context) {
+		s.clear()
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/assert", s.assert)
+	return router
+}
+
+// 等待匹配的消息数量满足 count、min、max 条件，都没有设置时相当于 min=1。
+// 满足时返回 200，超时返回 417 和最后一次的数量，适合在脚本中用 curl -f 检查
+func (s *store) assert(c *gin.Context) {
+	f, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bounds := map[string]int{}
+	for _, name := range []string{"count", "min", "max"} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s %q", name, v)})
+				return
+			}
+			bounds[name] = n
+		}
+	}
+	if len(bounds) == 0 {
+		bounds["min"] = 1
+	}
+	timeout := defaultAssertTimeout
+	if v := c.Query("timeout"); v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid timeout %q", v)})
+			return
+		}
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		messages, changed := s.list(f)
+		if satisfied(len(messages), bounds) {
+			c.JSON(http.StatusOK, gin.H{"ok": true, "count": len(messages), "messages": messages})
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			c.JSON(http.StatusExpectationFailed, gin.H{"ok": false, "count": len(messages), "expected": bounds, "messages": messages})
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func satisfied(n int, bounds map[string]int) bool {
+	if v, ok := bounds["count"]; ok && n != v {
+		return false
+	}
+	if v, ok := bounds["min"]; ok && n < v {
+		return false
+	}
+	if v, ok := bounds["max"]; ok && n > v {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,380 @@
+package targets
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultFile 没有通过 --targets 指定时，当前目录下存在该文件就自动加载
+const DefaultFile = "targets.yaml"
+
+// 数据库类型
+const (
+	KindPostgres = "pg"
+	KindMySQL    = "mysql"
+	KindMongo    = "mongo"
+	KindRedis    = "redis"
+	KindEtcd     = "etcd"
+)
+
+// File 共享的连接配置文件，数据库、ES 集群和 SSH 凭据按名称定义一次，各命令通过名称引用。
+// 名称在三类之间不能重复，password、uri 和 api_key 中的 ${VAR} 按环境变量展开
+type File struct {
+	Databases     map[string]Database    `yaml:"databases"`
+	Elasticsearch map[string]Cluster     `yaml:"elasticsearch"`
+	SSH           map[string]Credentials `yaml:"ssh"`
+
+	path string
+}
+
+// Database 一个数据库连接
+type Database struct {
+	Kind      string   `yaml:"kind"` // pg、mysql、mongo、redis 或 etcd
+	Host      string   `yaml:"host"`
+	Port      int      `yaml:"port"`
+	Endpoints []string `yaml:"endpoints"` // etcd 的地址，为空时使用 host:port
+	User      string   `yaml:"user"`
+	Password  string   `yaml:"password"`
+	Database  string   `yaml:"database"` // pg、mysql、mongo 的库名，redis 的库编号
+	SSLMode   string   `yaml:"sslmode"`  // pg 的 sslmode
+	URI       string   `yaml:"uri"`      // mongo 的 uri 或 mysql 的 dsn，设置后代替 host 等字段
+}
+
+// Cluster 一个 ES 集群
+type Cluster struct {
+	Addresses []string `yaml:"addresses"`
+	Username  string   `yaml:"username"`
+	Password  string   `yaml:"password"`
+	APIKey    string   `yaml:"api_key"`
+	CACert    string   `yaml:"ca_cert"`
+}
+
+// Credentials 一组 SSH 凭据
+type Credentials struct {
+	User       string   `yaml:"user"`
+	Password   string   `yaml:"password"`
+	Port       int      `yaml:"port"`
+	Keys       []string `yaml:"keys"`    // 依次尝试的私钥文件，最后尝试密码
+	Bastion    string   `yaml:"bastion"` // 跳板机，格式为 user:password@host:port
+	Become     string   `yaml:"become"`  // sudo 或 su
+	BecomeUser string   `yaml:"become_user"`
+}
+
+var loaded atomic.Pointer[File]
+
+// Load 读取连接配置文件，之后按名称查找的都是该文件中的条目
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read targets: %v", err)
+	}
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse targets %s: %v", path, err)
+	}
+	if err := file.check(); err != nil {
+		return fmt.Errorf("targets %s: %v", path, err)
+	}
+	file.path = path
+	loaded.Store(&file)
+	return nil
+}
+
+// Path 已加载的连接配置文件，没有加载时为空
+func Path() string {
+	if file := loaded.Load(); file != nil {
+		return file.path
+	}
+	return ""
+}
+
+// 检查名称和数据库类型，展开环境变量
+func (f *File) check() error {
+	seen := make(map[string]string)
+	add := func(section, name string) error {
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("name %q is defined in both %s and %s", name, other, section)
+		}
+		seen[name] = section
+		return nil
+	}
+	for name, db := range f.Databases {
+		if err := add("databases", name); err != nil {
+			return err
+		}
+		switch db.Kind {
+		case KindPostgres, KindMySQL, KindMongo, KindRedis, KindEtcd:
+		default:
+			return fmt.Errorf("databases.%s: unknown kind %q, want pg, mysql, mongo, redis or etcd", name, db.Kind)
+		}
+		db.Password, db.URI = os.ExpandEnv(db.Password), os.ExpandEnv(db.URI)
+		f.Databases[name] = db
+	}
+	for name, cluster := range f.Elasticsearch {
+		if err := add("elasticsearch", name); err != nil {
+			return err
+		}
+		cluster.Password, cluster.APIKey = os.ExpandEnv(cluster.Password), os.ExpandEnv(cluster.APIKey)
+		f.Elasticsearch[name] = cluster
+	}
+	for name, creds := range f.SSH {
+		if err := add("ssh", name); err != nil {
+			return err
+		}
+		creds.Password, creds.Bastion = os.ExpandEnv(creds.Password), os.ExpandEnv(creds.Bastion)
+		f.SSH[name] = creds
+	}
+	return nil
+}
+
+func current() (*File, error) {
+	file := loaded.Load()
+	if file == nil {
+		return nil, errors.New("no targets file loaded, pass --targets or create " + DefaultFile)
+	}
+	return file, nil
+}
+
+func unknown[T any](section, name string, entries map[string]T) error {
+	names := make([]string, 0, len(entries))
+	for n := range entries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%s %q not found in %s (available: %s)", section, name, Path(), strings.Join(names, ", "))
+}
+
+// FindDatabase 按名称查找数据库，kind 不为空时还要求类型一致
+func FindDatabase(name, kind string) (Database, error) {
+	file, err := current()
+	if err != nil {
+		return Database{}, err
+	}
+	db, ok := file.Databases[name]
+	if !ok {
+		return Database{}, unknown("database", name, file.Databases)
+	}
+	if kind != "" && db.Kind != kind {
+		return Database{}, fmt.Errorf("database %q is %s, not %s", name, db.Kind, kind)
+	}
+	return db, nil
+}
+
+// FindCluster 按名称查找 ES 集群
+func FindCluster(name string) (Cluster, error) {
+	file, err := current()
+	if err != nil {
+		return Cluster{}, err
+	}
+	cluster, ok := file.Elasticsearch[name]
+	if !ok {
+		return Cluster{}, unknown("elasticsearch cluster", name, file.Elasticsearch)
+	}
+	return cluster, nil
+}
+
+// FindCredentials 按名称查找 SSH 凭据
+func FindCredentials(name string) (Credentials, error) {
+	file, err := current()
+	if err != nil {
+		return Credentials{}, err
+	}
+	creds, ok := file.SSH[name]
+	if !ok {
+		return Credentials{}, unknown("ssh credentials", name, file.SSH)
+	}
+	return creds, nil
+}
+
+// FindSink 按名称查找数据库或 ES 集群，返回写入结果用的类型（es、pg 或 mongo）和连接串
+func FindSink(name string) (kind, address string, err error) {
+	file, err := current()
+	if err != nil {
+		return "", "", err
+	}
+	if cluster, ok := file.Elasticsearch[name]; ok {
+		// 结果输出只接受地址，用户名和密码放在地址中
+		addresses := make([]string, len(cluster.Addresses))
+		for i, address := range cluster.Addresses {
+			addresses[i] = address
+			if u, err := url.Parse(address); err == nil && cluster.Username != "" {
+				u.User = url.UserPassword(cluster.Username, cluster.Password)
+				addresses[i] = u.String()
+			}
+		}
+		return "es", strings.Join(addresses, ","), nil
+	}
+	db, ok := file.Databases[name]
+	if !ok {
+		return "", "", fmt.Errorf("database or elasticsearch cluster %q not found in %s", name, Path())
+	}
+	if db.Kind != KindPostgres && db.Kind != KindMongo {
+		return "", "", fmt.Errorf("database %q is %s, results can only be written to es, pg or mongo", name, db.Kind)
+	}
+	return db.Kind, db.URL(), nil
+}
+
+// Address host:port，没有设置端口时使用该类型的默认端口
+func (d Database) Address() string {
+	port := d.Port
+	if port == 0 {
+		port = map[string]int{KindPostgres: 5432, KindMySQL: 3306, KindMongo: 27017, KindRedis: 6379, KindEtcd: 2379}[d.Kind]
+	}
+	host := d.Host
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// URL 连接串：pg 为 postgres:// 地址，mongo 为 uri，mysql 为 dsn，redis 和 etcd 为 host:port
+func (d Database) URL() string {
+	if d.URI != "" {
+		return d.URI
+	}
+	switch d.Kind {
+	case KindPostgres, KindMongo:
+		u := url.URL{Scheme: "postgres", Host: d.Address(), Path: "/" + d.Database}
+		if d.Kind == KindMongo {
+			u.Scheme, u.Path = "mongodb", ""
+		}
+		if d.User != "" {
+			u.User = url.UserPassword(d.User, d.Password)
+		}
+		if d.Kind == KindPostgres && d.SSLMode != "" {
+			u.RawQuery = "sslmode=" + url.QueryEscape(d.SSLMode)
+		}
+		return u.String()
+	case KindMySQL:
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s", d.User, d.Password, d.Address(), d.Database)
+	}
+	return d.Address()
+}
+
+// Flags 转换成 prefix 开头的命令行参数，如 pg- 前缀得到 pg-host、pg-port 等，空值不返回
+func (d Database) Flags(prefix string) map[string]string {
+	flags := map[string]string{}
+	switch d.Kind {
+	case KindPostgres:
+		flags["host"], flags["user"], flags["password"], flags["db"] = d.Host, d.User, d.Password, d.Database
+		flags["sslmode"] = d.SSLMode
+		if d.Port != 0 {
+			flags["port"] = strconv.Itoa(d.Port)
+		}
+	case KindMySQL:
+		flags["dsn"] = d.URL()
+	case KindMongo:
+		flags["uri"], flags["db"] = d.URL(), d.Database
+	case KindRedis:
+		flags["addr"], flags["password"], flags["db"] = d.Address(), d.Password, d.Database
+	case KindEtcd:
+		endpoints := d.Endpoints
+		if len(endpoints) == 0 {
+			endpoints = []string{d.Address()}
+		}
+		flags["endpoints"], flags["username"], flags["password"] = strings.Join(endpoints, ","), d.User, d.Password
+	}
+	return prefixed(prefix, flags)
+}
+
+// URL 逗号分隔的集群地址
+func (c Cluster) URL() string {
+	return strings.Join(c.Addresses, ",")
+}
+
+// Flags 转换成 prefix 开头的命令行参数：address、username、password、api-key 和 ca-cert
+func (c Cluster) Flags(prefix string) map[string]string {
+	return prefixed(prefix, map[string]string{
+		"address":  c.URL(),
+		"username": c.Username,
+		"password": c.Password,
+		"api-key":  c.APIKey,
+		"ca-cert":  c.CACert,
+	})
+}
+
+// Flags 转换成 scan 的命令行参数
+func (c Credentials) Flags() map[string]string {
+	flags := map[string]string{
+		"user":        c.User,
+		"password":    c.Password,
+		"key":         strings.Join(c.Keys, ","),
+		"bastion":     c.Bastion,
+		"become":      c.Become,
+		"become-user": c.BecomeUser,
+	}
+	if c.Port != 0 {
+		flags["port"] = strconv.Itoa(c.Port)
+	}
+	return prefixed("", flags)
+}
+
+func prefixed(prefix string, flags map[string]string) map[string]string {
+	result := make(map[string]string, len(flags))
+	for name, v := range flags {
+		if v != "" {
+			result[prefix+name] = v
+		}
+	}
+	return result
+}
+
+// ApplyDatabase 查找类型为 kind 的数据库 name，写入 prefix 开头的参数，name 为空时不做任何事
+func ApplyDatabase(fs *flag.FlagSet, name, kind, prefix string) ([]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	db, err := FindDatabase(name, kind)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(fs, db.Flags(prefix))
+}
+
+// ApplyCluster 查找 ES 集群 name，写入 prefix 开头的参数，name 为空时不做任何事
+func ApplyCluster(fs *flag.FlagSet, name, prefix string) ([]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	cluster, err := FindCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(fs, cluster.Flags(prefix))
+}
+
+// Apply 把连接参数写入 FlagSet 中没有在命令行上给出的参数，命令行参数优先。
+// 返回 FlagSet 中没有定义、因此被忽略的参数名
+func Apply(fs *flag.FlagSet, flags map[string]string) ([]string, error) {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ignored []string
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			ignored = append(ignored, name)
+			continue
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, flags[name]); err != nil {
+			return nil, fmt.Errorf("-%s: %v", name, err)
+		}
+	}
+	return ignored, nil
+}
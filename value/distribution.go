@@ -0,0 +1,170 @@
+package value
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/goccy/go-yaml"
+)
+
+// distribution 按权重取值的分类分布
+type distribution struct {
+	values     []interface{}
+	cumulative []float64 // 累计权重，最后一个为总权重
+}
+
+// 命名的分布，@dist:<名称> 引用，重新加载时整体替换
+var (
+	distributions     atomic.Pointer[map[string]*distribution]
+	distributionsFile atomic.Value // 最近一次加载的文件，ReloadDistributions 重新读取
+)
+
+// LoadDistributions 读取命名分布文件（YAML 或 JSON）并替换已加载的分布。每个分布是值到权重的映射，
+// 或者需要保留值类型（如整数）时使用 [{value: 2, weight: 50}, ...] 列表
+func LoadDistributions(path string) error {
+	loaded, err := readDistributions(path)
+	if err != nil {
+		return err
+	}
+	distributions.Store(&loaded)
+	distributionsFile.Store(path)
+	logger.Info("加载命名分布", "file", path, "count", len(loaded))
+	return nil
+}
+
+// DistributionsFile 返回加载的命名分布文件，没有加载时为空
+func DistributionsFile() string {
+	path, _ := distributionsFile.Load().(string)
+	return path
+}
+
+// ReloadDistributions 重新读取命名分布文件，返回分布的数量。没有加载过时什么都不做，读取失败时保留当前的分布
+func ReloadDistributions() (int, error) {
+	path := DistributionsFile()
+	if path == "" {
+		return 0, nil
+	}
+	if err := LoadDistributions(path); err != nil {
+		return 0, err
+	}
+	return len(*distributions.Load()), nil
+}
+
+func readDistributions(path string) (map[string]*distribution, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read distributions: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("parse distributions %s: %v", path, err)
+	}
+	loaded := make(map[string]*distribution, len(raw))
+	for name, spec := range raw {
+		d, err := parseDistribution(spec)
+		if err != nil {
+			return nil, fmt.Errorf("distribution %s in %s: %v", name, path, err)
+		}
+		loaded[name] = d
+	}
+	return loaded, nil
+}
+
+func parseDistribution(spec interface{}) (*distribution, error) {
+	d := &distribution{}
+	add := func(v interface{}, weight interface{}) error {
+		w, ok := toWeight(weight)
+		if !ok {
+			return fmt.Errorf("weight of %v must be a non-negative number, got %v", v, weight)
+		}
+		total := w
+		if n := len(d.cumulative); n > 0 {
+			total += d.cumulative[n-1]
+		}
+		d.values = append(d.values, v)
+		d.cumulative = append(d.cumulative, total)
+		return nil
+	}
+	switch spec := spec.(type) {
+	case map[string]interface{}:
+		// 按值排序，固定种子时每次生成的数据相同
+		keys := make([]string, 0, len(spec))
+		for k := range spec {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := add(k, spec[k]); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range spec {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("item %d must be {value, weight}", i)
+			}
+			v, ok := entry["value"]
+			if !ok {
+				return nil, fmt.Errorf("item %d has no value", i)
+			}
+			if err := add(v, entry["weight"]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("must be a map of value to weight or a list of {value, weight}")
+	}
+	if len(d.values) == 0 || d.cumulative[len(d.cumulative)-1] <= 0 {
+		return nil, fmt.Errorf("needs at least one value with a positive weight")
+	}
+	return d, nil
+}
+
+func toWeight(v interface{}) (float64, bool) {
+	var w float64
+	switch v := v.(type) {
+	case int:
+		w = float64(v)
+	case int64:
+		w = float64(v)
+	case uint64:
+		w = float64(v)
+	case float64:
+		w = v
+	default:
+		return 0, false
+	}
+	return w, w >= 0
+}
+
+// HasDistribution 是否加载了指定名称的分布
+func HasDistribution(name string) bool {
+	_, ok := lookupDistribution(name)
+	return ok
+}
+
+func lookupDistribution(name string) (*distribution, bool) {
+	loaded := distributions.Load()
+	if loaded == nil {
+		return nil, false
+	}
+	d, ok := (*loaded)[name]
+	return d, ok
+}
+
+// 按权重取一个值，分布不存在时返回 false
+func (h *Handler) pickDistribution(name string) (interface{}, bool) {
+	d, ok := lookupDistribution(name)
+	if !ok {
+		return nil, false
+	}
+	target := h.r.Float64() * d.cumulative[len(d.cumulative)-1]
+	i := sort.Search(len(d.cumulative), func(i int) bool { return d.cumulative[i] > target })
+	if i == len(d.values) {
+		i--
+	}
+	return d.values[i], true
+}
@@ -0,0 +1,73 @@
+package value
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func loadTestDistributions(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "distributions.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDistributions(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPickDistribution(t *testing.T) {
+	loadTestDistributions(t, `
+status: {active: 80, disabled: 20}
+never: {a: 0, b: 1}
+retries:
+  - {value: 2, weight: 1}
+`)
+	h := NewValueHandler()
+	tests := []struct {
+		name  string
+		allow map[interface{}]bool
+	}{
+		{"status", map[interface{}]bool{"active": true, "disabled": true}},
+		{"never", map[interface{}]bool{"b": true}},
+		{"retries", map[interface{}]bool{uint64(2): true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 200; i++ {
+				v, ok := h.pickDistribution(tt.name)
+				if !ok || !tt.allow[v] {
+					t.Fatalf("pickDistribution(%q) = %v (%T), %v", tt.name, v, v, ok)
+				}
+			}
+		})
+	}
+	if _, ok := h.pickDistribution("missing"); ok {
+		t.Fatal("missing distribution was picked")
+	}
+	if got := h.generateDynamicValue("@dist:missing"); got != "@dist:missing" {
+		t.Fatalf("unknown distribution returned %v, want the placeholder", got)
+	}
+}
+
+// http mock 的所有请求共用一个 Handler，go test -race 检查并发取值
+func TestPickDistributionConcurrent(t *testing.T) {
+	loadTestDistributions(t, "status: {active: 80, disabled: 20}\n")
+	h := NewValueHandler()
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				if v := h.ProcessDynamicValues("@dist:status"); v != "active" && v != "disabled" {
+					t.Errorf("unexpected value %v", v)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,52 @@
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 每个 @dup 占位符最多保留的已生成值，超过后随机替换
+const maxDuplicateValues = 10000
+
+// 解析 @dup 的参数 <百分比>:<指令>，如 1:@email
+func parseDuplicate(args string) (float64, string, error) {
+	rate, inner, ok := strings.Cut(args, ":")
+	if !ok || !strings.HasPrefix(inner, "@") {
+		return 0, "", fmt.Errorf("expects <percent>:<directive>, e.g. @dup:1:@email")
+	}
+	percent, err := strconv.ParseFloat(rate, 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, "", fmt.Errorf("percent must be a number in [0, 100], got %q", rate)
+	}
+	return percent, inner, nil
+}
+
+// @dup:<百分比>:<指令> 按百分比返回同一占位符之前生成过的值，其余按指令生成，用于制造可控比例的重复数据
+func (h *Handler) generateDuplicate(placeholder, args string) interface{} {
+	percent, inner, err := parseDuplicate(args)
+	if err != nil {
+		return placeholder
+	}
+	h.mu.Lock()
+	if seen := h.seen[placeholder]; len(seen) > 0 && h.r.Float64()*100 < percent {
+		v := seen[h.r.Intn(len(seen))]
+		h.mu.Unlock()
+		return v
+	}
+	h.mu.Unlock()
+
+	// 生成时不持有锁，内层指令也可能是 @dup
+	v := h.generate(inner)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.seen == nil {
+		h.seen = make(map[string][]interface{})
+	}
+	if seen := h.seen[placeholder]; len(seen) < maxDuplicateValues {
+		h.seen[placeholder] = append(seen, v)
+	} else {
+		seen[h.r.Intn(len(seen))] = v
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package value
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParseDuplicate(t *testing.T) {
+	tests := []struct {
+		args    string
+		percent float64
+		inner   string
+		wantErr bool
+	}{
+		{"1:@email", 1, "@email", false},
+		{"12.5:@randInt:6", 12.5, "@randInt:6", false},
+		{"0:@uuid", 0, "@uuid", false},
+		{"100:@dup:50:@name", 100, "@dup:50:@name", false},
+		{"@email", 0, "", true},
+		{"1:email", 0, "", true},
+		{"x:@email", 0, "", true},
+		{"101:@email", 0, "", true},
+		{"-1:@email", 0, "", true},
+	}
+	for _, tt := range tests {
+		percent, inner, err := parseDuplicate(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDuplicate(%q) err = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (percent != tt.percent || inner != tt.inner) {
+			t.Errorf("parseDuplicate(%q) = %v, %q, want %v, %q", tt.args, percent, inner, tt.percent, tt.inner)
+		}
+	}
+}
+
+func TestGenerateDuplicate(t *testing.T) {
+	tests := []struct {
+		placeholder string
+		wantDup     func(distinct, total int) bool
+	}{
+		// 0% 不重复
+		{"@dup:0:@uuid", func(distinct, total int) bool { return distinct == total }},
+		// 100% 之后都是第一个值
+		{"@dup:100:@uuid", func(distinct, total int) bool { return distinct == 1 }},
+		{"@dup:50:@uuid", func(distinct, total int) bool { return distinct > total/4 && distinct < total*3/4 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.placeholder, func(t *testing.T) {
+			h := NewValueHandler()
+			const total = 1000
+			distinct := map[interface{}]bool{}
+			for i := 0; i < total; i++ {
+				distinct[h.ProcessDynamicValues(tt.placeholder)] = true
+			}
+			if !tt.wantDup(len(distinct), total) {
+				t.Fatalf("%d distinct values out of %d", len(distinct), total)
+			}
+		})
+	}
+
+	h := NewValueHandler()
+	if got := h.ProcessDynamicValues("@dup:abc"); got != "@dup:abc" {
+		t.Fatalf("invalid @dup returned %v, want the placeholder", got)
+	}
+}
+
+// http mock、grpc mock 等所有请求协程共用一个 Handler，go test -race 检查 @dup 的并发读写
+func TestGenerateDuplicateConcurrent(t *testing.T) {
+	h := NewValueHandler()
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				if _, ok := h.ProcessDynamicValues("@dup:10:@email").(string); !ok {
+					t.Error("@dup:10:@email did not return a string")
+					return
+				}
+				h.ProcessDynamicValues("@dup:50:@dup:50:@randString:8")
+			}
+		}()
+	}
+	wg.Wait()
+}
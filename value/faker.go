@@ -0,0 +1,41 @@
+package value
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// faker 指令使用的假数据生成器。指令只依赖这个接口，升级或更换生成器的实现时占位符的行为不变
+type faker interface {
+	Email() string
+	Name() string
+	Word() string
+	Sentence() string
+	UUID() string
+	Date() time.Time
+	Bool() bool
+	Int64() int64
+	IntRange(min, max int) int
+	Float64Range(min, max float64) float64
+	CurrencyShort() string
+	CurrencyLong() string
+	Price(min, max float64) float64
+	Country() string
+	CountryAbr() string
+	LanguageBCP() string
+}
+
+// seed 为 0 时使用随机种子
+func newFaker(seed int64) faker {
+	return gofakeitFaker{gofakeit.New(uint64(seed))}
+}
+
+// gofakeitFaker 基于 gofakeit v7 的实现
+type gofakeitFaker struct {
+	*gofakeit.Faker
+}
+
+func (f gofakeitFaker) Sentence() string {
+	return f.Faker.Sentence(5)
+}
@@ -0,0 +1,154 @@
+package value
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的指令，值为参数的说明，为空表示不接受参数
+var directives = map[string]string{
+	"@randInt":      "digits",
+	"@randString":   "length",
+	"@email":        "",
+	"@name":         "",
+	"@word":         "",
+	"@sentence":     "",
+	"@uuid":         "",
+	"@timestamp":    "",
+	"@date":         "",
+	"@datetime":     "",
+	"@bool":         "",
+	"@float":        "",
+	"@price":        "",
+	"@currency":     "",
+	"@currencyName": "",
+	"@country":      "",
+	"@countryCode":  "",
+	"@locale":       "",
+	"@dist":         "name",
+	"@dup":          "percent:directive",
+}
+
+// int64 最多 18 位十进制数不会溢出
+const maxRandIntDigits = 18
+
+// Lint 检查模板中的占位符，返回 "路径: 问题" 形式的说明：未知的指令、参数格式错误，
+// 以及写在文本中间不会被替换的占位符。这些问题运行时不会报错，只会原样返回占位符或忽略参数
+func Lint(root string, body interface{}) []string {
+	var problems []string
+	lint(root, body, &problems)
+	return problems
+}
+
+func lint(path string, body interface{}, problems *[]string) {
+	switch v := body.(type) {
+	case string:
+		if problem := lintString(v); problem != "" {
+			*problems = append(*problems, path+": "+problem)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			lint(child, v[k], problems)
+		}
+	case []interface{}:
+		for i, item := range v {
+			lint(fmt.Sprintf("%s[%d]", path, i), item, problems)
+		}
+	}
+}
+
+func lintString(s string) string {
+	if strings.HasPrefix(s, "@") {
+		directive, args, hasArgs := strings.Cut(s, ":")
+		if !isDirectiveName(directive) {
+			return ""
+		}
+		kind, ok := directives[directive]
+		if !ok {
+			return fmt.Sprintf("unknown directive %s, the string is returned as is", directive)
+		}
+		if directive == "@dup" {
+			_, inner, err := parseDuplicate(args)
+			if err != nil {
+				return fmt.Sprintf("@dup %v, the string is returned as is", err)
+			}
+			if inner == "@dup" || strings.HasPrefix(inner, "@dup:") {
+				return "@dup cannot wrap another @dup"
+			}
+			return lintString(inner)
+		}
+		if directive == "@dist" {
+			switch {
+			case args == "":
+				return "@dist expects a distribution name, the string is returned as is"
+			case !HasDistribution(args):
+				return fmt.Sprintf("unknown distribution %q, the string is returned as is (load distributions with --distributions)", args)
+			}
+			return ""
+		}
+		if !hasArgs {
+			return ""
+		}
+		if kind == "" {
+			return fmt.Sprintf("%s does not take arguments, %q is ignored", directive, args)
+		}
+		n, err := strconv.Atoi(args)
+		switch {
+		case err != nil || n < 0:
+			return fmt.Sprintf("%s expects a non-negative %s, got %q, the default is used", directive, kind, args)
+		case directive == "@randInt" && n > maxRandIntDigits:
+			return fmt.Sprintf("@randInt supports at most %d digits, got %d", maxRandIntDigits, n)
+		}
+		return ""
+	}
+	// 只有整个字符串是占位符时才会替换
+	for i := strings.IndexByte(s, '@'); i >= 0; i = nextAt(s, i) {
+		if i > 0 && isWordByte(s[i-1]) {
+			continue
+		}
+		end := i + 1
+		for end < len(s) && isWordByte(s[end]) {
+			end++
+		}
+		if _, ok := directives[s[i:end]]; ok {
+			return fmt.Sprintf("placeholder %s inside text is not replaced, only whole-string placeholders are", s[i:end])
+		}
+	}
+	return ""
+}
+
+func nextAt(s string, i int) int {
+	j := strings.IndexByte(s[i+1:], '@')
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// 形如 @name 的字符串才当作指令检查，@ 开头的普通文本（如 @ 某人）不检查
+func isDirectiveName(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isWordByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
@@ -0,0 +1,36 @@
+package value
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		body interface{}
+		want []string // 每个问题中应该包含的文本，为空表示没有问题
+	}{
+		{map[string]interface{}{"id": "@uuid", "n": "@randInt:6", "d": "@dup:5:@email"}, nil},
+		{map[string]interface{}{"x": "@nope"}, []string{"x: unknown directive @nope"}},
+		{map[string]interface{}{"x": "@uuid:3"}, []string{"does not take arguments"}},
+		{map[string]interface{}{"x": "@randInt:abc"}, []string{"expects a non-negative digits"}},
+		{map[string]interface{}{"x": "@randInt:19"}, []string{"at most 18 digits"}},
+		{[]interface{}{"id @uuid"}, []string{"[0]: placeholder @uuid inside text"}},
+		{map[string]interface{}{"x": "@dup:200:@email"}, []string{"@dup percent must be"}},
+		{map[string]interface{}{"x": "@dup:5:@dup:5:@email"}, []string{"cannot wrap another @dup"}},
+		{map[string]interface{}{"x": "@dist:missing"}, []string{`unknown distribution "missing"`}},
+		{"user@example.com", nil},
+	}
+	for _, tt := range tests {
+		problems := Lint("body", tt.body)
+		if len(problems) != len(tt.want) {
+			t.Errorf("Lint(%v) = %q, want %d problems", tt.body, problems, len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(problems[i], want) {
+				t.Errorf("Lint(%v)[%d] = %q, want it to contain %q", tt.body, i, problems[i], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package value
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 指令统计默认关闭，关闭时生成值只多一次原子读
+var metricsEnabled atomic.Bool
+
+// 按指令名保存 *directiveCounter
+var directiveCounters sync.Map
+
+type directiveCounter struct {
+	calls atomic.Int64
+	nanos atomic.Int64
+	bytes atomic.Int64
+}
+
+// EnableMetrics 开启或关闭所有 Handler 的指令统计
+func EnableMetrics(enabled bool) {
+	metricsEnabled.Store(enabled)
+}
+
+// DirectiveStats 一个指令的调用次数、累计耗时和生成值的字节数（按 JSON 文本长度估算）
+type DirectiveStats struct {
+	Directive string        `json:"directive"`
+	Calls     int64         `json:"calls"`
+	Duration  time.Duration `json:"duration"`
+	Bytes     int64         `json:"bytes"`
+}
+
+// Metrics 返回开启统计以来各指令的统计，按指令名排序
+func Metrics() []DirectiveStats {
+	var stats []DirectiveStats
+	directiveCounters.Range(func(key, value interface{}) bool {
+		c := value.(*directiveCounter)
+		stats = append(stats, DirectiveStats{
+			Directive: key.(string),
+			Calls:     c.calls.Load(),
+			Duration:  time.Duration(c.nanos.Load()),
+			Bytes:     c.bytes.Load(),
+		})
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Directive < stats[j].Directive })
+	return stats
+}
+
+// 记录一次指令调用，不是指令的字符串原样返回，不计入统计
+func record(placeholder string, generated interface{}, elapsed time.Duration) {
+	if s, ok := generated.(string); ok && s == placeholder {
+		return
+	}
+	directive, _, _ := strings.Cut(placeholder, ":")
+	counter, ok := directiveCounters.Load(directive)
+	if !ok {
+		counter, _ = directiveCounters.LoadOrStore(directive, &directiveCounter{})
+	}
+	c := counter.(*directiveCounter)
+	c.calls.Add(1)
+	c.nanos.Add(int64(elapsed))
+	c.bytes.Add(int64(valueSize(generated)))
+}
+
+func valueSize(v interface{}) int {
+	switch v := v.(type) {
+	case string:
+		return len(v) + 2
+	case int64:
+		return len(strconv.FormatInt(v, 10))
+	case float64:
+		return len(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		if v {
+			return 4
+		}
+		return 5
+	default:
+		return len(fmt.Sprint(v))
+	}
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出指令统计
+func WritePrometheus(w io.Writer) {
+	stats := Metrics()
+	metrics := []struct {
+		name, help string
+		value      func(DirectiveStats) string
+	}{
+		{"mockgo_value_directive_calls_total", "Placeholder directives evaluated by the value handler.",
+			func(s DirectiveStats) string { return strconv.FormatInt(s.Calls, 10) }},
+		{"mockgo_value_directive_seconds_total", "Time spent generating values per directive.",
+			func(s DirectiveStats) string { return strconv.FormatFloat(s.Duration.Seconds(), 'g', -1, 64) }},
+		{"mockgo_value_generated_bytes_total", "Approximate JSON size of the generated values per directive.",
+			func(s DirectiveStats) string { return strconv.FormatInt(s.Bytes, 10) }},
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", m.name, m.help, m.name)
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s{directive=%q} %s\n", m.name, s.Directive, m.value(s))
+		}
+	}
+}
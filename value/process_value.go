@@ -2,26 +2,68 @@ package value
 
 import (
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/brianvoe/gofakeit/v6"
 )
 
+// 固定的随机种子，为 0 时按当前时间播种
+var seed int64
+
+// SetSeed 设置之后创建的 Handler 使用的随机种子，相同种子生成相同的数据，传 0 恢复随机
+func SetSeed(s int64) {
+	seed = s
+}
+
 func NewValueHandler() *Handler {
 	// 设置随机种子
+	if seed != 0 {
+		rand.Seed(seed)
+		return &Handler{
+			fake: newFaker(seed),
+			r:    rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)}),
+		}
+	}
 	rand.Seed(time.Now().UnixNano())
-	gofakeit.Seed(0)
 	return &Handler{
-		fake: gofakeit.New(0),
-		r:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		fake: newFaker(0),
+		r:    rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano()).(rand.Source64)}),
 	}
 }
 
+// Handler 生成占位符的值。同一个 Handler 会被多个请求协程同时使用：faker 自带锁，r 使用加锁的随机源，seen 由 mu 保护
 type Handler struct {
-	fake *gofakeit.Faker
+	fake faker
 	r    *rand.Rand
+
+	mu   sync.Mutex
+	seen map[string][]interface{} // @dup 占位符已生成的值
+}
+
+// lockedSource 可以并发使用的随机源，rand.New 返回的 Rand 只有 Read 有自己的状态，其他方法都只调用源
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
 }
 
 // ProcessDynamicValues 处理动态值占位符
@@ -40,9 +82,15 @@ func (h *Handler) ProcessDynamicValues(body interface{}) interface{} {
 }
 
 func (h *Handler) ProcessDynamicMap(mapValue map[string]interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	for k, v := range mapValue {
-		result[k] = h.ProcessDynamicValues(v)
+	// 按键排序处理，固定种子时每次生成的数据相同
+	keys := make([]string, 0, len(mapValue))
+	for k := range mapValue {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make(map[string]interface{}, len(mapValue))
+	for _, k := range keys {
+		result[k] = h.ProcessDynamicValues(mapValue[k])
 	}
 	return result
 }
@@ -56,9 +104,18 @@ func (h *Handler) processArray(arr []interface{}) []interface{} {
 	return result
 }
 
-// generateDynamicValue 根据占位符生成动态值
+// generateDynamicValue 根据占位符生成动态值，开启统计时记录指令的调用次数和耗时
 func (h *Handler) generateDynamicValue(placeholder string) interface{} {
+	if !metricsEnabled.Load() {
+		return h.generate(placeholder)
+	}
+	start := time.Now()
+	generated := h.generate(placeholder)
+	record(placeholder, generated, time.Since(start))
+	return generated
+}
 
+func (h *Handler) generate(placeholder string) interface{} {
 	// 分割指令和参数
 	parts := strings.SplitN(placeholder, ":", 2)
 	directive := parts[0]
@@ -80,7 +137,7 @@ func (h *Handler) generateDynamicValue(placeholder string) interface{} {
 	case "@word":
 		return h.fake.Word()
 	case "@sentence":
-		return h.fake.Sentence(5)
+		return h.fake.Sentence()
 	case "@uuid":
 		return h.fake.UUID()
 	case "@timestamp":
@@ -93,6 +150,25 @@ func (h *Handler) generateDynamicValue(placeholder string) interface{} {
 		return h.fake.Bool()
 	case "@float":
 		return h.fake.Float64Range(0, 1000)
+	case "@price":
+		return h.fake.Price(0, 1000)
+	case "@currency":
+		return h.fake.CurrencyShort()
+	case "@currencyName":
+		return h.fake.CurrencyLong()
+	case "@country":
+		return h.fake.Country()
+	case "@countryCode":
+		return h.fake.CountryAbr()
+	case "@locale":
+		return h.fake.LanguageBCP()
+	case "@dup":
+		return h.generateDuplicate(placeholder, args)
+	case "@dist":
+		if v, ok := h.pickDistribution(args); ok {
+			return v
+		}
+		return placeholder
 	default:
 		return placeholder
 	}
@@ -0,0 +1,97 @@
+package value
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirectives(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	tests := []struct {
+		placeholder string
+		check       func(v interface{}) bool
+	}{
+		{"@randInt:4", func(v interface{}) bool { n, ok := v.(int64); return ok && n >= 1000 && n <= 9999 }},
+		{"@randInt", func(v interface{}) bool { _, ok := v.(int64); return ok }},
+		{"@randString:12", func(v interface{}) bool { s, ok := v.(string); return ok && len(s) == 12 }},
+		{"@randString", func(v interface{}) bool { s, ok := v.(string); return ok && len(s) == 10 }},
+		{"@email", func(v interface{}) bool { s, ok := v.(string); return ok && strings.Contains(s, "@") }},
+		{"@uuid", func(v interface{}) bool { s, ok := v.(string); return ok && uuid.MatchString(s) }},
+		{"@bool", func(v interface{}) bool { _, ok := v.(bool); return ok }},
+		{"@float", func(v interface{}) bool { f, ok := v.(float64); return ok && f >= 0 && f <= 1000 }},
+		{"@timestamp", func(v interface{}) bool { n, ok := v.(int64); return ok && time.Since(time.Unix(n, 0)) < time.Minute }},
+		{"@date", func(v interface{}) bool {
+			s, ok := v.(string)
+			_, err := time.Parse("2006-01-02", s)
+			return ok && err == nil
+		}},
+		{"@datetime", func(v interface{}) bool {
+			s, ok := v.(string)
+			_, err := time.Parse("2006-01-02 15:04:05", s)
+			return ok && err == nil
+		}},
+		{"@countryCode", func(v interface{}) bool { s, ok := v.(string); return ok && s != "" }},
+		// 未知指令和普通文本原样返回
+		{"@unknown", func(v interface{}) bool { return v == "@unknown" }},
+		{"plain text", func(v interface{}) bool { return v == "plain text" }},
+		{"mail me at @email", func(v interface{}) bool { return v == "mail me at @email" }},
+	}
+	h := NewValueHandler()
+	for _, tt := range tests {
+		t.Run(tt.placeholder, func(t *testing.T) {
+			if v := h.ProcessDynamicValues(tt.placeholder); !tt.check(v) {
+				t.Fatalf("%s generated %v (%T)", tt.placeholder, v, v)
+			}
+		})
+	}
+}
+
+func TestProcessDynamicValuesNested(t *testing.T) {
+	h := NewValueHandler()
+	body := map[string]interface{}{
+		"id":    "@uuid",
+		"count": 3,
+		"tags":  []interface{}{"@word", "fixed"},
+		"owner": map[string]interface{}{"name": "@name", "active": true},
+	}
+	got, ok := h.ProcessDynamicValues(body).(map[string]interface{})
+	if !ok {
+		t.Fatalf("result is %T", got)
+	}
+	if got["count"] != 3 || got["id"] == "@uuid" {
+		t.Fatalf("got %v", got)
+	}
+	tags := got["tags"].([]interface{})
+	if tags[0] == "@word" || tags[1] != "fixed" {
+		t.Fatalf("tags = %v", tags)
+	}
+	owner := got["owner"].(map[string]interface{})
+	if owner["name"] == "@name" || owner["active"] != true {
+		t.Fatalf("owner = %v", owner)
+	}
+	if body["id"] != "@uuid" {
+		t.Fatal("the template was modified")
+	}
+}
+
+// 相同种子生成相同的数据
+func TestSeed(t *testing.T) {
+	SetSeed(7)
+	defer SetSeed(0)
+	body := map[string]interface{}{"a": "@randInt:6", "b": "@randString:8", "c": "@name", "d": "@dup:50:@word"}
+	var results []interface{}
+	for i := 0; i < 2; i++ {
+		h := NewValueHandler()
+		var run []interface{}
+		for j := 0; j < 20; j++ {
+			run = append(run, h.ProcessDynamicValues(body))
+		}
+		results = append(results, run)
+	}
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Fatal("the same seed generated different data")
+	}
+}
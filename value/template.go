@@ -0,0 +1,30 @@
+package value
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TreeWu/mock-go/logging"
+	"github.com/goccy/go-yaml"
+)
+
+var logger = logging.For("value")
+
+// LoadTemplate 读取文档模板，YAML 或 JSON 对象，字段值可以使用占位符
+func LoadTemplate(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read template: %v", err)
+	}
+	var template map[string]interface{}
+	if err := yaml.Unmarshal(content, &template); err != nil {
+		return nil, fmt.Errorf("parse template %s: %v", path, err)
+	}
+	if len(template) == 0 {
+		return nil, fmt.Errorf("template %s is empty", path)
+	}
+	for _, problem := range Lint("", template) {
+		logger.Warn("模板中的占位符有问题", "template", path, "problem", problem)
+	}
+	return template, nil
+}